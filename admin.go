@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCallsHandler returns the recorded calls for support staff
+func ListCallsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		calls := pipedriveService.listCallMappings()
+		if tenant := requestTenant(c); tenant != "" {
+			for callID, mapping := range calls {
+				if mapping.Tenant != tenant {
+					delete(calls, callID)
+				}
+			}
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d calls", len(calls)),
+			Data:    calls,
+		})
+	}
+}
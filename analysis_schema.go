@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+)
+
+// AnalysisFieldSpec describes one expected key in Retell's custom_analysis_data
+type AnalysisFieldSpec struct {
+	Type           string   `json:"type"` // "string", "number", "boolean"
+	Required       bool     `json:"required"`
+	Enum           []string `json:"enum,omitempty"`
+	PipedriveField string   `json:"pipedrive_field,omitempty"` // Person field key the value is written to
+}
+
+// AnalysisSchema describes the custom analysis data an assistant is expected to produce
+type AnalysisSchema struct {
+	Fields map[string]AnalysisFieldSpec `json:"fields"`
+}
+
+// AnalysisValidationResult holds the outcome of validating custom analysis data
+type AnalysisValidationResult struct {
+	Valid   map[string]interface{} // Keys that passed validation
+	Missing []string               // Required keys that were absent
+	Invalid []string               // Keys present with the wrong type or value
+	Unknown []string               // Keys not declared in the schema
+}
+
+// HasDrift returns true if the analysis data did not match the schema
+func (r AnalysisValidationResult) HasDrift() bool {
+	return len(r.Missing) > 0 || len(r.Invalid) > 0 || len(r.Unknown) > 0
+}
+
+// loadAnalysisSchemas parses the RETELL_ANALYSIS_SCHEMAS JSON, keyed by agent ID ("*" applies to all agents)
+func loadAnalysisSchemas(raw string) map[string]AnalysisSchema {
+	schemas := make(map[string]AnalysisSchema)
+	if raw == "" {
+		return schemas
+	}
+	if err := json.Unmarshal([]byte(raw), &schemas); err != nil {
+		log.Printf("⚠️ Invalid RETELL_ANALYSIS_SCHEMAS, ignoring: %v", err)
+		return make(map[string]AnalysisSchema)
+	}
+	return schemas
+}
+
+// AnalysisSchemaFor returns the schema configured for an agent, falling back to "*"
+func (c *Config) AnalysisSchemaFor(agentID string) (AnalysisSchema, bool) {
+	if schema, ok := c.AnalysisSchemas[agentID]; ok {
+		return schema, true
+	}
+	schema, ok := c.AnalysisSchemas["*"]
+	return schema, ok
+}
+
+// Validate checks custom analysis data against the schema
+func (s AnalysisSchema) Validate(data map[string]interface{}) AnalysisValidationResult {
+	result := AnalysisValidationResult{Valid: make(map[string]interface{})}
+
+	for key, spec := range s.Fields {
+		value, ok := data[key]
+		if !ok || value == nil {
+			if spec.Required {
+				result.Missing = append(result.Missing, key)
+			}
+			continue
+		}
+		if !spec.accepts(value) {
+			result.Invalid = append(result.Invalid, key)
+			continue
+		}
+		result.Valid[key] = value
+	}
+
+	for key := range data {
+		if _, ok := s.Fields[key]; !ok {
+			result.Unknown = append(result.Unknown, key)
+		}
+	}
+
+	sort.Strings(result.Missing)
+	sort.Strings(result.Invalid)
+	sort.Strings(result.Unknown)
+	return result
+}
+
+// PipedriveFields maps validated analysis values onto their configured Pipedrive field keys
+func (s AnalysisSchema) PipedriveFields(valid map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for key, value := range valid {
+		if spec := s.Fields[key]; spec.PipedriveField != "" {
+			fields[spec.PipedriveField] = value
+		}
+	}
+	return fields
+}
+
+// accepts returns true if the value matches the field's type and enum
+func (f AnalysisFieldSpec) accepts(value interface{}) bool {
+	switch f.Type {
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string", "":
+		str, ok := value.(string)
+		if !ok {
+			return false
+		}
+		if len(f.Enum) == 0 {
+			return true
+		}
+		for _, allowed := range f.Enum {
+			if str == allowed {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
@@ -28,6 +28,9 @@ type Config struct {
 
 	// Logging configuration
 	LogLevel string
+
+	// Per-tenant overrides for additional Pipedrive companies
+	Tenants map[string]TenantConfig
 }
 
 // LoadConfig loads configuration from environment variables with defaults
@@ -54,6 +57,9 @@ func LoadConfig() *Config {
 
 		// Logging
 		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		// Tenants (JSON: tenant -> company ID and credential overrides)
+		Tenants: loadTenants(getEnv("TENANTS", "")),
 	}
 
 	return config
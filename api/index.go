@@ -12,16 +12,16 @@ import (
 )
 
 var (
-	router *gin.Engine
-	pipedriveService *PipedriveService
+	router  *gin.Engine
+	tenants *TenantRegistry
 )
 
 func init() {
 	// Load environment variables
 	config := LoadConfig()
 
-	// Initialize services
-	pipedriveService = NewPipedriveService(config)
+	// Services are created per tenant on first use and reused by later invocations
+	tenants = NewTenantRegistry(config)
 
 	// Set Gin to release mode
 	gin.SetMode(gin.ReleaseMode)
@@ -81,17 +81,14 @@ func setupRoutes() {
 		})
 	})
 
-	// Webhook endpoints
-	router.POST("/webhook/retell", RetellWebhookHandler(pipedriveService))
-	router.POST("/webhook/cal", CalWebhookHandler(pipedriveService))
-	router.POST("/webhook/retell/analyzed", RetellCallAnalyzedHandler(pipedriveService))
-	router.POST("/webhook/pipedrive/lead", PipedriveLeadWebhookHandler(pipedriveService))
-
-	// API versions
-	router.POST("/api/webhook/retell", RetellWebhookHandler(pipedriveService))
-	router.POST("/api/webhook/cal", CalWebhookHandler(pipedriveService))
-	router.POST("/api/webhook/retell/analyzed", RetellCallAnalyzedHandler(pipedriveService))
-	router.POST("/api/webhook/pipedrive/lead", PipedriveLeadWebhookHandler(pipedriveService))
+	// Webhook endpoints; /t/:tenant/... selects a tenant explicitly, otherwise
+	// Pipedrive webhooks are routed by meta.company_id and the rest use the default tenant
+	for _, prefix := range []string{"", "/api", "/t/:tenant", "/api/t/:tenant"} {
+		router.POST(prefix+"/webhook/retell", tenants.forTenant(RetellWebhookHandler))
+		router.POST(prefix+"/webhook/cal", tenants.forTenant(CalWebhookHandler))
+		router.POST(prefix+"/webhook/retell/analyzed", tenants.forTenant(RetellCallAnalyzedHandler))
+		router.POST(prefix+"/webhook/pipedrive/lead", tenants.forTenant(PipedriveLeadWebhookHandler))
+	}
 
 	// Test endpoints
 	router.POST("/test/completed", func(c *gin.Context) {
@@ -105,7 +102,7 @@ func setupRoutes() {
 			Timestamp:    time.Now().Format(time.RFC3339),
 		}
 
-		if err := pipedriveService.ProcessRetellCall(testData); err != nil {
+		if err := tenants.Service(DefaultTenant).ProcessRetellCall(testData); err != nil {
 			c.JSON(500, gin.H{
 				"success": false,
 				"message": "Test failed: " + err.Error(),
@@ -196,7 +193,7 @@ func setupRoutes() {
 			},
 		}
 
-		if err := pipedriveService.ProcessPipedriveLead(testData); err != nil {
+		if err := tenants.Service(DefaultTenant).ProcessPipedriveLead(testData); err != nil {
 			c.JSON(500, gin.H{
 				"success": false,
 				"message": "Test failed: " + err.Error(),
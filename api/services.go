@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,6 +30,7 @@ type CallMapping struct {
 type PipedriveService struct {
 	config       *Config
 	httpClient   *http.Client
+	mu           sync.RWMutex           // Guards callMappings across concurrent invocations
 	callMappings map[string]CallMapping // Maps callID to call info
 }
 
@@ -132,7 +134,6 @@ func (p *PipedriveService) makePipedriveRequest(method, endpoint string, body in
 	req.Header.Set("Accept", "application/json")
 
 	log.Printf("🌐 Making %s request to Pipedrive: %s", method, endpoint)
-	log.Printf("🔗 Full URL: %s", url)
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -676,8 +677,6 @@ func (p *PipedriveService) ProcessPipedriveLead(payload PipedriveLeadWebhookPayl
 	// Check configuration status
 	log.Printf("🔧 [DEBUG] Pipedrive configured: %t", p.config.HasPipedriveConfig())
 	log.Printf("🔧 [DEBUG] Retell AI configured: %t", p.config.HasRetellConfig())
-	log.Printf("🔧 [DEBUG] Pipedrive API Key: %s", p.config.PipedriveAPIKey)
-	log.Printf("🔧 [DEBUG] Retell API Key: %s", p.config.RetellAPIKey)
 	log.Printf("🔧 [DEBUG] Retell Assistant ID: %s", p.config.RetellAssistantID)
 
 	// Only process lead creation events
@@ -823,7 +822,6 @@ func (p *PipedriveService) CreateRetellCall(phoneNumber, personName, personEmail
 
 	log.Printf("🌐 Making Retell AI call to: %s", url)
 	log.Printf("📤 Request Body: %s", string(jsonData))
-	log.Printf("🔑 Using API Key: %s...", p.config.RetellAPIKey[:min(8, len(p.config.RetellAPIKey))])
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
@@ -1346,7 +1344,6 @@ func (p *PipedriveService) UpdatePersonWithCallData(personID int, transcript, du
 func (p *PipedriveService) ProcessCalAppointment(payload CalWebhookPayload) error {
 	log.Printf("🔧 [DEBUG] ProcessCalAppointment called")
 	log.Printf("🔧 [DEBUG] HasPipedriveConfig: %v", p.config.HasPipedriveConfig())
-	log.Printf("🔧 [DEBUG] PIPEDRIVE_API_KEY: %s", p.config.PipedriveAPIKey)
 
 	if p.config.HasPipedriveConfig() {
 		log.Printf("🚀 [REAL PIPEDRIVE] Processing Cal.com appointment webhook")
@@ -1547,6 +1544,8 @@ func extractPhoneFromPerson(person *PipedrivePerson) string {
 
 // storeCallMapping stores call information for later retrieval
 func (p *PipedriveService) storeCallMapping(callID, personName, personEmail, phoneNumber, leadTitle string, personID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.callMappings[callID] = CallMapping{
 		PersonName:  personName,
 		PersonEmail: personEmail,
@@ -1560,6 +1559,8 @@ func (p *PipedriveService) storeCallMapping(callID, personName, personEmail, pho
 
 // getCallMapping retrieves call information by call ID
 func (p *PipedriveService) getCallMapping(callID string) (CallMapping, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	mapping, exists := p.callMappings[callID]
 	return mapping, exists
 }
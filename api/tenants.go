@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTenant serves requests that name no tenant and match no company ID
+const DefaultTenant = "default"
+
+// TenantConfig overrides the environment configuration for one Pipedrive company
+type TenantConfig struct {
+	CompanyID           string `json:"company_id"`
+	PipedriveAPIKey     string `json:"pipedrive_api_key"`
+	PipedriveBaseURL    string `json:"pipedrive_base_url"`
+	RetellAPIKey        string `json:"retell_api_key"`
+	RetellAssistantID   string `json:"retell_assistant_id"`
+	RetellFromNumber    string `json:"retell_from_number"`
+	RetellWebhookSecret string `json:"retell_webhook_secret"`
+	CalWebhookSecret    string `json:"cal_webhook_secret"`
+}
+
+// TenantRegistry builds one service per tenant on first use and reuses it across invocations
+type TenantRegistry struct {
+	mu        sync.RWMutex
+	base      *Config
+	tenants   map[string]TenantConfig
+	companies map[string]string // Pipedrive company ID -> tenant
+	services  map[string]*PipedriveService
+}
+
+// NewTenantRegistry creates a registry from the base config and its TENANTS overrides
+func NewTenantRegistry(base *Config) *TenantRegistry {
+	registry := &TenantRegistry{
+		base:      base,
+		tenants:   base.Tenants,
+		companies: make(map[string]string),
+		services:  make(map[string]*PipedriveService),
+	}
+	if base.PipedriveCompanyID != "" {
+		registry.companies[base.PipedriveCompanyID] = DefaultTenant
+	}
+	for name, tenant := range base.Tenants {
+		if tenant.CompanyID != "" {
+			registry.companies[tenant.CompanyID] = name
+		}
+	}
+	return registry
+}
+
+// configFor returns the base config with a tenant's overrides applied
+func (r *TenantRegistry) configFor(tenant string) *Config {
+	config := *r.base
+	config.Tenants = nil
+	overrides, ok := r.tenants[tenant]
+	if !ok {
+		return &config
+	}
+	set := func(target *string, value string) {
+		if value != "" {
+			*target = value
+		}
+	}
+	set(&config.PipedriveCompanyID, overrides.CompanyID)
+	set(&config.PipedriveAPIKey, overrides.PipedriveAPIKey)
+	set(&config.PipedriveBaseURL, overrides.PipedriveBaseURL)
+	set(&config.RetellAPIKey, overrides.RetellAPIKey)
+	set(&config.RetellAssistantID, overrides.RetellAssistantID)
+	set(&config.RetellFromNumber, overrides.RetellFromNumber)
+	set(&config.RetellWebhookSecret, overrides.RetellWebhookSecret)
+	set(&config.CalWebhookSecret, overrides.CalWebhookSecret)
+	return &config
+}
+
+// Known reports whether a tenant is configured
+func (r *TenantRegistry) Known(tenant string) bool {
+	_, ok := r.tenants[tenant]
+	return ok || tenant == DefaultTenant
+}
+
+// Service returns the tenant's service, creating it once even under concurrent requests
+func (r *TenantRegistry) Service(tenant string) *PipedriveService {
+	r.mu.RLock()
+	service, ok := r.services[tenant]
+	r.mu.RUnlock()
+	if ok {
+		return service
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if service, ok := r.services[tenant]; ok {
+		return service
+	}
+	service = NewPipedriveService(r.configFor(tenant))
+	r.services[tenant] = service
+	log.Printf("🏢 Created service for tenant %s", tenant)
+	return service
+}
+
+// TenantForCompany maps a Pipedrive company ID to its tenant
+func (r *TenantRegistry) TenantForCompany(companyID string) (string, bool) {
+	tenant, ok := r.companies[companyID]
+	return tenant, ok
+}
+
+// resolveTenant picks the tenant from the /t/:tenant prefix, then the payload's meta.company_id.
+// A company ID no tenant is configured for is refused rather than served as the default tenant.
+func (r *TenantRegistry) resolveTenant(c *gin.Context) (string, error) {
+	if tenant := c.Param("tenant"); tenant != "" {
+		return tenant, nil
+	}
+	if c.Request.Body == nil || c.Request.Method != "POST" {
+		return DefaultTenant, nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read request body: %v", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var envelope struct {
+		Meta struct {
+			CompanyID json.Number `json:"company_id"`
+		} `json:"meta"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Meta.CompanyID != "" {
+		if tenant, ok := r.TenantForCompany(envelope.Meta.CompanyID.String()); ok {
+			return tenant, nil
+		}
+		log.Printf("⚠️ Unknown Pipedrive company %s, refusing the webhook", envelope.Meta.CompanyID)
+		return "", fmt.Errorf("unknown Pipedrive company: %s", envelope.Meta.CompanyID)
+	}
+	return DefaultTenant, nil
+}
+
+// forTenant wraps a handler constructor so each request runs against its tenant's service
+func (r *TenantRegistry) forTenant(build func(*PipedriveService) gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := r.resolveTenant(c)
+		if err != nil {
+			c.JSON(404, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		if !r.Known(tenant) {
+			c.JSON(404, WebhookResponse{
+				Success: false,
+				Message: "Unknown tenant: " + tenant,
+			})
+			return
+		}
+		c.Set("tenant", tenant)
+		build(r.Service(tenant))(c)
+	}
+}
+
+// loadTenants parses TENANTS (JSON: tenant -> overrides)
+func loadTenants(raw string) map[string]TenantConfig {
+	tenants := make(map[string]TenantConfig)
+	if strings.TrimSpace(raw) == "" {
+		return tenants
+	}
+	if err := json.Unmarshal([]byte(raw), &tenants); err != nil {
+		log.Printf("⚠️ Invalid TENANTS, ignoring: %v", err)
+		return make(map[string]TenantConfig)
+	}
+	return tenants
+}
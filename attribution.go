@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attributionKeys are the attribution parameters captured from requests and landing page URLs
+var attributionKeys = []string{
+	"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
+	"gclid", "fbclid", "referrer", "landing_page",
+}
+
+// Attribution limits: values come from anyone who can load a landing page and reach the agent's prompt
+const (
+	maxAttributionValue  = 200                 // Bytes kept of each value
+	maxAttributionLeads  = 10000               // Leads whose attribution is kept; the oldest are dropped first
+	attributionRetention = 30 * 24 * time.Hour // How long a lead's attribution is kept for its calls
+)
+
+// Attribution holds where a lead came from, by attribution key
+type Attribution map[string]string
+
+// sanitize keeps only printable text in each value, without template or markup delimiters, and caps
+// its length; values left empty are removed
+func (a Attribution) sanitize() {
+	for key, value := range a {
+		cleaned := strings.Map(func(r rune) rune {
+			switch {
+			case unicode.IsSpace(r):
+				return ' '
+			case !unicode.IsPrint(r), strings.ContainsRune("{}<>`", r):
+				return -1
+			}
+			return r
+		}, value)
+		cleaned = truncateRunes(strings.Join(strings.Fields(cleaned), " "), maxAttributionValue)
+		if cleaned == "" {
+			delete(a, key)
+			continue
+		}
+		a[key] = cleaned
+	}
+}
+
+// merge fills keys missing from a with the values from other
+func (a Attribution) merge(other Attribution) {
+	for key, value := range other {
+		if a[key] == "" && value != "" {
+			a[key] = value
+		}
+	}
+}
+
+// attributionFromQuery reads the attribution keys from URL query values
+func attributionFromQuery(query url.Values) Attribution {
+	attribution := make(Attribution)
+	for _, key := range attributionKeys {
+		if value := strings.TrimSpace(query.Get(key)); value != "" {
+			attribution[key] = value
+		}
+	}
+	return attribution
+}
+
+// attributionFromURL reads UTM parameters from a landing page URL and records the page itself
+func attributionFromURL(raw string) Attribution {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return make(Attribution)
+	}
+	attribution := attributionFromQuery(parsed.Query())
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	attribution["landing_page"] = parsed.String()
+	return attribution
+}
+
+// attributionFromRequest reads attribution from a webhook or form request's query string and Referer header
+func attributionFromRequest(c *gin.Context) Attribution {
+	attribution := attributionFromQuery(c.Request.URL.Query())
+	if referrer := c.GetHeader("Referer"); referrer != "" {
+		attribution.merge(attributionFromURL(referrer))
+		if attribution["referrer"] == "" {
+			attribution["referrer"] = referrer
+		}
+	}
+	return attribution
+}
+
+// attributionFields splits attribution into Pipedrive person and lead fields using ATTRIBUTION_FIELD_MAPPING
+func (c *Config) attributionFields(attribution Attribution) (person, lead map[string]interface{}) {
+	person = make(map[string]interface{})
+	lead = make(map[string]interface{})
+	for key, value := range attribution {
+		target := c.AttributionFieldMapping[key]
+		switch {
+		case strings.HasPrefix(target, "person."):
+			person[strings.TrimPrefix(target, "person.")] = value
+		case strings.HasPrefix(target, "lead."):
+			lead[strings.TrimPrefix(target, "lead.")] = value
+		}
+	}
+	return person, lead
+}
+
+// attributionNoteSection formats attribution for notes
+func attributionNoteSection(attribution Attribution) string {
+	if len(attribution) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(attribution))
+	for key := range attribution {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var section strings.Builder
+	section.WriteString("\n\nAttribution:")
+	for _, key := range keys {
+		section.WriteString(fmt.Sprintf("\n%s: %s", key, attribution[key]))
+	}
+	return section.String()
+}
+
+// leadAttribution is a lead's attribution and when it was first recorded
+type leadAttribution struct {
+	values  Attribution
+	addedAt time.Time
+}
+
+// AttributionStore keeps lead attribution by lead ID for attributionRetention, at most maxAttributionLeads
+type AttributionStore struct {
+	mu    sync.RWMutex
+	leads map[string]*leadAttribution
+}
+
+// NewAttributionStore creates an empty attribution store
+func NewAttributionStore() *AttributionStore {
+	return &AttributionStore{leads: make(map[string]*leadAttribution)}
+}
+
+// Set stores a lead's attribution, keeping values recorded earlier
+func (s *AttributionStore) Set(leadID string, attribution Attribution) {
+	if leadID == "" || len(attribution) == 0 {
+		return
+	}
+	cleaned := make(Attribution)
+	cleaned.merge(attribution)
+	cleaned.sanitize()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.leads[leadID]
+	if !ok {
+		s.pruneLocked()
+		existing = &leadAttribution{values: make(Attribution), addedAt: time.Now()}
+		s.leads[leadID] = existing
+	}
+	existing.values.merge(cleaned)
+}
+
+// pruneLocked drops expired leads and, when full, the oldest lead to make room; callers hold mu
+func (s *AttributionStore) pruneLocked() {
+	cutoff := time.Now().Add(-attributionRetention)
+	oldestID := ""
+	for id, lead := range s.leads {
+		if lead.addedAt.Before(cutoff) {
+			delete(s.leads, id)
+			continue
+		}
+		if oldestID == "" || lead.addedAt.Before(s.leads[oldestID].addedAt) {
+			oldestID = id
+		}
+	}
+	if len(s.leads) >= maxAttributionLeads {
+		delete(s.leads, oldestID)
+	}
+}
+
+// Get returns a copy of a lead's attribution
+func (s *AttributionStore) Get(leadID string) Attribution {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attribution := make(Attribution)
+	if lead, ok := s.leads[leadID]; ok && time.Since(lead.addedAt) <= attributionRetention {
+		attribution.merge(lead.values)
+	}
+	return attribution
+}
+
+// recordLeadAttribution stores attribution for a lead created in Pipedrive and writes it to the mapped fields
+func (p *PipedriveService) recordLeadAttribution(leadID string, personID int, attribution Attribution) {
+	attribution.sanitize()
+	if len(attribution) == 0 {
+		return
+	}
+	p.attributions.Set(leadID, attribution)
+
+	personFields, leadFields := p.config.attributionFields(attribution)
+	if len(leadFields) > 0 {
+		if err := p.UpdateLead(leadID, leadFields); err != nil {
+			p.logf("⚠️ Warning: Failed to write attribution to lead %s: %v", leadID, err)
+		}
+	}
+	if len(personFields) > 0 && personID != 0 {
+		resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", personID), personFields)
+		if err != nil {
+			p.logf("⚠️ Warning: Failed to write attribution to person %d: %v", personID, err)
+			return
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Audit actors
+const (
+	AuditActorSystem = "system"
+	AuditActorAgent  = "agent"
+)
+
+// AuditEntry records one automated action taken on a person
+type AuditEntry struct {
+	Seq       int64     `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	PersonID  int       `json:"person_id,omitempty"`
+	CallID    string    `json:"call_id,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"` // Tenant owning the call, when the entry has one
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// AuditLog is a log of automated actions holding the most recent AUDIT_MAX_ENTRIES, persisted as
+// JSON lines to AUDIT_LOG_FILE
+type AuditLog struct {
+	mu       sync.Mutex
+	seq      int64
+	entries  []AuditEntry // In sequence order
+	max      int
+	file     *os.File // nil when entries are kept in memory only
+	lines    int      // Entries in the file, compacted to the retained ones past twice max
+	shared   *RedisLocker
+	tenantOf func(entry AuditEntry) string
+}
+
+// NewAuditLog creates an audit log keeping up to max entries, replaying the newest entries already
+// in the file at path
+func NewAuditLog(path string, max int) *AuditLog {
+	a := &AuditLog{max: max}
+	if path == "" {
+		return a
+	}
+
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				log.Printf("⚠️ Warning: Skipping unreadable audit entry in %s: %v", path, err)
+				continue
+			}
+			a.lines++
+			a.entries = append(a.entries, entry)
+			a.trimLocked()
+			if entry.Seq > a.seq {
+				a.seq = entry.Seq
+			}
+		}
+		existing.Close()
+		if a.max > 0 && len(a.entries) > a.max {
+			a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-a.max:]...)
+		}
+		log.Printf("📝 Loaded %d audit entries from %s", len(a.entries), path)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("⚠️ Warning: Cannot open AUDIT_LOG_FILE %s, keeping the audit log in memory: %v", path, err)
+		return a
+	}
+	a.file = file
+	if a.lines > len(a.entries) {
+		if err := a.rewriteLocked(); err != nil {
+			log.Printf("⚠️ Warning: Failed to compact AUDIT_LOG_FILE %s: %v", path, err)
+		}
+	}
+	return a
+}
+
+// trimLocked drops the oldest entries beyond max, a quarter of max at a time so appends stay cheap;
+// callers hold mu
+func (a *AuditLog) trimLocked() {
+	if a.max <= 0 || len(a.entries) <= a.max+a.max/4 {
+		return
+	}
+	a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-a.max:]...)
+}
+
+// rewriteLocked replaces the file with the retained entries; callers hold mu
+func (a *AuditLog) rewriteLocked() error {
+	path := a.file.Name()
+	tmp, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range a.entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	a.file.Close()
+	a.file = file
+	a.lines = len(a.entries)
+	return nil
+}
+
+// nextSeq returns the next sequence number from the counter shared by all replicas, so entries
+// of different replicas never share one, falling back to this process's own count
+func (a *AuditLog) nextSeq() int64 {
+	if a.shared != nil {
+		seq, err := a.shared.Incr("audit:seq")
+		if err == nil {
+			return seq
+		}
+		log.Printf("⚠️ Warning: Failed to take a shared audit sequence number, using this replica's: %v", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.seq + 1
+}
+
+// Append adds an entry, stamping it with its sequence number, tenant and the current time
+func (a *AuditLog) Append(entry AuditEntry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Timestamp = entry.Timestamp.UTC()
+	if entry.Tenant == "" && a.tenantOf != nil {
+		entry.Tenant = a.tenantOf(entry)
+	}
+	entry.Seq = a.nextSeq()
+
+	a.mu.Lock()
+	if entry.Seq > a.seq {
+		a.seq = entry.Seq
+	}
+	// Keep sequence order when a concurrent append took its number first
+	i := len(a.entries)
+	for i > 0 && a.entries[i-1].Seq > entry.Seq {
+		i--
+	}
+	a.entries = append(a.entries, AuditEntry{})
+	copy(a.entries[i+1:], a.entries[i:])
+	a.entries[i] = entry
+	a.trimLocked()
+	if a.file != nil {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = a.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to persist audit entry %s: %v", entry.Action, err)
+		}
+		a.lines++
+		if a.max > 0 && a.lines > 2*a.max {
+			if err := a.rewriteLocked(); err != nil {
+				log.Printf("⚠️ Warning: Failed to compact AUDIT_LOG_FILE: %v", err)
+			}
+		}
+	}
+	a.mu.Unlock()
+	log.Printf("📝 Audit: %s %s person=%d call=%s %s", entry.Actor, entry.Action, entry.PersonID, entry.CallID, entry.Detail)
+}
+
+// LastSeq returns the sequence number of the most recent entry
+func (a *AuditLog) LastSeq() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.seq
+}
+
+// AuditQuery filters audit entries; zero values match everything
+type AuditQuery struct {
+	Tenant     string // Entries of this tenant's calls and persons only
+	PersonID   int
+	Action     string
+	Actor      string
+	From       time.Time
+	To         time.Time
+	AfterSeq   int64 // Entries with a greater sequence number
+	ThroughSeq int64 // Entries up to and including this sequence number
+}
+
+// Query returns the matching entries in the order they were appended
+func (a *AuditLog) Query(query AuditQuery) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// Entries without a call belong to the tenant of the person's latest call
+	var personTenants map[int]string
+	if query.Tenant != "" {
+		personTenants = make(map[int]string)
+		for _, entry := range a.entries {
+			if entry.Tenant != "" && entry.PersonID != 0 {
+				personTenants[entry.PersonID] = entry.Tenant
+			}
+		}
+	}
+
+	matches := []AuditEntry{}
+	for _, entry := range a.entries {
+		if query.Tenant != "" && entry.Tenant != query.Tenant && (entry.Tenant != "" || personTenants[entry.PersonID] != query.Tenant) {
+			continue
+		}
+		if query.PersonID != 0 && entry.PersonID != query.PersonID {
+			continue
+		}
+		if query.Action != "" && entry.Action != query.Action {
+			continue
+		}
+		if query.Actor != "" && entry.Actor != query.Actor {
+			continue
+		}
+		if !query.From.IsZero() && entry.Timestamp.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && !entry.Timestamp.Before(query.To) {
+			continue
+		}
+		if entry.Seq <= query.AfterSeq || (query.ThroughSeq != 0 && entry.Seq > query.ThroughSeq) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+	return matches
+}
+
+// auditObjects names the Pipedrive objects written by the service, keyed by endpoint collection
+var auditObjects = map[string]string{
+	"persons":       "person",
+	"deals":         "deal",
+	"leads":         "lead",
+	"activities":    "activity",
+	"notes":         "note",
+	"files":         "file",
+	"organizations": "organization",
+}
+
+// auditPipedriveWrite records a successful Pipedrive create/update/delete as a system action,
+// e.g. person_updated with the changed field keys or deal_created
+func (p *PipedriveService) auditPipedriveWrite(method, endpoint string, body interface{}) {
+	path := strings.TrimPrefix(strings.SplitN(endpoint, "?", 2)[0], pipedriveV2Prefix)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	object, ok := auditObjects[segments[0]]
+	if !ok {
+		object = segments[0]
+	}
+	verb := map[string]string{"POST": "created", "PUT": "updated", "PATCH": "updated", "DELETE": "deleted"}[method]
+	if verb == "" || object == "" {
+		return
+	}
+
+	entry := AuditEntry{Actor: AuditActorSystem, Action: object + "_" + verb}
+	detail := method + " " + path
+	if fields, ok := body.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(fields))
+		for key, value := range fields {
+			keys = append(keys, key)
+			if key == "person_id" {
+				entry.PersonID, _ = strconv.Atoi(fmt.Sprint(value))
+			}
+		}
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			detail += " fields: " + strings.Join(keys, ",")
+		}
+	}
+	if object == "person" && len(segments) > 1 {
+		entry.PersonID, _ = strconv.Atoi(segments[1])
+	}
+	entry.Detail = detail
+	p.auditLog.Append(entry)
+}
+
+// parseAuditTime accepts RFC 3339 (or unix) timestamps or YYYY-MM-DD dates; endOfDay makes a date inclusive
+func parseAuditTime(raw string, endOfDay bool) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.Parse("2006-01-02", raw); err == nil {
+		if endOfDay {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	ts, err := ParseTimestamp(raw, time.UTC)
+	return ts.UTC, err
+}
+
+// AuditLogHandler queries the audit log (?person_id=&from=&to=&action=&actor=), as CSV with ?format=csv
+func AuditLogHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query AuditQuery
+		var err error
+		if raw := c.Query("person_id"); raw != "" {
+			if query.PersonID, err = strconv.Atoi(raw); err != nil {
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "person_id must be a number",
+				})
+				return
+			}
+		}
+		if query.From, err = parseAuditTime(c.Query("from"), false); err == nil {
+			query.To, err = parseAuditTime(c.Query("to"), true)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "from and to must be RFC 3339 timestamps or YYYY-MM-DD dates",
+			})
+			return
+		}
+		query.Action = c.Query("action")
+		query.Actor = c.Query("actor")
+		query.Tenant = requestTenant(c)
+
+		entries := pipedriveService.auditLog.Query(query)
+		if c.Query("format") != "csv" {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: fmt.Sprintf("%d audit entries", len(entries)),
+				Data:    entries,
+			})
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="audit.csv"`)
+		c.Status(http.StatusOK)
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"seq", "timestamp", "actor", "action", "person_id", "call_id", "tenant", "detail"})
+		for _, entry := range entries {
+			personID := ""
+			if entry.PersonID != 0 {
+				personID = strconv.Itoa(entry.PersonID)
+			}
+			writer.Write([]string{
+				strconv.FormatInt(entry.Seq, 10),
+				entry.Timestamp.UTC().Format(time.RFC3339),
+				entry.Actor,
+				entry.Action,
+				personID,
+				entry.CallID,
+				entry.Tenant,
+				entry.Detail,
+			})
+		}
+		writer.Flush()
+	}
+}
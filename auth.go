@@ -0,0 +1,333 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin API roles, from least to most privileged
+const (
+	RoleReadOnly = "read-only"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+// roleRank orders roles so a higher role satisfies a lower requirement
+var roleRank = map[string]int{
+	RoleReadOnly: 1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// AdminUser is a user allowed to call the admin/dashboard APIs
+type AdminUser struct {
+	Name   string `json:"name"`
+	Token  string `json:"token"`
+	Role   string `json:"role"`
+	Tenant string `json:"tenant,omitempty"` // Restricts reads to one tenant's data; empty means all tenants
+}
+
+// loadAdminUsers parses the ADMIN_USERS JSON array
+func loadAdminUsers(raw string) []AdminUser {
+	if raw == "" {
+		return nil
+	}
+	var users []AdminUser
+	if err := json.Unmarshal([]byte(raw), &users); err != nil {
+		log.Printf("⚠️ Invalid ADMIN_USERS, ignoring: %v", err)
+		return nil
+	}
+	for i, user := range users {
+		if _, ok := roleRank[user.Role]; !ok {
+			log.Printf("⚠️ Admin user %s has unknown role %q, treating as %s", user.Name, user.Role, RoleReadOnly)
+			users[i].Role = RoleReadOnly
+		}
+	}
+	return users
+}
+
+// HasAdminAuth returns true if admin API authentication is configured
+func (c *Config) HasAdminAuth() bool {
+	return len(c.AdminUsers) > 0 || c.OIDCIssuer != ""
+}
+
+// adminAuthOpen reports whether the admin API is deliberately left open for local development:
+// only with ADMIN_AUTH_OPEN, no users configured and outside production
+func (c *Config) adminAuthOpen() bool {
+	return c.AdminAuthOpen && !c.HasAdminAuth() && !c.IsProduction()
+}
+
+// AdminAuthenticator resolves bearer tokens to admin users
+type AdminAuthenticator struct {
+	config *Config
+	oidc   *oidcVerifier
+}
+
+// NewAdminAuthenticator creates an authenticator from config
+func NewAdminAuthenticator(config *Config, httpClient *http.Client) *AdminAuthenticator {
+	auth := &AdminAuthenticator{config: config}
+	if config.OIDCIssuer != "" {
+		auth.oidc = &oidcVerifier{
+			issuer:     strings.TrimSuffix(config.OIDCIssuer, "/"),
+			audience:   config.OIDCAudience,
+			httpClient: httpClient,
+			keys:       make(map[string]*rsa.PublicKey),
+		}
+	}
+	return auth
+}
+
+// Authenticate returns the user for a bearer token
+func (a *AdminAuthenticator) Authenticate(token string) (*AdminUser, error) {
+	for _, user := range a.config.AdminUsers {
+		if subtle.ConstantTimeCompare([]byte(user.Token), []byte(token)) == 1 {
+			u := user
+			return &u, nil
+		}
+	}
+
+	if a.oidc != nil && strings.Count(token, ".") == 2 {
+		claims, err := a.oidc.Verify(token)
+		if err != nil {
+			return nil, err
+		}
+		role, _ := claims[a.config.OIDCRoleClaim].(string)
+		if _, ok := roleRank[role]; !ok {
+			return nil, fmt.Errorf("token has no known role in claim %s", a.config.OIDCRoleClaim)
+		}
+		name, _ := claims["email"].(string)
+		if name == "" {
+			name, _ = claims["sub"].(string)
+		}
+		// Only an explicit "*" reads all tenants; a token without a tenant claim reads none
+		tenant, _ := claims["tenant"].(string)
+		switch tenant {
+		case "":
+			tenant = noTenant
+		case "*":
+			tenant = ""
+		}
+		return &AdminUser{Name: name, Role: role, Tenant: tenant}, nil
+	}
+
+	return nil, fmt.Errorf("unknown token")
+}
+
+// RequireRole restricts a route to users with at least the given role
+func RequireRole(pipedriveService *PipedriveService, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveService.config.adminAuthOpen() {
+			c.Set(adminOpenKey, true)
+			c.Next()
+			return
+		}
+		if !pipedriveService.config.HasAdminAuth() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, WebhookResponse{
+				Success: false,
+				Message: "Admin API authentication is not configured",
+			})
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, WebhookResponse{
+				Success: false,
+				Message: "Missing bearer token",
+			})
+			return
+		}
+
+		user, err := pipedriveService.adminAuth.Authenticate(token)
+		if err != nil {
+			log.Printf("🔒 [ADMIN AUTH] Rejected token for %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, WebhookResponse{
+				Success: false,
+				Message: "Invalid credentials",
+			})
+			return
+		}
+
+		if roleRank[user.Role] < roleRank[role] {
+			c.AbortWithStatusJSON(http.StatusForbidden, WebhookResponse{
+				Success: false,
+				Message: fmt.Sprintf("Role %s required", role),
+			})
+			return
+		}
+
+		c.Set("admin_user", user)
+		c.Next()
+	}
+}
+
+// oidcVerifier validates RS256 ID tokens against an issuer's JWKS
+type oidcVerifier struct {
+	issuer     string
+	audience   string
+	httpClient *http.Client
+	mu         sync.Mutex
+	keys       map[string]*rsa.PublicKey
+	fetchedAt  time.Time
+}
+
+// Verify checks the token signature, issuer, audience and expiry and returns its claims
+func (v *oidcVerifier) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %v", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported token algorithm: %s", header.Alg)
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %v", err)
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != v.issuer {
+		return nil, fmt.Errorf("unexpected token issuer: %s", iss)
+	}
+	if exp, ok := claims["exp"].(float64); !ok || time.Now().Unix() > int64(exp) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if !audienceMatches(claims["aud"], v.audience) {
+		return nil, fmt.Errorf("unexpected token audience")
+	}
+	return claims, nil
+}
+
+// key returns the signing key for a key ID, refreshing the JWKS when needed
+func (v *oidcVerifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[kid]; ok && time.Since(v.fetchedAt) < time.Hour {
+		return key, nil
+	}
+	if err := v.refreshKeysLocked(); err != nil {
+		return nil, err
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	return key, nil
+}
+
+// refreshKeysLocked fetches the issuer's JWKS; callers must hold mu
+func (v *oidcVerifier) refreshKeysLocked() error {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(v.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return fmt.Errorf("failed to load OIDC discovery: %v", err)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("failed to load JWKS: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+// getJSON fetches a URL and decodes the JSON response
+func (v *oidcVerifier) getJSON(url string, target interface{}) error {
+	resp, err := v.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// decodeJWTPart base64url-decodes and unmarshals a JWT segment
+func decodeJWTPart(part string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// audienceMatches checks a string or array aud claim
+func audienceMatches(aud interface{}, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	switch value := aud.(type) {
+	case string:
+		return value == expected
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok && s == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
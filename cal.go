@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CalClient talks to the Cal.com API for availability and bookings
+type CalClient struct {
+	config     *Config
+	httpClient *http.Client
+}
+
+// CalSlot is an available start time for the configured event type
+type CalSlot struct {
+	Time time.Time `json:"time"`
+}
+
+// CalBooking is a booking created through the Cal.com API
+type CalBooking struct {
+	ID        int    `json:"id"`
+	UID       string `json:"uid"`
+	Title     string `json:"title"`
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+	Status    string `json:"status"`
+}
+
+// BookingRequest is the body for POST /api/bookings
+type BookingRequest struct {
+	Start    string `json:"start" binding:"required"` // RFC3339 start time, as offered to the agent
+	Name     string `json:"name"`
+	Email    string `json:"email" binding:"required"`
+	Phone    string `json:"phone"`
+	TimeZone string `json:"time_zone"`
+	CallID   string `json:"call_id"` // Retell call the slot was agreed on; fills name and phone when known
+	Notes    string `json:"notes"`
+}
+
+// newCalClient returns a Cal.com client, or nil if the API is not configured
+func newCalClient(config *Config, httpClient *http.Client) *CalClient {
+	if config.CalAPIKey == "" || config.CalEventTypeID == 0 {
+		return nil
+	}
+	return &CalClient{config: config, httpClient: httpClient}
+}
+
+// do sends a request to the Cal.com API and returns the response body
+func (c *CalClient) do(method, endpoint string, query url.Values, body interface{}) ([]byte, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("apiKey", c.config.CalAPIKey)
+	requestURL := strings.TrimRight(c.config.CalAPIURL, "/") + endpoint + "?" + query.Encode()
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %v", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBody)
+		debugf(SubsystemCal, "%s %s body: %s", method, endpoint, string(jsonBody))
+	}
+
+	req, err := http.NewRequest(method, requestURL, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Cal.com request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	debugf(SubsystemCal, "%s %s -> HTTP %d: %s", method, endpoint, resp.StatusCode, string(respBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Cal.com %s %s failed: HTTP %d, Response: %s", method, endpoint, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// AvailableSlots returns the open slots of the configured event type for the next days
+func (c *CalClient) AvailableSlots(from time.Time, days int) ([]CalSlot, error) {
+	if days <= 0 {
+		days = 1
+	}
+	query := url.Values{}
+	query.Set("eventTypeId", strconv.Itoa(c.config.CalEventTypeID))
+	query.Set("startTime", from.UTC().Format(time.RFC3339))
+	query.Set("endTime", from.AddDate(0, 0, days).UTC().Format(time.RFC3339))
+	query.Set("timeZone", c.config.CalTimeZone)
+
+	body, err := c.do("GET", "/slots", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Slots map[string][]CalSlot `json:"slots"` // Keyed by date
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse slots response: %v", err)
+	}
+
+	var slots []CalSlot
+	for _, daySlots := range result.Slots {
+		for _, slot := range daySlots {
+			if slot.Time.After(from) {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Time.Before(slots[j].Time) })
+	return slots, nil
+}
+
+// CreateBooking books a slot of the configured event type for an attendee
+func (c *CalClient) CreateBooking(request BookingRequest) (*CalBooking, error) {
+	timeZone := request.TimeZone
+	if timeZone == "" {
+		timeZone = c.config.CalTimeZone
+	}
+	responses := map[string]interface{}{
+		"name":  request.Name,
+		"email": request.Email,
+	}
+	if request.Phone != "" {
+		responses["attendeePhoneNumber"] = request.Phone
+	}
+	if request.Notes != "" {
+		responses["notes"] = request.Notes
+	}
+	metadata := map[string]string{}
+	if request.CallID != "" {
+		metadata["retell_call_id"] = request.CallID
+	}
+
+	body, err := c.do("POST", "/bookings", nil, map[string]interface{}{
+		"eventTypeId": c.config.CalEventTypeID,
+		"start":       request.Start,
+		"timeZone":    timeZone,
+		"language":    "en",
+		"responses":   responses,
+		"metadata":    metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var booking CalBooking
+	if err := json.Unmarshal(body, &booking); err != nil {
+		return nil, fmt.Errorf("failed to parse booking response: %v", err)
+	}
+	return &booking, nil
+}
+
+// GetBooking fetches a booking to confirm its current status and time
+func (c *CalClient) GetBooking(bookingID int) (*CalBooking, error) {
+	body, err := c.do("GET", fmt.Sprintf("/bookings/%d", bookingID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Booking *CalBooking `json:"booking"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse booking response: %v", err)
+	}
+	if result.Booking == nil {
+		return nil, fmt.Errorf("booking %d not found", bookingID)
+	}
+	return result.Booking, nil
+}
+
+// calSlotVariables turns the first available slots into dynamic variables for the agent
+func calSlotVariables(slots []CalSlot, limit int, timeZone string, locale Locale) map[string]interface{} {
+	variables := make(map[string]interface{})
+	if len(slots) == 0 {
+		variables["available_slots"] = "none"
+		return variables
+	}
+	if limit > 0 && len(slots) > limit {
+		slots = slots[:limit]
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	spoken := make([]string, 0, len(slots))
+	starts := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		spoken = append(spoken, locale.DateTime(slot.Time.In(location)))
+		starts = append(starts, slot.Time.UTC().Format(time.RFC3339))
+	}
+	variables["available_slots"] = strings.Join(spoken, "; ")
+	variables["available_slot_starts"] = strings.Join(starts, ",")
+	variables["slot_time_zone"] = location.String()
+	return variables
+}
+
+// CreateBookingHandler books a Cal.com slot the agent agreed on with the lead
+func CreateBookingHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveService.cal == nil {
+			c.JSON(http.StatusServiceUnavailable, WebhookResponse{
+				Success: false,
+				Message: "Cal.com API is not configured (CAL_API_KEY, CAL_EVENT_TYPE_ID)",
+			})
+			return
+		}
+
+		var request BookingRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload: start and email are required",
+			})
+			return
+		}
+		if _, err := time.Parse(time.RFC3339, request.Start); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "start must be an RFC3339 timestamp",
+			})
+			return
+		}
+
+		if request.CallID != "" {
+			if mapping, ok := pipedriveService.getCallMapping(request.CallID); ok {
+				if request.Name == "" {
+					request.Name = mapping.PersonName
+				}
+				if request.Phone == "" {
+					request.Phone = mapping.PhoneNumber
+				}
+			}
+		}
+		if request.Name == "" {
+			request.Name = request.Email
+		}
+
+		booking, err := pipedriveService.cal.CreateBooking(request)
+		if err != nil {
+			log.Printf("❌ Failed to create Cal.com booking for %s at %s: %v", request.Email, request.Start, err)
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to create booking: %v", err),
+			})
+			return
+		}
+
+		log.Printf("📅 Booked Cal.com slot %s for %s (booking %d)", request.Start, request.Email, booking.ID)
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Booking created",
+			Data:    booking,
+		})
+	}
+}
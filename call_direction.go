@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Retell call directions
+const (
+	CallDirectionInbound  = "inbound"
+	CallDirectionOutbound = "outbound"
+)
+
+// RetellEventCall is the call object of Retell's native call_started and call_ended events
+type RetellEventCall struct {
+	CallID              string        `json:"call_id"`
+	AgentID             string        `json:"agent_id"`
+	Direction           string        `json:"direction"` // "inbound" or "outbound"
+	FromNumber          string        `json:"from_number"`
+	ToNumber            string        `json:"to_number"`
+	CallStatus          string        `json:"call_status"`
+	StartTimestamp      int64         `json:"start_timestamp"`
+	EndTimestamp        int64         `json:"end_timestamp"`
+	DurationMs          int64         `json:"duration_ms"`
+	Transcript          string        `json:"transcript"`
+	DisconnectionReason string        `json:"disconnection_reason"`
+	Metadata            *CallMetadata `json:"metadata"`
+}
+
+// normalize fills the flat fields from a native Retell event's call object, so both formats go
+// through the same pipeline; fields set on the flat payload win
+func (p *RetellWebhookPayload) normalize() {
+	call := p.Call
+	if call == nil {
+		return
+	}
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&p.CallID, call.CallID)
+	fill(&p.AgentID, call.AgentID)
+	fill(&p.Direction, call.Direction)
+	fill(&p.FromNumber, call.FromNumber)
+	fill(&p.ToNumber, call.ToNumber)
+	fill(&p.Status, call.CallStatus)
+	fill(&p.Transcript, call.Transcript)
+	if p.Metadata == nil {
+		p.Metadata = call.Metadata
+	}
+	if p.Duration == 0 && call.DurationMs > 0 {
+		p.Duration = DurationFromMillis(call.DurationMs)
+	}
+	if p.Timestamp == "" {
+		at := call.StartTimestamp
+		if call.EndTimestamp > 0 && p.Event == "call_ended" {
+			at = call.EndTimestamp
+		}
+		if at > 0 {
+			p.Timestamp = TimestampFromMillis(at).UTC.Format(time.RFC3339)
+		}
+	}
+	if p.ContactPhone == "" {
+		p.ContactPhone = p.customerNumber()
+	}
+}
+
+// direction returns the call's direction; events without one are from calls we placed
+func (p RetellWebhookPayload) direction() string {
+	if strings.EqualFold(p.Direction, CallDirectionInbound) {
+		return CallDirectionInbound
+	}
+	return CallDirectionOutbound
+}
+
+// customerNumber is the other party's number: the caller on inbound calls, the callee on outbound
+func (p RetellWebhookPayload) customerNumber() string {
+	if p.direction() == CallDirectionInbound {
+		return p.FromNumber
+	}
+	return p.ToNumber
+}
+
+// CallActivityType returns the Pipedrive activity type for calls in a direction
+func (c *Config) CallActivityType(direction string) string {
+	if strings.EqualFold(direction, CallDirectionInbound) {
+		return c.InboundActivityType
+	}
+	return c.OutboundActivityType
+}
+
+// callEventSubject returns the activity subject for a call event, calling out inbound calls
+func callEventSubject(subject, direction string) string {
+	if direction == CallDirectionInbound {
+		return strings.Replace(subject, "AI Call", "Inbound AI Call", 1)
+	}
+	return subject
+}
+
+// callEventNote renders the activity note for a call event from the direction's template, or the
+// default layout
+func (p *PipedriveService) callEventNote(subject string, payload RetellWebhookPayload, callTime Timestamp) string {
+	template := p.config.OutboundNoteTemplate
+	if payload.direction() == CallDirectionInbound {
+		template = p.config.InboundNoteTemplate
+	}
+	if template != "" {
+		return renderTemplate(template, map[string]string{
+			"subject":   subject,
+			"call_id":   payload.CallID,
+			"phone":     payload.ContactPhone,
+			"from":      payload.FromNumber,
+			"to":        payload.ToNumber,
+			"agent":     payload.AgentID,
+			"direction": payload.direction(),
+			"time":      noteTime(callTime),
+			"duration":  payload.Duration.String(),
+			"status":    payload.Status,
+			"event":     payload.Event,
+		})
+	}
+
+	note := fmt.Sprintf("%s\n\nCall ID: %s\nPhone: %s\nTime: %s\nDuration: %s\nStatus: %s\nEvent: %s",
+		subject, payload.CallID, payload.ContactPhone, noteTime(callTime), payload.Duration, payload.Status, payload.Event)
+	if payload.Direction != "" {
+		note += fmt.Sprintf("\nDirection: %s\nFrom: %s\nTo: %s", payload.direction(), payload.FromNumber, payload.ToNumber)
+	}
+	if payload.AgentID != "" {
+		note += "\nAgent: " + payload.AgentID
+	}
+	return note
+}
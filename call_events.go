@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Call lifecycle event types
+const (
+	CallEventQueued     = "queued"
+	CallEventDialing    = "dialing"
+	CallEventConnected  = "connected"
+	CallEventOptedOut   = "opted_out"
+	CallEventEnded      = "ended"
+	CallEventAnalyzed   = "analyzed"
+	CallEventCRMUpdated = "crm_updated"
+	CallEventFailed     = "failed"
+	CallEventReplayed   = "replayed"
+)
+
+// CallEvent is one immutable state transition of a call
+type CallEvent struct {
+	Seq       int64     `json:"seq"`
+	CallID    string    `json:"call_id"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`      // UTC
+	Zone      string    `json:"zone,omitempty"` // Zone the event time was received in, when given
+	LeadID    string    `json:"lead_id,omitempty"`
+	PersonID  int       `json:"person_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+
+	Mapping  *CallMapping               `json:"mapping,omitempty"`  // Call context when dialing, used to rebuild lost mappings
+	Analysis *RetellCallAnalyzedPayload `json:"analysis,omitempty"` // Webhook payload, used to re-run CRM writes
+}
+
+// CallEventStore is an append-only log of call lifecycle events, optionally persisted as JSON lines
+type CallEventStore struct {
+	mu        sync.Mutex
+	seq       int64
+	events    map[string][]CallEvent // Keyed by call ID
+	inFlight  map[string]time.Time   // Calls whose latest event is queued, dialing or connected, with that event's time
+	file      *os.File               // nil when events are kept in memory only
+	keys      KeyProvider            // Seals the mapping and analysis written to the file; nil keeps them in memory only
+	tenantFor func(agentID string) string
+}
+
+// callEventLine is a call event as written to CALL_EVENTS_FILE: the call's mapping and analysis,
+// which hold the person's details and transcript, are sealed with the tenant's key
+type callEventLine struct {
+	CallEvent
+	Tenant string `json:"tenant,omitempty"`
+	Sealed []byte `json:"sealed,omitempty"`
+}
+
+// callEventDetails is the sealed part of a call event line
+type callEventDetails struct {
+	Mapping  *CallMapping               `json:"mapping,omitempty"`
+	Analysis *RetellCallAnalyzedPayload `json:"analysis,omitempty"`
+}
+
+// NewCallEventStore creates an event store, replaying events already in CALL_EVENTS_FILE
+func NewCallEventStore(path string, keys KeyProvider, tenantFor func(agentID string) string) *CallEventStore {
+	store := &CallEventStore{events: make(map[string][]CallEvent), inFlight: make(map[string]time.Time), keys: keys, tenantFor: tenantFor}
+	if path == "" {
+		return store
+	}
+	if keys == nil {
+		log.Printf("⚠️ No tenant encryption keys configured, call mappings and analyses are not written to CALL_EVENTS_FILE")
+	}
+
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+		for scanner.Scan() {
+			event, err := store.decode(scanner.Bytes())
+			if err != nil {
+				log.Printf("⚠️ Warning: Skipping unreadable call event in %s: %v", path, err)
+				continue
+			}
+			store.events[event.CallID] = append(store.events[event.CallID], event)
+			store.trackLocked(event)
+			if event.Seq > store.seq {
+				store.seq = event.Seq
+			}
+		}
+		existing.Close()
+		log.Printf("📼 Loaded call events for %d calls from %s", len(store.events), path)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("⚠️ Warning: Cannot open CALL_EVENTS_FILE %s, keeping call events in memory: %v", path, err)
+		return store
+	}
+	store.file = file
+	return store
+}
+
+// Append records an event, stamping its sequence number and time
+func (s *CallEventStore) Append(event CallEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.Timestamp = event.Timestamp.UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	event.Seq = s.seq
+	s.events[event.CallID] = append(s.events[event.CallID], event)
+	s.trackLocked(event)
+	if s.file != nil {
+		line, err := s.encode(event)
+		if err == nil {
+			_, err = s.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to persist %s event for call %s: %v", event.Type, event.CallID, err)
+		}
+	}
+	debugf(SubsystemRetell, "Call %s -> %s", event.CallID, event.Type)
+}
+
+// trackLocked keeps inFlight in step with a call's latest event; callers hold mu
+func (s *CallEventStore) trackLocked(event CallEvent) {
+	switch event.Type {
+	case CallEventQueued, CallEventDialing, CallEventConnected:
+		s.inFlight[event.CallID] = event.Timestamp
+	default:
+		delete(s.inFlight, event.CallID)
+	}
+}
+
+// Events returns a call's events in order
+func (s *CallEventStore) Events(callID string) []CallEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CallEvent(nil), s.events[callID]...)
+}
+
+// StartedBetween returns the events of calls whose first event is in [from, to), keyed by call ID
+func (s *CallEventStore) StartedBetween(from, to time.Time) map[string][]CallEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := make(map[string][]CallEvent)
+	for callID, events := range s.events {
+		if first := events[0].Timestamp; !first.Before(from) && first.Before(to) {
+			calls[callID] = append([]CallEvent(nil), events...)
+		}
+	}
+	return calls
+}
+
+// InFlight counts calls still queued, dialing or connected, ignoring calls idle longer than maxAge
+func (s *CallEventStore) InFlight(maxAge time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for callID, at := range s.inFlight {
+		if time.Since(at) > maxAge {
+			delete(s.inFlight, callID)
+		}
+	}
+	return len(s.inFlight)
+}
+
+// ExpiredAnalyses returns, per call, a copy of the latest event older than cutoff whose recorded
+// analysis still holds what match looks for
+func (s *CallEventStore) ExpiredAnalyses(cutoff time.Time, match func(*RetellCallAnalyzedPayload) bool) []CallEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []CallEvent
+	for _, events := range s.events {
+		for i := len(events) - 1; i >= 0; i-- {
+			if events[i].Analysis != nil && events[i].Timestamp.Before(cutoff) && match(events[i].Analysis) {
+				event := events[i]
+				analysis := *event.Analysis
+				event.Analysis = &analysis
+				expired = append(expired, event)
+				break
+			}
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Seq < expired[j].Seq })
+	return expired
+}
+
+// RedactCall applies redact to a call's recorded analyses older than cutoff and rewrites
+// CALL_EVENTS_FILE when anything changed; it reports whether anything did
+func (s *CallEventStore) RedactCall(callID string, cutoff time.Time, redact func(*RetellCallAnalyzedPayload) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for _, event := range s.events[callID] {
+		if event.Analysis != nil && event.Timestamp.Before(cutoff) && redact(event.Analysis) {
+			changed = true
+		}
+	}
+	if changed && s.file != nil {
+		if err := s.rewriteLocked(); err != nil {
+			log.Printf("⚠️ Warning: Failed to rewrite call events after redaction: %v", err)
+		}
+	}
+	return changed
+}
+
+// rewriteLocked replaces CALL_EVENTS_FILE with the events held in memory; callers hold mu
+func (s *CallEventStore) rewriteLocked() error {
+	var all []CallEvent
+	for _, events := range s.events {
+		all = append(all, events...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Seq < all[j].Seq })
+
+	path := s.file.Name()
+	tmp, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, event := range all {
+		line, err := s.encode(event)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	return nil
+}
+
+// eventTenant returns the tenant whose key seals an event's details
+func (s *CallEventStore) eventTenant(event CallEvent) string {
+	if event.Mapping != nil && event.Mapping.Tenant != "" {
+		return event.Mapping.Tenant
+	}
+	if event.Analysis != nil {
+		return s.tenantFor(event.Analysis.Call.AgentID)
+	}
+	return DefaultTenant
+}
+
+// encode renders an event as a CALL_EVENTS_FILE line, sealing its mapping and analysis; without
+// keys they are left out of the file
+func (s *CallEventStore) encode(event CallEvent) ([]byte, error) {
+	line := callEventLine{CallEvent: event}
+	line.Mapping, line.Analysis = nil, nil
+	if (event.Mapping != nil || event.Analysis != nil) && s.keys != nil {
+		details, err := json.Marshal(callEventDetails{Mapping: event.Mapping, Analysis: event.Analysis})
+		if err != nil {
+			return nil, err
+		}
+		line.Tenant = s.eventTenant(event)
+		if line.Sealed, err = sealForTenant(s.keys, line.Tenant, details); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(line)
+}
+
+// decode reads a CALL_EVENTS_FILE line, opening its sealed details; lines written before
+// encryption keep their details in the clear
+func (s *CallEventStore) decode(data []byte) (CallEvent, error) {
+	var line callEventLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return CallEvent{}, err
+	}
+	event := line.CallEvent
+	if len(line.Sealed) == 0 {
+		return event, nil
+	}
+	if s.keys == nil {
+		return event, nil
+	}
+	opened, err := openForTenant(s.keys, line.Tenant, line.Sealed)
+	if err != nil {
+		log.Printf("⚠️ Warning: Cannot open the details of call %s event %d: %v", event.CallID, event.Seq, err)
+		return event, nil
+	}
+	var details callEventDetails
+	if err := json.Unmarshal(opened, &details); err != nil {
+		return CallEvent{}, err
+	}
+	event.Mapping, event.Analysis = details.Mapping, details.Analysis
+	return event, nil
+}
+
+// latestCallEvent returns the most recent event of a type for a call
+func latestCallEvent(events []CallEvent, eventType string) *CallEvent {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == eventType {
+			return &events[i]
+		}
+	}
+	return nil
+}
+
+// retellCallEventType maps Retell call webhook events to lifecycle events
+func retellCallEventType(event string) string {
+	switch event {
+	case "call_started", "call.started":
+		return CallEventConnected
+	case "call.optout":
+		return CallEventOptedOut
+	case "call_ended", "call.completed", "call.hangup":
+		return CallEventEnded
+	}
+	return ""
+}
+
+// restoreCallMapping puts back a call mapping lost to a restart
+func (p *PipedriveService) restoreCallMapping(callID string, mapping CallMapping) {
+	if _, err := p.callMappings.PutIfAbsent(callID, mapping); err != nil {
+		p.logf("⚠️ Warning: Failed to restore call mapping for %s: %v", callID, err)
+	}
+}
+
+// CallEventsHandler reconstructs a call's lifecycle from its events
+func CallEventsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		callID := c.Param("id")
+		if callOutOfScope(c, pipedriveService, callID) {
+			return
+		}
+		events := pipedriveService.callEvents.Events(callID)
+		if len(events) == 0 {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "No events for call " + callID,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d events", len(events)),
+			Data: gin.H{
+				"call_id": callID,
+				"state":   events[len(events)-1].Type,
+				"events":  events,
+			},
+		})
+	}
+}
+
+// ReplayCallHandler re-runs the CRM writes of a call from its recorded analysis, e.g. after a bug fix
+func ReplayCallHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		callID := c.Param("id")
+		if callOutOfScope(c, pipedriveService, callID) {
+			return
+		}
+		events := pipedriveService.callEvents.Events(callID)
+		analyzed := latestCallEvent(events, CallEventAnalyzed)
+		if analyzed == nil || analyzed.Analysis == nil {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "No recorded analysis for call " + callID,
+			})
+			return
+		}
+		if dialing := latestCallEvent(events, CallEventDialing); dialing != nil && dialing.Mapping != nil {
+			pipedriveService.restoreCallMapping(callID, *dialing.Mapping)
+		}
+
+		// Replays take the same claim as the webhook
+		release, ok := pipedriveService.claim("analyzed:" + callID)
+		if !ok {
+			c.JSON(http.StatusConflict, WebhookResponse{
+				Success: false,
+				Message: "Call " + callID + " is being handled by another replica",
+			})
+			return
+		}
+
+		pipedriveService.callEvents.Append(CallEvent{
+			CallID:   callID,
+			Type:     CallEventReplayed,
+			LeadID:   analyzed.LeadID,
+			PersonID: analyzed.PersonID,
+			Detail:   fmt.Sprintf("analysis event %d", analyzed.Seq),
+		})
+		payload := *analyzed.Analysis
+		payload.Replay = true
+		if err := pipedriveService.ProcessRetellCallAnalyzed(payload); err != nil {
+			release()
+			status := http.StatusBadGateway
+			if errors.Is(err, ErrNoCallMapping) || errors.Is(err, ErrCallAlreadyLogged) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, WebhookResponse{
+				Success: false,
+				Message: "Replay failed: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Call replayed",
+			Data:    gin.H{"call_id": callID, "events": pipedriveService.callEvents.Events(callID)},
+		})
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Call mapping store backends
+const (
+	CallMappingStoreMemory   = "memory"
+	CallMappingStoreRedis    = "redis"
+	CallMappingStorePostgres = "postgres"
+)
+
+// CallMappingStore keeps the call ID to person mappings analyzed-call webhooks are resolved with.
+// Mappings expire CALL_MAPPING_TTL_DAYS after they were last written.
+type CallMappingStore interface {
+	Get(callID string) (CallMapping, bool, error)
+	Put(callID string, mapping CallMapping) error
+	// PutIfAbsent stores mapping unless the call already has one, reporting whether it did
+	PutIfAbsent(callID string, mapping CallMapping) (bool, error)
+	// Update applies update to the call's mapping atomically across replicas, reporting whether
+	// the call had one
+	Update(callID string, update func(*CallMapping)) (bool, error)
+	List() (map[string]CallMapping, error)
+	Delete(callID string) error
+}
+
+// newCallMappingStore creates the store selected by CALL_MAPPING_STORE. A shared backend that is
+// not configured or unreachable stops the service: replicas keeping mappings to themselves would
+// drop each other's analyzed calls.
+func newCallMappingStore(config *Config, locker *RedisLocker) CallMappingStore {
+	ttl := time.Duration(config.CallMappingTTLDays) * 24 * time.Hour
+	switch strings.ToLower(config.CallMappingStore) {
+	case "", CallMappingStoreMemory:
+		return newMemoryCallMappingStore(nil, ttl)
+	case CallMappingStoreRedis:
+		if locker == nil {
+			log.Fatalf("❌ CALL_MAPPING_STORE=redis needs a valid REDIS_URL")
+		}
+		store, err := newRedisCallMappingStore(locker, config.CallMappingRedisKey, ttl)
+		if err != nil {
+			log.Fatalf("❌ Redis call mapping store unavailable: %v", err)
+		}
+		log.Printf("📝 Call mappings are stored in Redis (%s:<call id>)", config.CallMappingRedisKey)
+		return store
+	case CallMappingStorePostgres:
+		store, err := newPostgresCallMappingStore(config.DatabaseURL, ttl)
+		if err != nil {
+			log.Fatalf("❌ Postgres call mapping store unavailable: %v", err)
+		}
+		log.Printf("📝 Call mappings are stored in Postgres (call_mappings)")
+		return store
+	}
+	log.Fatalf("❌ Invalid CALL_MAPPING_STORE %q, expected memory, redis or postgres", config.CallMappingStore)
+	return nil
+}
+
+// memoryCallMappingStore keeps call mappings in process memory; they are lost on restart
+type memoryCallMappingStore struct {
+	mu       sync.RWMutex
+	ttl      time.Duration // Zero keeps mappings until deleted
+	mappings map[string]memoryCallMapping
+	pruned   time.Time
+}
+
+// memoryCallMapping is a stored mapping with the time it expires at
+type memoryCallMapping struct {
+	mapping CallMapping
+	expires time.Time
+}
+
+// newMemoryCallMappingStore creates an in-memory store holding a copy of mappings
+func newMemoryCallMappingStore(mappings map[string]CallMapping, ttl time.Duration) *memoryCallMappingStore {
+	store := &memoryCallMappingStore{ttl: ttl, mappings: make(map[string]memoryCallMapping, len(mappings)), pruned: time.Now()}
+	for callID, mapping := range mappings {
+		store.putLocked(callID, mapping)
+	}
+	return store
+}
+
+// putLocked stores a mapping with a fresh expiry, dropping expired ones at most hourly; callers hold mu
+func (s *memoryCallMappingStore) putLocked(callID string, mapping CallMapping) {
+	stored := memoryCallMapping{mapping: mapping}
+	if s.ttl > 0 {
+		stored.expires = time.Now().Add(s.ttl)
+		if time.Since(s.pruned) > time.Hour {
+			s.pruneLocked()
+		}
+	}
+	s.mappings[callID] = stored
+}
+
+// pruneLocked drops expired mappings; callers hold mu
+func (s *memoryCallMappingStore) pruneLocked() {
+	now := time.Now()
+	for callID, stored := range s.mappings {
+		if stored.expired(now) {
+			delete(s.mappings, callID)
+		}
+	}
+	s.pruned = now
+}
+
+func (m memoryCallMapping) expired(now time.Time) bool {
+	return !m.expires.IsZero() && now.After(m.expires)
+}
+
+func (s *memoryCallMappingStore) Get(callID string) (CallMapping, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stored, ok := s.mappings[callID]
+	if !ok || stored.expired(time.Now()) {
+		return CallMapping{}, false, nil
+	}
+	return stored.mapping, true, nil
+}
+
+func (s *memoryCallMappingStore) Put(callID string, mapping CallMapping) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(callID, mapping)
+	return nil
+}
+
+func (s *memoryCallMappingStore) PutIfAbsent(callID string, mapping CallMapping) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.mappings[callID]; ok && !stored.expired(time.Now()) {
+		return false, nil
+	}
+	s.putLocked(callID, mapping)
+	return true, nil
+}
+
+func (s *memoryCallMappingStore) Update(callID string, update func(*CallMapping)) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.mappings[callID]
+	if !ok || stored.expired(time.Now()) {
+		return false, nil
+	}
+	update(&stored.mapping)
+	s.putLocked(callID, stored.mapping)
+	return true, nil
+}
+
+func (s *memoryCallMappingStore) Delete(callID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.mappings, callID)
+	return nil
+}
+
+func (s *memoryCallMappingStore) List() (map[string]CallMapping, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	mappings := make(map[string]CallMapping, len(s.mappings))
+	for callID, stored := range s.mappings {
+		mappings[callID] = stored.mapping
+	}
+	return mappings, nil
+}
+
+// redisCallMappingStore keeps each call mapping as JSON in its own Redis key under a prefix, so
+// Redis expires it and replicas update it with WATCH/MULTI/EXEC
+type redisCallMappingStore struct {
+	client *RedisLocker
+	prefix string
+	ttl    time.Duration
+}
+
+// newRedisCallMappingStore checks Redis answers and moves mappings kept by older versions in one
+// hash named prefix into their own keys
+func newRedisCallMappingStore(client *RedisLocker, prefix string, ttl time.Duration) (*redisCallMappingStore, error) {
+	store := &redisCallMappingStore{client: client, prefix: prefix, ttl: ttl}
+	if _, err := client.do("PING"); err != nil {
+		return nil, err
+	}
+	kind, err := client.do("TYPE", prefix)
+	if err != nil || kind != "hash" {
+		return store, err
+	}
+	items, err := client.doArray("HGETALL", prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(items); i += 2 {
+		if _, err := store.client.do(store.setArgs(items[i], items[i+1], "NX")...); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := client.do("DEL", prefix); err != nil {
+		return nil, err
+	}
+	log.Printf("📝 Moved %d call mappings from the %s hash to their own keys", len(items)/2, prefix)
+	return store, nil
+}
+
+func (s *redisCallMappingStore) key(callID string) string {
+	return s.prefix + ":" + callID
+}
+
+// setArgs builds the SET command storing a mapping with the store's expiry
+func (s *redisCallMappingStore) setArgs(callID, data string, options ...string) []string {
+	args := []string{"SET", s.key(callID), data}
+	if s.ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(s.ttl.Milliseconds(), 10))
+	}
+	return append(args, options...)
+}
+
+func (s *redisCallMappingStore) Get(callID string) (CallMapping, bool, error) {
+	var mapping CallMapping
+	reply, err := s.client.do("GET", s.key(callID))
+	if err != nil || reply == "" {
+		return mapping, false, err
+	}
+	if err := json.Unmarshal([]byte(reply), &mapping); err != nil {
+		return mapping, false, fmt.Errorf("invalid call mapping for %s: %v", callID, err)
+	}
+	return mapping, true, nil
+}
+
+func (s *redisCallMappingStore) Put(callID string, mapping CallMapping) error {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.do(s.setArgs(callID, string(data))...)
+	return err
+}
+
+func (s *redisCallMappingStore) PutIfAbsent(callID string, mapping CallMapping) (bool, error) {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return false, err
+	}
+	reply, err := s.client.do(s.setArgs(callID, string(data), "NX")...)
+	return reply == "OK", err
+}
+
+func (s *redisCallMappingStore) Update(callID string, update func(*CallMapping)) (bool, error) {
+	var decodeErr error
+	written, err := s.client.update(s.key(callID), s.ttl, func(value string) (string, bool) {
+		var mapping CallMapping
+		if value == "" {
+			return "", false
+		}
+		if decodeErr = json.Unmarshal([]byte(value), &mapping); decodeErr != nil {
+			return "", false
+		}
+		update(&mapping)
+		data, err := json.Marshal(mapping)
+		if decodeErr = err; err != nil {
+			return "", false
+		}
+		return string(data), true
+	})
+	if err == nil && decodeErr != nil {
+		err = fmt.Errorf("invalid call mapping for %s: %v", callID, decodeErr)
+	}
+	return written, err
+}
+
+func (s *redisCallMappingStore) Delete(callID string) error {
+	_, err := s.client.do("DEL", s.key(callID))
+	return err
+}
+
+func (s *redisCallMappingStore) List() (map[string]CallMapping, error) {
+	keys, err := s.client.scan(s.prefix + ":*")
+	if err != nil {
+		return nil, err
+	}
+	mappings := make(map[string]CallMapping, len(keys))
+	for start := 0; start < len(keys); start += 500 {
+		batch := keys[start:min(start+500, len(keys))]
+		values, err := s.client.doArray(append([]string{"MGET"}, batch...)...)
+		if err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			var mapping CallMapping
+			if value != "" && json.Unmarshal([]byte(value), &mapping) == nil {
+				mappings[strings.TrimPrefix(batch[i], s.prefix+":")] = mapping
+			}
+		}
+	}
+	return mappings, nil
+}
+
+// postgresCallMappingStore keeps call mappings in the call_mappings table
+type postgresCallMappingStore struct {
+	db     *sql.DB
+	ttl    time.Duration
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+// newPostgresCallMappingStore connects to DATABASE_URL and creates the call_mappings table
+func newPostgresCallMappingStore(databaseURL string, ttl time.Duration) (*postgresCallMappingStore, error) {
+	if databaseURL == "" {
+		return nil, fmt.Errorf("DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS call_mappings (
+		call_id    TEXT PRIMARY KEY,
+		mapping    JSONB NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err == nil {
+		_, err = db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS call_mappings_updated_at ON call_mappings (updated_at)`)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create call_mappings table: %v", err)
+	}
+	return &postgresCallMappingStore{db: db, ttl: ttl, pruned: time.Now()}, nil
+}
+
+// fresh is the SQL condition leaving out expired mappings
+func (s *postgresCallMappingStore) fresh() string {
+	if s.ttl <= 0 {
+		return "TRUE"
+	}
+	return fmt.Sprintf("updated_at > now() - interval '%d seconds'", int64(s.ttl.Seconds()))
+}
+
+// prune deletes expired mappings, at most hourly unless forced
+func (s *postgresCallMappingStore) prune(force bool) error {
+	if s.ttl <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	if !force && time.Since(s.pruned) < time.Hour {
+		s.mu.Unlock()
+		return nil
+	}
+	s.pruned = time.Now()
+	s.mu.Unlock()
+	_, err := s.db.Exec(`DELETE FROM call_mappings WHERE NOT (` + s.fresh() + `)`)
+	return err
+}
+
+func (s *postgresCallMappingStore) Get(callID string) (CallMapping, bool, error) {
+	var mapping CallMapping
+	var data []byte
+	err := s.db.QueryRow(`SELECT mapping FROM call_mappings WHERE call_id = $1 AND `+s.fresh(), callID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return mapping, false, nil
+	}
+	if err != nil {
+		return mapping, false, err
+	}
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return mapping, false, fmt.Errorf("invalid call mapping for %s: %v", callID, err)
+	}
+	return mapping, true, nil
+}
+
+func (s *postgresCallMappingStore) Put(callID string, mapping CallMapping) error {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	if err := s.prune(false); err != nil {
+		log.Printf("⚠️ Warning: Failed to delete expired call mappings: %v", err)
+	}
+	_, err = s.db.Exec(`INSERT INTO call_mappings (call_id, mapping, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (call_id) DO UPDATE SET mapping = EXCLUDED.mapping, updated_at = now()`, callID, string(data))
+	return err
+}
+
+func (s *postgresCallMappingStore) PutIfAbsent(callID string, mapping CallMapping) (bool, error) {
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return false, err
+	}
+	// An expired row counts as absent and is replaced
+	result, err := s.db.Exec(`INSERT INTO call_mappings (call_id, mapping, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (call_id) DO UPDATE SET mapping = EXCLUDED.mapping, updated_at = now()
+		WHERE NOT (`+strings.ReplaceAll(s.fresh(), "updated_at", "call_mappings.updated_at")+`)`, callID, string(data))
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted > 0, err
+}
+
+func (s *postgresCallMappingStore) Update(callID string, update func(*CallMapping)) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var data []byte
+	err = tx.QueryRow(`SELECT mapping FROM call_mappings WHERE call_id = $1 AND `+s.fresh()+` FOR UPDATE`, callID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var mapping CallMapping
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return false, fmt.Errorf("invalid call mapping for %s: %v", callID, err)
+	}
+	update(&mapping)
+	if data, err = json.Marshal(mapping); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE call_mappings SET mapping = $2, updated_at = now() WHERE call_id = $1`, callID, string(data)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *postgresCallMappingStore) Delete(callID string) error {
+	_, err := s.db.Exec(`DELETE FROM call_mappings WHERE call_id = $1`, callID)
+	return err
+}
+
+func (s *postgresCallMappingStore) List() (map[string]CallMapping, error) {
+	if err := s.prune(true); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT call_id, mapping FROM call_mappings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	mappings := make(map[string]CallMapping)
+	for rows.Next() {
+		var callID string
+		var data []byte
+		if err := rows.Scan(&callID, &data); err != nil {
+			return nil, err
+		}
+		var mapping CallMapping
+		if err := json.Unmarshal(data, &mapping); err == nil {
+			mappings[callID] = mapping
+		}
+	}
+	return mappings, rows.Err()
+}
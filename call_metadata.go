@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallMetadata is our context for a call, sent in Retell's metadata field on call creation and
+// returned on every webhook and get-call, so calls can be correlated without the in-memory call
+// mapping (e.g. after a restart or on another replica)
+type CallMetadata struct {
+	Tenant     string                `json:"tenant,omitempty"`
+	Campaign   string                `json:"campaign,omitempty"`
+	LeadID     string                `json:"lead_id,omitempty"`
+	PersonID   int                   `json:"person_id,omitempty"`
+	PersonName string                `json:"person_name,omitempty"`
+	LeadTitle  string                `json:"lead_title,omitempty"`
+	OwnerID    int                   `json:"owner_id,omitempty"`
+	Region     string                `json:"region,omitempty"`
+	FromNumber string                `json:"from_number,omitempty"`
+	Experiment *ExperimentAssignment `json:"experiment,omitempty"`
+	Attempt    int                   `json:"attempt,omitempty"`
+}
+
+// UnmarshalJSON ignores metadata of other shapes, e.g. on calls placed from the Retell dashboard,
+// rather than rejecting the whole webhook
+func (m *CallMetadata) UnmarshalJSON(data []byte) error {
+	type plain CallMetadata
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		debugf(SubsystemRetell, "Ignoring unrecognized call metadata: %v", err)
+		return nil
+	}
+	*m = CallMetadata(decoded)
+	return nil
+}
+
+// mapping rebuilds the call mapping the metadata was sent from
+func (m *CallMetadata) mapping(phoneNumber string) CallMapping {
+	return CallMapping{
+		PersonName:  m.PersonName,
+		PhoneNumber: phoneNumber,
+		LeadTitle:   m.LeadTitle,
+		PersonID:    m.PersonID,
+		Timestamp:   time.Now(),
+		Experiment:  m.Experiment,
+		OwnerID:     m.OwnerID,
+		Tenant:      m.Tenant,
+		Region:      m.Region,
+		LeadID:      m.LeadID,
+		FromNumber:  m.FromNumber,
+		Campaign:    m.Campaign,
+		Attempt:     m.Attempt,
+	}
+}
+
+// trustedMetadata returns a request's call metadata only when its Retell signature was verified;
+// anyone can post metadata naming any person, so unsigned requests must match a stored mapping
+func trustedMetadata(c *gin.Context, metadata *CallMetadata) *CallMetadata {
+	if !webhookVerified(c) {
+		return nil
+	}
+	return metadata
+}
+
+// callMappingFor returns the call's mapping, rebuilding it from the metadata Retell sent back
+// when this instance has none. Callers pass metadata only from verified webhooks or get-call.
+func (p *PipedriveService) callMappingFor(callID string, metadata *CallMetadata, phoneNumber string) (CallMapping, bool) {
+	if mapping, ok := p.getCallMapping(callID); ok {
+		return mapping, true
+	}
+	if metadata == nil || metadata.PersonID == 0 {
+		return CallMapping{}, false
+	}
+	p.restoreCallMapping(callID, metadata.mapping(phoneNumber))
+	p.logf("🏷️ Rebuilt call mapping for %s from Retell metadata (person %d, lead %s)", callID, metadata.PersonID, metadata.LeadID)
+	return p.getCallMapping(callID)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons a call is waiting in the queue
+const (
+	QueueReasonCampaign     = "campaign"       // Campaign trigger delay or calling window
+	QueueReasonCallWindow   = "call_window"    // Held for a window with a better answer rate
+	QueueReasonDialRule     = "dial_rule"      // Deprioritized by a dial rule
+	QueueReasonSMSFirst     = "sms_first"      // Follow-up call after a first-touch SMS
+	QueueReasonRepActivity  = "rep_activity"   // Deferred while a rep works the lead
+	QueueReasonRetry        = "campaign_retry" // Redial of an unanswered campaign call
+	QueueReasonNurture      = "nurture"        // Deal-stage nurture call
+	QueueReasonContactHours = "contact_hours"  // Outside CONTACT_HOURS
+)
+
+// QueuedCall is a call waiting to be placed
+type QueuedCall struct {
+	ID         string    `json:"id"`
+	Position   int       `json:"position"` // 1 for the next call to be placed
+	PersonID   int       `json:"person_id"`
+	LeadID     string    `json:"lead_id,omitempty"`
+	DealID     int       `json:"deal_id,omitempty"`
+	Title      string    `json:"title,omitempty"`
+	Campaign   string    `json:"campaign,omitempty"`
+	Reason     string    `json:"reason"`
+	QueuedAt   time.Time `json:"queued_at"`
+	ETA        time.Time `json:"eta"`
+	ActivityID int       `json:"activity_id,omitempty"` // Initiation activity the ETA was written to
+}
+
+// CallQueue keeps the lead calls held back until they are placed; the jobs that place them are
+// saved in SCHEDULE_FILE and restore the queue after a restart
+type CallQueue struct {
+	mu    sync.Mutex
+	calls map[string]*QueuedCall // Keyed by ID
+}
+
+// NewCallQueue creates an empty call queue
+func NewCallQueue() *CallQueue {
+	return &CallQueue{calls: make(map[string]*QueuedCall)}
+}
+
+// Add queues a call and returns its ID
+func (q *CallQueue) Add(call QueuedCall) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	call.ID = newProcessingID()
+	call.QueuedAt = time.Now()
+	q.calls[call.ID] = &call
+	return call.ID
+}
+
+// Release removes a call once its timer fires
+func (q *CallQueue) Release(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.calls, id)
+}
+
+// SetActivity records the initiation activity a queued call's ETA was written to
+func (q *CallQueue) SetActivity(id string, activityID int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if call, ok := q.calls[id]; ok {
+		call.ActivityID = activityID
+	}
+}
+
+// HoldsActivity reports whether an initiation activity belongs to a call still in the queue
+func (q *CallQueue) HoldsActivity(activityID int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, call := range q.calls {
+		if call.ActivityID == activityID {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the queued calls
+func (q *CallQueue) List() []QueuedCall {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	calls := make([]QueuedCall, 0, len(q.calls))
+	for _, call := range q.calls {
+		calls = append(calls, *call)
+	}
+	return calls
+}
+
+// queuePositions returns the queued lead calls and scheduled nurture calls in the order they
+// will be placed, numbered from 1. Overdue calls are due now.
+func (p *PipedriveService) queuePositions() []QueuedCall {
+	calls := p.callQueue.List()
+	for _, nurture := range p.nurtures.List() {
+		if nurture.Status != NurtureScheduled {
+			continue
+		}
+		calls = append(calls, QueuedCall{
+			ID:       fmt.Sprintf("nurture-%d", nurture.DealID),
+			PersonID: nurture.PersonID,
+			DealID:   nurture.DealID,
+			Title:    nurture.Title,
+			Reason:   QueueReasonNurture,
+			QueuedAt: nurture.EnteredAt,
+			ETA:      nurture.CallAt,
+		})
+	}
+	sort.SliceStable(calls, func(i, j int) bool {
+		if calls[i].ETA.Equal(calls[j].ETA) {
+			return calls[i].QueuedAt.Before(calls[j].QueuedAt)
+		}
+		return calls[i].ETA.Before(calls[j].ETA)
+	})
+	now := time.Now()
+	for i := range calls {
+		calls[i].Position = i + 1
+		if calls[i].ETA.Before(now) {
+			calls[i].ETA = now
+		}
+	}
+	return calls
+}
+
+// heldLead is a held lead call as saved in SCHEDULE_FILE: its queue entry, the webhook payload and
+// the dispatch state the payload's JSON leaves out
+type heldLead struct {
+	Call             QueuedCall                  `json:"call"`
+	Payload          PipedriveLeadWebhookPayload `json:"payload"`
+	Coalesced        []CoalescedLead             `json:"coalesced,omitempty"`
+	Intake           string                      `json:"intake,omitempty"`
+	HumanDeferred    bool                        `json:"human_deferred,omitempty"`
+	ChannelSequenced bool                        `json:"channel_sequenced,omitempty"`
+	Campaign         string                      `json:"campaign,omitempty"`
+	Attempt          int                         `json:"attempt,omitempty"`
+	CampaignDelayed  bool                        `json:"campaign_delayed,omitempty"`
+	WindowDelayed    bool                        `json:"window_delayed,omitempty"`
+	Deprioritized    bool                        `json:"deprioritized,omitempty"`
+	QueuedActivityID int                         `json:"queued_activity_id,omitempty"`
+	Tenant           string                      `json:"tenant,omitempty"`
+}
+
+// newHeldLead captures a payload and its queue entry for SCHEDULE_FILE
+func newHeldLead(call QueuedCall, payload PipedriveLeadWebhookPayload) heldLead {
+	return heldLead{
+		Call:             call,
+		Payload:          payload,
+		Coalesced:        payload.Coalesced,
+		Intake:           payload.Intake,
+		HumanDeferred:    payload.HumanDeferred,
+		ChannelSequenced: payload.ChannelSequenced,
+		Campaign:         payload.Campaign,
+		Attempt:          payload.Attempt,
+		CampaignDelayed:  payload.CampaignDelayed,
+		WindowDelayed:    payload.WindowDelayed,
+		Deprioritized:    payload.Deprioritized,
+		QueuedActivityID: payload.QueuedActivityID,
+		Tenant:           payload.Tenant,
+	}
+}
+
+// payload returns the held payload with its dispatch state restored
+func (h heldLead) payload() PipedriveLeadWebhookPayload {
+	payload := h.Payload
+	payload.Coalesced = h.Coalesced
+	payload.Intake = h.Intake
+	payload.HumanDeferred = h.HumanDeferred
+	payload.ChannelSequenced = h.ChannelSequenced
+	payload.Campaign = h.Campaign
+	payload.Attempt = h.Attempt
+	payload.CampaignDelayed = h.CampaignDelayed
+	payload.WindowDelayed = h.WindowDelayed
+	payload.Deprioritized = h.Deprioritized
+	payload.QueueID = h.Call.ID
+	payload.QueuedActivityID = h.QueuedActivityID
+	payload.Tenant = h.Tenant
+	return payload
+}
+
+// leadCallJobID is the scheduled job ID of a queued lead call
+func leadCallJobID(queueID string) string {
+	return "lead-call:" + queueID
+}
+
+// Restore puts a call saved in SCHEDULE_FILE back in the queue under its ID
+func (q *CallQueue) Restore(call QueuedCall) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.calls[call.ID] = &call
+}
+
+// holdLeadCall queues a lead call and schedules it to be dialed again at a time, replacing the
+// lead's previous queue entry. The payload goes through dialLead again when the hold ends, so a DNC
+// mark, snooze or new rep activity since then still stops the call. With QUEUE_ETA_ACTIVITY the
+// initiation activity is created right away with the ETA and updated with the call once it is placed.
+func (p *PipedriveService) holdLeadCall(payload *PipedriveLeadWebhookPayload, reason string, at time.Time) {
+	if payload.QueueID != "" {
+		p.callQueue.Release(payload.QueueID)
+		p.schedules.Cancel(leadCallJobID(payload.QueueID))
+	}
+	call := QueuedCall{
+		PersonID: payload.Data.PersonID,
+		LeadID:   payload.Data.ID,
+		Title:    payload.Data.Title,
+		Campaign: p.config.leadCampaign(*payload),
+		Reason:   reason,
+		ETA:      at,
+	}
+	payload.QueueID = p.callQueue.Add(call)
+	if p.config.QueueETAActivity && p.config.HasPipedriveConfig() {
+		p.queuedCallActivity(payload, reason, at)
+	}
+
+	call.ID = payload.QueueID
+	call.QueuedAt = time.Now()
+	call.ActivityID = payload.QueuedActivityID
+	if err := p.schedules.Schedule(leadCallJobID(payload.QueueID), JobLeadCall, at, newHeldLead(call, *payload)); err != nil {
+		p.logf("❌ Failed to schedule the held call for lead %s: %v", payload.Data.ID, err)
+	}
+}
+
+// runHeldLeadCall dials a lead whose hold ended
+func (p *PipedriveService) runHeldLeadCall(raw json.RawMessage) {
+	var held heldLead
+	if err := json.Unmarshal(raw, &held); err != nil {
+		p.logf("❌ Failed to read held lead call: %v", err)
+		return
+	}
+	p.callQueue.Release(held.Call.ID)
+	payload := held.payload()
+	if err := p.dialLead(payload); err != nil {
+		p.logf("❌ Failed to place held call for lead %s: %v", payload.Data.ID, err)
+	}
+}
+
+// restoreHeldLeadCalls puts the lead calls saved in SCHEDULE_FILE back in the call queue
+func (p *PipedriveService) restoreHeldLeadCalls() {
+	for _, job := range p.schedules.Jobs(JobLeadCall) {
+		var held heldLead
+		if err := json.Unmarshal(job.Payload, &held); err != nil {
+			p.logf("⚠️ Warning: Ignoring unreadable held lead call %s: %v", job.ID, err)
+			continue
+		}
+		p.callQueue.Restore(held.Call)
+	}
+}
+
+// queuedCallActivity creates or moves the initiation activity of a queued call to its ETA
+func (p *PipedriveService) queuedCallActivity(payload *PipedriveLeadWebhookPayload, reason string, at time.Time) {
+	note := fmt.Sprintf("Retell AI call queued for lead: %s\nETA: %s (%s)", payload.Data.Title, at.UTC().Format(time.RFC3339), reason)
+	activityData := map[string]interface{}{
+		"note":     note,
+		"due_date": pipedriveDueDate(at),
+		"due_time": pipedriveDueTime(at),
+	}
+	if payload.QueuedActivityID != 0 {
+		if err := p.updateActivity(payload.QueuedActivityID, activityData); err != nil {
+			p.logf("⚠️ Warning: Failed to update the ETA of activity %d: %v", payload.QueuedActivityID, err)
+		}
+		p.callQueue.SetActivity(payload.QueueID, payload.QueuedActivityID)
+		return
+	}
+
+	activityData["subject"] = fmt.Sprintf("AI Call Initiated - Lead: %s", payload.Data.Title)
+	activityData["type"] = "call"
+	activityData["person_id"] = payload.Data.PersonID
+	activityData["done"] = 0
+	if ownerID := p.resolveLeadOwner(payload.Data.ID, payload.Data.Title, payload.Data.OwnerID); ownerID != 0 {
+		activityData["user_id"] = ownerID
+	}
+	p.linkActivityToDeal(activityData, payload.Data.PersonID)
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to create the queued call activity for lead %s: %v", payload.Data.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+	var result PipedriveActivityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || !result.Success {
+		p.logf("⚠️ Warning: Failed to create the queued call activity for lead %s: HTTP %d", payload.Data.ID, resp.StatusCode)
+		return
+	}
+	payload.QueuedActivityID = result.Data.ID
+	p.callQueue.SetActivity(payload.QueueID, result.Data.ID)
+	p.logf("✅ Created queued call activity %d for lead %s (ETA %s)", result.Data.ID, payload.Data.ID, at.UTC().Format(time.RFC3339))
+}
+
+// QueuePositionsHandler reports the position and ETA of pending calls, optionally for one person
+// (?person_id=) or lead (?lead_id=)
+func QueuePositionsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		personID := 0
+		if raw := c.Query("person_id"); raw != "" {
+			id, err := strconv.Atoi(raw)
+			if err != nil || id <= 0 {
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "person_id must be a positive integer",
+				})
+				return
+			}
+			personID = id
+		}
+		leadID := c.Query("lead_id")
+
+		all := pipedriveService.queuePositions()
+		calls := make([]QueuedCall, 0, len(all))
+		for _, call := range all {
+			if (personID == 0 || call.PersonID == personID) && (leadID == "" || call.LeadID == leadID) {
+				calls = append(calls, call)
+			}
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d of %d queued calls", len(calls), len(all)),
+			Data: map[string]interface{}{
+				"calls":  calls,
+				"queued": len(all),
+			},
+		})
+	}
+}
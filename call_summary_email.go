@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallSummarySender is a tenant's sender and branding for call summary emails
+type CallSummarySender struct {
+	From     string `json:"from"`     // e.g. "Acme Sales <sales@acme.com>"; defaults to SMTP_FROM
+	ReplyTo  string `json:"reply_to"` // Where prospect replies go, e.g. the owner's team inbox
+	Brand    string `json:"brand"`    // Company name signing the email
+	Subject  string `json:"subject"`  // {{name}}, {{lead}}, {{brand}}
+	Template string `json:"template"` // Body: {{name}}, {{lead}}, {{summary}}, {{next_steps}}, {{brand}}, {{unsubscribe_url}}
+}
+
+// defaultCallSummarySubject is used when neither the tenant nor CALL_SUMMARY_EMAIL_SUBJECT set one
+const defaultCallSummarySubject = "Following up on our call"
+
+// loadCallSummarySenders parses CALL_SUMMARY_EMAIL_SENDERS (JSON: tenant -> sender), filling unset
+// fields from the default sender
+func loadCallSummarySenders(raw string, defaults CallSummarySender) map[string]CallSummarySender {
+	senders := make(map[string]CallSummarySender)
+	if raw == "" {
+		return senders
+	}
+	if err := json.Unmarshal([]byte(raw), &senders); err != nil {
+		log.Printf("⚠️ Invalid CALL_SUMMARY_EMAIL_SENDERS, ignoring: %v", err)
+		return make(map[string]CallSummarySender)
+	}
+	for tenant, sender := range senders {
+		if sender.From == "" {
+			sender.From = defaults.From
+		}
+		if sender.ReplyTo == "" {
+			sender.ReplyTo = defaults.ReplyTo
+		}
+		if sender.Brand == "" {
+			sender.Brand = defaults.Brand
+		}
+		if sender.Subject == "" {
+			sender.Subject = defaults.Subject
+		}
+		if sender.Template == "" {
+			sender.Template = defaults.Template
+		}
+		senders[tenant] = sender
+	}
+	return senders
+}
+
+// CallSummarySenderFor returns the tenant's summary email sender, or the default one
+func (c *Config) CallSummarySenderFor(tenant string) CallSummarySender {
+	if sender, ok := c.CallSummarySenders[tenant]; ok {
+		return sender
+	}
+	return c.CallSummaryDefault
+}
+
+// callSummaryEmailEnabled reports whether a tenant's contacts get call summary emails; all tenants
+// do when CALL_SUMMARY_EMAIL_TENANTS is empty
+func (c *Config) callSummaryEmailEnabled(tenant string) bool {
+	if !c.CallSummaryEmail {
+		return false
+	}
+	if len(c.CallSummaryEmailTenants) == 0 {
+		return true
+	}
+	for _, enabled := range c.CallSummaryEmailTenants {
+		if enabled == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// analysisText reads a custom analysis field given as a string or a list of strings
+func analysisText(data map[string]interface{}, field, separator string) string {
+	switch value := data[field].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case []interface{}:
+		var items []string
+		for _, item := range value {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+		return strings.Join(items, separator)
+	}
+	return ""
+}
+
+// unsubscribeToken signs an address so only links we sent can unsubscribe it
+func unsubscribeToken(secret, email string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// unsubscribeURL returns the public link that adds an address to the unsubscribe list
+func (c *Config) unsubscribeURL(email string) string {
+	return fmt.Sprintf("%s/unsubscribe?email=%s&token=%s", strings.TrimSuffix(c.PublicBaseURL, "/"),
+		url.QueryEscape(email), unsubscribeToken(c.CallSummaryUnsubscribeSecret, email))
+}
+
+// renderCallSummaryEmail builds the subject and body of a call summary email. The unsubscribe link
+// is appended when a custom template leaves it out.
+func renderCallSummaryEmail(sender CallSummarySender, vars map[string]string) (string, string) {
+	subject := sender.Subject
+	if subject == "" {
+		subject = defaultCallSummarySubject
+	}
+	if sender.Template != "" {
+		body := renderTemplate(sender.Template, vars)
+		if !strings.Contains(sender.Template, "unsubscribe_url") {
+			body = strings.TrimRight(body, "\n") + "\n\n--\nUnsubscribe: " + vars["unsubscribe_url"] + "\n"
+		}
+		return renderTemplate(subject, vars), body
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nThank you for taking the time to speak with us. Here is a summary of what we discussed:\n\n%s\n", vars["name"], vars["summary"])
+	if vars["next_steps"] != "" {
+		body += "\nNext steps:\n" + vars["next_steps"] + "\n"
+	}
+	body += "\nJust reply to this email if anything is missing or you have questions.\n"
+	if vars["brand"] != "" {
+		body += "\nBest regards,\n" + vars["brand"] + "\n"
+	}
+	body += "\n--\nDon't want these emails? Unsubscribe: " + vars["unsubscribe_url"] + "\n"
+	return renderTemplate(subject, vars), body
+}
+
+// sendCallSummaryEmail emails the contact of an answered call the prospect-facing summary from the
+// analysis and the next steps, from the tenant's sender, and logs the email as a note on the person.
+// Calls without that summary get no email: Retell's own call_summary is written for the team.
+// Unsubscribed and bounce-suppressed addresses are skipped. It talks to SMTP, so callers run it in
+// the background.
+func (p *PipedriveService) sendCallSummaryEmail(payload RetellCallAnalyzedPayload, mapping CallMapping) {
+	tenant := mapping.Tenant
+	if tenant == "" {
+		tenant = p.config.TenantFor(payload.Call.AgentID)
+	}
+	if !p.config.callSummaryEmailEnabled(tenant) || !callAnswered(payload) || mapping.PersonID == 0 || mapping.SummaryEmailed {
+		return
+	}
+	callID := payload.Call.CallID
+	if p.mailer == nil || p.config.PublicBaseURL == "" || p.config.CallSummaryUnsubscribeSecret == "" || p.config.CallSummaryUnsubscribeFile == "" {
+		p.logf("⚠️ CALL_SUMMARY_EMAIL requires SMTP_HOST, SMTP_FROM, PUBLIC_BASE_URL, CALL_SUMMARY_UNSUBSCRIBE_SECRET and CALL_SUMMARY_UNSUBSCRIBE_FILE, skipping call %s", callID)
+		return
+	}
+	if latestCallEvent(p.callEvents.Events(callID), CallEventOptedOut) != nil {
+		p.logf("🔕 Person %d opted out during call %s, not sending a summary email", mapping.PersonID, callID)
+		return
+	}
+	summary := analysisText(payload.Call.CallAnalysis.CustomAnalysisData, p.config.CallSummaryEmailField, "\n")
+	if summary == "" {
+		p.debugf(SubsystemRetell, "Call %s has no %s in its analysis, no summary email", callID, p.config.CallSummaryEmailField)
+		return
+	}
+
+	person, err := p.GetPersonByID(mapping.PersonID)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to load person %d for the summary email of call %s: %v", mapping.PersonID, callID, err)
+		return
+	}
+	email := ""
+	for _, candidate := range person.Email {
+		if candidate.Value != "" && (email == "" || candidate.Primary) {
+			email = strings.TrimSpace(candidate.Value)
+		}
+	}
+	if email == "" {
+		p.debugf(SubsystemRetell, "Person %d has no email, no summary email for call %s", mapping.PersonID, callID)
+		return
+	}
+	if p.unsubscribes.Contains(email) {
+		p.logf("🔕 %s unsubscribed from call summary emails, skipping call %s", email, callID)
+		return
+	}
+	if err := p.emailValidator.Validate(email); err != nil {
+		p.logf("⚠️ Not sending the summary email of call %s: %v", callID, err)
+		return
+	}
+
+	var nextSteps []string
+	for _, step := range strings.Split(analysisText(payload.Call.CallAnalysis.CustomAnalysisData, p.config.CallSummaryNextStepsField, "\n"), "\n") {
+		if step = strings.TrimSpace(step); step != "" {
+			nextSteps = append(nextSteps, "- "+strings.TrimPrefix(step, "- "))
+		}
+	}
+	sender := p.config.CallSummarySenderFor(tenant)
+	unsubscribe := p.config.unsubscribeURL(email)
+	subject, body := renderCallSummaryEmail(sender, map[string]string{
+		"name":            mapping.PersonName,
+		"lead":            mapping.LeadTitle,
+		"summary":         summary,
+		"next_steps":      strings.Join(nextSteps, "\n"),
+		"brand":           sender.Brand,
+		"unsubscribe_url": unsubscribe,
+	})
+	headers := []string{
+		"List-Unsubscribe: <" + unsubscribe + ">",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+	}
+	if err := p.mailer.SendWithHeaders(sender.From, sender.ReplyTo, []string{email}, subject, body, headers); err != nil {
+		p.logf("❌ Failed to send the summary email of call %s to %s: %v", callID, email, err)
+		return
+	}
+	p.updateCallMapping(callID, func(m *CallMapping) { m.SummaryEmailed = true })
+	p.logf("📧 Sent the summary email of call %s to %s", callID, email)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "call_summary_emailed", PersonID: mapping.PersonID, CallID: callID, Detail: "to " + email})
+
+	from := sender.From
+	if from == "" {
+		from = p.config.SMTPFrom
+	}
+	noteData := map[string]interface{}{
+		"content": fmt.Sprintf("Call summary emailed\nTo: %s\nFrom: %s\nSubject: %s\nCall ID: %s\n\n%s",
+			email, from, subject, callID, strings.TrimSpace(body)),
+		"person_id": mapping.PersonID,
+	}
+	if mapping.LeadID != "" {
+		noteData["lead_id"] = mapping.LeadID
+	}
+	p.linkActivityToDeal(noteData, mapping.PersonID)
+	resp, err := p.makePipedriveRequest("POST", "/notes", noteData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to log the summary email of call %s to person %d: %v", callID, mapping.PersonID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// UnsubscribeList is the addresses that opted out of call summary emails, kept in
+// CALL_SUMMARY_UNSUBSCRIBE_FILE. Replicas sharing the file reread it whenever it changed.
+type UnsubscribeList struct {
+	mu       sync.Mutex
+	entries  map[string]bool
+	file     string
+	modified time.Time // Modification time of the file when last read
+}
+
+// NewUnsubscribeList creates the list, loading CALL_SUMMARY_UNSUBSCRIBE_FILE when set
+func NewUnsubscribeList(config *Config) *UnsubscribeList {
+	list := &UnsubscribeList{entries: make(map[string]bool), file: config.CallSummaryUnsubscribeFile}
+	if list.file == "" {
+		return list
+	}
+	if err := list.refreshLocked(); err != nil {
+		log.Printf("⚠️ Warning: Cannot read CALL_SUMMARY_UNSUBSCRIBE_FILE %s: %v", list.file, err)
+		return list
+	}
+	log.Printf("🔕 Loaded %d unsubscribed addresses from %s", len(list.entries), list.file)
+	return list
+}
+
+// refreshLocked rereads CALL_SUMMARY_UNSUBSCRIBE_FILE when another replica changed it; callers hold mu
+func (l *UnsubscribeList) refreshLocked() error {
+	if l.file == "" {
+		return nil
+	}
+	info, err := os.Stat(l.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(l.modified) {
+		return nil
+	}
+	file, err := os.Open(l.file)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	entries := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if entry := normalizeBounceEntry(scanner.Text()); entry != "" {
+			entries[entry] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	l.entries, l.modified = entries, info.ModTime()
+	return nil
+}
+
+// Contains reports whether an address, or its "@domain", unsubscribed. An unreadable list counts
+// as containing every address.
+func (l *UnsubscribeList) Contains(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.refreshLocked(); err != nil {
+		log.Printf("❌ Cannot read CALL_SUMMARY_UNSUBSCRIBE_FILE %s, treating %s as unsubscribed: %v", l.file, email, err)
+		return true
+	}
+	return l.entries[email] || l.entries["@"+email[strings.LastIndex(email, "@")+1:]]
+}
+
+// Add unsubscribes addresses or "@domain" entries and saves the list
+func (l *UnsubscribeList) Add(entries []string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.refreshLocked(); err != nil {
+		return 0, fmt.Errorf("failed to read unsubscribe list: %v", err)
+	}
+	added := 0
+	for _, entry := range entries {
+		if entry = normalizeBounceEntry(entry); entry != "" && !l.entries[entry] {
+			l.entries[entry] = true
+			added++
+		}
+	}
+	return added, l.saveLocked()
+}
+
+// Remove resubscribes an address or "@domain" entry and saves the list
+func (l *UnsubscribeList) Remove(entry string) (bool, error) {
+	entry = normalizeBounceEntry(entry)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.refreshLocked(); err != nil {
+		return false, fmt.Errorf("failed to read unsubscribe list: %v", err)
+	}
+	if !l.entries[entry] {
+		return false, nil
+	}
+	delete(l.entries, entry)
+	return true, l.saveLocked()
+}
+
+// List returns the unsubscribed entries in order
+func (l *UnsubscribeList) List() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.refreshLocked(); err != nil {
+		log.Printf("⚠️ Warning: Cannot read CALL_SUMMARY_UNSUBSCRIBE_FILE %s: %v", l.file, err)
+	}
+	entries := make([]string, 0, len(l.entries))
+	for entry := range l.entries {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+// saveLocked rewrites CALL_SUMMARY_UNSUBSCRIBE_FILE; callers hold mu
+func (l *UnsubscribeList) saveLocked() error {
+	if l.file == "" {
+		return nil
+	}
+	entries := make([]string, 0, len(l.entries))
+	for entry := range l.entries {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	data := strings.Join(entries, "\n")
+	if len(entries) > 0 {
+		data += "\n"
+	}
+	tmp := l.file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0600); err != nil {
+		return fmt.Errorf("failed to save unsubscribe list: %v", err)
+	}
+	if err := os.Rename(tmp, l.file); err != nil {
+		return fmt.Errorf("failed to save unsubscribe list: %v", err)
+	}
+	if info, err := os.Stat(l.file); err == nil {
+		l.modified = info.ModTime()
+	}
+	return nil
+}
+
+// unsubscribeConfirmPage asks for a click before unsubscribing, so link scanners fetching the
+// emailed link don't unsubscribe anyone
+const unsubscribeConfirmPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body style="font-family: sans-serif; max-width: 32em; margin: 4em auto">
+<p>Stop receiving call summary emails at <strong>%s</strong>?</p>
+<form method="POST" action="%s"><button type="submit">Unsubscribe</button></form>
+</body></html>`
+
+// UnsubscribeHandler adds the address of a signed unsubscribe link to the unsubscribe list. GET
+// serves the link in the email with a page confirming the unsubscribe; POST, sent by that page and
+// by the one-click List-Unsubscribe request, unsubscribes.
+func UnsubscribeHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, token := c.Query("email"), c.Query("token")
+		secret := pipedriveService.config.CallSummaryUnsubscribeSecret
+		if email == "" || secret == "" || !hmac.Equal([]byte(token), []byte(unsubscribeToken(secret, email))) {
+			c.String(http.StatusBadRequest, "This unsubscribe link is invalid.")
+			return
+		}
+		if c.Request.Method == http.MethodGet {
+			action := "/unsubscribe?email=" + url.QueryEscape(email) + "&token=" + url.QueryEscape(token)
+			c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(fmt.Sprintf(unsubscribeConfirmPage, html.EscapeString(email), html.EscapeString(action))))
+			return
+		}
+		added, err := pipedriveService.unsubscribes.Add([]string{email})
+		if err != nil {
+			log.Printf("❌ Failed to save the unsubscribe of %s: %v", email, err)
+			c.String(http.StatusInternalServerError, "We could not process your request, please try again later.")
+			return
+		}
+		if added > 0 {
+			log.Printf("🔕 %s unsubscribed from call summary emails", email)
+			pipedriveService.auditLog.Append(AuditEntry{Actor: email, Action: "email_unsubscribed", Detail: "call summary emails"})
+		}
+		c.String(http.StatusOK, "You have been unsubscribed and will no longer receive call summary emails.")
+	}
+}
+
+// ListUnsubscribesHandler lists addresses that unsubscribed from call summary emails
+func ListUnsubscribesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entries := pipedriveService.unsubscribes.List()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d unsubscribed entries", len(entries)),
+			Data:    entries,
+		})
+	}
+}
+
+// AddUnsubscribesHandler unsubscribes addresses or "@domain" entries, e.g. after a request by phone
+func AddUnsubscribesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Emails []string `json:"emails"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || len(request.Emails) == 0 {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: `Expected {"emails": ["address@domain" or "@domain", ...]}`,
+			})
+			return
+		}
+		added, err := pipedriveService.unsubscribes.Add(request.Emails)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Unsubscribed %d new entries", added),
+		})
+	}
+}
+
+// RemoveUnsubscribeHandler resubscribes an address or "@domain" entry
+func RemoveUnsubscribeHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		removed, err := pipedriveService.unsubscribes.Remove(c.Param("email"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Entry is not unsubscribed",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Unsubscribe removed",
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// callWindowMinAttempts is how many attempts a window needs before it is recommended or used for bias
+const callWindowMinAttempts = 5
+
+// unansweredReasons are Retell disconnection reasons that mean nobody picked up
+var unansweredReasons = map[string]bool{
+	"dial_no_answer":    true,
+	"dial_busy":         true,
+	"dial_failed":       true,
+	"voicemail_reached": true,
+}
+
+// CallWindowStats holds answer rates for one weekday/hour slot
+type CallWindowStats struct {
+	Weekday    string  `json:"weekday"`
+	Hour       int     `json:"hour"`
+	Attempts   int     `json:"attempts"`
+	Answered   int     `json:"answered"`
+	AnswerRate float64 `json:"answer_rate"`
+}
+
+// CallWindowReport summarizes call windows for one area code
+type CallWindowReport struct {
+	Prefix      string            `json:"prefix"`
+	Attempts    int               `json:"attempts"`
+	Answered    int               `json:"answered"`
+	Recommended []CallWindowStats `json:"recommended"` // Best windows with enough attempts
+	Windows     []CallWindowStats `json:"windows"`
+}
+
+// windowCounts counts attempts and answers in one slot
+type windowCounts struct {
+	attempts int
+	answered int
+}
+
+// CallWindowTracker records answer/no-answer outcomes by weekday and hour per area code
+type CallWindowTracker struct {
+	mu          sync.Mutex
+	location    *time.Location
+	countryCode string // PHONE_COUNTRY_CODE, for numbers stored without one
+	prefixes    map[string]*[7][24]windowCounts
+}
+
+// NewCallWindowTracker creates a tracker bucketing calls in the configured time zone
+func NewCallWindowTracker(config *Config) *CallWindowTracker {
+	location, err := time.LoadLocation(config.CallWindowTimeZone)
+	if err != nil {
+		log.Printf("⚠️ Invalid CALL_WINDOW_TIME_ZONE %q, using UTC: %v", config.CallWindowTimeZone, err)
+		location = time.UTC
+	}
+	return &CallWindowTracker{location: location, countryCode: config.PhoneCountryCode, prefixes: make(map[string]*[7][24]windowCounts)}
+}
+
+// areaCodePrefix returns the area code prefix of the number in E.164 form ("+1" plus three digits
+// for NANP, else the first five characters), or "" when the number cannot be normalized
+func areaCodePrefix(phoneNumber, countryCode string) string {
+	normalized, reason := normalizeE164(phoneNumber, countryCode)
+	if reason != "" {
+		return ""
+	}
+	if len(normalized) < 5 {
+		return normalized
+	}
+	return normalized[:5]
+}
+
+// Record counts a call attempt at a time
+func (t *CallWindowTracker) Record(phoneNumber string, at time.Time, answered bool) {
+	prefix := areaCodePrefix(phoneNumber, t.countryCode)
+	if prefix == "" {
+		return
+	}
+	local := at.In(t.location)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts, ok := t.prefixes[prefix]
+	if !ok {
+		counts = &[7][24]windowCounts{}
+		t.prefixes[prefix] = counts
+	}
+	slot := &counts[local.Weekday()][local.Hour()]
+	slot.attempts++
+	if answered {
+		slot.answered++
+	}
+}
+
+// Report returns call window stats per prefix, optionally for a single prefix
+func (t *CallWindowTracker) Report(onlyPrefix string) []CallWindowReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reports := make([]CallWindowReport, 0, len(t.prefixes))
+	for prefix, counts := range t.prefixes {
+		if onlyPrefix != "" && prefix != onlyPrefix {
+			continue
+		}
+		report := CallWindowReport{Prefix: prefix, Windows: []CallWindowStats{}, Recommended: []CallWindowStats{}}
+		for day := 0; day < 7; day++ {
+			for hour := 0; hour < 24; hour++ {
+				slot := counts[day][hour]
+				if slot.attempts == 0 {
+					continue
+				}
+				stats := CallWindowStats{
+					Weekday:    time.Weekday(day).String(),
+					Hour:       hour,
+					Attempts:   slot.attempts,
+					Answered:   slot.answered,
+					AnswerRate: float64(slot.answered) / float64(slot.attempts),
+				}
+				report.Attempts += slot.attempts
+				report.Answered += slot.answered
+				report.Windows = append(report.Windows, stats)
+				if slot.attempts >= callWindowMinAttempts {
+					report.Recommended = append(report.Recommended, stats)
+				}
+			}
+		}
+		sort.Slice(report.Recommended, func(i, j int) bool {
+			return report.Recommended[i].AnswerRate > report.Recommended[j].AnswerRate
+		})
+		if len(report.Recommended) > 3 {
+			report.Recommended = report.Recommended[:3]
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Prefix < reports[j].Prefix })
+	return reports
+}
+
+// NextBetterWindow returns when to call a number instead of now, if a clearly better window is within maxDelay
+func (t *CallWindowTracker) NextBetterWindow(phoneNumber string, now time.Time, maxDelay time.Duration) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts, ok := t.prefixes[areaCodePrefix(phoneNumber, t.countryCode)]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	rate := func(at time.Time) (float64, bool) {
+		local := at.In(t.location)
+		slot := counts[local.Weekday()][local.Hour()]
+		if slot.attempts < callWindowMinAttempts {
+			return 0, false
+		}
+		return float64(slot.answered) / float64(slot.attempts), true
+	}
+
+	current, known := rate(now)
+	if !known {
+		return time.Time{}, false
+	}
+	best := current
+	var bestAt time.Time
+	start := now.Truncate(time.Hour).Add(time.Hour)
+	for at := start; at.Sub(now) <= maxDelay; at = at.Add(time.Hour) {
+		// Require a meaningful improvement before delaying a fresh lead
+		if candidate, ok := rate(at); ok && candidate > best && candidate-current >= 0.1 {
+			best = candidate
+			bestAt = at
+		}
+	}
+	return bestAt, !bestAt.IsZero()
+}
+
+// callAnswered classifies an analyzed call as answered by a person
+func callAnswered(payload RetellCallAnalyzedPayload) bool {
+	if payload.Call.CallAnalysis.InVoicemail {
+		return false
+	}
+	return !unansweredReasons[payload.Call.DisconnectionReason]
+}
+
+// CallWindowReportHandler reports answer rates by weekday and hour per area code
+func CallWindowReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := pipedriveService.callWindows.Report(c.Query("prefix"))
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Call windows for %d area codes", len(report)),
+			Data:    report,
+		})
+	}
+}
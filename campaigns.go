@@ -0,0 +1,632 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errCampaignTemplateNotFound is returned for operations on a template that does not exist
+var errCampaignTemplateNotFound = errors.New("campaign template not found")
+
+// campaignNamePattern limits template and campaign names to URL-safe characters
+var campaignNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// campaignWeekdays maps the day names a call window accepts to weekdays
+var campaignWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// CampaignWindow is when a campaign's calls may be placed
+type CampaignWindow struct {
+	Days     []string `json:"days,omitempty"`      // "mon".."sun"; empty for every day
+	Start    string   `json:"start"`               // "09:00"
+	End      string   `json:"end"`                 // "18:00"
+	TimeZone string   `json:"time_zone,omitempty"` // IANA zone (default: CALL_WINDOW_TIME_ZONE)
+}
+
+// CampaignTriggers decide which leads of a campaign are called and when
+type CampaignTriggers struct {
+	Actions      []string `json:"actions,omitempty"`       // Lead webhook actions that start a call (default: create)
+	DelayMinutes int      `json:"delay_minutes,omitempty"` // Wait after the lead arrives before dialing
+	SkipLabels   []string `json:"skip_labels,omitempty"`   // Label names or IDs whose leads are not called
+}
+
+// CampaignTemplate is a named set of call settings campaigns are launched from
+type CampaignTemplate struct {
+	Name        string           `json:"name"`
+	AgentID     string           `json:"agent_id,omitempty"`     // Default: RETELL_ASSISTANT_ID; AGENT_EXPERIMENTS still apply
+	FromNumbers []string         `json:"from_numbers,omitempty"` // Pool leads are spread over; default: FROM_NUMBER_RULES, RETELL_FROM_NUMBER
+	Window      *CampaignWindow  `json:"window,omitempty"`
+	RetryLadder []string         `json:"retry_ladder,omitempty"` // Waits before redialing unanswered calls, e.g. ["2h", "24h"]
+	Triggers    CampaignTriggers `json:"triggers"`
+	UpdatedBy   string           `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// CampaignAudience selects the leads of a campaign; every condition set must match
+type CampaignAudience struct {
+	Sources  []string `json:"sources,omitempty"`   // Lead source_name, case-insensitive
+	Labels   []string `json:"labels,omitempty"`    // Label names, resolved in the Pipedrive account
+	LabelIDs []string `json:"label_ids,omitempty"` // Matches when the lead has any of these labels
+}
+
+// Campaign is a template launched for an audience. Leads whose campaign (EXPERIMENT_CAMPAIGN_FIELD
+// or source) is the campaign's name belong to it as well
+type Campaign struct {
+	Name      string           `json:"name"`
+	Template  string           `json:"template"`
+	Audience  CampaignAudience `json:"audience"`
+	CreatedBy string           `json:"created_by,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// CampaignStore keeps campaign templates and launched campaigns, saved to CAMPAIGNS_FILE when set
+type CampaignStore struct {
+	mu        sync.RWMutex
+	path      string
+	Templates map[string]CampaignTemplate `json:"templates"`
+	Campaigns map[string]Campaign         `json:"campaigns"`
+}
+
+// NewCampaignStore creates a campaign store, loading CAMPAIGNS_FILE when set
+func NewCampaignStore(config *Config) *CampaignStore {
+	store := &CampaignStore{path: config.CampaignsFile}
+	store.reset()
+	if store.path == "" {
+		return store
+	}
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read CAMPAIGNS_FILE %s: %v", store.path, err)
+		}
+		return store
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable CAMPAIGNS_FILE %s: %v", store.path, err)
+		store.reset()
+		return store
+	}
+	if store.Templates == nil {
+		store.Templates = make(map[string]CampaignTemplate)
+	}
+	if store.Campaigns == nil {
+		store.Campaigns = make(map[string]Campaign)
+	}
+	log.Printf("📣 Loaded %d campaign templates and %d campaigns from %s", len(store.Templates), len(store.Campaigns), store.path)
+	return store
+}
+
+// reset empties the store
+func (s *CampaignStore) reset() {
+	s.Templates = make(map[string]CampaignTemplate)
+	s.Campaigns = make(map[string]Campaign)
+}
+
+// saveLocked writes the store to CAMPAIGNS_FILE; callers must hold mu
+func (s *CampaignStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save campaigns: %v", err)
+	}
+}
+
+// SaveTemplate creates or replaces a template
+func (s *CampaignStore) SaveTemplate(template CampaignTemplate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Templates[template.Name] = template
+	s.saveLocked()
+}
+
+// DeleteTemplate removes a template unless a campaign was launched from it
+func (s *CampaignStore) DeleteTemplate(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Templates[name]; !ok {
+		return fmt.Errorf("%w: %s", errCampaignTemplateNotFound, name)
+	}
+	for _, campaign := range s.Campaigns {
+		if campaign.Template == name {
+			return fmt.Errorf("campaign template %q is used by campaign %q", name, campaign.Name)
+		}
+	}
+	delete(s.Templates, name)
+	s.saveLocked()
+	return nil
+}
+
+// Launch adds a campaign for an existing template
+func (s *CampaignStore) Launch(campaign Campaign) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Templates[campaign.Template]; !ok {
+		return fmt.Errorf("%w: %s", errCampaignTemplateNotFound, campaign.Template)
+	}
+	if _, ok := s.Campaigns[campaign.Name]; ok {
+		return fmt.Errorf("campaign %q already exists", campaign.Name)
+	}
+	s.Campaigns[campaign.Name] = campaign
+	s.saveLocked()
+	return nil
+}
+
+// Stop removes a campaign and reports whether it existed
+func (s *CampaignStore) Stop(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.Campaigns[name]
+	delete(s.Campaigns, name)
+	s.saveLocked()
+	return ok
+}
+
+// Template returns the template a campaign was launched from
+func (s *CampaignStore) Template(campaign string) (CampaignTemplate, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	launched, ok := s.Campaigns[campaign]
+	if !ok {
+		return CampaignTemplate{}, false
+	}
+	template, ok := s.Templates[launched.Template]
+	return template, ok
+}
+
+// ListTemplates returns the templates sorted by name
+func (s *CampaignStore) ListTemplates() []CampaignTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	templates := make([]CampaignTemplate, 0, len(s.Templates))
+	for _, template := range s.Templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// ListCampaigns returns the campaigns sorted by name
+func (s *CampaignStore) ListCampaigns() []Campaign {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	campaigns := make([]Campaign, 0, len(s.Campaigns))
+	for _, campaign := range s.Campaigns {
+		campaigns = append(campaigns, campaign)
+	}
+	sort.Slice(campaigns, func(i, j int) bool { return campaigns[i].Name < campaigns[j].Name })
+	return campaigns
+}
+
+// validate checks a template's settings, normalizing day names and trigger actions
+func (t *CampaignTemplate) validate(config *Config) error {
+	if t.Window != nil {
+		w := t.Window
+		for i, day := range w.Days {
+			w.Days[i] = strings.ToLower(strings.TrimSpace(day))
+			if len(w.Days[i]) > 3 {
+				w.Days[i] = w.Days[i][:3]
+			}
+			if _, ok := campaignWeekdays[w.Days[i]]; !ok {
+				return fmt.Errorf("window: unknown day %q", day)
+			}
+		}
+		start, errStart := time.Parse("15:04", w.Start)
+		end, errEnd := time.Parse("15:04", w.End)
+		if errStart != nil || errEnd != nil || !end.After(start) {
+			return fmt.Errorf("window: start and end must be HH:MM with end after start")
+		}
+		if _, err := time.LoadLocation(w.zone(config)); err != nil {
+			return fmt.Errorf("window: unknown time_zone %q", w.TimeZone)
+		}
+	}
+	for _, wait := range t.RetryLadder {
+		if delay, err := time.ParseDuration(wait); err != nil || delay <= 0 {
+			return fmt.Errorf("retry_ladder: invalid wait %q, expected e.g. 2h or 90m", wait)
+		}
+	}
+	for _, number := range t.FromNumbers {
+		if !strings.HasPrefix(number, "+") {
+			return fmt.Errorf("from_numbers: %q is not an E.164 number", number)
+		}
+	}
+	for i, action := range t.Triggers.Actions {
+		t.Triggers.Actions[i] = strings.ToLower(strings.TrimSpace(action))
+	}
+	if t.Triggers.DelayMinutes < 0 {
+		return fmt.Errorf("triggers: delay_minutes must not be negative")
+	}
+	return nil
+}
+
+// zone returns the window's time zone name
+func (w *CampaignWindow) zone(config *Config) string {
+	if w.TimeZone != "" {
+		return w.TimeZone
+	}
+	return config.CallWindowTimeZone
+}
+
+// nextOpen returns the first time at or after t within the window
+func (w *CampaignWindow) nextOpen(t time.Time, location *time.Location) time.Time {
+	start, _ := time.Parse("15:04", w.Start)
+	end, _ := time.Parse("15:04", w.End)
+	local := t.In(location)
+	for day := 0; day < 8; day++ {
+		date := local.AddDate(0, 0, day)
+		opens := time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), 0, 0, location)
+		closes := time.Date(date.Year(), date.Month(), date.Day(), end.Hour(), end.Minute(), 0, 0, location)
+		if !w.allowsDay(date.Weekday()) || !local.Before(closes) {
+			continue
+		}
+		if local.After(opens) {
+			return t
+		}
+		return opens
+	}
+	return t
+}
+
+// allowsDay reports whether the window includes a weekday
+func (w *CampaignWindow) allowsDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if campaignWeekdays[name] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether a lead satisfies every condition of the audience; resolvedLabels are
+// the IDs of the audience's label names
+func (a CampaignAudience) matches(payload PipedriveLeadWebhookPayload, resolvedLabels []string) bool {
+	if len(a.Sources) == 0 && len(a.Labels) == 0 && len(a.LabelIDs) == 0 {
+		return false
+	}
+	if len(a.Sources) > 0 {
+		found := false
+		for _, source := range a.Sources {
+			if strings.EqualFold(strings.TrimSpace(payload.Data.SourceName), source) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(a.Labels) == 0 && len(a.LabelIDs) == 0 {
+		return true
+	}
+	return leadHasLabel(payload, append(append([]string{}, a.LabelIDs...), resolvedLabels...))
+}
+
+// leadHasLabel reports whether a lead has any of the label IDs
+func leadHasLabel(payload PipedriveLeadWebhookPayload, labelIDs []string) bool {
+	for _, want := range labelIDs {
+		for _, label := range payload.Data.LabelIDs {
+			if label == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// assignCampaign tags a lead with the launched campaign it belongs to: the one named by its
+// campaign field or source, otherwise the first (by name) whose audience it matches
+func (p *PipedriveService) assignCampaign(payload *PipedriveLeadWebhookPayload) {
+	if payload.Campaign != "" {
+		return
+	}
+	named := p.config.leadCampaign(*payload)
+	for _, campaign := range p.campaigns.ListCampaigns() {
+		if campaign.Name == named || campaign.Audience.matches(*payload, p.leadLabelIDs(campaign.Audience.Labels)) {
+			payload.Campaign = campaign.Name
+			p.debugf(SubsystemLead, "Lead %s belongs to campaign %s (template %s)", payload.Data.ID, campaign.Name, campaign.Template)
+			return
+		}
+	}
+}
+
+// campaignTemplate returns the template of the lead's launched campaign
+func (p *PipedriveService) campaignTemplate(payload PipedriveLeadWebhookPayload) (CampaignTemplate, bool) {
+	if payload.Campaign == "" {
+		return CampaignTemplate{}, false
+	}
+	return p.campaigns.Template(payload.Campaign)
+}
+
+// campaignStopped reports whether the lead's campaign was stopped since the lead was assigned to it;
+// its held, deferred and retried calls are dropped instead of dialing with the default settings
+func (p *PipedriveService) campaignStopped(payload PipedriveLeadWebhookPayload) bool {
+	if payload.Campaign == "" {
+		return false
+	}
+	if _, ok := p.campaigns.Template(payload.Campaign); ok {
+		return false
+	}
+	p.logf("⏹️ Campaign %s was stopped, not calling lead %s", payload.Campaign, payload.Data.ID)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "campaign_call_dropped", PersonID: payload.Data.PersonID,
+		Detail: fmt.Sprintf("lead %s, campaign %s stopped", payload.Data.ID, payload.Campaign)})
+	return true
+}
+
+// campaignTriggers reports whether the lead's campaign calls leads for this webhook, logging why not
+func (p *PipedriveService) campaignTriggers(payload PipedriveLeadWebhookPayload) bool {
+	template, ok := p.campaignTemplate(payload)
+	actions := []string{"create"}
+	if ok && len(template.Triggers.Actions) > 0 {
+		actions = template.Triggers.Actions
+	}
+	triggered := false
+	for _, action := range actions {
+		if payload.Meta.Action == action {
+			triggered = true
+		}
+	}
+	if !triggered {
+		p.logf("ℹ️ Skipping lead event: %s (only processing %s events)", payload.Meta.Action, strings.Join(actions, ", "))
+		return false
+	}
+	if ok && len(template.Triggers.SkipLabels) > 0 {
+		skip := append(append([]string{}, template.Triggers.SkipLabels...), p.leadLabelIDs(template.Triggers.SkipLabels)...)
+		if leadHasLabel(payload, skip) {
+			p.logf("ℹ️ Skipping lead %s: campaign %s does not call leads with its labels", payload.Data.ID, payload.Campaign)
+			return false
+		}
+	}
+	return true
+}
+
+// campaignDialTime returns when the lead's campaign lets its call be placed: after the trigger
+// delay, within the call window. Retries, SMS-first follow-up calls and calls that already waited
+// out the delay are only held for the window
+func (p *PipedriveService) campaignDialTime(payload PipedriveLeadWebhookPayload, now time.Time) time.Time {
+	template, ok := p.campaignTemplate(payload)
+	if !ok {
+		return now
+	}
+	at := now
+	if payload.Attempt == 0 && !payload.ChannelSequenced && !payload.CampaignDelayed {
+		at = at.Add(time.Duration(template.Triggers.DelayMinutes) * time.Minute)
+	}
+	if template.Window != nil {
+		location, err := time.LoadLocation(template.Window.zone(p.config))
+		if err != nil {
+			location = time.UTC
+		}
+		at = template.Window.nextOpen(at, location)
+	}
+	return at
+}
+
+// campaignFromNumber picks a number from the campaign's pool, spreading leads and their retries
+// over the pool
+func (p *PipedriveService) campaignFromNumber(payload PipedriveLeadWebhookPayload) string {
+	template, ok := p.campaignTemplate(payload)
+	if !ok || len(template.FromNumbers) == 0 {
+		return ""
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(payload.Campaign + ":" + payload.Data.ID))
+	return template.FromNumbers[(int(hash.Sum32()%uint32(len(template.FromNumbers)))+payload.Attempt)%len(template.FromNumbers)]
+}
+
+// scheduleCampaignRetry redials an unanswered lead call after the next wait of its campaign's
+// retry ladder
+func (p *PipedriveService) scheduleCampaignRetry(callID string, mapping CallMapping) {
+	if mapping.Campaign == "" || mapping.LeadID == "" {
+		return
+	}
+	template, ok := p.campaigns.Template(mapping.Campaign)
+	if !ok || mapping.Attempt >= len(template.RetryLadder) {
+		return
+	}
+	delay, err := time.ParseDuration(template.RetryLadder[mapping.Attempt])
+	if err != nil {
+		return
+	}
+
+	var payload PipedriveLeadWebhookPayload
+	payload.Data.ID = mapping.LeadID
+	payload.Data.Title = mapping.LeadTitle
+	payload.Data.PersonID = mapping.PersonID
+	payload.Data.OwnerID = mapping.OwnerID
+	payload.Meta.Action = "create"
+	payload.Campaign = mapping.Campaign
+	payload.Attempt = mapping.Attempt + 1
+
+	p.logf("🔁 Call %s for lead %s was not answered, retry %d of %d in %s (campaign %s)",
+		callID, mapping.LeadID, payload.Attempt, len(template.RetryLadder), delay, mapping.Campaign)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "campaign_retry_scheduled", PersonID: mapping.PersonID, CallID: callID,
+		Detail: fmt.Sprintf("lead %s, retry %d of %d in %s (campaign %s)", mapping.LeadID, payload.Attempt, len(template.RetryLadder), delay, mapping.Campaign)})
+	p.holdLeadCall(&payload, QueueReasonRetry, time.Now().Add(delay))
+}
+
+// ListCampaignTemplatesHandler lists campaign templates
+func ListCampaignTemplatesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		templates := pipedriveService.campaigns.ListTemplates()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d campaign templates", len(templates)),
+			Data:    templates,
+		})
+	}
+}
+
+// SaveCampaignTemplateHandler creates or replaces the campaign template named in the path
+func SaveCampaignTemplateHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var template CampaignTemplate
+		if err := c.ShouldBindJSON(&template); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+		template.Name = c.Param("name")
+		if !campaignNamePattern.MatchString(template.Name) {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "name must be 1-64 letters, digits, '-' or '_'",
+			})
+			return
+		}
+		if err := template.validate(pipedriveService.config); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		template.UpdatedBy = actor
+		template.UpdatedAt = time.Now().UTC()
+		pipedriveService.campaigns.SaveTemplate(template)
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "campaign_template_saved", Detail: template.Name})
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Campaign template %s saved", template.Name),
+			Data:    template,
+		})
+	}
+}
+
+// DeleteCampaignTemplateHandler removes a campaign template no campaign uses
+func DeleteCampaignTemplateHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := pipedriveService.campaigns.DeleteTemplate(name); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errCampaignTemplateNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "campaign_template_deleted", Detail: name})
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Campaign template %s deleted", name),
+		})
+	}
+}
+
+// ListCampaignsHandler lists launched campaigns
+func ListCampaignsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaigns := pipedriveService.campaigns.ListCampaigns()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d campaigns", len(campaigns)),
+			Data:    campaigns,
+		})
+	}
+}
+
+// LaunchCampaignHandler launches a campaign from a template and an audience
+func LaunchCampaignHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var campaign Campaign
+		if err := c.ShouldBindJSON(&campaign); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+		if !campaignNamePattern.MatchString(campaign.Name) || campaign.Template == "" {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "name (1-64 letters, digits, '-' or '_') and template are required",
+			})
+			return
+		}
+
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		campaign.CreatedBy = actor
+		campaign.CreatedAt = time.Now().UTC()
+		if err := pipedriveService.campaigns.Launch(campaign); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, errCampaignTemplateNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "campaign_launched",
+			Detail: fmt.Sprintf("%s from template %s", campaign.Name, campaign.Template)})
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Campaign %s launched", campaign.Name),
+			Data:    campaign,
+		})
+	}
+}
+
+// StopCampaignHandler stops a campaign; calls it still holds or retries are dropped, and new leads
+// are handled with the default settings again
+func StopCampaignHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if !pipedriveService.campaigns.Stop(name) {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: fmt.Sprintf("Campaign %s not found", name),
+			})
+			return
+		}
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "campaign_stopped", Detail: name})
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Campaign %s stopped", name),
+		})
+	}
+}
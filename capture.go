@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Captcha providers
+const (
+	CaptchaHCaptcha  = "hcaptcha"
+	CaptchaReCaptcha = "recaptcha"
+)
+
+// captchaVerifyURLs are the siteverify endpoints per provider
+var captchaVerifyURLs = map[string]string{
+	CaptchaHCaptcha:  "https://hcaptcha.com/siteverify",
+	CaptchaReCaptcha: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// LeadCaptureRequest is a landing page form submission
+type LeadCaptureRequest struct {
+	Name         string `json:"name" form:"name"`
+	Email        string `json:"email" form:"email"`
+	Phone        string `json:"phone" form:"phone"`
+	Company      string `json:"company" form:"company"`
+	Message      string `json:"message" form:"message"`
+	Page         string `json:"page" form:"page"`         // Landing page URL or name
+	Referrer     string `json:"referrer" form:"referrer"` // document.referrer of the landing page
+	UTMSource    string `json:"utm_source" form:"utm_source"`
+	UTMMedium    string `json:"utm_medium" form:"utm_medium"`
+	UTMCampaign  string `json:"utm_campaign" form:"utm_campaign"`
+	UTMTerm      string `json:"utm_term" form:"utm_term"`
+	UTMContent   string `json:"utm_content" form:"utm_content"`
+	GCLID        string `json:"gclid" form:"gclid"`
+	FBCLID       string `json:"fbclid" form:"fbclid"`
+	CaptchaToken string `json:"captcha_token" form:"captcha_token"` // h-captcha-response / g-recaptcha-response
+	Website      string `json:"website" form:"website"`             // Honeypot, must stay empty
+}
+
+// validate normalizes the submission and returns the first problem with it
+func (r *LeadCaptureRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	r.Phone = strings.TrimSpace(r.Phone)
+	if r.Email == "" && r.Phone == "" {
+		return fmt.Errorf("an email or phone number is required")
+	}
+	if r.Email != "" {
+		address, err := mail.ParseAddress(r.Email)
+		if err != nil || address.Address != r.Email {
+			return fmt.Errorf("invalid email address")
+		}
+	}
+	if r.Phone != "" {
+		phone := findPhoneInText(r.Phone)
+		if phone == "" {
+			return fmt.Errorf("invalid phone number")
+		}
+		r.Phone = phone
+	}
+	if len(r.Name) > 200 || len(r.Company) > 200 || len(r.Message) > 5000 {
+		return fmt.Errorf("submission too long")
+	}
+	return nil
+}
+
+// CaptureRateLimiter counts submissions per client IP in fixed one-minute windows
+type CaptureRateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Time
+	counts map[string]int
+}
+
+// NewCaptureRateLimiter creates a limiter allowing limit submissions per IP per minute (0 disables it)
+func NewCaptureRateLimiter(limit int) *CaptureRateLimiter {
+	return &CaptureRateLimiter{limit: limit, counts: make(map[string]int)}
+}
+
+// Allow counts a submission and returns false once the IP is over the limit
+func (l *CaptureRateLimiter) Allow(ip string) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	window := time.Now().Truncate(time.Minute)
+	if !window.Equal(l.window) {
+		l.window = window
+		l.counts = make(map[string]int)
+	}
+	l.counts[ip]++
+	return l.counts[ip] <= l.limit
+}
+
+// configureTrustedProxies decides which forwarding headers c.ClientIP() believes; by default none,
+// so a client cannot pick its own address for the capture rate limit
+func configureTrustedProxies(router *gin.Engine, config *Config) {
+	if err := router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Printf("⚠️ Warning: Invalid TRUSTED_PROXIES, trusting no proxy: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+	router.TrustedPlatform = config.TrustedClientIPHeader
+}
+
+// verifyCaptcha checks a captcha token with the configured provider
+func (p *PipedriveService) verifyCaptcha(token, remoteIP string) error {
+	provider := strings.ToLower(p.config.CaptureCaptchaProvider)
+	verifyURL, ok := captchaVerifyURLs[provider]
+	if !ok {
+		return fmt.Errorf("unknown captcha provider %q", provider)
+	}
+	if token == "" {
+		return fmt.Errorf("captcha is required")
+	}
+
+	form := url.Values{}
+	form.Set("secret", p.config.CaptureCaptchaSecret)
+	form.Set("response", token)
+	form.Set("remoteip", remoteIP)
+	resp, err := p.httpClient.PostForm(verifyURL, form)
+	if err != nil {
+		return fmt.Errorf("failed to verify captcha: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read captcha response: %v", err)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		Score      *float64 `json:"score"` // reCAPTCHA v3 only
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse captcha response: %v", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("captcha rejected: %s", strings.Join(result.ErrorCodes, ", "))
+	}
+	if result.Score != nil && *result.Score < p.config.CaptureMinCaptchaScore {
+		return fmt.Errorf("captcha score %.2f below %.2f", *result.Score, p.config.CaptureMinCaptchaScore)
+	}
+	return nil
+}
+
+// attribution collects the submitted UTM fields, then those on the page URL and request
+func (r LeadCaptureRequest) attribution(c *gin.Context) Attribution {
+	attribution := Attribution{
+		"utm_source":   r.UTMSource,
+		"utm_medium":   r.UTMMedium,
+		"utm_campaign": r.UTMCampaign,
+		"utm_term":     r.UTMTerm,
+		"utm_content":  r.UTMContent,
+		"gclid":        r.GCLID,
+		"fbclid":       r.FBCLID,
+		"referrer":     r.Referrer,
+	}
+	for key, value := range attribution {
+		if value == "" {
+			delete(attribution, key)
+		}
+	}
+	attribution.merge(attributionFromURL(r.Page))
+	attribution.merge(attributionFromRequest(c))
+	return attribution
+}
+
+// inboundLead turns a form submission into an inbound lead
+func (r LeadCaptureRequest) inboundLead(autoCall bool) InboundLead {
+	title := "Website lead"
+	if r.Name != "" {
+		title = "Website lead: " + r.Name
+	}
+	note := fmt.Sprintf("Landing page submission\nPage: %s\nName: %s\nEmail: %s\nPhone: %s\nCompany: %s",
+		r.Page, r.Name, r.Email, r.Phone, r.Company)
+	if r.Message != "" {
+		note += "\n\n" + r.Message
+	}
+	return InboundLead{
+		Source:   "web form",
+		Name:     r.Name,
+		Email:    r.Email,
+		Phone:    r.Phone,
+		Title:    title,
+		Note:     note,
+		AutoCall: autoCall && r.Phone != "",
+	}
+}
+
+// LeadCaptureHandler creates a Pipedrive person and lead from a landing page form and queues the AI call
+func LeadCaptureHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := pipedriveService.config
+		if !pipedriveService.captureLimiter.Allow(c.ClientIP()) {
+			c.Header("Retry-After", "60")
+			c.JSON(http.StatusTooManyRequests, WebhookResponse{
+				Success: false,
+				Message: "Too many submissions, please try again in a minute",
+			})
+			return
+		}
+
+		var request LeadCaptureRequest
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid submission",
+			})
+			return
+		}
+		if request.CaptchaToken == "" {
+			request.CaptchaToken = c.PostForm("h-captcha-response")
+			if request.CaptchaToken == "" {
+				request.CaptchaToken = c.PostForm("g-recaptcha-response")
+			}
+		}
+
+		// Bots fill every field; answer as if accepted so they learn nothing
+		if request.Website != "" {
+			log.Printf("🍯 Dropped capture submission from %s (honeypot filled)", c.ClientIP())
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "Thanks! We'll be in touch shortly.",
+			})
+			return
+		}
+		if err := request.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		if config.CaptureCaptchaProvider != "" {
+			if err := pipedriveService.verifyCaptcha(request.CaptchaToken, c.ClientIP()); err != nil {
+				log.Printf("⚠️ Capture submission from %s failed captcha: %v", c.ClientIP(), err)
+				c.JSON(http.StatusForbidden, WebhookResponse{
+					Success: false,
+					Message: "Captcha verification failed, please try again",
+				})
+				return
+			}
+		}
+		if request.Email != "" {
+			if err := pipedriveService.emailValidator.Validate(request.Email); err != nil {
+				log.Printf("⚠️ Capture submission from %s: %v", c.ClientIP(), err)
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "Please enter a valid email address",
+				})
+				return
+			}
+		}
+		if !config.HasPipedriveConfig() {
+			c.JSON(http.StatusServiceUnavailable, WebhookResponse{
+				Success: false,
+				Message: "Lead capture is not available right now",
+			})
+			return
+		}
+
+		lead := request.inboundLead(config.CaptureLeadAutoCall)
+		lead.Attribution = request.attribution(c)
+		result, err := pipedriveService.intakeLead(lead)
+		if err != nil {
+			log.Printf("❌ Failed to create lead from capture form: %v", err)
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "We couldn't save your details, please try again",
+			})
+			return
+		}
+		message := "Thanks! We'll be in touch shortly."
+		if result.CallQueued {
+			message = "Thanks! We'll call you in a few minutes."
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: message,
+			Data:    gin.H{"lead_id": result.LeadID, "call_queued": result.CallQueued},
+		})
+	}
+}
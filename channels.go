@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// First-touch channels for a lead
+const (
+	ChannelVoice = "voice" // Call first
+	ChannelSMS   = "sms"   // Text first, then call after CallAfterMinutes
+)
+
+// ChannelPolicy picks the first-touch channel per line type
+type ChannelPolicy struct {
+	Mobile           string `json:"mobile"`
+	Landline         string `json:"landline"` // Always voice; landlines cannot receive SMS
+	VoIP             string `json:"voip"`
+	SMSTemplate      string `json:"sms_template"`       // {{name}}, {{title}}, {{campaign}}
+	CallAfterMinutes int    `json:"call_after_minutes"` // Delay before the follow-up call of an SMS-first sequence; negative for SMS only
+}
+
+// channelFor returns the channel for a line type; unknown line types are called
+func (c ChannelPolicy) channelFor(lineType string) string {
+	switch lineType {
+	case LineTypeMobile:
+		return c.Mobile
+	case LineTypeVoIP:
+		return c.VoIP
+	}
+	return ChannelVoice
+}
+
+// normalizeChannel validates a channel name, defaulting to voice
+func normalizeChannel(name, setting string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ChannelVoice:
+		return ChannelVoice
+	case ChannelSMS:
+		return ChannelSMS
+	}
+	log.Printf("⚠️ Invalid %s channel %q, using %s", setting, name, ChannelVoice)
+	return ChannelVoice
+}
+
+// normalizeChannelPolicy fills unset fields from the defaults and validates channel names
+func normalizeChannelPolicy(policy, defaults ChannelPolicy, setting string) ChannelPolicy {
+	if policy.Mobile == "" {
+		policy.Mobile = defaults.Mobile
+	}
+	if policy.VoIP == "" {
+		policy.VoIP = defaults.VoIP
+	}
+	if policy.SMSTemplate == "" {
+		policy.SMSTemplate = defaults.SMSTemplate
+	}
+	if policy.CallAfterMinutes == 0 {
+		policy.CallAfterMinutes = defaults.CallAfterMinutes
+	}
+	policy.Mobile = normalizeChannel(policy.Mobile, setting)
+	policy.VoIP = normalizeChannel(policy.VoIP, setting)
+	if normalizeChannel(policy.Landline, setting) == ChannelSMS {
+		log.Printf("⚠️ %s: landlines cannot receive SMS, using %s", setting, ChannelVoice)
+	}
+	policy.Landline = ChannelVoice
+	return policy
+}
+
+// loadCampaignChannels parses CAMPAIGN_CHANNELS (JSON: campaign -> policy), filling unset fields from the defaults
+func loadCampaignChannels(raw string, defaults ChannelPolicy) map[string]ChannelPolicy {
+	policies := make(map[string]ChannelPolicy)
+	if raw == "" {
+		return policies
+	}
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		log.Printf("⚠️ Invalid CAMPAIGN_CHANNELS, ignoring: %v", err)
+		return make(map[string]ChannelPolicy)
+	}
+	for campaign, policy := range policies {
+		policies[campaign] = normalizeChannelPolicy(policy, defaults, "CAMPAIGN_CHANNELS["+campaign+"]")
+	}
+	return policies
+}
+
+// ChannelPolicyFor returns the campaign's policy, falling back to the CHANNEL_* defaults
+func (c *Config) ChannelPolicyFor(campaign string) ChannelPolicy {
+	if policy, ok := c.CampaignChannels[campaign]; ok {
+		return policy
+	}
+	return c.Channels
+}
+
+// leadLineType returns the person's stored line type: the PHONE_LINE_TYPE_FIELD value, else the
+// lookup made for the number, else a fresh lookup when PHONE_LOOKUP_PROVIDER is configured
+func (p *PipedriveService) leadLineType(person *PipedrivePerson, phoneNumber string, lookup *PhoneLookupResult) string {
+	if lookup != nil {
+		return lookup.LineType
+	}
+	if p.config.PhoneLineTypeField != "" {
+		if lineType := strings.ToLower(formatLeadFieldValue(person.Fields[p.config.PhoneLineTypeField])); lineType != "" {
+			return lineType
+		}
+	}
+	if p.phoneValidator == nil {
+		return LineTypeUnknown
+	}
+	if lineType, ok := p.phoneValidator.LineType(phoneNumber); ok {
+		return lineType
+	}
+	result, err := p.phoneValidator.Validate(phoneNumber)
+	if err != nil {
+		p.logf("⚠️ Warning: Phone lookup failed for %s, calling first: %v", phoneNumber, err)
+		return LineTypeUnknown
+	}
+	p.storeLineType(person.ID, result.LineType)
+	return result.LineType
+}
+
+// leadChannel chooses the first-touch channel; a channel already recorded on the person wins so
+// later leads (and manual overrides by reps) follow the earlier decision
+func (p *PipedriveService) leadChannel(payload PipedriveLeadWebhookPayload, person *PipedrivePerson, phoneNumber string, lookup *PhoneLookupResult) (string, ChannelPolicy) {
+	campaign := p.config.leadCampaign(payload)
+	policy := p.config.ChannelPolicyFor(campaign)
+	if p.config.ChannelField != "" {
+		switch recorded := strings.ToLower(formatLeadFieldValue(person.Fields[p.config.ChannelField])); recorded {
+		case ChannelVoice, ChannelSMS:
+			return recorded, policy
+		}
+	}
+	if policy.Mobile == ChannelVoice && policy.VoIP == ChannelVoice {
+		return ChannelVoice, policy
+	}
+	lineType := p.leadLineType(person, phoneNumber, lookup)
+	channel := policy.channelFor(lineType)
+	p.logf("📡 Lead %s (campaign %q, %s line) gets %s first", payload.Data.ID, campaign, lineType, channel)
+	p.storeChannel(person.ID, channel)
+	return channel, policy
+}
+
+// storeChannel records the chosen channel in the configured Pipedrive person field
+func (p *PipedriveService) storeChannel(personID int, channel string) {
+	if p.config.ChannelField == "" || !p.config.HasPipedriveConfig() {
+		return
+	}
+	resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", personID), map[string]interface{}{
+		p.config.ChannelField: channel,
+	})
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to store channel for person %d: %v", personID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// startSMSFirst texts the lead and schedules the follow-up call; it reports whether the SMS
+// sequence took over, returning false to call now when SMS is unavailable
+func (p *PipedriveService) startSMSFirst(payload PipedriveLeadWebhookPayload, person *PipedrivePerson, phoneNumber string, policy ChannelPolicy) bool {
+	if p.sms == nil {
+		p.logf("⚠️ SMS-first channel selected for lead %s but SMS is not configured (TWILIO_SMS_FROM), calling instead", payload.Data.ID)
+		return false
+	}
+	if blocked, notUntil := p.contactGate(person, phoneNumber, ContactSMS, "first-touch SMS", time.Now()); blocked != "" || !notUntil.IsZero() {
+		// The call path runs the same checks and holds the call for contact hours
+		return false
+	}
+	if _, ok := p.claim("sms-first:" + payload.Data.ID); !ok {
+		return true
+	}
+
+	campaign := p.config.leadCampaign(payload)
+	message := fmt.Sprintf("Hi %s, thanks for your interest in %s. We'll give you a call shortly - reply here if another time suits you better.", person.Name, payload.Data.Title)
+	if policy.SMSTemplate != "" {
+		message = renderTemplate(policy.SMSTemplate, map[string]string{
+			"name":     person.Name,
+			"title":    payload.Data.Title,
+			"campaign": campaign,
+		})
+	}
+	message = p.smsText(p.shortenLinks(message, payload.Data.PersonID))
+	reference, err := p.sms.Send(phoneNumber, message)
+	if err != nil {
+		p.logf("❌ Failed to send first-touch SMS for lead %s, calling instead: %v", payload.Data.ID, err)
+		return false
+	}
+	p.logf("✅ Sent first-touch SMS for lead %s to %s (%s)", payload.Data.ID, phoneNumber, reference)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "sms_sent", PersonID: payload.Data.PersonID, Detail: fmt.Sprintf("first-touch SMS for lead %s (%s)", payload.Data.ID, reference)})
+
+	followUp := "No follow-up call (SMS only)"
+	if policy.CallAfterMinutes > 0 {
+		delay := time.Duration(policy.CallAfterMinutes) * time.Minute
+		followUp = fmt.Sprintf("Follow-up AI call at %s", time.Now().Add(delay).UTC().Format(time.RFC3339))
+		payload.ChannelSequenced = true
+		p.holdLeadCall(&payload, QueueReasonSMSFirst, time.Now().Add(delay))
+	}
+
+	activityData := map[string]interface{}{
+		"subject":   fmt.Sprintf("First-touch SMS sent - Lead: %s", payload.Data.Title),
+		"type":      "task",
+		"person_id": payload.Data.PersonID,
+		"note":      fmt.Sprintf("SMS sent to %s\nReference: %s\nCampaign: %s\n%s\n\n%s", phoneNumber, reference, campaign, followUp, message),
+		"done":      1,
+		"due_date":  pipedriveDueDate(time.Now()),
+		"due_time":  pipedriveDueTime(time.Now()),
+	}
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to log first-touch SMS for person %d: %v", payload.Data.PersonID, err)
+	} else {
+		resp.Body.Close()
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Chaos faults
+const (
+	ChaosFaultStatus  = "status"  // Answer with an error status without calling the service
+	ChaosFaultTimeout = "timeout" // Hang, then fail as a timeout
+	ChaosFaultSlow    = "slow"    // Delay, then make the real request
+)
+
+// defaultChaosDelay applies to timeout and slow faults without a delay
+const defaultChaosDelay = 5 * time.Second
+
+// ChaosRule injects a fault into a percentage of outbound requests to a target
+type ChaosRule struct {
+	Target  string  `json:"target"`           // "pipedrive", "retell", "cal", "*" or a host substring
+	Fault   string  `json:"fault"`            // status, timeout or slow
+	Status  int     `json:"status,omitempty"` // Status for status faults, e.g. 429 or 500
+	Delay   string  `json:"delay,omitempty"`  // Hang/delay for timeout and slow faults, e.g. "3s"
+	Percent float64 `json:"percent"`          // Share of matching requests affected (0-100)
+
+	delay time.Duration
+}
+
+// loadChaosRules parses the CHAOS_RULES JSON list
+func loadChaosRules(raw string) []ChaosRule {
+	if raw == "" {
+		return nil
+	}
+	var rules []ChaosRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Printf("⚠️ Invalid CHAOS_RULES, ignoring: %v", err)
+		return nil
+	}
+
+	valid := make([]ChaosRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.Fault = strings.ToLower(rule.Fault)
+		switch rule.Fault {
+		case ChaosFaultStatus:
+			if rule.Status < 400 || rule.Status > 599 {
+				log.Printf("⚠️ Ignoring chaos rule %d: status must be 4xx or 5xx", i)
+				continue
+			}
+		case ChaosFaultTimeout, ChaosFaultSlow:
+		default:
+			log.Printf("⚠️ Ignoring chaos rule %d: fault must be status, timeout or slow", i)
+			continue
+		}
+		rule.delay = defaultChaosDelay
+		if rule.Delay != "" {
+			if delay, err := time.ParseDuration(rule.Delay); err == nil && delay > 0 {
+				rule.delay = delay
+			}
+		}
+		if rule.Target == "" {
+			rule.Target = "*"
+		}
+		valid = append(valid, rule)
+	}
+	return valid
+}
+
+// ChaosTransport injects configured failures into outbound requests (never enabled in production)
+type ChaosTransport struct {
+	next     http.RoundTripper
+	mu       sync.Mutex
+	rules    []ChaosRule
+	injected []int64           // Faults injected per rule
+	targets  map[string]string // Target name -> host
+}
+
+// newChaosTransport wraps a transport with failure injection, or returns it unchanged when chaos is off
+func newChaosTransport(config *Config, next http.RoundTripper) http.RoundTripper {
+	if !config.ChaosEnabled || len(config.ChaosRules) == 0 {
+		return next
+	}
+	if config.IsProduction() {
+		log.Printf("⚠️ CHAOS_ENABLED is ignored in production (APP_ENV=%s)", config.AppEnv)
+		return next
+	}
+
+	log.Printf("💥 Chaos failure injection enabled with %d rules", len(config.ChaosRules))
+	return &ChaosTransport{
+		next:     next,
+		rules:    config.ChaosRules,
+		injected: make([]int64, len(config.ChaosRules)),
+		targets:  vendorHosts(config),
+	}
+}
+
+// vendorHosts maps vendor names to the hosts of their configured base URLs
+func vendorHosts(config *Config) map[string]string {
+	hosts := make(map[string]string)
+	for name, base := range map[string]string{
+		"pipedrive": config.PipedriveBaseURL,
+		"retell":    config.RetellBaseURL,
+		"cal":       config.CalAPIURL,
+	} {
+		if parsed, err := url.Parse(base); err == nil && parsed.Host != "" {
+			hosts[name] = parsed.Host
+		}
+	}
+	return hosts
+}
+
+// matches returns true if a rule applies to a request host
+func (t *ChaosTransport) matches(rule ChaosRule, host string) bool {
+	if rule.Target == "*" {
+		return true
+	}
+	if targetHost, ok := t.targets[rule.Target]; ok {
+		return host == targetHost
+	}
+	return strings.Contains(host, rule.Target)
+}
+
+// RoundTrip applies the first rule that fires for the request, then makes the request unless the fault replaces it
+func (t *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for i, rule := range t.rules {
+		if !t.matches(rule, req.URL.Host) || rand.Float64()*100 >= rule.Percent {
+			continue
+		}
+		t.mu.Lock()
+		t.injected[i]++
+		t.mu.Unlock()
+		log.Printf("💥 Chaos: injecting %s into %s %s", rule.Fault, req.Method, req.URL.Host)
+
+		switch rule.Fault {
+		case ChaosFaultStatus:
+			header := make(http.Header)
+			header.Set("Content-Type", "application/json")
+			if rule.Status == http.StatusTooManyRequests {
+				header.Set("Retry-After", "1")
+				vendorThrottles.Record(req.URL.Host)
+			}
+			return &http.Response{
+				Status:     fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+				StatusCode: rule.Status,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(fmt.Sprintf(`{"success":false,"error":"chaos: injected %d"}`, rule.Status))),
+				Request:    req,
+			}, nil
+		case ChaosFaultTimeout:
+			select {
+			case <-time.After(rule.delay):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			return nil, fmt.Errorf("chaos: injected timeout after %s", rule.delay)
+		case ChaosFaultSlow:
+			select {
+			case <-time.After(rule.delay):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+		return t.next.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Report returns the rules with how often each fired
+func (t *ChaosTransport) Report() []gin.H {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := make([]gin.H, 0, len(t.rules))
+	for i, rule := range t.rules {
+		report = append(report, gin.H{
+			"target":   rule.Target,
+			"fault":    rule.Fault,
+			"status":   rule.Status,
+			"delay":    rule.delay.String(),
+			"percent":  rule.Percent,
+			"injected": t.injected[i],
+		})
+	}
+	return report
+}
+
+// ChaosStatusHandler reports the active failure injection rules
+func ChaosStatusHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chaos, ok := pipedriveService.httpClient.Transport.(*ChaosTransport)
+		if !ok {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "Chaos failure injection disabled",
+				Data:    gin.H{"enabled": false},
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Chaos failure injection enabled",
+			Data:    gin.H{"enabled": true, "rules": chaos.Report()},
+		})
+	}
+}
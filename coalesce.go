@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// CoalescedLead is a lead whose webhook was merged into another lead's call
+type CoalescedLead struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// coalescedBatch is a person's held lead and the outcome of dispatching it
+type coalescedBatch struct {
+	payload PipedriveLeadWebhookPayload
+	done    chan struct{} // Closed once the batch is dispatched
+	err     error
+}
+
+// wait blocks until the batch is dispatched and returns the dispatch result
+func (b *coalescedBatch) wait() error {
+	<-b.done
+	return b.err
+}
+
+// LeadCoalescer merges lead webhooks for the same person that arrive within a short window
+type LeadCoalescer struct {
+	mu      sync.Mutex
+	window  time.Duration
+	pending map[int]*coalescedBatch // Keyed by person ID
+}
+
+// newLeadCoalescer creates a coalescer, or returns nil when LEAD_COALESCE_SECONDS is 0
+func newLeadCoalescer(config *Config) *LeadCoalescer {
+	if config.LeadCoalesceSeconds <= 0 {
+		return nil
+	}
+	return &LeadCoalescer{
+		window:  time.Duration(config.LeadCoalesceSeconds) * time.Second,
+		pending: make(map[int]*coalescedBatch),
+	}
+}
+
+// Add queues a lead webhook; the first lead of a person's window is dispatched with the others merged into it.
+// It blocks until that call is dispatched and returns the dispatch result, which merged leads share
+func (c *LeadCoalescer) Add(payload PipedriveLeadWebhookPayload, dispatch func(PipedriveLeadWebhookPayload) error) error {
+	personID := payload.Data.PersonID
+
+	c.mu.Lock()
+	if batch, ok := c.pending[personID]; ok {
+		first := &batch.payload
+		merged := first.Data.ID == payload.Data.ID
+		for _, lead := range first.Coalesced {
+			merged = merged || lead.ID == payload.Data.ID
+		}
+		if !merged {
+			first.Coalesced = append(first.Coalesced, CoalescedLead{ID: payload.Data.ID, Title: payload.Data.Title})
+			log.Printf("🧩 Merged lead %s into pending call for person %d (lead %s)", payload.Data.ID, personID, first.Data.ID)
+		}
+		c.mu.Unlock()
+		return batch.wait()
+	}
+
+	batch := &coalescedBatch{payload: payload, done: make(chan struct{})}
+	c.pending[personID] = batch
+	c.mu.Unlock()
+	debugf(SubsystemLead, "Holding lead %s for %s to coalesce webhooks for person %d", payload.Data.ID, c.window, personID)
+
+	time.AfterFunc(c.window, func() {
+		c.mu.Lock()
+		delete(c.pending, personID)
+		held := batch.payload
+		c.mu.Unlock()
+		batch.err = dispatch(held)
+		close(batch.done)
+	})
+	return batch.wait()
+}
+
+// coalescedNoteSection lists the leads merged into a call for the initiation activity note
+func coalescedNoteSection(leads []CoalescedLead) string {
+	if len(leads) == 0 {
+		return ""
+	}
+	section := "\n\nMerged leads (same person, same burst):"
+	for _, lead := range leads {
+		section += fmt.Sprintf("\n- %s (%s)", lead.Title, lead.ID)
+	}
+	return section
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isOptOutEvent reports whether a Retell call event is a caller opting out
+func isOptOutEvent(payload RetellWebhookPayload) bool {
+	return payload.Event == "call.optout"
+}
+
+// marksPersonDNC reports whether a person webhook carries the person marked Do Not Call
+func (p *PipedriveService) marksPersonDNC(payload PipedrivePersonWebhookPayload) bool {
+	value, ok := personFieldValue(payload.person(), p.config.DNCField)
+	if !ok {
+		return false
+	}
+	return p.isMarkedDNC(&PipedrivePerson{Fields: map[string]interface{}{p.config.DNCField: value}})
+}
+
+// isComplianceRequest reports whether a webhook carries an opt-out or DNC change, which must not
+// be throttled or wait behind queued campaign work; the request body is left readable
+func (p *PipedriveService) isComplianceRequest(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	if c.Request.Body == nil || (path != "/webhook/retell" && path != "/webhook/pipedrive/person") {
+		return false
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	if path == "/webhook/retell" {
+		// Only the event is needed; decoding the rest may fail on fields the handler reports itself
+		var event struct {
+			Event string `json:"event"`
+		}
+		return json.Unmarshal(body, &event) == nil && isOptOutEvent(RetellWebhookPayload{Event: event.Event})
+	}
+	var payload PipedrivePersonWebhookPayload
+	return json.Unmarshal(body, &payload) == nil && p.marksPersonDNC(payload)
+}
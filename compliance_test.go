@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestComplianceRequestsAreRecognized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service := &PipedriveService{config: &Config{}}
+	tests := []struct {
+		path, body string
+		want       bool
+	}{
+		{"/webhook/retell", `{"event":"call.optout","call_id":"c1"}`, true},
+		{"/webhook/retell", `{"event":"call_ended","call_id":"c1"}`, false},
+		{"/webhook/retell", `not json`, false},
+		{"/webhook/pipedrive", `{"event":"call.optout"}`, false},
+	}
+	for _, test := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", test.path, strings.NewReader(test.body))
+		if got := service.isComplianceRequest(c); got != test.want {
+			t.Errorf("isComplianceRequest(%s %s) = %v, want %v", test.path, test.body, got, test.want)
+		}
+		// The handler still reads the body after the check
+		if body, _ := io.ReadAll(c.Request.Body); string(body) != test.body {
+			t.Errorf("body after the check is %q, want %q", body, test.body)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// maxConfigImportSize caps the size of an imported config file
+const maxConfigImportSize = 1 << 20
+
+// exportExcludedSettings are settings left out of exports besides the redacted ones, as they embed
+// credentials or are specific to one environment's infrastructure
+var exportExcludedSettings = []string{"redisurl"}
+
+// secretSetting reports whether an env var holds a secret, matched like redactedConfigFields
+func secretSetting(key string) bool {
+	name := strings.ToLower(strings.ReplaceAll(key, "_", ""))
+	for _, secret := range redactedConfigFields {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	for _, excluded := range exportExcludedSettings {
+		if strings.Contains(name, excluded) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportedSettings returns the settings set through env vars or the config file, by env var name,
+// without secrets; settings left at their defaults are not included
+func exportedSettings() map[string]string {
+	configFile.Lock()
+	defer configFile.Unlock()
+	settings := make(map[string]string, len(configFile.values))
+	for key, value := range configFile.values {
+		settings[key] = value
+	}
+	for key, source := range configFile.sources {
+		if source == ConfigSourceEnv {
+			settings[key] = os.Getenv(key)
+		}
+	}
+	for key := range settings {
+		if secretSetting(key) {
+			delete(settings, key)
+		}
+	}
+	return settings
+}
+
+// takeJSONSetting removes a JSON setting and decodes it into target, leaving it in place if it
+// doesn't decode
+func takeJSONSetting(settings map[string]string, key string, target interface{}) bool {
+	raw, ok := settings[key]
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal([]byte(raw), target); err != nil {
+		return false
+	}
+	delete(settings, key)
+	return true
+}
+
+// takeBoolSetting removes a boolean setting, leaving it in place if it doesn't parse
+func takeBoolSetting(settings map[string]string, key string) (bool, bool) {
+	value, err := strconv.ParseBool(settings[key])
+	if err != nil {
+		return false, false
+	}
+	delete(settings, key)
+	return value, true
+}
+
+// takeIntSetting removes an integer setting, leaving it in place if it doesn't parse
+func takeIntSetting(settings map[string]string, key string) (int, bool) {
+	value, err := strconv.Atoi(settings[key])
+	if err != nil {
+		return 0, false
+	}
+	delete(settings, key)
+	return value, true
+}
+
+// exportConfigFile lays the settings out as a config file: tenants, field mappings, rules,
+// templates and schedules in their sections, everything else under env
+func exportConfigFile(settings map[string]string) map[string]interface{} {
+	file := make(map[string]interface{})
+	rest := make(map[string]string, len(settings))
+	for key, value := range settings {
+		rest[key] = value
+	}
+
+	// Tenants; encryption keys are secrets and never exported
+	var agentTenants, regions map[string]string
+	if takeJSONSetting(rest, "AGENT_TENANTS", &agentTenants) {
+		takeJSONSetting(rest, "TENANT_REGIONS", &regions)
+		tenants := make(map[string]map[string]interface{})
+		tenant := func(name string) map[string]interface{} {
+			if tenants[name] == nil {
+				tenants[name] = make(map[string]interface{})
+			}
+			return tenants[name]
+		}
+		agents := make(map[string][]string)
+		for agentID, name := range agentTenants {
+			agents[name] = append(agents[name], agentID)
+		}
+		for name, ids := range agents {
+			sort.Strings(ids)
+			tenant(name)["agents"] = ids
+		}
+		for name, region := range regions {
+			tenant(name)["region"] = region
+		}
+		if len(tenants) > 0 {
+			file["tenants"] = tenants
+		}
+	}
+
+	// Field mappings
+	mappings := make(map[string]interface{})
+	for _, mapping := range []struct{ key, name string }{
+		{"DYNAMIC_VARIABLE_MAPPING", "dynamic_variables"},
+		{"LEAD_FIELD_MAPPING", "lead_fields"},
+		{"NOTION_PROPERTY_MAPPING", "notion_properties"},
+		{"GOOGLE_CALENDAR_IDS", "calendar_ids"},
+	} {
+		var values map[string]string
+		if takeJSONSetting(rest, mapping.key, &values) && len(values) > 0 {
+			mappings[mapping.name] = values
+		}
+	}
+	if len(mappings) > 0 {
+		file["field_mappings"] = mappings
+	}
+
+	// Rules
+	rules := make(map[string]interface{})
+	triggers := make(map[string]bool)
+	for name, key := range triggerEnvVars {
+		if enabled, ok := takeBoolSetting(rest, key); ok {
+			triggers[name] = enabled
+		}
+	}
+	if len(triggers) > 0 {
+		rules["triggers"] = triggers
+	}
+	for _, rule := range []struct{ key, name string }{
+		{"DIAL_RULES", "dial"},
+		{"LEAD_SCORING_RULES", "scoring"},
+		{"AGENT_EXPERIMENTS", "experiments"},
+		{"NURTURE_RULES", "nurture"},
+	} {
+		var value interface{}
+		if takeJSONSetting(rest, rule.key, &value) && value != nil {
+			rules[rule.name] = value
+		}
+	}
+	if len(rules) > 0 {
+		file["rules"] = rules
+	}
+
+	// Templates
+	templates := make(map[string]interface{})
+	for _, template := range []struct{ key, name string }{
+		{"FOLLOWUP_MESSAGE_TEMPLATE", "follow_up"},
+		{"REMINDER_SMS_TEMPLATE", "reminder_sms"},
+	} {
+		if value, ok := rest[template.key]; ok {
+			templates[template.name] = value
+			delete(rest, template.key)
+		}
+	}
+	if len(templates) > 0 {
+		file["templates"] = templates
+	}
+
+	// Schedules
+	reminders := make(map[string]interface{})
+	if enabled, ok := takeBoolSetting(rest, "REMINDERS_ENABLED"); ok {
+		reminders["enabled"] = enabled
+	}
+	if channel := rest["REMINDER_CHANNEL"]; channel == "sms" || channel == "call" {
+		reminders["channel"] = channel
+		delete(rest, "REMINDER_CHANNEL")
+	}
+	if hours, ok := takeIntSetting(rest, "REMINDER_HOURS_BEFORE"); ok {
+		reminders["hours_before"] = hours
+	}
+	if agentID, ok := rest["REMINDER_AGENT_ID"]; ok {
+		reminders["agent_id"] = agentID
+		delete(rest, "REMINDER_AGENT_ID")
+	}
+	windows := make(map[string]interface{})
+	if zone, ok := rest["CALL_WINDOW_TIME_ZONE"]; ok {
+		windows["time_zone"] = zone
+		delete(rest, "CALL_WINDOW_TIME_ZONE")
+	}
+	if bias, ok := takeBoolSetting(rest, "CALL_WINDOW_BIAS"); ok {
+		windows["bias"] = bias
+	}
+	if hours, ok := takeIntSetting(rest, "CALL_WINDOW_MAX_DELAY_HOURS"); ok {
+		windows["max_delay_hours"] = hours
+	}
+	schedules := make(map[string]interface{})
+	if len(reminders) > 0 {
+		schedules["reminders"] = reminders
+	}
+	if len(windows) > 0 {
+		schedules["call_windows"] = windows
+	}
+	if len(schedules) > 0 {
+		file["schedules"] = schedules
+	}
+
+	if len(rest) > 0 {
+		file["env"] = rest
+	}
+	return file
+}
+
+// ConfigExportHandler exports the runtime configuration, without secrets, as a config file that
+// can be imported into another environment or kept as a backup
+func ConfigExportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exported, err := yaml.Marshal(exportConfigFile(exportedSettings()))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: "Failed to export configuration: " + err.Error(),
+			})
+			return
+		}
+		header := fmt.Sprintf("# pipcal configuration exported %s; secrets are not included and must be set as env vars\n",
+			time.Now().UTC().Format(time.RFC3339))
+		c.Header("Content-Disposition", `attachment; filename="pipcal-config.yaml"`)
+		c.Data(http.StatusOK, "application/yaml", append([]byte(header), exported...))
+	}
+}
+
+// ConfigImportResult describes what an import changes
+type ConfigImportResult struct {
+	Path        string   `json:"path"`
+	DryRun      bool     `json:"dry_run"`
+	Added       []string `json:"added"`
+	Changed     []string `json:"changed"`
+	Removed     []string `json:"removed"`      // In the current config file but not the imported one
+	EnvOverride []string `json:"env_override"` // Imported but overridden by an env var
+	KeptSecrets []string `json:"kept_secrets"` // Secrets in the current config file carried over to the new one
+}
+
+// diffSettings compares the current settings and config file with imported ones, by env var name
+func diffSettings(current, file, imported map[string]string) ConfigImportResult {
+	result := ConfigImportResult{Added: []string{}, Changed: []string{}, Removed: []string{}, EnvOverride: []string{}}
+	for key, value := range imported {
+		old, ok := current[key]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, key)
+		case !sameSetting(old, value):
+			result.Changed = append(result.Changed, key)
+		}
+		if os.Getenv(key) != "" {
+			result.EnvOverride = append(result.EnvOverride, key)
+		}
+	}
+	for key := range file {
+		if _, ok := imported[key]; !ok {
+			result.Removed = append(result.Removed, key)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Changed)
+	sort.Strings(result.Removed)
+	sort.Strings(result.EnvOverride)
+	return result
+}
+
+// sameSetting compares two setting values, ignoring JSON formatting
+func sameSetting(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var decodedA, decodedB interface{}
+	if json.Unmarshal([]byte(a), &decodedA) != nil || json.Unmarshal([]byte(b), &decodedB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(decodedA, decodedB)
+}
+
+// writeConfigFile replaces the config file, keeping the previous one as <path>.bak
+func writeConfigFile(path string, raw []byte) error {
+	if previous, err := os.ReadFile(path); err == nil {
+		if err := os.WriteFile(path+".bak", previous, 0600); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", path, err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", path, err)
+	}
+	return nil
+}
+
+// keepFileSecrets carries the secrets set in the current config file, which exports never include,
+// over to an imported file under env, so an import doesn't drop e.g. tenant encryption keys
+func keepFileSecrets(raw []byte, file map[string]string) ([]byte, []string, error) {
+	var kept []string
+	for key := range file {
+		if secretSetting(key) {
+			kept = append(kept, key)
+		}
+	}
+	if len(kept) == 0 {
+		return raw, nil, nil
+	}
+	sort.Strings(kept)
+	document := make(map[string]interface{})
+	if err := yaml.Unmarshal(raw, &document); err != nil {
+		return nil, nil, err
+	}
+	env := make(map[string]interface{})
+	if existing, ok := document["env"].(map[string]interface{}); ok {
+		env = existing
+	}
+	for _, key := range kept {
+		env[key] = file[key]
+	}
+	document["env"] = env
+	merged, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merged, kept, nil
+}
+
+// ConfigImportHandler validates an exported config file and writes it as the config file, which
+// takes effect on the next restart; ?dry_run=true only reports what would change
+func ConfigImportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, err := io.ReadAll(io.LimitReader(c.Request.Body, maxConfigImportSize+1))
+		if err != nil || len(raw) > maxConfigImportSize {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Failed to read config file (max 1 MB)",
+			})
+			return
+		}
+		imported, err := parseConfigFile(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid config file: " + err.Error(),
+			})
+			return
+		}
+		var secrets []string
+		for key := range imported {
+			if secretSetting(key) {
+				secrets = append(secrets, key)
+			}
+		}
+		if len(secrets) > 0 {
+			sort.Strings(secrets)
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Secrets can't be imported, set them as env vars instead: " + strings.Join(secrets, ", "),
+			})
+			return
+		}
+
+		configFile.Lock()
+		path := configFile.path
+		file := make(map[string]string, len(configFile.values))
+		for key, value := range configFile.values {
+			file[key] = value
+		}
+		configFile.Unlock()
+		if path == "" {
+			path = os.Getenv("CONFIG_FILE")
+		}
+		if path == "" {
+			path = defaultConfigFile
+		}
+		merged, kept, err := keepFileSecrets(raw, file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid config file: " + err.Error(),
+			})
+			return
+		}
+		for _, key := range kept {
+			delete(file, key)
+		}
+		result := diffSettings(exportedSettings(), file, imported)
+		result.Path = path
+		result.KeptSecrets = append([]string{}, kept...)
+		result.DryRun = c.Query("dry_run") == "true"
+		if result.DryRun {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: fmt.Sprintf("Import would add %d, change %d and remove %d settings", len(result.Added), len(result.Changed), len(result.Removed)),
+				Data:    result,
+			})
+			return
+		}
+
+		if err := writeConfigFile(path, merged); err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		detail := fmt.Sprintf("%s: %d added, %d changed, %d removed", path, len(result.Added), len(result.Changed), len(result.Removed))
+		log.Printf("📄 Imported configuration to %s", detail)
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "config_imported", Detail: detail})
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Configuration written to " + path + "; restart the service to apply it",
+			Data:    result,
+		})
+	}
+}
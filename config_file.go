@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is loaded when CONFIG_FILE is not set and the file exists
+const defaultConfigFile = "config.yaml"
+
+// Configuration value sources
+const (
+	ConfigSourceEnv     = "env"
+	ConfigSourceFile    = "file"
+	ConfigSourceDefault = "default"
+)
+
+// FileConfig is the layout of the optional YAML configuration file
+type FileConfig struct {
+	Tenants       map[string]TenantFileConfig `yaml:"tenants"`
+	FieldMappings FieldMappingsFileConfig     `yaml:"field_mappings"`
+	Rules         RulesFileConfig             `yaml:"rules"`
+	Templates     TemplatesFileConfig         `yaml:"templates"`
+	Schedules     SchedulesFileConfig         `yaml:"schedules"`
+	Env           map[string]string           `yaml:"env"` // Any other setting, by env var name
+}
+
+// TenantFileConfig describes one tenant
+type TenantFileConfig struct {
+	Region        string   `yaml:"region"`
+	Agents        []string `yaml:"agents"`
+	EncryptionKey string   `yaml:"encryption_key"`
+}
+
+// FieldMappingsFileConfig holds the Pipedrive, Retell and Notion field mappings
+type FieldMappingsFileConfig struct {
+	DynamicVariables map[string]string `yaml:"dynamic_variables"`
+	LeadFields       map[string]string `yaml:"lead_fields"`
+	NotionProperties map[string]string `yaml:"notion_properties"`
+	CalendarIDs      map[string]string `yaml:"calendar_ids"`
+}
+
+// RulesFileConfig holds trigger toggles and rule lists (same shape as their JSON env vars)
+type RulesFileConfig struct {
+	Triggers    map[string]bool `yaml:"triggers"`
+	Dial        interface{}     `yaml:"dial"`
+	Scoring     interface{}     `yaml:"scoring"`
+	Experiments interface{}     `yaml:"experiments"`
+	Nurture     interface{}     `yaml:"nurture"`
+}
+
+// TemplatesFileConfig holds outbound message templates
+type TemplatesFileConfig struct {
+	FollowUp    string `yaml:"follow_up"`
+	ReminderSMS string `yaml:"reminder_sms"`
+}
+
+// SchedulesFileConfig holds reminder and call window schedules
+type SchedulesFileConfig struct {
+	Reminders struct {
+		Enabled     *bool  `yaml:"enabled"`
+		Channel     string `yaml:"channel"`
+		HoursBefore *int   `yaml:"hours_before"`
+		AgentID     string `yaml:"agent_id"`
+	} `yaml:"reminders"`
+	CallWindows struct {
+		TimeZone      string `yaml:"time_zone"`
+		Bias          *bool  `yaml:"bias"`
+		MaxDelayHours *int   `yaml:"max_delay_hours"`
+	} `yaml:"call_windows"`
+}
+
+// triggerEnvVars maps trigger names in the config file to their env vars
+var triggerEnvVars = map[string]string{
+	"auto_call":             "AUTOMATION_AUTO_CALL",
+	"call_started_activity": "AUTOMATION_CALL_STARTED_ACTIVITY",
+	"analysis_note":         "AUTOMATION_ANALYSIS_NOTE",
+	"optout_dnc":            "AUTOMATION_OPTOUT_DNC",
+	"cal_activity":          "AUTOMATION_CAL_ACTIVITY",
+}
+
+// Template placeholders
+var (
+	followUpPlaceholders    = []string{"name", "summary", "meeting_url"}
+	reminderSMSPlaceholders = []string{"name", "title", "time"}
+)
+
+// configFile holds the flattened config file values and where each setting came from
+var configFile = struct {
+	sync.Mutex
+	path    string
+	values  map[string]string
+	sources map[string]string
+}{values: make(map[string]string), sources: make(map[string]string)}
+
+// loadConfigFile reads the YAML config file, if any, so its values back the env var lookups
+func loadConfigFile() error {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err != nil {
+			return nil
+		}
+		path = defaultConfigFile
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := parseConfigFile(raw)
+	if err != nil {
+		return err
+	}
+
+	configFile.Lock()
+	configFile.path = path
+	configFile.values = values
+	configFile.Unlock()
+	log.Printf("📄 Loaded %d settings from %s (env vars take precedence)", len(values), path)
+	return nil
+}
+
+// parseConfigFile validates the YAML config and flattens it into env var values
+func parseConfigFile(raw []byte) (map[string]string, error) {
+	var file FileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for key, value := range file.Env {
+		values[strings.ToUpper(key)] = value
+	}
+
+	// Tenants
+	if len(file.Tenants) > 0 {
+		agentTenants := make(map[string]string)
+		regions := make(map[string]string)
+		keys := make(map[string]string)
+		for tenant, cfg := range file.Tenants {
+			for _, agentID := range cfg.Agents {
+				if other, ok := agentTenants[agentID]; ok {
+					return nil, fmt.Errorf("tenants: agent %s belongs to both %s and %s", agentID, other, tenant)
+				}
+				agentTenants[agentID] = tenant
+			}
+			if cfg.Region != "" {
+				regions[tenant] = strings.ToLower(cfg.Region)
+			}
+			if cfg.EncryptionKey != "" {
+				keys[tenant] = cfg.EncryptionKey
+			}
+		}
+		setJSONValue(values, "AGENT_TENANTS", agentTenants)
+		setJSONValue(values, "TENANT_REGIONS", regions)
+		setJSONValue(values, "TENANT_ENCRYPTION_KEYS", keys)
+	}
+
+	// Field mappings
+	setJSONValue(values, "DYNAMIC_VARIABLE_MAPPING", file.FieldMappings.DynamicVariables)
+	setJSONValue(values, "LEAD_FIELD_MAPPING", file.FieldMappings.LeadFields)
+	setJSONValue(values, "NOTION_PROPERTY_MAPPING", file.FieldMappings.NotionProperties)
+	setJSONValue(values, "GOOGLE_CALENDAR_IDS", file.FieldMappings.CalendarIDs)
+
+	// Rules, checked against the types their env vars are parsed into
+	for name, enabled := range file.Rules.Triggers {
+		key, ok := triggerEnvVars[name]
+		if !ok {
+			return nil, fmt.Errorf("rules.triggers: unknown trigger %q", name)
+		}
+		values[key] = strconv.FormatBool(enabled)
+	}
+	rules := []struct {
+		name   string
+		key    string
+		value  interface{}
+		target interface{}
+	}{
+		{"rules.dial", "DIAL_RULES", file.Rules.Dial, &[]DialRule{}},
+		{"rules.scoring", "LEAD_SCORING_RULES", file.Rules.Scoring, &[]ScoringRule{}},
+		{"rules.experiments", "AGENT_EXPERIMENTS", file.Rules.Experiments, &map[string][]ExperimentVariant{}},
+		{"rules.nurture", "NURTURE_RULES", file.Rules.Nurture, &[]NurtureRule{}},
+	}
+	for _, rule := range rules {
+		if rule.value == nil {
+			continue
+		}
+		encoded, err := json.Marshal(rule.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", rule.name, err)
+		}
+		if err := json.Unmarshal(encoded, rule.target); err != nil {
+			return nil, fmt.Errorf("%s: %v", rule.name, err)
+		}
+		values[rule.key] = string(encoded)
+	}
+
+	// Templates
+	if err := checkTemplate("templates.follow_up", file.Templates.FollowUp, followUpPlaceholders); err != nil {
+		return nil, err
+	}
+	if err := checkTemplate("templates.reminder_sms", file.Templates.ReminderSMS, reminderSMSPlaceholders); err != nil {
+		return nil, err
+	}
+	setValue(values, "FOLLOWUP_MESSAGE_TEMPLATE", file.Templates.FollowUp)
+	setValue(values, "REMINDER_SMS_TEMPLATE", file.Templates.ReminderSMS)
+
+	// Schedules
+	reminders := file.Schedules.Reminders
+	if reminders.Channel != "" && reminders.Channel != "sms" && reminders.Channel != "call" {
+		return nil, fmt.Errorf("schedules.reminders.channel: must be sms or call, got %q", reminders.Channel)
+	}
+	if reminders.HoursBefore != nil && *reminders.HoursBefore <= 0 {
+		return nil, fmt.Errorf("schedules.reminders.hours_before: must be positive")
+	}
+	if reminders.Enabled != nil {
+		values["REMINDERS_ENABLED"] = strconv.FormatBool(*reminders.Enabled)
+	}
+	setValue(values, "REMINDER_CHANNEL", reminders.Channel)
+	if reminders.HoursBefore != nil {
+		values["REMINDER_HOURS_BEFORE"] = strconv.Itoa(*reminders.HoursBefore)
+	}
+	setValue(values, "REMINDER_AGENT_ID", reminders.AgentID)
+
+	windows := file.Schedules.CallWindows
+	if windows.TimeZone != "" {
+		if _, err := time.LoadLocation(windows.TimeZone); err != nil {
+			return nil, fmt.Errorf("schedules.call_windows.time_zone: %v", err)
+		}
+	}
+	if windows.MaxDelayHours != nil && *windows.MaxDelayHours < 0 {
+		return nil, fmt.Errorf("schedules.call_windows.max_delay_hours: must not be negative")
+	}
+	setValue(values, "CALL_WINDOW_TIME_ZONE", windows.TimeZone)
+	if windows.Bias != nil {
+		values["CALL_WINDOW_BIAS"] = strconv.FormatBool(*windows.Bias)
+	}
+	if windows.MaxDelayHours != nil {
+		values["CALL_WINDOW_MAX_DELAY_HOURS"] = strconv.Itoa(*windows.MaxDelayHours)
+	}
+
+	return values, nil
+}
+
+// setValue sets a flattened value unless it is empty
+func setValue(values map[string]string, key, value string) {
+	if value != "" {
+		values[key] = value
+	}
+}
+
+// setJSONValue sets a flattened JSON map value unless it is empty
+func setJSONValue(values map[string]string, key string, mapping map[string]string) {
+	if len(mapping) == 0 {
+		return
+	}
+	encoded, _ := json.Marshal(mapping)
+	values[key] = string(encoded)
+}
+
+// checkTemplate rejects templates using placeholders that are never filled in
+func checkTemplate(name, template string, placeholders []string) error {
+	rest := template
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return fmt.Errorf("%s: unterminated placeholder", name)
+		}
+		placeholder := strings.TrimSpace(rest[start+2 : start+end])
+		known := false
+		for _, p := range placeholders {
+			known = known || p == placeholder
+		}
+		if !known {
+			return fmt.Errorf("%s: unknown placeholder {{%s}} (available: %s)", name, placeholder, strings.Join(placeholders, ", "))
+		}
+		rest = rest[start+end+2:]
+	}
+}
+
+// renderTemplate fills {{name}} placeholders in a message template
+func renderTemplate(template string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*4)
+	for name, value := range vars {
+		pairs = append(pairs, "{{"+name+"}}", value, "{{ "+name+" }}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// lookupEnv returns a setting from the environment, falling back to the config file
+func lookupEnv(key string) string {
+	configFile.Lock()
+	defer configFile.Unlock()
+	if value := os.Getenv(key); value != "" {
+		configFile.sources[key] = ConfigSourceEnv
+		return value
+	}
+	if value := configFile.values[key]; value != "" {
+		configFile.sources[key] = ConfigSourceFile
+		return value
+	}
+	configFile.sources[key] = ConfigSourceDefault
+	return ""
+}
+
+// redactedConfigFields are Config fields never shown in full
+var redactedConfigFields = []string{"apikey", "token", "secret", "password", "serviceaccount", "accountsid", "encryptionkeys", "adminusers", "webhookurl", "digestowners", "databaseurl", "redis", "credentials"}
+
+// redactConfig converts the config to JSON-like data with secrets masked
+func redactConfig(config *Config) map[string]interface{} {
+	view := make(map[string]interface{})
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if redactedName(field.Name) {
+			if fieldValue.IsZero() {
+				view[field.Name] = ""
+			} else {
+				view[field.Name] = "[redacted]"
+			}
+			continue
+		}
+		view[field.Name] = fieldValue.Interface()
+	}
+	return view
+}
+
+// ConfigViewHandler shows the effective configuration with secrets redacted and where each setting came from
+func ConfigViewHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		configFile.Lock()
+		sources := make(map[string]string, len(configFile.sources))
+		for key, source := range configFile.sources {
+			sources[key] = source
+		}
+		fileKeys := make([]string, 0, len(configFile.values))
+		for key := range configFile.values {
+			fileKeys = append(fileKeys, key)
+		}
+		path := configFile.path
+		configFile.Unlock()
+		sort.Strings(fileKeys)
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Effective configuration",
+			Data: gin.H{
+				"config_file": path,
+				"file_keys":   fileKeys,
+				"sources":     sources,
+				"config":      redactConfig(pipedriveService.config),
+			},
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Recording consent statuses
+const (
+	ConsentGiven    = "given"
+	ConsentDeclined = "declined"
+	ConsentUnknown  = "unknown"
+)
+
+// ConsentRecord is the recording consent outcome of a call
+type ConsentRecord struct {
+	Status     string    `json:"status"`
+	Announced  bool      `json:"announced"`
+	AtSeconds  float64   `json:"at_seconds,omitempty"`
+	Quote      string    `json:"quote,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// defaultAnnouncementMarkers identify the agent's recording announcement
+var defaultAnnouncementMarkers = []string{"recorded", "recording"}
+
+// defaultConsentDeclineMarkers identify a contact refusing to be recorded
+var defaultConsentDeclineMarkers = []string{"don't record", "do not record", "not okay", "no i don't", "i do not consent", "don't consent", "no thanks", "no"}
+
+// defaultConsentGiveMarkers identify a contact agreeing to be recorded
+var defaultConsentGiveMarkers = []string{"yes", "sure", "okay", "ok", "fine", "that's fine", "go ahead", "i consent", "no problem"}
+
+// requiresRecordingConsent returns true if calls to the number need a recording announcement
+func (c *Config) requiresRecordingConsent(phoneNumber string) bool {
+	if !c.RecordingConsentEnabled {
+		return false
+	}
+	if len(c.ConsentRequiredPrefixes) == 0 {
+		return true
+	}
+	normalized := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(phoneNumber)
+	if !strings.HasPrefix(normalized, "+") {
+		normalized = "+" + normalized
+	}
+	for _, prefix := range c.ConsentRequiredPrefixes {
+		if strings.HasPrefix(normalized, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectConsent finds the announcement in the transcript and the contact's reply to it
+func detectConsent(utterances []TranscriptUtterance) ConsentRecord {
+	record := ConsentRecord{Status: ConsentUnknown, RecordedAt: time.Now()}
+
+	for i, utterance := range utterances {
+		if utterance.Role != "agent" || !containsAny(strings.ToLower(utterance.Content), defaultAnnouncementMarkers) {
+			continue
+		}
+		record.Announced = true
+
+		// The next contact turn is the answer to the announcement
+		for _, reply := range utterances[i+1:] {
+			if reply.Role != "user" {
+				continue
+			}
+			record.Status = classifyConsentReply(reply.Content)
+			record.Quote = reply.Content
+			if len(reply.Words) > 0 {
+				record.AtSeconds = reply.Words[0].Start
+			}
+			return record
+		}
+		return record
+	}
+	return record
+}
+
+// containsAny returns true if text contains any of the phrases
+func containsAny(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyConsentReply matches each clause of the reply exactly against the markers, declines first,
+// so "no problem" is not read as "no" and "I'm not sure" is not read as "sure"
+func classifyConsentReply(content string) string {
+	clauses := consentClauses(content)
+	for _, markers := range []struct {
+		status string
+		list   []string
+	}{{ConsentDeclined, defaultConsentDeclineMarkers}, {ConsentGiven, defaultConsentGiveMarkers}} {
+		for _, clause := range clauses {
+			for _, marker := range markers.list {
+				if clause == marker {
+					return markers.status
+				}
+			}
+		}
+	}
+	return ConsentUnknown
+}
+
+// consentClauses splits a reply at punctuation into lowercase clauses of single-spaced words
+func consentClauses(content string) []string {
+	var clauses []string
+	for _, part := range strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return strings.ContainsRune(",.!?;:", r)
+	}) {
+		words := strings.FieldsFunc(part, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z') && r != '\''
+		})
+		if len(words) > 0 {
+			clauses = append(clauses, strings.Join(words, " "))
+		}
+	}
+	return clauses
+}
+
+// recordConsent logs the consent moment for a call, stores it on the call record and optionally on the person
+func (p *PipedriveService) recordConsent(callID string, mapping CallMapping, utterances []TranscriptUtterance) *ConsentRecord {
+	record := detectConsent(utterances)
+	p.updateCallMapping(callID, func(m *CallMapping) { m.Consent = &record })
+
+	if record.Status == ConsentUnknown {
+		p.logf("⚠️ [CONSENT] Call %s: no recording consent detected (announced: %t)", callID, record.Announced)
+	} else {
+		p.logf("📝 [CONSENT] Call %s: consent %s at %.1fs (%q)", callID, record.Status, record.AtSeconds, record.Quote)
+	}
+
+	if p.config.ConsentField != "" && p.config.HasPipedriveConfig() {
+		resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", mapping.PersonID), map[string]interface{}{
+			p.config.ConsentField: record.Status,
+		})
+		if err != nil {
+			p.logf("⚠️ Warning: Failed to write consent status to person %d: %v", mapping.PersonID, err)
+		} else {
+			resp.Body.Close()
+		}
+	}
+	return &record
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Outbound channels checked by the contact gate
+const (
+	ContactCall     = "call"
+	ContactSMS      = "sms"
+	ContactWhatsApp = "whatsapp"
+)
+
+// parseContactHours parses CONTACT_HOURS ("08:00-21:00") into a window in CALL_WINDOW_TIME_ZONE;
+// empty allows contact at any time
+func parseContactHours(raw string) *CampaignWindow {
+	if raw == "" {
+		return nil
+	}
+	parts := strings.SplitN(raw, "-", 2)
+	if len(parts) != 2 {
+		log.Printf("⚠️ Invalid CONTACT_HOURS %q, expected HH:MM-HH:MM; ignoring", raw)
+		return nil
+	}
+	window := &CampaignWindow{Start: strings.TrimSpace(parts[0]), End: strings.TrimSpace(parts[1])}
+	start, errStart := time.Parse("15:04", window.Start)
+	end, errEnd := time.Parse("15:04", window.End)
+	if errStart != nil || errEnd != nil || !end.After(start) {
+		log.Printf("⚠️ Invalid CONTACT_HOURS %q, expected HH:MM-HH:MM with end after start; ignoring", raw)
+		return nil
+	}
+	return window
+}
+
+// contactGate runs the checks every automated call and message must pass, whatever scheduled it:
+// holds and snoozes, the DNC field, skip dial rules, texts to landlines and CONTACT_HOURS. It
+// returns why the contact is blocked, or the time to retry when it is outside contact hours.
+func (p *PipedriveService) contactGate(person *PipedrivePerson, phoneNumber, channel, action string, now time.Time) (string, time.Time) {
+	if p.isSnoozed(person.ID, action) {
+		return "person is snoozed or held", time.Time{}
+	}
+	if p.isMarkedDNC(person) {
+		p.logf("⛔ Person %d is marked Do Not Call, skipping %s", person.ID, action)
+		return "person is marked Do Not Call", time.Time{}
+	}
+	number := p.config.dialRuleNumber(phoneNumber, p.featureTenant())
+	for _, rule := range p.config.DialRules {
+		if rule.Action == DialRuleSkip && rule.matches(number, nil) {
+			p.logf("⛔ Dial rule %s blocks %s to %s", rule.Name, action, phoneNumber)
+			return "blocked by dial rule " + rule.Name, time.Time{}
+		}
+	}
+	if channel == ContactSMS && p.leadLineType(person, phoneNumber, nil) == LineTypeLandline {
+		p.logf("⚠️ %s is a landline, skipping %s", phoneNumber, action)
+		return "number is a landline", time.Time{}
+	}
+
+	if window := p.config.ContactHours; window != nil {
+		location, err := time.LoadLocation(window.zone(p.config))
+		if err != nil {
+			location = time.UTC
+		}
+		if at := window.nextOpen(now, location); at.After(now) {
+			p.logf("🕐 Outside contact hours for %s to person %d, next opening %s", action, person.ID, at.Format(time.RFC3339))
+			return "", at
+		}
+	}
+	return "", time.Time{}
+}
+
+// consentAgent switches calls to numbers that need a recording announcement to CONSENT_AGENT_ID
+// and tells the agent to announce it; it returns the agent to call with and whether consent applies
+func (p *PipedriveService) consentAgent(phoneNumber, agentID string, variables map[string]interface{}) (string, bool) {
+	if !p.config.requiresRecordingConsent(phoneNumber) {
+		return agentID, false
+	}
+	if p.config.ConsentAgentID != "" {
+		agentID = p.config.ConsentAgentID
+	}
+	variables["recording_disclosure"] = "true"
+	p.logf("📢 Recording consent required for %s, using agent %s", phoneNumber, agentID)
+	return agentID, true
+}
+
+// smsText adds SMS_OPT_OUT_TEXT to an automated text that doesn't already say how to opt out
+func (p *PipedriveService) smsText(message string) string {
+	if p.config.SMSOptOutText == "" || strings.Contains(strings.ToUpper(message), "STOP") {
+		return message
+	}
+	return fmt.Sprintf("%s %s", strings.TrimSpace(message), p.config.SMSOptOutText)
+}
@@ -0,0 +1,55 @@
+package main
+
+// Behavior when a person has several matching open deals
+const (
+	DealLinkSkip   = "skip"   // Leave the activity linked to the person only
+	DealLinkLatest = "latest" // Link the most recently updated deal
+)
+
+// dealForPerson picks the open deal a person's activities belong to, or 0 if there is no clear match
+func (p *PipedriveService) dealForPerson(personID int) int {
+	deals, err := p.ListPersonDeals(personID)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to load deals of person %d for activity linking: %v", personID, err)
+		return 0
+	}
+
+	var open []PipedriveDeal
+	for _, deal := range deals {
+		if deal.Status != "open" {
+			continue
+		}
+		if p.config.DealLinkPipelineID != 0 && deal.PipelineID != p.config.DealLinkPipelineID {
+			continue
+		}
+		open = append(open, deal)
+	}
+
+	switch {
+	case len(open) == 0:
+		return 0
+	case len(open) == 1:
+		return open[0].ID
+	case p.config.DealLinkMultiple == DealLinkLatest:
+		latest := open[0]
+		for _, deal := range open[1:] {
+			// Pipedrive timestamps ("2006-01-02 15:04:05") sort lexically
+			if deal.UpdateTime > latest.UpdateTime {
+				latest = deal
+			}
+		}
+		return latest.ID
+	}
+	p.debugf(SubsystemPipedrive, "Person %d has %d open deals, not linking activity", personID, len(open))
+	return 0
+}
+
+// linkActivityToDeal sets deal_id on activity data when the person has a matching open deal
+func (p *PipedriveService) linkActivityToDeal(activityData map[string]interface{}, personID int) {
+	if !p.config.DealLinkingEnabled || personID == 0 {
+		return
+	}
+	if dealID := p.dealForPerson(personID); dealID != 0 {
+		activityData["deal_id"] = dealID
+	}
+}
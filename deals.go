@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DealInput describes a deal to create
+type DealInput struct {
+	Title      string
+	PersonID   int // Contact person, set in the same request so the deal is found from the person at once
+	PipelineID int // 0 for the account's default pipeline
+	StageID    int // 0 for the pipeline's first stage
+	OwnerID    int // 0 for the API token's user
+}
+
+// DealService creates deals in Pipedrive and moves them through the pipeline
+type DealService struct {
+	config    *Config
+	pipedrive *PipedriveService
+}
+
+// newDealService returns the deal service, or nil if deal creation is disabled
+func newDealService(config *Config, pipedrive *PipedriveService) *DealService {
+	if !config.DealCreationEnabled {
+		return nil
+	}
+	return &DealService{config: config, pipedrive: pipedrive}
+}
+
+// CreateDeal creates an open deal
+func (d *DealService) CreateDeal(input DealInput) (*PipedriveDeal, error) {
+	dealData := map[string]interface{}{
+		"title":  input.Title,
+		"status": "open",
+	}
+	if input.PersonID != 0 {
+		dealData["person_id"] = input.PersonID
+	}
+	if input.PipelineID != 0 {
+		dealData["pipeline_id"] = input.PipelineID
+	}
+	if input.StageID != 0 {
+		dealData["stage_id"] = input.StageID
+	}
+	if input.OwnerID != 0 {
+		dealData["user_id"] = input.OwnerID
+	}
+
+	resp, err := d.pipedrive.makePipedriveRequest("POST", "/deals", dealData)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deal response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("failed to create deal %q: HTTP %d, Response: %s", input.Title, resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Success bool           `json:"success"`
+		Data    *PipedriveDeal `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse deal response: %v", err)
+	}
+	if !result.Success || result.Data == nil {
+		return nil, fmt.Errorf("failed to create deal %q", input.Title)
+	}
+	return result.Data, nil
+}
+
+// UpdateDealStage moves a deal to a stage, and with it to the stage's pipeline
+func (d *DealService) UpdateDealStage(dealID, stageID int) error {
+	return d.pipedrive.updateDeal(dealID, map[string]interface{}{"stage_id": stageID})
+}
+
+// openPipelineDeal returns the person's open deal in DEAL_PIPELINE_ID (any pipeline when unset), or nil
+func (d *DealService) openPipelineDeal(personID int) (*PipedriveDeal, error) {
+	deals, err := d.pipedrive.ListPersonDeals(personID)
+	if err != nil {
+		return nil, err
+	}
+	for i, deal := range deals {
+		if deal.Status == "open" && (d.config.DealPipelineID == 0 || deal.PipelineID == d.config.DealPipelineID) {
+			return &deals[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// positiveCall reports whether an analyzed call was successful with positive user sentiment
+func positiveCall(payload RetellCallAnalyzedPayload) bool {
+	return payload.Call.CallAnalysis.CallSuccessful && strings.EqualFold(payload.Call.CallAnalysis.UserSentiment, "positive")
+}
+
+// createDealFromCall opens a deal for a successful, positive call, linked to the person and with the
+// call summary attached as a note on the deal, person and lead. A person who already has an open deal
+// in the pipeline gets the summary on that deal instead, moved to DEAL_STAGE_ID with
+// DEAL_ADVANCE_EXISTING. It runs under the call's analyzed: claim, so a redelivered webhook
+// waits for the deal to be recorded on the mapping rather than creating another.
+func (p *PipedriveService) createDealFromCall(payload RetellCallAnalyzedPayload, mapping CallMapping) {
+	if p.deals == nil || !positiveCall(payload) || mapping.PersonID == 0 {
+		return
+	}
+	callID := payload.Call.CallID
+	if mapping.DealID != 0 {
+		p.debugf(SubsystemPipedrive, "Deal %d already created for call %s", mapping.DealID, callID)
+		return
+	}
+
+	existing, err := p.deals.openPipelineDeal(mapping.PersonID)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to load deals of person %d, not creating a deal for call %s: %v", mapping.PersonID, callID, err)
+		return
+	}
+
+	dealID, detail := 0, ""
+	if existing != nil {
+		dealID, detail = existing.ID, fmt.Sprintf("summary attached to open deal %d", existing.ID)
+		if p.config.DealAdvanceExisting && p.config.DealStageID != 0 && existing.StageID != p.config.DealStageID {
+			if err := p.deals.UpdateDealStage(existing.ID, p.config.DealStageID); err != nil {
+				p.logf("⚠️ Warning: Failed to move deal %d to stage %d: %v", existing.ID, p.config.DealStageID, err)
+			} else {
+				detail = fmt.Sprintf("open deal %d moved to stage %d", existing.ID, p.config.DealStageID)
+				p.logf("✅ Moved deal %d of person %d to stage %d after call %s", existing.ID, mapping.PersonID, p.config.DealStageID, callID)
+			}
+		}
+	} else {
+		title := mapping.LeadTitle
+		if title == "" {
+			title = mapping.PersonName
+		}
+		deal, err := p.deals.CreateDeal(DealInput{
+			Title:      title,
+			PersonID:   mapping.PersonID,
+			PipelineID: p.config.DealPipelineID,
+			StageID:    p.config.DealStageID,
+			OwnerID:    mapping.OwnerID,
+		})
+		if err != nil {
+			p.logf("⚠️ Warning: Failed to create a deal for call %s: %v", callID, err)
+			return
+		}
+		dealID, detail = deal.ID, fmt.Sprintf("created deal %d", deal.ID)
+		p.logf("✅ Created deal %d for person %d after call %s", deal.ID, mapping.PersonID, callID)
+	}
+	p.updateCallMapping(callID, func(m *CallMapping) { m.DealID = dealID })
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "deal_from_call", PersonID: mapping.PersonID, CallID: callID, Detail: detail})
+
+	noteData := map[string]interface{}{
+		"content":   fmt.Sprintf("AI call summary\nCall ID: %s\nSentiment: %s\n\n%s", callID, payload.Call.CallAnalysis.UserSentiment, payload.Call.CallAnalysis.CallSummary),
+		"deal_id":   dealID,
+		"person_id": mapping.PersonID,
+	}
+	if mapping.LeadID != "" {
+		noteData["lead_id"] = mapping.LeadID
+	}
+	resp, err := p.makePipedriveRequest("POST", "/notes", noteData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to attach the call summary to deal %d: %v", dealID, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		p.logf("⚠️ Warning: Failed to attach the call summary to deal %d: HTTP %d, Response: %s", dealID, resp.StatusCode, string(body))
+	}
+}
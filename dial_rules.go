@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Dial rule actions
+const (
+	DialRuleSkip         = "skip"
+	DialRuleDeprioritize = "deprioritize"
+)
+
+// defaultDialRuleDelay is how long deprioritized calls wait when a rule sets no delay
+const defaultDialRuleDelay = 4 * time.Hour
+
+// DialRule skips or deprioritizes calls to numbers matching a prefix or carrier
+type DialRule struct {
+	Name    string `json:"name"`
+	Prefix  string `json:"prefix,omitempty"`  // E.164 prefix, e.g. "+1212"
+	Carrier string `json:"carrier,omitempty"` // Case-insensitive substring of the looked-up carrier
+	Action  string `json:"action"`            // "skip" or "deprioritize"
+	Delay   string `json:"delay,omitempty"`   // How long deprioritized calls wait, e.g. "4h"
+
+	delay time.Duration
+}
+
+// DialRuleStats counts the effect of a dial rule on one campaign
+type DialRuleStats struct {
+	Campaign       string `json:"campaign"`
+	Rule           string `json:"rule"`
+	Action         string `json:"action"`
+	Skipped        int    `json:"skipped"`
+	Deprioritized  int    `json:"deprioritized"`
+	DeferredDialed int    `json:"deferred_dialed"` // Deprioritized calls that were eventually placed
+}
+
+// loadDialRules parses the DIAL_RULES JSON list
+func loadDialRules(raw string) []DialRule {
+	if raw == "" {
+		return nil
+	}
+	var rules []DialRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Printf("⚠️ Invalid DIAL_RULES, ignoring: %v", err)
+		return nil
+	}
+
+	valid := make([]DialRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.Action = strings.ToLower(rule.Action)
+		if rule.Action != DialRuleSkip && rule.Action != DialRuleDeprioritize {
+			log.Printf("⚠️ Ignoring dial rule %d: action must be skip or deprioritize", i)
+			continue
+		}
+		rule.Prefix = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(" -./()", r) {
+				return -1
+			}
+			return r
+		}, rule.Prefix)
+		if rule.Prefix == "" && rule.Carrier == "" {
+			log.Printf("⚠️ Ignoring dial rule %d: prefix or carrier is required", i)
+			continue
+		}
+		rule.delay = defaultDialRuleDelay
+		if rule.Delay != "" {
+			delay, err := time.ParseDuration(rule.Delay)
+			if err != nil || delay <= 0 {
+				log.Printf("⚠️ Dial rule %d has invalid delay %q, using %s", i, rule.Delay, defaultDialRuleDelay)
+			} else {
+				rule.delay = delay
+			}
+		}
+		if rule.Name == "" {
+			rule.Name = strings.TrimSpace(rule.Prefix + " " + rule.Carrier)
+		}
+		valid = append(valid, rule)
+	}
+	return valid
+}
+
+// hasCarrierDialRules returns true if any dial rule needs the number's carrier
+func (c *Config) hasCarrierDialRules() bool {
+	for _, rule := range c.DialRules {
+		if rule.Carrier != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// dialRuleNumber returns a number in E.164 form for prefix matching, assuming the tenant's
+// calling code for national numbers; numbers that cannot be normalized are matched as given
+func (c *Config) dialRuleNumber(phoneNumber, tenant string) string {
+	if normalized, reason := normalizeE164(phoneNumber, c.PhoneCountryCodeFor(tenant)); reason == "" {
+		return normalized
+	}
+	return phoneNumber
+}
+
+// matches returns true if the rule applies to a number (see dialRuleNumber) and its lookup result
+func (r DialRule) matches(phoneNumber string, lookup *PhoneLookupResult) bool {
+	if r.Prefix != "" && !strings.HasPrefix(phoneNumber, r.Prefix) {
+		return false
+	}
+	if r.Carrier != "" {
+		if lookup == nil || !strings.Contains(strings.ToLower(lookup.Carrier), strings.ToLower(r.Carrier)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchDialRule returns the first dial rule matching a lead's number and records its effect; the
+// redial of a deprioritized call was already counted
+func (p *PipedriveService) matchDialRule(payload PipedriveLeadWebhookPayload, phoneNumber string, lookup *PhoneLookupResult) *DialRule {
+	number := p.config.dialRuleNumber(phoneNumber, p.leadTenant(payload))
+	for i := range p.config.DialRules {
+		rule := &p.config.DialRules[i]
+		if !rule.matches(number, lookup) {
+			continue
+		}
+		if !payload.Deprioritized {
+			p.dialRules.Record(p.config.leadCampaign(payload), *rule)
+		}
+		return rule
+	}
+	return nil
+}
+
+// deferLeadCall holds a deprioritized lead's call until the rule's delay has passed
+func (p *PipedriveService) deferLeadCall(payload PipedriveLeadWebhookPayload, phoneNumber string, rule *DialRule) {
+	p.logf("⏳ Deprioritizing call to %s for lead %s by %s (dial rule %s)", phoneNumber, payload.Data.ID, rule.delay, rule.Name)
+	payload.Deprioritized = true
+	p.holdLeadCall(&payload, QueueReasonDialRule, time.Now().Add(rule.delay))
+}
+
+// DialRuleTracker counts calls affected by dial rules per campaign
+type DialRuleTracker struct {
+	mu    sync.Mutex
+	stats map[string]*DialRuleStats // Keyed by campaign/rule
+}
+
+// NewDialRuleTracker creates a new dial rule tracker
+func NewDialRuleTracker() *DialRuleTracker {
+	return &DialRuleTracker{stats: make(map[string]*DialRuleStats)}
+}
+
+// statsLocked returns the stats entry for a campaign and rule; callers must hold mu
+func (t *DialRuleTracker) statsLocked(campaign string, rule DialRule) *DialRuleStats {
+	key := campaign + "/" + rule.Name
+	stats, ok := t.stats[key]
+	if !ok {
+		stats = &DialRuleStats{Campaign: campaign, Rule: rule.Name, Action: rule.Action}
+		t.stats[key] = stats
+	}
+	return stats
+}
+
+// Record counts a call skipped or deprioritized by a rule
+func (t *DialRuleTracker) Record(campaign string, rule DialRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.statsLocked(campaign, rule)
+	if rule.Action == DialRuleSkip {
+		stats.Skipped++
+	} else {
+		stats.Deprioritized++
+	}
+}
+
+// RecordDeferredDial counts a deprioritized call that was placed after its delay
+func (t *DialRuleTracker) RecordDeferredDial(campaign string, rule DialRule) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.statsLocked(campaign, rule).DeferredDialed++
+}
+
+// Report returns dial rule stats sorted by campaign and rule
+func (t *DialRuleTracker) Report() []DialRuleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := make([]DialRuleStats, 0, len(t.stats))
+	for _, stats := range t.stats {
+		report = append(report, *stats)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Campaign != report[j].Campaign {
+			return report[i].Campaign < report[j].Campaign
+		}
+		return report[i].Rule < report[j].Rule
+	})
+	return report
+}
+
+// DialRuleReportHandler reports calls skipped or deprioritized by dial rules per campaign
+func DialRuleReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := pipedriveService.dialRules.Report()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d dial rule entries", len(report)),
+			Data:    report,
+		})
+	}
+}
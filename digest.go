@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDigestHighlights is how many successful calls are quoted in a digest
+const maxDigestHighlights = 5
+
+// DigestRecipient is where an owner's weekly digest goes; with neither set it is emailed to the
+// owner's Pipedrive address
+type DigestRecipient struct {
+	Email           string `json:"email"`
+	SlackWebhookURL string `json:"slack_webhook_url"`
+}
+
+// OwnerDigest summarizes a week of AI calls to one owner's leads
+type OwnerDigest struct {
+	OwnerID        int       `json:"owner_id"`
+	OwnerName      string    `json:"owner_name,omitempty"`
+	Tenant         string    `json:"tenant,omitempty"` // Tenant of the owner's latest call, for the date format
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	CallsPlaced    int       `json:"calls_placed"`
+	Connected      int       `json:"connected"`
+	Successful     int       `json:"successful"`
+	Voicemail      int       `json:"voicemail"`
+	OptOuts        int       `json:"opt_outs"`
+	Failed         int       `json:"failed"`
+	MeetingsBooked int       `json:"meetings_booked"`
+	Highlights     []string  `json:"highlights,omitempty"` // Successful calls with their summary
+}
+
+// DigestDelivery is the outcome of sending one owner's digest
+type DigestDelivery struct {
+	OwnerID int    `json:"owner_id"`
+	Channel string `json:"channel"` // email or slack
+	To      string `json:"to,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// loadDigestOwners parses DIGEST_OWNERS (JSON: Pipedrive user ID -> recipient)
+func loadDigestOwners(raw string) map[string]DigestRecipient {
+	owners := make(map[string]DigestRecipient)
+	if raw == "" {
+		return owners
+	}
+	if err := json.Unmarshal([]byte(raw), &owners); err != nil {
+		log.Printf("⚠️ Invalid DIGEST_OWNERS, ignoring: %v", err)
+		return make(map[string]DigestRecipient)
+	}
+	return owners
+}
+
+// BuildOwnerDigests summarizes calls placed in [from, to) per owner from the shared call mappings
+func (p *PipedriveService) BuildOwnerDigests(from, to time.Time) map[int]*OwnerDigest {
+	mappings := p.listCallMappings()
+	callIDs := make([]string, 0, len(mappings))
+	for callID := range mappings {
+		callIDs = append(callIDs, callID)
+	}
+	// Highlights are picked in call order
+	sort.Slice(callIDs, func(i, j int) bool { return mappings[callIDs[i]].Timestamp.Before(mappings[callIDs[j]].Timestamp) })
+
+	digests := make(map[int]*OwnerDigest)
+	for _, callID := range callIDs {
+		mapping := mappings[callID]
+		if mapping.OwnerID == 0 || mapping.Timestamp.Before(from) || !mapping.Timestamp.Before(to) {
+			continue
+		}
+		digest, ok := digests[mapping.OwnerID]
+		if !ok {
+			digest = &OwnerDigest{OwnerID: mapping.OwnerID, From: from, To: to}
+			digests[mapping.OwnerID] = digest
+		}
+
+		digest.CallsPlaced++
+		if mapping.Tenant != "" {
+			digest.Tenant = mapping.Tenant
+		}
+		if strings.HasPrefix(callID, "failed-") {
+			digest.Failed++
+			continue
+		}
+		if mapping.Answered {
+			digest.Connected++
+		}
+		if mapping.OptedOut {
+			digest.OptOuts++
+		}
+		switch mapping.Outcome {
+		case CallOutcomeVoicemail:
+			digest.Voicemail++
+		case CallOutcomeSuccessful:
+			digest.Successful++
+			if len(digest.Highlights) < maxDigestHighlights {
+				digest.Highlights = append(digest.Highlights, fmt.Sprintf("%s (%s): %s", mapping.PersonName, mapping.LeadTitle, mapping.Summary))
+			}
+		}
+	}
+
+	for ownerID, digest := range digests {
+		if meetings, err := p.countMeetingsBooked(ownerID, from, to); err != nil {
+			p.logf("⚠️ Warning: Could not count meetings booked for owner %d: %v", ownerID, err)
+		} else {
+			digest.MeetingsBooked = meetings
+		}
+	}
+	return digests
+}
+
+// countMeetingsBooked counts Cal.com meeting activities added for the owner in [from, to)
+func (p *PipedriveService) countMeetingsBooked(ownerID int, from, to time.Time) (int, error) {
+	if !p.config.HasPipedriveConfig() {
+		return 0, nil
+	}
+	// Meetings are due after they were booked, so look ahead of the window
+	items, err := p.listPipedriveItems(fmt.Sprintf("/activities?user_id=%d&type=meeting&start_date=%s&end_date=%s",
+		ownerID, from.Format("2006-01-02"), to.AddDate(0, 3, 0).Format("2006-01-02")))
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, item := range items {
+		var activity PipedriveActivity
+		if err := json.Unmarshal(item, &activity); err != nil || !strings.HasPrefix(activity.Subject, "Cal.com:") {
+			continue
+		}
+		added, err := parsePipedriveTime(activity.AddTime)
+		if err == nil && !added.Before(from) && added.Before(to) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// pipedriveUser returns a Pipedrive user's name and email
+func (p *PipedriveService) pipedriveUser(userID int) (string, string, error) {
+	resp, err := p.makePipedriveRequest("GET", fmt.Sprintf("/users/%d", userID), nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read user response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return "", "", fmt.Errorf("user lookup failed: HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Data struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", "", fmt.Errorf("failed to parse user response: %v", err)
+	}
+	return result.Data.Name, result.Data.Email, nil
+}
+
+// Text renders the digest for email and Slack, with dates in the locale's format
+func (d OwnerDigest) Text(location *time.Location, locale Locale) string {
+	var text strings.Builder
+	name := d.OwnerName
+	if name == "" {
+		name = "owner " + strconv.Itoa(d.OwnerID)
+	}
+	fmt.Fprintf(&text, "Weekly AI calling digest for %s\n%s – %s\n\n", name,
+		locale.Date(d.From.In(location)), locale.Date(d.To.Add(-time.Second).In(location)))
+	fmt.Fprintf(&text, "Calls placed to your leads: %d\n", d.CallsPlaced)
+	fmt.Fprintf(&text, "Connected: %d\n", d.Connected)
+	fmt.Fprintf(&text, "Successful: %d\n", d.Successful)
+	fmt.Fprintf(&text, "Voicemail: %d\n", d.Voicemail)
+	fmt.Fprintf(&text, "Opt-outs: %d\n", d.OptOuts)
+	fmt.Fprintf(&text, "Failed to dial: %d\n", d.Failed)
+	fmt.Fprintf(&text, "Meetings booked: %d\n", d.MeetingsBooked)
+	if len(d.Highlights) > 0 {
+		text.WriteString("\nHighlights:\n")
+		for _, highlight := range d.Highlights {
+			text.WriteString("- " + highlight + "\n")
+		}
+	}
+	return text.String()
+}
+
+// digestWindow returns the seven days before the start of the given date
+func (p *PipedriveService) digestWindow(date time.Time) (time.Time, time.Time) {
+	local := date.In(p.kpis.location)
+	to := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, p.kpis.location)
+	return to.AddDate(0, 0, -7), to
+}
+
+// SendWeeklyDigests delivers the digests for [from, to) to every opted-in owner
+func (p *PipedriveService) SendWeeklyDigests(from, to time.Time) []DigestDelivery {
+	digests := p.BuildOwnerDigests(from, to)
+	var deliveries []DigestDelivery
+	for key, recipient := range p.config.DigestOwners {
+		ownerID, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		digest, ok := digests[ownerID]
+		if !ok {
+			digest = &OwnerDigest{OwnerID: ownerID, From: from, To: to}
+		}
+		name, email, err := p.pipedriveUser(ownerID)
+		if err != nil {
+			p.logf("⚠️ Warning: Could not look up owner %d for the weekly digest: %v", ownerID, err)
+		}
+		digest.OwnerName = name
+		text := digest.Text(p.kpis.location, p.config.LocaleFor(digest.Tenant))
+
+		if recipient.SlackWebhookURL != "" {
+			delivery := DigestDelivery{OwnerID: ownerID, Channel: "slack"}
+			if err := p.postSlackMessage(recipient.SlackWebhookURL, text); err != nil {
+				delivery.Error = err.Error()
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		if recipient.Email != "" || recipient.SlackWebhookURL == "" {
+			address := recipient.Email
+			if address == "" {
+				address = email
+			}
+			delivery := DigestDelivery{OwnerID: ownerID, Channel: "email", To: address}
+			switch {
+			case p.mailer == nil:
+				delivery.Error = "email not configured (SMTP_HOST)"
+			case address == "":
+				delivery.Error = "no email address for owner"
+			default:
+				if err := p.mailer.Send([]string{address}, "Your weekly AI calling digest", text); err != nil {
+					delivery.Error = err.Error()
+				}
+			}
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].OwnerID < deliveries[j].OwnerID })
+	for _, delivery := range deliveries {
+		if delivery.Error != "" {
+			p.logf("❌ Failed to send weekly digest to owner %d by %s: %s", delivery.OwnerID, delivery.Channel, delivery.Error)
+		} else {
+			p.logf("📬 Sent weekly digest to owner %d by %s", delivery.OwnerID, delivery.Channel)
+		}
+	}
+	return deliveries
+}
+
+// postSlackMessage posts text to a Slack incoming webhook
+func (p *PipedriveService) postSlackMessage(webhookURL, text string) error {
+	body, _ := json.Marshal(map[string]string{"text": text})
+	resp, err := p.httpClient.Post(webhookURL, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("failed to post to Slack: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Slack webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runWeeklyDigest sends the digests every DIGEST_WEEKDAY at DIGEST_HOUR
+func (p *PipedriveService) runWeeklyDigest() {
+	runDaily(p.config.DigestHour, p.kpis.location, func(date string) {
+		now := time.Now().In(p.kpis.location)
+		if !strings.EqualFold(now.Weekday().String(), p.config.DigestWeekday) {
+			return
+		}
+		if _, ok := p.claim("digest:" + date); !ok {
+			return
+		}
+		p.SendWeeklyDigests(p.digestWindow(now))
+	})
+}
+
+// OwnerDigestReportHandler previews the weekly digests (?owner_id= for one owner)
+func OwnerDigestReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to := pipedriveService.digestWindow(time.Now())
+		digests := pipedriveService.BuildOwnerDigests(from, to)
+		ownerID, _ := strconv.Atoi(c.Query("owner_id"))
+		report := make([]*OwnerDigest, 0, len(digests))
+		for id, digest := range digests {
+			if ownerID == 0 || id == ownerID {
+				report = append(report, digest)
+			}
+		}
+		sort.Slice(report, func(i, j int) bool { return report[i].OwnerID < report[j].OwnerID })
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Digests for %d owners", len(report)),
+			Data:    report,
+		})
+	}
+}
+
+// SendDigestsHandler sends the weekly digests now
+func SendDigestsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deliveries := pipedriveService.SendWeeklyDigests(pipedriveService.digestWindow(time.Now()))
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d digest deliveries", len(deliveries)),
+			Data:    deliveries,
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a call length as sent by webhooks in "HH:MM:SS", "MM:SS" or whole seconds
+type Duration time.Duration
+
+// DurationError reports a duration in a format we can't read
+type DurationError struct {
+	Value string
+}
+
+func (e *DurationError) Error() string {
+	return fmt.Sprintf("invalid duration %q: expected HH:MM:SS, MM:SS or seconds", e.Value)
+}
+
+// DurationFromMillis converts a millisecond count (Retell's duration_ms) to a Duration
+func DurationFromMillis(ms int64) Duration {
+	return Duration(time.Duration(ms) * time.Millisecond)
+}
+
+// DurationFromSeconds converts a second count to a Duration
+func DurationFromSeconds(seconds int64) Duration {
+	return Duration(time.Duration(seconds) * time.Second)
+}
+
+// ParseDuration reads "HH:MM:SS", "MM:SS" or a non-negative number of seconds
+func ParseDuration(value string) (Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if !strings.Contains(value, ":") {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			return 0, &DurationError{Value: value}
+		}
+		return Duration(time.Duration(seconds * float64(time.Second))), nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, &DurationError{Value: value}
+	}
+	var total int64
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 || (i > 0 && n >= 60) {
+			return 0, &DurationError{Value: value}
+		}
+		total = total*60 + n
+	}
+	return DurationFromSeconds(total), nil
+}
+
+// Seconds returns the duration in whole seconds
+func (d Duration) Seconds() int {
+	return int(time.Duration(d) / time.Second)
+}
+
+// String formats the duration as "HH:MM:SS" for notes
+func (d Duration) String() string {
+	seconds := d.Seconds()
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, (seconds%3600)/60, seconds%60)
+}
+
+// ActivityDuration formats the duration as the "HH:MM" Pipedrive activities accept, rounding started minutes up
+func (d Duration) ActivityDuration() string {
+	minutes := (d.Seconds() + 59) / 60
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// UnmarshalJSON accepts a duration string or a number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		*d = 0
+		return nil
+	}
+	var value string
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return &DurationError{Value: raw}
+		}
+	} else {
+		value = raw
+	}
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as "HH:MM:SS"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadDynamicVariableMapping parses the DYNAMIC_VARIABLE_MAPPING JSON (variable name -> person field key)
+func loadDynamicVariableMapping(raw string) map[string]string {
+	mapping := make(map[string]string)
+	if raw == "" {
+		return mapping
+	}
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		log.Printf("⚠️ Invalid DYNAMIC_VARIABLE_MAPPING, ignoring: %v", err)
+		return make(map[string]string)
+	}
+	return mapping
+}
+
+// mappedDynamicVariables builds Retell dynamic variables from the person's mapped fields
+func (p *PipedriveService) mappedDynamicVariables(person *PipedrivePerson) map[string]interface{} {
+	variables := make(map[string]interface{})
+	for name, fieldKey := range p.config.DynamicVariableMapping {
+		value, ok := person.Fields[fieldKey]
+		if !ok || value == nil {
+			continue
+		}
+		// Retell dynamic variables are always strings
+		variables[name] = fmt.Sprint(value)
+	}
+	return variables
+}
+
+// leadDynamicVariables builds Retell dynamic variables from the lead's mapped custom fields
+func (p *PipedriveService) leadDynamicVariables(customFields map[string]interface{}) map[string]interface{} {
+	variables := make(map[string]interface{})
+	for name, fieldKey := range p.config.LeadFieldMapping {
+		value := formatLeadFieldValue(customFields[fieldKey])
+		if value == "" {
+			continue
+		}
+		variables[name] = value
+	}
+	return variables
+}
+
+// formatLeadFieldValue stringifies a lead custom field, unwrapping monetary and option values
+func formatLeadFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		// Monetary fields come as {"value": 100, "currency": "EUR"}
+		if amount, ok := v["value"]; ok {
+			formatted := formatLeadFieldValue(amount)
+			if currency, ok := v["currency"].(string); ok && currency != "" {
+				formatted += " " + currency
+			}
+			return formatted
+		}
+		if label, ok := v["label"].(string); ok {
+			return label
+		}
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if formatted := formatLeadFieldValue(item); formatted != "" {
+				parts = append(parts, formatted)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// leadContextNoteSection renders lead context variables for the initiation activity note
+func leadContextNoteSection(variables map[string]interface{}) string {
+	if len(variables) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var section strings.Builder
+	section.WriteString("\n\nLead Context:")
+	for _, name := range names {
+		fmt.Fprintf(&section, "\n• %s: %v", name, variables[name])
+	}
+	return section.String()
+}
+
+// mappedVariableNames returns every dynamic variable name the service sends
+func (p *PipedriveService) mappedVariableNames() []string {
+	names := []string{"person_name", "lead_title"}
+	for name := range p.config.DynamicVariableMapping {
+		names = append(names, name)
+	}
+	for name := range p.config.LeadFieldMapping {
+		if _, ok := p.config.DynamicVariableMapping[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SyncAgentDynamicVariables registers any missing dynamic variables on the agent's Retell LLM and returns the names added
+func (p *PipedriveService) SyncAgentDynamicVariables() ([]string, error) {
+	body, err := p.makeRetellRequest("GET", "/get-agent/"+url.PathEscape(p.config.RetellAssistantID), nil)
+	if err != nil {
+		return nil, err
+	}
+	var agent struct {
+		ResponseEngine struct {
+			Type  string `json:"type"`
+			LLMID string `json:"llm_id"`
+		} `json:"response_engine"`
+	}
+	if err := json.Unmarshal(body, &agent); err != nil {
+		return nil, fmt.Errorf("failed to parse agent: %v", err)
+	}
+	if agent.ResponseEngine.LLMID == "" {
+		return nil, fmt.Errorf("agent %s does not use a Retell LLM (engine: %s)", p.config.RetellAssistantID, agent.ResponseEngine.Type)
+	}
+
+	llmPath := "/get-retell-llm/" + url.PathEscape(agent.ResponseEngine.LLMID)
+	body, err = p.makeRetellRequest("GET", llmPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	var llm struct {
+		DefaultDynamicVariables map[string]string `json:"default_dynamic_variables"`
+	}
+	if err := json.Unmarshal(body, &llm); err != nil {
+		return nil, fmt.Errorf("failed to parse Retell LLM: %v", err)
+	}
+
+	variables := llm.DefaultDynamicVariables
+	if variables == nil {
+		variables = make(map[string]string)
+	}
+	var added []string
+	for _, name := range p.mappedVariableNames() {
+		if _, ok := variables[name]; !ok {
+			variables[name] = ""
+			added = append(added, name)
+		}
+	}
+
+	if len(added) == 0 {
+		p.logf("✅ Retell agent %s already has all %d dynamic variables", p.config.RetellAssistantID, len(variables))
+		return added, nil
+	}
+
+	_, err = p.makeRetellRequest("PATCH", "/update-retell-llm/"+url.PathEscape(agent.ResponseEngine.LLMID), map[string]interface{}{
+		"default_dynamic_variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.logf("✅ Registered dynamic variables on Retell agent %s: %v", p.config.RetellAssistantID, added)
+	return added, nil
+}
+
+// SyncDynamicVariablesHandler registers mapped dynamic variables on the Retell agent on demand
+func SyncDynamicVariablesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		added, err := pipedriveService.SyncAgentDynamicVariables()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to sync dynamic variables: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Registered %d new dynamic variables", len(added)),
+			Data: gin.H{
+				"added":     added,
+				"variables": pipedriveService.mappedVariableNames(),
+			},
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/mail"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mxLookupTimeout bounds the DNS lookups made while validating an address
+const mxLookupTimeout = 3 * time.Second
+
+// mxCacheTTL is how long a domain's mail server lookup is reused
+const mxCacheTTL = time.Hour
+
+// reservedEmailDomains never receive mail (RFC 2606 and RFC 6761)
+var reservedEmailDomains = []string{"example.com", "example.net", "example.org"}
+
+// reservedEmailTLDs are top-level domains that never receive mail
+var reservedEmailTLDs = []string{".test", ".example", ".invalid", ".localhost", ".local"}
+
+// EmailValidationError explains why an address was rejected before creating a contact
+type EmailValidationError struct {
+	Email  string
+	Reason string
+}
+
+func (e *EmailValidationError) Error() string {
+	return fmt.Sprintf("email %s rejected: %s", e.Email, e.Reason)
+}
+
+// mxResult is a cached mail server lookup
+type mxResult struct {
+	accepts   bool
+	checkedAt time.Time
+}
+
+// EmailValidator checks addresses for syntax, a mail server and the bounce-suppression list
+type EmailValidator struct {
+	mu         sync.Mutex
+	checkMX    bool
+	resolver   *net.Resolver
+	mx         map[string]mxResult // Keyed by domain
+	bounces    map[string]bool     // Addresses and "@domain" entries
+	bounceFile string
+}
+
+// NewEmailValidator creates a validator, loading EMAIL_BOUNCE_LIST_FILE when set
+func NewEmailValidator(config *Config) *EmailValidator {
+	v := &EmailValidator{
+		checkMX:    config.EmailMXCheck,
+		resolver:   net.DefaultResolver,
+		mx:         make(map[string]mxResult),
+		bounces:    make(map[string]bool),
+		bounceFile: config.EmailBounceListFile,
+	}
+	if v.bounceFile == "" {
+		return v
+	}
+	file, err := os.Open(v.bounceFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read EMAIL_BOUNCE_LIST_FILE %s: %v", v.bounceFile, err)
+		}
+		return v
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if entry := normalizeBounceEntry(scanner.Text()); entry != "" {
+			v.bounces[entry] = true
+		}
+	}
+	log.Printf("📭 Loaded %d bounce-suppressed addresses from %s", len(v.bounces), v.bounceFile)
+	return v
+}
+
+// normalizeBounceEntry lowercases an address or "@domain" entry, ignoring blanks and # comments
+func normalizeBounceEntry(entry string) string {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if entry == "" || strings.HasPrefix(entry, "#") || !strings.Contains(entry, "@") {
+		return ""
+	}
+	return entry
+}
+
+// Validate returns an *EmailValidationError when an address should not become a Pipedrive contact
+func (v *EmailValidator) Validate(email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return &EmailValidationError{Email: email, Reason: "invalid syntax"}
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if !strings.Contains(domain, ".") {
+		return &EmailValidationError{Email: email, Reason: "domain has no top-level domain"}
+	}
+	for _, reserved := range reservedEmailDomains {
+		if domain == reserved || strings.HasSuffix(domain, "."+reserved) {
+			return &EmailValidationError{Email: email, Reason: "reserved example domain"}
+		}
+	}
+	for _, tld := range reservedEmailTLDs {
+		if strings.HasSuffix(domain, tld) {
+			return &EmailValidationError{Email: email, Reason: "reserved top-level domain"}
+		}
+	}
+
+	v.mu.Lock()
+	bounced := v.bounces[email] || v.bounces["@"+domain]
+	v.mu.Unlock()
+	if bounced {
+		return &EmailValidationError{Email: email, Reason: "on the bounce-suppression list"}
+	}
+
+	if v.checkMX && !v.acceptsMail(domain) {
+		return &EmailValidationError{Email: email, Reason: "domain does not accept mail"}
+	}
+	return nil
+}
+
+// acceptsMail reports whether a domain has a mail server, failing open when DNS is unavailable
+func (v *EmailValidator) acceptsMail(domain string) bool {
+	v.mu.Lock()
+	cached, ok := v.mx[domain]
+	v.mu.Unlock()
+	if ok && time.Since(cached.checkedAt) < mxCacheTTL {
+		return cached.accepts
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mxLookupTimeout)
+	defer cancel()
+	accepts := true
+	records, err := v.resolver.LookupMX(ctx, domain)
+	switch {
+	case err == nil:
+		// A single "." MX is a null MX: the domain explicitly accepts no mail (RFC 7505)
+		accepts = !(len(records) == 1 && records[0].Host == ".")
+	case isNotFound(err):
+		// Without MX records mail goes to the domain's address records (RFC 5321 section 5.1)
+		_, hostErr := v.resolver.LookupHost(ctx, domain)
+		accepts = hostErr == nil || !isNotFound(hostErr)
+	default:
+		log.Printf("⚠️ Warning: MX lookup for %s failed, accepting address: %v", domain, err)
+		return true
+	}
+
+	v.mu.Lock()
+	v.mx[domain] = mxResult{accepts: accepts, checkedAt: time.Now()}
+	v.mu.Unlock()
+	return accepts
+}
+
+// isNotFound reports whether a DNS error means the name or record does not exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// AddBounces suppresses addresses or "@domain" entries and saves the list
+func (v *EmailValidator) AddBounces(entries []string) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	added := 0
+	for _, entry := range entries {
+		if entry = normalizeBounceEntry(entry); entry != "" && !v.bounces[entry] {
+			v.bounces[entry] = true
+			added++
+		}
+	}
+	return added, v.saveLocked()
+}
+
+// RemoveBounce lifts the suppression of an address or "@domain" entry and saves the list
+func (v *EmailValidator) RemoveBounce(entry string) (bool, error) {
+	entry = normalizeBounceEntry(entry)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.bounces[entry] {
+		return false, nil
+	}
+	delete(v.bounces, entry)
+	return true, v.saveLocked()
+}
+
+// Bounces returns the suppressed entries in order
+func (v *EmailValidator) Bounces() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	entries := make([]string, 0, len(v.bounces))
+	for entry := range v.bounces {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	return entries
+}
+
+// saveLocked rewrites EMAIL_BOUNCE_LIST_FILE; callers hold mu
+func (v *EmailValidator) saveLocked() error {
+	if v.bounceFile == "" {
+		return nil
+	}
+	entries := make([]string, 0, len(v.bounces))
+	for entry := range v.bounces {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	data := strings.Join(entries, "\n")
+	if len(entries) > 0 {
+		data += "\n"
+	}
+	if err := os.WriteFile(v.bounceFile, []byte(data), 0600); err != nil {
+		return fmt.Errorf("failed to save bounce list: %v", err)
+	}
+	return nil
+}
+
+// ListEmailBouncesHandler lists bounce-suppressed addresses and domains
+func ListEmailBouncesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bounces := pipedriveService.emailValidator.Bounces()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d suppressed entries", len(bounces)),
+			Data:    bounces,
+		})
+	}
+}
+
+// AddEmailBouncesHandler adds addresses or "@domain" entries to the bounce-suppression list
+func AddEmailBouncesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Emails []string `json:"emails"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || len(request.Emails) == 0 {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: `Expected {"emails": ["address@domain" or "@domain", ...]}`,
+			})
+			return
+		}
+		added, err := pipedriveService.emailValidator.AddBounces(request.Emails)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Suppressed %d new entries", added),
+		})
+	}
+}
+
+// RemoveEmailBounceHandler removes an address or "@domain" entry from the bounce-suppression list
+func RemoveEmailBounceHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		removed, err := pipedriveService.emailValidator.RemoveBounce(c.Param("email"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Entry is not suppressed",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Suppression removed",
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultEscalationKeywords is used when ESCALATION_KEYWORDS is not set
+var defaultEscalationKeywords = map[string][]string{
+	"Legal threat": {"lawyer", "attorney", "lawsuit", "sue you", "legal action", "take you to court"},
+	"Complaint":    {"complaint", "complain", "report you", "unacceptable", "harassment", "harassing"},
+}
+
+// Escalation is a risk found in a negative call
+type Escalation struct {
+	Label        string `json:"label"`
+	Phrase       string `json:"phrase"`
+	Seconds      int    `json:"seconds"` // Offset into the recording; -1 when the transcript has no timestamps
+	Excerpt      string `json:"excerpt"`
+	RecordingURL string `json:"recording_url,omitempty"` // Links to the matched moment when timestamps are known
+}
+
+// loadEscalationKeywords parses the ESCALATION_KEYWORDS JSON (label -> phrases)
+func loadEscalationKeywords(raw string) map[string][]string {
+	if raw == "" {
+		return defaultEscalationKeywords
+	}
+	keywords := make(map[string][]string)
+	if err := json.Unmarshal([]byte(raw), &keywords); err != nil {
+		log.Printf("⚠️ Invalid ESCALATION_KEYWORDS, using defaults: %v", err)
+		return defaultEscalationKeywords
+	}
+	return keywords
+}
+
+// matchEscalationPhrase returns the label and phrase of the risk keyword said first by the contact;
+// when phrases of several labels start at the same place, the label sorting first wins
+func matchEscalationPhrase(content string, keywords map[string][]string) (string, string, bool) {
+	content = strings.ToLower(content)
+	labels := make([]string, 0, len(keywords))
+	for label := range keywords {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	matchedLabel, matchedPhrase, at := "", "", -1
+	for _, label := range labels {
+		for _, phrase := range keywords[label] {
+			index := strings.Index(content, strings.ToLower(phrase))
+			if index >= 0 && (at < 0 || index < at) {
+				matchedLabel, matchedPhrase, at = label, phrase, index
+			}
+		}
+	}
+	return matchedLabel, matchedPhrase, at >= 0
+}
+
+// escalationWebhookURL returns the channel a tenant's escalations are posted to: its own from
+// ESCALATION_TENANT_WEBHOOK_URLS, else ESCALATION_WEBHOOK_URL. Escalations carry what the contact
+// said, so they never fall back to the ops alert channel.
+func (c *Config) escalationWebhookURL(tenant string) string {
+	if url, ok := c.EscalationWebhookURLs[tenant]; ok {
+		return url
+	}
+	return c.EscalationWebhookURL
+}
+
+// findEscalations returns the first match of each risk label in the contact's turns, with the
+// surrounding turns as the excerpt. The plain transcript is searched when there is no
+// transcript_object.
+func findEscalations(utterances []TranscriptUtterance, transcript string, keywords map[string][]string, contextTurns int, recordingURL string) []Escalation {
+	type turn struct {
+		role, content string
+		seconds       int
+	}
+	var turns []turn
+	for _, utterance := range utterances {
+		seconds := -1
+		if len(utterance.Words) > 0 {
+			seconds = int(utterance.Words[0].Start)
+		}
+		turns = append(turns, turn{role: utterance.Role, content: utterance.Content, seconds: seconds})
+	}
+	if len(turns) == 0 {
+		for _, line := range strings.Split(transcript, "\n") {
+			role, content, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			turns = append(turns, turn{role: strings.ToLower(strings.TrimSpace(role)), content: strings.TrimSpace(content), seconds: -1})
+		}
+	}
+
+	var escalations []Escalation
+	seen := make(map[string]bool)
+	for i, current := range turns {
+		if current.role != "user" {
+			continue
+		}
+		label, phrase, ok := matchEscalationPhrase(current.content, keywords)
+		if !ok || seen[label] {
+			continue
+		}
+		seen[label] = true
+
+		var excerpt strings.Builder
+		for j := i - contextTurns; j <= i+contextTurns; j++ {
+			if j < 0 || j >= len(turns) {
+				continue
+			}
+			if excerpt.Len() > 0 {
+				excerpt.WriteString("\n")
+			}
+			role := "Agent"
+			if turns[j].role == "user" {
+				role = "Contact"
+			}
+			fmt.Fprintf(&excerpt, "%s: %s", role, turns[j].content)
+		}
+		escalation := Escalation{Label: label, Phrase: phrase, Seconds: current.seconds, Excerpt: excerpt.String(), RecordingURL: recordingURL}
+		if recordingURL != "" && current.seconds >= 0 {
+			escalation.RecordingURL = fmt.Sprintf("%s#t=%d", recordingURL, current.seconds)
+		}
+		escalations = append(escalations, escalation)
+	}
+	return escalations
+}
+
+// escalateCall creates a high-priority task for a manager and notifies the tenant's escalation
+// channel when a call with negative sentiment contains risk keywords such as a legal threat or a
+// complaint. The call is marked escalated only once the task exists, so a failed one is retried.
+func (p *PipedriveService) escalateCall(payload RetellCallAnalyzedPayload, mapping CallMapping) {
+	if !p.config.EscalationEnabled || !strings.EqualFold(payload.Call.CallAnalysis.UserSentiment, "negative") || mapping.Escalated {
+		return
+	}
+	escalations := findEscalations(payload.Call.TranscriptObject, payload.Call.Transcript, p.config.EscalationKeywords,
+		p.config.EscalationContextTurns, payload.Call.RecordingURL)
+	if len(escalations) == 0 {
+		return
+	}
+	callID := payload.Call.CallID
+
+	labels := make([]string, 0, len(escalations))
+	var details strings.Builder
+	for _, escalation := range escalations {
+		labels = append(labels, escalation.Label)
+		fmt.Fprintf(&details, "\n\n%s (\"%s\")", escalation.Label, escalation.Phrase)
+		if escalation.Seconds >= 0 {
+			fmt.Fprintf(&details, " at %02d:%02d", escalation.Seconds/60, escalation.Seconds%60)
+		}
+		fmt.Fprintf(&details, "\n%s", escalation.Excerpt)
+		if escalation.RecordingURL != "" {
+			fmt.Fprintf(&details, "\nRecording: %s", escalation.RecordingURL)
+		}
+	}
+	summary := strings.Join(labels, ", ")
+	p.logf("🚨 Escalating call %s with person %d: %s", callID, mapping.PersonID, summary)
+
+	now := time.Now()
+	activityData := map[string]interface{}{
+		"subject":   fmt.Sprintf("🚨 Escalation: %s - %s", summary, mapping.PersonName),
+		"type":      p.config.EscalationActivityType,
+		"person_id": mapping.PersonID,
+		"done":      0,
+		"due_date":  pipedriveDueDate(now),
+		"due_time":  pipedriveDueTime(now),
+		"note":      fmt.Sprintf("Negative AI call flagged for review\nLead: %s\nSummary: %s\nCall ID: %s%s", mapping.LeadTitle, payload.Call.CallAnalysis.CallSummary, callID, details.String()),
+	}
+	if p.config.EscalationPriority != 0 {
+		activityData["priority"] = p.config.EscalationPriority
+	}
+	manager := p.config.EscalationManagerID
+	if manager == 0 {
+		manager = mapping.OwnerID
+	}
+	if manager != 0 {
+		activityData["user_id"] = manager
+	}
+	p.linkActivityToDeal(activityData, mapping.PersonID)
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to create escalation task for call %s: %v", callID, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		p.logf("⚠️ Warning: Failed to create escalation task for call %s: HTTP %d, Response: %s", callID, resp.StatusCode, string(body))
+		return
+	}
+	p.updateCallMapping(callID, func(m *CallMapping) { m.Escalated = true })
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "call_escalated", PersonID: mapping.PersonID, CallID: callID, Detail: summary})
+	p.logf("✅ Created escalation task for call %s (manager %d)", callID, manager)
+
+	tenant := mapping.Tenant
+	if tenant == "" {
+		tenant = p.config.TenantFor(payload.Call.AgentID)
+	}
+	channel := p.config.escalationWebhookURL(tenant)
+	if channel == "" {
+		p.debugf(SubsystemRetell, "No escalation channel for tenant %s, call %s escalated by task only", tenant, callID)
+		return
+	}
+	message := fmt.Sprintf("🚨 Escalation: %s\nPerson: %s (%d)\nLead: %s\nCall ID: %s%s",
+		summary, mapping.PersonName, mapping.PersonID, mapping.LeadTitle, callID, details.String())
+	go func() {
+		if err := p.postSlackMessage(channel, message); err != nil {
+			p.logf("⚠️ Warning: Failed to send escalation for call %s: %v", callID, err)
+		}
+	}()
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExperimentVariant is one assistant in an A/B experiment
+type ExperimentVariant struct {
+	Name    string `json:"name"`
+	AgentID string `json:"agent_id"`
+	Weight  int    `json:"weight"`
+}
+
+// ExperimentAssignment records which variant a call was placed with
+type ExperimentAssignment struct {
+	Campaign string `json:"campaign"`
+	Variant  string `json:"variant"`
+	AgentID  string `json:"agent_id"`
+}
+
+// VariantStats holds conversion metrics for one experiment variant
+type VariantStats struct {
+	Campaign       string  `json:"campaign"`
+	Variant        string  `json:"variant"`
+	AgentID        string  `json:"agent_id"`
+	Calls          int     `json:"calls"`
+	Analyzed       int     `json:"analyzed"`
+	Successful     int     `json:"successful"`
+	PurchaseIntent int     `json:"purchase_intent"`
+	ConversionRate float64 `json:"conversion_rate"` // Successful / analyzed
+	Scored         int     `json:"scored"`
+	AverageScore   float64 `json:"average_score"`
+
+	totalScore float64
+}
+
+// loadExperiments parses the AGENT_EXPERIMENTS JSON, keyed by campaign ("*" applies to all leads)
+func loadExperiments(raw string) map[string][]ExperimentVariant {
+	experiments := make(map[string][]ExperimentVariant)
+	if raw == "" {
+		return experiments
+	}
+	if err := json.Unmarshal([]byte(raw), &experiments); err != nil {
+		log.Printf("⚠️ Invalid AGENT_EXPERIMENTS, ignoring: %v", err)
+		return make(map[string][]ExperimentVariant)
+	}
+	for campaign, variants := range experiments {
+		for i, variant := range variants {
+			if variant.Name == "" {
+				experiments[campaign][i].Name = string(rune('A' + i))
+			}
+			if variant.Weight < 0 {
+				experiments[campaign][i].Weight = 0
+			}
+		}
+	}
+	return experiments
+}
+
+// ExperimentFor returns the variants configured for a campaign, falling back to "*"
+func (c *Config) ExperimentFor(campaign string) (string, []ExperimentVariant, bool) {
+	if variants, ok := c.AgentExperiments[campaign]; ok && len(variants) > 0 {
+		return campaign, variants, true
+	}
+	variants, ok := c.AgentExperiments["*"]
+	return "*", variants, ok && len(variants) > 0
+}
+
+// leadCampaign returns the campaign a lead belongs to: its launched campaign, the
+// EXPERIMENT_CAMPAIGN_FIELD value or its source
+func (c *Config) leadCampaign(payload PipedriveLeadWebhookPayload) string {
+	if payload.Campaign != "" {
+		return payload.Campaign
+	}
+	if c.ExperimentCampaignField != "" {
+		if campaign := formatLeadFieldValue(payload.Data.CustomFields[c.ExperimentCampaignField]); campaign != "" {
+			return campaign
+		}
+	}
+	return payload.Data.SourceName
+}
+
+// assignVariant picks a variant deterministically from the lead ID so retries land on the same assistant
+func assignVariant(leadID string, campaign string, variants []ExperimentVariant) ExperimentVariant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if total == 0 {
+		return variants[0]
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(campaign + ":" + leadID))
+	bucket := int(hash.Sum32() % uint32(total))
+	for _, variant := range variants {
+		if bucket < variant.Weight {
+			return variant
+		}
+		bucket -= variant.Weight
+	}
+	return variants[len(variants)-1]
+}
+
+// ExperimentTracker records conversion metrics per variant, saved to EXPERIMENTS_FILE when set
+type ExperimentTracker struct {
+	mu    sync.Mutex
+	path  string
+	stats map[string]*VariantStats // Keyed by campaign/variant
+}
+
+// experimentFileRecord is a variant's stats as saved to EXPERIMENTS_FILE, with the score total the
+// average is computed from
+type experimentFileRecord struct {
+	VariantStats
+	TotalScore float64 `json:"total_score"`
+}
+
+// NewExperimentTracker creates an experiment tracker, loading EXPERIMENTS_FILE when set
+func NewExperimentTracker(config *Config) *ExperimentTracker {
+	tracker := &ExperimentTracker{path: config.ExperimentsFile, stats: make(map[string]*VariantStats)}
+	if tracker.path == "" {
+		return tracker
+	}
+	data, err := os.ReadFile(tracker.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read EXPERIMENTS_FILE %s: %v", tracker.path, err)
+		}
+		return tracker
+	}
+	var saved map[string]experimentFileRecord
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable EXPERIMENTS_FILE %s: %v", tracker.path, err)
+		return tracker
+	}
+	for key, record := range saved {
+		stats := record.VariantStats
+		stats.totalScore = record.TotalScore
+		tracker.stats[key] = &stats
+	}
+	log.Printf("🧪 Loaded stats for %d experiment variants from %s", len(tracker.stats), tracker.path)
+	return tracker
+}
+
+// saveLocked writes the stats to EXPERIMENTS_FILE; callers must hold mu
+func (t *ExperimentTracker) saveLocked() {
+	if t.path == "" {
+		return
+	}
+	saved := make(map[string]experimentFileRecord, len(t.stats))
+	for key, stats := range t.stats {
+		saved[key] = experimentFileRecord{VariantStats: *stats, TotalScore: stats.totalScore}
+	}
+	data, err := json.Marshal(saved)
+	if err == nil {
+		tmp := t.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, t.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save experiment stats: %v", err)
+	}
+}
+
+// statsLocked returns the stats entry for an assignment; callers must hold mu
+func (t *ExperimentTracker) statsLocked(assignment ExperimentAssignment) *VariantStats {
+	key := assignment.Campaign + "/" + assignment.Variant
+	stats, ok := t.stats[key]
+	if !ok {
+		stats = &VariantStats{Campaign: assignment.Campaign, Variant: assignment.Variant, AgentID: assignment.AgentID}
+		t.stats[key] = stats
+	}
+	return stats
+}
+
+// RecordCall counts a call placed with a variant
+func (t *ExperimentTracker) RecordCall(assignment ExperimentAssignment) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.statsLocked(assignment).Calls++
+	t.saveLocked()
+}
+
+// RecordOutcome counts an analyzed call towards its variant's conversion metrics
+func (t *ExperimentTracker) RecordOutcome(assignment ExperimentAssignment, successful, purchaseIntent bool, score *LeadScore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.statsLocked(assignment)
+	stats.Analyzed++
+	if successful {
+		stats.Successful++
+	}
+	if purchaseIntent {
+		stats.PurchaseIntent++
+	}
+	if score != nil {
+		stats.Scored++
+		stats.totalScore += score.Score
+	}
+	t.saveLocked()
+}
+
+// Report returns conversion metrics for every variant, ordered by campaign and variant
+func (t *ExperimentTracker) Report() []VariantStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := make([]VariantStats, 0, len(t.stats))
+	for _, stats := range t.stats {
+		entry := *stats
+		if entry.Analyzed > 0 {
+			entry.ConversionRate = float64(entry.Successful) / float64(entry.Analyzed)
+		}
+		if entry.Scored > 0 {
+			entry.AverageScore = entry.totalScore / float64(entry.Scored)
+		}
+		report = append(report, entry)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Campaign != report[j].Campaign {
+			return report[i].Campaign < report[j].Campaign
+		}
+		return report[i].Variant < report[j].Variant
+	})
+	return report
+}
+
+// ExperimentReportHandler reports conversion metrics per experiment variant
+func ExperimentReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := pipedriveService.experiments.Report()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d experiment variants", len(report)),
+			Data:    report,
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CallExport is an analyzed call formatted for an external call library
+type CallExport struct {
+	CallID       string
+	Title        string
+	Summary      string
+	Transcript   string
+	RecordingURL string
+	CallTime     time.Time
+	Metadata     map[string]string // person_name, person_id, phone_number, lead_title, deal_id, ...
+}
+
+// Document renders the export as plain text
+func (e CallExport) Document() string {
+	var doc strings.Builder
+	doc.WriteString(e.Title + "\n\n")
+	keys := make([]string, 0, len(e.Metadata))
+	for key := range e.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&doc, "%s: %s\n", key, e.Metadata[key])
+	}
+	if e.RecordingURL != "" {
+		fmt.Fprintf(&doc, "recording: %s\n", e.RecordingURL)
+	}
+	fmt.Fprintf(&doc, "\nSummary:\n%s\n\nTranscript:\n%s\n", e.Summary, e.Transcript)
+	return doc.String()
+}
+
+// TranscriptExporter pushes analyzed calls to an external call library
+type TranscriptExporter interface {
+	Name() string
+	Export(export CallExport) error
+}
+
+// newTranscriptExporters returns the configured exporters
+func newTranscriptExporters(config *Config, httpClient *http.Client) []TranscriptExporter {
+	var exporters []TranscriptExporter
+	if config.NotionAPIKey != "" && config.NotionDatabaseID != "" {
+		exporters = append(exporters, &NotionExporter{
+			apiKey:          config.NotionAPIKey,
+			databaseID:      config.NotionDatabaseID,
+			propertyMapping: config.NotionPropertyMapping,
+			httpClient:      httpClient,
+			pages:           make(map[string]*notionPage),
+		})
+	}
+	if config.GoogleDriveFolderID != "" && config.GoogleServiceAccount != "" {
+		drive, err := newGoogleDriveExporter(config.GoogleServiceAccount, config.GoogleDriveFolderID, httpClient)
+		if err != nil {
+			log.Printf("⚠️ Google Drive export disabled: %v", err)
+		} else {
+			exporters = append(exporters, drive)
+		}
+	}
+	return exporters
+}
+
+// exportCall pushes a call to every exporter, retrying with backoff; every call is tried at least once
+func (p *PipedriveService) exportCall(export CallExport) {
+	// Calls without a deal of their own are filed under the person's open deal, like their activities
+	if export.Metadata["deal_id"] == "" && p.config.DealLinkingEnabled {
+		if personID, _ := strconv.Atoi(export.Metadata["person_id"]); personID != 0 {
+			if dealID := p.dealForPerson(personID); dealID != 0 {
+				export.Metadata["deal_id"] = strconv.Itoa(dealID)
+			}
+		}
+	}
+
+	attempts := p.config.ExportMaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for _, exporter := range p.exporters {
+		var err error
+		for attempt := 1; attempt <= attempts; attempt++ {
+			if err = exporter.Export(export); err == nil {
+				p.logf("✅ Exported call %s to %s", export.CallID, exporter.Name())
+				break
+			}
+			p.logf("⚠️ Export of call %s to %s failed (attempt %d/%d): %v",
+				export.CallID, exporter.Name(), attempt, attempts, err)
+			if attempt < attempts {
+				time.Sleep(time.Duration(1<<uint(attempt-1)) * 2 * time.Second)
+			}
+		}
+		if err != nil {
+			p.logf("❌ Giving up exporting call %s to %s: %v", export.CallID, exporter.Name(), err)
+		}
+	}
+}
+
+// NotionExporter creates a page per call in a Notion database
+type NotionExporter struct {
+	apiKey          string
+	databaseID      string
+	propertyMapping map[string]string // Metadata key -> Notion property name
+	httpClient      *http.Client
+
+	mu    sync.Mutex
+	pages map[string]*notionPage // Pages created for calls whose blocks are not all appended yet, by call ID
+}
+
+// notionPage is a created page and how many of the blocks beyond the first 100 it has
+type notionPage struct {
+	id       string
+	appended int
+}
+
+// Name returns the exporter name
+func (n *NotionExporter) Name() string {
+	return "Notion"
+}
+
+// notionText builds a rich_text array, splitting at Notion's 2000 character limit
+func notionText(text string) []map[string]interface{} {
+	var parts []map[string]interface{}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		end := 2000
+		if len(runes) < end {
+			end = len(runes)
+		}
+		parts = append(parts, map[string]interface{}{
+			"type": "text",
+			"text": map[string]string{"content": string(runes[:end])},
+		})
+		runes = runes[end:]
+	}
+	return parts
+}
+
+// Export creates the Notion page
+func (n *NotionExporter) Export(export CallExport) error {
+	properties := map[string]interface{}{
+		n.propertyName("title"): map[string]interface{}{"title": notionText(export.Title)},
+	}
+	for key, value := range export.Metadata {
+		if property, ok := n.propertyMapping[key]; ok && value != "" {
+			properties[property] = map[string]interface{}{"rich_text": notionText(value)}
+		}
+	}
+
+	var children []map[string]interface{}
+	paragraph := func(text string) {
+		// Notion limits each block to 100 rich text items
+		parts := notionText(text)
+		for len(parts) > 0 {
+			end := 100
+			if len(parts) < end {
+				end = len(parts)
+			}
+			children = append(children, map[string]interface{}{
+				"object":    "block",
+				"type":      "paragraph",
+				"paragraph": map[string]interface{}{"rich_text": parts[:end]},
+			})
+			parts = parts[end:]
+		}
+	}
+	heading := func(text string) {
+		children = append(children, map[string]interface{}{
+			"object":    "block",
+			"type":      "heading_2",
+			"heading_2": map[string]interface{}{"rich_text": notionText(text)},
+		})
+	}
+	heading("Summary")
+	paragraph(export.Summary)
+	if export.RecordingURL != "" {
+		paragraph("Recording: " + export.RecordingURL)
+	}
+	heading("Transcript")
+	for _, line := range strings.Split(export.Transcript, "\n") {
+		if strings.TrimSpace(line) != "" {
+			paragraph(line)
+		}
+	}
+
+	// Notion accepts at most 100 blocks on create; the rest are appended afterwards
+	initial := children
+	var remaining []map[string]interface{}
+	if len(children) > 100 {
+		initial, remaining = children[:100], children[100:]
+	}
+
+	// A retry carries on with the page the failed attempt created
+	n.mu.Lock()
+	page, resumed := n.pages[export.CallID]
+	n.mu.Unlock()
+	if !resumed {
+		existing, err := n.findPage(export.CallID)
+		if err != nil {
+			return err
+		}
+		if existing != "" {
+			log.Printf("ℹ️ Notion already has page %s for call %s, not exporting it again", existing, export.CallID)
+			return nil
+		}
+		body, err := n.request("POST", "/pages", map[string]interface{}{
+			"parent":     map[string]string{"database_id": n.databaseID},
+			"properties": properties,
+			"children":   initial,
+		})
+		if err != nil {
+			return err
+		}
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(body, &created); err != nil {
+			return fmt.Errorf("failed to parse Notion page: %v", err)
+		}
+		page = &notionPage{id: created.ID}
+		n.mu.Lock()
+		n.pages[export.CallID] = page
+		n.mu.Unlock()
+	}
+
+	remaining = remaining[min(page.appended, len(remaining)):]
+	for len(remaining) > 0 {
+		end := 100
+		if len(remaining) < end {
+			end = len(remaining)
+		}
+		if _, err := n.request("PATCH", "/blocks/"+page.id+"/children", map[string]interface{}{
+			"children": remaining[:end],
+		}); err != nil {
+			return err
+		}
+		page.appended += end
+		remaining = remaining[end:]
+	}
+	n.mu.Lock()
+	delete(n.pages, export.CallID)
+	n.mu.Unlock()
+	return nil
+}
+
+// findPage returns the ID of the database page already exported for a call, or "" when there is
+// none; pages can only be found when call_id is mapped to a property
+func (n *NotionExporter) findPage(callID string) (string, error) {
+	property, ok := n.propertyMapping["call_id"]
+	if !ok {
+		return "", nil
+	}
+	body, err := n.request("POST", "/databases/"+n.databaseID+"/query", map[string]interface{}{
+		"filter":    map[string]interface{}{"property": property, "rich_text": map[string]string{"equals": callID}},
+		"page_size": 1,
+	})
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Results []struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse Notion query: %v", err)
+	}
+	if len(result.Results) == 0 {
+		return "", nil
+	}
+	return result.Results[0].ID, nil
+}
+
+// propertyName returns the Notion property for a metadata key
+func (n *NotionExporter) propertyName(key string) string {
+	if property, ok := n.propertyMapping[key]; ok {
+		return property
+	}
+	return "Name"
+}
+
+// request makes an authenticated Notion API request
+func (n *NotionExporter) request(method, endpoint string, payload interface{}) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Notion request: %v", err)
+	}
+	req, err := http.NewRequest(method, "https://api.notion.com/v1"+endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.apiKey)
+	req.Header.Set("Notion-Version", "2022-06-28")
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Notion request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Notion request failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// GoogleDriveExporter uploads a text document per call to a Drive folder
+type GoogleDriveExporter struct {
+	tokens     *googleTokenSource
+	folderID   string
+	httpClient *http.Client
+}
+
+// newGoogleDriveExporter loads the service account from a JSON string or file path
+func newGoogleDriveExporter(serviceAccount, folderID string, httpClient *http.Client) (*GoogleDriveExporter, error) {
+	tokens, err := newGoogleTokenSource(serviceAccount, "https://www.googleapis.com/auth/drive.file", httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return &GoogleDriveExporter{
+		tokens:     tokens,
+		folderID:   folderID,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Name returns the exporter name
+func (g *GoogleDriveExporter) Name() string {
+	return "Google Drive"
+}
+
+// Export uploads the call document to the Drive folder
+func (g *GoogleDriveExporter) Export(export CallExport) error {
+	token, err := g.tokens.accessToken()
+	if err != nil {
+		return err
+	}
+
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"name":          fmt.Sprintf("%s - %s.txt", export.CallTime.Format("2006-01-02"), export.Title),
+		"parents":       []string{g.folderID},
+		"mimeType":      "text/plain",
+		"appProperties": export.Metadata,
+	})
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	metaPart, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	metaPart.Write(metadata)
+	filePart, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=UTF-8"}})
+	filePart.Write([]byte(export.Document()))
+	writer.Close()
+
+	req, err := http.NewRequest("POST", "https://www.googleapis.com/upload/drive/v3/files?uploadType=multipart&supportsAllDrives=true", &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+writer.Boundary())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload to Google Drive: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Google Drive upload failed: HTTP %d, Response: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// callExportMetadata returns the person/lead metadata attached to exports
+func callExportMetadata(callID string, mapping CallMapping) map[string]string {
+	metadata := map[string]string{
+		"call_id":      callID,
+		"person_name":  mapping.PersonName,
+		"person_id":    strconv.Itoa(mapping.PersonID),
+		"phone_number": mapping.PhoneNumber,
+		"lead_title":   mapping.LeadTitle,
+	}
+	if mapping.OwnerID != 0 {
+		metadata["owner_id"] = strconv.Itoa(mapping.OwnerID)
+	}
+	if mapping.DealID != 0 {
+		metadata["deal_id"] = strconv.Itoa(mapping.DealID)
+	}
+	return metadata
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Standard targets in FACEBOOK_FIELD_MAPPING; anything else is "person.<key>" or "lead.<key>"
+const (
+	FacebookTargetName      = "name"
+	FacebookTargetFirstName = "first_name"
+	FacebookTargetLastName  = "last_name"
+	FacebookTargetEmail     = "email"
+	FacebookTargetPhone     = "phone"
+)
+
+// defaultFacebookFieldMapping covers the prefilled fields of Lead Ads forms
+var defaultFacebookFieldMapping = map[string]string{
+	"full_name":    FacebookTargetName,
+	"first_name":   FacebookTargetFirstName,
+	"last_name":    FacebookTargetLastName,
+	"email":        FacebookTargetEmail,
+	"phone_number": FacebookTargetPhone,
+}
+
+// FacebookLeadgenWebhook is the Graph API webhook payload for page leadgen events
+type FacebookLeadgenWebhook struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		ID      string `json:"id"`
+		Time    int64  `json:"time"`
+		Changes []struct {
+			Field string `json:"field"`
+			Value struct {
+				LeadgenID   string `json:"leadgen_id"`
+				PageID      string `json:"page_id"`
+				FormID      string `json:"form_id"`
+				AdID        string `json:"ad_id"`
+				AdgroupID   string `json:"adgroup_id"`
+				CreatedTime int64  `json:"created_time"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// FacebookLead is a lead fetched from the Graph API
+type FacebookLead struct {
+	ID          string `json:"id"`
+	CreatedTime string `json:"created_time"`
+	FormID      string `json:"form_id"`
+	AdID        string `json:"ad_id"`
+	AdName      string `json:"ad_name"`
+	CampaignID  string `json:"campaign_id"`
+	FieldData   []struct {
+		Name   string   `json:"name"`
+		Values []string `json:"values"`
+	} `json:"field_data"`
+}
+
+// FacebookLeadsClient fetches Lead Ads submissions from the Graph API
+type FacebookLeadsClient struct {
+	config     *Config
+	httpClient *http.Client
+}
+
+// newFacebookLeadsClient returns a Lead Ads client, or nil if Facebook is not configured
+func newFacebookLeadsClient(config *Config, httpClient *http.Client) *FacebookLeadsClient {
+	if config.FacebookPageAccessToken == "" {
+		return nil
+	}
+	return &FacebookLeadsClient{config: config, httpClient: httpClient}
+}
+
+// GetLead fetches a lead's form answers by leadgen ID
+func (f *FacebookLeadsClient) GetLead(leadgenID string) (*FacebookLead, error) {
+	query := url.Values{}
+	query.Set("fields", "id,created_time,form_id,ad_id,ad_name,campaign_id,field_data")
+	requestURL := strings.TrimRight(f.config.FacebookGraphURL, "/") + "/" + url.PathEscape(leadgenID) + "?" + query.Encode()
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	// In the header, the token stays out of URLs that end up in errors and proxy logs
+	req.Header.Set("Authorization", "Bearer "+f.config.FacebookPageAccessToken)
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Graph API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Graph API lead %s failed: HTTP %d, Response: %s", leadgenID, resp.StatusCode, string(body))
+	}
+
+	var lead FacebookLead
+	if err := json.Unmarshal(body, &lead); err != nil {
+		return nil, fmt.Errorf("failed to parse lead: %v", err)
+	}
+	return &lead, nil
+}
+
+// verifyFacebookSignature checks the X-Hub-Signature-256 header against the app secret; nothing
+// verifies without a secret
+func verifyFacebookSignature(payload []byte, header, secret string) bool {
+	signature := strings.TrimPrefix(header, "sha256=")
+	if secret == "" || signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// inboundLead maps a Lead Ads submission to an inbound lead using FACEBOOK_FIELD_MAPPING
+func (f *FacebookLeadsClient) inboundLead(lead *FacebookLead) InboundLead {
+	mapping := f.config.FacebookFieldMapping
+	if len(mapping) == 0 {
+		mapping = defaultFacebookFieldMapping
+	}
+
+	inbound := InboundLead{
+		Source:       "facebook",
+		PersonFields: make(map[string]interface{}),
+		LeadFields:   make(map[string]interface{}),
+		Attribution:  Attribution{"utm_source": "facebook", "utm_medium": "paid_social"},
+		AutoCall:     f.config.FacebookLeadAutoCall,
+	}
+	if lead.CampaignID != "" {
+		inbound.Attribution["utm_campaign"] = lead.CampaignID
+	}
+	if lead.AdName != "" {
+		inbound.Attribution["utm_content"] = lead.AdName
+	}
+
+	var firstName, lastName string
+	var answers []string
+	for _, field := range lead.FieldData {
+		value := strings.Join(field.Values, ", ")
+		answers = append(answers, fmt.Sprintf("%s: %s", field.Name, value))
+
+		target := mapping[field.Name]
+		switch {
+		case target == FacebookTargetName:
+			inbound.Name = value
+		case target == FacebookTargetFirstName:
+			firstName = value
+		case target == FacebookTargetLastName:
+			lastName = value
+		case target == FacebookTargetEmail:
+			inbound.Email = strings.ToLower(value)
+		case target == FacebookTargetPhone:
+			inbound.Phone = value
+		case strings.HasPrefix(target, "person."):
+			inbound.PersonFields[strings.TrimPrefix(target, "person.")] = value
+		case strings.HasPrefix(target, "lead."):
+			inbound.LeadFields[strings.TrimPrefix(target, "lead.")] = value
+		}
+	}
+	if inbound.Name == "" {
+		inbound.Name = strings.TrimSpace(firstName + " " + lastName)
+	}
+
+	inbound.Title = "Facebook lead"
+	if inbound.Name != "" {
+		inbound.Title = "Facebook lead: " + inbound.Name
+	}
+	inbound.Note = fmt.Sprintf("Facebook Lead Ads submission %s\nForm: %s\nAd: %s %s\nCampaign: %s\n\n%s",
+		lead.ID, lead.FormID, lead.AdID, lead.AdName, lead.CampaignID, strings.Join(answers, "\n"))
+	return inbound
+}
+
+// processFacebookLead fetches a leadgen submission and runs it through intake once
+func (p *PipedriveService) processFacebookLead(leadgenID string) error {
+	release, ok := p.claim("fblead:" + leadgenID)
+	if !ok {
+		return nil
+	}
+	lead, err := p.facebook.GetLead(leadgenID)
+	if err != nil {
+		release()
+		return err
+	}
+	result, err := p.intakeLead(p.facebook.inboundLead(lead))
+	if err != nil {
+		release()
+		return fmt.Errorf("failed to create lead from Facebook lead %s: %v", leadgenID, err)
+	}
+	p.logf("📘 Facebook lead %s -> Pipedrive lead %s (person %d, call queued: %t)", leadgenID, result.LeadID, result.PersonID, result.CallQueued)
+	return nil
+}
+
+// FacebookVerifyHandler answers the Graph API webhook subscription challenge
+func FacebookVerifyHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := pipedriveService.config.FacebookVerifyToken
+		if c.Query("hub.mode") != "subscribe" || token == "" ||
+			subtle.ConstantTimeCompare([]byte(c.Query("hub.verify_token")), []byte(token)) != 1 {
+			c.JSON(http.StatusForbidden, WebhookResponse{
+				Success: false,
+				Message: "Verification failed",
+			})
+			return
+		}
+		c.String(http.StatusOK, c.Query("hub.challenge"))
+	}
+}
+
+// FacebookLeadsWebhookHandler handles Facebook Lead Ads leadgen webhooks
+func FacebookLeadsWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
+		if pipedriveService.facebook == nil {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Facebook Lead Ads integration not configured",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Failed to read request body",
+			})
+			return
+		}
+		var payload FacebookLeadgenWebhook
+		if err := json.Unmarshal(body, &payload); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		// Facebook expects a fast 200, so each lead is fetched and created in the background
+		var jobs []string
+		for _, entry := range payload.Entry {
+			for _, change := range entry.Changes {
+				if change.Field != "leadgen" || change.Value.LeadgenID == "" {
+					continue
+				}
+				leadgenID := change.Value.LeadgenID
+				job, err := pipedriveService.processing.Run("facebook_lead", func() error {
+					return pipedriveService.processFacebookLead(leadgenID)
+				})
+				if err != nil {
+					c.Header("Retry-After", "5")
+					c.JSON(http.StatusServiceUnavailable, WebhookResponse{
+						Success: false,
+						Message: "Server busy, retry later: " + err.Error(),
+					})
+					return
+				}
+				jobs = append(jobs, job.ID)
+			}
+		}
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Accepted %d Facebook leads", len(jobs)),
+			Data:    gin.H{"processing_ids": jobs},
+		})
+	}
+}
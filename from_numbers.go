@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retellNumbersCacheTTL is how long the Retell phone number list is reused when validating rules
+const retellNumbersCacheTTL = 10 * time.Minute
+
+// FromNumberRule calls leads matching a source and/or label from a specific Retell number
+type FromNumberRule struct {
+	Source     string   `json:"source"`      // Lead source_name, case-insensitive
+	LabelIDs   []string `json:"label_ids"`   // Matches when the lead has any of these labels
+	Labels     []string `json:"labels"`      // Label names, resolved to IDs in the Pipedrive account
+	FromNumber string   `json:"from_number"` // E.164 number attached to the chosen agent
+}
+
+// matches reports whether a lead satisfies every condition the rule sets; resolvedLabels are the
+// IDs of the rule's label names
+func (r FromNumberRule) matches(sourceName string, labelIDs, resolvedLabels []string) bool {
+	if r.Source != "" && !strings.EqualFold(strings.TrimSpace(sourceName), r.Source) {
+		return false
+	}
+	if len(r.LabelIDs) == 0 && len(r.Labels) == 0 {
+		return true
+	}
+	for _, want := range append(append([]string{}, r.LabelIDs...), resolvedLabels...) {
+		for _, label := range labelIDs {
+			if label == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// loadFromNumberRules parses the FROM_NUMBER_RULES JSON array, dropping rules without a condition or number
+func loadFromNumberRules(raw string) []FromNumberRule {
+	if raw == "" {
+		return nil
+	}
+	var rules []FromNumberRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Printf("⚠️ Invalid FROM_NUMBER_RULES, ignoring: %v", err)
+		return nil
+	}
+	valid := rules[:0]
+	for _, rule := range rules {
+		rule.Source = strings.TrimSpace(rule.Source)
+		if rule.FromNumber == "" || (rule.Source == "" && len(rule.LabelIDs) == 0 && len(rule.Labels) == 0) {
+			log.Printf("⚠️ Ignoring FROM_NUMBER_RULES entry without from_number and source, label_ids or labels: %+v", rule)
+			continue
+		}
+		valid = append(valid, rule)
+	}
+	return valid
+}
+
+// RetellNumberCache keeps the Retell phone number list for from-number validation
+type RetellNumberCache struct {
+	mu        sync.Mutex
+	numbers   []RetellPhoneNumber
+	fetchedAt time.Time
+}
+
+// retellNumbers returns the account's phone numbers, refreshing the cache when it is stale
+func (p *PipedriveService) retellNumbers() ([]RetellPhoneNumber, error) {
+	cache := p.phoneNumbers
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.numbers != nil && time.Since(cache.fetchedAt) < retellNumbersCacheTTL {
+		return cache.numbers, nil
+	}
+	numbers, err := p.ListRetellPhoneNumbers()
+	if err != nil {
+		return nil, err
+	}
+	cache.numbers, cache.fetchedAt = numbers, time.Now()
+	return numbers, nil
+}
+
+// numberAttachedToAgent reports whether Retell has the number bound to the agent for outbound calls
+func (p *PipedriveService) numberAttachedToAgent(fromNumber, agentID string) (bool, error) {
+	numbers, err := p.retellNumbers()
+	if err != nil {
+		return false, err
+	}
+	for _, number := range numbers {
+		if number.PhoneNumber == fromNumber {
+			return number.OutboundAgentID == agentID, nil
+		}
+	}
+	return false, nil
+}
+
+// leadFromNumber picks the from-number for a lead call: the first matching FROM_NUMBER_RULES entry
+// whose number is attached to the agent, otherwise "" for RETELL_FROM_NUMBER
+func (p *PipedriveService) leadFromNumber(payload PipedriveLeadWebhookPayload, agentID string) string {
+	for _, rule := range p.config.FromNumberRules {
+		if !rule.matches(payload.Data.SourceName, payload.Data.LabelIDs, p.leadLabelIDs(rule.Labels)) {
+			continue
+		}
+		attached, err := p.numberAttachedToAgent(rule.FromNumber, agentID)
+		if err != nil {
+			// Retell rejects the call itself if the number turns out to be unusable
+			p.logf("⚠️ Warning: Could not verify from-number %s for agent %s, using it anyway: %v", rule.FromNumber, agentID, err)
+			return rule.FromNumber
+		}
+		if !attached {
+			detail := fmt.Sprintf("from-number %s is not attached to agent %s; trying the next rule", rule.FromNumber, agentID)
+			p.logf("⚠️ Lead %s matched a from-number rule but %s", payload.Data.ID, detail)
+			p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "from_number_rule_rejected", PersonID: payload.Data.PersonID, Detail: detail})
+			continue
+		}
+		p.logf("📱 Lead %s (source %q) will be called from %s", payload.Data.ID, payload.Data.SourceName, rule.FromNumber)
+		return rule.FromNumber
+	}
+	return ""
+}
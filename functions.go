@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetellFunctionRequest is the body Retell sends when an agent invokes a custom function
+type RetellFunctionRequest struct {
+	Name string `json:"name"`
+	Call struct {
+		CallID     string        `json:"call_id"`
+		AgentID    string        `json:"agent_id"`
+		FromNumber string        `json:"from_number"`
+		ToNumber   string        `json:"to_number"`
+		Metadata   *CallMetadata `json:"metadata"`
+	} `json:"call"`
+	Args json.RawMessage `json:"args"`
+}
+
+// bindFunctionRequest parses a Retell function call and its arguments
+func bindFunctionRequest(c *gin.Context, args interface{}) (*RetellFunctionRequest, bool) {
+	var request RetellFunctionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, WebhookResponse{
+			Success: false,
+			Message: "Invalid JSON payload",
+		})
+		return nil, false
+	}
+	if len(request.Args) > 0 {
+		if err := json.Unmarshal(request.Args, args); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid function arguments",
+			})
+			return nil, false
+		}
+	}
+	request.Call.Metadata = trustedMetadata(c, request.Call.Metadata)
+	log.Printf("🛠️ Agent called %s during call %s", c.FullPath(), request.Call.CallID)
+	return &request, true
+}
+
+// functionResult replies to the agent; failures still return 200 so the agent can read the message
+func functionResult(c *gin.Context, success bool, message string, data any) {
+	c.JSON(http.StatusOK, WebhookResponse{
+		Success: success,
+		Message: message,
+		Data:    data,
+	})
+}
+
+// CheckAvailabilityArgs are the arguments of the check-availability function
+type CheckAvailabilityArgs struct {
+	Days int `json:"days"` // How many days ahead to look, defaults to CAL_SLOT_DAYS
+}
+
+// CheckAvailabilityFunctionHandler returns open meeting times the agent can offer mid-call
+func CheckAvailabilityFunctionHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var args CheckAvailabilityArgs
+		request, ok := bindFunctionRequest(c, &args)
+		if !ok {
+			return
+		}
+		if pipedriveService.cal == nil {
+			functionResult(c, false, "Scheduling is not available right now, offer to have someone follow up instead.", nil)
+			return
+		}
+
+		days := args.Days
+		if days <= 0 {
+			days = pipedriveService.config.CalSlotDays
+		}
+		slots, err := pipedriveService.cal.AvailableSlots(time.Now(), days)
+		if err != nil {
+			log.Printf("❌ Availability check failed for call %s: %v", request.Call.CallID, err)
+			functionResult(c, false, "Could not load the calendar right now, offer to have someone follow up instead.", nil)
+			return
+		}
+		if mapping, ok := pipedriveService.callMappingFor(request.Call.CallID, request.Call.Metadata, request.Call.ToNumber); ok {
+			slots = pipedriveService.repAvailableSlots(mapping.OwnerID, slots)
+		}
+
+		locale := pipedriveService.config.LocaleFor(pipedriveService.config.TenantFor(request.Call.AgentID))
+		variables := calSlotVariables(slots, pipedriveService.config.CalOfferedSlots, pipedriveService.config.CalTimeZone, locale)
+		if len(slots) == 0 {
+			functionResult(c, true, fmt.Sprintf("No open times in the next %d days.", days), variables)
+			return
+		}
+		functionResult(c, true, fmt.Sprintf("Open times: %s", variables["available_slots"]), variables)
+	}
+}
+
+// BookMeetingArgs are the arguments of the book-meeting function
+type BookMeetingArgs struct {
+	Start string `json:"start"` // RFC3339 start, one of available_slot_starts
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Notes string `json:"notes"`
+}
+
+// BookMeetingFunctionHandler books a Cal.com meeting mid-call and notes it on the Pipedrive person
+func BookMeetingFunctionHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var args BookMeetingArgs
+		request, ok := bindFunctionRequest(c, &args)
+		if !ok {
+			return
+		}
+		if pipedriveService.cal == nil {
+			functionResult(c, false, "Booking is not available right now, offer to have someone follow up instead.", nil)
+			return
+		}
+		if _, err := time.Parse(time.RFC3339, args.Start); err != nil {
+			functionResult(c, false, "The start time must be one of the offered times.", nil)
+			return
+		}
+		if args.Email == "" {
+			functionResult(c, false, "An email address is needed to send the invitation, please ask for it.", nil)
+			return
+		}
+
+		booking := BookingRequest{
+			Start:  args.Start,
+			Name:   args.Name,
+			Email:  args.Email,
+			CallID: request.Call.CallID,
+			Notes:  args.Notes,
+		}
+		mapping, hasMapping := pipedriveService.callMappingFor(request.Call.CallID, request.Call.Metadata, request.Call.ToNumber)
+		if hasMapping {
+			if booking.Name == "" {
+				booking.Name = mapping.PersonName
+			}
+			booking.Phone = mapping.PhoneNumber
+		}
+		if booking.Name == "" {
+			booking.Name = args.Email
+		}
+
+		result, err := pipedriveService.cal.CreateBooking(booking)
+		if err != nil {
+			log.Printf("❌ In-call booking failed for call %s: %v", request.Call.CallID, err)
+			functionResult(c, false, "That time could not be booked, please offer another time.", nil)
+			return
+		}
+		log.Printf("📅 Agent booked %s for call %s (booking %d)", args.Start, request.Call.CallID, result.ID)
+
+		if hasMapping && mapping.PersonID != 0 {
+			noteData := map[string]interface{}{
+				"content":   fmt.Sprintf("📅 Meeting booked by AI agent during call\nCall ID: %s\nStart: %s\nEmail: %s\nBooking ID: %d", request.Call.CallID, args.Start, args.Email, result.ID),
+				"person_id": mapping.PersonID,
+			}
+			resp, err := pipedriveService.makePipedriveRequest("POST", "/notes", noteData)
+			if err != nil {
+				log.Printf("⚠️ Warning: Failed to add booking note: %v", err)
+			} else {
+				resp.Body.Close()
+			}
+		}
+
+		functionResult(c, true, "The meeting is booked and an invitation was sent by email.", result)
+	}
+}
+
+// LookupContactArgs are the arguments of the lookup-contact function
+type LookupContactArgs struct {
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+}
+
+// ContactSummary is what the agent learns about the person it is speaking with
+type ContactSummary struct {
+	PersonID        int                 `json:"person_id"`
+	Name            string              `json:"name"`
+	MatchedBy       string              `json:"matched_by"`
+	OtherMatches    int                 `json:"other_matches"` // Other persons sharing the phone/email; verify identity when > 0
+	Deals           []PipedriveDeal     `json:"deals"`
+	LastInteraction *PipedriveActivity  `json:"last_interaction,omitempty"`
+	OpenInvoices    []PaymentLinkRecord `json:"open_invoices"`
+}
+
+// lookupFunctionContact finds the person for a function call by email, phone or the call itself
+func (p *PipedriveService) lookupFunctionContact(request *RetellFunctionRequest, args LookupContactArgs) (*PipedrivePerson, string, int, error) {
+	searches := []struct{ field, term string }{
+		{"email", args.Email},
+		{"phone", args.Phone},
+	}
+	for _, search := range searches {
+		if search.term == "" {
+			continue
+		}
+		persons, err := p.SearchPersons(search.field, search.term)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		if len(persons) > 0 {
+			return &persons[0], search.field, len(persons) - 1, nil
+		}
+	}
+
+	if mapping, ok := p.callMappingFor(request.Call.CallID, request.Call.Metadata, request.Call.ToNumber); ok && mapping.PersonID != 0 {
+		person, err := p.GetPersonByID(mapping.PersonID)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		return person, "call", 0, nil
+	}
+	if args.Phone == "" && args.Email == "" && request.Call.ToNumber != "" {
+		return p.lookupFunctionContact(request, LookupContactArgs{Phone: request.Call.ToNumber})
+	}
+	return nil, "", 0, nil
+}
+
+// LookupContactFunctionHandler returns deal status, last interaction and open invoices for the person on the call
+func LookupContactFunctionHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var args LookupContactArgs
+		request, ok := bindFunctionRequest(c, &args)
+		if !ok {
+			return
+		}
+
+		person, matchedBy, others, err := pipedriveService.lookupFunctionContact(request, args)
+		if err != nil {
+			log.Printf("❌ Contact lookup failed for call %s: %v", request.Call.CallID, err)
+			functionResult(c, false, "The CRM could not be reached, continue without account details.", nil)
+			return
+		}
+		if person == nil {
+			functionResult(c, false, "No matching contact was found in the CRM.", nil)
+			return
+		}
+
+		summary := ContactSummary{
+			PersonID:     person.ID,
+			Name:         person.Name,
+			MatchedBy:    matchedBy,
+			OtherMatches: others,
+		}
+		if deals, err := pipedriveService.ListPersonDeals(person.ID); err != nil {
+			log.Printf("⚠️ Warning: Failed to list deals for person %d: %v", person.ID, err)
+		} else {
+			summary.Deals = deals
+		}
+		if activities, err := pipedriveService.ListPersonActivities(person.ID); err != nil {
+			log.Printf("⚠️ Warning: Failed to list activities for person %d: %v", person.ID, err)
+		} else {
+			for i := range activities {
+				activity := activities[i]
+				if !activity.Done {
+					continue
+				}
+				if summary.LastInteraction == nil || activity.DueDate+activity.DueTime > summary.LastInteraction.DueDate+summary.LastInteraction.DueTime {
+					summary.LastInteraction = &activity
+				}
+			}
+		}
+		if pipedriveService.stripe != nil {
+			summary.OpenInvoices = pipedriveService.stripe.OpenLinks(person.ID)
+		}
+
+		message := fmt.Sprintf("Contact: %s.", person.Name)
+		if others > 0 {
+			message += fmt.Sprintf(" %d other contacts share this %s, confirm you are speaking with %s.", others, matchedBy, person.Name)
+		}
+		openDeals := 0
+		for _, deal := range summary.Deals {
+			if deal.Status == "open" {
+				openDeals++
+			}
+		}
+		message += fmt.Sprintf(" %d open deals.", openDeals)
+		if summary.LastInteraction != nil {
+			message += fmt.Sprintf(" Last interaction: %s on %s.", summary.LastInteraction.Subject, summary.LastInteraction.DueDate)
+		}
+		if len(summary.OpenInvoices) > 0 {
+			message += fmt.Sprintf(" %d unpaid invoices.", len(summary.OpenInvoices))
+		}
+
+		log.Printf("🔍 Agent looked up person %d during call %s (matched by %s)", person.ID, request.Call.CallID, matchedBy)
+		functionResult(c, true, message, summary)
+	}
+}
+
+// MarkDNCArgs are the arguments of the mark-dnc function
+type MarkDNCArgs struct {
+	Reason string `json:"reason"`
+}
+
+// isMarkedDNC returns true if the person's DNC field is already set
+func (p *PipedriveService) isMarkedDNC(person *PipedrivePerson) bool {
+	switch strings.ToLower(formatLeadFieldValue(person.Fields[p.config.DNCField])) {
+	case "true", "1", "yes":
+		return true
+	}
+	return false
+}
+
+// MarkDNCFunctionHandler marks the person on the call Do-Not-Call as soon as they ask
+func MarkDNCFunctionHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var args MarkDNCArgs
+		request, ok := bindFunctionRequest(c, &args)
+		if !ok {
+			return
+		}
+
+		personID, err := pipedriveService.resolveCallPersonID(request.Call.CallID, request.Call.ToNumber, request.Call.Metadata)
+		if err != nil || personID == 0 {
+			log.Printf("❌ mark-dnc: no person for call %s: %v", request.Call.CallID, err)
+			functionResult(c, false, "The contact could not be found; confirm the request will be passed on to the team.", nil)
+			return
+		}
+
+		// Retries and repeated requests must not write twice
+		person, err := pipedriveService.GetPersonByID(personID)
+		if err != nil {
+			log.Printf("❌ mark-dnc: failed to load person %d: %v", personID, err)
+			functionResult(c, false, "The request could not be saved right now; confirm it will be passed on to the team.", nil)
+			return
+		}
+		if pipedriveService.isMarkedDNC(person) {
+			log.Printf("ℹ️ Person %d is already Do Not Call (call %s)", personID, request.Call.CallID)
+			functionResult(c, true, "The contact is already on the do-not-call list.", gin.H{"person_id": personID, "already_marked": true})
+			return
+		}
+
+		if err := pipedriveService.MarkContactAsDNC(personID); err != nil {
+			log.Printf("❌ mark-dnc: %v", err)
+			functionResult(c, false, "The request could not be saved right now; confirm it will be passed on to the team.", nil)
+			return
+		}
+		pipedriveService.auditLog.Append(AuditEntry{
+			Actor:    AuditActorAgent,
+			Action:   "dnc_set",
+			PersonID: personID,
+			CallID:   request.Call.CallID,
+			Detail:   args.Reason,
+		})
+
+		activityData := map[string]interface{}{
+			"subject":   "Customer Requested Do Not Call",
+			"type":      "call",
+			"person_id": personID,
+			"note":      fmt.Sprintf("Marked Do Not Call by the AI agent during the call\nCall ID: %s\nReason: %s", request.Call.CallID, args.Reason),
+			"done":      1,
+			"due_date":  pipedriveDueDate(time.Now()),
+			"due_time":  pipedriveDueTime(time.Now()),
+		}
+		resp, err := pipedriveService.createActivity(activityData)
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to log DNC activity for person %d: %v", personID, err)
+		} else {
+			resp.Body.Close()
+		}
+
+		functionResult(c, true, "The contact has been added to the do-not-call list.", gin.H{"person_id": personID, "already_marked": false})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// googleServiceAccount holds the fields of a Google service account key file
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// googleTokenSource issues access tokens for a service account and one OAuth scope
+type googleTokenSource struct {
+	account    googleServiceAccount
+	key        *rsa.PrivateKey
+	scope      string
+	httpClient *http.Client
+	mu         sync.Mutex
+	token      string
+	expiresAt  time.Time
+}
+
+// newGoogleTokenSource loads the service account from a JSON string or file path
+func newGoogleTokenSource(serviceAccount, scope string, httpClient *http.Client) (*googleTokenSource, error) {
+	data := []byte(serviceAccount)
+	if !strings.HasPrefix(strings.TrimSpace(serviceAccount), "{") {
+		fileData, err := os.ReadFile(serviceAccount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account file: %v", err)
+		}
+		data = fileData
+	}
+
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid service account JSON: %v", err)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid service account private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("service account private key is not RSA")
+	}
+
+	return &googleTokenSource{
+		account:    account,
+		key:        key,
+		scope:      scope,
+		httpClient: httpClient,
+	}, nil
+}
+
+// accessToken exchanges a signed JWT assertion for an access token, caching it until shortly before expiry
+func (g *googleTokenSource) accessToken() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.token != "" && time.Now().Before(g.expiresAt) {
+		return g.token, nil
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   g.account.ClientEmail,
+		"scope": g.scope,
+		"aud":   g.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token assertion: %v", err)
+	}
+
+	resp, err := g.httpClient.PostForm(g.account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("token request failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %v", err)
+	}
+	g.token = result.AccessToken
+	g.expiresAt = now.Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return g.token, nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// holdBookingProperty is the private extended property tying a calendar hold to its Cal.com
+// booking, so the hold can be found again after a restart
+const holdBookingProperty = "pipcal_booking"
+
+// GoogleCalendarClient checks rep availability and manages calendar holds
+type GoogleCalendarClient struct {
+	tokens     *googleTokenSource
+	httpClient *http.Client
+	mu         sync.Mutex
+	emails     map[int]string // Pipedrive user ID -> email, used as the calendar ID
+}
+
+// busyPeriod is a time range a calendar is busy
+type busyPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// newGoogleCalendarClient returns a Calendar client, or nil if it is not configured
+func newGoogleCalendarClient(config *Config, httpClient *http.Client) *GoogleCalendarClient {
+	if !config.GoogleCalendarEnabled {
+		return nil
+	}
+	if config.GoogleServiceAccount == "" {
+		log.Printf("⚠️ GOOGLE_CALENDAR_ENABLED requires GOOGLE_SERVICE_ACCOUNT")
+		return nil
+	}
+	tokens, err := newGoogleTokenSource(config.GoogleServiceAccount, "https://www.googleapis.com/auth/calendar", httpClient)
+	if err != nil {
+		log.Printf("⚠️ Google Calendar disabled: %v", err)
+		return nil
+	}
+	return &GoogleCalendarClient{
+		tokens:     tokens,
+		httpClient: httpClient,
+		emails:     make(map[int]string),
+	}
+}
+
+// request sends an authenticated request to the Calendar API; payload is nil for requests without a body
+func (g *GoogleCalendarClient) request(method, endpoint string, payload interface{}) ([]byte, error) {
+	token, err := g.tokens.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	var reqBody io.Reader
+	if payload != nil {
+		jsonBody, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %v", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	req, err := http.NewRequest(method, "https://www.googleapis.com/calendar/v3"+endpoint, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Google Calendar request: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Google Calendar %s %s failed: HTTP %d, Response: %s", method, endpoint, resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// BusyPeriods returns the busy ranges of a calendar between two times
+func (g *GoogleCalendarClient) BusyPeriods(calendarID string, from, to time.Time) ([]busyPeriod, error) {
+	body, err := g.request("POST", "/freeBusy", map[string]interface{}{
+		"timeMin": from.UTC().Format(time.RFC3339),
+		"timeMax": to.UTC().Format(time.RFC3339),
+		"items":   []map[string]string{{"id": calendarID}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Calendars map[string]struct {
+			Busy   []busyPeriod `json:"busy"`
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"calendars"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse free/busy response: %v", err)
+	}
+	calendar := result.Calendars[calendarID]
+	if len(calendar.Errors) > 0 {
+		return nil, fmt.Errorf("calendar %s is not accessible: %s", calendarID, calendar.Errors[0].Reason)
+	}
+	return calendar.Busy, nil
+}
+
+// holdEvent is the event body of a calendar hold
+func holdEvent(summary, description string, start, end time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"summary":      summary,
+		"description":  description,
+		"start":        map[string]string{"dateTime": start.UTC().Format(time.RFC3339)},
+		"end":          map[string]string{"dateTime": end.UTC().Format(time.RFC3339)},
+		"transparency": "opaque",
+	}
+}
+
+// CreateHold creates a busy event for a booking on a calendar and returns its ID
+func (g *GoogleCalendarClient) CreateHold(calendarID, booking, summary, description string, start, end time.Time) (string, error) {
+	event := holdEvent(summary, description, start, end)
+	event["extendedProperties"] = map[string]interface{}{
+		"private": map[string]string{holdBookingProperty: booking},
+	}
+	body, err := g.request("POST", "/calendars/"+url.PathEscape(calendarID)+"/events", event)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse event response: %v", err)
+	}
+	return result.ID, nil
+}
+
+// FindHold returns the ID of a booking's hold on a calendar, or "" if it has none
+func (g *GoogleCalendarClient) FindHold(calendarID, booking string) (string, error) {
+	query := url.Values{"privateExtendedProperty": {holdBookingProperty + "=" + booking}}
+	body, err := g.request("GET", "/calendars/"+url.PathEscape(calendarID)+"/events?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		Items []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse events response: %v", err)
+	}
+	if len(result.Items) == 0 {
+		return "", nil
+	}
+	return result.Items[0].ID, nil
+}
+
+// UpdateHold moves a hold and refreshes its summary and description
+func (g *GoogleCalendarClient) UpdateHold(calendarID, eventID, summary, description string, start, end time.Time) error {
+	_, err := g.request("PATCH", "/calendars/"+url.PathEscape(calendarID)+"/events/"+url.PathEscape(eventID), holdEvent(summary, description, start, end))
+	return err
+}
+
+// DeleteHold removes a hold from a calendar
+func (g *GoogleCalendarClient) DeleteHold(calendarID, eventID string) error {
+	_, err := g.request("DELETE", "/calendars/"+url.PathEscape(calendarID)+"/events/"+url.PathEscape(eventID), nil)
+	return err
+}
+
+// repCalendarID returns the calendar of a Pipedrive user, from GOOGLE_CALENDAR_IDS or the user's email
+func (p *PipedriveService) repCalendarID(userID int) (string, error) {
+	if calendarID, ok := p.config.GoogleCalendarIDs[strconv.Itoa(userID)]; ok {
+		return calendarID, nil
+	}
+
+	p.calendar.mu.Lock()
+	email, ok := p.calendar.emails[userID]
+	p.calendar.mu.Unlock()
+	if ok {
+		return email, nil
+	}
+
+	resp, err := p.makePipedriveRequest("GET", fmt.Sprintf("/users/%d", userID), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read user response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("user lookup failed: HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Data struct {
+			Email string `json:"email"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse user response: %v", err)
+	}
+	if result.Data.Email == "" {
+		return "", fmt.Errorf("user %d has no email", userID)
+	}
+
+	p.calendar.mu.Lock()
+	p.calendar.emails[userID] = result.Data.Email
+	p.calendar.mu.Unlock()
+	return result.Data.Email, nil
+}
+
+// repAvailableSlots drops slots that overlap the assigned rep's busy calendar time
+func (p *PipedriveService) repAvailableSlots(userID int, slots []CalSlot) []CalSlot {
+	if p.calendar == nil || userID == 0 || len(slots) == 0 {
+		return slots
+	}
+	calendarID, err := p.repCalendarID(userID)
+	if err != nil {
+		p.logf("⚠️ Warning: No calendar for user %d, offering slots unchecked: %v", userID, err)
+		return slots
+	}
+
+	length := time.Duration(p.config.GoogleCalendarMeetingMinutes) * time.Minute
+	busy, err := p.calendar.BusyPeriods(calendarID, slots[0].Time, slots[len(slots)-1].Time.Add(length))
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to check availability of user %d, offering slots unchecked: %v", userID, err)
+		return slots
+	}
+
+	available := make([]CalSlot, 0, len(slots))
+	for _, slot := range slots {
+		end := slot.Time.Add(length)
+		free := true
+		for _, period := range busy {
+			if slot.Time.Before(period.End) && end.After(period.Start) {
+				free = false
+				break
+			}
+		}
+		if free {
+			available = append(available, slot)
+		}
+	}
+	p.debugf(SubsystemCal, "User %d is free for %d of %d slots", userID, len(available), len(slots))
+	return available
+}
+
+// mirrorMeetingHold keeps the hold on the rep's calendar in step with a Cal.com booking: a
+// cancellation deletes it, a reschedule moves it and a new booking creates it
+func (p *PipedriveService) mirrorMeetingHold(trigger, booking string, userID int, subject, note string, start, end time.Time) {
+	if p.calendar == nil || userID == 0 {
+		return
+	}
+	calendarID, err := p.repCalendarID(userID)
+	if err != nil {
+		p.logf("⚠️ Warning: No calendar for user %d, skipping hold: %v", userID, err)
+		return
+	}
+	eventID, err := p.calendar.FindHold(calendarID, booking)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to look up the calendar hold of booking %s for user %d: %v", booking, userID, err)
+		return
+	}
+
+	if trigger == "BOOKING_CANCELLED" {
+		if eventID == "" {
+			return
+		}
+		if err := p.calendar.DeleteHold(calendarID, eventID); err != nil {
+			p.logf("⚠️ Warning: Failed to delete calendar hold %s for user %d: %v", eventID, userID, err)
+			return
+		}
+		p.logf("📅 Deleted calendar hold %s for user %d", eventID, userID)
+		return
+	}
+
+	if !end.After(start) {
+		end = start.Add(time.Duration(p.config.GoogleCalendarMeetingMinutes) * time.Minute)
+	}
+	if eventID != "" {
+		if err := p.calendar.UpdateHold(calendarID, eventID, subject, note, start, end); err != nil {
+			p.logf("⚠️ Warning: Failed to update calendar hold %s for user %d: %v", eventID, userID, err)
+			return
+		}
+		p.logf("📅 Updated calendar hold %s for user %d to %s", eventID, userID, start.Format(time.RFC3339))
+		return
+	}
+	eventID, err = p.calendar.CreateHold(calendarID, booking, subject, note, start, end)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to create calendar hold for user %d: %v", userID, err)
+		return
+	}
+	p.logf("📅 Created calendar hold %s for user %d at %s", eventID, userID, start.Format(time.RFC3339))
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Actions when a rep is already working a lead
+const (
+	HumanActivitySkip  = "skip"
+	HumanActivityDefer = "defer"
+)
+
+// HumanActivityPolicy decides whether recent rep activity on a person stops the AI call
+type HumanActivityPolicy struct {
+	Hours      int      `json:"hours"`       // Look-back window; 0 disables the check
+	Action     string   `json:"action"`      // skip or defer
+	DeferHours int      `json:"defer_hours"` // How long a deferred call waits before checking again
+	Types      []string `json:"types"`       // Activity types that count as working the lead
+}
+
+// counts reports whether an activity type counts as working the lead
+func (h HumanActivityPolicy) counts(activityType string) bool {
+	for _, t := range h.Types {
+		if strings.EqualFold(t, activityType) {
+			return true
+		}
+	}
+	return false
+}
+
+// HumanActivityPolicyFor returns the tenant's policy, falling back to the HUMAN_ACTIVITY_* defaults
+func (c *Config) HumanActivityPolicyFor(tenant string) HumanActivityPolicy {
+	if policy, ok := c.TenantHumanActivity[tenant]; ok {
+		return policy
+	}
+	return c.HumanActivity
+}
+
+// loadTenantHumanActivity parses TENANT_HUMAN_ACTIVITY (JSON: tenant -> policy), filling unset fields from the default policy
+func loadTenantHumanActivity(raw string, defaults HumanActivityPolicy) map[string]HumanActivityPolicy {
+	policies := make(map[string]HumanActivityPolicy)
+	if raw == "" {
+		return policies
+	}
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		log.Printf("⚠️ Invalid TENANT_HUMAN_ACTIVITY, ignoring: %v", err)
+		return make(map[string]HumanActivityPolicy)
+	}
+	for tenant, policy := range policies {
+		if policy.Action == "" {
+			policy.Action = defaults.Action
+		}
+		if policy.DeferHours == 0 {
+			policy.DeferHours = defaults.DeferHours
+		}
+		if len(policy.Types) == 0 {
+			policy.Types = defaults.Types
+		}
+		policies[tenant] = policy
+	}
+	return policies
+}
+
+// APIUserCache keeps the ID of the Pipedrive user the API token belongs to, which creates all of
+// this service's activities
+type APIUserCache struct {
+	mu sync.Mutex
+	id int
+}
+
+// apiUserID returns the ID of the API token's Pipedrive user, looking it up until it is known
+func (p *PipedriveService) apiUserID() (int, error) {
+	p.apiUser.mu.Lock()
+	id := p.apiUser.id
+	p.apiUser.mu.Unlock()
+	if id != 0 {
+		return id, nil
+	}
+
+	resp, err := p.makePipedriveRequest("GET", "/users/me", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read user response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("failed to get API user: HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Success bool `json:"success"`
+		Data    struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, fmt.Errorf("failed to parse user response: %v", err)
+	}
+	if !result.Success || result.Data.ID == 0 {
+		return 0, fmt.Errorf("failed to get API user")
+	}
+	p.apiUser.mu.Lock()
+	p.apiUser.id = result.Data.ID
+	p.apiUser.mu.Unlock()
+	return result.Data.ID, nil
+}
+
+// isOwnActivity reports whether this service created an activity, i.e. its creator is the API user.
+// The subject is only matched when the creator is unknown
+func (p *PipedriveService) isOwnActivity(creatorID int, subject string) bool {
+	if creatorID != 0 {
+		apiUser, err := p.apiUserID()
+		if err == nil {
+			return creatorID == apiUser
+		}
+		p.debugf(SubsystemPipedrive, "Could not look up the API user, matching activity subjects: %v", err)
+	}
+	return isAutomatedActivity(subject)
+}
+
+// leadTenant returns the tenant the lead's webhook came from, or else the tenant of the agent
+// that would call it
+func (p *PipedriveService) leadTenant(payload PipedriveLeadWebhookPayload) string {
+	if payload.Tenant != "" {
+		return payload.Tenant
+	}
+	agentID := p.config.RetellAssistantID
+	if template, ok := p.campaignTemplate(payload); ok && template.AgentID != "" {
+		agentID = template.AgentID
+	}
+	if campaign, variants, ok := p.config.ExperimentFor(p.config.leadCampaign(payload)); ok {
+		agentID = assignVariant(payload.Data.ID, campaign, variants).AgentID
+	}
+	return p.config.TenantFor(agentID)
+}
+
+// recentHumanActivity returns the newest rep activity on the person within the policy window, if any
+func (p *PipedriveService) recentHumanActivity(personID int, policy HumanActivityPolicy) (*PipedriveActivity, error) {
+	activities, err := p.ListPersonActivities(personID)
+	if err != nil {
+		return nil, err
+	}
+	since := time.Now().Add(-time.Duration(policy.Hours) * time.Hour)
+	var newest *PipedriveActivity
+	var newestAt time.Time
+	for i, activity := range activities {
+		if p.isOwnActivity(activity.CreatorUserID, activity.Subject) || !policy.counts(activity.Type) {
+			continue
+		}
+		added, err := parsePipedriveTime(activity.AddTime)
+		if err != nil || !added.After(since) || !added.After(newestAt) {
+			continue
+		}
+		newest, newestAt = &activities[i], added
+	}
+	return newest, nil
+}
+
+// humanWorkingLead skips or defers the lead's call when a rep has recently called or emailed the
+// person; it reports whether the call was taken over
+func (p *PipedriveService) humanWorkingLead(payload PipedriveLeadWebhookPayload) bool {
+	policy := p.config.HumanActivityPolicyFor(p.leadTenant(payload))
+	if policy.Hours <= 0 {
+		return false
+	}
+	activity, err := p.recentHumanActivity(payload.Data.PersonID, policy)
+	if err != nil {
+		p.logf("⚠️ Warning: Could not check rep activity for person %d, dialing anyway: %v", payload.Data.PersonID, err)
+		return false
+	}
+	if activity == nil {
+		return false
+	}
+
+	detail := fmt.Sprintf("%s activity %d (%q) by user %d at %s", activity.Type, activity.ID, activity.Subject, activity.UserID, activity.AddTime)
+	if policy.Action == HumanActivityDefer && !payload.HumanDeferred {
+		delay := time.Duration(policy.DeferHours) * time.Hour
+		p.logf("🧑‍💼 Lead %s is being worked by a rep (%s), deferring the AI call by %s", payload.Data.ID, detail, delay)
+		p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "ai_call_deferred_rep_activity", PersonID: payload.Data.PersonID, Detail: detail})
+		payload.HumanDeferred = true
+		p.holdLeadCall(&payload, QueueReasonRepActivity, time.Now().Add(delay))
+		return true
+	}
+
+	p.logf("🧑‍💼 Lead %s is being worked by a rep (%s), skipping the AI call", payload.Data.ID, detail)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "ai_call_skipped_rep_activity", PersonID: payload.Data.PersonID, Detail: detail})
+	return true
+}
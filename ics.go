@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Meeting invite delivery modes (CAL_ICS_MODE)
+const (
+	ICSModeOff    = "off"
+	ICSModeAttach = "attach" // Upload the .ics to the activity through the Pipedrive Files API
+	ICSModeLink   = "link"   // Serve the .ics from this server and link it in the activity note
+)
+
+// invitesRetention is how long linked invites stay downloadable after the meeting ends
+const invitesRetention = 7 * 24 * time.Hour
+
+// MeetingInvite describes a meeting for an .ics calendar file
+type MeetingInvite struct {
+	UID         string
+	Title       string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Attendees   []CalAttendee
+}
+
+// CalAttendee is a Cal.com booking attendee
+type CalAttendee struct {
+	Email string
+	Name  string
+}
+
+// icsEscape escapes a TEXT value per RFC 5545
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// icsFold folds a content line at 75 octets without splitting UTF-8 sequences
+func icsFold(line string) string {
+	var out strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > 75 {
+			out.WriteString("\r\n ")
+			width = 1
+		}
+		out.WriteRune(r)
+		width += size
+	}
+	return out.String() + "\r\n"
+}
+
+// ICS renders the invite as an iCalendar file
+func (m MeetingInvite) ICS() []byte {
+	const stamp = "20060102T150405Z"
+	end := m.End
+	if !end.After(m.Start) {
+		end = m.Start.Add(30 * time.Minute)
+	}
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//PipCal//Meeting Invite//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"BEGIN:VEVENT",
+		"UID:" + m.UID,
+		"DTSTAMP:" + time.Now().UTC().Format(stamp),
+		"DTSTART:" + m.Start.UTC().Format(stamp),
+		"DTEND:" + end.UTC().Format(stamp),
+		"SUMMARY:" + icsEscape(m.Title),
+	}
+	if m.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+icsEscape(m.Description))
+	}
+	if m.Location != "" {
+		lines = append(lines, "LOCATION:"+icsEscape(m.Location))
+		if strings.HasPrefix(m.Location, "http://") || strings.HasPrefix(m.Location, "https://") {
+			lines = append(lines, "URL:"+m.Location)
+		}
+	}
+	for _, attendee := range m.Attendees {
+		if attendee.Email == "" {
+			continue
+		}
+		name := strings.NewReplacer(`"`, "", ";", "", ":", "").Replace(attendee.Name)
+		lines = append(lines, fmt.Sprintf(`ATTENDEE;CN="%s";ROLE=REQ-PARTICIPANT:mailto:%s`, name, attendee.Email))
+	}
+	lines = append(lines, "STATUS:CONFIRMED", "END:VEVENT", "END:VCALENDAR")
+
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString(icsFold(line))
+	}
+	return []byte(out.String())
+}
+
+// Filename returns a file name for the invite
+func (m MeetingInvite) Filename() string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '-'
+		}
+		return r
+	}, m.Title)
+	return fmt.Sprintf("%s %s.ics", m.Start.UTC().Format("2006-01-02"), truncateRunes(strings.TrimSpace(name), 60))
+}
+
+// calMeetingInvite builds the invite for a Cal.com booking
+func calMeetingInvite(payload CalWebhookPayload, description string, start, end time.Time) MeetingInvite {
+	uid := payload.Payload.UID
+	if uid == "" {
+		uid = strconv.Itoa(payload.Payload.ID)
+	}
+	invite := MeetingInvite{
+		UID:         "cal-" + uid + "@pipcal",
+		Title:       payload.Payload.Title,
+		Description: description,
+		Location:    payload.Payload.Location,
+		Start:       start,
+		End:         end,
+	}
+	for _, attendee := range payload.Payload.Attendees {
+		invite.Attendees = append(invite.Attendees, CalAttendee{Email: attendee.Email, Name: attendee.Name})
+	}
+	return invite
+}
+
+// linkedInvite is an invite served at /meetings/:token/invite.ics
+type linkedInvite struct {
+	Filename string    `json:"filename"`
+	ICS      []byte    `json:"ics"`
+	Expires  time.Time `json:"expires"`
+}
+
+// MeetingInviteStore keeps invites linked from activity notes, saved to MEETING_INVITES_FILE when
+// set so the links keep working after a restart; the token is the credential
+type MeetingInviteStore struct {
+	mu      sync.Mutex
+	path    string
+	invites map[string]linkedInvite
+}
+
+// NewMeetingInviteStore creates an invite store, loading the unexpired invites in the file at path
+func NewMeetingInviteStore(path string) *MeetingInviteStore {
+	store := &MeetingInviteStore{path: path, invites: make(map[string]linkedInvite)}
+	if path == "" {
+		return store
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read MEETING_INVITES_FILE %s: %v", path, err)
+		}
+		return store
+	}
+	if err := json.Unmarshal(data, &store.invites); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable MEETING_INVITES_FILE %s: %v", path, err)
+		store.invites = make(map[string]linkedInvite)
+		return store
+	}
+	now := time.Now()
+	for token, invite := range store.invites {
+		if now.After(invite.Expires) {
+			delete(store.invites, token)
+		}
+	}
+	log.Printf("📅 Loaded %d meeting invites from %s", len(store.invites), path)
+	return store
+}
+
+// saveLocked writes the invites to MEETING_INVITES_FILE; callers must hold mu
+func (s *MeetingInviteStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s.invites)
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save meeting invites: %v", err)
+	}
+}
+
+// Add stores an invite and returns its download token
+func (s *MeetingInviteStore) Add(invite MeetingInvite) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, existing := range s.invites {
+		if now.After(existing.Expires) {
+			delete(s.invites, key)
+		}
+	}
+	s.invites[token] = linkedInvite{Filename: invite.Filename(), ICS: invite.ICS(), Expires: invite.End.Add(invitesRetention)}
+	s.saveLocked()
+	return token, nil
+}
+
+// Get returns an unexpired invite
+func (s *MeetingInviteStore) Get(token string) (linkedInvite, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invite, ok := s.invites[token]
+	if !ok || time.Now().After(invite.Expires) {
+		return linkedInvite{}, false
+	}
+	return invite, true
+}
+
+// meetingInviteNote returns the note line linking a served invite, when CAL_ICS_MODE=link
+func (p *PipedriveService) meetingInviteNote(invite MeetingInvite) string {
+	if p.config.CalICSMode != ICSModeLink {
+		return ""
+	}
+	if p.config.PublicBaseURL == "" {
+		p.logf("⚠️ CAL_ICS_MODE=link requires PUBLIC_BASE_URL, not linking an invite")
+		return ""
+	}
+	token, err := p.invites.Add(invite)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to store meeting invite: %v", err)
+		return ""
+	}
+	return fmt.Sprintf("\nAdd to calendar: %s/meetings/%s/invite.ics", p.config.PublicBaseURL, token)
+}
+
+// attachMeetingInvite uploads the invite to the activity, when CAL_ICS_MODE=attach
+func (p *PipedriveService) attachMeetingInvite(invite MeetingInvite, activityID, personID int) {
+	if p.config.CalICSMode != ICSModeAttach {
+		return
+	}
+	fields := map[string]string{"activity_id": strconv.Itoa(activityID)}
+	if personID != 0 {
+		fields["person_id"] = strconv.Itoa(personID)
+	}
+	if err := p.uploadPipedriveFile(invite.Filename(), "text/calendar", invite.ICS(), fields); err != nil {
+		p.logf("⚠️ Warning: Failed to attach meeting invite to activity %d: %v", activityID, err)
+		return
+	}
+	p.logf("📅 Attached meeting invite to activity %d", activityID)
+}
+
+// pipedriveUpload is a multipart body makePipedriveRequest sends as is instead of as JSON
+type pipedriveUpload struct {
+	contentType string
+	data        []byte
+}
+
+// uploadPipedriveFile uploads a file through the Pipedrive Files API, linked to the given entities
+func (p *PipedriveService) uploadPipedriveFile(filename, contentType string, content []byte, fields map[string]string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {fmt.Sprintf(`form-data; name="file"; filename="%s"`, strings.ReplaceAll(filename, `"`, ""))},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		return err
+	}
+	part.Write(content)
+	writer.Close()
+
+	resp, err := p.makePipedriveRequest("POST", "/files", &pipedriveUpload{contentType: writer.FormDataContentType(), data: body.Bytes()})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("file upload failed: HTTP %d, Response: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// MeetingInviteHandler serves a linked .ics invite
+func MeetingInviteHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invite, ok := pipedriveService.invites.Get(c.Param("token"))
+		if !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Invite not found or expired",
+			})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(invite.Filename, `"`, "")+`"`)
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", invite.ICS)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxEmailNoteChars = 5000               // Caps how much of an email body goes into the lead note
+	emailDedupTTL     = 7 * 24 * time.Hour // How long a processed email is remembered; parse services retry for less
+)
+
+// InboundEmail is an email posted by an inbound parse service
+type InboundEmail struct {
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// messageIDHeaderPattern finds the Message-ID line in SendGrid's raw "headers" field
+var messageIDHeaderPattern = regexp.MustCompile(`(?im)^message-id:\s*(\S+)`)
+
+// htmlTagPattern matches HTML tags, for emails without a plain text part
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// parseInboundEmail reads the sender, subject and body from a SendGrid Inbound Parse or Mailgun route form
+func parseInboundEmail(c *gin.Context) (InboundEmail, error) {
+	var email InboundEmail
+
+	// SendGrid posts "from", Mailgun posts "from" and the bare "sender"
+	from := c.PostForm("from")
+	if from == "" {
+		from = c.PostForm("sender")
+	}
+	if address, err := mail.ParseAddress(from); err == nil {
+		email.Name = address.Name
+		email.Email = strings.ToLower(address.Address)
+	} else if sender := c.PostForm("sender"); strings.Contains(sender, "@") {
+		email.Email = strings.ToLower(strings.TrimSpace(sender))
+	} else {
+		return email, fmt.Errorf("missing or invalid sender %q", from)
+	}
+
+	email.Subject = strings.TrimSpace(c.PostForm("subject"))
+
+	// Mailgun posts "Message-Id", SendGrid only the raw headers
+	email.MessageID = strings.TrimSpace(c.PostForm("Message-Id"))
+	if email.MessageID == "" {
+		if match := messageIDHeaderPattern.FindStringSubmatch(c.PostForm("headers")); match != nil {
+			email.MessageID = match[1]
+		}
+	}
+
+	// Prefer the reply without quoted history (Mailgun), then plain text, then stripped HTML
+	for _, field := range []string{"stripped-text", "body-plain", "text"} {
+		if body := strings.TrimSpace(c.PostForm(field)); body != "" {
+			email.Body = body
+			break
+		}
+	}
+	if email.Body == "" {
+		for _, field := range []string{"body-html", "html"} {
+			if body := strings.TrimSpace(c.PostForm(field)); body != "" {
+				email.Body = strings.TrimSpace(htmlTagPattern.ReplaceAllString(body, " "))
+				break
+			}
+		}
+	}
+	return email, nil
+}
+
+// dedupKey identifies an email across parse service retries: its Message-ID, or a hash of its content
+func (e InboundEmail) dedupKey() string {
+	if e.MessageID != "" {
+		return "id:" + strings.ToLower(strings.Trim(e.MessageID, "<>"))
+	}
+	sum := sha256.Sum256([]byte(e.Email + "\x00" + e.Subject + "\x00" + e.Body))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// inboundLead turns an email into an inbound lead. The sender is not verified, so the lead is never
+// called: a phone number in the body only goes into the note
+func (e InboundEmail) inboundLead() InboundLead {
+	title := e.Subject
+	if title == "" {
+		title = "Email from " + e.Email
+	}
+	body := e.Body
+	if len(body) > maxEmailNoteChars {
+		body = truncateRunes(body, maxEmailNoteChars) + "\n[truncated]"
+	}
+	note := fmt.Sprintf("Inbound email from %s\nSubject: %s\n\n%s", e.Email, e.Subject, body)
+	if phone := findPhoneInText(e.Body); phone != "" {
+		note += "\n\nPhone number mentioned (not verified): " + phone
+	}
+	return InboundLead{
+		Source: "email",
+		Name:   e.Name,
+		Email:  e.Email,
+		Title:  title,
+		Note:   note,
+	}
+}
+
+// EmailDedupStore remembers processed inbound emails, saved to EMAIL_DEDUP_FILE when set
+type EmailDedupStore struct {
+	mu     sync.Mutex
+	path   string
+	emails map[string]time.Time // Dedup key -> processed at
+}
+
+// NewEmailDedupStore creates an email dedup store, loading EMAIL_DEDUP_FILE when set
+func NewEmailDedupStore(config *Config) *EmailDedupStore {
+	store := &EmailDedupStore{path: config.EmailDedupFile, emails: make(map[string]time.Time)}
+	if store.path == "" {
+		return store
+	}
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read EMAIL_DEDUP_FILE %s: %v", store.path, err)
+		}
+		return store
+	}
+	if err := json.Unmarshal(data, &store.emails); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable EMAIL_DEDUP_FILE %s: %v", store.path, err)
+		store.emails = make(map[string]time.Time)
+	}
+	return store
+}
+
+// Mark records an email as processed; false if it already was within emailDedupTTL
+func (s *EmailDedupStore) Mark(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for existing, at := range s.emails {
+		if now.Sub(at) > emailDedupTTL {
+			delete(s.emails, existing)
+		}
+	}
+	if _, ok := s.emails[key]; ok {
+		return false
+	}
+	s.emails[key] = now
+	s.saveLocked()
+	return true
+}
+
+// Forget drops an email whose processing failed so the parse service's retry is handled
+func (s *EmailDedupStore) Forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.emails, key)
+	s.saveLocked()
+}
+
+// saveLocked rewrites EMAIL_DEDUP_FILE; callers hold mu
+func (s *EmailDedupStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s.emails)
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save processed emails: %v", err)
+	}
+}
+
+// EmailWebhookHandler creates leads from inbound emails (SendGrid Inbound Parse or Mailgun routes)
+func EmailWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
+		config := pipedriveService.config
+		email, err := parseInboundEmail(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid inbound email: " + err.Error(),
+			})
+			return
+		}
+		if !config.HasPipedriveConfig() {
+			pipedriveService.logf("⚠️ Configuration missing - ignoring inbound email from %s", email.Email)
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "Pipedrive not configured, email ignored",
+			})
+			return
+		}
+
+		// Parse services retry until they get a 2xx; each email creates one lead
+		key := email.dedupKey()
+		release, ok := pipedriveService.claim("email:" + key)
+		if !ok || !pipedriveService.emailDedup.Mark(key, time.Now()) {
+			pipedriveService.logf("ℹ️ Inbound email from %s was already processed, skipping", email.Email)
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "Inbound email already processed",
+			})
+			return
+		}
+
+		lead := email.inboundLead()
+		lead.Attribution = attributionFromRequest(c)
+		result, err := pipedriveService.intakeLead(lead)
+		if err != nil {
+			pipedriveService.emailDedup.Forget(key)
+			release()
+			pipedriveService.logf("❌ Failed to create lead from email %s: %v", email.Email, err)
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: "Failed to create lead: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Inbound email processed successfully",
+			Data:    result,
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// intakeLeadTTL is how long a lead created by intake is remembered, so Pipedrive's echo of it is not dialed again
+const intakeLeadTTL = time.Hour
+
+// InboundLead is a lead arriving from outside Pipedrive, e.g. an email or a form
+type InboundLead struct {
+	Source       string                 // Where the lead came from, e.g. "email"
+	Name         string                 // Person name; derived from the email when empty
+	Email        string                 // Email or phone is required
+	Phone        string                 // E.164 where possible
+	Title        string                 // Lead title
+	Note         string                 // Added to the lead as a note
+	PersonFields map[string]interface{} // Extra person fields by Pipedrive key
+	LeadFields   map[string]interface{} // Extra lead fields by Pipedrive key
+	Attribution  Attribution            // UTM parameters and referrer
+	AutoCall     bool                   // Run the lead through the auto-call flow
+}
+
+// IntakeResult is what intake did with an inbound lead
+type IntakeResult struct {
+	PersonID      int    `json:"person_id"`
+	PersonCreated bool   `json:"person_created"`
+	LeadID        string `json:"lead_id"`
+	CallQueued    bool   `json:"call_queued"`
+	ProcessingID  string `json:"processing_id,omitempty"`
+}
+
+// IntakeTracker remembers recently created leads
+type IntakeTracker struct {
+	mu    sync.Mutex
+	leads map[string]time.Time // Lead ID -> created at
+}
+
+// NewIntakeTracker creates an intake tracker
+func NewIntakeTracker() *IntakeTracker {
+	return &IntakeTracker{leads: make(map[string]time.Time)}
+}
+
+// Add remembers a lead created by intake
+func (t *IntakeTracker) Add(leadID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for id, createdAt := range t.leads {
+		if now.Sub(createdAt) > intakeLeadTTL {
+			delete(t.leads, id)
+		}
+	}
+	t.leads[leadID] = now
+}
+
+// Owns returns true if a lead was recently created by intake
+func (t *IntakeTracker) Owns(leadID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	createdAt, ok := t.leads[leadID]
+	return ok && time.Since(createdAt) <= intakeLeadTTL
+}
+
+// Phone-number-like runs in free text, and dates that look like them
+var (
+	phoneInTextPattern = regexp.MustCompile(`\+?\d[\d\s().-]{7,}\d`)
+	datePrefixPattern  = regexp.MustCompile(`^\d{4}[-/.]\d{1,2}[-/.]\d{1,2}`)
+)
+
+// findPhoneInText returns the first plausible phone number in free text, digits only with any leading +
+func findPhoneInText(text string) string {
+	for _, match := range phoneInTextPattern.FindAllString(text, -1) {
+		if datePrefixPattern.MatchString(match) {
+			continue
+		}
+		var digits strings.Builder
+		for i, r := range match {
+			if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+				digits.WriteRune(r)
+			}
+		}
+		phone := digits.String()
+		count := len(strings.TrimPrefix(phone, "+"))
+		if count >= 10 && count <= 15 {
+			return phone
+		}
+	}
+	return ""
+}
+
+// findOrCreatePerson finds a person by email, then phone, creating one when there is no match
+func (p *PipedriveService) findOrCreatePerson(lead InboundLead) (*PipedrivePerson, bool, error) {
+	searches := []struct{ field, term string }{
+		{"email", lead.Email},
+		{"phone", lead.Phone},
+	}
+	for _, search := range searches {
+		if search.term == "" {
+			continue
+		}
+		persons, err := p.SearchPersons(search.field, search.term)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(persons) > 0 {
+			// Search results carry no phone list, load the full person
+			person, err := p.GetPersonByID(persons[0].ID)
+			return person, false, err
+		}
+	}
+
+	personData := map[string]interface{}{
+		"name": lead.Name,
+	}
+	if lead.Email != "" {
+		personData["email"] = []map[string]interface{}{{"value": lead.Email, "primary": true}}
+	}
+	if lead.Phone != "" {
+		personData["phone"] = []map[string]interface{}{{"value": lead.Phone, "primary": true}}
+	}
+	for key, value := range lead.PersonFields {
+		personData[key] = value
+	}
+	resp, err := p.makePipedriveRequest("POST", "/persons", personData)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create person: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read person response: %v", err)
+	}
+	var result PipedrivePersonResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, false, fmt.Errorf("failed to parse person response: %v", err)
+	}
+	if !result.Success || result.Data == nil {
+		return nil, false, fmt.Errorf("failed to create person: HTTP %d", resp.StatusCode)
+	}
+	return result.Data, true, nil
+}
+
+// updateIntakePerson adds a missing phone and any mapped fields to an existing person
+func (p *PipedriveService) updateIntakePerson(person *PipedrivePerson, lead InboundLead) error {
+	update := make(map[string]interface{})
+	if lead.Phone != "" && p.extractPhoneFromPerson(person) == "" {
+		update["phone"] = []map[string]interface{}{{"value": lead.Phone, "primary": true}}
+	}
+	for key, value := range lead.PersonFields {
+		update[key] = value
+	}
+	if len(update) == 0 {
+		return nil
+	}
+	resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", person.ID), update)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("failed to update person %d: HTTP %d", person.ID, resp.StatusCode)
+	}
+	return nil
+}
+
+// dialsIntakePhone reports whether an existing person would be called on the phone number the lead
+// submitted, or on none yet (updateIntakePerson then adds it)
+func (p *PipedriveService) dialsIntakePhone(person *PipedrivePerson, lead InboundLead) bool {
+	if lead.Phone == "" {
+		return false
+	}
+	existing := p.extractPhoneFromPerson(person)
+	if existing == "" {
+		return true
+	}
+	countryCode := p.config.PhoneCountryCodeFor(DefaultTenant)
+	submitted, _ := normalizeE164(lead.Phone, countryCode)
+	dialed, _ := normalizeE164(existing, countryCode)
+	return submitted != "" && submitted == dialed
+}
+
+// intakeLead creates or updates the person, creates the lead and optionally queues the AI call
+func (p *PipedriveService) intakeLead(lead InboundLead) (IntakeResult, error) {
+	var result IntakeResult
+	if lead.Email == "" && lead.Phone == "" {
+		return result, fmt.Errorf("an email or phone number is required")
+	}
+	if lead.Name == "" {
+		lead.Name = strings.Split(lead.Email, "@")[0]
+		if lead.Name == "" {
+			lead.Name = lead.Phone
+		}
+	}
+
+	// Attribution goes into the mapped fields of the records about to be written
+	lead.Attribution.sanitize()
+	if len(lead.Attribution) > 0 {
+		personFields, leadFields := p.config.attributionFields(lead.Attribution)
+		if lead.PersonFields == nil {
+			lead.PersonFields = make(map[string]interface{})
+		}
+		if lead.LeadFields == nil {
+			lead.LeadFields = make(map[string]interface{})
+		}
+		for key, value := range personFields {
+			lead.PersonFields[key] = value
+		}
+		for key, value := range leadFields {
+			lead.LeadFields[key] = value
+		}
+		lead.Note += attributionNoteSection(lead.Attribution)
+	}
+
+	person, created, err := p.findOrCreatePerson(lead)
+	if err != nil {
+		return result, err
+	}
+	result.PersonID = person.ID
+	result.PersonCreated = created
+	if !created {
+		if err := p.updateIntakePerson(person, lead); err != nil {
+			p.logf("⚠️ Warning: Failed to update person %d from %s lead: %v", person.ID, lead.Source, err)
+		}
+	}
+
+	newLead, err := p.CreateLead(lead.Title, person.ID, lead.LeadFields)
+	if err != nil {
+		return result, err
+	}
+	result.LeadID = newLead.ID
+	p.intake.Add(newLead.ID)
+	p.attributions.Set(newLead.ID, lead.Attribution)
+	p.logf("📥 Created lead %s for person %d from %s", newLead.ID, person.ID, lead.Source)
+
+	if lead.Note != "" {
+		noteData := map[string]interface{}{
+			"content": lead.Note,
+			"lead_id": newLead.ID,
+		}
+		if resp, err := p.makePipedriveRequest("POST", "/notes", noteData); err != nil {
+			p.logf("⚠️ Warning: Failed to add %s note to lead %s: %v", lead.Source, newLead.ID, err)
+		} else {
+			resp.Body.Close()
+		}
+	}
+
+	if !lead.AutoCall {
+		return result, nil
+	}
+	// A matched email proves nothing about the submitter: only call a number they gave us
+	if !created && !p.dialsIntakePhone(person, lead) {
+		p.logf("ℹ️ Person %d matched a %s lead but not its phone number, not calling", person.ID, lead.Source)
+		return result, nil
+	}
+	var payload PipedriveLeadWebhookPayload
+	payload.Data.ID = newLead.ID
+	payload.Data.Title = lead.Title
+	payload.Data.PersonID = person.ID
+	payload.Data.OwnerID = newLead.OwnerID
+	payload.Data.CustomFields = lead.LeadFields
+	payload.Meta.Action = "create"
+	payload.Intake = lead.Source
+	job, err := p.processing.Run("lead", func() error { return p.ProcessPipedriveLead(payload) })
+	if err != nil {
+		p.logf("⚠️ Warning: Could not queue call for %s lead %s: %v", lead.Source, newLead.ID, err)
+		return result, nil
+	}
+	result.CallQueued = true
+	result.ProcessingID = job.ID
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// TranscriptUtterance is one turn of Retell's transcript_object with word-level timestamps
+type TranscriptUtterance struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Words   []struct {
+		Word  string  `json:"word"`
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	} `json:"words"`
+}
+
+// KeyMoment is a notable point in a call
+type KeyMoment struct {
+	Label   string  `json:"label"`
+	Seconds float64 `json:"seconds"`
+	Quote   string  `json:"quote,omitempty"`
+}
+
+// defaultKeyMomentKeywords is used when KEY_MOMENT_KEYWORDS is not set
+var defaultKeyMomentKeywords = map[string][]string{
+	"Objection raised": {"too expensive", "not interested", "not sure", "already have", "no budget"},
+	"Price discussed":  {"price", "cost", "pricing", "how much"},
+	"Meeting agreed":   {"book a meeting", "schedule a call", "sounds good", "let's meet", "calendar invite"},
+}
+
+// loadKeyMomentKeywords parses the KEY_MOMENT_KEYWORDS JSON (label -> phrases)
+func loadKeyMomentKeywords(raw string) map[string][]string {
+	if raw == "" {
+		return defaultKeyMomentKeywords
+	}
+	keywords := make(map[string][]string)
+	if err := json.Unmarshal([]byte(raw), &keywords); err != nil {
+		log.Printf("⚠️ Invalid KEY_MOMENT_KEYWORDS, using defaults: %v", err)
+		return defaultKeyMomentKeywords
+	}
+	return keywords
+}
+
+// extractKeyMoments finds keyword matches in the transcript, using the first word's timestamp
+func extractKeyMoments(utterances []TranscriptUtterance, keywords map[string][]string) []KeyMoment {
+	var moments []KeyMoment
+	seen := make(map[string]bool)
+
+	for _, utterance := range utterances {
+		if len(utterance.Words) == 0 {
+			continue
+		}
+		content := strings.ToLower(utterance.Content)
+		for label, phrases := range keywords {
+			if seen[label] {
+				continue
+			}
+			for _, phrase := range phrases {
+				if strings.Contains(content, strings.ToLower(phrase)) {
+					moments = append(moments, KeyMoment{
+						Label:   label,
+						Seconds: utterance.Words[0].Start,
+						Quote:   utterance.Content,
+					})
+					seen[label] = true
+					break
+				}
+			}
+		}
+	}
+
+	sort.Slice(moments, func(i, j int) bool { return moments[i].Seconds < moments[j].Seconds })
+	return moments
+}
+
+// extractKeyMomentsWithLLM asks the LLM to pick key moments from a timestamped transcript
+func (p *PipedriveService) extractKeyMomentsWithLLM(utterances []TranscriptUtterance) ([]KeyMoment, error) {
+	var transcript strings.Builder
+	for _, utterance := range utterances {
+		if len(utterance.Words) == 0 {
+			continue
+		}
+		fmt.Fprintf(&transcript, "[%.1f] %s: %s\n", utterance.Words[0].Start, utterance.Role, utterance.Content)
+	}
+
+	reply, err := p.llm.Complete(
+		`Identify notable moments in this sales call (objection raised, price discussed, meeting agreed, competitor mentioned). `+
+			`Reply with only a JSON array of {"label": string, "seconds": number, "quote": string} using the bracketed timestamps.`,
+		transcript.String())
+	if err != nil {
+		return nil, err
+	}
+
+	reply = strings.TrimSpace(reply)
+	reply = strings.TrimPrefix(reply, "```json")
+	reply = strings.TrimPrefix(reply, "```")
+	reply = strings.TrimSuffix(reply, "```")
+
+	var moments []KeyMoment
+	if err := json.Unmarshal([]byte(strings.TrimSpace(reply)), &moments); err != nil {
+		return nil, fmt.Errorf("failed to parse key moments: %v", err)
+	}
+	sort.Slice(moments, func(i, j int) bool { return moments[i].Seconds < moments[j].Seconds })
+	return moments, nil
+}
+
+// keyMomentsNoteSection renders key moments as deep links into the recording
+func (p *PipedriveService) keyMomentsNoteSection(utterances []TranscriptUtterance, recordingURL string) string {
+	if len(utterances) == 0 {
+		return ""
+	}
+
+	var moments []KeyMoment
+	if p.llm != nil && p.config.KeyMomentsLLM {
+		llmMoments, err := p.extractKeyMomentsWithLLM(utterances)
+		if err != nil {
+			p.logf("⚠️ Warning: LLM key moment extraction failed, using keywords: %v", err)
+		} else {
+			moments = llmMoments
+		}
+	}
+	if moments == nil {
+		moments = extractKeyMoments(utterances, p.config.KeyMomentKeywords)
+	}
+	if len(moments) == 0 {
+		return ""
+	}
+
+	var section strings.Builder
+	section.WriteString("\n\nKey Moments:")
+	for _, moment := range moments {
+		seconds := int(moment.Seconds)
+		fmt.Fprintf(&section, "\n• %02d:%02d %s", seconds/60, seconds%60, moment.Label)
+		if recordingURL != "" {
+			fmt.Fprintf(&section, " - %s#t=%d", recordingURL, seconds)
+		}
+	}
+	return section.String()
+}
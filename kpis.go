@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Outcomes of analyzed calls, kept on their call mapping
+const (
+	CallOutcomeSuccessful   = "successful"
+	CallOutcomeVoicemail    = "voicemail"
+	CallOutcomeUnsuccessful = "unsuccessful"
+)
+
+// kpiSubjectPrefix starts the subject of the daily KPI activity, which identifies an earlier write-back
+const kpiSubjectPrefix = "AI SDR daily:"
+
+// DailyKPIs are the AI SDR's totals for one day
+type DailyKPIs struct {
+	Date           string `json:"date"`
+	CallsPlaced    int    `json:"calls_placed"`
+	CallsAnswered  int    `json:"calls_answered"`
+	MeetingsBooked int    `json:"meetings_booked"`
+}
+
+// KPITracker totals AI activity per day in the configured time zone. The totals are counted from
+// the shared call mappings, one per call, so every replica reports the same numbers and replayed
+// webhooks are not counted twice.
+type KPITracker struct {
+	location *time.Location
+}
+
+// NewKPITracker creates a KPI tracker
+func NewKPITracker(config *Config) *KPITracker {
+	location, err := time.LoadLocation(config.KPITimeZone)
+	if err != nil {
+		log.Printf("⚠️ Invalid KPI_TIME_ZONE %q, using UTC: %v", config.KPITimeZone, err)
+		location = time.UTC
+	}
+	return &KPITracker{location: location}
+}
+
+// tally counts calls placed and answered on the day they were placed and meetings on the day they
+// were booked
+func (k *KPITracker) tally(mappings map[string]CallMapping) map[string]*DailyKPIs {
+	days := make(map[string]*DailyKPIs)
+	day := func(t time.Time) *DailyKPIs {
+		date := t.In(k.location).Format("2006-01-02")
+		if _, ok := days[date]; !ok {
+			days[date] = &DailyKPIs{Date: date}
+		}
+		return days[date]
+	}
+	for callID, mapping := range mappings {
+		if strings.HasPrefix(callID, "failed-") {
+			continue
+		}
+		day(mapping.Timestamp).CallsPlaced++
+		if mapping.Answered {
+			day(mapping.Timestamp).CallsAnswered++
+		}
+		if mapping.MeetingBookedAt != nil {
+			day(*mapping.MeetingBookedAt).MeetingsBooked++
+		}
+	}
+	return days
+}
+
+// KPIDay returns one day's totals
+func (p *PipedriveService) KPIDay(date string) DailyKPIs {
+	if day, ok := p.kpis.tally(p.listCallMappings())[date]; ok {
+		return *day
+	}
+	return DailyKPIs{Date: date}
+}
+
+// KPIReport returns the totals of every day with stored calls, most recent first; days go as
+// their call mappings expire (CALL_MAPPING_TTL_DAYS)
+func (p *PipedriveService) KPIReport() []DailyKPIs {
+	days := p.kpis.tally(p.listCallMappings())
+	report := make([]DailyKPIs, 0, len(days))
+	for _, day := range days {
+		report = append(report, *day)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Date > report[j].Date })
+	return report
+}
+
+// recordCallOutcome keeps an analyzed call's outcome on its mapping. Setting the same outcome
+// again on a replay changes nothing, so a replay counts a call that failed the first time once.
+func (p *PipedriveService) recordCallOutcome(payload RetellCallAnalyzedPayload) {
+	analysis := payload.Call.CallAnalysis
+	outcome := CallOutcomeUnsuccessful
+	switch {
+	case analysis.InVoicemail:
+		outcome = CallOutcomeVoicemail
+	case analysis.CallSuccessful:
+		outcome = CallOutcomeSuccessful
+	}
+	p.updateCallMapping(payload.Call.CallID, func(m *CallMapping) {
+		m.Answered = callAnswered(payload)
+		m.Outcome = outcome
+		m.Summary = truncateRunes(analysis.CallSummary, 200)
+	})
+}
+
+// recordMeetingBooked credits a Cal.com booking to the person's latest AI call; bookings by
+// people the AI has not called are not counted
+func (p *PipedriveService) recordMeetingBooked(personID int, bookedAt time.Time) {
+	var latestID string
+	var latest CallMapping
+	for callID, mapping := range p.listCallMappings() {
+		if mapping.PersonID != personID || strings.HasPrefix(callID, "failed-") || mapping.Timestamp.After(bookedAt) {
+			continue
+		}
+		if latestID == "" || mapping.Timestamp.After(latest.Timestamp) {
+			latestID, latest = callID, mapping
+		}
+	}
+	if latestID == "" {
+		p.debugf(SubsystemCal, "Person %d has no AI call, not counting the booking", personID)
+		return
+	}
+	p.updateCallMapping(latestID, func(m *CallMapping) {
+		if m.MeetingBookedAt == nil {
+			m.MeetingBookedAt = &bookedAt
+		}
+	})
+}
+
+// findKPIActivity returns the activity an earlier write-back logged for a day, or nil
+func (p *PipedriveService) findKPIActivity(date string) (*PipedriveActivity, error) {
+	userID := p.config.KPIUserID
+	if userID == 0 {
+		var err error
+		if userID, err = p.apiUserID(); err != nil {
+			return nil, err
+		}
+	}
+	items, err := p.listPipedriveItems(fmt.Sprintf("/activities?user_id=%d&start_date=%s&end_date=%s&done=1", userID, date, date))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		var activity PipedriveActivity
+		if err := json.Unmarshal(item, &activity); err == nil && strings.HasPrefix(activity.Subject, kpiSubjectPrefix) && activity.DueDate == date {
+			return &activity, nil
+		}
+	}
+	return nil, nil
+}
+
+// writeBackKPIs logs a day's totals as a done activity on the AI SDR user so they show in Pipedrive
+// reports; a day already written back has its activity updated rather than logged again
+func (p *PipedriveService) writeBackKPIs(date string) (DailyKPIs, error) {
+	release, ok := p.claim("kpis:" + date)
+	if !ok {
+		return DailyKPIs{}, fmt.Errorf("KPIs for %s are being written back elsewhere", date)
+	}
+	defer release()
+
+	day := p.KPIDay(date)
+	activityData := map[string]interface{}{
+		"subject":  fmt.Sprintf("%s %d calls, %d meetings booked", kpiSubjectPrefix, day.CallsPlaced, day.MeetingsBooked),
+		"type":     p.config.KPIActivityType,
+		"note":     fmt.Sprintf("AI activity for %s\nCalls placed: %d\nCalls answered: %d\nMeetings booked: %d", date, day.CallsPlaced, day.CallsAnswered, day.MeetingsBooked),
+		"done":     1,
+		"due_date": date,
+	}
+	if p.config.KPIUserID != 0 {
+		activityData["user_id"] = p.config.KPIUserID
+	}
+
+	existing, err := p.findKPIActivity(date)
+	if err != nil {
+		return day, fmt.Errorf("failed to look up earlier KPI write-back for %s: %v", date, err)
+	}
+	if existing != nil {
+		if err := p.updateActivity(existing.ID, activityData); err != nil {
+			return day, fmt.Errorf("failed to update KPIs for %s: %v", date, err)
+		}
+		p.logf("📊 Updated AI KPIs for %s in activity %d (%d calls, %d meetings)", date, existing.ID, day.CallsPlaced, day.MeetingsBooked)
+		return day, nil
+	}
+
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		return day, fmt.Errorf("failed to write back KPIs for %s: %v", date, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return day, fmt.Errorf("failed to write back KPIs for %s: HTTP %d", date, resp.StatusCode)
+	}
+	p.logf("📊 Wrote back AI KPIs for %s (%d calls, %d meetings)", date, day.CallsPlaced, day.MeetingsBooked)
+	return day, nil
+}
+
+// runKPIWriteBack writes back the previous day's KPIs every day at KPI_WRITEBACK_HOUR
+func (p *PipedriveService) runKPIWriteBack() {
+	runDaily(p.config.KPIWriteBackHour, p.kpis.location, func(date string) {
+		if _, err := p.writeBackKPIs(date); err != nil {
+			p.logf("⚠️ Warning: %v", err)
+		}
+	})
+}
+
+// KPIReportHandler reports daily AI KPIs
+func KPIReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "AI KPIs by day",
+			Data:    pipedriveService.KPIReport(),
+		})
+	}
+}
+
+// KPIWriteBackHandler writes back one day's KPIs now (?date=2006-01-02, default today)
+func KPIWriteBackHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		date := c.DefaultQuery("date", time.Now().In(pipedriveService.kpis.location).Format("2006-01-02"))
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid date, expected YYYY-MM-DD",
+			})
+			return
+		}
+		day, err := pipedriveService.writeBackKPIs(date)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "KPIs written back",
+			Data:    day,
+		})
+	}
+}
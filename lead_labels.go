@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// leadLabelsCacheTTL is how long the account's lead labels are reused before a miss refetches them
+const leadLabelsCacheTTL = 10 * time.Minute
+
+// leadLabelsMissRefetch is the minimum age of the cached labels before an unknown name refetches them
+const leadLabelsMissRefetch = time.Minute
+
+// Colors Pipedrive accepts for lead labels
+var leadLabelColors = []string{"green", "blue", "red", "yellow", "purple", "gray"}
+
+// validLeadLabelColor reports whether Pipedrive accepts the color for a lead label
+func validLeadLabelColor(color string) bool {
+	for _, valid := range leadLabelColors {
+		if color == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// PipedriveLeadLabel is a lead label as returned by the Pipedrive lead labels API
+type PipedriveLeadLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// LeadLabelCache keeps the account's lead labels so names in the config resolve to IDs
+type LeadLabelCache struct {
+	mu        sync.Mutex
+	labels    []PipedriveLeadLabel
+	fetchedAt time.Time
+}
+
+// ListLeadLabels returns all lead labels of the Pipedrive account
+func (p *PipedriveService) ListLeadLabels() ([]PipedriveLeadLabel, error) {
+	resp, err := p.makePipedriveRequest("GET", "/leadLabels", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lead labels response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to list lead labels: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool                 `json:"success"`
+		Data    []PipedriveLeadLabel `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse lead labels response: %v", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list lead labels")
+	}
+	return result.Data, nil
+}
+
+// CreateLeadLabel creates a lead label; color is one of leadLabelColors (default gray)
+func (p *PipedriveService) CreateLeadLabel(name, color string) (*PipedriveLeadLabel, error) {
+	if color == "" {
+		color = "gray"
+	}
+	resp, err := p.makePipedriveRequest("POST", "/leadLabels", map[string]interface{}{
+		"name":  name,
+		"color": color,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lead label response: %v", err)
+	}
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		return nil, fmt.Errorf("failed to create lead label %q: HTTP %d, Response: %s", name, resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Success bool                `json:"success"`
+		Data    *PipedriveLeadLabel `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse lead label response: %v", err)
+	}
+	if !result.Success || result.Data == nil {
+		return nil, fmt.Errorf("failed to create lead label %q", name)
+	}
+
+	cache := p.leadLabels
+	cache.mu.Lock()
+	if cache.labels != nil {
+		cache.labels = append(cache.labels, *result.Data)
+	}
+	cache.mu.Unlock()
+	return result.Data, nil
+}
+
+// findLeadLabel looks a label up by name, case-insensitive; the cached list is refetched when it is
+// stale or, at most once a minute, when it does not have the name, so labels added in Pipedrive
+// are picked up without a restart
+func (p *PipedriveService) findLeadLabel(name string) (*PipedriveLeadLabel, error) {
+	cache := p.leadLabels
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.labels != nil && time.Since(cache.fetchedAt) < leadLabelsCacheTTL {
+		if label := leadLabelNamed(cache.labels, name); label != nil || time.Since(cache.fetchedAt) < leadLabelsMissRefetch {
+			return label, nil
+		}
+	}
+	labels, err := p.ListLeadLabels()
+	if err != nil {
+		return nil, err
+	}
+	cache.labels, cache.fetchedAt = labels, time.Now()
+	return leadLabelNamed(labels, name), nil
+}
+
+// leadLabelNamed returns the label with the given name, or nil
+func leadLabelNamed(labels []PipedriveLeadLabel, name string) *PipedriveLeadLabel {
+	name = strings.TrimSpace(name)
+	for i := range labels {
+		if strings.EqualFold(strings.TrimSpace(labels[i].Name), name) {
+			return &labels[i]
+		}
+	}
+	return nil
+}
+
+// leadLabelID resolves a label name to its ID in this account, creating the label when
+// LEAD_LABELS_AUTO_CREATE is set; it returns "" when the label cannot be resolved
+func (p *PipedriveService) leadLabelID(name string) string {
+	if name == "" || !p.config.HasPipedriveConfig() {
+		return ""
+	}
+	label, err := p.findLeadLabel(name)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to resolve lead label %q: %v", name, err)
+		return ""
+	}
+	if label != nil {
+		return label.ID
+	}
+	if !p.config.LeadLabelsAutoCreate {
+		p.logf("⚠️ Lead label %q does not exist in Pipedrive; create it or set LEAD_LABELS_AUTO_CREATE=true", name)
+		return ""
+	}
+	label, err = p.CreateLeadLabel(name, "")
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to create lead label %q: %v", name, err)
+		return ""
+	}
+	p.logf("🏷️ Created lead label %q (%s)", name, label.ID)
+	return label.ID
+}
+
+// leadLabelIDs resolves label names to IDs, skipping names that cannot be resolved
+func (p *PipedriveService) leadLabelIDs(names []string) []string {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		if id := p.leadLabelID(name); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// leadContactedLabelID returns LEAD_CONTACTED_LABEL_ID, else the ID of LEAD_CONTACTED_LABEL
+func (p *PipedriveService) leadContactedLabelID() string {
+	if p.config.LeadContactedLabelID != "" {
+		return p.config.LeadContactedLabelID
+	}
+	return p.leadLabelID(p.config.LeadContactedLabel)
+}
+
+// configuredLeadLabels lists the label names used by the config
+func (c *Config) configuredLeadLabels() []string {
+	var names []string
+	if c.LeadContactedLabel != "" && c.LeadContactedLabelID == "" {
+		names = append(names, c.LeadContactedLabel)
+	}
+	for _, rule := range c.FromNumberRules {
+		names = append(names, rule.Labels...)
+	}
+	return names
+}
+
+// resolveLeadLabels resolves (and with LEAD_LABELS_AUTO_CREATE creates) every label named in the
+// config, so a missing label is reported at startup rather than on the first lead
+func (p *PipedriveService) resolveLeadLabels() {
+	names := p.config.configuredLeadLabels()
+	if len(names) == 0 || !p.config.HasPipedriveConfig() {
+		return
+	}
+	resolved := 0
+	for _, name := range names {
+		if id := p.leadLabelID(name); id != "" {
+			resolved++
+		}
+	}
+	p.logf("🏷️ Resolved %d of %d configured lead labels for company %s", resolved, len(names), p.config.PipedriveCompanyID)
+}
+
+// ListLeadLabelsHandler lists the account's lead labels
+func ListLeadLabelsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		labels, err := pipedriveService.ListLeadLabels()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to list lead labels: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d lead labels", len(labels)),
+			Data:    labels,
+		})
+	}
+}
+
+// CreateLeadLabelHandler creates a lead label ({"name", "color"}), returning the existing one
+// when a label with that name already exists
+func CreateLeadLabelHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "name is required",
+			})
+			return
+		}
+		if request.Color != "" && !validLeadLabelColor(request.Color) {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "color must be one of " + strings.Join(leadLabelColors, ", "),
+			})
+			return
+		}
+
+		existing, err := pipedriveService.findLeadLabel(request.Name)
+		if err == nil && existing != nil {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: fmt.Sprintf("Lead label %q already exists", existing.Name),
+				Data:    existing,
+			})
+			return
+		}
+		label, err := pipedriveService.CreateLeadLabel(strings.TrimSpace(request.Name), request.Color)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to create lead label: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusCreated, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Lead label %q created", label.Name),
+			Data:    label,
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PipedriveLead is a lead as returned by the Pipedrive leads API
+type PipedriveLead struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	LabelIDs   []string `json:"label_ids"`
+	IsArchived bool     `json:"is_archived"`
+	WasSeen    bool     `json:"was_seen"`
+	PersonID   int      `json:"person_id"`
+	OwnerID    int      `json:"owner_id"`
+	SourceName string   `json:"source_name"`
+	Value      *struct {
+		Amount   float64 `json:"amount"`
+		Currency string  `json:"currency"`
+	} `json:"value"`
+}
+
+// GetLead fetches a lead by ID
+func (p *PipedriveService) GetLead(leadID string) (*PipedriveLead, error) {
+	resp, err := p.makePipedriveRequest("GET", "/leads/"+leadID, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lead response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get lead %s: HTTP %d", leadID, resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool           `json:"success"`
+		Data    *PipedriveLead `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse lead response: %v", err)
+	}
+	if !result.Success || result.Data == nil {
+		return nil, fmt.Errorf("failed to get lead %s", leadID)
+	}
+	return result.Data, nil
+}
+
+// CreateLead creates a lead for a person, with any extra lead fields by Pipedrive key
+func (p *PipedriveService) CreateLead(title string, personID int, fields map[string]interface{}) (*PipedriveLead, error) {
+	leadData := map[string]interface{}{
+		"title":     title,
+		"person_id": personID,
+	}
+	for key, value := range fields {
+		leadData[key] = value
+	}
+	resp, err := p.makePipedriveRequest("POST", "/leads", leadData)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lead response: %v", err)
+	}
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		return nil, fmt.Errorf("failed to create lead: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Success bool           `json:"success"`
+		Data    *PipedriveLead `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse lead response: %v", err)
+	}
+	if !result.Success || result.Data == nil {
+		return nil, fmt.Errorf("failed to create lead")
+	}
+	return result.Data, nil
+}
+
+// UpdateLead patches fields on a lead
+func (p *PipedriveService) UpdateLead(leadID string, fields map[string]interface{}) error {
+	resp, err := p.makePipedriveRequest("PATCH", "/leads/"+leadID, fields)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update lead %s: HTTP %d, Response: %s", leadID, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// writeBackLeadOutcome labels the originating lead as contacted, stamps the last-contacted field and unarchives it
+func (p *PipedriveService) writeBackLeadOutcome(leadID string, contactedAt time.Time) error {
+	lead, err := p.GetLead(leadID)
+	if err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{
+		"was_seen": true,
+	}
+	if lead.IsArchived {
+		update["is_archived"] = false
+	}
+	contactedLabel := p.leadContactedLabelID()
+	if contactedLabel != "" {
+		labels := lead.LabelIDs
+		hasLabel := false
+		for _, label := range labels {
+			if label == contactedLabel {
+				hasLabel = true
+				break
+			}
+		}
+		if !hasLabel {
+			update["label_ids"] = append(labels, contactedLabel)
+		}
+	}
+	if p.config.LeadLastContactedField != "" {
+		update[p.config.LeadLastContactedField] = contactedAt.Format("2006-01-02")
+	}
+
+	if err := p.UpdateLead(leadID, update); err != nil {
+		return err
+	}
+	if _, added := update["label_ids"]; added {
+		p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "label_added", PersonID: lead.PersonID, Detail: fmt.Sprintf("lead %s label %s", leadID, contactedLabel)})
+	}
+	p.logf("✅ Updated lead %s after successful contact (unarchived: %t)", leadID, lead.IsArchived)
+	return nil
+}
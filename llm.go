@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// LLMClient calls an OpenAI-compatible chat completions API
+type LLMClient struct {
+	apiKey     string
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+// newLLMClient returns an LLM client, or nil if no API key is configured
+func newLLMClient(config *Config, httpClient *http.Client) *LLMClient {
+	if config.LLMAPIKey == "" {
+		return nil
+	}
+	return &LLMClient{
+		apiKey:     config.LLMAPIKey,
+		baseURL:    config.LLMBaseURL,
+		model:      config.LLMModel,
+		httpClient: httpClient,
+	}
+}
+
+// Complete sends a system and user prompt and returns the model's reply
+func (l *LLMClient) Complete(systemPrompt, userPrompt string) (string, error) {
+	requestBody := map[string]interface{}{
+		"model": l.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": systemPrompt},
+			{"role": "user", "content": userPrompt},
+		},
+		"temperature": 0.2,
+	}
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal LLM request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", l.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+l.apiKey)
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make LLM request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("LLM request failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || len(result.Choices) == 0 {
+		return "", fmt.Errorf("failed to parse LLM response: %s", string(body))
+	}
+	return result.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+)
+
+// Clock formats
+const (
+	Clock12h = "12h"
+	Clock24h = "24h"
+)
+
+// Locale controls how dates and times are written in notes, activities, messages and agent variables
+type Locale struct {
+	DateFormat string `json:"date_format"` // Go layout, e.g. "Monday, January 2" or "Monday 2 January 2006"
+	Clock      string `json:"clock"`       // "12h" or "24h"
+	Language   string `json:"language"`    // Language of weekday and month names: en, de, fr, es, it, nl, pt
+}
+
+// localeNames holds weekday (Sunday first) and month names, and the word joining a date and a time
+type localeNames struct {
+	weekdays []string
+	months   []string
+	at       string
+}
+
+// localeLanguages are the supported languages besides English
+var localeLanguages = map[string]localeNames{
+	"de": {
+		weekdays: []string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		months:   []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		at:       "um",
+	},
+	"fr": {
+		weekdays: []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		months:   []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		at:       "à",
+	},
+	"es": {
+		weekdays: []string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		months:   []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		at:       "a las",
+	},
+	"it": {
+		weekdays: []string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		months:   []string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		at:       "alle",
+	},
+	"nl": {
+		weekdays: []string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		months:   []string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		at:       "om",
+	},
+	"pt": {
+		weekdays: []string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		months:   []string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		at:       "às",
+	},
+}
+
+// translator replaces English weekday and month names (full, then abbreviated) with the locale's language
+func (l Locale) translator() (*strings.Replacer, string) {
+	names, ok := localeLanguages[strings.ToLower(l.Language)]
+	if !ok {
+		return nil, "at"
+	}
+	var full, short []string
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full = append(full, day.String(), names.weekdays[day])
+		short = append(short, day.String()[:3], abbreviate(names.weekdays[day]))
+	}
+	for month := time.January; month <= time.December; month++ {
+		full = append(full, month.String(), names.months[month-1])
+		short = append(short, month.String()[:3], abbreviate(names.months[month-1]))
+	}
+	return strings.NewReplacer(append(full, short...)...), names.at
+}
+
+// abbreviate shortens a weekday or month name to three letters
+func abbreviate(name string) string {
+	runes := []rune(name)
+	if len(runes) <= 3 {
+		return name
+	}
+	return string(runes[:3])
+}
+
+// Date formats the date part of t
+func (l Locale) Date(t time.Time) string {
+	formatted := t.Format(l.DateFormat)
+	if replacer, _ := l.translator(); replacer != nil {
+		formatted = replacer.Replace(formatted)
+	}
+	return formatted
+}
+
+// Time formats the time of day of t on the locale's clock
+func (l Locale) Time(t time.Time) string {
+	if l.Clock == Clock24h {
+		return t.Format("15:04")
+	}
+	return t.Format("3:04 PM")
+}
+
+// DateTime formats t as a date and time, e.g. "Monday, January 2 at 3:04 PM" or "Montag, 2. Januar um 15:04"
+func (l Locale) DateTime(t time.Time) string {
+	_, at := l.translator()
+	return l.Date(t) + " " + at + " " + l.Time(t)
+}
+
+// LocaleFor returns a tenant's locale, falling back to the default locale
+func (c *Config) LocaleFor(tenant string) Locale {
+	if locale, ok := c.TenantLocales[tenant]; ok {
+		return locale
+	}
+	return c.DefaultLocale
+}
+
+// loadTenantLocales parses TENANT_LOCALES (JSON: tenant -> locale), filling unset fields from the default locale
+func loadTenantLocales(raw string, defaults Locale) map[string]Locale {
+	locales := make(map[string]Locale)
+	if raw == "" {
+		return locales
+	}
+	if err := json.Unmarshal([]byte(raw), &locales); err != nil {
+		log.Printf("⚠️ Invalid TENANT_LOCALES, ignoring: %v", err)
+		return make(map[string]Locale)
+	}
+	for tenant, locale := range locales {
+		if locale.DateFormat == "" {
+			locale.DateFormat = defaults.DateFormat
+		}
+		if locale.Clock == "" {
+			locale.Clock = defaults.Clock
+		}
+		if locale.Language == "" {
+			locale.Language = defaults.Language
+		}
+		locales[tenant] = locale
+	}
+	return locales
+}
+
+// meetingTimeText formats a meeting start in the Cal.com time zone and the tenant's locale, with the zone abbreviation
+func (p *PipedriveService) meetingTimeText(tenant string, start time.Time) string {
+	if location, err := time.LoadLocation(p.config.CalTimeZone); err == nil {
+		start = start.In(location)
+	}
+	return p.config.LocaleFor(tenant).DateTime(start) + start.Format(" MST")
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Debug logging subsystems
+const (
+	SubsystemConfig    = "config"
+	SubsystemPipedrive = "pipedrive"
+	SubsystemRetell    = "retell"
+	SubsystemCal       = "cal"
+	SubsystemLead      = "lead"
+	SubsystemHTTP      = "http"
+)
+
+// LogSettings controls which verbose logging is enabled at runtime
+type LogSettings struct {
+	Level      string          `json:"level"`                // debug, info, warn or error; "debug" enables every subsystem
+	Subsystems map[string]bool `json:"subsystems,omitempty"` // Subsystems with debug logging on
+	Endpoints  map[string]bool `json:"endpoints,omitempty"`  // Route paths whose request bodies are dumped
+	Tenants    map[string]bool `json:"tenants,omitempty"`    // Tenants whose webhook payloads are dumped
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"` // When the settings revert to the startup level
+}
+
+// debugLogger holds the current log settings
+type debugLogger struct {
+	mu       sync.RWMutex
+	settings LogSettings
+	startup  LogSettings
+}
+
+// debugLog is the process-wide debug logger
+var debugLog = &debugLogger{}
+
+// logOutput is where log lines are written; captureLogs adds the support capture
+var logOutput io.Writer = os.Stderr
+
+// logLevel is the minimum level of info, warning and error lines; debug lines are gated per subsystem
+var logLevel = new(slog.LevelVar)
+
+// logSecrets masks configured secret values in log lines
+var logSecrets = strings.NewReplacer()
+
+// logMarkers give lines logged with log.Printf the level of their leading emoji
+var logMarkers = []struct {
+	marker string
+	level  slog.Level
+}{
+	{"⚠️", slog.LevelWarn},
+	{"❌", slog.LevelError},
+}
+
+// requestIDPattern is what a caller-supplied X-Request-ID must look like to be reused
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// parseLogLevel maps LOG_LEVEL to a slog level
+func parseLogLevel(level string) (slog.Level, bool) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "info", "":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// applyLogLevel sets the level info, warning and error lines are filtered on
+func applyLogLevel(level string) {
+	parsed, _ := parseLogLevel(level)
+	logLevel.Set(parsed)
+}
+
+// redactSecrets masks configured secrets, token query parameters, bearer tokens, URL passwords and
+// the token fields of JSON bodies logged by debugf
+func redactSecrets(text string) string {
+	text = logSecrets.Replace(text)
+	text = sensitiveQueryPattern.ReplaceAllString(text, "${1}[redacted]")
+	text = bearerPattern.ReplaceAllString(text, "${1}[redacted]")
+	text = urlPasswordPattern.ReplaceAllString(text, "${1}[redacted]@")
+	return secretFieldPattern.ReplaceAllString(text, "${1}[redacted]")
+}
+
+// leveledHandler levels lines by their leading emoji (⚠️ warning, ❌ error), drops the emoji from
+// the message, masks secrets and filters on LOG_LEVEL. Debug lines pass, as debugf already
+// checked their subsystem.
+type leveledHandler struct {
+	slog.Handler
+}
+
+// Enabled accepts every line; the level is only known once Handle reads the message
+func (h leveledHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle writes a line at the level of its marker
+func (h leveledHandler) Handle(ctx context.Context, record slog.Record) error {
+	level, message := record.Level, record.Message
+	for _, marker := range logMarkers {
+		if strings.HasPrefix(strings.TrimLeft(message, " "), marker.marker) {
+			level = marker.level
+			break
+		}
+	}
+	if level >= slog.LevelInfo && level < logLevel.Level() {
+		return nil
+	}
+	if r, _ := utf8.DecodeRuneInString(message); r >= 0x2000 {
+		// Drop the leading emoji, which the level now carries
+		if _, rest, ok := strings.Cut(message, " "); ok {
+			message = strings.TrimLeft(rest, " ")
+		}
+	}
+
+	leveled := slog.NewRecord(record.Time, level, redactSecrets(message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		leveled.AddAttrs(attr)
+		return true
+	})
+	return h.Handler.Handle(ctx, leveled)
+}
+
+// WithAttrs keeps the leveling on loggers with attributes
+func (h leveledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return leveledHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the leveling on grouped loggers
+func (h leveledHandler) WithGroup(name string) slog.Handler {
+	return leveledHandler{h.Handler.WithGroup(name)}
+}
+
+// configureLogging routes log output, including the standard logger's, through a structured
+// logger in LOG_FORMAT at LOG_LEVEL, and sets the startup debug settings
+func configureLogging(config *Config) {
+	if _, ok := parseLogLevel(config.LogLevel); !ok {
+		log.Printf("⚠️ Invalid LOG_LEVEL %q, using info", config.LogLevel)
+		config.LogLevel = "info"
+	}
+	logSecrets = strings.NewReplacer(configSecretPairs(config)...)
+
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler = slog.NewTextHandler(logOutput, options)
+	if strings.EqualFold(config.LogFormat, "json") {
+		handler = slog.NewJSONHandler(logOutput, options)
+	}
+	slog.SetDefault(slog.New(leveledHandler{handler}))
+	configureDebugLogging(config)
+}
+
+// configureDebugLogging sets the startup log settings from config
+func configureDebugLogging(config *Config) {
+	level, _ := parseLogLevel(config.LogLevel)
+	settings := LogSettings{
+		Level:      strings.ToLower(level.String()),
+		Subsystems: make(map[string]bool),
+	}
+	for _, subsystem := range config.DebugSubsystems {
+		settings.Subsystems[subsystem] = true
+	}
+
+	debugLog.mu.Lock()
+	defer debugLog.mu.Unlock()
+	debugLog.settings = settings
+	debugLog.startup = settings
+	applyLogLevel(settings.Level)
+}
+
+// current returns the active settings, reverting expired runtime overrides
+func (d *debugLogger) current() LogSettings {
+	d.mu.RLock()
+	settings := d.settings
+	d.mu.RUnlock()
+	if settings.ExpiresAt != nil && time.Now().After(*settings.ExpiresAt) {
+		d.mu.Lock()
+		d.settings = d.startup
+		settings = d.settings
+		d.mu.Unlock()
+		applyLogLevel(settings.Level)
+		log.Printf("ℹ️ Runtime log settings expired, reverted to level %s", settings.Level)
+	}
+	return settings
+}
+
+// debugEnabled returns true if verbose logging is on for a subsystem
+func debugEnabled(subsystem string) bool {
+	settings := debugLog.current()
+	return settings.Level == "debug" || settings.Subsystems[subsystem]
+}
+
+// debugTenantEnabled returns true if payload dumps are on for a tenant
+func debugTenantEnabled(tenant string) bool {
+	settings := debugLog.current()
+	return settings.Level == "debug" || settings.Tenants[tenant]
+}
+
+// debugf logs a verbose message when the subsystem has debug logging on
+func debugf(subsystem, format string, args ...interface{}) {
+	if debugEnabled(subsystem) {
+		slog.Debug(fmt.Sprintf(format, args...), "subsystem", subsystem)
+	}
+}
+
+// logger returns the service's logger, which carries the request ID on a per-request copy
+func (p *PipedriveService) logger() *slog.Logger {
+	if p.requestLog != nil {
+		return p.requestLog
+	}
+	return slog.Default()
+}
+
+// logf logs a line at the level of its leading emoji, with the request ID when there is one
+func (p *PipedriveService) logf(format string, args ...interface{}) {
+	p.logger().Info(fmt.Sprintf(format, args...))
+}
+
+// debugf logs a verbose message when the subsystem has debug logging on, with the request ID
+func (p *PipedriveService) debugf(subsystem, format string, args ...interface{}) {
+	if debugEnabled(subsystem) {
+		p.logger().Debug(fmt.Sprintf(format, args...), "subsystem", subsystem)
+	}
+}
+
+// forRequest returns a copy of the service whose log lines carry the request's ID, so a webhook can
+// be traced through everything it triggers, including background processing
+func (p *PipedriveService) forRequest(c *gin.Context) *PipedriveService {
+	requestID := c.GetString("request_id")
+	if requestID == "" {
+		return p
+	}
+	scoped := *p
+	scoped.requestLog = slog.Default().With("request_id", requestID)
+	scoped.tenant = webhookTenant(c)
+	if p.deals != nil {
+		scoped.deals = newDealService(p.config, &scoped)
+	}
+	return &scoped
+}
+
+// RequestIDMiddleware tags each request with an ID, reusing a well-formed X-Request-ID from the
+// caller, returns it in X-Request-ID and logs the request when it completes
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newProcessingID()
+		}
+		c.Set("request_id", requestID)
+		c.Header("X-Request-ID", requestID)
+
+		start := time.Now()
+		c.Next()
+		slog.Info("HTTP request",
+			"request_id", requestID,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// DebugRequestMiddleware dumps request bodies for endpoints with debug logging on
+func DebugRequestMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings := debugLog.current()
+		if (settings.Endpoints[c.FullPath()] || debugEnabled(SubsystemHTTP)) && c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err == nil {
+				slog.Debug(fmt.Sprintf("%s %s body: %s", c.Request.Method, c.Request.URL.Path, string(body)),
+					"subsystem", SubsystemHTTP, "request_id", c.GetString("request_id"))
+				c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+			}
+		}
+		c.Next()
+	}
+}
+
+// LogLevelRequest is the body for POST /admin/log-level
+type LogLevelRequest struct {
+	Level      string          `json:"level"`
+	Subsystems map[string]bool `json:"subsystems"`
+	Endpoints  map[string]bool `json:"endpoints"`
+	Tenants    map[string]bool `json:"tenants"`
+	ExpiresIn  string          `json:"expires_in"` // Optional duration after which settings revert, e.g. "30m"
+}
+
+// GetLogLevelHandler returns the current runtime log settings
+func GetLogLevelHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Current log settings",
+			Data:    debugLog.current(),
+		})
+	}
+}
+
+// SetLogLevelHandler changes debug logging at runtime without a redeploy
+func SetLogLevelHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request LogLevelRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		settings := debugLog.current()
+		if request.Level != "" {
+			level := strings.ToLower(request.Level)
+			parsed, ok := parseLogLevel(level)
+			if !ok {
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "level must be debug, info, warn or error",
+				})
+				return
+			}
+			settings.Level = strings.ToLower(parsed.String())
+		}
+		settings.Subsystems = mergeToggles(settings.Subsystems, request.Subsystems)
+		settings.Endpoints = mergeToggles(settings.Endpoints, request.Endpoints)
+		settings.Tenants = mergeToggles(settings.Tenants, request.Tenants)
+
+		settings.ExpiresAt = nil
+		if request.ExpiresIn != "" {
+			duration, err := time.ParseDuration(request.ExpiresIn)
+			if err != nil || duration <= 0 {
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "Invalid expires_in duration",
+				})
+				return
+			}
+			expiresAt := time.Now().Add(duration)
+			settings.ExpiresAt = &expiresAt
+		}
+
+		debugLog.mu.Lock()
+		debugLog.settings = settings
+		debugLog.mu.Unlock()
+		applyLogLevel(settings.Level)
+
+		who := "anonymous"
+		if user, ok := c.Get("admin_user"); ok {
+			who = user.(*AdminUser).Name
+		}
+		log.Printf("ℹ️ Log settings changed by %s: level=%s subsystems=%v endpoints=%v tenants=%v",
+			who, settings.Level, settings.Subsystems, settings.Endpoints, settings.Tenants)
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Log level set to %s", settings.Level),
+			Data:    settings,
+		})
+	}
+}
+
+// mergeToggles applies requested on/off toggles to a copy of the current ones
+func mergeToggles(current, changes map[string]bool) map[string]bool {
+	merged := make(map[string]bool, len(current))
+	for key, on := range current {
+		merged[key] = on
+	}
+	for key, on := range changes {
+		if on {
+			merged[key] = true
+		} else {
+			delete(merged, key)
+		}
+	}
+	return merged
+}
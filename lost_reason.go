@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Records LOST_REASON_TARGETS can close
+const (
+	LostTargetLead = "lead" // Archive the lead that triggered the call
+	LostTargetDeal = "deal" // Mark the person's open deal as lost
+)
+
+// LostMark records a lead or deal closed after a call clearly ended in rejection
+type LostMark struct {
+	CallID     string     `json:"call_id"`
+	PersonID   int        `json:"person_id"`
+	LeadID     string     `json:"lead_id,omitempty"` // Archived lead
+	DealID     int        `json:"deal_id,omitempty"` // Deal marked lost
+	Reason     string     `json:"reason"`
+	MarkedAt   time.Time  `json:"marked_at"`
+	RevertedAt *time.Time `json:"reverted_at,omitempty"`
+	RevertedBy string     `json:"reverted_by,omitempty"`
+}
+
+// LostMarkStore keeps lost marks by call ID so they can be listed and reverted, saved to
+// LOST_MARKS_FILE when set
+type LostMarkStore struct {
+	mu    sync.Mutex
+	path  string
+	marks map[string]*LostMark
+	busy  map[string]bool // Calls being marked or reverted, while their Pipedrive requests run
+}
+
+// NewLostMarkStore creates a lost mark store, loading the file at path when set
+func NewLostMarkStore(path string) *LostMarkStore {
+	store := &LostMarkStore{path: path, marks: make(map[string]*LostMark), busy: make(map[string]bool)}
+	if path == "" {
+		return store
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read LOST_MARKS_FILE %s: %v", path, err)
+		}
+		return store
+	}
+	if err := json.Unmarshal(data, &store.marks); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable LOST_MARKS_FILE %s: %v", path, err)
+		store.marks = make(map[string]*LostMark)
+		return store
+	}
+	log.Printf("📉 Loaded %d lost marks from %s", len(store.marks), path)
+	return store
+}
+
+// saveLocked writes the marks to LOST_MARKS_FILE; callers must hold mu
+func (s *LostMarkStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s.marks)
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save lost marks: %v", err)
+	}
+}
+
+// reserve claims a call for marking, reporting false when it already has a mark or is being marked
+func (s *LostMarkStore) reserve(callID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.marks[callID]; ok || s.busy[callID] {
+		return false
+	}
+	s.busy[callID] = true
+	return true
+}
+
+// finish releases a call claimed by reserve or startRevert, storing its mark when there is one
+func (s *LostMarkStore) finish(callID string, mark *LostMark) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.busy, callID)
+	if mark != nil {
+		s.marks[callID] = mark
+		s.saveLocked()
+	}
+}
+
+// startRevert claims a call's mark for reverting and returns a copy of it; ok is false when there
+// is no mark and busy when another request is marking or reverting it
+func (s *LostMarkStore) startRevert(callID string) (mark LostMark, ok, busy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.marks[callID]
+	if !ok {
+		return LostMark{}, false, false
+	}
+	if s.busy[callID] {
+		return *current, true, true
+	}
+	if current.RevertedAt == nil {
+		s.busy[callID] = true
+	}
+	return *current, true, false
+}
+
+// Get returns a copy of a call's lost mark
+func (s *LostMarkStore) Get(callID string) (LostMark, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mark, ok := s.marks[callID]
+	if !ok {
+		return LostMark{}, false
+	}
+	return *mark, true
+}
+
+// List returns all lost marks, newest first
+func (s *LostMarkStore) List() []LostMark {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]LostMark, 0, len(s.marks))
+	for _, mark := range s.marks {
+		list = append(list, *mark)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].MarkedAt.After(list[j].MarkedAt) })
+	return list
+}
+
+// lostReasonFor maps the LOST_REASON_FIELD analysis value to a Pipedrive lost reason
+func (c *Config) lostReasonFor(data map[string]interface{}) (string, bool) {
+	var value string
+	switch raw := data[c.LostReasonField].(type) {
+	case string:
+		value = raw
+	case bool:
+		value = fmt.Sprintf("%t", raw)
+	default:
+		return "", false
+	}
+	value = strings.ToLower(strings.TrimSpace(value))
+	for key, reason := range c.LostReasonMapping {
+		if strings.ToLower(key) == value && reason != "" {
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+// hasLostTarget reports whether LOST_REASON_TARGETS includes a record type
+func (c *Config) hasLostTarget(target string) bool {
+	for _, t := range c.LostReasonTargets {
+		if strings.EqualFold(t, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateDeal sets fields on a deal
+func (p *PipedriveService) updateDeal(dealID int, fields map[string]interface{}) error {
+	resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/deals/%d", dealID), fields)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update deal %d: HTTP %d, Response: %s", dealID, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// markLost archives the call's lead and marks the person's open deal lost with the mapped reason
+func (p *PipedriveService) markLost(callID string, mapping CallMapping, reason string) {
+	if !p.lostMarks.reserve(callID) {
+		return
+	}
+	var stored *LostMark
+	defer func() { p.lostMarks.finish(callID, stored) }()
+	mark := &LostMark{CallID: callID, PersonID: mapping.PersonID, Reason: reason, MarkedAt: time.Now()}
+
+	if p.config.hasLostTarget(LostTargetLead) && mapping.LeadID != "" {
+		if err := p.UpdateLead(mapping.LeadID, map[string]interface{}{"is_archived": true}); err != nil {
+			p.logf("⚠️ Warning: Failed to archive lead %s as lost: %v", mapping.LeadID, err)
+		} else {
+			mark.LeadID = mapping.LeadID
+			note := map[string]interface{}{
+				"lead_id": mapping.LeadID,
+				"content": fmt.Sprintf("Lead archived after AI call %s\nLost reason: %s", callID, reason),
+			}
+			if resp, err := p.makePipedriveRequest("POST", "/notes", note); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	if p.config.hasLostTarget(LostTargetDeal) {
+		if dealID := p.dealForPerson(mapping.PersonID); dealID != 0 {
+			if err := p.updateDeal(dealID, map[string]interface{}{"status": "lost", "lost_reason": reason}); err != nil {
+				p.logf("⚠️ Warning: Failed to mark deal %d as lost: %v", dealID, err)
+			} else {
+				mark.DealID = dealID
+			}
+		}
+	}
+	if mark.LeadID == "" && mark.DealID == 0 {
+		return
+	}
+
+	stored = mark
+	p.auditLog.Append(AuditEntry{
+		Actor:    AuditActorSystem,
+		Action:   "marked_lost",
+		PersonID: mapping.PersonID,
+		CallID:   callID,
+		Detail:   fmt.Sprintf("lead=%s deal=%d reason=%s", mark.LeadID, mark.DealID, reason),
+	})
+	p.logf("📉 Call %s marked lead %q / deal %d lost: %s", callID, mark.LeadID, mark.DealID, reason)
+}
+
+// ErrLostMarkBusy is returned while another request is marking or reverting the call
+var ErrLostMarkBusy = errors.New("the call's lost mark is being changed")
+
+// revertLost reopens the deal and unarchives the lead a call marked lost
+func (p *PipedriveService) revertLost(callID, revertedBy string) (LostMark, error) {
+	mark, ok, busy := p.lostMarks.startRevert(callID)
+	if !ok {
+		return LostMark{}, fmt.Errorf("call %s did not mark anything lost", callID)
+	}
+	if busy {
+		return mark, ErrLostMarkBusy
+	}
+	if mark.RevertedAt != nil {
+		return mark, nil
+	}
+
+	var reverted *LostMark
+	defer func() { p.lostMarks.finish(callID, reverted) }()
+	if mark.DealID != 0 {
+		if err := p.updateDeal(mark.DealID, map[string]interface{}{"status": "open"}); err != nil {
+			return mark, err
+		}
+	}
+	if mark.LeadID != "" {
+		if err := p.UpdateLead(mark.LeadID, map[string]interface{}{"is_archived": false}); err != nil {
+			return mark, err
+		}
+	}
+	now := time.Now()
+	mark.RevertedAt = &now
+	mark.RevertedBy = revertedBy
+	reverted = &mark
+	p.auditLog.Append(AuditEntry{
+		Actor:    revertedBy,
+		Action:   "lost_reverted",
+		PersonID: mark.PersonID,
+		CallID:   callID,
+		Detail:   fmt.Sprintf("lead=%s deal=%d", mark.LeadID, mark.DealID),
+	})
+	return mark, nil
+}
+
+// ListLostMarksHandler lists leads and deals closed as lost after calls
+func ListLostMarksHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		marks := pipedriveService.lostMarks.List()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d lost marks", len(marks)),
+			Data:    marks,
+		})
+	}
+}
+
+// RevertLostHandler reopens the deal and lead a call marked lost
+func RevertLostHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		callID := c.Param("id")
+		if callOutOfScope(c, pipedriveService, callID) {
+			return
+		}
+		if _, ok := pipedriveService.lostMarks.Get(callID); !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "No lost mark for call " + callID,
+			})
+			return
+		}
+		revertedBy := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			revertedBy = user.(*AdminUser).Name
+		}
+		mark, err := pipedriveService.revertLost(callID, revertedBy)
+		if errors.Is(err, ErrLostMarkBusy) {
+			c.JSON(http.StatusConflict, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to revert: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Lost mark reverted",
+			Data:    mark,
+		})
+	}
+}
+
+// loadLostReasonMapping parses LOST_REASON_MAPPING (JSON: analysis value -> Pipedrive lost reason)
+func loadLostReasonMapping(raw string) map[string]string {
+	mapping := make(map[string]string)
+	if raw == "" {
+		return mapping
+	}
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		log.Printf("⚠️ Invalid LOST_REASON_MAPPING, ignoring: %v", err)
+		return make(map[string]string)
+	}
+	return mapping
+}
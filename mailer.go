@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text email through SMTP
+type Mailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// newMailer returns an SMTP mailer, or nil if SMTP_HOST is not set
+func newMailer(config *Config) *Mailer {
+	if config.SMTPHost == "" {
+		return nil
+	}
+	if config.SMTPFrom == "" {
+		log.Printf("⚠️ SMTP_HOST requires SMTP_FROM, email delivery disabled")
+		return nil
+	}
+	mailer := &Mailer{
+		addr: config.SMTPHost + ":" + strconv.Itoa(config.SMTPPort),
+		from: config.SMTPFrom,
+	}
+	if config.SMTPUsername != "" {
+		mailer.auth = smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, config.SMTPHost)
+	}
+	return mailer
+}
+
+// Send delivers a plain text message to the recipients
+func (m *Mailer) Send(to []string, subject, body string) error {
+	return m.SendAs(m.from, "", to, subject, body)
+}
+
+// SendAs delivers a plain text message with its own From (e.g. a tenant's branded sender) and
+// optional Reply-To
+func (m *Mailer) SendAs(from, replyTo string, to []string, subject, body string) error {
+	return m.SendWithHeaders(from, replyTo, to, subject, body, nil)
+}
+
+// SendWithHeaders is SendAs with extra headers, e.g. List-Unsubscribe
+func (m *Mailer) SendWithHeaders(from, replyTo string, to []string, subject, body string, extra []string) error {
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	if from == "" {
+		from = m.from
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %v", from, err)
+	}
+	headers := []string{
+		"From: " + sender.String(),
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+	}
+	if replyTo != "" {
+		headers = append(headers, "Reply-To: "+replyTo)
+	}
+	headers = append(headers, extra...)
+	message := strings.Join(headers, "\r\n") + "\r\n\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+	if err := smtp.SendMail(m.addr, m.auth, sender.Address, to, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
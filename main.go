@@ -3,9 +3,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,6 +20,8 @@ import (
 )
 
 func main() {
+	captureLogs()
+
 	// Load environment variables FIRST (optional - will use system env vars if .env not found)
 	_ = godotenv.Load() // Ignore error - will use Railway/Vercel env vars in production
 
@@ -26,12 +30,12 @@ func main() {
 
 	// Create Gin router
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery())
+	router.Use(RequestIDMiddleware(), gin.Recovery())
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if c.Request.Method == "OPTIONS" {
@@ -44,17 +48,29 @@ func main() {
 
 	// Load configuration
 	config := LoadConfig()
+	configureLogging(config)
+	configureTrustedProxies(router, config)
+
+	// Reject misrouted webhooks before anything reads or logs their payload
+	router.Use(RegionGuardMiddleware(config))
 
-	// DEBUG: Print configuration
-	log.Printf("🔧 [DEBUG] PipedriveAPIKey: %s", config.PipedriveAPIKey)
-	log.Printf("🔧 [DEBUG] RetellAPIKey: %s", config.RetellAPIKey)
-	log.Printf("🔧 [DEBUG] RetellAssistantID: %s", config.RetellAssistantID)
-	log.Printf("🔧 [DEBUG] RetellFromNumber: %s", config.RetellFromNumber)
-	log.Printf("🔧 [DEBUG] HasPipedriveConfig: %t", config.HasPipedriveConfig())
-	log.Printf("🔧 [DEBUG] HasRetellConfig: %t", config.HasRetellConfig())
+	// Response compression and request dumps for debugged endpoints
+	router.Use(CompressionMiddleware(config))
+	router.Use(DebugRequestMiddleware())
+
+	// Print configuration (debug only)
+	debugf(SubsystemConfig, "RetellAssistantID: %s", config.RetellAssistantID)
+	debugf(SubsystemConfig, "RetellFromNumber: %s", config.RetellFromNumber)
+	debugf(SubsystemConfig, "HasPipedriveConfig: %t", config.HasPipedriveConfig())
+	debugf(SubsystemConfig, "HasRetellConfig: %t", config.HasRetellConfig())
 
 	// Initialize services
 	pipedriveService := NewPipedriveService(config)
+	router.Use(SLOMiddleware(pipedriveService))
+	router.Use(FailedWebhookCaptureMiddleware())
+	router.Use(WebhookAuthMiddleware(config))
+	router.Use(WebhookArchiveMiddleware(pipedriveService))
+	router.Use(WebhookBackpressureMiddleware(pipedriveService))
 
 	// Serve static files
 	router.Static("/static", "./static")
@@ -68,6 +84,111 @@ func main() {
 	router.POST("/webhook/cal", CalWebhookHandler(pipedriveService))
 	router.POST("/webhook/retell/analyzed", RetellCallAnalyzedHandler(pipedriveService))
 	router.POST("/webhook/pipedrive/lead", PipedriveLeadWebhookHandler(pipedriveService))
+	router.POST("/webhook/stripe", StripeWebhookHandler(pipedriveService))
+
+	// API endpoints
+	router.POST("/api/phones/validate", RequireRole(pipedriveService, RoleOperator), PhoneValidationHandler(pipedriveService))
+	router.GET("/api/processing/:id", RequireRole(pipedriveService, RoleReadOnly), ProcessingStatusHandler(pipedriveService))
+	router.GET("/api/transcripts/:call_id", RequireRole(pipedriveService, RoleReadOnly), TranscriptHandler(pipedriveService))
+	router.GET("/admin/calls", RequireRole(pipedriveService, RoleReadOnly), ListCallsHandler(pipedriveService))
+	router.GET("/admin/retell/phone-numbers", RequireRole(pipedriveService, RoleReadOnly), ListRetellPhoneNumbersHandler(pipedriveService))
+	router.POST("/admin/retell/phone-numbers", RequireRole(pipedriveService, RoleAdmin), PurchaseRetellPhoneNumberHandler(pipedriveService))
+	router.PATCH("/admin/retell/phone-numbers/:number", RequireRole(pipedriveService, RoleAdmin), AttachRetellPhoneNumberHandler(pipedriveService))
+	router.POST("/admin/retell/onboard", RequireRole(pipedriveService, RoleAdmin), RetellOnboardHandler(pipedriveService))
+	router.POST("/admin/retell/sync-variables", RequireRole(pipedriveService, RoleAdmin), SyncDynamicVariablesHandler(pipedriveService))
+	router.GET("/api/reports/experiments", RequireRole(pipedriveService, RoleReadOnly), ExperimentReportHandler(pipedriveService))
+	router.POST("/api/persons/:id/snooze", RequireRole(pipedriveService, RoleOperator), SnoozePersonHandler(pipedriveService))
+	router.DELETE("/api/persons/:id/snooze", RequireRole(pipedriveService, RoleOperator), UnsnoozePersonHandler(pipedriveService))
+	router.GET("/admin/snoozes", RequireRole(pipedriveService, RoleReadOnly), ListSnoozesHandler(pipedriveService))
+	router.GET("/admin/reports/owner-fallbacks", RequireRole(pipedriveService, RoleReadOnly), OwnerFallbackReportHandler(pipedriveService))
+	router.GET("/admin/workers", RequireRole(pipedriveService, RoleReadOnly), WorkerStatsHandler(pipedriveService))
+	router.GET("/admin/log-level", RequireRole(pipedriveService, RoleReadOnly), GetLogLevelHandler(pipedriveService))
+	router.POST("/admin/log-level", RequireRole(pipedriveService, RoleAdmin), SetLogLevelHandler(pipedriveService))
+	router.GET("/api/reports/dial-rules", RequireRole(pipedriveService, RoleReadOnly), DialRuleReportHandler(pipedriveService))
+	router.POST("/api/bookings", RequireRole(pipedriveService, RoleOperator), CreateBookingHandler(pipedriveService))
+	router.GET("/admin/reminders", RequireRole(pipedriveService, RoleReadOnly), ListRemindersHandler(pipedriveService))
+	router.POST("/functions/check-availability", RequireVerifiedWebhook(pipedriveService), CheckAvailabilityFunctionHandler(pipedriveService))
+	router.POST("/functions/book-meeting", RequireVerifiedWebhook(pipedriveService), BookMeetingFunctionHandler(pipedriveService))
+	router.POST("/functions/lookup-contact", RequireVerifiedWebhook(pipedriveService), LookupContactFunctionHandler(pipedriveService))
+	router.POST("/functions/mark-dnc", RequireVerifiedWebhook(pipedriveService), MarkDNCFunctionHandler(pipedriveService))
+	router.GET("/api/reports/call-windows", RequireRole(pipedriveService, RoleReadOnly), CallWindowReportHandler(pipedriveService))
+	router.GET("/admin/region", RequireRole(pipedriveService, RoleReadOnly), RegionStatusHandler(pipedriveService))
+	router.GET("/admin/config", RequireRole(pipedriveService, RoleAdmin), ConfigViewHandler(pipedriveService))
+	router.POST("/admin/retell/voicemail-drop", RequireRole(pipedriveService, RoleAdmin), VoicemailDropSetupHandler(pipedriveService))
+	router.GET("/admin/calls/:id/events", RequireRole(pipedriveService, RoleReadOnly), CallEventsHandler(pipedriveService))
+	router.POST("/admin/calls/:id/replay", RequireRole(pipedriveService, RoleAdmin), ReplayCallHandler(pipedriveService))
+	router.GET("/api/reports/kpis", RequireRole(pipedriveService, RoleReadOnly), KPIReportHandler(pipedriveService))
+	router.POST("/admin/kpis/write-back", RequireRole(pipedriveService, RoleOperator), KPIWriteBackHandler(pipedriveService))
+	router.GET("/admin/chaos", RequireRole(pipedriveService, RoleReadOnly), ChaosStatusHandler(pipedriveService))
+	router.POST("/admin/calls/:id/refresh", RequireRole(pipedriveService, RoleOperator), RefreshCallHandler(pipedriveService))
+	router.POST("/admin/reconcile", RequireRole(pipedriveService, RoleOperator), ReconcileHandler(pipedriveService))
+	router.GET("/api/reports/reconciliation", RequireRole(pipedriveService, RoleReadOnly), ReconciliationReportHandler(pipedriveService))
+	router.POST("/webhook/pipedrive/deal", PipedriveDealWebhookHandler(pipedriveService))
+	router.POST("/webhook/pipedrive/activity", PipedriveActivityWebhookHandler(pipedriveService))
+	router.GET("/admin/nurtures", RequireRole(pipedriveService, RoleReadOnly), ListNurturesHandler(pipedriveService))
+	router.POST("/webhook/email", EmailWebhookHandler(pipedriveService))
+	router.GET("/webhook/facebook", FacebookVerifyHandler(pipedriveService))
+	router.POST("/webhook/facebook", FacebookLeadsWebhookHandler(pipedriveService))
+	router.POST("/api/leads/capture", LeadCaptureHandler(pipedriveService))
+	router.GET("/internal/scaling", RequireRole(pipedriveService, RoleReadOnly), ScalingHandler(pipedriveService))
+	router.GET("/api/slo", RequireRole(pipedriveService, RoleReadOnly), SLOReportHandler(pipedriveService))
+	router.GET("/admin/email-bounces", RequireRole(pipedriveService, RoleReadOnly), ListEmailBouncesHandler(pipedriveService))
+	router.POST("/admin/email-bounces", RequireRole(pipedriveService, RoleOperator), AddEmailBouncesHandler(pipedriveService))
+	router.DELETE("/admin/email-bounces/:email", RequireRole(pipedriveService, RoleOperator), RemoveEmailBounceHandler(pipedriveService))
+	router.GET("/admin/lost-marks", RequireRole(pipedriveService, RoleReadOnly), ListLostMarksHandler(pipedriveService))
+	router.POST("/admin/calls/:id/revert-lost", RequireRole(pipedriveService, RoleOperator), RevertLostHandler(pipedriveService))
+	router.GET("/admin/persons/:id/memory", RequireRole(pipedriveService, RoleReadOnly), PersonMemoryHandler(pipedriveService))
+	router.DELETE("/admin/persons/:id/memory", RequireRole(pipedriveService, RoleOperator), ForgetPersonMemoryHandler(pipedriveService))
+	router.GET("/admin/routing/team", RequireRole(pipedriveService, RoleReadOnly), TeamRoutingHandler(pipedriveService))
+	router.GET("/admin/retention", RequireRole(pipedriveService, RoleReadOnly), RetentionStatusHandler(pipedriveService))
+	router.POST("/admin/retention/purge", RequireRole(pipedriveService, RoleAdmin), RetentionPurgeHandler(pipedriveService))
+	router.GET("/test/fixtures", RequireTestConsole(pipedriveService), TestFixturesHandler)
+	router.POST("/test/scenario/:name", ScenarioHandler(pipedriveService))
+	router.GET("/admin/support-bundle", RequireRole(pipedriveService, RoleAdmin), SupportBundleHandler(pipedriveService))
+	router.POST("/webhook/pipedrive/person", PipedrivePersonWebhookHandler(pipedriveService))
+	router.GET("/admin/person-changes", RequireRole(pipedriveService, RoleReadOnly), PersonChangesHandler(pipedriveService))
+	router.GET("/meetings/:token/invite.ics", MeetingInviteHandler(pipedriveService))
+	router.GET("/r/:code", ShortLinkRedirectHandler(pipedriveService))
+	router.GET("/admin/short-links", RequireRole(pipedriveService, RoleReadOnly), ListShortLinksHandler(pipedriveService))
+	router.POST("/admin/short-links", RequireRole(pipedriveService, RoleOperator), CreateShortLinkHandler(pipedriveService))
+	router.GET("/api/reports/owner-digest", RequireRole(pipedriveService, RoleReadOnly), OwnerDigestReportHandler(pipedriveService))
+	router.POST("/admin/digests/send", RequireRole(pipedriveService, RoleAdmin), SendDigestsHandler(pipedriveService))
+	router.GET("/api/audit", RequireRole(pipedriveService, RoleReadOnly), AuditLogHandler(pipedriveService))
+	router.GET("/health/ready", ReadinessHandler(pipedriveService))
+	router.POST("/admin/pipedrive/features/:feature/enable", RequireRole(pipedriveService, RoleAdmin), EnablePipedriveFeatureHandler(pipedriveService))
+	router.GET("/admin/webhooks", RequireRole(pipedriveService, RoleAdmin), ListArchivedWebhooksHandler(pipedriveService))
+	router.POST("/admin/reprocess/:archived_id", RequireRole(pipedriveService, RoleAdmin), ReprocessWebhookHandler(pipedriveService))
+	router.GET("/admin/pipedrive/lead-labels", RequireRole(pipedriveService, RoleReadOnly), ListLeadLabelsHandler(pipedriveService))
+	router.POST("/admin/pipedrive/lead-labels", RequireRole(pipedriveService, RoleAdmin), CreateLeadLabelHandler(pipedriveService))
+	router.GET("/api/pipedrive/persons/:id", RequireRole(pipedriveService, RoleReadOnly), PersonProxyHandler(pipedriveService))
+	router.GET("/admin/retell/failover", RequireRole(pipedriveService, RoleReadOnly), RetellFailoverStatusHandler(pipedriveService))
+	router.POST("/admin/retell/failback", RequireRole(pipedriveService, RoleAdmin), RetellFailbackHandler(pipedriveService))
+	router.POST("/admin/activities/cleanup", RequireRole(pipedriveService, RoleOperator), StaleActivityCleanupHandler(pipedriveService))
+	router.GET("/admin/config/export", RequireRole(pipedriveService, RoleAdmin), ConfigExportHandler(pipedriveService))
+	router.POST("/admin/config/import", RequireRole(pipedriveService, RoleAdmin), ConfigImportHandler(pipedriveService))
+	router.GET("/admin/meetings", RequireRole(pipedriveService, RoleReadOnly), ListMeetingsHandler(pipedriveService))
+	router.GET("/admin/campaign-templates", RequireRole(pipedriveService, RoleReadOnly), ListCampaignTemplatesHandler(pipedriveService))
+	router.PUT("/admin/campaign-templates/:name", RequireRole(pipedriveService, RoleAdmin), SaveCampaignTemplateHandler(pipedriveService))
+	router.DELETE("/admin/campaign-templates/:name", RequireRole(pipedriveService, RoleAdmin), DeleteCampaignTemplateHandler(pipedriveService))
+	router.GET("/admin/campaigns", RequireRole(pipedriveService, RoleReadOnly), ListCampaignsHandler(pipedriveService))
+	router.POST("/admin/campaigns", RequireRole(pipedriveService, RoleOperator), LaunchCampaignHandler(pipedriveService))
+	router.DELETE("/admin/campaigns/:name", RequireRole(pipedriveService, RoleOperator), StopCampaignHandler(pipedriveService))
+	router.GET("/admin/retries", RequireRole(pipedriveService, RoleOperator), ListPipedriveRetriesHandler(pipedriveService))
+	router.POST("/admin/retries/:id", RequireRole(pipedriveService, RoleOperator), RequeuePipedriveRetryHandler(pipedriveService))
+	router.DELETE("/admin/retries/:id", RequireRole(pipedriveService, RoleOperator), DiscardPipedriveRetryHandler(pipedriveService))
+	router.GET("/api/queue/positions", RequireRole(pipedriveService, RoleReadOnly), QueuePositionsHandler(pipedriveService))
+	router.GET("/admin/pipedrive/rate-limit", RequireRole(pipedriveService, RoleReadOnly), PipedriveRateLimitHandler(pipedriveService))
+	router.GET("/admin/pipedrive/webhooks/auth-check", RequireRole(pipedriveService, RoleAdmin), PipedriveWebhookAuthCheckHandler(pipedriveService))
+	router.POST("/admin/phones/backfill", RequireRole(pipedriveService, RoleAdmin), StartPhoneBackfillHandler(pipedriveService))
+	router.GET("/admin/phones/backfill", RequireRole(pipedriveService, RoleReadOnly), ListPhoneBackfillsHandler(pipedriveService))
+	router.GET("/admin/phones/backfill/:id", RequireRole(pipedriveService, RoleReadOnly), PhoneBackfillReportHandler(pipedriveService))
+	router.GET("/admin/webhook-gaps", RequireRole(pipedriveService, RoleReadOnly), WebhookGapsHandler(pipedriveService))
+	router.POST("/admin/webhook-gaps/check", RequireRole(pipedriveService, RoleOperator), WebhookGapCheckHandler(pipedriveService))
+	router.GET("/unsubscribe", UnsubscribeHandler(pipedriveService))
+	router.POST("/unsubscribe", UnsubscribeHandler(pipedriveService))
+	router.GET("/admin/email-unsubscribes", RequireRole(pipedriveService, RoleReadOnly), ListUnsubscribesHandler(pipedriveService))
+	router.POST("/admin/email-unsubscribes", RequireRole(pipedriveService, RoleOperator), AddUnsubscribesHandler(pipedriveService))
+	router.DELETE("/admin/email-unsubscribes/:email", RequireRole(pipedriveService, RoleOperator), RemoveUnsubscribeHandler(pipedriveService))
 
 	// Test endpoints
 	router.POST("/test/completed", func(c *gin.Context) {
@@ -75,7 +196,7 @@ func main() {
 			Event:        "call.completed",
 			CallID:       "test-call-" + strconv.FormatInt(time.Now().Unix(), 10),
 			ContactPhone: "+1234567890",
-			Duration:     "00:02:30",
+			Duration:     DurationFromSeconds(150),
 			Status:       "completed",
 			Transcript:   "This is a test call transcript for completed call.",
 			Timestamp:    time.Now().Format(time.RFC3339),
@@ -188,11 +309,7 @@ func main() {
 	})
 
 	// Root endpoint
-	router.GET("/", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "index.html", gin.H{
-			"title": "PipCal Webhook Server",
-		})
-	})
+	router.GET("/", TestConsoleHandler(pipedriveService))
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -207,8 +324,111 @@ func main() {
 	log.Printf("   POST /webhook/cal")
 	log.Printf("   POST /webhook/retell/analyzed")
 	log.Printf("   POST /webhook/pipedrive/lead")
+	log.Printf("   POST /webhook/stripe")
+	log.Printf("   POST /api/phones/validate")
+	log.Printf("   GET  /api/processing/:id")
+	log.Printf("   GET  /api/transcripts/:call_id")
+	log.Printf("   GET  /admin/calls")
+	log.Printf("   GET  /admin/retell/phone-numbers")
+	log.Printf("   POST /admin/retell/phone-numbers")
+	log.Printf("   PATCH /admin/retell/phone-numbers/:number")
+	log.Printf("   POST /admin/retell/onboard")
+	log.Printf("   POST /admin/retell/sync-variables")
+	log.Printf("   GET  /api/reports/experiments")
+	log.Printf("   POST /api/persons/:id/snooze")
+	log.Printf("   DELETE /api/persons/:id/snooze")
+	log.Printf("   GET  /admin/snoozes")
+	log.Printf("   GET  /admin/reports/owner-fallbacks")
+	log.Printf("   GET  /admin/workers")
+	log.Printf("   GET  /admin/log-level")
+	log.Printf("   POST /admin/log-level")
+	log.Printf("   GET  /api/reports/dial-rules")
+	log.Printf("   POST /api/bookings")
+	log.Printf("   GET  /admin/reminders")
+	log.Printf("   POST /functions/check-availability")
+	log.Printf("   POST /functions/book-meeting")
+	log.Printf("   POST /functions/lookup-contact")
+	log.Printf("   POST /functions/mark-dnc")
+	log.Printf("   GET  /api/reports/call-windows")
+	log.Printf("   GET  /admin/region")
+	log.Printf("   GET  /admin/config")
+	log.Printf("   POST /admin/retell/voicemail-drop")
+	log.Printf("   GET  /admin/calls/:id/events")
+	log.Printf("   POST /admin/calls/:id/replay")
+	log.Printf("   GET  /api/reports/kpis")
+	log.Printf("   POST /admin/kpis/write-back")
+	log.Printf("   GET  /admin/chaos")
+	log.Printf("   POST /admin/calls/:id/refresh")
+	log.Printf("   POST /admin/reconcile")
+	log.Printf("   GET  /api/reports/reconciliation")
+	log.Printf("   POST /webhook/pipedrive/deal")
+	log.Printf("   POST /webhook/pipedrive/activity")
+	log.Printf("   GET  /admin/nurtures")
+	log.Printf("   POST /webhook/email")
+	log.Printf("   GET  /webhook/facebook")
+	log.Printf("   POST /webhook/facebook")
+	log.Printf("   POST /api/leads/capture")
+	log.Printf("   GET  /internal/scaling")
+	log.Printf("   GET  /api/slo")
+	log.Printf("   GET  /admin/email-bounces")
+	log.Printf("   POST /admin/email-bounces")
+	log.Printf("   DELETE /admin/email-bounces/:email")
+	log.Printf("   GET  /admin/lost-marks")
+	log.Printf("   POST /admin/calls/:id/revert-lost")
+	log.Printf("   GET  /admin/persons/:id/memory")
+	log.Printf("   DELETE /admin/persons/:id/memory")
+	log.Printf("   GET  /admin/routing/team")
+	log.Printf("   GET  /admin/retention")
+	log.Printf("   POST /admin/retention/purge")
+	log.Printf("   GET  /test/fixtures")
+	log.Printf("   GET  /admin/support-bundle")
+	log.Printf("   POST /webhook/pipedrive/person")
+	log.Printf("   GET  /admin/person-changes")
+	log.Printf("   GET  /meetings/:token/invite.ics")
+	log.Printf("   GET  /r/:code")
+	log.Printf("   GET  /admin/short-links")
+	log.Printf("   POST /admin/short-links")
+	log.Printf("   GET  /api/reports/owner-digest")
+	log.Printf("   POST /admin/digests/send")
+	log.Printf("   GET  /api/audit")
+	log.Printf("   GET  /health/ready")
+	log.Printf("   POST /admin/pipedrive/features/:feature/enable")
+	log.Printf("   GET  /admin/webhooks")
+	log.Printf("   POST /admin/reprocess/:archived_id")
+	log.Printf("   GET  /admin/pipedrive/lead-labels")
+	log.Printf("   POST /admin/pipedrive/lead-labels")
+	log.Printf("   GET  /api/pipedrive/persons/:id")
+	log.Printf("   GET  /admin/retell/failover")
+	log.Printf("   POST /admin/retell/failback")
+	log.Printf("   POST /admin/activities/cleanup")
+	log.Printf("   GET  /admin/config/export")
+	log.Printf("   POST /admin/config/import")
+	log.Printf("   GET  /admin/meetings")
+	log.Printf("   GET  /admin/campaign-templates")
+	log.Printf("   PUT  /admin/campaign-templates/:name")
+	log.Printf("   DELETE /admin/campaign-templates/:name")
+	log.Printf("   GET  /admin/campaigns")
+	log.Printf("   POST /admin/campaigns")
+	log.Printf("   DELETE /admin/campaigns/:name")
+	log.Printf("   GET  /admin/retries")
+	log.Printf("   POST /admin/retries/:id")
+	log.Printf("   DELETE /admin/retries/:id")
+	log.Printf("   GET  /api/queue/positions")
+	log.Printf("   GET  /admin/pipedrive/rate-limit")
+	log.Printf("   GET  /admin/pipedrive/webhooks/auth-check")
+	log.Printf("   POST /admin/phones/backfill")
+	log.Printf("   GET  /admin/phones/backfill")
+	log.Printf("   GET  /admin/phones/backfill/:id")
+	log.Printf("   GET  /admin/webhook-gaps")
+	log.Printf("   POST /admin/webhook-gaps/check")
+	log.Printf("   GET  /unsubscribe")
+	log.Printf("   POST /unsubscribe")
+	log.Printf("   GET  /admin/email-unsubscribes")
+	log.Printf("   POST /admin/email-unsubscribes")
+	log.Printf("   DELETE /admin/email-unsubscribes/:email")
 	log.Printf("   POST /test/completed")
 	log.Printf("   POST /test/pipedrive-lead")
+	log.Printf("   POST /test/scenario/:name")
 
 	// Check if Pipedrive is configured
 	if config.HasPipedriveConfig() {
@@ -218,6 +438,76 @@ func main() {
 		log.Printf("   Set PIPEDRIVE_API_KEY to enable real Pipedrive integration")
 	}
 
+	// Check if admin API auth is configured
+	if config.HasAdminAuth() {
+		log.Printf("✅ Admin API authentication configured")
+	} else if config.adminAuthOpen() {
+		log.Printf("⚠️  Admin API authentication not configured - admin endpoints are OPEN (ADMIN_AUTH_OPEN, APP_ENV=%s)", config.AppEnv)
+	} else {
+		log.Printf("⚠️  Admin API authentication not configured - admin endpoints are closed")
+		log.Printf("   Set ADMIN_USERS or OIDC_ISSUER to allow access")
+	}
+
+	// Register mapped dynamic variables on the Retell agent
+	if config.SyncDynamicVariables && config.HasRetellConfig() {
+		go func() {
+			if _, err := pipedriveService.SyncAgentDynamicVariables(); err != nil {
+				log.Printf("⚠️ Failed to sync Retell dynamic variables: %v", err)
+			}
+		}()
+	}
+
+	// Resolve lead label names in the config to this account's label IDs
+	if config.HasPipedriveConfig() {
+		go pipedriveService.resolveLeadLabels()
+	}
+
+	// Daily KPI write-back to the AI SDR user
+	if config.KPIWriteBackEnabled && config.HasPipedriveConfig() {
+		go pipedriveService.runKPIWriteBack()
+	}
+
+	// Weekly digests to opted-in owners
+	if len(config.DigestOwners) > 0 {
+		go pipedriveService.runWeeklyDigest()
+	}
+
+	// Save short link click counts in batches
+	if config.ShortLinksFile != "" {
+		go pipedriveService.shortLinks.runFlush()
+	}
+
+	// Persist SLO history and alert on fast error budget burn
+	go pipedriveService.runSLOMonitor()
+
+	// Nightly purge of recordings and transcripts past their retention period
+	if config.RetentionRecordingDays > 0 || config.RetentionTranscriptDays > 0 {
+		go pipedriveService.runRetentionPurge()
+	}
+
+	// Nightly reconciliation of Retell calls against Pipedrive activities
+	if config.ReconcileEnabled && config.HasPipedriveConfig() && config.HasRetellConfig() {
+		go pipedriveService.runReconciliation()
+	}
+
+	// Hourly cleanup of initiation activities left pending by calls that never reported back
+	if config.StaleActivityHours > 0 && config.HasPipedriveConfig() {
+		go pipedriveService.runStaleActivityCleanup()
+	}
+
+	// Watch placed calls for Retell webhooks that never arrive and heal them from get-call
+	if config.WebhookGapEnabled && config.HasRetellConfig() {
+		go pipedriveService.runWebhookGapDetector()
+	}
+
+	// Re-attempt Pipedrive writes that failed with a rate limit or server error
+	if pipedriveService.retries != nil && config.HasPipedriveConfig() {
+		go pipedriveService.runPipedriveRetries()
+	}
+
+	// Re-arm held calls and follow-ups saved before a restart
+	pipedriveService.resumeScheduledJobs()
+
 	// Check if Retell AI is configured
 	if config.HasRetellConfig() {
 		log.Printf("✅ Retell AI configured")
@@ -226,34 +516,32 @@ func main() {
 		log.Printf("   Set RETELL_API_KEY and RETELL_ASSISTANT_ID to enable real Retell AI integration")
 	}
 
-	// Debug configuration
-	log.Printf("🔧 [DEBUG] Configuration details:")
-	log.Printf("   PIPEDRIVE_API_KEY: %s", config.PipedriveAPIKey)
-	log.Printf("   RETELL_API_KEY: %s", config.RetellAPIKey)
-	log.Printf("   RETELL_ASSISTANT_ID: %s", config.RetellAssistantID)
-	log.Printf("   RETELL_FROM_NUMBER: %s", config.RetellFromNumber)
-
 	log.Printf("🔧 Visit http://localhost:%s to test the webhooks!", port)
 	log.Printf("📖 See README.md for detailed usage instructions")
 
 	// Start the server
-	router.Run(":" + port)
+	server := newHTTPServer(":"+port, config, router)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("❌ Server stopped: %v", err)
+	}
 }
 
 // Handler is the main entry point for Vercel
 func Handler(w http.ResponseWriter, r *http.Request) {
+	captureLogs()
+
 	// Set Gin to release mode for Vercel
 	gin.SetMode(gin.ReleaseMode)
 	
 	// Create Gin router
 	router := gin.New()
-	router.Use(gin.Logger())
+	router.Use(RequestIDMiddleware())
 	router.Use(gin.Recovery())
 
 	// CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if c.Request.Method == "OPTIONS" {
@@ -266,9 +554,23 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 	// Load configuration
 	config := LoadConfig()
+	configureLogging(config)
+	configureTrustedProxies(router, config)
+
+	// Reject misrouted webhooks before anything reads or logs their payload
+	router.Use(RegionGuardMiddleware(config))
+
+	// Response compression and request dumps for debugged endpoints
+	router.Use(CompressionMiddleware(config))
+	router.Use(DebugRequestMiddleware())
 
 	// Create Pipedrive service
 	pipedriveService := NewPipedriveService(config)
+	router.Use(SLOMiddleware(pipedriveService))
+	router.Use(FailedWebhookCaptureMiddleware())
+	router.Use(WebhookAuthMiddleware(config))
+	router.Use(WebhookArchiveMiddleware(pipedriveService))
+	router.Use(WebhookBackpressureMiddleware(pipedriveService))
 
 	// Health check endpoint
 	router.GET("/health", HealthCheckHandler)
@@ -278,6 +580,111 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	router.POST("/webhook/cal", CalWebhookHandler(pipedriveService))
 	router.POST("/webhook/retell/analyzed", RetellCallAnalyzedHandler(pipedriveService))
 	router.POST("/webhook/pipedrive/lead", PipedriveLeadWebhookHandler(pipedriveService))
+	router.POST("/webhook/stripe", StripeWebhookHandler(pipedriveService))
+
+	// API endpoints
+	router.POST("/api/phones/validate", RequireRole(pipedriveService, RoleOperator), PhoneValidationHandler(pipedriveService))
+	router.GET("/api/processing/:id", RequireRole(pipedriveService, RoleReadOnly), ProcessingStatusHandler(pipedriveService))
+	router.GET("/api/transcripts/:call_id", RequireRole(pipedriveService, RoleReadOnly), TranscriptHandler(pipedriveService))
+	router.GET("/admin/calls", RequireRole(pipedriveService, RoleReadOnly), ListCallsHandler(pipedriveService))
+	router.GET("/admin/retell/phone-numbers", RequireRole(pipedriveService, RoleReadOnly), ListRetellPhoneNumbersHandler(pipedriveService))
+	router.POST("/admin/retell/phone-numbers", RequireRole(pipedriveService, RoleAdmin), PurchaseRetellPhoneNumberHandler(pipedriveService))
+	router.PATCH("/admin/retell/phone-numbers/:number", RequireRole(pipedriveService, RoleAdmin), AttachRetellPhoneNumberHandler(pipedriveService))
+	router.POST("/admin/retell/onboard", RequireRole(pipedriveService, RoleAdmin), RetellOnboardHandler(pipedriveService))
+	router.POST("/admin/retell/sync-variables", RequireRole(pipedriveService, RoleAdmin), SyncDynamicVariablesHandler(pipedriveService))
+	router.GET("/api/reports/experiments", RequireRole(pipedriveService, RoleReadOnly), ExperimentReportHandler(pipedriveService))
+	router.POST("/api/persons/:id/snooze", RequireRole(pipedriveService, RoleOperator), SnoozePersonHandler(pipedriveService))
+	router.DELETE("/api/persons/:id/snooze", RequireRole(pipedriveService, RoleOperator), UnsnoozePersonHandler(pipedriveService))
+	router.GET("/admin/snoozes", RequireRole(pipedriveService, RoleReadOnly), ListSnoozesHandler(pipedriveService))
+	router.GET("/admin/reports/owner-fallbacks", RequireRole(pipedriveService, RoleReadOnly), OwnerFallbackReportHandler(pipedriveService))
+	router.GET("/admin/workers", RequireRole(pipedriveService, RoleReadOnly), WorkerStatsHandler(pipedriveService))
+	router.GET("/admin/log-level", RequireRole(pipedriveService, RoleReadOnly), GetLogLevelHandler(pipedriveService))
+	router.POST("/admin/log-level", RequireRole(pipedriveService, RoleAdmin), SetLogLevelHandler(pipedriveService))
+	router.GET("/api/reports/dial-rules", RequireRole(pipedriveService, RoleReadOnly), DialRuleReportHandler(pipedriveService))
+	router.POST("/api/bookings", RequireRole(pipedriveService, RoleOperator), CreateBookingHandler(pipedriveService))
+	router.GET("/admin/reminders", RequireRole(pipedriveService, RoleReadOnly), ListRemindersHandler(pipedriveService))
+	router.POST("/functions/check-availability", RequireVerifiedWebhook(pipedriveService), CheckAvailabilityFunctionHandler(pipedriveService))
+	router.POST("/functions/book-meeting", RequireVerifiedWebhook(pipedriveService), BookMeetingFunctionHandler(pipedriveService))
+	router.POST("/functions/lookup-contact", RequireVerifiedWebhook(pipedriveService), LookupContactFunctionHandler(pipedriveService))
+	router.POST("/functions/mark-dnc", RequireVerifiedWebhook(pipedriveService), MarkDNCFunctionHandler(pipedriveService))
+	router.GET("/api/reports/call-windows", RequireRole(pipedriveService, RoleReadOnly), CallWindowReportHandler(pipedriveService))
+	router.GET("/admin/region", RequireRole(pipedriveService, RoleReadOnly), RegionStatusHandler(pipedriveService))
+	router.GET("/admin/config", RequireRole(pipedriveService, RoleAdmin), ConfigViewHandler(pipedriveService))
+	router.POST("/admin/retell/voicemail-drop", RequireRole(pipedriveService, RoleAdmin), VoicemailDropSetupHandler(pipedriveService))
+	router.GET("/admin/calls/:id/events", RequireRole(pipedriveService, RoleReadOnly), CallEventsHandler(pipedriveService))
+	router.POST("/admin/calls/:id/replay", RequireRole(pipedriveService, RoleAdmin), ReplayCallHandler(pipedriveService))
+	router.GET("/api/reports/kpis", RequireRole(pipedriveService, RoleReadOnly), KPIReportHandler(pipedriveService))
+	router.POST("/admin/kpis/write-back", RequireRole(pipedriveService, RoleOperator), KPIWriteBackHandler(pipedriveService))
+	router.GET("/admin/chaos", RequireRole(pipedriveService, RoleReadOnly), ChaosStatusHandler(pipedriveService))
+	router.POST("/admin/calls/:id/refresh", RequireRole(pipedriveService, RoleOperator), RefreshCallHandler(pipedriveService))
+	router.POST("/admin/reconcile", RequireRole(pipedriveService, RoleOperator), ReconcileHandler(pipedriveService))
+	router.GET("/api/reports/reconciliation", RequireRole(pipedriveService, RoleReadOnly), ReconciliationReportHandler(pipedriveService))
+	router.POST("/webhook/pipedrive/deal", PipedriveDealWebhookHandler(pipedriveService))
+	router.POST("/webhook/pipedrive/activity", PipedriveActivityWebhookHandler(pipedriveService))
+	router.GET("/admin/nurtures", RequireRole(pipedriveService, RoleReadOnly), ListNurturesHandler(pipedriveService))
+	router.POST("/webhook/email", EmailWebhookHandler(pipedriveService))
+	router.GET("/webhook/facebook", FacebookVerifyHandler(pipedriveService))
+	router.POST("/webhook/facebook", FacebookLeadsWebhookHandler(pipedriveService))
+	router.POST("/api/leads/capture", LeadCaptureHandler(pipedriveService))
+	router.GET("/internal/scaling", RequireRole(pipedriveService, RoleReadOnly), ScalingHandler(pipedriveService))
+	router.GET("/api/slo", RequireRole(pipedriveService, RoleReadOnly), SLOReportHandler(pipedriveService))
+	router.GET("/admin/email-bounces", RequireRole(pipedriveService, RoleReadOnly), ListEmailBouncesHandler(pipedriveService))
+	router.POST("/admin/email-bounces", RequireRole(pipedriveService, RoleOperator), AddEmailBouncesHandler(pipedriveService))
+	router.DELETE("/admin/email-bounces/:email", RequireRole(pipedriveService, RoleOperator), RemoveEmailBounceHandler(pipedriveService))
+	router.GET("/admin/lost-marks", RequireRole(pipedriveService, RoleReadOnly), ListLostMarksHandler(pipedriveService))
+	router.POST("/admin/calls/:id/revert-lost", RequireRole(pipedriveService, RoleOperator), RevertLostHandler(pipedriveService))
+	router.GET("/admin/persons/:id/memory", RequireRole(pipedriveService, RoleReadOnly), PersonMemoryHandler(pipedriveService))
+	router.DELETE("/admin/persons/:id/memory", RequireRole(pipedriveService, RoleOperator), ForgetPersonMemoryHandler(pipedriveService))
+	router.GET("/admin/routing/team", RequireRole(pipedriveService, RoleReadOnly), TeamRoutingHandler(pipedriveService))
+	router.GET("/admin/retention", RequireRole(pipedriveService, RoleReadOnly), RetentionStatusHandler(pipedriveService))
+	router.POST("/admin/retention/purge", RequireRole(pipedriveService, RoleAdmin), RetentionPurgeHandler(pipedriveService))
+	router.GET("/test/fixtures", RequireTestConsole(pipedriveService), TestFixturesHandler)
+	router.POST("/test/scenario/:name", ScenarioHandler(pipedriveService))
+	router.GET("/admin/support-bundle", RequireRole(pipedriveService, RoleAdmin), SupportBundleHandler(pipedriveService))
+	router.POST("/webhook/pipedrive/person", PipedrivePersonWebhookHandler(pipedriveService))
+	router.GET("/admin/person-changes", RequireRole(pipedriveService, RoleReadOnly), PersonChangesHandler(pipedriveService))
+	router.GET("/meetings/:token/invite.ics", MeetingInviteHandler(pipedriveService))
+	router.GET("/r/:code", ShortLinkRedirectHandler(pipedriveService))
+	router.GET("/admin/short-links", RequireRole(pipedriveService, RoleReadOnly), ListShortLinksHandler(pipedriveService))
+	router.POST("/admin/short-links", RequireRole(pipedriveService, RoleOperator), CreateShortLinkHandler(pipedriveService))
+	router.GET("/api/reports/owner-digest", RequireRole(pipedriveService, RoleReadOnly), OwnerDigestReportHandler(pipedriveService))
+	router.POST("/admin/digests/send", RequireRole(pipedriveService, RoleAdmin), SendDigestsHandler(pipedriveService))
+	router.GET("/api/audit", RequireRole(pipedriveService, RoleReadOnly), AuditLogHandler(pipedriveService))
+	router.GET("/health/ready", ReadinessHandler(pipedriveService))
+	router.POST("/admin/pipedrive/features/:feature/enable", RequireRole(pipedriveService, RoleAdmin), EnablePipedriveFeatureHandler(pipedriveService))
+	router.GET("/admin/webhooks", RequireRole(pipedriveService, RoleAdmin), ListArchivedWebhooksHandler(pipedriveService))
+	router.POST("/admin/reprocess/:archived_id", RequireRole(pipedriveService, RoleAdmin), ReprocessWebhookHandler(pipedriveService))
+	router.GET("/admin/pipedrive/lead-labels", RequireRole(pipedriveService, RoleReadOnly), ListLeadLabelsHandler(pipedriveService))
+	router.POST("/admin/pipedrive/lead-labels", RequireRole(pipedriveService, RoleAdmin), CreateLeadLabelHandler(pipedriveService))
+	router.GET("/api/pipedrive/persons/:id", RequireRole(pipedriveService, RoleReadOnly), PersonProxyHandler(pipedriveService))
+	router.GET("/admin/retell/failover", RequireRole(pipedriveService, RoleReadOnly), RetellFailoverStatusHandler(pipedriveService))
+	router.POST("/admin/retell/failback", RequireRole(pipedriveService, RoleAdmin), RetellFailbackHandler(pipedriveService))
+	router.POST("/admin/activities/cleanup", RequireRole(pipedriveService, RoleOperator), StaleActivityCleanupHandler(pipedriveService))
+	router.GET("/admin/config/export", RequireRole(pipedriveService, RoleAdmin), ConfigExportHandler(pipedriveService))
+	router.POST("/admin/config/import", RequireRole(pipedriveService, RoleAdmin), ConfigImportHandler(pipedriveService))
+	router.GET("/admin/meetings", RequireRole(pipedriveService, RoleReadOnly), ListMeetingsHandler(pipedriveService))
+	router.GET("/admin/campaign-templates", RequireRole(pipedriveService, RoleReadOnly), ListCampaignTemplatesHandler(pipedriveService))
+	router.PUT("/admin/campaign-templates/:name", RequireRole(pipedriveService, RoleAdmin), SaveCampaignTemplateHandler(pipedriveService))
+	router.DELETE("/admin/campaign-templates/:name", RequireRole(pipedriveService, RoleAdmin), DeleteCampaignTemplateHandler(pipedriveService))
+	router.GET("/admin/campaigns", RequireRole(pipedriveService, RoleReadOnly), ListCampaignsHandler(pipedriveService))
+	router.POST("/admin/campaigns", RequireRole(pipedriveService, RoleOperator), LaunchCampaignHandler(pipedriveService))
+	router.DELETE("/admin/campaigns/:name", RequireRole(pipedriveService, RoleOperator), StopCampaignHandler(pipedriveService))
+	router.GET("/admin/retries", RequireRole(pipedriveService, RoleOperator), ListPipedriveRetriesHandler(pipedriveService))
+	router.POST("/admin/retries/:id", RequireRole(pipedriveService, RoleOperator), RequeuePipedriveRetryHandler(pipedriveService))
+	router.DELETE("/admin/retries/:id", RequireRole(pipedriveService, RoleOperator), DiscardPipedriveRetryHandler(pipedriveService))
+	router.GET("/api/queue/positions", RequireRole(pipedriveService, RoleReadOnly), QueuePositionsHandler(pipedriveService))
+	router.GET("/admin/pipedrive/rate-limit", RequireRole(pipedriveService, RoleReadOnly), PipedriveRateLimitHandler(pipedriveService))
+	router.GET("/admin/pipedrive/webhooks/auth-check", RequireRole(pipedriveService, RoleAdmin), PipedriveWebhookAuthCheckHandler(pipedriveService))
+	router.POST("/admin/phones/backfill", RequireRole(pipedriveService, RoleAdmin), StartPhoneBackfillHandler(pipedriveService))
+	router.GET("/admin/phones/backfill", RequireRole(pipedriveService, RoleReadOnly), ListPhoneBackfillsHandler(pipedriveService))
+	router.GET("/admin/phones/backfill/:id", RequireRole(pipedriveService, RoleReadOnly), PhoneBackfillReportHandler(pipedriveService))
+	router.GET("/admin/webhook-gaps", RequireRole(pipedriveService, RoleReadOnly), WebhookGapsHandler(pipedriveService))
+	router.POST("/admin/webhook-gaps/check", RequireRole(pipedriveService, RoleOperator), WebhookGapCheckHandler(pipedriveService))
+	router.GET("/unsubscribe", UnsubscribeHandler(pipedriveService))
+	router.POST("/unsubscribe", UnsubscribeHandler(pipedriveService))
+	router.GET("/admin/email-unsubscribes", RequireRole(pipedriveService, RoleReadOnly), ListUnsubscribesHandler(pipedriveService))
+	router.POST("/admin/email-unsubscribes", RequireRole(pipedriveService, RoleOperator), AddUnsubscribesHandler(pipedriveService))
+	router.DELETE("/admin/email-unsubscribes/:email", RequireRole(pipedriveService, RoleOperator), RemoveUnsubscribeHandler(pipedriveService))
 
 	// Test endpoints
 	router.POST("/test/completed", func(c *gin.Context) {
@@ -285,7 +692,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 			Event:        "call.completed",
 			CallID:       "test-call-" + strconv.FormatInt(time.Now().Unix(), 10),
 			ContactPhone: "+1234567890",
-			Duration:     "00:02:30",
+			Duration:     DurationFromSeconds(150),
 			Status:       "completed",
 			Transcript:   "This is a test call transcript for completed call.",
 			Timestamp:    time.Now().Format(time.RFC3339),
@@ -409,6 +816,7 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 					"cal": "/webhook/cal",
 					"retell_analyzed": "/webhook/retell/analyzed",
 					"pipedrive_lead": "/webhook/pipedrive/lead",
+					"stripe": "/webhook/stripe",
 				},
 				"test": gin.H{
 					"completed": "/test/completed",
@@ -430,10 +838,51 @@ type Config struct {
 	Port string
 	Host string
 
+	// HTTP server and outbound client tuning (seconds / connection counts)
+	ServerReadHeaderTimeout     int
+	ServerReadTimeout           int
+	ServerIdleTimeout           int
+	ResponseCompression         bool
+	OutboundMaxIdleConns        int
+	OutboundMaxIdleConnsPerHost int
+	OutboundIdleConnTimeout     int
+
+	// Outbound TLS (custom CA bundle for on-prem gateways)
+	AppEnv                        string // "production" unless set; insecure TLS is refused in production
+	OutboundCAFile                string
+	OutboundTLSMinVersion         string
+	OutboundTLSInsecureSkipVerify bool
+
+	// Failure injection for staging (refused in production)
+	ChaosEnabled bool
+	ChaosRules   []ChaosRule
+
+	// Webhook test console at / (refused in production)
+	TestConsoleEnabled bool
+
 	// Pipedrive API configuration (for real integration)
 	PipedriveAPIKey    string
 	PipedriveBaseURL   string
 	PipedriveCompanyID string
+	PipedriveWebURL    string // e.g. https://yourcompany.pipedrive.com, for links in the test console
+
+	// Pipedrive API version used for activities (v1 or v2)
+	PipedriveActivityAPI string
+
+	// Features the Pipedrive plan or token scopes refuse (402/403) are paused and re-checked
+	PipedriveFeatureRetryHours int
+	AlertWebhookURL            string // Slack-compatible webhook for operational alerts
+
+	// Send If-None-Match/If-Modified-Since on Pipedrive GETs
+	PipedriveConditionalRequests bool
+
+	// Token bucket for Pipedrive requests; requests wait in line for a token
+	PipedriveRateLimit   int // Requests per second; 0 disables
+	PipedriveRateBurst   int // Bucket size (default: twice the limit)
+	PipedriveRateMaxWait int // Seconds a request may wait before it fails
+
+	// How long GET /api/pipedrive/persons/:id reuses a person read from Pipedrive
+	PersonProxyCacheSeconds int
 
 	// Retell AI configuration
 	RetellAPIKey       string
@@ -441,25 +890,471 @@ type Config struct {
 	RetellBaseURL      string
 	RetellFromNumber   string
 
+	// Backup Retell account new calls fail over to when the primary errors or runs out of capacity
+	RetellSecondaryAPIKey        string
+	RetellSecondaryBaseURL       string
+	RetellSecondaryAgentID       string            // Agent used on the secondary account for unmapped agents
+	RetellSecondaryAgents        map[string]string // Primary agent ID -> secondary agent ID
+	RetellSecondaryFromNumber    string
+	RetellSecondaryWebhookSecret string // Signs webhooks of calls placed on the secondary account
+	RetellFailoverErrors         int    // Provider errors among the last RetellFailoverWindow calls that fail over
+	RetellFailoverWindow         int
+
 	// Webhook security (optional)
-	RetellWebhookSecret string
-	CalWebhookSecret    string
+	RetellWebhookSecret      string
+	CalWebhookSecret         string
+	PipedriveWebhookUser     string // Basic auth credentials set on the Pipedrive webhooks
+	PipedriveWebhookPassword string
+	WebhookVerifyBypass      bool // Skip verification for local testing; ignored in production
+
+	PipedriveWebhookCredentials map[string]PipedriveWebhookCredentials // Per-tenant Pipedrive webhook credentials, matched by meta.company_id
+
+	// Retell custom analysis schemas, keyed by assistant/agent ID
+	AnalysisSchemas map[string]AnalysisSchema
+
+	// WhatsApp follow-up configuration
+	WhatsAppProvider        string // "meta" or "twilio"
+	WhatsAppAccessToken     string
+	WhatsAppPhoneNumberID   string
+	WhatsAppTemplateName    string // Approved Meta template with one body parameter; Meta messages are sent through it
+	WhatsAppTemplateLang    string
+	TwilioAccountSID        string
+	TwilioAuthToken         string
+	TwilioWhatsAppFrom      string
+	TwilioSMSFrom           string
+	SMSOptOutText           string // Appended to automated texts that don't mention STOP
+	WhatsAppFollowUpEnabled bool
+	WhatsAppActivityType    string
+	FollowUpMeetingURL      string
+	FollowUpTemplate        string // Placeholders: {{name}}, {{summary}}, {{meeting_url}}
+
+	// Stripe payment links
+	StripeAPIKey        string
+	StripePriceID       string
+	StripeWebhookSecret string
+	StripeIntentField   string
+	StripeSendLink      bool
+	StripeLinksFile     string // JSON file payment links are saved to; empty keeps them in memory
+
+	// Cal.com API for availability lookups and bookings
+	CalAPIKey       string
+	CalAPIURL       string
+	CalEventTypeID  int
+	CalTimeZone     string
+	CalSlotDays     int
+	CalOfferedSlots int
+
+	// Appointment reminders before Cal.com meetings
+	RemindersEnabled    bool
+	ReminderChannel     string // "sms" or "call"
+	ReminderHoursBefore int
+	ReminderAgentID     string
+	ReminderSMSTemplate string // Placeholders: {{name}}, {{title}}, {{time}}
+
+	// Follow-up sequences after Cal.com meeting outcomes (attended, no_show, host_no_show)
+	PostMeetingSequences map[string][]MeetingFollowUpStep
+
+	// Phone lookup pre-flight
+	PhoneLookupProvider string // "twilio"
+	PhoneLookupPreDial  bool
+	PhoneLineTypeField  string
+
+	// First-touch channel (voice or SMS) per line type and campaign
+	Channels         ChannelPolicy
+	CampaignChannels map[string]ChannelPolicy
+	ChannelField     string // Person field recording the chosen channel
+
+	// Automation toggles (each automation can be switched off independently)
+	AutoCallOnLead        bool
+	ActivityOnCallStarted bool
+	NoteOnAnalysis        bool
+	DNCOnOptout           bool
+	CalActivityCreation   bool
+	CalICSMode            string // off, attach (Files API) or link (served .ics linked in the note)
+	MeetingInvitesFile    string // Linked invites kept across restarts
+	DNCField              string
+
+	// Respond 202 and process long-running webhooks in the background
+	AsyncWebhookProcessing bool
+
+	// Transcript handling and LLM summarization
+	TranscriptNoteLimit int
+	TranscriptChunkSize int
+	PublicBaseURL       string
+	LLMAPIKey           string
+	LLMBaseURL          string
+	LLMModel            string
+
+	// Key moment extraction
+	KeyMomentKeywords map[string][]string
+	KeyMomentsLLM     bool
+
+	// Admin API access control
+	AdminUsers    []AdminUser
+	OIDCIssuer    string
+	OIDCAudience  string
+	OIDCRoleClaim string
+	AdminAuthOpen bool // Leave the admin API open when no users are configured; ignored in production
+
+	// Dynamic variables sent to Retell, mapped from Pipedrive person fields
+	DynamicVariableMapping map[string]string
+	SyncDynamicVariables   bool
+	LeadFieldMapping       map[string]string
+
+	// A/B testing of assistants per campaign
+	AgentExperiments        map[string][]ExperimentVariant
+	ExperimentCampaignField string
+	ExperimentsFile         string // JSON file per-variant stats are saved to; empty keeps them in memory
+
+	// Retell from-number per lead source or label
+	FromNumberRules []FromNumberRule
+
+	// Owners assigned when a lead's owner is deactivated
+	FallbackOwnerIDs []int
+
+	// Pipedrive team whose members take turns on unowned leads' activities
+	RoutingTeamID    int
+	RoutingStateFile string
+
+	// Blackout and deprioritization rules by phone prefix or carrier
+	DialRules []DialRule
+
+	// Lead scoring from call analysis and Pipedrive data
+	LeadScoringRules []ScoringRule
+	LeadScoreField   string
+
+	// Linking call and meeting activities to the person's open deal
+	DealLinkingEnabled bool
+	DealLinkPipelineID int    // Only consider open deals in this pipeline; 0 = any
+	DealLinkMultiple   string // "skip" or "latest" when several open deals match
+
+	// Deals created from successful calls with positive sentiment
+	DealCreationEnabled bool
+	DealPipelineID      int  // Pipeline new deals are created in; 0 = default pipeline
+	DealStageID         int  // Stage new deals are created in; 0 = first stage
+	DealAdvanceExisting bool // Move the person's open deal to DealStageID instead of leaving it
+
+	// Escalation of negative calls with risk keywords
+	EscalationEnabled      bool
+	EscalationKeywords     map[string][]string // Label -> phrases said by the contact
+	EscalationWebhookURL   string              // Slack-compatible channel notified with the excerpt; "" = none
+	EscalationWebhookURLs  map[string]string   // Tenant -> its own escalation channel
+	EscalationManagerID    int                 // User the task is assigned to; 0 = call owner
+	EscalationActivityType string
+	EscalationPriority     int // Pipedrive activity priority ID for the task; 0 = not set
+	EscalationContextTurns int // Turns before and after the match included in the excerpt
+
+	// Voicemail drop when Retell detects voicemail
+	VoicemailDropMessage  string // Placeholders: {{name}}, {{lead}}
+	VoicemailDropAudioURL string
+	VoicemailActivityType string
+
+	// Activity types and note templates for inbound vs outbound Retell calls
+	InboundActivityType  string
+	OutboundActivityType string
+	InboundNoteTemplate  string // Placeholders: {{subject}}, {{call_id}}, {{phone}}, {{from}}, {{to}}, {{agent}}, {{direction}}, {{time}}, {{duration}}, {{status}}, {{event}}
+	OutboundNoteTemplate string
+
+	// Distributed locks so only one replica dispatches per lead/call
+	RedisURL     string
+	LockTTL      int // Seconds a lease is held
+	LockPrefix   string
+	LockFailOpen bool // Proceed without the lease when Redis is unreachable, risking duplicates
+
+	// Call mapping storage, so analyzed calls resolve after restarts and across replicas
+	CallMappingStore    string // memory (default), redis or postgres
+	CallMappingRedisKey string // Key prefix the redis store keeps mappings under
+	CallMappingTTLDays  int    // Days a mapping is kept after it was last written; 0 keeps it
+	DatabaseURL         string // Postgres connection URL for the postgres store
+
+	// Call lifecycle event log
+	CallEventsFile string // JSON lines file; empty keeps events in memory
+
+	// Audit log of automated actions
+	AuditLogFile    string // JSON lines file; empty keeps the log in memory
+	AuditMaxEntries int    // Most recent entries kept; 0 keeps all
+
+	// Archive of inbound webhooks for reprocessing
+	WebhookArchiveFile string // JSON lines file; empty keeps the archive in memory
+	WebhookArchiveSize int    // Most recent webhooks kept
+
+	// Daily AI KPI write-back to Pipedrive
+	KPIWriteBackEnabled bool
+	KPIWriteBackHour    int // Hour of day the previous day is written back
+	KPITimeZone         string
+	KPIUserID           int // "AI SDR" Pipedrive user the activities are assigned to
+	KPIActivityType     string
+
+	// Nightly Retell/Pipedrive reconciliation
+	ReconcileEnabled bool
+	ReconcileHour    int // Hour of day (KPI_TIME_ZONE) the previous day is reconciled
+
+	// Queued lead calls
+	QueueETAActivity bool   // Create the "AI Call Initiated" activity when a call is queued, with its ETA
+	ScheduleFile     string // JSON file held calls and follow-ups are saved to; empty keeps them in memory
+
+	// Hourly cleanup of pending "AI Call Initiated" activities left open by calls that never reported back
+	StaleActivityHours int // Age after which a pending initiation activity is closed or deleted; 0 disables
+
+	// Retell webhook gap detection: placed calls are expected to report started, ended and analyzed
+	WebhookGapEnabled         bool
+	WebhookGapStartedMinutes  int // Wait after dialing for call_started
+	WebhookGapEndedMinutes    int // Wait after call_started for call_ended
+	WebhookGapAnalyzedMinutes int // Wait after call_ended for call_analyzed
+	WebhookGapMaxChecks       int // get-call attempts before a gap is reported unresolved
+
+	// Retry queue for activity, note and person writes that failed with 429, 5xx or a network error
+	PipedriveRetryMaxAttempts     int    // Attempts including the original request before a write is dead-lettered; 1 disables
+	PipedriveRetryBaseSeconds     int    // Wait before the first retry, doubled for each further attempt
+	PipedriveRetryMaxDelayMinutes int    // Cap on the wait between attempts
+	PipedriveRetryFile            string // JSON file pending retries and dead letters are saved to; empty keeps them in memory
+
+	// Deal-stage nurture calls
+	NurtureRules []NurtureRule
+
+	// Inbound email leads (SendGrid Inbound Parse / Mailgun routes)
+	EmailWebhookToken      string // Expected as ?token= (SendGrid Inbound Parse)
+	EmailWebhookSigningKey string // Mailgun webhook signing key
+	EmailDedupFile         string // JSON file processed emails are saved to; empty keeps them in memory
+
+	// Facebook Lead Ads
+	FacebookAppSecret       string
+	FacebookVerifyToken     string
+	FacebookPageAccessToken string
+	FacebookGraphURL        string
+	FacebookFieldMapping    map[string]string // Form field -> name, email, phone, person.<key> or lead.<key>
+	FacebookLeadAutoCall    bool
+
+	// Landing page lead capture
+	CaptureRateLimit       int    // Submissions per client IP per minute, 0 disables the limit
+	CaptureCaptchaProvider string // hcaptcha, recaptcha or empty for none
+	CaptureCaptchaSecret   string
+	CaptureMinCaptchaScore float64 // Minimum reCAPTCHA v3 score
+	CaptureLeadAutoCall    bool
+	TrustedProxies         []string // Proxy IPs/CIDRs whose X-Forwarded-For gives the client IP; empty uses the connection address
+	TrustedClientIPHeader  string   // Header set by the hosting platform with the client IP, e.g. CF-Connecting-IP
+
+	// UTM/source attribution
+	AttributionFieldMapping map[string]string // Attribution key -> person.<key> or lead.<key>
+
+	// Webhook latency and success objectives
+	SLOEndpoints       []string // Route prefixes tracked against the SLO; "job:" prefixes match background job kinds
+	SLOTarget          float64  // Share of requests that must succeed within SLOLatencyMs
+	SLOLatencyMs       int
+	SLOJobLatencyMs    int                  // Latency objective of background jobs, from acceptance to completion
+	SLOTargets         map[string]SLOTarget // Per-route overrides
+	SLOWindowDays      int
+	SLOFile            string
+	SLOAlertWebhookURL string
+	SLOFastBurnRate    float64
+	SLOSlowBurnRate    float64
+
+	// Email validation before creating contacts
+	EmailMXCheck        bool
+	EmailBounceListFile string // One address or "@domain" per line
+
+	// Closing leads and deals after rejected calls
+	LostReasonEnabled bool
+	LostReasonField   string            // Custom analysis key holding the call outcome
+	LostReasonMapping map[string]string // Analysis value -> Pipedrive lost reason
+	LostReasonTargets []string          // "lead", "deal"
+	LostMarksFile     string            // Lost marks kept across restarts so they can be reverted
+
+	// Conversation memory passed to repeat calls
+	MemoryEnabled          bool
+	MemoryDisabledTenants  []string
+	MemoryMaxCalls         int
+	MemoryMaxChars         int
+	MemoryCommitmentsField string // Custom analysis key listing commitments made on the call
+	MemoryFile             string
+
+	// Retention of call recordings and transcripts (days; 0 keeps forever)
+	RetentionRecordingDays        int
+	RetentionTranscriptDays       int
+	RetentionPurgeHour            int
+	RetentionDeleteRetellCalls    bool   // Also delete the call and its recording in Retell
+	RetentionRedactPipedriveNotes bool   // Also strip the recording links and transcript from the call's Pipedrive activity
+	RetentionLogFile              string // JSON lines log of purged items
+
+	// Person update webhooks
+	PersonMonitoredFields []string // Extra person field keys to diff besides phone, DNC and owner
+	PersonNormalizePhones bool     // Rewrite changed phones in dialable form
+	PersonHoldsFile       string   // Persons whose calls are held, kept across restarts
+
+	// Phone backfill
+	PhoneCountryCode        string            // Calling code assumed for numbers without one
+	TenantPhoneCountryCodes map[string]string // Per-tenant calling codes
+	PhoneBackfillField      string            // Person field receiving the E.164 number; also preferred when dialing
+	PhoneBackfillFixPrimary bool              // Rewrite the main phone field in E.164
+
+	// Skip or defer AI calls to persons a rep has recently called or emailed
+	HumanActivity       HumanActivityPolicy
+	TenantHumanActivity map[string]HumanActivityPolicy
+
+	// Skip leads whose person we spoke to recently, e.g. on list re-imports
+	RecentContactDays         int
+	CampaignRecentContactDays map[string]int // Campaign -> days, overriding RecentContactDays
+	RecentContactTypes        []string       // Pipedrive activity types that count as contact
+
+	// Tracked short links in SMS and WhatsApp messages
+	ShortLinksEnabled bool
+	ShortLinkBaseURL  string // Vanity domain serving /r/:code; defaults to PUBLIC_BASE_URL
+	ShortLinksFile    string
+	ShortLinkTTLDays  int // Links stop resolving this long after creation; 0 keeps them forever
+
+	// Campaign templates and launched campaigns, managed through the admin API
+	CampaignsFile string // JSON file they are saved to; empty keeps them in memory
+
+	// Outbound email
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Meeting confirmation emails for Cal.com bookings
+	MeetingConfirmationEmail   bool
+	MeetingConfirmationDefault MeetingConfirmationSender
+	MeetingConfirmationSenders map[string]MeetingConfirmationSender // Tenant -> sender
+
+	// Call summary emails to the contact after answered calls
+	CallSummaryEmail             bool
+	CallSummaryEmailTenants      []string // Tenants whose contacts get summary emails; empty for all
+	CallSummaryDefault           CallSummarySender
+	CallSummarySenders           map[string]CallSummarySender // Tenant -> sender
+	CallSummaryEmailField        string                       // Custom analysis key with a prospect-facing summary; falls back to call_summary
+	CallSummaryNextStepsField    string                       // Custom analysis key listing next steps
+	CallSummaryUnsubscribeSecret string                       // Signs unsubscribe links
+	CallSummaryUnsubscribeFile   string                       // One unsubscribed address or "@domain" per line; empty keeps the list in memory
+
+	// Weekly per-owner digest
+	DigestOwners  map[string]DigestRecipient // Opted-in Pipedrive user ID -> recipient
+	DigestWeekday string
+	DigestHour    int
+
+	// Per-person coalescing of lead webhook bursts
+	LeadCoalesceSeconds int // 0 disables coalescing
+
+	// Best time to call learning
+	CallWindowTimeZone string
+	CallWindowBias     bool
+	CallWindowMaxDelay int             // Hours a lead may wait for a better window
+	ContactHours       *CampaignWindow // Automated calls and texts only go out within these hours; nil allows any time
+
+	// Call library export (Notion / Google Drive)
+	NotionAPIKey          string
+	NotionDatabaseID      string
+	NotionPropertyMapping map[string]string
+	GoogleServiceAccount  string
+	GoogleDriveFolderID   string
+	ExportMaxAttempts     int
+
+	// Google Calendar rep availability and meeting holds (reuses GOOGLE_SERVICE_ACCOUNT)
+	GoogleCalendarEnabled        bool
+	GoogleCalendarIDs            map[string]string
+	GoogleCalendarMeetingMinutes int
+
+	// Lead write-back after a successful contact
+	LeadWriteBack          bool
+	LeadContactedLabelID   string
+	LeadContactedLabel     string // Label name, used when LeadContactedLabelID is not set
+	LeadLastContactedField string
+
+	// Lead labels named in the config are created when missing from the Pipedrive account
+	LeadLabelsAutoCreate bool
+
+	// Zone naive timestamps (without an offset) are read in; everything is stored in UTC
+	NaiveTimestampZone string
+
+	// Recording consent announcement and logging
+	RecordingConsentEnabled bool
+	ConsentAgentID          string
+	ConsentRequiredPrefixes []string
+	ConsentField            string
+
+	// Tenant isolation and encryption at rest
+	AgentTenants         map[string]string
+	Region               string            // Region of this deployment, e.g. "eu"
+	TenantRegions        map[string]string // Tenant -> region
+	TenantEncryptionKeys map[string]string
+	TranscriptEncryption bool
+
+	// Date and time formatting in notes and messages
+	DefaultLocale Locale
+	TenantLocales map[string]Locale
+
+	// Background worker pool
+	WorkerPoolSize        int
+	WorkerQueueDepth      int
+	WorkerBackpressure    string
+	WorkerEnqueueTimeout  int
+	WorkerPriorityWorkers int // Workers reserved for opt-out and compliance jobs
+
+	// 429s from one vendor within the throttle window before /internal/scaling reports vendor-bound
+	ScalingVendorThrottles int
+
+	// 429 responses to webhook senders that retry while the worker queue is saturated
+	WebhookShedPaths        []string // Route prefixes that may be throttled
+	WebhookShedQueuePercent int      // Queue fill (% of WORKER_QUEUE_DEPTH) that triggers 429s; 0 disables
+	WebhookShedMaxWait      int      // Seconds the oldest queued job may wait before 429s; 0 disables
+	WebhookRetryAfter       int      // Retry-After seconds sent with 429s
 
 	// Logging configuration
-	LogLevel string
+	LogLevel        string // debug, info, warn or error
+	LogFormat       string // text or json
+	DebugSubsystems []string
 }
 
 // LoadConfig loads configuration from environment variables with defaults
 func LoadConfig() *Config {
+	if err := loadConfigFile(); err != nil {
+		log.Printf("❌ Ignoring invalid config file, using env vars and defaults only: %v", err)
+	}
+
 	config := &Config{
 		// Server defaults
 		Port: getEnv("PORT", "8080"),
 		Host: getEnv("HOST", "0.0.0.0"),
 
+		// HTTP tuning
+		ServerReadHeaderTimeout:     getEnvAsInt("SERVER_READ_HEADER_TIMEOUT", 5),
+		ServerReadTimeout:           getEnvAsInt("SERVER_READ_TIMEOUT", 15),
+		ServerIdleTimeout:           getEnvAsInt("SERVER_IDLE_TIMEOUT", 120),
+		ResponseCompression:         getEnvAsBool("RESPONSE_COMPRESSION", true),
+		OutboundMaxIdleConns:        getEnvAsInt("OUTBOUND_MAX_IDLE_CONNS", 100),
+		OutboundMaxIdleConnsPerHost: getEnvAsInt("OUTBOUND_MAX_IDLE_CONNS_PER_HOST", 20),
+		OutboundIdleConnTimeout:     getEnvAsInt("OUTBOUND_IDLE_CONN_TIMEOUT", 90),
+
+		// Outbound TLS
+		AppEnv:                        strings.ToLower(getEnv("APP_ENV", "production")),
+		OutboundCAFile:                getEnv("OUTBOUND_CA_FILE", ""),
+		OutboundTLSMinVersion:         getEnv("OUTBOUND_TLS_MIN_VERSION", "1.2"),
+		OutboundTLSInsecureSkipVerify: getEnvAsBool("OUTBOUND_TLS_INSECURE_SKIP_VERIFY", false),
+
+		// Failure injection (JSON: list of target/fault/percent rules)
+		ChaosEnabled: getEnvAsBool("CHAOS_ENABLED", false),
+		ChaosRules:   loadChaosRules(getEnv("CHAOS_RULES", "")),
+
+		TestConsoleEnabled: getEnvAsBool("TEST_CONSOLE_ENABLED", false),
+
 		// Pipedrive configuration
 		PipedriveAPIKey:    getEnv("PIPEDRIVE_API_KEY", ""),
 		PipedriveBaseURL:   getEnv("PIPEDRIVE_BASE_URL", "https://api.pipedrive.com/v1"),
 		PipedriveCompanyID: getEnv("PIPEDRIVE_COMPANY_ID", ""),
+		PipedriveWebURL:    getEnv("PIPEDRIVE_WEB_URL", ""),
+
+		PipedriveActivityAPI: normalizeActivityAPIVersion(getEnv("PIPEDRIVE_ACTIVITY_API", PipedriveActivityAPIV1)),
+
+		PipedriveFeatureRetryHours: getEnvAsInt("PIPEDRIVE_FEATURE_RETRY_HOURS", 24),
+		AlertWebhookURL:            getEnv("ALERT_WEBHOOK_URL", getEnv("SLO_ALERT_WEBHOOK_URL", "")),
+
+		PipedriveConditionalRequests: getEnvAsBool("PIPEDRIVE_CONDITIONAL_REQUESTS", false),
+
+		PipedriveRateLimit:   getEnvAsInt("PIPEDRIVE_RATE_LIMIT", 0),
+		PipedriveRateBurst:   getEnvAsInt("PIPEDRIVE_RATE_BURST", 0),
+		PipedriveRateMaxWait: getEnvAsInt("PIPEDRIVE_RATE_MAX_WAIT_SECONDS", 30),
+
+		PersonProxyCacheSeconds: getEnvAsInt("PERSON_PROXY_CACHE_SECONDS", 60),
 
 		// Retell AI configuration
 		RetellAPIKey:       getEnv("RETELL_API_KEY", ""),
@@ -467,12 +1362,448 @@ func LoadConfig() *Config {
 		RetellBaseURL:      getEnv("RETELL_BASE_URL", "https://api.retellai.com"),
 		RetellFromNumber:   getEnv("RETELL_FROM_NUMBER", "18005300627"),
 
+		// Retell failover
+		RetellSecondaryAPIKey:        getEnv("RETELL_SECONDARY_API_KEY", ""),
+		RetellSecondaryBaseURL:       getEnv("RETELL_SECONDARY_BASE_URL", ""),
+		RetellSecondaryAgentID:       getEnv("RETELL_SECONDARY_AGENT_ID", ""),
+		RetellSecondaryAgents:        loadDynamicVariableMapping(getEnv("RETELL_SECONDARY_AGENTS", "")),
+		RetellSecondaryFromNumber:    getEnv("RETELL_SECONDARY_FROM_NUMBER", ""),
+		RetellSecondaryWebhookSecret: getEnv("RETELL_SECONDARY_WEBHOOK_SECRET", ""),
+		RetellFailoverErrors:         getEnvAsInt("RETELL_FAILOVER_ERRORS", 5),
+		RetellFailoverWindow:         getEnvAsInt("RETELL_FAILOVER_WINDOW", 10),
+
 		// Webhook secrets (optional for basic auth)
-		RetellWebhookSecret: getEnv("RETELL_WEBHOOK_SECRET", ""),
-		CalWebhookSecret:    getEnv("CAL_WEBHOOK_SECRET", ""),
+		RetellWebhookSecret:      getEnv("RETELL_WEBHOOK_SECRET", ""),
+		CalWebhookSecret:         getEnv("CAL_WEBHOOK_SECRET", ""),
+		PipedriveWebhookUser:     getEnv("PIPEDRIVE_WEBHOOK_USER", ""),
+		PipedriveWebhookPassword: getEnv("PIPEDRIVE_WEBHOOK_PASSWORD", ""),
+		WebhookVerifyBypass:      getEnvAsBool("WEBHOOK_VERIFY_BYPASS", false),
+
+		PipedriveWebhookCredentials: loadPipedriveWebhookCredentials(getEnv("PIPEDRIVE_WEBHOOK_CREDENTIALS", "")),
+
+		// Custom analysis schemas (JSON, optional)
+		AnalysisSchemas: loadAnalysisSchemas(getEnv("RETELL_ANALYSIS_SCHEMAS", "")),
+
+		// WhatsApp follow-ups (optional)
+		WhatsAppProvider:        getEnv("WHATSAPP_PROVIDER", ""),
+		WhatsAppAccessToken:     getEnv("WHATSAPP_ACCESS_TOKEN", ""),
+		WhatsAppPhoneNumberID:   getEnv("WHATSAPP_PHONE_NUMBER_ID", ""),
+		WhatsAppTemplateName:    getEnv("WHATSAPP_TEMPLATE_NAME", ""),
+		WhatsAppTemplateLang:    getEnv("WHATSAPP_TEMPLATE_LANGUAGE", "en_US"),
+		TwilioAccountSID:        getEnv("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:         getEnv("TWILIO_AUTH_TOKEN", ""),
+		TwilioWhatsAppFrom:      getEnv("TWILIO_WHATSAPP_FROM", ""),
+		TwilioSMSFrom:           getEnv("TWILIO_SMS_FROM", ""),
+		SMSOptOutText:           getEnv("SMS_OPT_OUT_TEXT", "Reply STOP to opt out."),
+		WhatsAppFollowUpEnabled: getEnvAsBool("WHATSAPP_FOLLOWUP_ENABLED", false),
+		WhatsAppActivityType:    getEnv("WHATSAPP_ACTIVITY_TYPE", "task"),
+		FollowUpMeetingURL:      getEnv("FOLLOWUP_MEETING_URL", ""),
+		FollowUpTemplate:        getEnv("FOLLOWUP_MESSAGE_TEMPLATE", ""),
+
+		// Stripe payment links (optional)
+		StripeAPIKey:        getEnv("STRIPE_API_KEY", ""),
+		StripePriceID:       getEnv("STRIPE_PRICE_ID", ""),
+		StripeWebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", ""),
+		StripeIntentField:   getEnv("STRIPE_INTENT_FIELD", "purchase_intent"),
+		StripeSendLink:      getEnvAsBool("STRIPE_SEND_LINK", false),
+		StripeLinksFile:     getEnv("STRIPE_PAYMENT_LINKS_FILE", ""),
+
+		// Cal.com API (optional)
+		CalAPIKey:       getEnv("CAL_API_KEY", ""),
+		CalAPIURL:       getEnv("CAL_API_URL", "https://api.cal.com/v1"),
+		CalEventTypeID:  getEnvAsInt("CAL_EVENT_TYPE_ID", 0),
+		CalTimeZone:     getEnv("CAL_TIME_ZONE", "UTC"),
+		CalSlotDays:     getEnvAsInt("CAL_SLOT_DAYS", 3),
+		CalOfferedSlots: getEnvAsInt("CAL_OFFERED_SLOTS", 3),
+
+		// Appointment reminders (optional)
+		RemindersEnabled:    getEnvAsBool("REMINDERS_ENABLED", false),
+		ReminderChannel:     getEnv("REMINDER_CHANNEL", "sms"),
+		ReminderHoursBefore: getEnvAsInt("REMINDER_HOURS_BEFORE", 24),
+		ReminderAgentID:     getEnv("REMINDER_AGENT_ID", ""),
+		ReminderSMSTemplate: getEnv("REMINDER_SMS_TEMPLATE", ""),
+
+		// Phone lookup (optional, reuses Twilio credentials)
+		PhoneLookupProvider: getEnv("PHONE_LOOKUP_PROVIDER", ""),
+		PhoneLookupPreDial:  getEnvAsBool("PHONE_LOOKUP_PREDIAL", false),
+		PhoneLineTypeField:  getEnv("PHONE_LINE_TYPE_FIELD", ""),
+
+		// Channel selection
+		Channels: ChannelPolicy{
+			Mobile:           getEnv("CHANNEL_MOBILE", ChannelVoice),
+			Landline:         ChannelVoice,
+			VoIP:             getEnv("CHANNEL_VOIP", ChannelVoice),
+			SMSTemplate:      getEnv("CHANNEL_SMS_TEMPLATE", ""),
+			CallAfterMinutes: getEnvAsInt("CHANNEL_SMS_CALL_AFTER_MINUTES", 60),
+		},
+		ChannelField: getEnv("PERSON_CHANNEL_FIELD", ""),
+
+		// Automation toggles (all enabled by default)
+		AutoCallOnLead:        getEnvAsBool("AUTOMATION_AUTO_CALL", true),
+		ActivityOnCallStarted: getEnvAsBool("AUTOMATION_CALL_STARTED_ACTIVITY", true),
+		NoteOnAnalysis:        getEnvAsBool("AUTOMATION_ANALYSIS_NOTE", true),
+		DNCOnOptout:           getEnvAsBool("AUTOMATION_OPTOUT_DNC", true),
+		CalActivityCreation:   getEnvAsBool("AUTOMATION_CAL_ACTIVITY", true),
+		CalICSMode:            getEnv("CAL_ICS_MODE", ICSModeOff),
+		MeetingInvitesFile:    getEnv("MEETING_INVITES_FILE", ""),
+		DNCField:              getEnv("PIPEDRIVE_DNC_FIELD", "do_not_call"),
+
+		// Async webhook processing
+		AsyncWebhookProcessing: getEnvAsBool("ASYNC_WEBHOOK_PROCESSING", false),
+
+		// Transcripts
+		TranscriptNoteLimit: getEnvAsInt("TRANSCRIPT_NOTE_LIMIT", 20000),
+		TranscriptChunkSize: getEnvAsInt("TRANSCRIPT_CHUNK_SIZE", 8000),
+		PublicBaseURL:       getEnv("PUBLIC_BASE_URL", ""),
+		LLMAPIKey:           getEnv("LLM_API_KEY", ""),
+		LLMBaseURL:          getEnv("LLM_BASE_URL", "https://api.openai.com/v1"),
+		LLMModel:            getEnv("LLM_MODEL", "gpt-4o-mini"),
+
+		// Key moments
+		KeyMomentKeywords: loadKeyMomentKeywords(getEnv("KEY_MOMENT_KEYWORDS", "")),
+		KeyMomentsLLM:     getEnvAsBool("KEY_MOMENTS_LLM", false),
+
+		// Admin API access (closed when neither users nor OIDC are configured)
+		AdminUsers:    loadAdminUsers(getEnv("ADMIN_USERS", "")),
+		OIDCIssuer:    getEnv("OIDC_ISSUER", ""),
+		OIDCAudience:  getEnv("OIDC_AUDIENCE", ""),
+		OIDCRoleClaim: getEnv("OIDC_ROLE_CLAIM", "role"),
+		AdminAuthOpen: getEnvAsBool("ADMIN_AUTH_OPEN", false),
+
+		// Dynamic variable mapping (JSON: variable name -> person field key)
+		DynamicVariableMapping: loadDynamicVariableMapping(getEnv("DYNAMIC_VARIABLE_MAPPING", "")),
+		SyncDynamicVariables:   getEnvAsBool("RETELL_SYNC_DYNAMIC_VARIABLES", false),
+		LeadFieldMapping:       loadDynamicVariableMapping(getEnv("LEAD_FIELD_MAPPING", "")),
+
+		// Agent experiments (JSON: campaign -> weighted variants)
+		AgentExperiments:        loadExperiments(getEnv("AGENT_EXPERIMENTS", "")),
+		ExperimentCampaignField: getEnv("EXPERIMENT_CAMPAIGN_FIELD", ""),
+		ExperimentsFile:         getEnv("EXPERIMENTS_FILE", ""),
+
+		// From-number rules (JSON array of {source, label_ids, from_number})
+		FromNumberRules: loadFromNumberRules(getEnv("FROM_NUMBER_RULES", "")),
+
+		// Fallback owners (comma-separated Pipedrive user IDs)
+		FallbackOwnerIDs: parseIDList(getEnv("FALLBACK_OWNER_IDS", "")),
+
+		// Team routing
+		RoutingTeamID:    getEnvAsInt("ROUTING_TEAM_ID", 0),
+		RoutingStateFile: getEnv("ROUTING_STATE_FILE", ""),
+
+		// Dial rules (JSON: list of prefix/carrier rules)
+		DialRules: loadDialRules(getEnv("DIAL_RULES", "")),
+
+		// Lead scoring (JSON: list of signal rules)
+		LeadScoringRules: loadScoringRules(getEnv("LEAD_SCORING_RULES", "")),
+		LeadScoreField:   getEnv("LEAD_SCORE_FIELD", ""),
+
+		// Deal linking
+		DealLinkingEnabled: getEnvAsBool("DEAL_LINKING_ENABLED", false),
+		DealLinkPipelineID: getEnvAsInt("DEAL_LINK_PIPELINE_ID", 0),
+		DealLinkMultiple:   strings.ToLower(getEnv("DEAL_LINK_MULTIPLE", DealLinkSkip)),
+
+		// Deal creation
+		DealCreationEnabled: getEnvAsBool("DEAL_CREATION_ENABLED", false),
+		DealPipelineID:      getEnvAsInt("DEAL_PIPELINE_ID", 0),
+		DealStageID:         getEnvAsInt("DEAL_STAGE_ID", 0),
+		DealAdvanceExisting: getEnvAsBool("DEAL_ADVANCE_EXISTING", false),
+
+		// Escalation
+		EscalationEnabled:      getEnvAsBool("ESCALATION_ENABLED", false),
+		EscalationKeywords:     loadEscalationKeywords(getEnv("ESCALATION_KEYWORDS", "")),
+		EscalationWebhookURL:   getEnv("ESCALATION_WEBHOOK_URL", ""),
+		EscalationWebhookURLs:  loadDynamicVariableMapping(getEnv("ESCALATION_TENANT_WEBHOOK_URLS", "")),
+		EscalationManagerID:    getEnvAsInt("ESCALATION_MANAGER_ID", 0),
+		EscalationActivityType: getEnv("ESCALATION_ACTIVITY_TYPE", "task"),
+		EscalationPriority:     getEnvAsInt("ESCALATION_PRIORITY", 0),
+		EscalationContextTurns: getEnvAsInt("ESCALATION_CONTEXT_TURNS", 1),
+
+		// Voicemail drop
+		VoicemailDropMessage:  getEnv("VOICEMAIL_DROP_MESSAGE", ""),
+		VoicemailDropAudioURL: getEnv("VOICEMAIL_DROP_AUDIO_URL", ""),
+		VoicemailActivityType: getEnv("VOICEMAIL_ACTIVITY_TYPE", ""),
+
+		// Call direction
+		InboundActivityType:  getEnv("INBOUND_CALL_ACTIVITY_TYPE", "call"),
+		OutboundActivityType: getEnv("OUTBOUND_CALL_ACTIVITY_TYPE", "call"),
+		InboundNoteTemplate:  getEnv("INBOUND_CALL_NOTE_TEMPLATE", ""),
+		OutboundNoteTemplate: getEnv("OUTBOUND_CALL_NOTE_TEMPLATE", ""),
+
+		// Distributed locks
+		RedisURL:     getEnv("REDIS_URL", ""),
+		LockTTL:      getEnvAsInt("LOCK_TTL_SECONDS", 600),
+		LockPrefix:   getEnv("LOCK_PREFIX", "pipcal:lock:"),
+		LockFailOpen: getEnvAsBool("LOCK_FAIL_OPEN", false),
+
+		// Call mapping store
+		CallMappingStore:    getEnv("CALL_MAPPING_STORE", CallMappingStoreMemory),
+		CallMappingRedisKey: getEnv("CALL_MAPPING_REDIS_KEY", "pipcal:call-mappings"),
+		CallMappingTTLDays:  getEnvAsInt("CALL_MAPPING_TTL_DAYS", 30),
+		DatabaseURL:         getEnv("DATABASE_URL", ""),
+
+		// Call events
+		CallEventsFile: getEnv("CALL_EVENTS_FILE", ""),
+
+		// Audit log
+		AuditLogFile:    getEnv("AUDIT_LOG_FILE", ""),
+		AuditMaxEntries: getEnvAsInt("AUDIT_MAX_ENTRIES", 100000),
+
+		// Webhook archive
+		WebhookArchiveFile: getEnv("WEBHOOK_ARCHIVE_FILE", ""),
+		WebhookArchiveSize: getEnvAsInt("WEBHOOK_ARCHIVE_SIZE", 500),
+
+		// KPI write-back
+		KPIWriteBackEnabled: getEnvAsBool("KPI_WRITEBACK_ENABLED", false),
+		KPIWriteBackHour:    getEnvAsInt("KPI_WRITEBACK_HOUR", 1),
+		KPITimeZone:         getEnv("KPI_TIME_ZONE", "UTC"),
+		KPIUserID:           getEnvAsInt("KPI_USER_ID", 0),
+		KPIActivityType:     getEnv("KPI_ACTIVITY_TYPE", "task"),
+
+		// Reconciliation
+		ReconcileEnabled: getEnvAsBool("RECONCILE_ENABLED", false),
+		ReconcileHour:    getEnvAsInt("RECONCILE_HOUR", 2),
+
+		// Call queue
+		QueueETAActivity: getEnvAsBool("QUEUE_ETA_ACTIVITY", false),
+		ScheduleFile:     getEnv("SCHEDULE_FILE", ""),
+
+		// Stale activity cleanup
+		StaleActivityHours: getEnvAsInt("STALE_ACTIVITY_HOURS", 0),
+
+		// Webhook gap detection
+		WebhookGapEnabled:         getEnvAsBool("WEBHOOK_GAP_DETECTION", false),
+		WebhookGapStartedMinutes:  getEnvAsInt("WEBHOOK_GAP_STARTED_MINUTES", 5),
+		WebhookGapEndedMinutes:    getEnvAsInt("WEBHOOK_GAP_ENDED_MINUTES", 30),
+		WebhookGapAnalyzedMinutes: getEnvAsInt("WEBHOOK_GAP_ANALYZED_MINUTES", 15),
+		WebhookGapMaxChecks:       getEnvAsInt("WEBHOOK_GAP_MAX_CHECKS", 5),
+
+		// Pipedrive write retries
+		PipedriveRetryMaxAttempts:     getEnvAsInt("PIPEDRIVE_RETRY_MAX_ATTEMPTS", 6),
+		PipedriveRetryBaseSeconds:     getEnvAsInt("PIPEDRIVE_RETRY_BASE_SECONDS", 30),
+		PipedriveRetryMaxDelayMinutes: getEnvAsInt("PIPEDRIVE_RETRY_MAX_DELAY_MINUTES", 60),
+		PipedriveRetryFile:            getEnv("PIPEDRIVE_RETRY_FILE", ""),
+
+		// Deal-stage nurture (JSON: list of stage/days/agent rules)
+		NurtureRules: loadNurtureRules(getEnv("NURTURE_RULES", "")),
+
+		// Inbound email leads
+		EmailWebhookToken:      getEnv("EMAIL_WEBHOOK_TOKEN", ""),
+		EmailWebhookSigningKey: getEnv("EMAIL_WEBHOOK_SIGNING_KEY", ""),
+		EmailDedupFile:         getEnv("EMAIL_DEDUP_FILE", ""),
+
+		// Facebook Lead Ads (JSON: form field -> Pipedrive target)
+		FacebookAppSecret:       getEnv("FACEBOOK_APP_SECRET", ""),
+		FacebookVerifyToken:     getEnv("FACEBOOK_VERIFY_TOKEN", ""),
+		FacebookPageAccessToken: getEnv("FACEBOOK_PAGE_ACCESS_TOKEN", ""),
+		FacebookGraphURL:        getEnv("FACEBOOK_GRAPH_URL", "https://graph.facebook.com/v19.0"),
+		FacebookFieldMapping:    loadDynamicVariableMapping(getEnv("FACEBOOK_FIELD_MAPPING", "")),
+		FacebookLeadAutoCall:    getEnvAsBool("FACEBOOK_LEAD_AUTO_CALL", true),
+
+		// Lead capture
+		CaptureRateLimit:       getEnvAsInt("CAPTURE_RATE_LIMIT", 5),
+		CaptureCaptchaProvider: getEnv("CAPTURE_CAPTCHA_PROVIDER", ""),
+		CaptureCaptchaSecret:   getEnv("CAPTURE_CAPTCHA_SECRET", ""),
+		CaptureMinCaptchaScore: getEnvAsFloat("CAPTURE_MIN_CAPTCHA_SCORE", 0.5),
+		CaptureLeadAutoCall:    getEnvAsBool("CAPTURE_LEAD_AUTO_CALL", false),
+		TrustedProxies:         parseList(getEnv("TRUSTED_PROXIES", "")),
+		TrustedClientIPHeader:  getEnv("TRUSTED_CLIENT_IP_HEADER", ""),
+
+		// Attribution (JSON: utm_source etc. -> Pipedrive field)
+		AttributionFieldMapping: loadDynamicVariableMapping(getEnv("ATTRIBUTION_FIELD_MAPPING", "")),
+
+		// SLOs (SLO_TARGETS JSON: route -> {"latency_ms", "target"})
+		SLOEndpoints:       parseList(getEnv("SLO_ENDPOINTS", "/webhook/,/functions/,/api/leads/capture,job:")),
+		SLOTarget:          getEnvAsFloat("SLO_TARGET", 0.995),
+		SLOLatencyMs:       getEnvAsInt("SLO_LATENCY_MS", 2000),
+		SLOJobLatencyMs:    getEnvAsInt("SLO_JOB_LATENCY_MS", 60000),
+		SLOTargets:         loadSLOTargets(getEnv("SLO_TARGETS", "")),
+		SLOWindowDays:      getEnvAsInt("SLO_WINDOW_DAYS", 30),
+		SLOFile:            getEnv("SLO_FILE", ""),
+		SLOAlertWebhookURL: getEnv("SLO_ALERT_WEBHOOK_URL", ""),
+		SLOFastBurnRate:    getEnvAsFloat("SLO_FAST_BURN_RATE", 14.4),
+		SLOSlowBurnRate:    getEnvAsFloat("SLO_SLOW_BURN_RATE", 6),
+
+		// Email validation
+		EmailMXCheck:        getEnvAsBool("EMAIL_MX_CHECK", false),
+		EmailBounceListFile: getEnv("EMAIL_BOUNCE_LIST_FILE", ""),
+
+		// Lost reasons (LOST_REASON_MAPPING JSON: analysis value -> lost reason)
+		LostReasonEnabled: getEnvAsBool("LOST_REASON_ENABLED", false),
+		LostReasonField:   getEnv("LOST_REASON_FIELD", "rejection_reason"),
+		LostReasonMapping: loadLostReasonMapping(getEnv("LOST_REASON_MAPPING", "")),
+		LostReasonTargets: parseList(getEnv("LOST_REASON_TARGETS", "lead,deal")),
+		LostMarksFile:     getEnv("LOST_MARKS_FILE", ""),
+
+		// Conversation memory
+		MemoryEnabled:          getEnvAsBool("MEMORY_ENABLED", false),
+		MemoryDisabledTenants:  parseList(getEnv("MEMORY_DISABLED_TENANTS", "")),
+		MemoryMaxCalls:         getEnvAsInt("MEMORY_MAX_CALLS", 5),
+		MemoryMaxChars:         getEnvAsInt("MEMORY_MAX_CHARS", 1000),
+		MemoryCommitmentsField: getEnv("MEMORY_COMMITMENTS_FIELD", "commitments"),
+		MemoryFile:             getEnv("MEMORY_FILE", ""),
+
+		// Recording and transcript retention
+		RetentionRecordingDays:        getEnvAsInt("RETENTION_RECORDING_DAYS", 0),
+		RetentionTranscriptDays:       getEnvAsInt("RETENTION_TRANSCRIPT_DAYS", 0),
+		RetentionPurgeHour:            getEnvAsInt("RETENTION_PURGE_HOUR", 3),
+		RetentionDeleteRetellCalls:    getEnvAsBool("RETENTION_DELETE_RETELL_CALLS", false),
+		RetentionRedactPipedriveNotes: getEnvAsBool("RETENTION_REDACT_PIPEDRIVE_NOTES", false),
+		RetentionLogFile:              getEnv("RETENTION_LOG_FILE", ""),
+
+		// Person update webhooks
+		PersonMonitoredFields: parseList(getEnv("PERSON_MONITORED_FIELDS", "")),
+		PersonNormalizePhones: getEnvAsBool("PERSON_NORMALIZE_PHONES", false),
+		PersonHoldsFile:       getEnv("PERSON_HOLDS_FILE", ""),
+
+		// Phone backfill
+		PhoneCountryCode:        strings.TrimPrefix(getEnv("PHONE_COUNTRY_CODE", "1"), "+"),
+		TenantPhoneCountryCodes: loadTenantPhoneCountryCodes(getEnv("TENANT_PHONE_COUNTRY_CODES", "")),
+		PhoneBackfillField:      getEnv("PHONE_BACKFILL_FIELD", ""),
+		PhoneBackfillFixPrimary: getEnvAsBool("PHONE_BACKFILL_FIX_PRIMARY", false),
+
+		// Rep activity check before dialing
+		HumanActivity: HumanActivityPolicy{
+			Hours:      getEnvAsInt("HUMAN_ACTIVITY_HOURS", 0),
+			Action:     getEnv("HUMAN_ACTIVITY_ACTION", HumanActivitySkip),
+			DeferHours: getEnvAsInt("HUMAN_ACTIVITY_DEFER_HOURS", 4),
+			Types:      parseList(getEnv("HUMAN_ACTIVITY_TYPES", "call,email")),
+		},
+
+		// Recent contact suppression
+		RecentContactDays:         getEnvAsInt("RECENT_CONTACT_DAYS", 0),
+		CampaignRecentContactDays: loadCampaignRecentContactDays(getEnv("CAMPAIGN_RECENT_CONTACT_DAYS", "")),
+		RecentContactTypes:        parseList(getEnv("RECENT_CONTACT_TYPES", "call,meeting")),
+
+		// Short links
+		ShortLinksEnabled: getEnvAsBool("SHORT_LINKS_ENABLED", false),
+		ShortLinkBaseURL:  getEnv("SHORT_LINK_BASE_URL", ""),
+		ShortLinksFile:    getEnv("SHORT_LINKS_FILE", ""),
+		ShortLinkTTLDays:  getEnvAsInt("SHORT_LINK_TTL_DAYS", 90),
+
+		// Campaigns
+		CampaignsFile: getEnv("CAMPAIGNS_FILE", ""),
+
+		// Outbound email
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		// Meeting confirmation emails
+		MeetingConfirmationEmail: getEnvAsBool("MEETING_CONFIRMATION_EMAIL", false),
+		MeetingConfirmationDefault: MeetingConfirmationSender{
+			From:     getEnv("MEETING_CONFIRMATION_FROM", ""),
+			ReplyTo:  getEnv("MEETING_CONFIRMATION_REPLY_TO", ""),
+			Brand:    getEnv("MEETING_CONFIRMATION_BRAND", ""),
+			Subject:  getEnv("MEETING_CONFIRMATION_SUBJECT", ""),
+			Template: getEnv("MEETING_CONFIRMATION_TEMPLATE", ""),
+		},
+
+		// Call summary emails
+		CallSummaryEmail:        getEnvAsBool("CALL_SUMMARY_EMAIL", false),
+		CallSummaryEmailTenants: parseList(getEnv("CALL_SUMMARY_EMAIL_TENANTS", "")),
+		CallSummaryDefault: CallSummarySender{
+			From:     getEnv("CALL_SUMMARY_EMAIL_FROM", ""),
+			ReplyTo:  getEnv("CALL_SUMMARY_EMAIL_REPLY_TO", ""),
+			Brand:    getEnv("CALL_SUMMARY_EMAIL_BRAND", ""),
+			Subject:  getEnv("CALL_SUMMARY_EMAIL_SUBJECT", ""),
+			Template: getEnv("CALL_SUMMARY_EMAIL_TEMPLATE", ""),
+		},
+		CallSummaryEmailField:        getEnv("CALL_SUMMARY_EMAIL_FIELD", "email_summary"),
+		CallSummaryNextStepsField:    getEnv("CALL_SUMMARY_NEXT_STEPS_FIELD", "next_steps"),
+		CallSummaryUnsubscribeSecret: getEnv("CALL_SUMMARY_UNSUBSCRIBE_SECRET", ""),
+		CallSummaryUnsubscribeFile:   getEnv("CALL_SUMMARY_UNSUBSCRIBE_FILE", ""),
+
+		// Weekly digest
+		DigestOwners:  loadDigestOwners(getEnv("DIGEST_OWNERS", "")),
+		DigestWeekday: getEnv("DIGEST_WEEKDAY", "monday"),
+		DigestHour:    getEnvAsInt("DIGEST_HOUR", 8),
+
+		// Lead webhook coalescing
+		LeadCoalesceSeconds: getEnvAsInt("LEAD_COALESCE_SECONDS", 0),
+
+		// Call windows
+		CallWindowTimeZone: getEnv("CALL_WINDOW_TIME_ZONE", "UTC"),
+		CallWindowBias:     getEnvAsBool("CALL_WINDOW_BIAS", false),
+		CallWindowMaxDelay: getEnvAsInt("CALL_WINDOW_MAX_DELAY_HOURS", 24),
+		ContactHours:       parseContactHours(getEnv("CONTACT_HOURS", "")),
+
+		// Call library export
+		NotionAPIKey:          getEnv("NOTION_API_KEY", ""),
+		NotionDatabaseID:      getEnv("NOTION_DATABASE_ID", ""),
+		NotionPropertyMapping: loadDynamicVariableMapping(getEnv("NOTION_PROPERTY_MAPPING", "")),
+		GoogleServiceAccount:  getEnv("GOOGLE_SERVICE_ACCOUNT", ""),
+		GoogleDriveFolderID:   getEnv("GOOGLE_DRIVE_FOLDER_ID", ""),
+		ExportMaxAttempts:     getEnvAsInt("EXPORT_MAX_ATTEMPTS", 3),
+
+		// Google Calendar (JSON: Pipedrive user ID -> calendar ID)
+		GoogleCalendarEnabled:        getEnvAsBool("GOOGLE_CALENDAR_ENABLED", false),
+		GoogleCalendarIDs:            loadDynamicVariableMapping(getEnv("GOOGLE_CALENDAR_IDS", "")),
+		GoogleCalendarMeetingMinutes: getEnvAsInt("GOOGLE_CALENDAR_MEETING_MINUTES", 30),
+
+		// Lead write-back
+		LeadWriteBack:          getEnvAsBool("LEAD_WRITE_BACK", false),
+		LeadContactedLabelID:   getEnv("LEAD_CONTACTED_LABEL_ID", ""),
+		LeadContactedLabel:     getEnv("LEAD_CONTACTED_LABEL", ""),
+		LeadLastContactedField: getEnv("LEAD_LAST_CONTACTED_FIELD", ""),
+
+		// Lead labels
+		LeadLabelsAutoCreate: getEnvAsBool("LEAD_LABELS_AUTO_CREATE", false),
+
+		// Timestamps
+		NaiveTimestampZone: getEnv("NAIVE_TIMESTAMP_ZONE", "UTC"),
+
+		// Recording consent
+		RecordingConsentEnabled: getEnvAsBool("RECORDING_CONSENT_ENABLED", false),
+		ConsentAgentID:          getEnv("CONSENT_AGENT_ID", ""),
+		ConsentRequiredPrefixes: parseList(getEnv("CONSENT_REQUIRED_PREFIXES", "")),
+		ConsentField:            getEnv("PIPEDRIVE_CONSENT_FIELD", ""),
+
+		// Tenants (JSON: agent ID -> tenant; tenant -> base64 AES-256 key)
+		AgentTenants:         loadDynamicVariableMapping(getEnv("AGENT_TENANTS", "")),
+		Region:               strings.ToLower(getEnv("DEPLOYMENT_REGION", "")),
+		TenantRegions:        loadDynamicVariableMapping(getEnv("TENANT_REGIONS", "")),
+		TenantEncryptionKeys: loadTenantKeys(getEnv("TENANT_ENCRYPTION_KEYS", "")),
+		TranscriptEncryption: getEnvAsBool("TRANSCRIPT_ENCRYPTION", false),
+
+		// Locale (Go date layout, 12h/24h clock, language of day and month names)
+		DefaultLocale: Locale{
+			DateFormat: getEnv("LOCALE_DATE_FORMAT", "Monday, January 2"),
+			Clock:      getEnv("LOCALE_CLOCK", Clock12h),
+			Language:   getEnv("LOCALE_LANGUAGE", "en"),
+		},
+
+		// Worker pool
+		WorkerPoolSize:        getEnvAsInt("WORKER_POOL_SIZE", 8),
+		WorkerQueueDepth:      getEnvAsInt("WORKER_QUEUE_DEPTH", 1000),
+		WorkerBackpressure:    getEnv("WORKER_BACKPRESSURE", BackpressureBuffer),
+		WorkerEnqueueTimeout:  getEnvAsInt("WORKER_ENQUEUE_TIMEOUT", 5),
+		WorkerPriorityWorkers: getEnvAsInt("WORKER_PRIORITY_WORKERS", 1),
+
+		ScalingVendorThrottles: getEnvAsInt("SCALING_VENDOR_THROTTLES", 5),
+
+		// Webhook backpressure
+		WebhookShedPaths:        parseList(getEnv("WEBHOOK_SHED_PATHS", "/webhook/pipedrive/,/webhook/cal")),
+		WebhookShedQueuePercent: getEnvAsInt("WEBHOOK_SHED_QUEUE_PERCENT", 90),
+		WebhookShedMaxWait:      getEnvAsInt("WEBHOOK_SHED_MAX_WAIT", 0),
+		WebhookRetryAfter:       getEnvAsInt("WEBHOOK_RETRY_AFTER", 30),
 
 		// Logging
-		LogLevel: getEnv("LOG_LEVEL", "info"),
+		LogLevel:        getEnv("LOG_LEVEL", "info"),
+		LogFormat:       getEnv("LOG_FORMAT", "text"),
+		DebugSubsystems: parseList(getEnv("DEBUG_SUBSYSTEMS", "")),
+	}
+	config.TenantLocales = loadTenantLocales(getEnv("TENANT_LOCALES", ""), config.DefaultLocale)
+	config.CallSummarySenders = loadCallSummarySenders(getEnv("CALL_SUMMARY_EMAIL_SENDERS", ""), config.CallSummaryDefault)
+	config.MeetingConfirmationSenders = loadMeetingConfirmationSenders(getEnv("MEETING_CONFIRMATION_SENDERS", ""), config.MeetingConfirmationDefault)
+	config.PostMeetingSequences = loadPostMeetingSequences(getEnv("POST_MEETING_SEQUENCES", ""))
+	config.TenantHumanActivity = loadTenantHumanActivity(getEnv("TENANT_HUMAN_ACTIVITY", ""), config.HumanActivity)
+	config.Channels = normalizeChannelPolicy(config.Channels, config.Channels, "CHANNEL_*")
+	config.CampaignChannels = loadCampaignChannels(getEnv("CAMPAIGN_CHANNELS", ""), config.Channels)
+	if config.OIDCIssuer != "" && config.OIDCAudience == "" {
+		log.Printf("❌ OIDC_ISSUER requires OIDC_AUDIENCE, ignoring OIDC tokens")
+		config.OIDCIssuer = ""
 	}
 
 	return config
@@ -480,12 +1811,62 @@ func LoadConfig() *Config {
 
 // getEnv gets an environment variable with a fallback default value
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := lookupEnv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
+// getEnvAsInt gets an environment variable as integer with a fallback default value
+func getEnvAsInt(key string, defaultValue int) int {
+	if value := lookupEnv(key); value != "" {
+		if intValue, err := strconv.Atoi(value); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsFloat gets an environment variable as float with a fallback default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := lookupEnv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsBool gets an environment variable as boolean with a fallback default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := lookupEnv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// parseList splits a comma-separated value, dropping empty entries
+func parseList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// IsProduction returns true unless APP_ENV names a non-production environment
+func (c *Config) IsProduction() bool {
+	switch c.AppEnv {
+	case "development", "dev", "local", "test", "staging":
+		return false
+	}
+	return true
+}
+
 // HasPipedriveConfig returns true if Pipedrive API key is configured
 func (c *Config) HasPipedriveConfig() bool {
 	return c.PipedriveAPIKey != ""
@@ -510,10 +1891,52 @@ type RetellWebhookPayload struct {
 	CallID        string `json:"call_id"`
 	ContactPhone  string `json:"contact_phone"`
 	Transcript    string `json:"transcript"`
-	Duration      string `json:"duration"` // Format: "00:02:15"
+	Duration      Duration `json:"duration"` // "00:02:15", "02:15" or seconds
 	Status        string `json:"status"`   // "completed", "hangup", "optout"
 	Timestamp     string `json:"timestamp"` // ISO8601 format
-	Event         string `json:"event"`     // "call.completed", "call.hangup", "call.optout"
+	Event         string `json:"event"`     // "call.completed", "call.hangup", "call.optout", "call_started", "call_ended"
+	Metadata      *CallMetadata `json:"metadata"`
+
+	// Native Retell events carry these in call; normalize copies them to the flat fields
+	AgentID    string           `json:"agent_id"`
+	Direction  string           `json:"direction"` // "inbound" or "outbound" (default)
+	FromNumber string           `json:"from_number"`
+	ToNumber   string           `json:"to_number"`
+	Call       *RetellEventCall `json:"call"`
+}
+
+// RetellCallAnalyzedPayload represents the call_analyzed webhook payload
+type RetellCallAnalyzedPayload struct {
+	Event string `json:"event"`
+	Call  struct {
+		CallID              string                `json:"call_id"`
+		CallType            string                `json:"call_type"`
+		Direction           string                `json:"direction"`
+		FromNumber          string                `json:"from_number"`
+		AgentID             string                `json:"agent_id"`
+		AgentVersion        int                   `json:"agent_version"`
+		AgentName           string                `json:"agent_name"`
+		CallStatus          string                `json:"call_status"`
+		StartTimestamp      int64                 `json:"start_timestamp"`
+		EndTimestamp        int64                 `json:"end_timestamp"`
+		DurationMs          int                   `json:"duration_ms"`
+		Transcript          string                `json:"transcript"`
+		TranscriptObject    []TranscriptUtterance `json:"transcript_object"`
+		DisconnectionReason string                `json:"disconnection_reason"`
+		CallAnalysis        struct {
+			CallSummary        string                 `json:"call_summary"`
+			InVoicemail        bool                   `json:"in_voicemail"`
+			UserSentiment      string                 `json:"user_sentiment"`
+			CallSuccessful     bool                   `json:"call_successful"`
+			CustomAnalysisData map[string]interface{} `json:"custom_analysis_data"`
+		} `json:"call_analysis"`
+		RecordingURL string        `json:"recording_url"`
+		PublicLogURL string        `json:"public_log_url"`
+		ToNumber     string        `json:"to_number"`
+		Metadata     *CallMetadata `json:"metadata"` // Our context for the call, as sent on creation
+	} `json:"call"`
+
+	Replay bool `json:"-"` // Re-run on admin request (replay or refresh); skips the dispatch lock
 }
 
 // PipedriveLeadWebhookPayload represents the incoming Pipedrive lead webhook data
@@ -560,6 +1983,20 @@ type PipedriveLeadWebhookPayload struct {
 		Attempt            int      `json:"attempt"`
 		Host               string   `json:"host"`
 	} `json:"meta"`
+
+	Coalesced []CoalescedLead `json:"-"` // Leads for the same person merged into this one
+	Intake    string          `json:"-"` // Source of a lead created by intake (e.g. "email"); empty for Pipedrive webhooks
+
+	HumanDeferred    bool   `json:"-"` // Already deferred once because a rep was working the lead
+	ChannelSequenced bool   `json:"-"` // Follow-up call of an SMS-first sequence; the channel is already chosen
+	Campaign         string `json:"-"` // Launched campaign the lead belongs to
+	Attempt          int    `json:"-"` // Step of the campaign's retry ladder; 0 for the first call
+	CampaignDelayed  bool   `json:"-"` // Already held for the campaign's trigger delay
+	WindowDelayed    bool   `json:"-"` // Already held for a window with a better answer rate
+	Deprioritized    bool   `json:"-"` // Already deferred by a deprioritizing dial rule
+	QueueID          string `json:"-"` // Call queue entry while the call is held by a timer
+	QueuedActivityID int    `json:"-"` // Initiation activity created with the ETA (QUEUE_ETA_ACTIVITY)
+	Tenant           string `json:"-"` // Tenant whose credentials verified the webhook; only its agents call the lead
 }
 
 // RetellCallRequest represents the request to create a call via Retell AI
@@ -569,6 +2006,7 @@ type RetellCallRequest struct {
 	AssistantID         string                 `json:"assistant_id"`
 	MaxDurationSeconds  int                    `json:"max_duration_seconds,omitempty"`
 	DynamicVariables    map[string]interface{} `json:"dynamic_variables,omitempty"`
+	Metadata            *CallMetadata          `json:"metadata,omitempty"` // Returned on the call's webhooks
 }
 
 // RetellCallResponse represents the response from Retell AI call creation
@@ -588,33 +2026,126 @@ type WebhookResponse struct {
 type CalWebhookPayload struct {
 	TriggerEvent string `json:"triggerEvent"`
 	CreatedAt    string `json:"createdAt"`
+	Version      string `json:"-"` // CalWebhookV1 or CalWebhookV2, set by normalize
 	Payload      struct {
-		ID        int    `json:"id"`
-		Title     string `json:"title"`
-		StartTime string `json:"startTime"`
-		EndTime   string `json:"endTime"`
-		Attendees []struct {
-			Email string `json:"email"`
-			Name  string `json:"name"`
+		ID         int    `json:"id"`
+		BookingID  int    `json:"bookingId"`  // v2 booking ID
+		UID        string `json:"uid"`        // v2 booking UID
+		BookingUID string `json:"bookingUid"` // Booking UID of no-show updates
+		Title      string `json:"title"`
+		StartTime  string `json:"startTime"`
+		EndTime    string `json:"endTime"`
+		Start      string `json:"start"` // v2 start time
+		End        string `json:"end"`   // v2 end time
+		Attendees  []struct {
+			Email  string `json:"email"`
+			Name   string `json:"name"`
+			NoShow bool   `json:"noShow"` // Set on BOOKING_NO_SHOW_UPDATED
 		} `json:"attendees"`
-		Location string `json:"location"`
+		Location   string `json:"location"`
+		MeetingURL string `json:"meetingUrl"` // v2 meeting link
+		Metadata   struct {
+			VideoCallURL string `json:"videoCallUrl"`
+		} `json:"metadata"`
 	} `json:"payload"`
 }
 
 // PipedriveService handles real Pipedrive API interactions
 type PipedriveService struct {
-	config       *Config
-	httpClient   *http.Client
-	callMappings map[string]CallMapping // Maps callID to call info
+	config          *Config
+	httpClient      *http.Client
+	callMappings    CallMappingStore      // Maps callID to call info
+	whatsApp        MessagingProvider     // nil when WhatsApp is not configured
+	stripe          *StripeClient         // nil when Stripe is not configured
+	deals           *DealService          // nil when DEAL_CREATION_ENABLED is off
+	facebook        *FacebookLeadsClient  // nil when Facebook Lead Ads is not configured
+	cal             *CalClient            // nil when the Cal.com API is not configured
+	calendar        *GoogleCalendarClient // nil when Google Calendar is not configured
+	sms             MessagingProvider     // nil when SMS is not configured
+	reminders       *ReminderScheduler    // Pending appointment reminders
+	nurtures        *NurtureScheduler     // Pending deal-stage nurture calls
+	intake          *IntakeTracker        // Leads recently created from email and other inbound sources
+	emailDedup      *EmailDedupStore      // Inbound emails already turned into leads
+	captureLimiter  *CaptureRateLimiter   // Landing page submissions per client IP
+	attributions    *AttributionStore     // UTM/referrer attribution by lead ID
+	auditLog        *AuditLog             // Append-only log of automated actions
+	callWindows     *CallWindowTracker    // Answer rates by weekday/hour per area code
+	coalescer       *LeadCoalescer        // nil when lead webhook coalescing is disabled
+	locker          *RedisLocker          // nil when distributed locking is disabled
+	leases          *localLeases          // Leases taken instead when it is; nil claims nothing
+	callEvents      *CallEventStore       // Call lifecycle events
+	kpis            *KPITracker           // Daily AI activity totals
+	reconciliations *ReconciliationLog    // Recent Retell/Pipedrive reconciliation reports
+	phoneValidator  *PhoneValidator       // nil when phone lookup is not configured
+	processing      *ProcessingTracker    // Background webhook processing jobs
+	transcripts     *TranscriptStore      // Full transcripts too long for Pipedrive
+	llm             *LLMClient            // nil when no LLM is configured
+	adminAuth       *AdminAuthenticator   // Resolves admin API users
+	experiments     *ExperimentTracker    // A/B conversion metrics per variant
+	snoozes         *SnoozeStore          // Persons with outreach temporarily suppressed
+	owners          *OwnerResolver        // Lead owner validation and fallbacks
+	exporters       []TranscriptExporter  // External call libraries analyzed calls are pushed to
+	workers         *WorkerPool           // Runs background webhook processing
+	conditional     *conditionalCache     // ETag/Last-Modified validators for Pipedrive GETs; nil when disabled
+	dialRules       *DialRuleTracker      // Calls skipped or deferred by dial rules per campaign
+	slo             *SLOTracker           // Webhook latency and success against objectives
+	emailValidator  *EmailValidator       // Syntax, MX and bounce checks before creating contacts
+	lostMarks       *LostMarkStore        // Leads and deals closed as lost after calls
+	memory          *MemoryStore          // Recent call summaries per person for repeat calls
+	teamRouter      *TeamRouter           // Round-robin position within ROUTING_TEAM_ID
+	retention       *RetentionManager     // Recording and transcript purge history
+	personChanges   *PersonChangeTracker  // Person snapshots, monitored field diffs and call holds
+	invites         *MeetingInviteStore   // Meeting .ics files linked from activity notes
+	meetings        *MeetingTracker       // Booked meetings awaiting or with an outcome
+	shortLinks      *ShortLinkStore       // Short codes for links in outgoing messages
+	campaigns       *CampaignStore        // Campaign templates and launched campaigns
+	retries         *PipedriveRetryQueue  // Failed Pipedrive writes awaiting another attempt; nil when disabled
+	callQueue       *CallQueue            // Lead calls held by a timer until they are placed
+	rateLimiter     *PipedriveRateLimiter // Paces Pipedrive requests; nil when PIPEDRIVE_RATE_LIMIT=0
+	phoneBackfills  *PhoneBackfiller      // Phone normalization jobs and their reports
+	mailer          *Mailer               // nil when SMTP is not configured
+	phoneNumbers    *RetellNumberCache    // Retell numbers used to validate from-number rules
+	features        *PipedriveFeatureGate // Pipedrive features refused by the plan or token scopes
+	webhooks        *WebhookArchive       // Inbound webhooks kept for reprocessing
+	leadLabels      *LeadLabelCache       // Lead labels used to resolve label names in the config
+	personProxy     *PersonProxyCache     // Person views served to the frontend
+	retellFailover  *RetellFailover       // Which Retell account new calls are placed on
+	unsubscribes    *UnsubscribeList      // Addresses that opted out of call summary emails
+	requestLog      *slog.Logger          // Carries the request ID on per-request copies; nil otherwise
+	webhookGaps     *WebhookGapTracker    // Overdue Retell webhooks and how they were healed
+	schedules       *JobScheduler         // Held calls and follow-ups, kept across restarts
+	tenant          string                // Tenant whose webhook a per-request copy handles; empty otherwise
+	apiUser         *APIUserCache         // The Pipedrive user the API token belongs to
 }
 
 // CallMapping stores call information for later use
 type CallMapping struct {
-	PersonName string
-	PhoneNumber string
-	LeadTitle  string
-	PersonID   int
-	Timestamp  time.Time
+	PersonName  string    `json:"person_name"`
+	PhoneNumber string    `json:"phone_number"`
+	LeadTitle   string    `json:"lead_title"`
+	PersonID    int       `json:"person_id"`
+	Timestamp   time.Time `json:"timestamp"`
+
+	Experiment *ExperimentAssignment `json:"experiment,omitempty"`  // A/B variant the call was placed with
+	OwnerID    int                   `json:"owner_id,omitempty"`    // User activities for the call are assigned to
+	Tenant     string                `json:"tenant,omitempty"`      // Tenant owning the call's data
+	Region     string                `json:"region,omitempty"`      // Region the call's data is stored in
+	Consent    *ConsentRecord        `json:"consent,omitempty"`     // Recording consent outcome
+	Score      *LeadScore            `json:"score,omitempty"`       // Qualification score from the analyzed call
+	LeadID     string                `json:"lead_id,omitempty"`     // Lead that triggered the call
+	FromNumber string                `json:"from_number,omitempty"` // Number the call was placed from, when not RETELL_FROM_NUMBER
+	Campaign   string                `json:"campaign,omitempty"`    // Campaign of the lead that triggered the call
+	Attempt    int                   `json:"attempt,omitempty"`     // Campaign retry the call was placed as
+	DealID     int                   `json:"deal_id,omitempty"`     // Deal created or updated after the call
+	ActivityID int                   `json:"activity_id,omitempty"` // Analyzed-call activity written to Pipedrive
+	Escalated  bool                  `json:"escalated,omitempty"`   // Escalated to a manager after the call
+	Answered   bool                  `json:"answered,omitempty"`    // The analyzed call reached a person
+	Outcome    string                `json:"outcome,omitempty"`     // CallOutcome* once the call is analyzed
+	Summary    string                `json:"summary,omitempty"`     // Start of the call summary, quoted in owner digests
+	OptedOut   bool                  `json:"opted_out,omitempty"`   // The person opted out during the call
+
+	SummaryEmailed  bool       `json:"summary_emailed,omitempty"`   // Summary email sent to the contact after the call
+	MeetingBookedAt *time.Time `json:"meeting_booked_at,omitempty"` // The person booked a Cal.com meeting after the call
 }
 
 // PipedrivePhone represents a phone number from Pipedrive API
@@ -626,10 +2157,11 @@ type PipedrivePhone struct {
 
 // PipedrivePerson represents a person from Pipedrive API
 type PipedrivePerson struct {
-	ID    int             `json:"id"`
-	Name  string          `json:"name"`
-	Email []PipedrivePhone `json:"email"`
-	Phone []PipedrivePhone `json:"phone"`
+	ID     int                    `json:"id"`
+	Name   string                 `json:"name"`
+	Email  []PipedrivePhone       `json:"email"`
+	Phone  []PipedrivePhone       `json:"phone"`
+	Fields map[string]interface{} `json:"-"` // All person fields, including custom field keys
 }
 
 // PipedrivePersonResponse represents the response from Pipedrive persons API
@@ -647,13 +2179,20 @@ type PipedrivePersonSearchResponse struct {
 
 // PipedriveActivity represents an activity in Pipedrive
 type PipedriveActivity struct {
-	ID       int    `json:"id"`
-	Subject  string `json:"subject"`
-	Type     string `json:"type"`
-	DueDate  string `json:"due_date"`
-	PersonID int    `json:"person_id"`
-	Note     string `json:"note"`
-	Duration string `json:"duration"`
+	ID               int    `json:"id"`
+	Subject          string `json:"subject"`
+	Type             string `json:"type"`
+	Done             bool   `json:"done"`
+	DueDate          string `json:"due_date"`
+	DueTime          string `json:"due_time"`
+	PersonID         int    `json:"person_id"`
+	DealID           int    `json:"deal_id"`
+	UserID           int    `json:"user_id"`
+	CreatorUserID    int    `json:"created_by_user_id"`
+	AddTime          string `json:"add_time"`
+	MarkedAsDoneTime string `json:"marked_as_done_time"`
+	Note             string `json:"note"`
+	Duration         string `json:"duration"`
 }
 
 // PipedriveActivityResponse represents the response from Pipedrive activities API
@@ -662,36 +2201,140 @@ type PipedriveActivityResponse struct {
 	Data    *PipedriveActivity `json:"data"`
 }
 
+// PipedriveDeal represents a Pipedrive deal
+type PipedriveDeal struct {
+	ID         int     `json:"id"`
+	Title      string  `json:"title"`
+	Status     string  `json:"status"` // open, won, lost
+	Value      float64 `json:"value"`
+	Currency   string  `json:"currency"`
+	StageID    int     `json:"stage_id"`
+	PipelineID int     `json:"pipeline_id"`
+	UpdateTime string  `json:"update_time"`
+}
+
 // NewPipedriveService creates a new Pipedrive service instance
 func NewPipedriveService(config *Config) *PipedriveService {
-	return &PipedriveService{
-		config:       config,
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
-		callMappings: make(map[string]CallMapping),
+	httpClient := newHTTPClient(config)
+	workers := NewWorkerPool(config)
+	slo := NewSLOTracker(config)
+	var conditional *conditionalCache
+	if config.PipedriveConditionalRequests {
+		conditional = newConditionalCache()
 	}
+	schedules := NewJobScheduler(config)
+	keys := newKeyProvider(config)
+	locker := newRedisLocker(config)
+	service := &PipedriveService{
+		config:          config,
+		httpClient:      httpClient,
+		callMappings:    newCallMappingStore(config, locker),
+		whatsApp:        newWhatsAppProvider(config, httpClient),
+		stripe:          newStripeClient(config, httpClient),
+		facebook:        newFacebookLeadsClient(config, httpClient),
+		cal:             newCalClient(config, httpClient),
+		calendar:        newGoogleCalendarClient(config, httpClient),
+		sms:             newSMSProvider(config, httpClient),
+		reminders:       NewReminderScheduler(schedules),
+		meetings:        NewMeetingTracker(schedules),
+		nurtures:        NewNurtureScheduler(schedules),
+		intake:          NewIntakeTracker(),
+		emailDedup:      NewEmailDedupStore(config),
+		captureLimiter:  NewCaptureRateLimiter(config.CaptureRateLimit),
+		attributions:    NewAttributionStore(),
+		auditLog:        NewAuditLog(config.AuditLogFile, config.AuditMaxEntries),
+		callWindows:     NewCallWindowTracker(config),
+		coalescer:       newLeadCoalescer(config),
+		locker:          locker,
+		leases:          newLocalLeases(config),
+		callEvents:      NewCallEventStore(config.CallEventsFile, keys, config.TenantFor),
+		kpis:            NewKPITracker(config),
+		reconciliations: NewReconciliationLog(),
+		phoneValidator:  newPhoneValidator(config, httpClient),
+		processing:      NewProcessingTracker(workers, slo),
+		transcripts:     NewTranscriptStore(keys, config.RegionPolicy()),
+		llm:             newLLMClient(config, httpClient),
+		adminAuth:       NewAdminAuthenticator(config, httpClient),
+		experiments:     NewExperimentTracker(config),
+		snoozes:         NewSnoozeStore(),
+		owners:          NewOwnerResolver(),
+		exporters:       newTranscriptExporters(config, httpClient),
+		workers:         workers,
+		conditional:     conditional,
+		dialRules:       NewDialRuleTracker(),
+		slo:             slo,
+		emailValidator:  NewEmailValidator(config),
+		lostMarks:       NewLostMarkStore(config.LostMarksFile),
+		memory:          NewMemoryStore(config, keys),
+		teamRouter:      NewTeamRouter(config),
+		retention:       NewRetentionManager(config),
+		personChanges:   NewPersonChangeTracker(config),
+		invites:         NewMeetingInviteStore(config.MeetingInvitesFile),
+		shortLinks:      NewShortLinkStore(config),
+		campaigns:       NewCampaignStore(config),
+		retries:         NewPipedriveRetryQueue(config),
+		callQueue:       NewCallQueue(),
+		rateLimiter:     NewPipedriveRateLimiter(config),
+		phoneBackfills:  NewPhoneBackfiller(),
+		mailer:          newMailer(config),
+		phoneNumbers:    &RetellNumberCache{},
+		features:        NewPipedriveFeatureGate(config),
+		webhooks:        NewWebhookArchive(config, keys),
+		leadLabels:      &LeadLabelCache{},
+		apiUser:         &APIUserCache{},
+		personProxy:     NewPersonProxyCache(),
+		retellFailover:  NewRetellFailover(config),
+		webhookGaps:     NewWebhookGapTracker(),
+		unsubscribes:    NewUnsubscribeList(config),
+		schedules:       schedules,
+	}
+	service.deals = newDealService(config, service)
+	service.auditLog.shared = locker
+	service.auditLog.tenantOf = func(entry AuditEntry) string {
+		if entry.CallID == "" {
+			return ""
+		}
+		return service.callTenant(entry.CallID)
+	}
+	service.handleScheduledJobs()
+	return service
 }
 
 // makePipedriveRequest makes an HTTP request to Pipedrive API
 func (p *PipedriveService) makePipedriveRequest(method, endpoint string, body interface{}) (*http.Response, error) {
+	return p.doPipedriveRequest(method, endpoint, body, true)
+}
+
+// doPipedriveRequest makes a Pipedrive request, queueing failed activity, note and person writes
+// for another attempt when retry is set
+func (p *PipedriveService) doPipedriveRequest(method, endpoint string, body interface{}, retry bool) (*http.Response, error) {
+	// Don't keep calling endpoints the plan or token scopes refuse
+	if err := p.features.check(p.featureTenant(), pipedriveEndpoint(method, endpoint)); err != nil {
+		return nil, err
+	}
+
 	// Check if endpoint already has query parameters
 	separator := "?"
 	if strings.Contains(endpoint, "?") {
 		separator = "&"
-		log.Printf("🔧 [DEBUG] Endpoint contains '?', using '&' separator")
+		p.debugf(SubsystemPipedrive, "Endpoint contains '?', using '&' separator")
 	} else {
-		log.Printf("🔧 [DEBUG] Endpoint does NOT contain '?', using '?' separator")
+		p.debugf(SubsystemPipedrive, "Endpoint does NOT contain '?', using '?' separator")
 	}
-	log.Printf("🔧 [DEBUG] Endpoint before building URL: %s", endpoint)
-	url := p.config.PipedriveBaseURL + endpoint + separator + "api_token=" + p.config.PipedriveAPIKey
+	p.debugf(SubsystemPipedrive, "Endpoint before building URL: %s", endpoint)
+	url := p.config.pipedriveURL(endpoint) + separator + "api_token=" + p.config.PipedriveAPIKey
 	
 	var reqBody io.Reader
-	if body != nil {
+	contentType := "application/json"
+	if upload, ok := body.(*pipedriveUpload); ok {
+		reqBody, contentType = bytes.NewReader(upload.data), upload.contentType
+	} else if body != nil {
 		jsonData, err := json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %v", err)
 		}
 		reqBody = bytes.NewBuffer(jsonData)
-		log.Printf("📤 Request Body: %s", string(jsonData))
+		p.debugf(SubsystemPipedrive, "Request Body: %s", string(jsonData))
 	}
 	
 	req, err := http.NewRequest(method, url, reqBody)
@@ -699,26 +2342,58 @@ func (p *PipedriveService) makePipedriveRequest(method, endpoint string, body in
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Accept", "application/json")
+	if method == "GET" && p.conditional != nil {
+		p.conditional.apply(endpoint, req)
+	}
 	
-	log.Printf("🌐 Making %s request to Pipedrive: %s", method, endpoint)
-	log.Printf("🔗 Full URL: %s", url)
+	p.logf("🌐 Making %s request to Pipedrive: %s", method, endpoint)
+	p.debugf(SubsystemPipedrive, "Full URL: %s", redactSecrets(url))
 	
+	// Wait for our turn under the Pipedrive rate limit
+	if err := p.rateLimiter.Wait(method == "GET"); err != nil {
+		// Not queued for a retry: the retry would only add to the load holding the limiter up
+		p.logf("🚦 %s %s refused: %v", method, endpoint, err)
+		return nil, err
+	}
+
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
+		if retry {
+			p.queuePipedriveRetry(method, endpoint, body, 0, err.Error(), 0)
+		}
 		return nil, fmt.Errorf("failed to make request: %v", err)
 	}
+	p.rateLimiter.Observe(resp)
+	if method != "GET" && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		p.auditPipedriveWrite(method, endpoint, body)
+	}
 	
 	// Log the response
-	log.Printf("📥 Pipedrive Response Status: %d", resp.StatusCode)
+	p.logf("📥 Pipedrive Response Status: %d", resp.StatusCode)
 	
 	// Read and log response body
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("❌ Failed to read response body: %v", err)
+		p.logf("❌ Failed to read response body: %v", err)
 	} else {
-		log.Printf("📥 Pipedrive Response Body: %s", string(bodyBytes))
+		p.debugf(SubsystemPipedrive, "Pipedrive Response Body: %s", string(bodyBytes))
+	}
+	p.recordFeatureResponse(method, endpoint, resp.StatusCode, bodyBytes)
+	if retry && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) {
+		p.queuePipedriveRetry(method, endpoint, body, resp.StatusCode, truncateRetryError(bodyBytes), retryAfter(resp))
+	}
+
+	// Serve unchanged resources from the conditional request cache
+	if method == "GET" && p.conditional != nil {
+		var notModified bool
+		bodyBytes, notModified = p.conditional.resolve(endpoint, resp, bodyBytes)
+		if notModified {
+			p.debugf(SubsystemPipedrive, "Pipedrive %s not modified, using cached response", endpoint)
+			resp.StatusCode = http.StatusOK
+			resp.Status = "200 OK"
+		}
 	}
 	
 	// Create a new response with the body for further processing
@@ -740,20 +2415,37 @@ func (p *PipedriveService) GetPersonByID(personID int) (*PipedrivePerson, error)
 		return nil, fmt.Errorf("failed to get person: HTTP %d", resp.StatusCode)
 	}
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
 	var result PipedrivePersonResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
 
-	if !result.Success {
+	if !result.Success || result.Data == nil {
 		return nil, fmt.Errorf("failed to get person")
 	}
 
+	// Keep the raw fields so custom fields can be mapped by key
+	var raw struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &raw); err == nil {
+		result.Data.Fields = raw.Data
+	}
+
 	return result.Data, nil
 }
 
 // extractPhoneFromPerson extracts phone number from PipedrivePerson
 func (p *PipedriveService) extractPhoneFromPerson(person *PipedrivePerson) string {
+	// Prefer the E.164 number written by the phone backfill while it matches the main phone
+	if backfilled := p.config.backfilledPhone(person); backfilled != "" {
+		return backfilled
+	}
 	if person.Phone != nil && len(person.Phone) > 0 {
 		phoneNumber := person.Phone[0].Value
 		
@@ -778,58 +2470,91 @@ func (p *PipedriveService) extractPhoneFromPerson(person *PipedrivePerson) strin
 }
 
 // CreateRetellCall creates a call via Retell AI API
-func (p *PipedriveService) CreateRetellCall(phoneNumber, personName, leadTitle string) (string, error) {
+func (p *PipedriveService) CreateRetellCall(phoneNumber, personName, leadTitle, agentID, fromNumber string, extraVariables map[string]interface{}, metadata *CallMetadata) (string, error) {
 	// Check if we have valid Retell AI configuration
 	if p.config.RetellAPIKey == "" || p.config.RetellAssistantID == "" {
 		return "", fmt.Errorf("Retell AI not configured: missing API key or assistant ID")
 	}
+	if agentID == "" {
+		agentID = p.config.RetellAssistantID
+	}
+	if fromNumber == "" {
+		fromNumber = p.config.RetellFromNumber
+	}
 
-	log.Printf("🚀 Creating Retell AI call for %s (%s) - Lead: %s", personName, phoneNumber, leadTitle)
+	p.logf("🚀 Creating Retell AI call for %s (%s) - Lead: %s", personName, phoneNumber, leadTitle)
 
 	callRequest := RetellCallRequest{
-		FromNumber:          p.config.RetellFromNumber,
+		FromNumber:          fromNumber,
 		ToNumber:            phoneNumber,
-		AssistantID:         p.config.RetellAssistantID,
+		AssistantID:         agentID,
 		MaxDurationSeconds:  300, // 5 minutes max
 		DynamicVariables: map[string]interface{}{
 			"person_name": personName,
 			"lead_title":  leadTitle,
 		},
+		Metadata: metadata,
+	}
+	for name, value := range p.voicemailVariables(personName, leadTitle) {
+		callRequest.DynamicVariables[name] = value
+	}
+	for name, value := range extraVariables {
+		callRequest.DynamicVariables[name] = value
+	}
+
+	account := p.activeRetellAccount()
+	callID, failedOver, err := p.postRetellCall(account, callRequest)
+	if failedOver {
+		// The call that tripped the failover is placed on the secondary account rather than dropped
+		callID, _, err = p.postRetellCall(p.config.secondaryRetellAccount(), callRequest)
+	}
+	return callID, err
+}
+
+// postRetellCall creates a call on a Retell account, feeding the outcome to the failover; it
+// reports whether this attempt failed calls over to the secondary account
+func (p *PipedriveService) postRetellCall(account RetellAccount, callRequest RetellCallRequest) (string, bool, error) {
+	if account.Name == RetellAccountSecondary {
+		callRequest.AssistantID = p.config.secondaryRetellAgent(callRequest.AssistantID)
+		if p.config.RetellSecondaryFromNumber != "" {
+			callRequest.FromNumber = p.config.RetellSecondaryFromNumber
+		}
 	}
 
 	// Use the correct Retell AI endpoint
-	url := p.config.RetellBaseURL + "/v2/create-phone-call"
+	url := account.BaseURL + "/v2/create-phone-call"
 	jsonData, err := json.Marshal(callRequest)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal call request: %v", err)
+		return "", false, fmt.Errorf("failed to marshal call request: %v", err)
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return "", false, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.config.RetellAPIKey)
+	req.Header.Set("Authorization", "Bearer "+account.APIKey)
 
-	log.Printf("🌐 Making Retell AI call to: %s", url)
-	log.Printf("📤 Request Body: %s", string(jsonData))
-	log.Printf("🔑 Using API Key: %s...", p.config.RetellAPIKey[:min(8, len(p.config.RetellAPIKey))])
+	p.logf("🌐 Making Retell AI call to: %s (%s account)", url, account.Name)
+	p.debugf(SubsystemRetell, "Request Body: %s", string(jsonData))
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to make Retell AI request: %v", err)
+		failedOver := p.recordRetellCallResult(account, 0, nil, err)
+		return "", failedOver, fmt.Errorf("failed to make Retell AI request: %v", err)
 	}
 	defer resp.Body.Close()
 
-	log.Printf("📥 Retell AI Response Status: %d", resp.StatusCode)
+	p.logf("📥 Retell AI Response Status: %d", resp.StatusCode)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
+		return "", false, fmt.Errorf("failed to read response body: %v", err)
 	}
+	failedOver := p.recordRetellCallResult(account, resp.StatusCode, body, nil)
 
-	log.Printf("📥 Retell AI Response Body: %s", string(body))
+	p.debugf(SubsystemRetell, "Retell AI Response Body: %s", string(body))
 
 	if resp.StatusCode == 200 || resp.StatusCode == 201 {
 		var callResponse RetellCallResponse
@@ -838,21 +2563,21 @@ func (p *PipedriveService) CreateRetellCall(phoneNumber, personName, leadTitle s
 			var responseMap map[string]interface{}
 			if err := json.Unmarshal(body, &responseMap); err == nil {
 				if callID, ok := responseMap["call_id"].(string); ok {
-					log.Printf("✅ Successfully created Retell AI call: %s", callID)
-					return callID, nil
+					p.logf("✅ Successfully created Retell AI call: %s", callID)
+					return callID, false, nil
 				}
 				if callID, ok := responseMap["id"].(string); ok {
-					log.Printf("✅ Successfully created Retell AI call: %s", callID)
-					return callID, nil
+					p.logf("✅ Successfully created Retell AI call: %s", callID)
+					return callID, false, nil
 				}
 			}
-			return "", fmt.Errorf("failed to parse Retell AI response: %v", err)
+			return "", false, fmt.Errorf("failed to parse Retell AI response: %v", err)
 		}
-		log.Printf("✅ Successfully created Retell AI call: %s", callResponse.CallID)
-		return callResponse.CallID, nil
+		p.logf("✅ Successfully created Retell AI call: %s", callResponse.CallID)
+		return callResponse.CallID, false, nil
 	}
 
-	return "", fmt.Errorf("Retell AI call failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	return "", failedOver, fmt.Errorf("Retell AI call failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
 }
 
 // min returns the minimum of two integers
@@ -865,122 +2590,753 @@ func min(a, b int) int {
 
 // storeCallMapping stores call information for later retrieval
 func (p *PipedriveService) storeCallMapping(callID, personName, phoneNumber, leadTitle string, personID int) {
-	p.callMappings[callID] = CallMapping{
+	err := p.callMappings.Put(callID, CallMapping{
 		PersonName:  personName,
 		PhoneNumber: phoneNumber,
 		LeadTitle:   leadTitle,
 		PersonID:    personID,
 		Timestamp:   time.Now(),
+	})
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to store call mapping for %s: %v", callID, err)
+		return
+	}
+	p.logf("📝 Stored call mapping for %s: %s (%s)", callID, personName, phoneNumber)
+}
+
+// getCallMapping retrieves call information by call ID
+func (p *PipedriveService) getCallMapping(callID string) (CallMapping, bool) {
+	mapping, exists, err := p.callMappings.Get(callID)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to read call mapping for %s: %v", callID, err)
+	}
+	return mapping, exists
+}
+
+// updateCallMapping applies a change to a stored call mapping, atomically across replicas
+func (p *PipedriveService) updateCallMapping(callID string, update func(*CallMapping)) {
+	if _, err := p.callMappings.Update(callID, update); err != nil {
+		p.logf("⚠️ Warning: Failed to update call mapping for %s: %v", callID, err)
+	}
+}
+
+// listCallMappings returns a copy of all stored call mappings keyed by call ID
+func (p *PipedriveService) listCallMappings() map[string]CallMapping {
+	calls, err := p.callMappings.List()
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to list call mappings: %v", err)
+		return map[string]CallMapping{}
 	}
-	log.Printf("📝 Stored call mapping for %s: %s (%s)", callID, personName, phoneNumber)
+	return calls
 }
 
 // ProcessPipedriveLead processes a Pipedrive lead webhook and triggers a Retell AI call
 func (p *PipedriveService) ProcessPipedriveLead(payload PipedriveLeadWebhookPayload) error {
-	log.Printf("🔍 [SIMULATION MODE] Processing Pipedrive lead webhook")
-	log.Printf("   Lead ID: %s", payload.Data.ID)
-	log.Printf("   Title: %s", payload.Data.Title)
-	log.Printf("   Person ID: %d", payload.Data.PersonID)
-	log.Printf("   Action: %s", payload.Meta.Action)
-	log.Printf("   ⚠️  This is a SIMULATION SERVER - not real Pipedrive or Retell AI")
+	p.logf("🔍 [SIMULATION MODE] Processing Pipedrive lead webhook")
+	p.logf("   Lead ID: %s", payload.Data.ID)
+	p.logf("   Title: %s", payload.Data.Title)
+	p.logf("   Person ID: %d", payload.Data.PersonID)
+	p.logf("   Action: %s", payload.Meta.Action)
+	p.logf("   ⚠️  This is a SIMULATION SERVER - not real Pipedrive or Retell AI")
 
 	// Check configuration status
-	log.Printf("🔧 [DEBUG] Pipedrive configured: %t", p.config.HasPipedriveConfig())
-	log.Printf("🔧 [DEBUG] Retell AI configured: %t", p.config.HasRetellConfig())
-	log.Printf("🔧 [DEBUG] Pipedrive API Key: %s", p.config.PipedriveAPIKey)
-	log.Printf("🔧 [DEBUG] Retell API Key: %s", p.config.RetellAPIKey)
-	log.Printf("🔧 [DEBUG] Retell Assistant ID: %s", p.config.RetellAssistantID)
-
-	// Only process lead creation events
-	if payload.Meta.Action != "create" {
-		log.Printf("ℹ️ Skipping lead event: %s (only processing 'create' events)", payload.Meta.Action)
+	p.debugf(SubsystemLead, "Pipedrive configured: %t", p.config.HasPipedriveConfig())
+	p.debugf(SubsystemLead, "Retell AI configured: %t", p.config.HasRetellConfig())
+	p.debugf(SubsystemLead, "Retell Assistant ID: %s", p.config.RetellAssistantID)
+
+	// Only process lead creation events, or the events the lead's campaign triggers on
+	p.assignCampaign(&payload)
+	if !p.campaignTriggers(payload) {
+		return nil
+	}
+
+	if !p.config.AutoCallOnLead {
+		p.logf("ℹ️ Auto-call on lead is disabled (AUTOMATION_AUTO_CALL=false), skipping lead %s", payload.Data.ID)
+		return nil
+	}
+
+	// Leads created by intake are dispatched there; Pipedrive's webhook for them is an echo
+	if payload.Intake == "" && p.intake.Owns(payload.Data.ID) {
+		p.logf("ℹ️ Lead %s was created by intake, skipping webhook", payload.Data.ID)
+		return nil
+	}
+
+	if p.isSnoozed(payload.Data.PersonID, "call for lead "+payload.Data.ID) {
+		return nil
+	}
+
+	// Only one replica handles a lead; the lease is kept for LOCK_TTL_SECONDS so duplicate deliveries are dropped
+	release, ok := p.claim("lead:" + payload.Data.ID)
+	if !ok {
+		return nil
+	}
+
+	// List re-imports must not call people we spoke to within the campaign's window; checked once
+	// the lead is queued for its campaign, before it is coalesced or dialed
+	if p.suppressRecentlyContacted(payload) {
+		return nil
+	}
+
+	// Merge bursts of webhooks for the same person (e.g. a list import) into one call; leads
+	// without a person have nothing to merge on
+	if p.coalescer != nil && payload.Data.PersonID != 0 {
+		err := p.coalescer.Add(payload, func(batch PipedriveLeadWebhookPayload) error {
+			err := p.dialLead(batch)
+			if err != nil {
+				p.logf("❌ Failed to process coalesced lead %s: %v", batch.Data.ID, err)
+			}
+			return err
+		})
+		if err != nil {
+			release()
+		}
+		return err
+	}
+
+	if err := p.dialLead(payload); err != nil {
+		release()
+		return err
+	}
+	return nil
+}
+
+// dialLead looks up the lead's person and places or schedules the call
+func (p *PipedriveService) dialLead(payload PipedriveLeadWebhookPayload) error {
+	if p.campaignStopped(payload) {
 		return nil
 	}
 
 	// Try to process with real integration if configured
 	if p.config.HasPipedriveConfig() && p.config.HasRetellConfig() {
-		log.Printf("🚀 [REAL INTEGRATION] Processing Pipedrive lead webhook")
+		p.logf("🚀 [REAL INTEGRATION] Processing Pipedrive lead webhook")
 
 		// Get person details from Pipedrive
 		person, err := p.GetPersonByID(payload.Data.PersonID)
 		if err != nil {
-			log.Printf("❌ Failed to get person details: %v", err)
+			p.logf("❌ Failed to get person details: %v", err)
 			return fmt.Errorf("failed to get person details: %v", err)
 		}
 
+		// Retries and delayed calls fetch the person again, so a DNC mark since the lead arrived holds
+		if p.isMarkedDNC(person) {
+			p.logf("⛔ Person %d is marked Do Not Call, not calling lead %s", payload.Data.PersonID, payload.Data.ID)
+			return nil
+		}
+
 		// Extract phone number
 		phoneNumber := p.extractPhoneFromPerson(person)
 		if phoneNumber == "" {
-			log.Printf("⚠️ No phone number found for person %d, skipping call", payload.Data.PersonID)
+			p.logf("⚠️ No phone number found for person %d, skipping call", payload.Data.PersonID)
 			return nil
 		}
 
-		log.Printf("📞 Found phone number: %s for person: %s", phoneNumber, person.Name)
+		p.logf("📞 Found phone number: %s for person: %s", phoneNumber, person.Name)
 
-		// Create Retell AI call with person name and lead title
-		callID, err := p.CreateRetellCall(phoneNumber, person.Name, payload.Data.Title)
-		if err != nil {
-			log.Printf("❌ Failed to create Retell AI call: %v", err)
-			// Don't return error, just log it and continue
-			callID = "failed-" + strconv.FormatInt(time.Now().Unix(), 10)
-		} else {
-			log.Printf("✅ Created Retell AI call %s for lead %s (person: %s, phone: %s)", 
-				callID, payload.Data.Title, person.Name, phoneNumber)
+		// Leave leads that a rep is already working to the rep
+		if p.humanWorkingLead(payload) {
+			return nil
 		}
 
-		// Store the call mapping for later use in call_analyzed webhook
-		p.storeCallMapping(callID, person.Name, phoneNumber, payload.Data.Title, payload.Data.PersonID)
+		// Pre-dial line type check
+		var lookup *PhoneLookupResult
+		if p.phoneValidator != nil && (p.config.PhoneLookupPreDial || p.config.hasCarrierDialRules()) {
+			result, err := p.phoneValidator.Validate(phoneNumber)
+			if err != nil {
+				p.logf("⚠️ Warning: Phone lookup failed for %s, dialing anyway: %v", phoneNumber, err)
+			} else {
+				lookup = &result
+			}
+			if lookup != nil && p.config.PhoneLookupPreDial {
+				p.storeLineType(payload.Data.PersonID, lookup.LineType)
+				if !lookup.VoiceEligible() {
+					p.logf("⚠️ Skipping call to %s (valid: %t, line type: %s)", phoneNumber, lookup.Valid, lookup.LineType)
+					return nil
+				}
+			}
+		}
 
-		// Create activity in Pipedrive to track the call
-		activityData := map[string]interface{}{
-			"subject":   fmt.Sprintf("AI Call Initiated - Lead: %s", payload.Data.Title),
-			"type":      "call",
-			"person_id": payload.Data.PersonID,
-			"note":      fmt.Sprintf("Retell AI call initiated for lead: %s\nCall ID: %s\nPhone: %s", 
-				payload.Data.Title, callID, phoneNumber),
-			"done":      0, // Mark as pending
-			"due_date":  time.Now().Format("2006-01-02"),
-			"due_time":  time.Now().Add(5 * time.Minute).Format("15:04:05"),
+		// Blackout and deprioritization rules by prefix or carrier
+		if rule := p.matchDialRule(payload, phoneNumber, lookup); rule != nil {
+			switch rule.Action {
+			case DialRuleSkip:
+				p.logf("⛔ Skipping call to %s for lead %s (dial rule %s)", phoneNumber, payload.Data.ID, rule.Name)
+				return nil
+			case DialRuleDeprioritize:
+				if payload.Deprioritized {
+					p.dialRules.RecordDeferredDial(p.config.leadCampaign(payload), *rule)
+					break
+				}
+				p.deferLeadCall(payload, phoneNumber, rule)
+				return nil
+			}
 		}
 
-		resp, err := p.makePipedriveRequest("POST", "/activities", activityData)
-		if err != nil {
-			log.Printf("⚠️ Warning: Failed to create activity: %v", err)
-		} else {
-			resp.Body.Close()
-			log.Printf("✅ Created activity for Retell AI call")
+		// Campaigns hold calls for their trigger delay and call window
+		if at := p.campaignDialTime(payload, time.Now()); time.Until(at) > time.Second {
+			p.logf("🕐 Delaying call to %s for lead %s until %s (campaign %s)", phoneNumber, payload.Data.ID, at.Format(time.RFC3339), payload.Campaign)
+			payload.CampaignDelayed = true
+			p.holdLeadCall(&payload, QueueReasonCampaign, at)
+			return nil
+		}
+
+		// Mobiles can get an SMS before the call, per campaign
+		if !payload.ChannelSequenced && !payload.Deprioritized && !payload.WindowDelayed {
+			if channel, policy := p.leadChannel(payload, person, phoneNumber, lookup); channel == ChannelSMS && p.startSMSFirst(payload, person, phoneNumber, policy) {
+				return nil
+			}
+		}
+
+		// Hold the call for a window with a clearly better answer rate
+		if p.config.CallWindowBias && !payload.WindowDelayed {
+			if at, ok := p.callWindows.NextBetterWindow(phoneNumber, time.Now(), time.Duration(p.config.CallWindowMaxDelay)*time.Hour); ok {
+				p.logf("🕐 Delaying call to %s for lead %s until %s (better answer rate)", phoneNumber, payload.Data.ID, at.Format(time.RFC3339))
+				payload.WindowDelayed = true
+				p.holdLeadCall(&payload, QueueReasonCallWindow, at)
+				return nil
+			}
+		}
+
+		// Snoozes, skip dial rules and contact hours apply to every call, however it was scheduled
+		blocked, notUntil := p.contactGate(person, phoneNumber, ContactCall, "call for lead "+payload.Data.ID, time.Now())
+		if blocked != "" {
+			return nil
 		}
+		if !notUntil.IsZero() {
+			p.holdLeadCall(&payload, QueueReasonContactHours, notUntil)
+			return nil
+		}
+
+		p.placeLeadCall(payload, person, phoneNumber)
 	} else {
-		log.Printf("⚠️  Configuration missing - running in simulation mode")
+		p.logf("⚠️  Configuration missing - running in simulation mode")
 		if !p.config.HasPipedriveConfig() {
-			log.Printf("   Missing: PIPEDRIVE_API_KEY")
+			p.logf("   Missing: PIPEDRIVE_API_KEY")
 		}
 		if !p.config.HasRetellConfig() {
-			log.Printf("   Missing: RETELL_API_KEY or RETELL_ASSISTANT_ID")
+			p.logf("   Missing: RETELL_API_KEY or RETELL_ASSISTANT_ID")
 		}
 	}
 
 	return nil
 }
 
+// placeLeadCall creates the Retell call for a lead and logs it in Pipedrive
+func (p *PipedriveService) placeLeadCall(payload PipedriveLeadWebhookPayload, person *PipedrivePerson, phoneNumber string) {
+	if p.campaignStopped(payload) {
+		return
+	}
+	queuedAt := time.Now()
+	// Make sure activities go to an active user
+	ownerID := p.resolveLeadOwner(payload.Data.ID, payload.Data.Title, payload.Data.OwnerID)
+
+	// Build call context from mapped person and lead custom fields
+	variables := p.mappedDynamicVariables(person)
+	leadContext := p.leadDynamicVariables(payload.Data.CustomFields)
+	for name, value := range leadContext {
+		variables[name] = value
+	}
+
+	// Let the agent reference the campaign the lead came from
+	for name, value := range p.attributions.Get(payload.Data.ID) {
+		variables[name] = value
+	}
+
+	// Offer concrete meeting times the agent can propose during the call
+	if p.cal != nil {
+		if slots, err := p.cal.AvailableSlots(time.Now(), p.config.CalSlotDays); err != nil {
+			p.logf("⚠️ Warning: Failed to fetch Cal.com availability: %v", err)
+		} else {
+			slots = p.repAvailableSlots(ownerID, slots)
+			locale := p.config.LocaleFor(p.leadTenant(payload))
+			for name, value := range calSlotVariables(slots, p.config.CalOfferedSlots, p.config.CalTimeZone, locale) {
+				variables[name] = value
+			}
+		}
+	}
+
+	// Pick the assistant variant when the lead's campaign is under experiment
+	var assignment *ExperimentAssignment
+	if campaign, variants, ok := p.config.ExperimentFor(p.config.leadCampaign(payload)); ok {
+		variant := assignVariant(payload.Data.ID, campaign, variants)
+		assignment = &ExperimentAssignment{Campaign: campaign, Variant: variant.Name, AgentID: variant.AgentID}
+		p.logf("🧪 Lead %s assigned to variant %s (agent %s) in campaign %s", payload.Data.ID, variant.Name, variant.AgentID, campaign)
+	}
+	agentID := p.config.RetellAssistantID
+	template, hasTemplate := p.campaignTemplate(payload)
+	if hasTemplate && template.AgentID != "" {
+		agentID = template.AgentID
+	}
+	if assignment != nil {
+		agentID = assignment.AgentID
+	}
+	if !p.tenantAllows(payload.Tenant, agentID, "Lead "+payload.Data.ID) {
+		return
+	}
+
+	// The call belongs to the tenant of the agent picked for the lead, also when the
+	// announcing assistant places it
+	tenant := p.config.TenantFor(agentID)
+
+	// Jurisdictions requiring recording consent use the announcing assistant
+	agentID, consentRequired := p.consentAgent(phoneNumber, agentID, variables)
+
+	// Let the agent pick up where earlier calls left off
+	p.addMemoryVariables(variables, payload.Data.PersonID, tenant)
+
+	// Spanish-language and other campaigns can call from their own number
+	fromNumber := p.campaignFromNumber(payload)
+	if fromNumber == "" {
+		fromNumber = p.leadFromNumber(payload, agentID)
+	}
+
+	// Delayed and deferred dispatches may fire on any replica
+	dialKey := "dial:" + payload.Data.ID
+	if payload.Attempt > 0 {
+		dialKey = fmt.Sprintf("dial:%s:retry-%d", payload.Data.ID, payload.Attempt)
+	}
+	release, ok := p.claim(dialKey)
+	if !ok {
+		return
+	}
+
+	// Create Retell AI call with person name and lead title
+	metadata := &CallMetadata{
+		Tenant:     tenant,
+		Campaign:   p.config.leadCampaign(payload),
+		LeadID:     payload.Data.ID,
+		PersonID:   payload.Data.PersonID,
+		PersonName: person.Name,
+		LeadTitle:  payload.Data.Title,
+		OwnerID:    ownerID,
+		Region:     p.config.RegionPolicy().RegionOf(tenant),
+		FromNumber: fromNumber,
+		Experiment: assignment,
+		Attempt:    payload.Attempt,
+	}
+	callID, err := p.CreateRetellCall(phoneNumber, person.Name, payload.Data.Title, agentID, fromNumber, variables, metadata)
+	if err != nil {
+		release()
+		p.logf("❌ Failed to create Retell AI call: %v", err)
+		// Don't return error, just log it and continue
+		callID = "failed-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	} else {
+		p.logf("✅ Created Retell AI call %s for lead %s (person: %s, phone: %s)",
+			callID, payload.Data.Title, person.Name, phoneNumber)
+		p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "call_placed", PersonID: payload.Data.PersonID, CallID: callID, Detail: fmt.Sprintf("lead %s with agent %s", payload.Data.ID, agentID)})
+	}
+
+	// Store the call mapping for later use in call_analyzed webhook
+	p.storeCallMapping(callID, person.Name, phoneNumber, payload.Data.Title, payload.Data.PersonID)
+	if assignment != nil && err == nil {
+		p.updateCallMapping(callID, func(m *CallMapping) { m.Experiment = assignment })
+		p.experiments.RecordCall(*assignment)
+	}
+
+	p.updateCallMapping(callID, func(m *CallMapping) {
+		m.OwnerID = ownerID
+		m.Tenant = metadata.Tenant
+		m.Region = metadata.Region
+		m.LeadID = payload.Data.ID
+		m.FromNumber = fromNumber
+		m.Campaign = metadata.Campaign
+		m.Attempt = payload.Attempt
+		if consentRequired {
+			m.Consent = &ConsentRecord{Status: ConsentUnknown, RecordedAt: time.Now()}
+		}
+	})
+	p.callEvents.Append(CallEvent{CallID: callID, Type: CallEventQueued, Timestamp: queuedAt, LeadID: payload.Data.ID, PersonID: payload.Data.PersonID})
+	if err != nil {
+		p.callEvents.Append(CallEvent{CallID: callID, Type: CallEventFailed, LeadID: payload.Data.ID, PersonID: payload.Data.PersonID, Detail: err.Error()})
+	} else {
+		mapping, _ := p.getCallMapping(callID)
+		p.callEvents.Append(CallEvent{CallID: callID, Type: CallEventDialing, LeadID: payload.Data.ID, PersonID: payload.Data.PersonID, Mapping: &mapping})
+	}
+
+	// Create activity in Pipedrive to track the call
+	activityData := map[string]interface{}{
+		"subject":   fmt.Sprintf("AI Call Initiated - Lead: %s", payload.Data.Title),
+		"type":      "call",
+		"person_id": payload.Data.PersonID,
+		"note": fmt.Sprintf("Retell AI call initiated for lead: %s\nCall ID: %s\nPhone: %s%s",
+			payload.Data.Title, callID, phoneNumber, leadContextNoteSection(leadContext)+coalescedNoteSection(payload.Coalesced)),
+		"done":     0, // Mark as pending
+		"due_date": pipedriveDueDate(time.Now()),
+		"due_time": pipedriveDueTime(time.Now().Add(5 * time.Minute)),
+	}
+	if ownerID != 0 {
+		activityData["user_id"] = ownerID
+	}
+	p.linkActivityToDeal(activityData, payload.Data.PersonID)
+
+	// A queued call already has its initiation activity, created with the ETA
+	if payload.QueuedActivityID != 0 {
+		err := p.updateActivity(payload.QueuedActivityID, activityData)
+		if err == nil {
+			p.logf("✅ Updated queued call activity %d for Retell AI call", payload.QueuedActivityID)
+			return
+		}
+		p.logf("⚠️ Warning: Failed to update queued call activity %d, creating a new one: %v", payload.QueuedActivityID, err)
+	}
+
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to create activity: %v", err)
+	} else {
+		resp.Body.Close()
+		p.logf("✅ Created activity for Retell AI call")
+	}
+}
+
 // ProcessRetellCall processes a Retell AI call webhook
 func (p *PipedriveService) ProcessRetellCall(payload RetellWebhookPayload) error {
-	log.Printf("🔧 [DEBUG] ProcessRetellCall called with event: %s", payload.Event)
+	p.debugf(SubsystemRetell, "ProcessRetellCall called with event: %s", payload.Event)
 	if p.config.HasPipedriveConfig() {
-		log.Printf("🚀 [REAL PIPEDRIVE] Processing Retell webhook: %s", payload.Event)
-		// Implementation for real Pipedrive integration
+		p.logf("🚀 [REAL PIPEDRIVE] Processing Retell webhook: %s", payload.Event)
+
+		personID, err := p.resolveCallPersonID(payload.CallID, payload.ContactPhone, payload.Metadata)
+		if err != nil {
+			return err
+		}
+
+		callTime, err := ParseTimestamp(payload.Timestamp, p.config.naiveLocation())
+		if err != nil {
+			callTime = NewTimestamp(time.Now().UTC())
+		}
+		if eventType := retellCallEventType(payload.Event); eventType != "" {
+			p.callEvents.Append(CallEvent{CallID: payload.CallID, Type: eventType, Timestamp: callTime.UTC, Zone: callTime.Zone, PersonID: personID})
+		}
+
+		switch payload.Event {
+		case "call_started", "call.started":
+			if !p.config.ActivityOnCallStarted {
+				p.logf("ℹ️ Call started activity is disabled, skipping call %s", payload.CallID)
+				return nil
+			}
+			return p.createCallEventActivity(personID, callEventSubject("AI Call Started", payload.direction()), payload, callTime, false)
+		case "call.optout":
+			if p.config.DNCOnOptout {
+				if err := p.MarkContactAsDNC(personID); err != nil {
+					return err
+				}
+				p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "dnc_set", PersonID: personID, CallID: payload.CallID, Detail: "call.optout"})
+				// Queued lead and follow-up calls for the person check the hold before dialing
+				p.holdPersonCalls(personID, "opted out")
+			} else {
+				p.logf("ℹ️ DNC on opt-out is disabled, not marking person %d", personID)
+			}
+			p.updateCallMapping(payload.CallID, func(m *CallMapping) { m.OptedOut = true })
+			return p.createCallEventActivity(personID, "Customer Opted Out", payload, callTime, true)
+		case "call_ended", "call.completed", "call.hangup":
+			return p.createCallEventActivity(personID, callEventSubject("AI Call Ended", payload.direction()), payload, callTime, true)
+		default:
+			p.logf("⚠️ Unknown event type: %s", payload.Event)
+		}
 	} else {
-		log.Printf("🔍 [SIMULATION MODE] Processing Retell webhook: %s", payload.Event)
-		log.Printf("   Call ID: %s", payload.CallID)
-		log.Printf("   Phone: %s", payload.ContactPhone)
-		log.Printf("   Duration: %s", payload.Duration)
-		log.Printf("   Status: %s", payload.Status)
+		p.logf("🔍 [SIMULATION MODE] Processing Retell webhook: %s", payload.Event)
+		p.logf("   Call ID: %s", payload.CallID)
+		p.logf("   Phone: %s", payload.ContactPhone)
+		p.logf("   Duration: %s", payload.Duration)
+		p.logf("   Status: %s", payload.Status)
 
 		if payload.Transcript != "" {
-			log.Printf("   Transcript: %s", payload.Transcript)
+			p.logf("   Transcript: %s", payload.Transcript)
 		}
 
-		log.Printf("   ⚠️  This is a SIMULATION SERVER - not real Retell AI or Pipedrive")
+		p.logf("   ⚠️  This is a SIMULATION SERVER - not real Retell AI or Pipedrive")
+	}
+
+	return nil
+}
+
+// resolveCallPersonID finds the Pipedrive person for a call, preferring the stored call mapping or
+// the call's Retell metadata
+func (p *PipedriveService) resolveCallPersonID(callID, phoneNumber string, metadata *CallMetadata) (int, error) {
+	if mapping, ok := p.callMappingFor(callID, metadata, phoneNumber); ok {
+		return mapping.PersonID, nil
+	}
+
+	searchURL := fmt.Sprintf("/persons/search?term=%s&fields=phone", url.QueryEscape(phoneNumber))
+	resp, err := p.makePipedriveRequest("GET", searchURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search for contact: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var searchResult struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Items []struct {
+				Item PipedrivePerson `json:"item"`
+			} `json:"items"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchResult); err != nil {
+		return 0, fmt.Errorf("failed to decode search response: %v", err)
+	}
+	if !searchResult.Success || len(searchResult.Data.Items) == 0 {
+		return 0, fmt.Errorf("no person found for call %s (phone %s)", callID, phoneNumber)
+	}
+	return searchResult.Data.Items[0].Item.ID, nil
+}
+
+// createCallEventActivity logs a Retell call event as a Pipedrive activity
+func (p *PipedriveService) createCallEventActivity(personID int, subject string, payload RetellWebhookPayload, callTime Timestamp, done bool) error {
+	doneValue := 0
+	if done {
+		doneValue = 1
+	}
+
+	activityData := map[string]interface{}{
+		"subject":   subject,
+		"type":      p.config.CallActivityType(payload.direction()),
+		"person_id": personID,
+		"note":      p.callEventNote(subject, payload, callTime),
+		"done":      doneValue,
+		"due_date":  pipedriveDueDate(callTime.UTC),
+		"due_time":  pipedriveDueTime(callTime.UTC),
+	}
+	p.linkActivityToDeal(activityData, personID)
+
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		return fmt.Errorf("failed to create call activity: %v", err)
+	}
+	resp.Body.Close()
+
+	p.logf("✅ Created '%s' activity for person %d", subject, personID)
+	return nil
+}
+
+// MarkContactAsDNC marks a person as Do Not Call in Pipedrive
+func (p *PipedriveService) MarkContactAsDNC(personID int) error {
+	updateData := map[string]interface{}{
+		p.config.DNCField: true,
+	}
+
+	resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", personID), updateData)
+	if err != nil {
+		return fmt.Errorf("failed to mark as DNC: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("failed to mark as DNC: HTTP %d", resp.StatusCode)
+	}
+
+	p.logf("🚫 Marked person %d as Do Not Call (DNC)", personID)
+	return nil
+}
+
+// ProcessRetellCallAnalyzed processes a Retell AI call_analyzed webhook
+func (p *PipedriveService) ProcessRetellCallAnalyzed(payload RetellCallAnalyzedPayload) (err error) {
+	if debugTenantEnabled(p.config.TenantFor(payload.Call.AgentID)) {
+		if dump, err := json.Marshal(payload); err == nil {
+			p.logger().Debug(fmt.Sprintf("call_analyzed payload for tenant %s: %s", p.config.TenantFor(payload.Call.AgentID), string(dump)), "subsystem", SubsystemRetell)
+		}
+	}
+
+	// Validate custom analysis data against the assistant's schema before anything touches Pipedrive
+	schema, hasSchema := p.config.AnalysisSchemaFor(payload.Call.AgentID)
+	var validation AnalysisValidationResult
+	if hasSchema {
+		validation = schema.Validate(payload.Call.CallAnalysis.CustomAnalysisData)
+		if validation.HasDrift() {
+			p.logf("⚠️ [ANALYSIS SCHEMA] Drift detected for agent %s on call %s: missing=%v invalid=%v unknown=%v",
+				payload.Call.AgentID, payload.Call.CallID, validation.Missing, validation.Invalid, validation.Unknown)
+		}
+	} else if len(payload.Call.CallAnalysis.CustomAnalysisData) > 0 {
+		p.logf("ℹ️ [ANALYSIS SCHEMA] No schema configured for agent %s, custom analysis data will not be mapped", payload.Call.AgentID)
+	}
+
+	if !p.config.HasPipedriveConfig() {
+		p.logf("🔍 [SIMULATION MODE] Processing Retell call_analyzed webhook")
+		p.logf("   Call ID: %s", payload.Call.CallID)
+		p.logf("   Agent: %s", payload.Call.AgentName)
+		p.logf("   Duration: %d ms", payload.Call.DurationMs)
+		p.logf("   Sentiment: %s", payload.Call.CallAnalysis.UserSentiment)
+		p.logf("   Validated analysis fields: %d", len(validation.Valid))
+		p.logf("   ⚠️  This is a SIMULATION SERVER - not real Retell AI or Pipedrive")
+		return nil
+	}
+
+	p.logf("🚀 [REAL PIPEDRIVE] Processing Retell call_analyzed webhook")
+
+	callMapping, exists := p.callMappingFor(payload.Call.CallID, payload.Call.Metadata, payload.Call.ToNumber)
+	if !exists {
+		p.logf("⚠️ Warning: No call mapping found for call ID: %s, skipping Pipedrive update", payload.Call.CallID)
+		if payload.Replay {
+			return ErrNoCallMapping
+		}
+		return nil
+	}
+	// Counted once per call however often the webhook is delivered or replayed
+	p.recordCallOutcome(payload)
+
+	release := func() {}
+	if !payload.Replay {
+		var ok bool
+		if release, ok = p.claim("analyzed:" + payload.Call.CallID); !ok {
+			return nil
+		}
+	} else {
+		// Replays and refreshes never write a call Pipedrive already has
+		logged, err := p.hasCallActivity(payload.Call.CallID, callMapping, TimestampFromMillis(payload.Call.StartTimestamp).UTC)
+		if err != nil {
+			return fmt.Errorf("failed to check Pipedrive for the call: %v", err)
+		}
+		if logged {
+			p.callEvents.Append(CallEvent{CallID: payload.Call.CallID, Type: CallEventCRMUpdated, LeadID: callMapping.LeadID, PersonID: callMapping.PersonID, Detail: "already in Pipedrive"})
+			return ErrCallAlreadyLogged
+		}
+	}
+	recorded := payload
+	p.callEvents.Append(CallEvent{CallID: payload.Call.CallID, Type: CallEventAnalyzed, LeadID: callMapping.LeadID, PersonID: callMapping.PersonID, Analysis: &recorded})
+	defer func() {
+		if err != nil {
+			release()
+			p.callEvents.Append(CallEvent{CallID: payload.Call.CallID, Type: CallEventFailed, LeadID: callMapping.LeadID, PersonID: callMapping.PersonID, Detail: err.Error()})
+			return
+		}
+		p.callEvents.Append(CallEvent{CallID: payload.Call.CallID, Type: CallEventCRMUpdated, LeadID: callMapping.LeadID, PersonID: callMapping.PersonID})
+	}()
+
+	startTime := TimestampFromMillis(payload.Call.StartTimestamp).UTC
+	p.callWindows.Record(callMapping.PhoneNumber, startTime, callAnswered(payload))
+	if !callAnswered(payload) && !payload.Replay {
+		p.scheduleCampaignRetry(payload.Call.CallID, callMapping)
+	}
+	duration := DurationFromMillis(int64(payload.Call.DurationMs))
+
+	note := fmt.Sprintf("AI Call Analysis\nPerson: %s\nPhone: %s\nLead: %s\nDuration: %s\n\nSummary:\n%s\n\nSentiment: %s\nCall Successful: %t\nRecording: %s\nCall ID: %s",
+		callMapping.PersonName, callMapping.PhoneNumber, callMapping.LeadTitle, duration,
+		payload.Call.CallAnalysis.CallSummary, payload.Call.CallAnalysis.UserSentiment,
+		payload.Call.CallAnalysis.CallSuccessful, payload.Call.RecordingURL, payload.Call.CallID)
+	purchaseIntent := hasPurchaseIntent(payload.Call.CallAnalysis.CustomAnalysisData, p.config.StripeIntentField)
+	score := p.scoreLead(payload, callMapping, purchaseIntent)
+	if score != nil {
+		note += fmt.Sprintf("\nLead Score: %g (%s)", score.Score, strings.Join(score.Matched, ", "))
+		p.updateCallMapping(payload.Call.CallID, func(m *CallMapping) { m.Score = score })
+	}
+	if validation.HasDrift() {
+		note += fmt.Sprintf("\n\nAnalysis schema warnings: missing=%v invalid=%v", validation.Missing, validation.Invalid)
+	}
+	if p.config.RecordingConsentEnabled && callMapping.Consent != nil {
+		if consent := p.recordConsent(payload.Call.CallID, callMapping, payload.Call.TranscriptObject); consent != nil {
+			note += fmt.Sprintf("\nRecording Consent: %s", consent.Status)
+		}
+	}
+	note += p.keyMomentsNoteSection(payload.Call.TranscriptObject, payload.Call.RecordingURL)
+	note += p.transcriptNoteSection(p.config.TenantFor(payload.Call.AgentID), payload.Call.CallID, callMapping.PersonID, payload.Call.Transcript, payload.Call.CallAnalysis.CallSummary)
+
+	activityData := map[string]interface{}{
+		"subject":   fmt.Sprintf("AI Call Analyzed - %s", callMapping.LeadTitle),
+		"type":      p.config.CallActivityType(payload.Call.Direction),
+		"person_id": callMapping.PersonID,
+		"duration":  duration.ActivityDuration(),
+		"note":      note,
+		"done":      1,
+		"due_date":  pipedriveDueDate(startTime),
+		"due_time":  pipedriveDueTime(startTime),
+	}
+	// Messages left on voicemail are logged as their own activity type so reports separate them from conversations
+	if p.isVoicemailDrop(payload, callMapping.PersonName, callMapping.LeadTitle) {
+		activityData["subject"] = fmt.Sprintf("AI Voicemail Left - %s", callMapping.LeadTitle)
+		if p.config.VoicemailActivityType != "" {
+			activityData["type"] = p.config.VoicemailActivityType
+		}
+		activityData["note"] = note + p.voicemailNoteSection(callMapping.PersonName, callMapping.LeadTitle)
+	}
+	if callMapping.OwnerID != 0 {
+		activityData["user_id"] = callMapping.OwnerID
+	}
+
+	if p.config.NoteOnAnalysis {
+		p.linkActivityToDeal(activityData, callMapping.PersonID)
+		resp, err := p.createActivity(activityData)
+		if err != nil {
+			return fmt.Errorf("failed to create call activity: %v", err)
+		}
+		var created PipedriveActivityResponse
+		if err := json.NewDecoder(resp.Body).Decode(&created); err == nil && created.Data.ID != 0 {
+			p.updateCallMapping(payload.Call.CallID, func(m *CallMapping) { m.ActivityID = created.Data.ID })
+		}
+		resp.Body.Close()
+		p.logf("✅ Created call analyzed activity for person %d", callMapping.PersonID)
+	} else {
+		p.logf("ℹ️ Analysis note is disabled, skipping activity for call %s", payload.Call.CallID)
+	}
+
+	// Only validated keys are written to person fields
+	if hasSchema {
+		if fields := schema.PipedriveFields(validation.Valid); len(fields) > 0 {
+			resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", callMapping.PersonID), fields)
+			if err != nil {
+				p.logf("⚠️ Warning: Failed to write analysis fields to person %d: %v", callMapping.PersonID, err)
+			} else {
+				resp.Body.Close()
+				p.logf("✅ Wrote %d analysis fields to person %d", len(fields), callMapping.PersonID)
+			}
+		}
+	}
+
+	if score != nil {
+		p.writeLeadScore(callMapping.PersonID, score)
+	}
+
+	// Mark the originating lead as contacted
+	if p.config.LeadWriteBack && callMapping.LeadID != "" && payload.Call.CallAnalysis.CallSuccessful {
+		if err := p.writeBackLeadOutcome(callMapping.LeadID, startTime); err != nil {
+			p.logf("⚠️ Warning: Failed to update lead %s: %v", callMapping.LeadID, err)
+		}
+	}
+
+	p.createDealFromCall(payload, callMapping)
+	p.escalateCall(payload, callMapping)
+	go p.sendCallSummaryEmail(payload, callMapping)
+
+	p.rememberCall(payload, callMapping)
+
+	// Close out the lead and deal when the call clearly ended in rejection
+	if p.config.LostReasonEnabled {
+		if reason, ok := p.config.lostReasonFor(payload.Call.CallAnalysis.CustomAnalysisData); ok {
+			p.markLost(payload.Call.CallID, callMapping, reason)
+		}
+	}
+
+	if callMapping.Experiment != nil {
+		p.experiments.RecordOutcome(*callMapping.Experiment, payload.Call.CallAnalysis.CallSuccessful, purchaseIntent, score)
+	}
+
+	// Payment link for committed purchase intent
+	if p.stripe != nil && purchaseIntent {
+		if err := p.sendPaymentLink(payload.Call.CallID, callMapping); err != nil {
+			p.logf("⚠️ Warning: Failed to create payment link for call %s: %v", payload.Call.CallID, err)
+		}
+	}
+
+	// Post-call WhatsApp follow-up
+	if p.config.WhatsAppFollowUpEnabled && p.whatsApp != nil && payload.Call.CallAnalysis.CallSuccessful {
+		message := p.buildFollowUpMessage(callMapping.PersonName, payload.Call.CallAnalysis.CallSummary)
+		if err := p.SendWhatsAppFollowUp(callMapping.PersonID, callMapping.PhoneNumber, message); err != nil {
+			p.logf("⚠️ Warning: WhatsApp follow-up failed for person %d: %v", callMapping.PersonID, err)
+		}
+	}
+
+	// Push the call to external call libraries in the background
+	if len(p.exporters) > 0 {
+		exported := callMapping
+		if latest, ok := p.getCallMapping(payload.Call.CallID); ok {
+			exported = latest // Carries the deal created from the call
+		}
+		go p.exportCall(CallExport{
+			CallID:       payload.Call.CallID,
+			Title:        fmt.Sprintf("%s - %s", callMapping.PersonName, callMapping.LeadTitle),
+			Summary:      payload.Call.CallAnalysis.CallSummary,
+			Transcript:   payload.Call.Transcript,
+			RecordingURL: payload.Call.RecordingURL,
+			CallTime:     startTime,
+			Metadata:     callExportMetadata(payload.Call.CallID, exported),
+		})
 	}
 
 	return nil
@@ -988,92 +3344,161 @@ func (p *PipedriveService) ProcessRetellCall(payload RetellWebhookPayload) error
 
 // ProcessCalAppointment processes a Cal.com appointment webhook
 func (p *PipedriveService) ProcessCalAppointment(payload CalWebhookPayload) error {
-	log.Printf("🔧 [DEBUG] ProcessCalAppointment called")
-	log.Printf("🔧 [DEBUG] HasPipedriveConfig: %v", p.config.HasPipedriveConfig())
-	log.Printf("🔧 [DEBUG] PIPEDRIVE_API_KEY: %s", p.config.PipedriveAPIKey)
+	p.debugf(SubsystemCal, "ProcessCalAppointment called")
+	p.debugf(SubsystemCal, "HasPipedriveConfig: %v", p.config.HasPipedriveConfig())
 
 	if p.config.HasPipedriveConfig() {
-		log.Printf("🚀 [REAL PIPEDRIVE] Processing Cal.com appointment webhook")
+		p.logf("🚀 [REAL PIPEDRIVE] Processing Cal.com appointment webhook")
+
+		if payload.TriggerEvent == "BOOKING_CANCELLED" {
+			p.reminders.Cancel(bookingKey(payload.Payload.ID, payload.Payload.UID))
+		}
+		if !p.config.CalActivityCreation && !p.config.RemindersEnabled {
+			p.logf("ℹ️ Cal.com activity creation is disabled (AUTOMATION_CAL_ACTIVITY=false), skipping booking %d", payload.Payload.ID)
+			return nil
+		}
 
 		// Parse start time
-		startTime, err := time.Parse(time.RFC3339, payload.Payload.StartTime)
+		start, err := ParseTimestamp(payload.Payload.StartTime, p.config.naiveLocation())
 		if err != nil {
-			log.Printf("❌ [DEBUG] Error parsing startTime: %v", err)
+			p.logf("❌ Error parsing startTime: %v", err)
 			return fmt.Errorf("invalid startTime format: %v", err)
 		}
+		startTime := start.UTC
 
 		// Get the first attendee (main contact)
 		attendee := payload.Payload.Attendees[0]
-		log.Printf("📧 [DEBUG] Processing attendee: %s (%s)", attendee.Name, attendee.Email)
+		p.debugf(SubsystemCal, "Processing attendee: %s (%s)", attendee.Name, attendee.Email)
 
-		// Find or create contact by email
+		// Find or create contact by email; a rejected email still gets the meeting logged, without a person
+		var personID int
+		var rejectedEmail string
 		contact, err := p.FindOrCreateContactByEmail(attendee.Email, attendee.Name)
-		if err != nil {
-			log.Printf("❌ [DEBUG] Error finding/creating contact: %v", err)
+		var emailErr *EmailValidationError
+		switch {
+		case errors.As(err, &emailErr):
+			p.logf("⚠️ Not creating a person for Cal.com booking %d: %v", payload.Payload.ID, err)
+			rejectedEmail = emailErr.Reason
+		case err != nil:
+			p.logf("❌ Error finding/creating contact: %v", err)
 			return fmt.Errorf("failed to find/create contact: %v", err)
+		default:
+			p.debugf(SubsystemCal, "Contact found/created: ID=%s, Name=%s", contact.ID, contact.Name)
+
+			// Convert contactID to int
+			personID, err = strconv.Atoi(contact.ID)
+			if err != nil {
+				p.logf("❌ Error converting contact ID: %v", err)
+				return fmt.Errorf("invalid contact ID: %v", err)
+			}
 		}
 
-		log.Printf("✅ [DEBUG] Contact found/created: ID=%s, Name=%s", contact.ID, contact.Name)
-
-		// Convert contactID to int
-		personID, err := strconv.Atoi(contact.ID)
-		if err != nil {
-			log.Printf("❌ [DEBUG] Error converting contact ID: %v", err)
-			return fmt.Errorf("invalid contact ID: %v", err)
+		if payload.TriggerEvent == "BOOKING_CREATED" && personID != 0 {
+			p.recordMeetingBooked(personID, time.Now())
+		}
+		// The reminder is scheduled on the way out, with the meeting activity once it exists so
+		// the reminder can re-check it
+		var meetingActivityID int
+		if payload.TriggerEvent != "BOOKING_CANCELLED" && personID != 0 {
+			defer func() {
+				p.scheduleReminder(payload.Payload.ID, payload.Payload.UID, personID, payload.Payload.Title, startTime, meetingActivityID)
+			}()
+		}
+		if !p.config.CalActivityCreation {
+			p.logf("ℹ️ Cal.com activity creation is disabled (AUTOMATION_CAL_ACTIVITY=false), skipping the activity for booking %d", payload.Payload.ID)
+			return nil
 		}
 
 		// Create appointment activity in Pipedrive
+		end, _ := ParseTimestamp(payload.Payload.EndTime, p.config.naiveLocation())
+		endTime := end.UTC
+		note := fmt.Sprintf("Appointment: %s\nWhen: %s\nAttendee: %s (%s)\nMeeting URL: %s", payload.Payload.Title, p.meetingTimeText(p.bookingTenant(personID), startTime), attendee.Name, attendee.Email, payload.Payload.Location)
+		if rejectedEmail != "" {
+			note += fmt.Sprintf("\nAttendee email rejected (%s); no Pipedrive person was created", rejectedEmail)
+		}
+		var invite *MeetingInvite
+		if payload.TriggerEvent != "BOOKING_CANCELLED" && p.config.CalICSMode != ICSModeOff {
+			meeting := calMeetingInvite(payload, note, startTime, endTime)
+			invite = &meeting
+			note += p.meetingInviteNote(meeting)
+		}
+		subject := fmt.Sprintf("Cal.com: %s", payload.Payload.Title)
 		activityData := map[string]interface{}{
-			"subject":   fmt.Sprintf("Cal.com: %s", payload.Payload.Title),
-			"type":      "meeting",
-			"person_id": personID,
-			"note":      fmt.Sprintf("Appointment: %s\nAttendee: %s (%s)\nMeeting URL: %s", payload.Payload.Title, attendee.Name, attendee.Email, payload.Payload.Location),
-			"done":      0, // Not completed yet
-			"due_date":  startTime.Format("2006-01-02"),
-			"due_time":  startTime.Format("15:04:05"),
+			"subject":  subject,
+			"type":     "meeting",
+			"note":     note,
+			"done":     0, // Not completed yet
+			"due_date": pipedriveDueDate(startTime),
+			"due_time": pipedriveDueTime(startTime),
+		}
+		if personID != 0 {
+			activityData["person_id"] = personID
 		}
+		// Guests beyond the booker are invited as attendees; the booker is the linked person
+		if len(payload.Payload.Attendees) > 1 {
+			attendees := make([]map[string]interface{}, 0, len(payload.Payload.Attendees)-1)
+			for _, guest := range payload.Payload.Attendees[1:] {
+				attendees = append(attendees, map[string]interface{}{"email_address": guest.Email, "name": guest.Name})
+			}
+			activityData["attendees"] = attendees
+		}
+		p.linkActivityToDeal(activityData, personID)
 
-		log.Printf("🔧 [DEBUG] Creating appointment activity for personID: %d", personID)
-		log.Printf("🔧 [DEBUG] Activity data: %+v", activityData)
+		p.debugf(SubsystemCal, "Creating appointment activity for personID: %d", personID)
+		p.debugf(SubsystemCal, "Activity data: %+v", activityData)
 
-		resp, err := p.makePipedriveRequest("POST", "/activities", activityData)
+		resp, err := p.createActivity(activityData)
 		if err != nil {
-			log.Printf("❌ [DEBUG] Error creating appointment activity: %v", err)
+			p.logf("❌ Error creating appointment activity: %v", err)
 			return fmt.Errorf("failed to create appointment activity: %v", err)
 		}
 		defer resp.Body.Close()
 
-		log.Printf("🔧 [DEBUG] Appointment activity creation response status: %d", resp.StatusCode)
+		p.debugf(SubsystemCal, "Appointment activity creation response status: %d", resp.StatusCode)
 
 		var activityResult PipedriveActivityResponse
 		if err := json.NewDecoder(resp.Body).Decode(&activityResult); err != nil {
-			log.Printf("❌ [DEBUG] Error decoding appointment activity response: %v", err)
+			p.logf("❌ Error decoding appointment activity response: %v", err)
 			return fmt.Errorf("failed to decode activity response: %v", err)
 		}
 
-		log.Printf("🔧 [DEBUG] Appointment activity result: %+v", activityResult)
+		p.debugf(SubsystemCal, "Appointment activity result: %+v", activityResult)
 
 		if !activityResult.Success {
-			log.Printf("❌ [DEBUG] Appointment activity creation failed in Pipedrive")
+			p.logf("❌ Appointment activity creation failed in Pipedrive")
 			return fmt.Errorf("failed to create appointment activity in Pipedrive")
 		}
 
-		log.Printf("✅ Created appointment activity in Pipedrive: ID=%d", activityResult.Data.ID)
+		p.logf("✅ Created appointment activity in Pipedrive: ID=%d", activityResult.Data.ID)
+		meetingActivityID = activityResult.Data.ID
+		if payload.TriggerEvent != "BOOKING_CANCELLED" {
+			p.recordMeeting(payload, activityResult.Data.ID, personID, note, startTime, endTime)
+		}
+		if invite != nil {
+			p.attachMeetingInvite(*invite, activityResult.Data.ID, personID)
+		}
+
+		// Mirror the meeting as a hold on the rep's calendar
+		p.mirrorMeetingHold(payload.TriggerEvent, bookingKey(payload.Payload.ID, payload.Payload.UID), activityResult.Data.UserID, subject, note, startTime, endTime)
+
+		if (payload.TriggerEvent == "BOOKING_CREATED" || payload.TriggerEvent == "BOOKING_RESCHEDULED") && rejectedEmail == "" {
+			p.sendMeetingConfirmation(payload, personID, startTime)
+		}
 
 	} else {
 		// Simulation mode
-		log.Printf("🔍 [SIMULATION MODE] Processing Cal.com appointment webhook")
-		log.Printf("   Event: %s", payload.TriggerEvent)
-		log.Printf("   Booking ID: %d", payload.Payload.ID)
-		log.Printf("   Title: %s", payload.Payload.Title)
+		p.logf("🔍 [SIMULATION MODE] Processing Cal.com appointment webhook")
+		p.logf("   Event: %s", payload.TriggerEvent)
+		p.logf("   Booking ID: %d", payload.Payload.ID)
+		p.logf("   Title: %s", payload.Payload.Title)
 		if len(payload.Payload.Attendees) > 0 {
 			attendee := payload.Payload.Attendees[0]
-			log.Printf("   Attendee: %s (%s)", attendee.Name, attendee.Email)
+			p.logf("   Attendee: %s (%s)", attendee.Name, attendee.Email)
 		}
-		log.Printf("   Start Time: %s", payload.Payload.StartTime)
-		log.Printf("   End Time: %s", payload.Payload.EndTime)
-		log.Printf("   Location: %s", payload.Payload.Location)
-		log.Printf("   ⚠️  This is a SIMULATION SERVER - not real Cal.com or Pipedrive")
+		p.logf("   Start Time: %s", payload.Payload.StartTime)
+		p.logf("   End Time: %s", payload.Payload.EndTime)
+		p.logf("   Location: %s", payload.Payload.Location)
+		p.logf("   ⚠️  This is a SIMULATION SERVER - not real Cal.com or Pipedrive")
 	}
 
 	return nil
@@ -1081,7 +3506,7 @@ func (p *PipedriveService) ProcessCalAppointment(payload CalWebhookPayload) erro
 
 // FindOrCreateContactByEmail finds or creates a contact by email address
 func (p *PipedriveService) FindOrCreateContactByEmail(email, name string) (*Contact, error) {
-	log.Printf("🔍 [REAL PIPEDRIVE API] Searching for contact by email: %s", email)
+	p.logf("🔍 [REAL PIPEDRIVE API] Searching for contact by email: %s", email)
 
 	// Search for existing contact by email
 	// URL-encode the email to handle special characters like @ and +
@@ -1101,7 +3526,7 @@ func (p *PipedriveService) FindOrCreateContactByEmail(email, name string) (*Cont
 	// If contact found, return it
 	if searchResult.Success && len(searchResult.Items) > 0 {
 		person := searchResult.Items[0]
-		log.Printf("✅ Found existing contact: ID=%d, Name=%s", person.ID, person.Name)
+		p.logf("✅ Found existing contact: ID=%d, Name=%s", person.ID, person.Name)
 		return &Contact{
 			ID:    strconv.Itoa(person.ID),
 			Name:  person.Name,
@@ -1110,8 +3535,11 @@ func (p *PipedriveService) FindOrCreateContactByEmail(email, name string) (*Cont
 		}, nil
 	}
 
-	// Contact not found, create new one
-	log.Printf("📝 Creating new contact in Pipedrive for email: %s", email)
+	// Contact not found, create new one unless the address is obviously fake or bounced
+	if err := p.emailValidator.Validate(email); err != nil {
+		return nil, err
+	}
+	p.logf("📝 Creating new contact in Pipedrive for email: %s", email)
 	personData := map[string]interface{}{
 		"name": name,
 		"email": []map[string]interface{}{
@@ -1135,7 +3563,7 @@ func (p *PipedriveService) FindOrCreateContactByEmail(email, name string) (*Cont
 	}
 
 	person := personResult.Data
-	log.Printf("✅ Created new contact in Pipedrive: ID=%d, Name=%s", person.ID, person.Name)
+	p.logf("✅ Created new contact in Pipedrive: ID=%d, Name=%s", person.ID, person.Name)
 
 	return &Contact{
 		ID:    strconv.Itoa(person.ID),
@@ -1164,16 +3592,24 @@ func HealthCheckHandler(c *gin.Context) {
 
 func RetellWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
 		var payload RetellWebhookPayload
 
 		// Bind JSON payload
 		if err := c.ShouldBindJSON(&payload); err != nil {
+			message := "Invalid JSON payload"
+			var durationErr *DurationError
+			if errors.As(err, &durationErr) {
+				message = durationErr.Error()
+			}
 			c.JSON(http.StatusBadRequest, WebhookResponse{
 				Success: false,
-				Message: "Invalid JSON payload",
+				Message: message,
 			})
 			return
 		}
+		payload.normalize()
+		payload.Metadata = trustedMetadata(c, payload.Metadata)
 
 		// Validate required fields for Retell format
 		if payload.CallID == "" || payload.ContactPhone == "" {
@@ -1184,6 +3620,14 @@ func RetellWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 			return
 		}
 
+		// Opt-outs run on the priority lane so they never wait behind queued campaign work
+		if pipedriveService.config.AsyncWebhookProcessing && isOptOutEvent(payload) {
+			respondAcceptedPriority(c, pipedriveService, "retell_optout", func() error {
+				return pipedriveService.ProcessRetellCall(payload)
+			})
+			return
+		}
+
 		// Process the call
 		if err := pipedriveService.ProcessRetellCall(payload); err != nil {
 			c.JSON(http.StatusInternalServerError, WebhookResponse{
@@ -1210,26 +3654,43 @@ func RetellWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 
 func CalWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		log.Printf("🔔 [CAL WEBHOOK] Received Cal.com webhook request")
+		pipedriveService := pipedriveService.forRequest(c)
+		pipedriveService.logf("🔔 [CAL WEBHOOK] Received Cal.com webhook request")
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Failed to read request body",
+			})
+			return
+		}
 
 		var payload CalWebhookPayload
 
 		// Bind JSON payload
-		if err := c.ShouldBindJSON(&payload); err != nil {
-			log.Printf("❌ [CAL WEBHOOK] Failed to bind JSON: %v", err)
+		if err := json.Unmarshal(body, &payload); err != nil {
+			pipedriveService.logf("❌ [CAL WEBHOOK] Failed to bind JSON: %v", err)
 			c.JSON(http.StatusBadRequest, WebhookResponse{
 				Success: false,
 				Message: "Invalid JSON payload",
 			})
 			return
 		}
+		payload.normalize(c.GetHeader("X-Cal-Webhook-Version"))
 
-		log.Printf("📦 [CAL WEBHOOK] Payload received: Event=%s, ID=%d, Title=%s",
-			payload.TriggerEvent, payload.Payload.ID, payload.Payload.Title)
+		pipedriveService.logf("📦 [CAL WEBHOOK] Payload received (%s): Event=%s, ID=%d, UID=%s, Title=%s",
+			payload.Version, payload.TriggerEvent, payload.Payload.ID, payload.Payload.UID, payload.Payload.Title)
+
+		// Meeting started/ended and no-show events report on a booking already processed
+		if isMeetingOutcomeEvent(payload.TriggerEvent) {
+			respondMeetingOutcome(c, pipedriveService, payload)
+			return
+		}
 
 		// Validate required fields
 		if len(payload.Payload.Attendees) == 0 {
-			log.Printf("❌ [CAL WEBHOOK] Validation failed: No attendees")
+			pipedriveService.logf("❌ [CAL WEBHOOK] Validation failed: No attendees")
 			c.JSON(http.StatusBadRequest, WebhookResponse{
 				Success: false,
 				Message: "Missing required field: attendees",
@@ -1238,7 +3699,7 @@ func CalWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 		}
 
 		if payload.Payload.StartTime == "" || payload.Payload.Location == "" {
-			log.Printf("❌ [CAL WEBHOOK] Validation failed: StartTime=%s, Location=%s",
+			pipedriveService.logf("❌ [CAL WEBHOOK] Validation failed: StartTime=%s, Location=%s",
 				payload.Payload.StartTime, payload.Payload.Location)
 			c.JSON(http.StatusBadRequest, WebhookResponse{
 				Success: false,
@@ -1247,11 +3708,18 @@ func CalWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 			return
 		}
 
-		log.Printf("✅ [CAL WEBHOOK] Validation passed, calling ProcessCalAppointment")
+		pipedriveService.logf("✅ [CAL WEBHOOK] Validation passed, calling ProcessCalAppointment")
+
+		if pipedriveService.config.AsyncWebhookProcessing {
+			respondAccepted(c, pipedriveService, "cal_appointment", func() error {
+				return pipedriveService.ProcessCalAppointment(payload)
+			})
+			return
+		}
 
 		// Process the appointment
 		if err := pipedriveService.ProcessCalAppointment(payload); err != nil {
-			log.Printf("❌ [CAL WEBHOOK] ProcessCalAppointment failed: %v", err)
+			pipedriveService.logf("❌ [CAL WEBHOOK] ProcessCalAppointment failed: %v", err)
 			c.JSON(http.StatusInternalServerError, WebhookResponse{
 				Success: false,
 				Message: "Failed to process appointment: " + err.Error(),
@@ -1259,7 +3727,7 @@ func CalWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 			return
 		}
 
-		log.Printf("✅ [CAL WEBHOOK] ProcessCalAppointment completed successfully")
+		pipedriveService.logf("✅ [CAL WEBHOOK] ProcessCalAppointment completed successfully")
 
 		// Return success response
 		c.JSON(http.StatusOK, WebhookResponse{
@@ -1268,6 +3736,8 @@ func CalWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 			Data: gin.H{
 				"trigger_event": payload.TriggerEvent,
 				"booking_id":    payload.Payload.ID,
+				"booking_uid":   payload.Payload.UID,
+				"version":       payload.Version,
 				"title":         payload.Payload.Title,
 				"start_time":    payload.Payload.StartTime,
 				"end_time":      payload.Payload.EndTime,
@@ -1276,21 +3746,65 @@ func CalWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 			},
 		})
 
-		log.Printf("🎉 [CAL WEBHOOK] Webhook response sent successfully")
+		pipedriveService.logf("🎉 [CAL WEBHOOK] Webhook response sent successfully")
 	}
 }
 
 func RetellCallAnalyzedHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
+		var payload RetellCallAnalyzedPayload
+
+		// Bind JSON payload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		// Validate required fields
+		if payload.Call.CallID == "" {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Missing required field: call.call_id",
+			})
+			return
+		}
+		payload.Call.Metadata = trustedMetadata(c, payload.Call.Metadata)
+
+		if pipedriveService.config.AsyncWebhookProcessing {
+			respondAccepted(c, pipedriveService, "retell_call_analyzed", func() error {
+				return pipedriveService.ProcessRetellCallAnalyzed(payload)
+			})
+			return
+		}
+
+		// Process the analysis
+		if err := pipedriveService.ProcessRetellCallAnalyzed(payload); err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: "Failed to process call analyzed: " + err.Error(),
+			})
+			return
+		}
+
 		c.JSON(http.StatusOK, WebhookResponse{
 			Success: true,
 			Message: "Retell call analyzed webhook processed successfully",
+			Data: gin.H{
+				"call_id":   payload.Call.CallID,
+				"agent_id":  payload.Call.AgentID,
+				"sentiment": payload.Call.CallAnalysis.UserSentiment,
+			},
 		})
 	}
 }
 
 func PipedriveLeadWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
 		var payload PipedriveLeadWebhookPayload
 
 		// Bind JSON payload
@@ -1311,6 +3825,13 @@ func PipedriveLeadWebhookHandler(pipedriveService *PipedriveService) gin.Handler
 			return
 		}
 
+		payload.Tenant = webhookTenant(c)
+
+		// Attribution passed on the webhook URL, e.g. by a form tool that creates the lead
+		if attribution := attributionFromRequest(c); len(attribution) > 0 {
+			pipedriveService.recordLeadAttribution(payload.Data.ID, payload.Data.PersonID, attribution)
+		}
+
 		// Process the lead
 		if err := pipedriveService.ProcessPipedriveLead(payload); err != nil {
 			c.JSON(http.StatusInternalServerError, WebhookResponse{
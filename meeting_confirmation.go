@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// MeetingConfirmationSender is a tenant's sender and branding for meeting confirmation emails
+type MeetingConfirmationSender struct {
+	From     string `json:"from"`     // e.g. "Acme Sales <sales@acme.com>"; defaults to SMTP_FROM
+	ReplyTo  string `json:"reply_to"` // Where attendee replies go, e.g. the rep team's inbox
+	Brand    string `json:"brand"`    // Company name signing the email
+	Subject  string `json:"subject"`  // {{name}}, {{title}}, {{time}}, {{location}}, {{brand}}
+	Template string `json:"template"` // Body, same placeholders as the subject
+}
+
+// defaultMeetingConfirmationSubject is used when neither the tenant nor MEETING_CONFIRMATION_SUBJECT set one
+const defaultMeetingConfirmationSubject = "Confirmed: {{title}} on {{time}}"
+
+// loadMeetingConfirmationSenders parses MEETING_CONFIRMATION_SENDERS (JSON: tenant -> sender), filling
+// unset fields from the default sender
+func loadMeetingConfirmationSenders(raw string, defaults MeetingConfirmationSender) map[string]MeetingConfirmationSender {
+	senders := make(map[string]MeetingConfirmationSender)
+	if raw == "" {
+		return senders
+	}
+	if err := json.Unmarshal([]byte(raw), &senders); err != nil {
+		log.Printf("⚠️ Invalid MEETING_CONFIRMATION_SENDERS, ignoring: %v", err)
+		return make(map[string]MeetingConfirmationSender)
+	}
+	for tenant, sender := range senders {
+		if sender.From == "" {
+			sender.From = defaults.From
+		}
+		if sender.ReplyTo == "" {
+			sender.ReplyTo = defaults.ReplyTo
+		}
+		if sender.Brand == "" {
+			sender.Brand = defaults.Brand
+		}
+		if sender.Subject == "" {
+			sender.Subject = defaults.Subject
+		}
+		if sender.Template == "" {
+			sender.Template = defaults.Template
+		}
+		senders[tenant] = sender
+	}
+	return senders
+}
+
+// MeetingConfirmationSenderFor returns the tenant's confirmation sender, or the default one
+func (c *Config) MeetingConfirmationSenderFor(tenant string) MeetingConfirmationSender {
+	if sender, ok := c.MeetingConfirmationSenders[tenant]; ok {
+		return sender
+	}
+	return c.MeetingConfirmationDefault
+}
+
+// bookingTenant returns the tenant of a Cal.com booking: the tenant whose webhook delivered it, else
+// the tenant of the person's most recent call
+func (p *PipedriveService) bookingTenant(personID int) string {
+	if p.tenant != "" {
+		return p.tenant
+	}
+	return p.personTenant(personID)
+}
+
+// personTenant returns the tenant of the person's most recent call, or the default tenant
+func (p *PipedriveService) personTenant(personID int) string {
+	tenant, latest := DefaultTenant, time.Time{}
+	for _, mapping := range p.listCallMappings() {
+		if mapping.PersonID == personID && mapping.Tenant != "" && mapping.Timestamp.After(latest) {
+			tenant, latest = mapping.Tenant, mapping.Timestamp
+		}
+	}
+	return tenant
+}
+
+// renderMeetingConfirmation builds the subject and body of a confirmation email
+func renderMeetingConfirmation(sender MeetingConfirmationSender, vars map[string]string) (string, string) {
+	subject := sender.Subject
+	if subject == "" {
+		subject = defaultMeetingConfirmationSubject
+	}
+	if sender.Template != "" {
+		return renderTemplate(subject, vars), renderTemplate(sender.Template, vars)
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nYour meeting \"%s\" is confirmed for %s.\n", vars["name"], vars["title"], vars["time"])
+	if vars["location"] != "" {
+		body += "\nJoin here: " + vars["location"] + "\n"
+	}
+	body += "\nIf the time no longer works, use the link in your booking email to reschedule.\n"
+	if vars["brand"] != "" {
+		body += "\nSee you soon,\n" + vars["brand"] + "\n"
+	}
+	return renderTemplate(subject, vars), body
+}
+
+// sendMeetingConfirmation emails the booker a confirmation of their Cal.com booking from the tenant's
+// sender, and logs the email as a note on the person
+func (p *PipedriveService) sendMeetingConfirmation(payload CalWebhookPayload, personID int, startTime time.Time) {
+	if !p.config.MeetingConfirmationEmail || len(payload.Payload.Attendees) == 0 {
+		return
+	}
+	if p.mailer == nil {
+		p.logf("⚠️ MEETING_CONFIRMATION_EMAIL requires SMTP_HOST and SMTP_FROM, skipping confirmation for booking %d", payload.Payload.ID)
+		return
+	}
+	attendee := payload.Payload.Attendees[0]
+	if attendee.Email == "" {
+		return
+	}
+
+	tenant := p.bookingTenant(personID)
+	sender := p.config.MeetingConfirmationSenderFor(tenant)
+	subject, body := renderMeetingConfirmation(sender, map[string]string{
+		"name":     attendee.Name,
+		"title":    payload.Payload.Title,
+		"time":     p.meetingTimeText(tenant, startTime),
+		"location": payload.Payload.Location,
+		"brand":    sender.Brand,
+	})
+	if err := p.mailer.SendAs(sender.From, sender.ReplyTo, []string{attendee.Email}, subject, body); err != nil {
+		p.logf("❌ Failed to send meeting confirmation for booking %d to %s: %v", payload.Payload.ID, attendee.Email, err)
+		return
+	}
+	p.logf("📧 Sent meeting confirmation for booking %d to %s", payload.Payload.ID, attendee.Email)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "meeting_confirmation_sent", PersonID: personID, Detail: fmt.Sprintf("booking %d to %s", payload.Payload.ID, attendee.Email)})
+
+	from := sender.From
+	if from == "" {
+		from = p.config.SMTPFrom
+	}
+	noteData := map[string]interface{}{
+		"content": fmt.Sprintf("Meeting confirmation emailed\nTo: %s\nFrom: %s\nSubject: %s\n\n%s",
+			attendee.Email, from, subject, strings.TrimSpace(body)),
+		"person_id": personID,
+	}
+	p.linkActivityToDeal(noteData, personID)
+	resp, err := p.makePipedriveRequest("POST", "/notes", noteData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to log meeting confirmation for person %d: %v", personID, err)
+		return
+	}
+	resp.Body.Close()
+}
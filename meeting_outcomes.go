@@ -0,0 +1,678 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cal.com triggers reporting what happened at a booked meeting
+const (
+	CalMeetingStarted = "MEETING_STARTED"
+	CalMeetingEnded   = "MEETING_ENDED"
+	CalNoShowUpdated  = "BOOKING_NO_SHOW_UPDATED"        // Attendees marked (or unmarked) as no-shows
+	CalGuestsNoShow   = "AFTER_GUESTS_CAL_VIDEO_NO_SHOW" // No guest joined the Cal Video call
+	CalHostsNoShow    = "AFTER_HOSTS_CAL_VIDEO_NO_SHOW"  // No host joined the Cal Video call
+)
+
+// Meeting attendance outcomes, also the keys of POST_MEETING_SEQUENCES
+const (
+	AttendanceAttended   = "attended"
+	AttendanceNoShow     = "no_show"      // The booker did not join
+	AttendanceHostNoShow = "host_no_show" // Nobody from our side joined
+)
+
+// meetingRetention is how long a meeting stays tracked after it ended or was last looked up
+const meetingRetention = 30 * 24 * time.Hour
+
+// isMeetingOutcomeEvent reports whether a Cal.com trigger reports on a meeting rather than a booking
+func isMeetingOutcomeEvent(trigger string) bool {
+	switch trigger {
+	case CalMeetingStarted, CalMeetingEnded, CalNoShowUpdated, CalGuestsNoShow, CalHostsNoShow:
+		return true
+	}
+	return false
+}
+
+// defaultFollowUpTemplates are sent by steps without a template
+var defaultFollowUpTemplates = map[string]string{
+	AttendanceAttended:   "Hi {{name}}, thanks for joining {{title}}. Reply here if you have any questions.",
+	AttendanceNoShow:     "Hi {{name}}, sorry we missed you at {{title}}. Pick a new time here: {{meeting_url}}",
+	AttendanceHostNoShow: "Hi {{name}}, we're sorry we couldn't make {{title}}. Pick a new time here: {{meeting_url}}",
+}
+
+// MeetingFollowUpStep is one step of a post-meeting follow-up sequence
+type MeetingFollowUpStep struct {
+	AfterMinutes int    `json:"after_minutes"` // Delay after the outcome is recorded
+	Channel      string `json:"channel"`       // "sms", "whatsapp" or "call"
+	Template     string `json:"template"`      // Message; placeholders: {{name}}, {{title}}, {{time}}, {{meeting_url}}
+	AgentID      string `json:"agent_id"`      // Agent for call steps (default: RETELL_ASSISTANT_ID)
+}
+
+// loadPostMeetingSequences parses POST_MEETING_SEQUENCES (JSON: attendance outcome -> steps)
+func loadPostMeetingSequences(raw string) map[string][]MeetingFollowUpStep {
+	sequences := make(map[string][]MeetingFollowUpStep)
+	if raw == "" {
+		return sequences
+	}
+	if err := json.Unmarshal([]byte(raw), &sequences); err != nil {
+		log.Printf("⚠️ Invalid POST_MEETING_SEQUENCES, ignoring: %v", err)
+		return make(map[string][]MeetingFollowUpStep)
+	}
+	for attendance, steps := range sequences {
+		switch attendance {
+		case AttendanceAttended, AttendanceNoShow, AttendanceHostNoShow:
+		default:
+			log.Printf("⚠️ POST_MEETING_SEQUENCES: unknown outcome %q, ignoring", attendance)
+			delete(sequences, attendance)
+			continue
+		}
+		valid := steps[:0]
+		for _, step := range steps {
+			step.Channel = strings.ToLower(step.Channel)
+			if step.Channel != "sms" && step.Channel != "whatsapp" && step.Channel != "call" {
+				log.Printf("⚠️ POST_MEETING_SEQUENCES: unknown channel %q for %s, ignoring the step", step.Channel, attendance)
+				continue
+			}
+			valid = append(valid, step)
+		}
+		sequences[attendance] = valid
+	}
+	return sequences
+}
+
+// MeetingFollowUp is a scheduled or completed step of a meeting's follow-up sequence; its statuses
+// are those of reminders
+type MeetingFollowUp struct {
+	Step    int       `json:"step"`
+	Channel string    `json:"channel"`
+	SendAt  time.Time `json:"send_at"`
+	Status  string    `json:"status"`
+	Detail  string    `json:"detail,omitempty"`
+
+	job string // Scheduled job ID while the step is pending
+}
+
+// Meeting is a booked Cal.com meeting, its Pipedrive activity and what happened at it
+type Meeting struct {
+	BookingID  int               `json:"booking_id"`
+	UID        string            `json:"uid,omitempty"`
+	ActivityID int               `json:"activity_id"`
+	PersonID   int               `json:"person_id"`
+	Tenant     string            `json:"tenant,omitempty"` // Tenant of the booking, for scoped admin reads
+	Title      string            `json:"title"`
+	StartTime  time.Time         `json:"start_time"`
+	EndTime    time.Time         `json:"end_time"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	EndedAt    *time.Time        `json:"ended_at,omitempty"`
+	Duration   Duration          `json:"duration,omitempty"` // Actual length, from start to end events
+	Attendance string            `json:"attendance,omitempty"`
+	FollowUps  []MeetingFollowUp `json:"follow_ups,omitempty"` // Sequence for the current attendance
+
+	note    string    // Activity note as created; the outcome is appended to it
+	tracked time.Time // When the meeting was recorded or looked up
+}
+
+// key is the booking the meeting is for
+func (m *Meeting) key() string {
+	return bookingKey(m.BookingID, m.UID)
+}
+
+// MeetingTracker keeps booked meetings by Cal.com booking until their outcome is known and for
+// meetingRetention after; follow-up steps are jobs in SCHEDULE_FILE
+type MeetingTracker struct {
+	mu       sync.Mutex
+	meetings map[string]*Meeting // Keyed by bookingKey
+	jobs     *JobScheduler
+}
+
+// NewMeetingTracker creates a new meeting tracker
+func NewMeetingTracker(jobs *JobScheduler) *MeetingTracker {
+	return &MeetingTracker{meetings: make(map[string]*Meeting), jobs: jobs}
+}
+
+// Record tracks a meeting whose activity was just created, replacing an earlier one for the booking
+func (t *MeetingTracker) Record(meeting Meeting) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if existing := t.find(meeting.BookingID, meeting.UID); existing != nil {
+		t.stopFollowUps(existing)
+		delete(t.meetings, existing.key())
+	}
+	t.pruneLocked(time.Now())
+	meeting.tracked = time.Now()
+	t.meetings[meeting.key()] = &meeting
+}
+
+// track tracks a meeting looked up in Pipedrive, or returns the one another event tracked meanwhile
+func (t *MeetingTracker) track(meeting *Meeting) *Meeting {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if existing := t.find(meeting.BookingID, meeting.UID); existing != nil {
+		return existing
+	}
+	t.pruneLocked(time.Now())
+	meeting.tracked = time.Now()
+	t.meetings[meeting.key()] = meeting
+	return meeting
+}
+
+// pruneLocked drops meetings that ended, and were last tracked, over meetingRetention ago and
+// have no pending follow-up; callers hold mu
+func (t *MeetingTracker) pruneLocked(now time.Time) {
+	for key, meeting := range t.meetings {
+		if now.Sub(meeting.EndTime) < meetingRetention || now.Sub(meeting.tracked) < meetingRetention {
+			continue
+		}
+		pending := false
+		for _, step := range meeting.FollowUps {
+			pending = pending || step.Status == ReminderScheduled
+		}
+		if !pending {
+			delete(t.meetings, key)
+		}
+	}
+}
+
+// find returns the tracked meeting for a booking ID or UID; callers hold mu
+func (t *MeetingTracker) find(bookingID int, uid string) *Meeting {
+	if bookingID == 0 && uid == "" {
+		return nil
+	}
+	if meeting, ok := t.meetings[bookingKey(bookingID, uid)]; ok {
+		return meeting
+	}
+	for _, meeting := range t.meetings {
+		if (uid != "" && meeting.UID == uid) || (bookingID != 0 && meeting.BookingID == bookingID) {
+			return meeting
+		}
+	}
+	return nil
+}
+
+// List returns the tracked meetings of a tenant, or of all tenants when tenant is "", sorted by
+// start time
+func (t *MeetingTracker) List(tenant string) []Meeting {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]Meeting, 0, len(t.meetings))
+	for _, meeting := range t.meetings {
+		if tenant != "" && meeting.Tenant != tenant {
+			continue
+		}
+		copied := *meeting
+		copied.FollowUps = append([]MeetingFollowUp(nil), meeting.FollowUps...)
+		list = append(list, copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartTime.Before(list[j].StartTime) })
+	return list
+}
+
+// FollowUpJob is a scheduled follow-up step, carrying what sending it needs when the meeting is no
+// longer tracked after a restart
+type FollowUpJob struct {
+	BookingID  int       `json:"booking_id"`
+	UID        string    `json:"uid,omitempty"`
+	PersonID   int       `json:"person_id"`
+	Title      string    `json:"title"`
+	StartTime  time.Time `json:"start_time"`
+	Attendance string    `json:"attendance"`
+	Index      int       `json:"index"`
+}
+
+// followUpJobID is the scheduled job ID of a follow-up step of a booking (see bookingKey)
+func followUpJobID(key string, attendance string, index int) string {
+	return fmt.Sprintf("meeting-followup:%s:%s:%d", key, attendance, index)
+}
+
+// stopFollowUps cancels a meeting's pending follow-up steps
+func (t *MeetingTracker) stopFollowUps(meeting *Meeting) {
+	for i := range meeting.FollowUps {
+		if step := &meeting.FollowUps[i]; step.Status == ReminderScheduled {
+			t.jobs.Cancel(step.job)
+			step.Status = ReminderCancelled
+		}
+	}
+}
+
+// recordMeeting tracks a meeting activity created from a Cal.com booking
+func (p *PipedriveService) recordMeeting(payload CalWebhookPayload, activityID, personID int, note string, startTime, endTime time.Time) {
+	p.meetings.Record(Meeting{
+		BookingID:  payload.Payload.ID,
+		UID:        payload.Payload.UID,
+		ActivityID: activityID,
+		PersonID:   personID,
+		Tenant:     p.bookingTenant(personID),
+		Title:      payload.Payload.Title,
+		StartTime:  startTime.UTC(),
+		EndTime:    endTime.UTC(),
+		note:       note,
+	})
+}
+
+// lookupMeeting finds the meeting activity of a booking that is no longer tracked (e.g. after a
+// restart) from the booker's open "Cal.com: <title>" meeting activity
+func (p *PipedriveService) lookupMeeting(payload CalWebhookPayload) (*Meeting, error) {
+	if len(payload.Payload.Attendees) == 0 || payload.Payload.Title == "" {
+		return nil, fmt.Errorf("booking %d is not tracked and has no attendee and title to look it up by", payload.Payload.ID)
+	}
+	persons, err := p.SearchPersons("email", payload.Payload.Attendees[0].Email)
+	if err != nil {
+		return nil, err
+	}
+	subject := "Cal.com: " + payload.Payload.Title
+	for _, person := range persons {
+		activities, err := p.listActivities("person_id", person.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, activity := range activities {
+			if activity.Subject != subject || activity.Done {
+				continue
+			}
+			meeting := &Meeting{
+				BookingID:  payload.Payload.ID,
+				UID:        payload.Payload.UID,
+				ActivityID: activity.ID,
+				PersonID:   person.ID,
+				Tenant:     p.bookingTenant(person.ID),
+				Title:      payload.Payload.Title,
+				note:       activity.Note,
+			}
+			if start, err := ParseTimestamp(payload.Payload.StartTime, p.config.naiveLocation()); err == nil {
+				meeting.StartTime = start.UTC
+			}
+			if end, err := ParseTimestamp(payload.Payload.EndTime, p.config.naiveLocation()); err == nil {
+				meeting.EndTime = end.UTC
+			}
+			return meeting, nil
+		}
+	}
+	return nil, fmt.Errorf("no open meeting activity %q found for booking %d", subject, payload.Payload.ID)
+}
+
+// meetingAttendance returns the attendance a Cal.com event reports, or "" when it reports none
+func meetingAttendance(payload CalWebhookPayload, current string) string {
+	switch payload.TriggerEvent {
+	case CalMeetingEnded:
+		if current == "" {
+			return AttendanceAttended
+		}
+		return current
+	case CalGuestsNoShow:
+		return AttendanceNoShow
+	case CalHostsNoShow:
+		return AttendanceHostNoShow
+	case CalNoShowUpdated:
+		for _, attendee := range payload.Payload.Attendees {
+			if attendee.NoShow {
+				return AttendanceNoShow
+			}
+		}
+		// Unmarking a no-show means the booker did attend
+		if current == AttendanceNoShow {
+			return AttendanceAttended
+		}
+		return current
+	}
+	return current
+}
+
+// outcomeNote appends the meeting's outcome to its activity note
+func (m *Meeting) outcomeNote() string {
+	lines := []string{"Meeting outcome: " + strings.ReplaceAll(m.Attendance, "_", "-")}
+	if m.Duration > 0 {
+		lines = append(lines, "Actual duration: "+m.Duration.String())
+	}
+	if m.StartedAt != nil {
+		lines = append(lines, "Started: "+m.StartedAt.UTC().Format(time.RFC3339))
+	}
+	if m.EndedAt != nil {
+		lines = append(lines, "Ended: "+m.EndedAt.UTC().Format(time.RFC3339))
+	}
+	return m.note + "\n\n" + strings.Join(lines, "\n")
+}
+
+// ProcessCalMeetingOutcome marks a booked meeting's Pipedrive activity done with its actual duration
+// and attendance when Cal.com reports the meeting ended or a no-show, and starts the follow-up
+// sequence for the outcome
+func (p *PipedriveService) ProcessCalMeetingOutcome(payload CalWebhookPayload) error {
+	if !p.config.HasPipedriveConfig() {
+		p.logf("🔍 [SIMULATION MODE] Cal.com %s for booking %d", payload.TriggerEvent, payload.Payload.ID)
+		return nil
+	}
+
+	// The tracker lock is never held across Pipedrive requests
+	p.meetings.mu.Lock()
+	meeting := p.meetings.find(payload.Payload.ID, payload.Payload.UID)
+	p.meetings.mu.Unlock()
+	if meeting == nil {
+		found, err := p.lookupMeeting(payload)
+		if err != nil {
+			p.logf("⚠️ Cal.com %s: %v", payload.TriggerEvent, err)
+			return nil
+		}
+		meeting = p.meetings.track(found)
+	}
+
+	p.meetings.mu.Lock()
+	key := meeting.key()
+	now := time.Now().UTC()
+	if payload.TriggerEvent == CalMeetingStarted {
+		meeting.StartedAt = &now
+		p.meetings.mu.Unlock()
+		p.logf("📅 Meeting for booking %s started", key)
+		return nil
+	}
+	if payload.TriggerEvent == CalMeetingEnded {
+		meeting.EndedAt = &now
+		// Without a start event the meeting is taken to have started on time
+		start := meeting.StartTime
+		if meeting.StartedAt != nil {
+			start = *meeting.StartedAt
+		}
+		if length := now.Sub(start); !start.IsZero() && length > 0 {
+			meeting.Duration = Duration(length)
+		} else if scheduled := meeting.EndTime.Sub(meeting.StartTime); scheduled > 0 {
+			meeting.Duration = Duration(scheduled)
+		}
+	}
+
+	attendance := meetingAttendance(payload, meeting.Attendance)
+	if attendance == "" {
+		p.meetings.mu.Unlock()
+		return nil
+	}
+	previous := meeting.Attendance
+	meeting.Attendance = attendance
+
+	update := map[string]interface{}{"done": 1, "note": meeting.outcomeNote()}
+	if meeting.Duration > 0 && attendance == AttendanceAttended {
+		update["duration"] = meeting.Duration.ActivityDuration()
+	}
+	activityID, personID := meeting.ActivityID, meeting.PersonID
+	p.meetings.mu.Unlock()
+
+	if err := p.updateActivity(activityID, update); err != nil {
+		p.meetings.mu.Lock()
+		if meeting.Attendance == attendance {
+			meeting.Attendance = previous
+		}
+		p.meetings.mu.Unlock()
+		return fmt.Errorf("failed to record outcome of booking %s on activity %d: %v", key, activityID, err)
+	}
+	p.logf("✅ Recorded %s for booking %s on activity %d", attendance, key, activityID)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "meeting_outcome_recorded", PersonID: personID,
+		Detail: fmt.Sprintf("booking %s: %s (%s)", key, attendance, payload.TriggerEvent)})
+
+	if attendance != previous {
+		p.meetings.mu.Lock()
+		// A later event may have changed the attendance while the activity was updated
+		if meeting.Attendance == attendance {
+			p.meetings.stopFollowUps(meeting)
+			p.startFollowUps(meeting)
+		}
+		p.meetings.mu.Unlock()
+	}
+	return nil
+}
+
+// startFollowUps schedules the POST_MEETING_SEQUENCES steps for a meeting's attendance; the caller
+// holds the tracker lock
+func (p *PipedriveService) startFollowUps(meeting *Meeting) {
+	steps := p.config.PostMeetingSequences[meeting.Attendance]
+	meeting.FollowUps = make([]MeetingFollowUp, 0, len(steps))
+	for i, step := range steps {
+		delay := time.Duration(step.AfterMinutes) * time.Minute
+		meeting.FollowUps = append(meeting.FollowUps, MeetingFollowUp{
+			Step:    i,
+			Channel: step.Channel,
+			SendAt:  time.Now().Add(delay).UTC(),
+			Status:  ReminderScheduled,
+		})
+		job := FollowUpJob{
+			BookingID:  meeting.BookingID,
+			UID:        meeting.UID,
+			PersonID:   meeting.PersonID,
+			Title:      meeting.Title,
+			StartTime:  meeting.StartTime,
+			Attendance: meeting.Attendance,
+			Index:      i,
+		}
+		meeting.FollowUps[i].job = followUpJobID(meeting.key(), job.Attendance, i)
+		if err := p.schedules.Schedule(meeting.FollowUps[i].job, JobMeetingFollowUp, meeting.FollowUps[i].SendAt, job); err != nil {
+			meeting.FollowUps[i].Status = ReminderFailed
+			meeting.FollowUps[i].Detail = err.Error()
+		}
+	}
+	if len(steps) > 0 {
+		p.logf("📨 Scheduled %d %s follow-ups for booking %s", len(steps), meeting.Attendance, meeting.key())
+	}
+}
+
+// finishFollowUp records the outcome of a follow-up step if the sequence is still current
+func (p *PipedriveService) finishFollowUp(key string, attendance string, index int, status, detail string) {
+	p.meetings.mu.Lock()
+	defer p.meetings.mu.Unlock()
+	meeting, ok := p.meetings.meetings[key]
+	if ok && meeting.Attendance == attendance && index < len(meeting.FollowUps) {
+		meeting.FollowUps[index].Status = status
+		meeting.FollowUps[index].Detail = detail
+	}
+}
+
+// sendFollowUp sends one step of a meeting's follow-up sequence by SMS, WhatsApp or AI call; a step
+// whose meeting is no longer tracked (after a restart) is sent from the job
+func (p *PipedriveService) sendFollowUp(raw json.RawMessage) {
+	var job FollowUpJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		p.logf("❌ Failed to read meeting follow-up: %v", err)
+		return
+	}
+	key, attendance, index := bookingKey(job.BookingID, job.UID), job.Attendance, job.Index
+	copied := Meeting{BookingID: job.BookingID, UID: job.UID, PersonID: job.PersonID, Title: job.Title, StartTime: job.StartTime, Attendance: attendance}
+	p.meetings.mu.Lock()
+	meeting, ok := p.meetings.meetings[key]
+	pending := !ok || (meeting.Attendance == attendance && index < len(meeting.FollowUps) && meeting.FollowUps[index].Status == ReminderScheduled)
+	if ok && pending {
+		copied = *meeting
+	}
+	p.meetings.mu.Unlock()
+	if !pending {
+		return
+	}
+	steps := p.config.PostMeetingSequences[attendance]
+	if index >= len(steps) {
+		return
+	}
+	step := steps[index]
+	release, ok := p.claim(followUpJobID(key, attendance, index))
+	if !ok {
+		p.finishFollowUp(key, attendance, index, ReminderSkipped, "sent by another replica")
+		return
+	}
+
+	person, err := p.GetPersonByID(copied.PersonID)
+	if err != nil {
+		p.logf("❌ Failed to get person %d for meeting follow-up: %v", copied.PersonID, err)
+		p.finishFollowUp(key, attendance, index, ReminderFailed, err.Error())
+		return
+	}
+	phoneNumber := p.extractPhoneFromPerson(person)
+	if phoneNumber == "" {
+		p.logf("⚠️ No phone number for person %d, skipping meeting follow-up", copied.PersonID)
+		p.finishFollowUp(key, attendance, index, ReminderSkipped, "no phone number")
+		return
+	}
+	blocked, notUntil := p.contactGate(person, phoneNumber, step.Channel, "meeting follow-up", time.Now())
+	if blocked != "" {
+		p.finishFollowUp(key, attendance, index, ReminderSkipped, blocked)
+		return
+	}
+	if !notUntil.IsZero() {
+		release()
+		p.rescheduleFollowUp(job, notUntil)
+		return
+	}
+
+	when := p.meetingTimeText(p.personTenant(copied.PersonID), copied.StartTime)
+	template := step.Template
+	if template == "" {
+		template = defaultFollowUpTemplates[attendance]
+	}
+	message := renderTemplate(template, map[string]string{
+		"name":        person.Name,
+		"title":       copied.Title,
+		"time":        when,
+		"meeting_url": p.config.FollowUpMeetingURL,
+	})
+
+	var reference string
+	switch step.Channel {
+	case "call":
+		variables := map[string]interface{}{
+			"meeting_follow_up": "true",
+			"meeting_title":     copied.Title,
+			"meeting_time":      when,
+			"meeting_outcome":   attendance,
+		}
+		tenant := p.config.TenantFor(step.AgentID)
+		agentID, consentRequired := p.consentAgent(phoneNumber, step.AgentID, variables)
+		reference, err = p.CreateRetellCall(phoneNumber, person.Name, copied.Title, agentID, "", variables, &CallMetadata{
+			Tenant:     tenant,
+			PersonID:   copied.PersonID,
+			PersonName: person.Name,
+			LeadTitle:  copied.Title,
+			Region:     p.config.RegionPolicy().RegionOf(tenant),
+		})
+		if err != nil {
+			break
+		}
+		// The call's analysis is written to the person like any other AI call
+		p.storeCallMapping(reference, person.Name, phoneNumber, copied.Title, copied.PersonID)
+		p.updateCallMapping(reference, func(m *CallMapping) {
+			m.Tenant = tenant
+			m.Region = p.config.RegionPolicy().RegionOf(tenant)
+			if consentRequired {
+				m.Consent = &ConsentRecord{Status: ConsentUnknown, RecordedAt: time.Now()}
+			}
+		})
+		mapping, _ := p.getCallMapping(reference)
+		p.callEvents.Append(CallEvent{CallID: reference, Type: CallEventDialing, PersonID: copied.PersonID, Detail: "meeting follow-up for booking " + key, Mapping: &mapping})
+	case "whatsapp":
+		if p.whatsApp == nil {
+			err = fmt.Errorf("WhatsApp not configured")
+			break
+		}
+		// Logged as its own WhatsApp activity
+		err = p.SendWhatsAppFollowUp(copied.PersonID, phoneNumber, message)
+	default:
+		if p.sms == nil {
+			err = fmt.Errorf("SMS not configured (TWILIO_SMS_FROM)")
+			break
+		}
+		reference, err = p.sms.Send(phoneNumber, p.smsText(p.shortenLinks(message, copied.PersonID)))
+	}
+	if err != nil {
+		p.logf("❌ Failed to send %s follow-up %d for booking %s: %v", step.Channel, index+1, key, err)
+		p.finishFollowUp(key, attendance, index, ReminderFailed, err.Error())
+		return
+	}
+	p.logf("✅ Sent %s follow-up %d for booking %s to %s (%s)", step.Channel, index+1, key, phoneNumber, reference)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "meeting_follow_up_sent", PersonID: copied.PersonID,
+		Detail: fmt.Sprintf("%s follow-up %d after %s for booking %s (%s)", step.Channel, index+1, attendance, key, reference)})
+	p.finishFollowUp(key, attendance, index, ReminderSent, reference)
+	if step.Channel == "whatsapp" {
+		return
+	}
+
+	activityData := map[string]interface{}{
+		"subject":   fmt.Sprintf("Post-meeting follow-up sent (%s)", step.Channel),
+		"type":      "task",
+		"person_id": copied.PersonID,
+		"note":      fmt.Sprintf("Follow-up %d after %s (%s) sent to %s\nReference: %s", index+1, copied.Title, strings.ReplaceAll(attendance, "_", "-"), phoneNumber, reference),
+		"done":      1,
+		"due_date":  pipedriveDueDate(time.Now()),
+		"due_time":  pipedriveDueTime(time.Now()),
+	}
+	if step.Channel == "call" {
+		activityData["type"] = "call"
+	}
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to log meeting follow-up for person %d: %v", copied.PersonID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// rescheduleFollowUp moves a follow-up step that is due outside contact hours to when they open;
+// the claim is released so the moved step can be sent
+func (p *PipedriveService) rescheduleFollowUp(job FollowUpJob, at time.Time) {
+	key := bookingKey(job.BookingID, job.UID)
+	p.meetings.mu.Lock()
+	defer p.meetings.mu.Unlock()
+	if meeting, ok := p.meetings.meetings[key]; ok {
+		if meeting.Attendance != job.Attendance || job.Index >= len(meeting.FollowUps) {
+			return
+		}
+		meeting.FollowUps[job.Index].SendAt = at.UTC()
+	}
+	if err := p.schedules.Schedule(followUpJobID(key, job.Attendance, job.Index), JobMeetingFollowUp, at, job); err != nil {
+		p.logf("❌ Failed to reschedule follow-up %d for booking %s: %v", job.Index+1, key, err)
+	}
+}
+
+// respondMeetingOutcome processes a Cal.com meeting outcome webhook
+func respondMeetingOutcome(c *gin.Context, pipedriveService *PipedriveService, payload CalWebhookPayload) {
+	if payload.Payload.ID == 0 && payload.Payload.UID == "" {
+		c.JSON(http.StatusBadRequest, WebhookResponse{
+			Success: false,
+			Message: "Missing required field: booking ID or UID",
+		})
+		return
+	}
+
+	if pipedriveService.config.AsyncWebhookProcessing {
+		respondAccepted(c, pipedriveService, "cal_meeting_outcome", func() error {
+			return pipedriveService.ProcessCalMeetingOutcome(payload)
+		})
+		return
+	}
+	if err := pipedriveService.ProcessCalMeetingOutcome(payload); err != nil {
+		log.Printf("❌ [CAL WEBHOOK] ProcessCalMeetingOutcome failed: %v", err)
+		c.JSON(http.StatusInternalServerError, WebhookResponse{
+			Success: false,
+			Message: "Failed to process meeting outcome: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, WebhookResponse{
+		Success: true,
+		Message: "Meeting outcome processed successfully",
+		Data: gin.H{
+			"trigger_event": payload.TriggerEvent,
+			"booking_id":    payload.Payload.ID,
+			"booking_uid":   payload.Payload.UID,
+		},
+	})
+}
+
+// ListMeetingsHandler lists booked meetings with their outcomes and follow-ups
+func ListMeetingsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		meetings := pipedriveService.meetings.List(requestTenant(c))
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d meetings", len(meetings)),
+			Data:    meetings,
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MemoryEntry is what one earlier call contributes to a person's conversation memory
+type MemoryEntry struct {
+	CallID      string    `json:"call_id"`
+	At          time.Time `json:"at"`
+	Summary     string    `json:"summary"`
+	Commitments string    `json:"commitments,omitempty"` // What the agent or the person agreed to
+	Tenant      string    `json:"tenant,omitempty"`      // Tenant owning the call
+}
+
+// memoryKey identifies a person's memory within one tenant; tenants never see each other's memory
+type memoryKey struct {
+	Tenant   string
+	PersonID int
+}
+
+// memoryFileRecord is one tenant's memory of a person as written to MEMORY_FILE, sealed with the
+// tenant's key
+type memoryFileRecord struct {
+	Tenant   string `json:"tenant"`
+	PersonID int    `json:"person_id"`
+	Sealed   []byte `json:"sealed"`
+}
+
+// legacyMemoryRecord is a person's memory in a MEMORY_FILE written before memory was kept per
+// tenant, sealed with the key of the tenant of their latest call
+type legacyMemoryRecord struct {
+	Tenant string `json:"tenant,omitempty"`
+	Sealed []byte `json:"sealed"`
+}
+
+// MemoryStore keeps recent call memories per tenant and person, optionally persisted to MEMORY_FILE
+type MemoryStore struct {
+	mu       sync.Mutex
+	path     string
+	limit    int                         // Entries kept per tenant and person
+	memories map[memoryKey][]MemoryEntry // Oldest first
+	keys     KeyProvider                 // Seals the memories written to the file; nil keeps them in memory only
+}
+
+// memoryTenant returns the tenant memory is kept under, DefaultTenant for entries recorded without one
+func memoryTenant(tenant string) string {
+	if tenant == "" {
+		return DefaultTenant
+	}
+	return tenant
+}
+
+// NewMemoryStore creates a memory store, loading MEMORY_FILE when set. Files written before memories
+// were sealed per tenant are read once and rewritten.
+func NewMemoryStore(config *Config, keys KeyProvider) *MemoryStore {
+	store := &MemoryStore{path: config.MemoryFile, limit: config.MemoryMaxCalls, memories: make(map[memoryKey][]MemoryEntry), keys: keys}
+	if store.path == "" {
+		return store
+	}
+	if keys == nil {
+		log.Printf("⚠️ No tenant encryption keys configured, conversation memory is not written to MEMORY_FILE")
+		// A plaintext file left by an older version is still rewritten, without the memories
+		defer func() { store.path = "" }()
+	}
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read MEMORY_FILE %s: %v", store.path, err)
+		}
+		return store
+	}
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		store.load(data)
+	} else if store.loadLegacy(data) {
+		store.saveLocked()
+	}
+	log.Printf("🧠 Loaded %d person memories from %s", len(store.memories), store.path)
+	return store
+}
+
+// load reads the sealed per-tenant records of MEMORY_FILE
+func (s *MemoryStore) load(data []byte) {
+	var records []memoryFileRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable MEMORY_FILE %s: %v", s.path, err)
+		return
+	}
+	if s.keys == nil {
+		return
+	}
+	for _, record := range records {
+		var entries []MemoryEntry
+		opened, err := openForTenant(s.keys, record.Tenant, record.Sealed)
+		if err == nil {
+			err = json.Unmarshal(opened, &entries)
+		}
+		if err != nil {
+			log.Printf("⚠️ Warning: Skipping unreadable memory of person %d in MEMORY_FILE: %v", record.PersonID, err)
+			continue
+		}
+		s.memories[memoryKey{Tenant: memoryTenant(record.Tenant), PersonID: record.PersonID}] = entries
+	}
+}
+
+// loadLegacy reads a MEMORY_FILE keyed by person alone, as plain lists of entries or sealed with one
+// tenant's key, and splits each person's entries by the tenant of their call. It reports whether
+// anything was read that must be rewritten
+func (s *MemoryStore) loadLegacy(data []byte) bool {
+	var records map[int]json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable MEMORY_FILE %s: %v", s.path, err)
+		return false
+	}
+	for personID, raw := range records {
+		entries, err := s.decodeLegacy(raw)
+		if err != nil {
+			log.Printf("⚠️ Warning: Skipping unreadable memory of person %d in MEMORY_FILE: %v", personID, err)
+			continue
+		}
+		for _, entry := range entries {
+			entry.Tenant = memoryTenant(entry.Tenant)
+			key := memoryKey{Tenant: entry.Tenant, PersonID: personID}
+			s.memories[key] = s.capped(append(s.memories[key], entry))
+		}
+	}
+	return len(records) > 0
+}
+
+// decodeLegacy reads a person's record from a MEMORY_FILE keyed by person alone
+func (s *MemoryStore) decodeLegacy(raw json.RawMessage) (entries []MemoryEntry, err error) {
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(raw, &entries)
+		return entries, err
+	}
+	var record legacyMemoryRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, err
+	}
+	if s.keys == nil {
+		return nil, fmt.Errorf("no tenant encryption keys configured")
+	}
+	opened, err := openForTenant(s.keys, record.Tenant, record.Sealed)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(opened, &entries)
+	return entries, err
+}
+
+// capped keeps the newest entries up to the limit
+func (s *MemoryStore) capped(entries []MemoryEntry) []MemoryEntry {
+	if s.limit > 0 && len(entries) > s.limit {
+		return append([]MemoryEntry(nil), entries[len(entries)-s.limit:]...)
+	}
+	return entries
+}
+
+// Add appends a call to a person's memory in its tenant, once per call, keeping the newest entries
+func (s *MemoryStore) Add(personID int, entry MemoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.Tenant = memoryTenant(entry.Tenant)
+	key := memoryKey{Tenant: entry.Tenant, PersonID: personID}
+	entries := s.memories[key]
+	for _, existing := range entries {
+		if existing.CallID == entry.CallID {
+			return
+		}
+	}
+	s.memories[key] = s.capped(append(entries, entry))
+	s.saveLocked()
+}
+
+// Get returns a person's memory entries in one tenant, oldest first
+func (s *MemoryStore) Get(tenant string, personID int) []MemoryEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MemoryEntry(nil), s.memories[memoryKey{Tenant: memoryTenant(tenant), PersonID: personID}]...)
+}
+
+// Visible returns a person's memory entries as seen from a request scope (see requestTenant):
+// one tenant's, or every tenant's for an unscoped scope, oldest first
+func (s *MemoryStore) Visible(scope string, personID int) []MemoryEntry {
+	if scope != "" {
+		return s.Get(scope, personID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []MemoryEntry
+	for key, tenantEntries := range s.memories {
+		if key.PersonID == personID {
+			entries = append(entries, tenantEntries...)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	return entries
+}
+
+// Forget deletes a person's memory within a request scope: one tenant's, or every tenant's for an
+// unscoped scope. It reports whether there was anything to delete
+func (s *MemoryStore) Forget(scope string, personID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	forgotten := false
+	for key := range s.memories {
+		if key.PersonID == personID && (scope == "" || key.Tenant == scope) {
+			delete(s.memories, key)
+			forgotten = true
+		}
+	}
+	if forgotten {
+		s.saveLocked()
+	}
+	return forgotten
+}
+
+// Purge drops the entries of calls before cutoff and returns how many
+func (s *MemoryStore) Purge(cutoff time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purged := 0
+	for key, entries := range s.memories {
+		kept := make([]MemoryEntry, 0, len(entries))
+		for _, entry := range entries {
+			if entry.At.Before(cutoff) {
+				purged++
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		if len(kept) == 0 {
+			delete(s.memories, key)
+		} else {
+			s.memories[key] = kept
+		}
+	}
+	if purged > 0 {
+		s.saveLocked()
+	}
+	return purged
+}
+
+// saveLocked rewrites MEMORY_FILE with each tenant's memory of a person sealed with that tenant's
+// key; memories that cannot be sealed are left out of the file. Callers hold mu
+func (s *MemoryStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	records := make([]memoryFileRecord, 0, len(s.memories))
+	for key, entries := range s.memories {
+		if s.keys == nil || len(entries) == 0 {
+			continue
+		}
+		plaintext, err := json.Marshal(entries)
+		if err != nil {
+			continue
+		}
+		sealed, err := sealForTenant(s.keys, key.Tenant, plaintext)
+		if err != nil {
+			log.Printf("⚠️ Warning: Not writing the %s memory of person %d to MEMORY_FILE: %v", key.Tenant, key.PersonID, err)
+			continue
+		}
+		records = append(records, memoryFileRecord{Tenant: key.Tenant, PersonID: key.PersonID, Sealed: sealed})
+	}
+	data, err := json.Marshal(records)
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save conversation memory: %v", err)
+	}
+}
+
+// memoryEnabled reports whether conversation memory is used for a tenant
+func (c *Config) memoryEnabled(tenant string) bool {
+	if !c.MemoryEnabled {
+		return false
+	}
+	for _, optedOut := range c.MemoryDisabledTenants {
+		if optedOut == tenant {
+			return false
+		}
+	}
+	return true
+}
+
+// compactMemory formats entries newest first, dropping older calls once maxChars is reached
+func compactMemory(entries []MemoryEntry, maxChars int) string {
+	var lines []string
+	length := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		line := fmt.Sprintf("%s: %s", entries[i].At.Format("2006-01-02"), strings.Join(strings.Fields(entries[i].Summary), " "))
+		if entries[i].Commitments != "" {
+			line += " Commitments: " + entries[i].Commitments
+		}
+		if maxChars > 0 && length+len(line) > maxChars {
+			if len(lines) == 0 {
+				lines = append(lines, truncateRunes(line, maxChars))
+			}
+			break
+		}
+		lines = append(lines, line)
+		length += len(line) + 1
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateRunes shortens s to at most max bytes without splitting a character
+func truncateRunes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// addMemoryVariables passes a person's conversation memory to the agent of a new call
+func (p *PipedriveService) addMemoryVariables(variables map[string]interface{}, personID int, tenant string) {
+	if !p.config.memoryEnabled(tenant) {
+		return
+	}
+	entries := p.memory.Get(tenant, personID)
+	if len(entries) == 0 {
+		return
+	}
+	variables["conversation_memory"] = compactMemory(entries, p.config.MemoryMaxChars)
+	variables["previous_calls"] = strconv.Itoa(len(entries))
+}
+
+// rememberCall adds an analyzed call's summary and commitments to the person's memory
+func (p *PipedriveService) rememberCall(payload RetellCallAnalyzedPayload, mapping CallMapping) {
+	tenant := mapping.Tenant
+	if tenant == "" {
+		tenant = p.config.TenantFor(payload.Call.AgentID)
+	}
+	if !p.config.memoryEnabled(tenant) || !callAnswered(payload) {
+		return
+	}
+	summary := strings.TrimSpace(payload.Call.CallAnalysis.CallSummary)
+	if summary == "" || mapping.PersonID == 0 {
+		return
+	}
+	commitments := analysisText(payload.Call.CallAnalysis.CustomAnalysisData, p.config.MemoryCommitmentsField, "; ")
+	p.memory.Add(mapping.PersonID, MemoryEntry{
+		CallID:      payload.Call.CallID,
+		At:          TimestampFromMillis(payload.Call.StartTimestamp).UTC,
+		Summary:     summary,
+		Commitments: commitments,
+		Tenant:      tenant,
+	})
+}
+
+// PersonMemoryHandler shows the conversation memory passed to a person's next call
+func PersonMemoryHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		personID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid person ID",
+			})
+			return
+		}
+		// Memory of other tenants is answered like no memory at all
+		entries := pipedriveService.memory.Visible(requestTenant(c), personID)
+		if len(entries) == 0 {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: fmt.Sprintf("No memory for person %d", personID),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d remembered calls", len(entries)),
+			Data: gin.H{
+				"entries":             entries,
+				"conversation_memory": compactMemory(entries, pipedriveService.config.MemoryMaxChars),
+			},
+		})
+	}
+}
+
+// ForgetPersonMemoryHandler deletes a person's conversation memory
+func ForgetPersonMemoryHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		personID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid person ID",
+			})
+			return
+		}
+		if !pipedriveService.memory.Forget(requestTenant(c), personID) {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: fmt.Sprintf("No memory for person %d", personID),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Memory for person %d deleted", personID),
+		})
+	}
+}
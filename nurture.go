@@ -0,0 +1,560 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Nurture statuses
+const (
+	NurtureScheduled = "scheduled"
+	NurtureCalled    = "called"
+	NurtureCancelled = "cancelled"
+	NurtureSkipped   = "skipped"
+	NurtureFailed    = "failed"
+)
+
+// NurtureRule places an AI call when a deal sits in a stage for a number of days without activity
+type NurtureRule struct {
+	Name       string `json:"name"`
+	StageID    int    `json:"stage_id"`
+	PipelineID int    `json:"pipeline_id,omitempty"` // Optional, stage IDs are already unique per pipeline
+	Days       int    `json:"days"`                  // Days without activity before calling
+	AgentID    string `json:"agent_id,omitempty"`    // Defaults to RETELL_ASSISTANT_ID
+}
+
+// loadNurtureRules parses the NURTURE_RULES JSON list
+func loadNurtureRules(raw string) []NurtureRule {
+	if raw == "" {
+		return nil
+	}
+	var rules []NurtureRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Printf("⚠️ Invalid NURTURE_RULES, ignoring: %v", err)
+		return nil
+	}
+
+	valid := make([]NurtureRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.StageID == 0 || rule.Days <= 0 {
+			log.Printf("⚠️ Ignoring nurture rule %d: stage_id and positive days are required", i)
+			continue
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("stage %d", rule.StageID)
+		}
+		valid = append(valid, rule)
+	}
+	return valid
+}
+
+// nurtureRuleFor returns the rule for a deal stage, if any
+func (c *Config) nurtureRuleFor(stageID, pipelineID int) *NurtureRule {
+	for i, rule := range c.NurtureRules {
+		if rule.StageID == stageID && (rule.PipelineID == 0 || rule.PipelineID == pipelineID) {
+			return &c.NurtureRules[i]
+		}
+	}
+	return nil
+}
+
+// isAutomatedActivity returns true for activity subjects this service writes; isOwnActivity falls back
+// to it when the activity's creator is unknown
+func isAutomatedActivity(subject string) bool {
+	return strings.HasPrefix(subject, "AI ") ||
+		strings.HasPrefix(subject, "Inbound AI ") ||
+		strings.HasPrefix(subject, "Customer Requested Do Not Call") ||
+		strings.HasPrefix(subject, "Meeting reminder sent") ||
+		strings.HasPrefix(subject, "WhatsApp Follow-up Sent")
+}
+
+// PipedriveDealWebhookPayload represents the incoming Pipedrive deal webhook data
+type PipedriveDealWebhookPayload struct {
+	Data struct {
+		ID         int    `json:"id"`
+		Title      string `json:"title"`
+		StageID    int    `json:"stage_id"`
+		PipelineID int    `json:"pipeline_id"`
+		PersonID   int    `json:"person_id"`
+		OwnerID    int    `json:"owner_id"`
+		Status     string `json:"status"`
+	} `json:"data"`
+	Previous struct {
+		StageID *int `json:"stage_id"`
+	} `json:"previous"`
+	Meta struct {
+		Action       string `json:"action"`
+		Entity       string `json:"entity"`
+		ChangeSource string `json:"change_source"`
+	} `json:"meta"`
+
+	Tenant string `json:"-"` // Tenant whose credentials verified the webhook
+}
+
+// PipedriveActivityWebhookPayload represents the incoming Pipedrive activity webhook data
+type PipedriveActivityWebhookPayload struct {
+	Data struct {
+		ID       int    `json:"id"`
+		Subject  string `json:"subject"`
+		Type     string `json:"type"`
+		DealID   int    `json:"deal_id"`
+		PersonID int    `json:"person_id"`
+		UserID   int    `json:"user_id"`
+		Creator  int    `json:"created_by_user_id"`
+	} `json:"data"`
+	Meta struct {
+		Action       string `json:"action"`
+		ChangeSource string `json:"change_source"`
+	} `json:"meta"`
+}
+
+// Nurture is a pending or completed nurture call for a deal
+type Nurture struct {
+	DealID    int       `json:"deal_id"`
+	PersonID  int       `json:"person_id"`
+	Title     string    `json:"title"`
+	StageID   int       `json:"stage_id"`
+	Rule      string    `json:"rule"`
+	AgentID   string    `json:"agent_id"`
+	EnteredAt time.Time `json:"entered_at"`
+	CallAt    time.Time `json:"call_at"`
+	Status    string    `json:"status"`
+	Detail    string    `json:"detail,omitempty"`
+	CallID    string    `json:"call_id,omitempty"`
+}
+
+// NurtureScheduler keeps nurture calls per deal; the scheduled ones are jobs in SCHEDULE_FILE
+type NurtureScheduler struct {
+	mu       sync.Mutex
+	nurtures map[int]*Nurture // Keyed by deal ID
+	jobs     *JobScheduler
+}
+
+// NewNurtureScheduler creates a new nurture scheduler
+func NewNurtureScheduler(jobs *JobScheduler) *NurtureScheduler {
+	return &NurtureScheduler{nurtures: make(map[int]*Nurture), jobs: jobs}
+}
+
+// nurtureJobID is the scheduled job ID of a deal's nurture call
+func nurtureJobID(dealID int) string {
+	return fmt.Sprintf("nurture:%d", dealID)
+}
+
+// Pending returns the scheduled nurture for a deal, if any
+func (s *NurtureScheduler) Pending(dealID int) (Nurture, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nurture, ok := s.nurtures[dealID]
+	if !ok || nurture.Status != NurtureScheduled {
+		return Nurture{}, false
+	}
+	return *nurture, true
+}
+
+// Cancel stops the pending nurture call for a deal
+func (s *NurtureScheduler) Cancel(dealID int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nurture, ok := s.nurtures[dealID]
+	if !ok || nurture.Status != NurtureScheduled {
+		return
+	}
+	s.jobs.Cancel(nurtureJobID(dealID))
+	nurture.Status = NurtureCancelled
+	nurture.Detail = reason
+	log.Printf("🔕 Cancelled nurture call for deal %d (%s)", dealID, reason)
+}
+
+// CancelPerson stops all pending nurture calls to a person and returns how many were cancelled
+func (s *NurtureScheduler) CancelPerson(personID int, reason string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancelled := 0
+	for _, nurture := range s.nurtures {
+		if nurture.PersonID != personID || nurture.Status != NurtureScheduled {
+			continue
+		}
+		s.jobs.Cancel(nurtureJobID(nurture.DealID))
+		nurture.Status = NurtureCancelled
+		nurture.Detail = reason
+		cancelled++
+		log.Printf("🔕 Cancelled nurture call for deal %d (%s)", nurture.DealID, reason)
+	}
+	return cancelled
+}
+
+// finish records the outcome of a nurture
+func (s *NurtureScheduler) finish(nurture *Nurture, status, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nurture.Status = status
+	nurture.Detail = detail
+}
+
+// List returns all nurtures sorted by call time
+func (s *NurtureScheduler) List() []Nurture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Nurture, 0, len(s.nurtures))
+	for _, nurture := range s.nurtures {
+		list = append(list, *nurture)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CallAt.Before(list[j].CallAt) })
+	return list
+}
+
+// GetDeal fetches a deal by ID
+func (p *PipedriveService) GetDeal(dealID int) (*PipedriveDeal, error) {
+	resp, err := p.makePipedriveRequest("GET", fmt.Sprintf("/deals/%d", dealID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deal response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to get deal %d: HTTP %d", dealID, resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool           `json:"success"`
+		Data    *PipedriveDeal `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse deal response: %v", err)
+	}
+	if !result.Success || result.Data == nil {
+		return nil, fmt.Errorf("failed to get deal %d", dealID)
+	}
+	return result.Data, nil
+}
+
+// ProcessDealWebhook starts a nurture timer when a deal enters a configured stage and cancels it when the deal moves on
+func (p *PipedriveService) ProcessDealWebhook(payload PipedriveDealWebhookPayload) {
+	deal := payload.Data
+	action := strings.ToLower(payload.Meta.Action)
+	if action == "delete" || action == "deleted" {
+		p.nurtures.Cancel(deal.ID, "deal deleted")
+		return
+	}
+	if deal.Status != "" && deal.Status != "open" {
+		p.nurtures.Cancel(deal.ID, "deal "+deal.Status)
+		return
+	}
+
+	entered := action == "create" || action == "added" ||
+		(payload.Previous.StageID != nil && *payload.Previous.StageID != deal.StageID)
+	if !entered {
+		return
+	}
+
+	rule := p.config.nurtureRuleFor(deal.StageID, deal.PipelineID)
+	if rule == nil {
+		p.nurtures.Cancel(deal.ID, "deal left the nurture stage")
+		return
+	}
+	if deal.PersonID == 0 {
+		p.logf("ℹ️ Deal %d entered nurture stage %d without a person, skipping", deal.ID, deal.StageID)
+		return
+	}
+	agentID := rule.AgentID
+	if agentID == "" {
+		agentID = p.config.RetellAssistantID
+	}
+	if !p.tenantAllows(payload.Tenant, agentID, fmt.Sprintf("Deal %d", deal.ID)) {
+		return
+	}
+	p.scheduleNurture(deal.ID, deal.PersonID, deal.Title, deal.StageID, *rule)
+}
+
+// ProcessActivityWebhook cancels a deal's pending nurture call when someone works the deal
+func (p *PipedriveService) ProcessActivityWebhook(payload PipedriveActivityWebhookPayload) {
+	action := strings.ToLower(payload.Meta.Action)
+	if action == "delete" || action == "deleted" || payload.Data.DealID == 0 {
+		return
+	}
+	if p.isOwnActivity(payload.Data.Creator, payload.Data.Subject) {
+		return
+	}
+	p.nurtures.Cancel(payload.Data.DealID, fmt.Sprintf("activity %d added", payload.Data.ID))
+}
+
+// scheduleNurture schedules a nurture call for a deal, replacing any earlier one
+func (p *PipedriveService) scheduleNurture(dealID, personID int, title string, stageID int, rule NurtureRule) {
+	agentID := rule.AgentID
+	if agentID == "" {
+		agentID = p.config.RetellAssistantID
+	}
+	now := time.Now()
+
+	p.nurtures.mu.Lock()
+	defer p.nurtures.mu.Unlock()
+	nurture := &Nurture{
+		DealID:    dealID,
+		PersonID:  personID,
+		Title:     title,
+		StageID:   stageID,
+		Rule:      rule.Name,
+		AgentID:   agentID,
+		EnteredAt: now,
+		CallAt:    now.AddDate(0, 0, rule.Days),
+		Status:    NurtureScheduled,
+	}
+	if err := p.schedules.Schedule(nurtureJobID(dealID), JobNurtureCall, nurture.CallAt, nurture); err != nil {
+		p.logf("❌ Failed to schedule nurture call for deal %d: %v", dealID, err)
+		return
+	}
+	p.nurtures.nurtures[dealID] = nurture
+	p.logf("🌱 Scheduled nurture call for deal %d (%s) at %s", dealID, rule.Name, nurture.CallAt.Format(time.RFC3339))
+}
+
+// dealWorkedSince returns true if a person other than this service logged an activity on the deal since a time
+func (p *PipedriveService) dealWorkedSince(dealID int, since time.Time) (bool, error) {
+	activities, err := p.ListDealActivities(dealID)
+	if err != nil {
+		return false, err
+	}
+	for _, activity := range activities {
+		if p.isOwnActivity(activity.CreatorUserID, activity.Subject) {
+			continue
+		}
+		added, err := parsePipedriveTime(activity.AddTime)
+		if err == nil && added.After(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// restoreNurtures lists the nurture calls saved in SCHEDULE_FILE as scheduled again
+func (p *PipedriveService) restoreNurtures() {
+	p.nurtures.mu.Lock()
+	defer p.nurtures.mu.Unlock()
+	for _, job := range p.schedules.Jobs(JobNurtureCall) {
+		var nurture Nurture
+		if err := json.Unmarshal(job.Payload, &nurture); err != nil {
+			p.logf("⚠️ Warning: Ignoring unreadable nurture call %s: %v", job.ID, err)
+			continue
+		}
+		p.nurtures.nurtures[nurture.DealID] = &nurture
+	}
+}
+
+// runNurtureJob places a nurture call whose time has come
+func (p *PipedriveService) runNurtureJob(raw json.RawMessage) {
+	var job Nurture
+	if err := json.Unmarshal(raw, &job); err != nil {
+		p.logf("❌ Failed to read nurture call: %v", err)
+		return
+	}
+	p.nurtures.mu.Lock()
+	nurture, ok := p.nurtures.nurtures[job.DealID]
+	pending := ok && nurture.EnteredAt.Equal(job.EnteredAt) && nurture.Status == NurtureScheduled
+	p.nurtures.mu.Unlock()
+	if pending {
+		p.placeNurtureCall(nurture)
+	}
+}
+
+// placeNurtureCall re-checks the deal and places the nurture call
+func (p *PipedriveService) placeNurtureCall(nurture *Nurture) {
+	if _, ok := p.claim(fmt.Sprintf("nurture:%d:%d", nurture.DealID, nurture.CallAt.Unix())); !ok {
+		p.nurtures.finish(nurture, NurtureSkipped, "placed by another replica")
+		return
+	}
+
+	// The deal may have moved, closed or been worked since the timer started
+	deal, err := p.GetDeal(nurture.DealID)
+	if err != nil {
+		p.logf("⚠️ Warning: Could not confirm deal %d, skipping nurture call: %v", nurture.DealID, err)
+		p.nurtures.finish(nurture, NurtureFailed, err.Error())
+		return
+	}
+	if deal.Status != "open" || deal.StageID != nurture.StageID {
+		p.nurtures.finish(nurture, NurtureSkipped, fmt.Sprintf("deal is %s in stage %d", deal.Status, deal.StageID))
+		return
+	}
+	worked, err := p.dealWorkedSince(nurture.DealID, nurture.EnteredAt)
+	if err != nil {
+		p.logf("⚠️ Warning: Could not check activities of deal %d, skipping nurture call: %v", nurture.DealID, err)
+		p.nurtures.finish(nurture, NurtureFailed, err.Error())
+		return
+	}
+	if worked {
+		p.nurtures.finish(nurture, NurtureSkipped, "deal has new activity")
+		return
+	}
+	person, err := p.GetPersonByID(nurture.PersonID)
+	if err != nil {
+		p.logf("❌ Failed to get person %d for nurture call: %v", nurture.PersonID, err)
+		p.nurtures.finish(nurture, NurtureFailed, err.Error())
+		return
+	}
+	phoneNumber := p.extractPhoneFromPerson(person)
+	if phoneNumber == "" {
+		p.nurtures.finish(nurture, NurtureSkipped, "no phone number")
+		return
+	}
+	blocked, notUntil := p.contactGate(person, phoneNumber, ContactCall, "nurture call", time.Now())
+	if blocked != "" {
+		p.nurtures.finish(nurture, NurtureSkipped, blocked)
+		return
+	}
+	if !notUntil.IsZero() {
+		p.rescheduleNurture(nurture, notUntil)
+		return
+	}
+
+	variables := p.mappedDynamicVariables(person)
+	variables["nurture"] = "true"
+	variables["deal_title"] = deal.Title
+	variables["days_inactive"] = fmt.Sprintf("%d", int(time.Since(nurture.EnteredAt).Hours()/24))
+	tenant := p.config.TenantFor(nurture.AgentID)
+	agentID, consentRequired := p.consentAgent(phoneNumber, nurture.AgentID, variables)
+	p.addMemoryVariables(variables, person.ID, tenant)
+	callID, err := p.CreateRetellCall(phoneNumber, person.Name, deal.Title, agentID, "", variables, &CallMetadata{
+		Tenant:     tenant,
+		PersonID:   nurture.PersonID,
+		PersonName: person.Name,
+		LeadTitle:  deal.Title,
+		Region:     p.config.RegionPolicy().RegionOf(tenant),
+	})
+	if err != nil {
+		p.logf("❌ Failed to place nurture call for deal %d: %v", nurture.DealID, err)
+		p.nurtures.finish(nurture, NurtureFailed, err.Error())
+		return
+	}
+	p.logf("🌱 Placed nurture call %s for deal %d (person: %s)", callID, nurture.DealID, person.Name)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "call_placed", PersonID: nurture.PersonID, CallID: callID, Detail: fmt.Sprintf("nurture call for deal %d", nurture.DealID)})
+
+	p.storeCallMapping(callID, person.Name, phoneNumber, deal.Title, nurture.PersonID)
+	p.updateCallMapping(callID, func(m *CallMapping) {
+		m.Tenant = tenant
+		m.Region = p.config.RegionPolicy().RegionOf(tenant)
+		if consentRequired {
+			m.Consent = &ConsentRecord{Status: ConsentUnknown, RecordedAt: time.Now()}
+		}
+	})
+	mapping, _ := p.getCallMapping(callID)
+	p.callEvents.Append(CallEvent{CallID: callID, Type: CallEventDialing, PersonID: nurture.PersonID, Detail: fmt.Sprintf("nurture for deal %d", nurture.DealID), Mapping: &mapping})
+
+	p.nurtures.mu.Lock()
+	nurture.CallID = callID
+	p.nurtures.mu.Unlock()
+	p.nurtures.finish(nurture, NurtureCalled, "")
+
+	activityData := map[string]interface{}{
+		"subject":   fmt.Sprintf("AI Nurture Call Initiated - Deal: %s", deal.Title),
+		"type":      "call",
+		"person_id": nurture.PersonID,
+		"deal_id":   nurture.DealID,
+		"note": fmt.Sprintf("Retell AI nurture call after %d days in stage without activity (%s)\nCall ID: %s\nPhone: %s",
+			int(time.Since(nurture.EnteredAt).Hours()/24), nurture.Rule, callID, phoneNumber),
+		"done":     0,
+		"due_date": pipedriveDueDate(time.Now()),
+		"due_time": pipedriveDueTime(time.Now().Add(5 * time.Minute)),
+	}
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to create nurture call activity for deal %d: %v", nurture.DealID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// rescheduleNurture moves a nurture call that is due outside contact hours to when they open;
+// the claim is per time so the moved call can be placed
+func (p *PipedriveService) rescheduleNurture(nurture *Nurture, at time.Time) {
+	p.nurtures.mu.Lock()
+	defer p.nurtures.mu.Unlock()
+	if p.nurtures.nurtures[nurture.DealID] != nurture || nurture.Status != NurtureScheduled {
+		return
+	}
+	nurture.CallAt = at
+	if err := p.schedules.Schedule(nurtureJobID(nurture.DealID), JobNurtureCall, at, nurture); err != nil {
+		p.logf("❌ Failed to reschedule nurture call for deal %d: %v", nurture.DealID, err)
+		nurture.Status = NurtureFailed
+		nurture.Detail = err.Error()
+	}
+}
+
+// PipedriveDealWebhookHandler handles Pipedrive deal webhooks for stage-triggered nurture calls
+func PipedriveDealWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
+		var payload PipedriveDealWebhookPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+		payload.Tenant = webhookTenant(c)
+		if payload.Data.ID == 0 {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Missing required field: data.id",
+			})
+			return
+		}
+
+		pipedriveService.ProcessDealWebhook(payload)
+		nurture, scheduled := pipedriveService.nurtures.Pending(payload.Data.ID)
+		data := gin.H{"deal_id": payload.Data.ID, "stage_id": payload.Data.StageID, "nurture_scheduled": scheduled}
+		if scheduled {
+			data["call_at"] = nurture.CallAt
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Pipedrive deal webhook processed successfully",
+			Data:    data,
+		})
+	}
+}
+
+// PipedriveActivityWebhookHandler handles Pipedrive activity webhooks, cancelling nurture calls for worked deals
+func PipedriveActivityWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
+		var payload PipedriveActivityWebhookPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		pipedriveService.ProcessActivityWebhook(payload)
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Pipedrive activity webhook processed successfully",
+			Data:    gin.H{"activity_id": payload.Data.ID, "deal_id": payload.Data.DealID},
+		})
+	}
+}
+
+// ListNurturesHandler lists scheduled and completed nurture calls
+func ListNurturesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nurtures := pipedriveService.nurtures.List()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d nurture calls", len(nurtures)),
+			Data:    nurtures,
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownerStatusTTL is how long a user's active flag is cached
+const ownerStatusTTL = 15 * time.Minute
+
+// maxOwnerFallbacks is how many recent owner fallbacks are kept for the report
+const maxOwnerFallbacks = 500
+
+// OwnerFallback records a lead whose owner could not be assigned activities
+type OwnerFallback struct {
+	LeadID        string    `json:"lead_id"`
+	LeadTitle     string    `json:"lead_title"`
+	OriginalOwner int       `json:"original_owner_id"`
+	AssignedOwner int       `json:"assigned_owner_id"`
+	Reason        string    `json:"reason"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// ownerStatus is a cached Pipedrive user active flag
+type ownerStatus struct {
+	active    bool
+	fetchedAt time.Time
+}
+
+// OwnerResolver validates lead owners and picks fallbacks for deactivated users
+type OwnerResolver struct {
+	mu        sync.Mutex
+	statuses  map[int]ownerStatus
+	fallbacks []OwnerFallback
+}
+
+// NewOwnerResolver creates a new owner resolver
+func NewOwnerResolver() *OwnerResolver {
+	return &OwnerResolver{statuses: make(map[int]ownerStatus)}
+}
+
+// parseIDList parses a comma-separated list of numeric IDs
+func parseIDList(raw string) []int {
+	var ids []int
+	for _, part := range parseList(raw) {
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			log.Printf("⚠️ Ignoring invalid ID %q", part)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// isUserActive checks whether a Pipedrive user is active, caching the result
+func (p *PipedriveService) isUserActive(userID int) (bool, error) {
+	p.owners.mu.Lock()
+	status, ok := p.owners.statuses[userID]
+	p.owners.mu.Unlock()
+	if ok && time.Since(status.fetchedAt) < ownerStatusTTL {
+		return status.active, nil
+	}
+
+	resp, err := p.makePipedriveRequest("GET", fmt.Sprintf("/users/%d", userID), nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	active := false
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return false, fmt.Errorf("failed to read user response: %v", err)
+		}
+		var result struct {
+			Data struct {
+				ActiveFlag bool `json:"active_flag"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return false, fmt.Errorf("failed to parse user response: %v", err)
+		}
+		active = result.Data.ActiveFlag
+	case http.StatusNotFound:
+		// Deleted users are treated as deactivated
+	default:
+		return false, fmt.Errorf("user lookup failed: HTTP %d", resp.StatusCode)
+	}
+
+	p.owners.mu.Lock()
+	p.owners.statuses[userID] = ownerStatus{active: active, fetchedAt: time.Now()}
+	p.owners.mu.Unlock()
+	return active, nil
+}
+
+// resolveLeadOwner returns the user activities for a lead should be assigned to, or 0 to leave it unset
+func (p *PipedriveService) resolveLeadOwner(leadID, leadTitle string, ownerID int) int {
+	if ownerID == 0 {
+		return p.fallbackOwner(leadID, leadTitle, ownerID, "lead has no owner")
+	}
+
+	active, err := p.isUserActive(ownerID)
+	if err != nil {
+		p.logf("⚠️ Warning: Could not check owner %d for lead %s, keeping owner: %v", ownerID, leadID, err)
+		return ownerID
+	}
+	if active {
+		return ownerID
+	}
+	return p.fallbackOwner(leadID, leadTitle, ownerID, "owner is deactivated")
+}
+
+// fallbackOwner picks a fallback owner for a lead and records it in the warning report
+func (p *PipedriveService) fallbackOwner(leadID, leadTitle string, ownerID int, reason string) int {
+	assigned := 0
+	if ownerID == 0 && p.config.RoutingTeamID != 0 {
+		// Unowned leads take turns across the routing team
+		assigned = p.nextTeamMember()
+	}
+	if assigned == 0 && len(p.config.FallbackOwnerIDs) > 0 {
+		// Spread leads over the fallback team deterministically
+		hash := fnv.New32a()
+		hash.Write([]byte(leadID))
+		assigned = p.config.FallbackOwnerIDs[hash.Sum32()%uint32(len(p.config.FallbackOwnerIDs))]
+	}
+
+	if assigned != 0 {
+		p.logf("⚠️ Lead %s: %s (user %d), assigning activities to fallback owner %d", leadID, reason, ownerID, assigned)
+	} else {
+		p.logf("⚠️ Lead %s: %s (user %d) and no FALLBACK_OWNER_IDS configured, activities will be unassigned", leadID, reason, ownerID)
+	}
+
+	p.owners.mu.Lock()
+	p.owners.fallbacks = append(p.owners.fallbacks, OwnerFallback{
+		LeadID:        leadID,
+		LeadTitle:     leadTitle,
+		OriginalOwner: ownerID,
+		AssignedOwner: assigned,
+		Reason:        reason,
+		Timestamp:     time.Now(),
+	})
+	if len(p.owners.fallbacks) > maxOwnerFallbacks {
+		p.owners.fallbacks = append([]OwnerFallback(nil), p.owners.fallbacks[len(p.owners.fallbacks)-maxOwnerFallbacks:]...)
+	}
+	p.owners.mu.Unlock()
+	return assigned
+}
+
+// OwnerFallbackReportHandler lists leads whose activities were reassigned or left unassigned
+func OwnerFallbackReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService.owners.mu.Lock()
+		report := make([]OwnerFallback, len(pipedriveService.owners.fallbacks))
+		copy(report, pipedriveService.owners.fallbacks)
+		pipedriveService.owners.mu.Unlock()
+
+		sort.Slice(report, func(i, j int) bool { return report[i].Timestamp.After(report[j].Timestamp) })
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d leads affected by inactive owners", len(report)),
+			Data:    report,
+		})
+	}
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPersonDiffs is how many recent person diffs are kept for the admin API
+const maxPersonDiffs = 500
+
+// Monitored person fields
+const (
+	PersonFieldPhone = "phone"
+	PersonFieldDNC   = "dnc"
+	PersonFieldOwner = "owner_id"
+)
+
+// PersonSnapshot is the last seen state of a person's monitored fields
+type PersonSnapshot struct {
+	PersonID int               `json:"person_id"`
+	Phones   []string          `json:"phones"` // Primary first
+	DNC      bool              `json:"dnc"`
+	OwnerID  int               `json:"owner_id"`
+	Fields   map[string]string `json:"fields,omitempty"` // PERSON_MONITORED_FIELDS values
+	SeenAt   time.Time         `json:"seen_at"`
+}
+
+// PersonFieldChange is one monitored field that changed
+type PersonFieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// PersonDiff records the monitored changes of one person update and the actions taken
+type PersonDiff struct {
+	PersonID int                 `json:"person_id"`
+	At       time.Time           `json:"at"`
+	Source   string              `json:"source"` // snapshot or webhook_previous
+	Changes  []PersonFieldChange `json:"changes"`
+	Actions  []string            `json:"actions,omitempty"`
+}
+
+// PersonChangeTracker keeps person snapshots, recent diffs and call holds
+type PersonChangeTracker struct {
+	mu        sync.Mutex
+	snapshots map[int]PersonSnapshot
+	diffs     []PersonDiff
+	holds     map[int]string // Person ID -> why pending calls are held
+	holdsFile string         // PERSON_HOLDS_FILE; empty keeps holds in memory only
+}
+
+// NewPersonChangeTracker creates a person change tracker, restoring the call holds saved in PERSON_HOLDS_FILE
+func NewPersonChangeTracker(config *Config) *PersonChangeTracker {
+	t := &PersonChangeTracker{
+		snapshots: make(map[int]PersonSnapshot),
+		holds:     make(map[int]string),
+		holdsFile: config.PersonHoldsFile,
+	}
+	if t.holdsFile == "" {
+		return t
+	}
+	data, err := os.ReadFile(t.holdsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read PERSON_HOLDS_FILE %s: %v", t.holdsFile, err)
+		}
+		return t
+	}
+	if err := json.Unmarshal(data, &t.holds); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable PERSON_HOLDS_FILE %s: %v", t.holdsFile, err)
+		t.holds = make(map[int]string)
+	}
+	return t
+}
+
+// saveHoldsLocked writes the call holds to PERSON_HOLDS_FILE; callers hold mu
+func (t *PersonChangeTracker) saveHoldsLocked() {
+	if t.holdsFile == "" {
+		return
+	}
+	data, err := json.Marshal(t.holds)
+	if err == nil {
+		tmp := t.holdsFile + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, t.holdsFile)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save person call holds: %v", err)
+	}
+}
+
+// swap stores a person's new snapshot and returns the previous one, if any
+func (t *PersonChangeTracker) swap(snapshot PersonSnapshot) (PersonSnapshot, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous, ok := t.snapshots[snapshot.PersonID]
+	t.snapshots[snapshot.PersonID] = snapshot
+	return previous, ok
+}
+
+// forget drops a deleted person's snapshot and hold
+func (t *PersonChangeTracker) forget(personID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.snapshots, personID)
+	if _, held := t.holds[personID]; held {
+		delete(t.holds, personID)
+		t.saveHoldsLocked()
+	}
+}
+
+// record appends a diff, keeping the most recent maxPersonDiffs
+func (t *PersonChangeTracker) record(diff PersonDiff) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.diffs = append(t.diffs, diff)
+	if len(t.diffs) > maxPersonDiffs {
+		t.diffs = append([]PersonDiff(nil), t.diffs[len(t.diffs)-maxPersonDiffs:]...)
+	}
+}
+
+// setHold holds or releases a person's pending calls; an empty reason releases
+func (t *PersonChangeTracker) setHold(personID int, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if reason == "" {
+		delete(t.holds, personID)
+	} else {
+		t.holds[personID] = reason
+	}
+	t.saveHoldsLocked()
+}
+
+// Held returns why a person's calls are held, if they are
+func (t *PersonChangeTracker) Held(personID int) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	reason, ok := t.holds[personID]
+	return reason, ok
+}
+
+// Diffs returns recent diffs, newest first, optionally for one person
+func (t *PersonChangeTracker) Diffs(personID int) []PersonDiff {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	diffs := make([]PersonDiff, 0, len(t.diffs))
+	for i := len(t.diffs) - 1; i >= 0; i-- {
+		if personID == 0 || t.diffs[i].PersonID == personID {
+			diffs = append(diffs, t.diffs[i])
+		}
+	}
+	return diffs
+}
+
+// PipedrivePersonWebhookPayload is a Pipedrive person webhook; v2 sends data/previous,
+// v1 sends current/previous with custom fields at the top level
+type PipedrivePersonWebhookPayload struct {
+	Data     map[string]interface{} `json:"data"`
+	Current  map[string]interface{} `json:"current"`
+	Previous map[string]interface{} `json:"previous"`
+	Meta     struct {
+		Action   string      `json:"action"`
+		Entity   string      `json:"entity"`
+		EntityID json.Number `json:"entity_id"`
+		ID       json.Number `json:"id"`
+	} `json:"meta"`
+}
+
+// person returns the person's current fields from either webhook version
+func (w PipedrivePersonWebhookPayload) person() map[string]interface{} {
+	if w.Data != nil {
+		return w.Data
+	}
+	return w.Current
+}
+
+// personID returns the person's ID from the body or the meta block
+func (w PipedrivePersonWebhookPayload) personID() int {
+	for _, fields := range []map[string]interface{}{w.person(), w.Previous} {
+		if id := intField(fields["id"]); id != 0 {
+			return id
+		}
+	}
+	for _, raw := range []json.Number{w.Meta.EntityID, w.Meta.ID} {
+		if id, err := raw.Int64(); err == nil {
+			return int(id)
+		}
+	}
+	return 0
+}
+
+// intField reads an ID that Pipedrive sends as a number, a string or an object with an id
+func intField(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		id, _ := strconv.Atoi(v)
+		return id
+	case map[string]interface{}:
+		return intField(v["id"])
+	}
+	return 0
+}
+
+// personFieldValue reads a field from the top level or, for v2 payloads, custom_fields
+func personFieldValue(fields map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := fields[key]; ok {
+		return value, true
+	}
+	if custom, ok := fields["custom_fields"].(map[string]interface{}); ok {
+		value, ok := custom[key]
+		return value, ok
+	}
+	return nil, false
+}
+
+// personPhoneEntries lists the phone entries, in Pipedrive order, from "phones" (v2) or "phone" (v1)
+func personPhoneEntries(fields map[string]interface{}) []PipedrivePhone {
+	raw, ok := fields["phones"].([]interface{})
+	if !ok {
+		raw, _ = fields["phone"].([]interface{})
+	}
+	var entries []PipedrivePhone
+	for _, entry := range raw {
+		item, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value := strings.TrimSpace(formatLeadFieldValue(item["value"]))
+		if value == "" {
+			continue
+		}
+		primary, _ := item["primary"].(bool)
+		label, _ := item["label"].(string)
+		entries = append(entries, PipedrivePhone{Label: label, Value: value, Primary: primary})
+	}
+	return entries
+}
+
+// personPhones lists phone values, primary first, from "phones" (v2) or "phone" (v1)
+func personPhones(fields map[string]interface{}) []string {
+	var phones []string
+	for _, entry := range personPhoneEntries(fields) {
+		if entry.Primary {
+			phones = append([]string{entry.Value}, phones...)
+		} else {
+			phones = append(phones, entry.Value)
+		}
+	}
+	return phones
+}
+
+// snapshotPerson extracts the monitored fields from webhook person fields
+func (p *PipedriveService) snapshotPerson(personID int, fields map[string]interface{}) PersonSnapshot {
+	snapshot := PersonSnapshot{
+		PersonID: personID,
+		Phones:   personPhones(fields),
+		OwnerID:  intField(fields["owner_id"]),
+		SeenAt:   time.Now(),
+	}
+	if value, ok := personFieldValue(fields, p.config.DNCField); ok {
+		snapshot.DNC = p.isMarkedDNC(&PipedrivePerson{Fields: map[string]interface{}{p.config.DNCField: value}})
+	}
+	if len(p.config.PersonMonitoredFields) > 0 {
+		snapshot.Fields = make(map[string]string)
+		for _, key := range p.config.PersonMonitoredFields {
+			if value, ok := personFieldValue(fields, key); ok {
+				snapshot.Fields[key] = formatLeadFieldValue(value)
+			}
+		}
+	}
+	return snapshot
+}
+
+// previousPersonFields overlays a webhook's previous values on the current fields; v2 only
+// sends the fields that changed
+func previousPersonFields(current, previous map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for key, value := range previous {
+		if key == "custom_fields" {
+			custom := make(map[string]interface{})
+			if existing, ok := current["custom_fields"].(map[string]interface{}); ok {
+				for k, v := range existing {
+					custom[k] = v
+				}
+			}
+			if changed, ok := value.(map[string]interface{}); ok {
+				for k, v := range changed {
+					custom[k] = v
+				}
+			}
+			merged[key] = custom
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// diffPersonSnapshots lists the monitored fields that differ
+func diffPersonSnapshots(before, after PersonSnapshot) []PersonFieldChange {
+	var changes []PersonFieldChange
+	if from, to := strings.Join(before.Phones, ", "), strings.Join(after.Phones, ", "); from != to {
+		changes = append(changes, PersonFieldChange{Field: PersonFieldPhone, From: from, To: to})
+	}
+	if before.DNC != after.DNC {
+		changes = append(changes, PersonFieldChange{Field: PersonFieldDNC, From: strconv.FormatBool(before.DNC), To: strconv.FormatBool(after.DNC)})
+	}
+	if before.OwnerID != after.OwnerID {
+		changes = append(changes, PersonFieldChange{Field: PersonFieldOwner, From: strconv.Itoa(before.OwnerID), To: strconv.Itoa(after.OwnerID)})
+	}
+	keys := make([]string, 0, len(after.Fields))
+	for key := range after.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if before.Fields[key] != after.Fields[key] {
+			changes = append(changes, PersonFieldChange{Field: key, From: before.Fields[key], To: after.Fields[key]})
+		}
+	}
+	return changes
+}
+
+// ProcessPersonWebhook diffs a person update against the last snapshot and reacts to the changes
+func (p *PipedriveService) ProcessPersonWebhook(payload PipedrivePersonWebhookPayload) (*PersonDiff, error) {
+	personID := payload.personID()
+	if personID == 0 {
+		return nil, fmt.Errorf("missing person ID")
+	}
+	p.personProxy.Invalidate(personID)
+	action := strings.ToLower(payload.Meta.Action)
+	if action == "delete" || action == "deleted" {
+		p.personChanges.forget(personID)
+		p.nurtures.CancelPerson(personID, "person deleted")
+		return nil, nil
+	}
+
+	current := p.snapshotPerson(personID, payload.person())
+	previous, ok := p.personChanges.swap(current)
+	source := "snapshot"
+	if !ok {
+		if len(payload.Previous) == 0 {
+			p.debugf(SubsystemPipedrive, "First snapshot of person %d, nothing to diff", personID)
+			return nil, nil
+		}
+		previous = p.snapshotPerson(personID, previousPersonFields(payload.person(), payload.Previous))
+		source = "webhook_previous"
+	}
+	changes := diffPersonSnapshots(previous, current)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	diff := PersonDiff{PersonID: personID, At: current.SeenAt, Source: source, Changes: changes}
+	for _, change := range changes {
+		switch change.Field {
+		case PersonFieldPhone:
+			if len(current.Phones) == 0 {
+				diff.Actions = append(diff.Actions, p.holdPersonCalls(personID, "phone removed")...)
+			} else if normalized, ok := p.renormalizePersonPhone(personID, personPhoneEntries(payload.person())); ok {
+				diff.Actions = append(diff.Actions, "normalized phone to "+normalized)
+			}
+		case PersonFieldDNC:
+			if current.DNC {
+				diff.Actions = append(diff.Actions, p.holdPersonCalls(personID, "marked DNC")...)
+			}
+		case PersonFieldOwner:
+			diff.Actions = append(diff.Actions, fmt.Sprintf("owner changed from %s to %s", change.From, change.To))
+		}
+	}
+	if len(current.Phones) > 0 && !current.DNC {
+		if reason, held := p.personChanges.Held(personID); held {
+			p.personChanges.setHold(personID, "")
+			diff.Actions = append(diff.Actions, "released call hold ("+reason+")")
+		}
+	}
+
+	p.personChanges.record(diff)
+	p.logf("👤 Person %d changed: %d monitored field(s), actions: %s", personID, len(changes), strings.Join(diff.Actions, "; "))
+	return &diff, nil
+}
+
+// holdPersonCalls cancels a person's pending nurture calls and holds deferred, scheduled and
+// coalesced calls until the person is callable again
+func (p *PipedriveService) holdPersonCalls(personID int, reason string) []string {
+	p.personChanges.setHold(personID, reason)
+	actions := []string{"held pending calls (" + reason + ")"}
+	if cancelled := p.nurtures.CancelPerson(personID, reason); cancelled > 0 {
+		actions = append(actions, fmt.Sprintf("cancelled %d nurture call(s)", cancelled))
+	}
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "person_calls_held", PersonID: personID, Detail: reason})
+	return actions
+}
+
+// renormalizePersonPhone rewrites the primary phone in dialable form when PERSON_NORMALIZE_PHONES is
+// set. Pipedrive replaces the whole phone list on update, so every entry is sent back with its label.
+func (p *PipedriveService) renormalizePersonPhone(personID int, phones []PipedrivePhone) (string, bool) {
+	if !p.config.PersonNormalizePhones || len(phones) == 0 {
+		return "", false
+	}
+	primary := 0
+	for i, phone := range phones {
+		if phone.Primary {
+			primary = i
+			break
+		}
+	}
+	normalized := p.extractPhoneFromPerson(&PipedrivePerson{Phone: []PipedrivePhone{{Value: phones[primary].Value}}})
+	if normalized == "" || normalized == phones[primary].Value {
+		return "", false
+	}
+	entries := make([]map[string]interface{}, 0, len(phones))
+	for i, phone := range phones {
+		entry := map[string]interface{}{"value": phone.Value, "primary": i == primary}
+		if i == primary {
+			entry["value"] = normalized
+		}
+		if phone.Label != "" {
+			entry["label"] = phone.Label
+		}
+		entries = append(entries, entry)
+	}
+	if err := p.writePersonPhone(personID, map[string]interface{}{"phone": entries}); err != nil {
+		p.logf("⚠️ Warning: Failed to normalize phone of person %d: %v", personID, err)
+		return "", false
+	}
+	return normalized, true
+}
+
+// PipedrivePersonWebhookHandler handles Pipedrive person webhooks
+func PipedrivePersonWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
+		var payload PipedrivePersonWebhookPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		diff, err := pipedriveService.ProcessPersonWebhook(payload)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid person webhook: " + err.Error(),
+			})
+			return
+		}
+		data := gin.H{"person_id": payload.personID(), "changed": diff != nil}
+		if diff != nil {
+			data["changes"] = diff.Changes
+			data["actions"] = diff.Actions
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Pipedrive person webhook processed successfully",
+			Data:    data,
+		})
+	}
+}
+
+// PersonChangesHandler lists recent monitored person changes, optionally filtered by ?person_id=
+func PersonChangesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		personID, _ := strconv.Atoi(c.Query("person_id"))
+		diffs := pipedriveService.personChanges.Diffs(personID)
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d person changes", len(diffs)),
+			Data:    diffs,
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PersonView is the sanitized subset of a Pipedrive person served to our frontend
+type PersonView struct {
+	ID                int            `json:"id"`
+	Name              string         `json:"name"`
+	Phones            []PersonPhone  `json:"phones"`
+	LastAIInteraction *AIInteraction `json:"last_ai_interaction"`
+	FetchedAt         time.Time      `json:"fetched_at"` // When the person was read from Pipedrive
+}
+
+// PersonPhone is one of a person's phone numbers
+type PersonPhone struct {
+	Value   string `json:"value"`
+	Label   string `json:"label,omitempty"`
+	Primary bool   `json:"primary"`
+}
+
+// AIInteraction summarizes the person's most recent AI call
+type AIInteraction struct {
+	CallID    string    `json:"call_id"`
+	At        time.Time `json:"at"`     // Last event of the call
+	Status    string    `json:"status"` // Latest call event type
+	Summary   string    `json:"summary,omitempty"`
+	Sentiment string    `json:"sentiment,omitempty"`
+	Voicemail bool      `json:"voicemail"`
+}
+
+// PersonProxyCache keeps person views read from Pipedrive for PERSON_PROXY_CACHE_SECONDS
+type PersonProxyCache struct {
+	mu      sync.Mutex
+	persons map[int]PersonView
+}
+
+// NewPersonProxyCache creates an empty person view cache
+func NewPersonProxyCache() *PersonProxyCache {
+	return &PersonProxyCache{persons: make(map[int]PersonView)}
+}
+
+// get returns a cached view no older than ttl
+func (c *PersonProxyCache) get(personID int, ttl time.Duration) (PersonView, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	view, ok := c.persons[personID]
+	if !ok || time.Since(view.FetchedAt) >= ttl {
+		return PersonView{}, false
+	}
+	return view, true
+}
+
+// put caches a view
+func (c *PersonProxyCache) put(view PersonView) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.persons[view.ID] = view
+}
+
+// Invalidate drops a person's cached view, e.g. when Pipedrive reports a change
+func (c *PersonProxyCache) Invalidate(personID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.persons, personID)
+}
+
+// LatestForPerson returns the events of the person's most recent call
+func (s *CallEventStore) LatestForPerson(personID int) []CallEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var latest []CallEvent
+	for _, events := range s.events {
+		for _, event := range events {
+			if event.PersonID != personID {
+				continue
+			}
+			if latest == nil || events[len(events)-1].Seq > latest[len(latest)-1].Seq {
+				latest = events
+			}
+			break
+		}
+	}
+	return append([]CallEvent(nil), latest...)
+}
+
+// lastAIInteraction summarizes the person's most recent call from the call event log, or nil
+func (p *PipedriveService) lastAIInteraction(personID int) *AIInteraction {
+	events := p.callEvents.LatestForPerson(personID)
+	if len(events) == 0 {
+		return nil
+	}
+	last := events[len(events)-1]
+	interaction := &AIInteraction{CallID: last.CallID, At: last.Timestamp, Status: last.Type}
+	if analyzed := latestCallEvent(events, CallEventAnalyzed); analyzed != nil && analyzed.Analysis != nil {
+		analysis := analyzed.Analysis.Call.CallAnalysis
+		interaction.Summary = analysis.CallSummary
+		interaction.Sentiment = analysis.UserSentiment
+		interaction.Voicemail = analysis.InVoicemail
+	}
+	return interaction
+}
+
+// personView reads a person through the cache; the last AI interaction is always current
+func (p *PipedriveService) personView(personID int) (PersonView, bool, error) {
+	ttl := time.Duration(p.config.PersonProxyCacheSeconds) * time.Second
+	view, cached := p.personProxy.get(personID, ttl)
+	if !cached {
+		person, err := p.GetPersonByID(personID)
+		if err != nil {
+			return PersonView{}, false, err
+		}
+		view = PersonView{ID: person.ID, Name: person.Name, Phones: []PersonPhone{}, FetchedAt: time.Now().UTC()}
+		for _, phone := range person.Phone {
+			if phone.Value != "" {
+				view.Phones = append(view.Phones, PersonPhone{Value: phone.Value, Label: phone.Label, Primary: phone.Primary})
+			}
+		}
+		p.personProxy.put(view)
+	}
+	view.LastAIInteraction = p.lastAIInteraction(personID)
+	return view, cached, nil
+}
+
+// PersonProxyHandler serves a sanitized, cached subset of a Pipedrive person so the frontend never
+// needs the Pipedrive token
+func PersonProxyHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		personID, err := strconv.Atoi(c.Param("id"))
+		if err != nil || personID <= 0 {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid person ID",
+			})
+			return
+		}
+		// Don't reveal persons whose calls belong to another tenant
+		if tenant := requestTenant(c); tenant != "" && pipedriveService.personTenant(personID) != tenant {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Person not found",
+			})
+			return
+		}
+		if !pipedriveService.config.HasPipedriveConfig() {
+			c.JSON(http.StatusServiceUnavailable, WebhookResponse{
+				Success: false,
+				Message: "Pipedrive is not configured",
+			})
+			return
+		}
+
+		view, cached, err := pipedriveService.personView(personID)
+		if err != nil && strings.Contains(err.Error(), "HTTP 404") {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Person not found",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: fmt.Sprintf("Failed to read person %d from Pipedrive", personID),
+			})
+			return
+		}
+		if cached {
+			c.Header("X-Cache", "HIT")
+		} else {
+			c.Header("X-Cache", "MISS")
+		}
+		c.Header("Cache-Control", "private, max-age="+strconv.Itoa(pipedriveService.config.PersonProxyCacheSeconds))
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Person %d", personID),
+			Data:    view,
+		})
+	}
+}
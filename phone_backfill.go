@@ -0,0 +1,548 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Phone backfill job statuses
+const (
+	PhoneBackfillRunning   = "running"
+	PhoneBackfillCompleted = "completed"
+	PhoneBackfillFailed    = "failed"
+)
+
+// What the backfill did with a person
+const (
+	PhoneBackfillUpdated     = "updated"
+	PhoneBackfillWouldUpdate = "would_update" // Dry run
+	PhoneBackfillInvalid     = "invalid"      // Primary phone cannot be normalized
+	PhoneBackfillError       = "failed"       // Pipedrive refused the update
+)
+
+// ErrPhoneBackfillRunning is returned when a backfill is started while another one runs
+var ErrPhoneBackfillRunning = errors.New("a phone backfill is already running")
+
+// phoneBackfillMaxRows caps the person rows kept in a report; counters keep counting past it
+const phoneBackfillMaxRows = 5000
+
+// phoneBackfillHistory is how many finished jobs are kept for their reports
+const phoneBackfillHistory = 20
+
+// PhoneBackfillRow records a person the backfill changed or could not fix
+type PhoneBackfillRow struct {
+	PersonID int    `json:"person_id"`
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	From     string `json:"from"`
+	To       string `json:"to,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// PhoneBackfillJob is one pass over a tenant's persons and its report
+type PhoneBackfillJob struct {
+	ID          string             `json:"id"`
+	Tenant      string             `json:"tenant"`
+	FilterID    int                `json:"filter_id,omitempty"` // Saved Pipedrive filter selecting the persons
+	CountryCode string             `json:"country_code"`        // Assumed for numbers without one
+	Field       string             `json:"field,omitempty"`     // Person field receiving the E.164 number
+	FixPrimary  bool               `json:"fix_primary"`         // Main phone field rewritten in E.164
+	DryRun      bool               `json:"dry_run"`
+	Actor       string             `json:"actor"`
+	Status      string             `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	StartedAt   time.Time          `json:"started_at"`
+	FinishedAt  *time.Time         `json:"finished_at,omitempty"`
+	Scanned     int                `json:"scanned"`
+	Clean       int                `json:"clean"`    // Already in E.164
+	NoPhone     int                `json:"no_phone"` // No phone to normalize
+	Updated     int                `json:"updated"`  // Updated, or would be in a dry run
+	Invalid     int                `json:"invalid"`
+	Failed      int                `json:"failed"`
+	Rows        []PhoneBackfillRow `json:"rows,omitempty"`
+	Truncated   bool               `json:"truncated,omitempty"` // More rows than phoneBackfillMaxRows
+}
+
+// PhoneBackfiller runs phone backfill jobs one at a time and keeps their reports
+type PhoneBackfiller struct {
+	mu   sync.Mutex
+	jobs []*PhoneBackfillJob // Oldest first
+}
+
+// NewPhoneBackfiller creates an empty phone backfiller
+func NewPhoneBackfiller() *PhoneBackfiller {
+	return &PhoneBackfiller{}
+}
+
+// start records a new job, refusing while another one is running
+func (b *PhoneBackfiller) start(job *PhoneBackfillJob) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, existing := range b.jobs {
+		if existing.Status == PhoneBackfillRunning {
+			return fmt.Errorf("%w (%s)", ErrPhoneBackfillRunning, existing.ID)
+		}
+	}
+	b.jobs = append(b.jobs, job)
+	if len(b.jobs) > phoneBackfillHistory {
+		b.jobs = b.jobs[len(b.jobs)-phoneBackfillHistory:]
+	}
+	return nil
+}
+
+// update changes a job under the lock so reports can be read while it runs
+func (b *PhoneBackfiller) update(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fn()
+}
+
+// Get returns a copy of a job and its report
+func (b *PhoneBackfiller) Get(id string) (PhoneBackfillJob, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, job := range b.jobs {
+		if job.ID == id {
+			copied := *job
+			copied.Rows = append([]PhoneBackfillRow(nil), job.Rows...)
+			return copied, true
+		}
+	}
+	return PhoneBackfillJob{}, false
+}
+
+// List returns the jobs, newest first, without their rows
+func (b *PhoneBackfiller) List() []PhoneBackfillJob {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	jobs := make([]PhoneBackfillJob, 0, len(b.jobs))
+	for i := len(b.jobs) - 1; i >= 0; i-- {
+		job := *b.jobs[i]
+		job.Rows = nil
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// addRow appends a report row; callers must hold mu
+func (j *PhoneBackfillJob) addRow(row PhoneBackfillRow) {
+	if len(j.Rows) >= phoneBackfillMaxRows {
+		j.Truncated = true
+		return
+	}
+	j.Rows = append(j.Rows, row)
+}
+
+// PhoneCountryCodeFor returns the calling code assumed for a tenant's numbers without one
+func (c *Config) PhoneCountryCodeFor(tenant string) string {
+	if code, ok := c.TenantPhoneCountryCodes[tenant]; ok && code != "" {
+		return code
+	}
+	return c.PhoneCountryCode
+}
+
+// loadTenantPhoneCountryCodes parses TENANT_PHONE_COUNTRY_CODES (JSON: tenant -> calling code)
+func loadTenantPhoneCountryCodes(raw string) map[string]string {
+	codes := make(map[string]string)
+	if raw == "" {
+		return codes
+	}
+	if err := json.Unmarshal([]byte(raw), &codes); err != nil {
+		log.Printf("⚠️ Invalid TENANT_PHONE_COUNTRY_CODES, ignoring: %v", err)
+		return make(map[string]string)
+	}
+	for tenant, code := range codes {
+		codes[tenant] = strings.TrimPrefix(code, "+")
+	}
+	return codes
+}
+
+// primaryPhone returns the person's primary phone as typed, or the first one when none is flagged
+func primaryPhone(person *PipedrivePerson) string {
+	first := ""
+	for _, phone := range person.Phone {
+		if strings.TrimSpace(phone.Value) == "" {
+			continue
+		}
+		if phone.Primary {
+			return phone.Value
+		}
+		if first == "" {
+			first = phone.Value
+		}
+	}
+	return first
+}
+
+// backfilledPhone returns the number the phone backfill wrote to PHONE_BACKFILL_FIELD while it
+// still is the person's primary phone in E.164 under one of the configured calling codes; a
+// primary phone edited since the backfill wins over the stale copy
+func (c *Config) backfilledPhone(person *PipedrivePerson) string {
+	if c.PhoneBackfillField == "" {
+		return ""
+	}
+	backfilled := formatLeadFieldValue(person.Fields[c.PhoneBackfillField])
+	primary := primaryPhone(person)
+	if !strings.HasPrefix(backfilled, "+") || primary == "" {
+		return ""
+	}
+	codes := []string{c.PhoneCountryCode}
+	for _, code := range c.TenantPhoneCountryCodes {
+		codes = append(codes, code)
+	}
+	for _, code := range codes {
+		if normalized, reason := normalizeE164(primary, code); reason == "" && normalized == backfilled {
+			return backfilled
+		}
+	}
+	return ""
+}
+
+// normalizeE164 rewrites a phone number as typed into a CRM in E.164 form, assuming countryCode for
+// national numbers. Extensions are dropped; numbers that cannot be dialed return a reason instead.
+func normalizeE164(raw, countryCode string) (string, string) {
+	number := strings.TrimSpace(raw)
+	lower := strings.ToLower(number)
+	for _, marker := range []string{"ext", "x", "#", ";"} {
+		if i := strings.Index(lower, marker); i > 0 {
+			number, lower = number[:i], lower[:i]
+		}
+	}
+	number = strings.ReplaceAll(number, "(0)", "")
+
+	international := false
+	var digits strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			international = true
+		case strings.ContainsRune(" -./()\t\u00a0", r): // Separators, including non-breaking spaces from pasted numbers
+		default:
+			return "", fmt.Sprintf("unexpected character %q", r)
+		}
+	}
+	national := digits.String()
+	if national == "" {
+		return "", "no digits"
+	}
+
+	e164 := national
+	switch {
+	case international:
+	case strings.HasPrefix(national, "00"):
+		e164 = national[2:]
+	case countryCode == "1" && strings.HasPrefix(national, "011"):
+		e164 = national[3:]
+	case countryCode == "":
+		return "", "no country code and no default set"
+	case countryCode == "1" && len(national) == 11 && national[0] == '1':
+	case countryCode != "1" && strings.HasPrefix(national, countryCode) && len(national) > 10:
+	default:
+		e164 = countryCode + strings.TrimPrefix(national, "0")
+	}
+
+	switch {
+	case e164 == "" || e164[0] == '0':
+		return "", "invalid country code"
+	case len(e164) < 8:
+		return "", "too short"
+	case len(e164) > 15:
+		return "", "too long"
+	case strings.HasPrefix(e164, "1") && len(e164) != 11:
+		return "", "North American numbers have 10 digits"
+	}
+	return "+" + e164, ""
+}
+
+// StartPhoneBackfill starts a job normalizing the phones of every person, or of the persons in a saved
+// filter, in the background
+func (p *PipedriveService) StartPhoneBackfill(tenant, actor string, filterID int, dryRun bool) (PhoneBackfillJob, error) {
+	if !p.config.HasPipedriveConfig() {
+		return PhoneBackfillJob{}, fmt.Errorf("Pipedrive is not configured")
+	}
+	if p.config.PhoneBackfillField == "" && !p.config.PhoneBackfillFixPrimary {
+		return PhoneBackfillJob{}, fmt.Errorf("set PHONE_BACKFILL_FIELD or PHONE_BACKFILL_FIX_PRIMARY to choose where normalized numbers go")
+	}
+	job := &PhoneBackfillJob{
+		ID:          newProcessingID(),
+		Tenant:      tenant,
+		FilterID:    filterID,
+		CountryCode: p.config.PhoneCountryCodeFor(tenant),
+		Field:       p.config.PhoneBackfillField,
+		FixPrimary:  p.config.PhoneBackfillFixPrimary,
+		DryRun:      dryRun,
+		Actor:       actor,
+		Status:      PhoneBackfillRunning,
+		StartedAt:   time.Now(),
+	}
+	if err := p.phoneBackfills.start(job); err != nil {
+		return PhoneBackfillJob{}, err
+	}
+	p.auditLog.Append(AuditEntry{Actor: actor, Action: "phone_backfill_started", Detail: fmt.Sprintf("job %s, tenant %s, dry run %t", job.ID, tenant, dryRun)})
+	p.logf("📞 Phone backfill %s started for tenant %s (dry run: %t)", job.ID, tenant, dryRun)
+
+	started, _ := p.phoneBackfills.Get(job.ID)
+	go p.runPhoneBackfill(job)
+	return started, nil
+}
+
+// runPhoneBackfill pages through the persons and normalizes each one's phones
+func (p *PipedriveService) runPhoneBackfill(job *PhoneBackfillJob) {
+	endpoint := "/persons"
+	if job.FilterID != 0 {
+		endpoint = fmt.Sprintf("/persons?filter_id=%d", job.FilterID)
+	}
+	err := p.eachPipedrivePage(endpoint, func(items []json.RawMessage) error {
+		for _, item := range items {
+			var person PipedrivePerson
+			if err := json.Unmarshal(item, &person); err != nil {
+				return fmt.Errorf("failed to parse person: %v", err)
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(item, &fields); err == nil {
+				person.Fields = fields
+			}
+			p.backfillPersonPhone(job, &person)
+		}
+		return nil
+	})
+
+	p.phoneBackfills.update(func() {
+		finished := time.Now()
+		job.FinishedAt = &finished
+		job.Status = PhoneBackfillCompleted
+		if err != nil {
+			job.Status = PhoneBackfillFailed
+			job.Error = err.Error()
+		}
+	})
+	report, _ := p.phoneBackfills.Get(job.ID)
+	p.auditLog.Append(AuditEntry{Actor: job.Actor, Action: "phone_backfill_finished", Detail: fmt.Sprintf("job %s %s: %d scanned, %d updated, %d invalid, %d failed", job.ID, report.Status, report.Scanned, report.Updated, report.Invalid, report.Failed)})
+	if err != nil {
+		p.logf("❌ Phone backfill %s stopped after %d persons: %v", job.ID, report.Scanned, err)
+		return
+	}
+	p.logf("✅ Phone backfill %s finished: %d scanned, %d updated, %d invalid, %d failed", job.ID, report.Scanned, report.Updated, report.Invalid, report.Failed)
+}
+
+// backfillPersonPhone writes a person's primary phone in E.164 to the backfill field and, with
+// PHONE_BACKFILL_FIX_PRIMARY, rewrites the main phone field, recording the outcome in the job
+func (p *PipedriveService) backfillPersonPhone(job *PhoneBackfillJob, person *PipedrivePerson) {
+	var phones []PipedrivePhone
+	primary := -1
+	for _, phone := range person.Phone {
+		if strings.TrimSpace(phone.Value) == "" {
+			continue
+		}
+		if phone.Primary && primary < 0 {
+			primary = len(phones)
+		}
+		phones = append(phones, phone)
+	}
+	if len(phones) == 0 {
+		p.phoneBackfills.update(func() {
+			job.Scanned++
+			job.NoPhone++
+		})
+		return
+	}
+	if primary < 0 {
+		primary = 0
+	}
+
+	e164, reason := normalizeE164(phones[primary].Value, job.CountryCode)
+	if reason != "" {
+		p.phoneBackfills.update(func() {
+			job.Scanned++
+			job.Invalid++
+			job.addRow(PhoneBackfillRow{PersonID: person.ID, Name: person.Name, Action: PhoneBackfillInvalid, From: phones[primary].Value, Reason: reason})
+		})
+		return
+	}
+
+	update := map[string]interface{}{}
+	if job.Field != "" && formatLeadFieldValue(person.Fields[job.Field]) != e164 {
+		update[job.Field] = e164
+	}
+	if job.FixPrimary {
+		fixed := make([]map[string]interface{}, 0, len(phones))
+		changed := false
+		for i, phone := range phones {
+			value := phone.Value
+			if normalized, reason := normalizeE164(phone.Value, job.CountryCode); reason == "" {
+				changed = changed || normalized != phone.Value
+				value = normalized
+			}
+			entry := map[string]interface{}{"value": value, "primary": i == primary}
+			if phone.Label != "" {
+				entry["label"] = phone.Label
+			}
+			fixed = append(fixed, entry)
+		}
+		if changed {
+			update["phone"] = fixed
+		}
+	}
+	if len(update) == 0 {
+		p.phoneBackfills.update(func() {
+			job.Scanned++
+			job.Clean++
+		})
+		return
+	}
+
+	row := PhoneBackfillRow{PersonID: person.ID, Name: person.Name, Action: PhoneBackfillWouldUpdate, From: phones[primary].Value, To: e164}
+	if !job.DryRun {
+		row.Action = PhoneBackfillUpdated
+		if err := p.writePersonPhone(person.ID, update); err != nil {
+			row.Action = PhoneBackfillError
+			row.Reason = err.Error()
+		} else {
+			p.auditLog.Append(AuditEntry{Actor: job.Actor, Action: "person_phone_normalized", PersonID: person.ID, Detail: fmt.Sprintf("%s -> %s (backfill %s)", row.From, e164, job.ID)})
+		}
+	}
+	p.phoneBackfills.update(func() {
+		job.Scanned++
+		if row.Action == PhoneBackfillError {
+			job.Failed++
+		} else {
+			job.Updated++
+		}
+		job.addRow(row)
+	})
+}
+
+// writePersonPhone updates a person's phone fields
+func (p *PipedriveService) writePersonPhone(personID int, update map[string]interface{}) error {
+	resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", personID), update)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// StartPhoneBackfillHandler starts a phone backfill ({"tenant", "filter_id", "dry_run"}). Persons are
+// not tied to a tenant in Pipedrive, so tenant-scoped admins can't run one
+func StartPhoneBackfillHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Tenant   string `json:"tenant"`
+			FilterID int    `json:"filter_id"`
+			DryRun   bool   `json:"dry_run"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "Invalid JSON payload",
+				})
+				return
+			}
+		}
+		if requestTenant(c) != "" {
+			c.JSON(http.StatusForbidden, WebhookResponse{
+				Success: false,
+				Message: "A phone backfill updates every tenant's persons and needs an admin who isn't tenant-scoped",
+			})
+			return
+		}
+		tenant := request.Tenant
+		if tenant == "" {
+			tenant = DefaultTenant
+		}
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+
+		job, err := pipedriveService.StartPhoneBackfill(tenant, actor, request.FilterID, request.DryRun)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrPhoneBackfillRunning) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, WebhookResponse{
+				Success: false,
+				Message: "Cannot start phone backfill: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusAccepted, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Phone backfill %s started for tenant %s", job.ID, tenant),
+			Data:    job,
+		})
+	}
+}
+
+// ListPhoneBackfillsHandler lists recent phone backfills without their rows
+func ListPhoneBackfillsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs := pipedriveService.phoneBackfills.List()
+		if tenant := requestTenant(c); tenant != "" {
+			scoped := jobs[:0]
+			for _, job := range jobs {
+				if job.Tenant == tenant {
+					scoped = append(scoped, job)
+				}
+			}
+			jobs = scoped
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d phone backfills", len(jobs)),
+			Data:    jobs,
+		})
+	}
+}
+
+// PhoneBackfillReportHandler returns a phone backfill's progress and report, as CSV with ?format=csv
+func PhoneBackfillReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := pipedriveService.phoneBackfills.Get(c.Param("id"))
+		if tenant := requestTenant(c); ok && tenant != "" && job.Tenant != tenant {
+			ok = false
+		}
+		if !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Phone backfill not found",
+			})
+			return
+		}
+		if c.Query("format") != "csv" {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: fmt.Sprintf("Phone backfill %s %s: %d scanned, %d updated, %d invalid, %d failed", job.ID, job.Status, job.Scanned, job.Updated, job.Invalid, job.Failed),
+				Data:    job,
+			})
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="phone-backfill-%s.csv"`, job.ID))
+		c.Status(http.StatusOK)
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"person_id", "name", "action", "from", "to", "reason"})
+		for _, row := range job.Rows {
+			writer.Write([]string{strconv.Itoa(row.PersonID), row.Name, row.Action, row.From, row.To, row.Reason})
+		}
+		writer.Flush()
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Line types reported by phone lookups
+const (
+	LineTypeMobile   = "mobile"
+	LineTypeLandline = "landline"
+	LineTypeVoIP     = "voip"
+	LineTypeUnknown  = "unknown"
+)
+
+// PhoneLookupResult holds carrier lookup data for a phone number
+type PhoneLookupResult struct {
+	PhoneNumber string    `json:"phone_number"`
+	Valid       bool      `json:"valid"`
+	LineType    string    `json:"line_type"`
+	Carrier     string    `json:"carrier,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// VoiceEligible returns true if the number should stay in voice campaigns
+func (r PhoneLookupResult) VoiceEligible() bool {
+	return r.Valid && r.LineType != LineTypeLandline
+}
+
+// SMSCapable returns true if the number can be used for SMS fallback
+func (r PhoneLookupResult) SMSCapable() bool {
+	return r.Valid && r.LineType == LineTypeMobile
+}
+
+// PhoneLookupProvider looks up carrier and line-type information for a number
+type PhoneLookupProvider interface {
+	Lookup(phoneNumber string) (*PhoneLookupResult, error)
+}
+
+// TwilioLookupProvider uses Twilio Lookup v2 with line type intelligence
+type TwilioLookupProvider struct {
+	accountSID string
+	authToken  string
+	httpClient *http.Client
+}
+
+// Lookup queries Twilio Lookup for a phone number
+func (t *TwilioLookupProvider) Lookup(phoneNumber string) (*PhoneLookupResult, error) {
+	endpoint := fmt.Sprintf("https://lookups.twilio.com/v2/PhoneNumbers/%s?Fields=line_type_intelligence", url.PathEscape(phoneNumber))
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make lookup request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode == 404 {
+		return &PhoneLookupResult{PhoneNumber: phoneNumber, Valid: false, LineType: LineTypeUnknown, CheckedAt: time.Now()}, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("lookup failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		PhoneNumber          string `json:"phone_number"`
+		Valid                bool   `json:"valid"`
+		LineTypeIntelligence struct {
+			Type        string `json:"type"`
+			CarrierName string `json:"carrier_name"`
+		} `json:"line_type_intelligence"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse lookup response: %v", err)
+	}
+
+	lineType := LineTypeUnknown
+	switch result.LineTypeIntelligence.Type {
+	case "mobile":
+		lineType = LineTypeMobile
+	case "landline":
+		lineType = LineTypeLandline
+	case "nonFixedVoip", "fixedVoip":
+		lineType = LineTypeVoIP
+	}
+
+	return &PhoneLookupResult{
+		PhoneNumber: phoneNumber,
+		Valid:       result.Valid,
+		LineType:    lineType,
+		Carrier:     result.LineTypeIntelligence.CarrierName,
+		CheckedAt:   time.Now(),
+	}, nil
+}
+
+// PhoneValidator runs lookups and remembers the line type per number
+type PhoneValidator struct {
+	provider PhoneLookupProvider
+	mu       sync.Mutex
+	results  map[string]PhoneLookupResult // Maps phone number to last lookup
+}
+
+// newPhoneValidator returns a validator, or nil if no lookup provider is configured
+func newPhoneValidator(config *Config, httpClient *http.Client) *PhoneValidator {
+	if config.PhoneLookupProvider != "twilio" {
+		return nil
+	}
+	if config.TwilioAccountSID == "" || config.TwilioAuthToken == "" {
+		log.Printf("⚠️ PHONE_LOOKUP_PROVIDER=twilio requires TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN")
+		return nil
+	}
+	return &PhoneValidator{
+		provider: &TwilioLookupProvider{
+			accountSID: config.TwilioAccountSID,
+			authToken:  config.TwilioAuthToken,
+			httpClient: httpClient,
+		},
+		results: make(map[string]PhoneLookupResult),
+	}
+}
+
+// Validate returns the lookup result for a number, using the stored result when available
+func (v *PhoneValidator) Validate(phoneNumber string) (PhoneLookupResult, error) {
+	v.mu.Lock()
+	cached, ok := v.results[phoneNumber]
+	v.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	result, err := v.provider.Lookup(phoneNumber)
+	if err != nil {
+		return PhoneLookupResult{}, err
+	}
+
+	v.mu.Lock()
+	v.results[phoneNumber] = *result
+	v.mu.Unlock()
+	return *result, nil
+}
+
+// LineType returns the stored line type for a number, if it has been looked up
+func (v *PhoneValidator) LineType(phoneNumber string) (string, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	result, ok := v.results[phoneNumber]
+	return result.LineType, ok
+}
+
+// storeLineType writes the line type into the configured Pipedrive person field
+func (p *PipedriveService) storeLineType(personID int, lineType string) {
+	if p.config.PhoneLineTypeField == "" || !p.config.HasPipedriveConfig() {
+		return
+	}
+	resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", personID), map[string]interface{}{
+		p.config.PhoneLineTypeField: lineType,
+	})
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to store line type for person %d: %v", personID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// PhoneValidationRequest is the body for bulk phone validation
+type PhoneValidationRequest struct {
+	Phones    []string `json:"phones"`
+	PersonIDs []int    `json:"person_ids"`
+}
+
+// PhoneValidationHandler runs a bulk pre-flight lookup and splits numbers into voice, SMS and dropped lists
+func PhoneValidationHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveService.phoneValidator == nil {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Phone lookup not configured",
+			})
+			return
+		}
+
+		var request PhoneValidationRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		type target struct {
+			phone    string
+			personID int
+		}
+		var targets []target
+		for _, phone := range request.Phones {
+			targets = append(targets, target{phone: phone})
+		}
+		for _, personID := range request.PersonIDs {
+			person, err := pipedriveService.GetPersonByID(personID)
+			if err != nil {
+				log.Printf("⚠️ Skipping person %d: %v", personID, err)
+				continue
+			}
+			if phone := pipedriveService.extractPhoneFromPerson(person); phone != "" {
+				targets = append(targets, target{phone: phone, personID: personID})
+			}
+		}
+
+		voice := []PhoneLookupResult{}
+		sms := []PhoneLookupResult{}
+		dropped := []PhoneLookupResult{}
+		var failed []string
+
+		for _, t := range targets {
+			result, err := pipedriveService.phoneValidator.Validate(t.phone)
+			if err != nil {
+				log.Printf("❌ Lookup failed for %s: %v", t.phone, err)
+				failed = append(failed, t.phone)
+				continue
+			}
+			if t.personID != 0 {
+				pipedriveService.storeLineType(t.personID, result.LineType)
+			}
+			if result.VoiceEligible() {
+				voice = append(voice, result)
+			} else {
+				dropped = append(dropped, result)
+			}
+			if result.SMSCapable() {
+				sms = append(sms, result)
+			}
+		}
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Validated %d numbers", len(targets)-len(failed)),
+			Data: gin.H{
+				"voice":   voice,
+				"sms":     sms,
+				"dropped": dropped,
+				"failed":  failed,
+			},
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Pipedrive activity API versions
+const (
+	PipedriveActivityAPIV1 = "v1"
+	PipedriveActivityAPIV2 = "v2" // participants/attendees shapes, owner_id, cursor pagination
+)
+
+// pipedriveV2Prefix marks endpoints served from the API v2 root instead of PIPEDRIVE_BASE_URL
+const pipedriveV2Prefix = "/api/v2"
+
+// normalizeActivityAPIVersion validates PIPEDRIVE_ACTIVITY_API
+func normalizeActivityAPIVersion(version string) string {
+	switch strings.ToLower(strings.TrimSpace(version)) {
+	case "", PipedriveActivityAPIV1:
+		return PipedriveActivityAPIV1
+	case PipedriveActivityAPIV2:
+		return PipedriveActivityAPIV2
+	}
+	log.Printf("⚠️ Invalid PIPEDRIVE_ACTIVITY_API %q, using %s", version, PipedriveActivityAPIV1)
+	return PipedriveActivityAPIV1
+}
+
+// pipedriveURL returns the full URL of an endpoint; /api/v2 endpoints are resolved against the
+// API root of PIPEDRIVE_BASE_URL (https://api.pipedrive.com/v1 -> https://api.pipedrive.com/api/v2)
+func (c *Config) pipedriveURL(endpoint string) string {
+	if !strings.HasPrefix(endpoint, pipedriveV2Prefix+"/") {
+		return c.PipedriveBaseURL + endpoint
+	}
+	root := strings.TrimSuffix(c.PipedriveBaseURL, "/")
+	root = strings.TrimSuffix(root, "/v1")
+	root = strings.TrimSuffix(root, "/api")
+	return root + endpoint
+}
+
+// activityV2Body translates an activity built in the v1 shape into the v2 request shape:
+// user_id -> owner_id, person_id -> primary participant, attendee email_address -> email,
+// done 0/1 -> boolean, busy_flag -> busy, location -> {value}, and HH:MM times
+func activityV2Body(data map[string]interface{}) map[string]interface{} {
+	body := make(map[string]interface{}, len(data))
+	var participants []map[string]interface{}
+	for key, value := range data {
+		switch key {
+		case "user_id":
+			body["owner_id"] = value
+		case "person_id":
+			participants = append([]map[string]interface{}{{"person_id": value, "primary": true}}, participants...)
+		case "participants":
+			for _, participant := range activityMaps(value) {
+				entry := map[string]interface{}{"person_id": participant["person_id"], "primary": false}
+				if primary, ok := participant["primary_flag"]; ok {
+					entry["primary"] = primary
+				} else if primary, ok := participant["primary"]; ok {
+					entry["primary"] = primary
+				}
+				participants = append(participants, entry)
+			}
+		case "attendees":
+			var attendees []map[string]interface{}
+			for _, attendee := range activityMaps(value) {
+				entry := make(map[string]interface{}, len(attendee))
+				for field, v := range attendee {
+					if field == "email_address" {
+						field = "email"
+					}
+					entry[field] = v
+				}
+				attendees = append(attendees, entry)
+			}
+			body["attendees"] = attendees
+		case "done":
+			body["done"] = activityFlag(value)
+		case "busy_flag":
+			body["busy"] = activityFlag(value)
+		case "location":
+			if location, ok := value.(string); ok {
+				body["location"] = map[string]interface{}{"value": location}
+			} else {
+				body["location"] = value
+			}
+		case "due_time", "duration":
+			if text, ok := value.(string); ok && len(text) == len("15:04:05") {
+				value = text[:len("15:04")]
+			}
+			body[key] = value
+		default:
+			body[key] = value
+		}
+	}
+	if len(participants) > 0 {
+		// The person given as person_id stays the only primary participant
+		_, hasPerson := data["person_id"]
+		seen := make(map[string]bool)
+		unique := participants[:0]
+		for i, participant := range participants {
+			id := fmt.Sprint(participant["person_id"])
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if hasPerson && i > 0 {
+				participant["primary"] = false
+			}
+			unique = append(unique, participant)
+		}
+		body["participants"] = unique
+	}
+	return body
+}
+
+// activityMaps reads a participants/attendees value built as []map or decoded from JSON
+func activityMaps(value interface{}) []map[string]interface{} {
+	switch entries := value.(type) {
+	case []map[string]interface{}:
+		return entries
+	case []interface{}:
+		maps := make([]map[string]interface{}, 0, len(entries))
+		for _, entry := range entries {
+			if m, ok := entry.(map[string]interface{}); ok {
+				maps = append(maps, m)
+			}
+		}
+		return maps
+	}
+	return nil
+}
+
+// activityFlag converts 0/1 or boolean flags to a boolean
+func activityFlag(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v == "1" || strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// createActivity creates a Pipedrive activity from data in the v1 shape, translating it for
+// PIPEDRIVE_ACTIVITY_API=v2; callers decode the response the same way for both versions
+func (p *PipedriveService) createActivity(data map[string]interface{}) (*http.Response, error) {
+	if p.config.PipedriveActivityAPI != PipedriveActivityAPIV2 {
+		return p.makePipedriveRequest("POST", "/activities", data)
+	}
+	return p.makePipedriveRequest("POST", pipedriveV2Prefix+"/activities", activityV2Body(data))
+}
+
+// updateActivity updates fields of an activity given in the v1 shape
+func (p *PipedriveService) updateActivity(activityID int, data map[string]interface{}) error {
+	method, endpoint := "PUT", fmt.Sprintf("/activities/%d", activityID)
+	if p.config.PipedriveActivityAPI == PipedriveActivityAPIV2 {
+		method, endpoint, data = "PATCH", fmt.Sprintf("%s/activities/%d", pipedriveV2Prefix, activityID), activityV2Body(data)
+	}
+	resp, err := p.makePipedriveRequest(method, endpoint, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update activity %d: HTTP %d", activityID, resp.StatusCode)
+	}
+	return nil
+}
+
+// deleteActivity deletes an activity
+func (p *PipedriveService) deleteActivity(activityID int) error {
+	endpoint := fmt.Sprintf("/activities/%d", activityID)
+	if p.config.PipedriveActivityAPI == PipedriveActivityAPIV2 {
+		endpoint = fmt.Sprintf("%s/activities/%d", pipedriveV2Prefix, activityID)
+	}
+	resp, err := p.makePipedriveRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete activity %d: HTTP %d", activityID, resp.StatusCode)
+	}
+	return nil
+}
+
+// pipedriveActivityV2 is an activity as returned by API v2
+type pipedriveActivityV2 struct {
+	ID           int    `json:"id"`
+	Subject      string `json:"subject"`
+	Type         string `json:"type"`
+	Done         bool   `json:"done"`
+	DueDate      string `json:"due_date"`
+	DueTime      string `json:"due_time"`
+	PersonID     int    `json:"person_id"`
+	DealID       int    `json:"deal_id"`
+	OwnerID      int    `json:"owner_id"`
+	AddTime      string `json:"add_time"` // RFC 3339
+	Note         string `json:"note"`
+	Duration     string `json:"duration"`
+	Participants []struct {
+		PersonID int  `json:"person_id"`
+		Primary  bool `json:"primary"`
+	} `json:"participants"`
+}
+
+// parseActivity decodes a listed activity into the v1 shape the rest of the service reads
+func parseActivity(item json.RawMessage, version string) (PipedriveActivity, error) {
+	if version != PipedriveActivityAPIV2 {
+		var activity PipedriveActivity
+		err := json.Unmarshal(item, &activity)
+		return activity, err
+	}
+	var v2 pipedriveActivityV2
+	if err := json.Unmarshal(item, &v2); err != nil {
+		return PipedriveActivity{}, err
+	}
+	activity := PipedriveActivity{
+		ID:       v2.ID,
+		Subject:  v2.Subject,
+		Type:     v2.Type,
+		Done:     v2.Done,
+		DueDate:  v2.DueDate,
+		DueTime:  v2.DueTime,
+		PersonID: v2.PersonID,
+		DealID:   v2.DealID,
+		UserID:   v2.OwnerID,
+		AddTime:  v2.AddTime,
+		Note:     v2.Note,
+		Duration: v2.Duration,
+	}
+	for _, participant := range v2.Participants {
+		if activity.PersonID == 0 || participant.Primary {
+			activity.PersonID = participant.PersonID
+		}
+	}
+	if added, err := ParseTimestamp(v2.AddTime, time.UTC); err == nil {
+		activity.AddTime = added.UTC.Format("2006-01-02 15:04:05")
+	}
+	return activity, nil
+}
+
+// listActivities lists activities linked to a person or deal ("person_id" or "deal_id")
+func (p *PipedriveService) listActivities(field string, id int) ([]PipedriveActivity, error) {
+	version := p.config.PipedriveActivityAPI
+	endpoint := fmt.Sprintf("%s/activities?%s=%d", pipedriveV2Prefix, field, id)
+	if version != PipedriveActivityAPIV2 {
+		endpoint = fmt.Sprintf("/%ss/%d/activities", strings.TrimSuffix(field, "_id"), id)
+	}
+	return p.listActivitiesAt(endpoint, version)
+}
+
+// listActivitiesAt lists the activities of a list endpoint in the v1 shape
+func (p *PipedriveService) listActivitiesAt(endpoint, version string) ([]PipedriveActivity, error) {
+	items, err := p.listPipedriveItems(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	activities := make([]PipedriveActivity, 0, len(items))
+	for _, item := range items {
+		activity, err := parseActivity(item, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse activity: %v", err)
+		}
+		activities = append(activities, activity)
+	}
+	return activities, nil
+}
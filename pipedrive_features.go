@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// entitlementHints mark a 403 as a plan or OAuth scope restriction rather than a per-record permission
+var entitlementHints = []string{"scope", "plan", "feature", "upgrade", "subscription"}
+
+// PipedriveFeatureStatus describes a Pipedrive endpoint disabled because the account cannot use it
+type PipedriveFeatureStatus struct {
+	Tenant     string    `json:"tenant"`
+	Feature    string    `json:"feature"`  // Endpoint collection, e.g. leads
+	Endpoint   string    `json:"endpoint"` // Method and path that was refused, record IDs as :id
+	Status     int       `json:"status"`   // 402 or 403
+	Reason     string    `json:"reason"`   // Pipedrive's error message
+	DisabledAt time.Time `json:"disabled_at"`
+	RetryAt    time.Time `json:"retry_at"` // When one request is let through to check again
+	Blocked    int       `json:"blocked"`  // Requests skipped while disabled
+}
+
+// PipedriveFeatureError is returned instead of calling an endpoint the account cannot use
+type PipedriveFeatureError struct {
+	Endpoint string
+	Status   int
+	Reason   string
+}
+
+func (e *PipedriveFeatureError) Error() string {
+	return fmt.Sprintf("Pipedrive %s is disabled for this account (HTTP %d: %s)", e.Endpoint, e.Status, e.Reason)
+}
+
+// PipedriveFeatureGate stops requests to endpoints that returned payment-required or scope errors.
+// Endpoints are gated per tenant, so one tenant's refusal does not pause the others.
+type PipedriveFeatureGate struct {
+	mu       sync.Mutex
+	retry    time.Duration
+	disabled map[string]*PipedriveFeatureStatus // Keyed by tenant and endpoint
+}
+
+// NewPipedriveFeatureGate creates a gate that re-checks disabled features after PIPEDRIVE_FEATURE_RETRY_HOURS
+func NewPipedriveFeatureGate(config *Config) *PipedriveFeatureGate {
+	return &PipedriveFeatureGate{
+		retry:    time.Duration(config.PipedriveFeatureRetryHours) * time.Hour,
+		disabled: make(map[string]*PipedriveFeatureStatus),
+	}
+}
+
+// pipedriveFeature returns the feature an endpoint belongs to, e.g. /leads/abc -> leads
+func pipedriveFeature(endpoint string) string {
+	path := strings.TrimPrefix(strings.SplitN(endpoint, "?", 2)[0], pipedriveV2Prefix)
+	return strings.SplitN(strings.Trim(path, "/"), "/", 2)[0]
+}
+
+// pipedriveEndpoint returns the method and path of a request with record IDs replaced, e.g.
+// GET /persons/12/deals -> GET persons/:id/deals, so refusals of one record don't gate the endpoint twice
+func pipedriveEndpoint(method, endpoint string) string {
+	path := strings.TrimPrefix(strings.SplitN(endpoint, "?", 2)[0], pipedriveV2Prefix)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if i > 0 && isRecordID(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
+
+// isRecordID reports whether a path segment is a numeric ID or a UUID (lead IDs)
+func isRecordID(segment string) bool {
+	if _, err := strconv.Atoi(segment); err == nil {
+		return true
+	}
+	return len(segment) == 36 && strings.Count(segment, "-") == 4
+}
+
+// featureKey keys a tenant's endpoint in the gate
+func featureKey(tenant, endpoint string) string {
+	return tenant + " " + endpoint
+}
+
+// check returns an error while the tenant's endpoint is disabled; once RetryAt passes one request
+// is let through and the next retry is scheduled
+func (g *PipedriveFeatureGate) check(tenant, endpoint string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	status, ok := g.disabled[featureKey(tenant, endpoint)]
+	if !ok {
+		return nil
+	}
+	if now := time.Now(); g.retry > 0 && now.After(status.RetryAt) {
+		status.RetryAt = now.Add(g.retry)
+		return nil
+	}
+	status.Blocked++
+	return &PipedriveFeatureError{Endpoint: endpoint, Status: status.Status, Reason: status.Reason}
+}
+
+// disable marks the tenant's endpoint unavailable, reporting whether it was newly disabled
+func (g *PipedriveFeatureGate) disable(tenant, endpoint string, status int, reason string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := featureKey(tenant, endpoint)
+	if _, ok := g.disabled[key]; ok {
+		return false
+	}
+	now := time.Now()
+	g.disabled[key] = &PipedriveFeatureStatus{
+		Tenant:     tenant,
+		Feature:    pipedriveFeature(strings.SplitN(endpoint, " ", 2)[1]),
+		Endpoint:   endpoint,
+		Status:     status,
+		Reason:     reason,
+		DisabledAt: now,
+		RetryAt:    now.Add(g.retry),
+	}
+	return true
+}
+
+// enable clears the tenant's disabled endpoint, reporting whether it had been disabled
+func (g *PipedriveFeatureGate) enable(tenant, endpoint string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := featureKey(tenant, endpoint)
+	if _, ok := g.disabled[key]; !ok {
+		return false
+	}
+	delete(g.disabled, key)
+	return true
+}
+
+// enableFeature clears every disabled endpoint of a feature, for one tenant or all when tenant is
+// empty, and returns how many it cleared
+func (g *PipedriveFeatureGate) enableFeature(tenant, feature string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	cleared := 0
+	for key, status := range g.disabled {
+		if status.Feature == feature && (tenant == "" || status.Tenant == tenant) {
+			delete(g.disabled, key)
+			cleared++
+		}
+	}
+	return cleared
+}
+
+// Disabled lists the disabled endpoints by tenant and endpoint
+func (g *PipedriveFeatureGate) Disabled() []PipedriveFeatureStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	features := make([]PipedriveFeatureStatus, 0, len(g.disabled))
+	for _, status := range g.disabled {
+		features = append(features, *status)
+	}
+	sort.Slice(features, func(i, j int) bool {
+		return featureKey(features[i].Tenant, features[i].Endpoint) < featureKey(features[j].Tenant, features[j].Endpoint)
+	})
+	return features
+}
+
+// entitlementError reports whether a response means the plan or token cannot use the endpoint
+func entitlementError(status int, body []byte) (bool, string) {
+	if status != http.StatusPaymentRequired && status != http.StatusForbidden {
+		return false, ""
+	}
+	var result struct {
+		Error     string `json:"error"`
+		ErrorInfo string `json:"error_info"`
+	}
+	json.Unmarshal(body, &result)
+	reason := result.Error
+	if result.ErrorInfo != "" {
+		reason = strings.TrimSpace(reason + " (" + result.ErrorInfo + ")")
+	}
+	if reason == "" {
+		reason = http.StatusText(status)
+	}
+	if status == http.StatusPaymentRequired {
+		return true, reason
+	}
+	lower := strings.ToLower(reason)
+	for _, hint := range entitlementHints {
+		if strings.Contains(lower, hint) {
+			return true, reason
+		}
+	}
+	return false, ""
+}
+
+// featureTenant returns the tenant whose Pipedrive requests this service copy makes
+func (p *PipedriveService) featureTenant() string {
+	if p.tenant != "" {
+		return p.tenant
+	}
+	return DefaultTenant
+}
+
+// recordFeatureResponse disables the tenant's endpoint on payment-required/scope errors and
+// re-enables it when a retried request succeeds, alerting on both transitions
+func (p *PipedriveService) recordFeatureResponse(method, endpoint string, status int, body []byte) {
+	tenant, key := p.featureTenant(), pipedriveEndpoint(method, endpoint)
+	if status >= 200 && status < 300 {
+		if p.features.enable(tenant, key) {
+			p.logf("✅ Pipedrive %s is available again for tenant %s, re-enabled", key, tenant)
+			p.sendAlert(fmt.Sprintf("✅ Pipedrive %s is available again for tenant %s and has been re-enabled", key, tenant))
+		}
+		return
+	}
+	refused, reason := entitlementError(status, body)
+	if !refused || !p.features.disable(tenant, key, status, reason) {
+		return
+	}
+	p.logf("🚫 Pipedrive %s refused with HTTP %d (%s) for tenant %s; disabling it for %s", key, status, reason, tenant, p.features.retry)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "pipedrive_feature_disabled", Tenant: tenant, Detail: fmt.Sprintf("%s: HTTP %d %s", key, status, reason)})
+	p.sendAlert(fmt.Sprintf("🚫 Pipedrive %s is not available for tenant %s (HTTP %d: %s). Features using it are paused; check the Pipedrive plan or the API token scopes.",
+		key, tenant, status, reason))
+}
+
+// sendAlert posts an operational message to ALERT_WEBHOOK_URL
+func (p *PipedriveService) sendAlert(message string) {
+	if p.config.AlertWebhookURL == "" {
+		return
+	}
+	go func() {
+		if err := p.postSlackMessage(p.config.AlertWebhookURL, message); err != nil {
+			p.logf("⚠️ Warning: Failed to send alert: %v", err)
+		}
+	}()
+}
+
+// ReadinessHandler reports whether the service can take traffic, which Pipedrive features are disabled
+// and which Retell account new calls go to
+func ReadinessHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		disabled := pipedriveService.features.Disabled()
+		retellAccount := pipedriveService.retellFailover.Active()
+		status := "ready"
+		if len(disabled) > 0 || retellAccount != RetellAccountPrimary {
+			status = "degraded"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status":            status,
+			"pipedrive":         pipedriveService.config.HasPipedriveConfig(),
+			"retell":            pipedriveService.config.HasRetellConfig(),
+			"disabled_features": disabled,
+			"retell_account":    retellAccount,
+		})
+	}
+}
+
+// EnablePipedriveFeatureHandler re-enables a feature's disabled endpoints (?tenant=, default every
+// tenant), e.g. after upgrading the plan
+func EnablePipedriveFeatureHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feature := c.Param("feature")
+		if pipedriveService.features.enableFeature(c.Query("tenant"), feature) == 0 {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: fmt.Sprintf("Pipedrive %s is not disabled", feature),
+			})
+			return
+		}
+		log.Printf("✅ Pipedrive %s re-enabled by an admin", feature)
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Pipedrive %s re-enabled", feature),
+		})
+	}
+}
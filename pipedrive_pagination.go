@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// pipedrivePageLimit is the page size requested from Pipedrive list endpoints (API maximum is 500)
+const pipedrivePageLimit = 100
+
+// pipedriveMaxPages guards against endless paging on a misbehaving API
+const pipedriveMaxPages = 200
+
+// conditionalCacheSize caps how many GET responses are kept for conditional requests
+const conditionalCacheSize = 1000
+
+// conditionalEntry is a cached GET response with its validators
+type conditionalEntry struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// conditionalCache remembers ETag/Last-Modified validators for Pipedrive GETs
+type conditionalCache struct {
+	mu      sync.Mutex
+	entries map[string]conditionalEntry
+}
+
+// newConditionalCache creates an empty conditional request cache
+func newConditionalCache() *conditionalCache {
+	return &conditionalCache{entries: make(map[string]conditionalEntry)}
+}
+
+// apply adds If-None-Match/If-Modified-Since headers for a cached endpoint
+func (c *conditionalCache) apply(endpoint string, req *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[endpoint]
+	if !ok {
+		return
+	}
+	if entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+	if entry.lastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+}
+
+// resolve returns the body to use for a response, storing validators or serving the cached body on 304
+func (c *conditionalCache) resolve(endpoint string, resp *http.Response, body []byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if resp.StatusCode == http.StatusNotModified {
+		entry, ok := c.entries[endpoint]
+		if !ok {
+			return body, false
+		}
+		return entry.body, true
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return body, false
+	}
+	if len(c.entries) >= conditionalCacheSize {
+		// Evict an arbitrary entry to stay bounded
+		for key := range c.entries {
+			delete(c.entries, key)
+			break
+		}
+	}
+	c.entries[endpoint] = conditionalEntry{etag: etag, lastModified: lastModified, body: body}
+	return body, false
+}
+
+// pipedrivePage is the common envelope of Pipedrive list and search responses
+type pipedrivePage struct {
+	Success        bool            `json:"success"`
+	Data           json.RawMessage `json:"data"`
+	AdditionalData struct {
+		Pagination struct {
+			Start                 int  `json:"start"`
+			Limit                 int  `json:"limit"`
+			MoreItemsInCollection bool `json:"more_items_in_collection"`
+			NextStart             int  `json:"next_start"`
+		} `json:"pagination"`
+		NextCursor string `json:"next_cursor"` // API v2 cursor pagination
+	} `json:"additional_data"`
+}
+
+// listPipedriveItems fetches every page of a Pipedrive list or search endpoint and returns the raw items
+func (p *PipedriveService) listPipedriveItems(endpoint string) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	err := p.eachPipedrivePage(endpoint, func(page []json.RawMessage) error {
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// eachPipedrivePage fetches a Pipedrive list or search endpoint page by page, handing each page's raw
+// items to fn and stopping at the first error; /api/v2 endpoints page by cursor
+func (p *PipedriveService) eachPipedrivePage(endpoint string, fn func(items []json.RawMessage) error) error {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+
+	cursorPaged := strings.HasPrefix(endpoint, pipedriveV2Prefix+"/")
+
+	start := 0
+	cursor := ""
+	for page := 0; page < pipedriveMaxPages; page++ {
+		pageEndpoint := fmt.Sprintf("%s%sstart=%d&limit=%d", endpoint, separator, start, pipedrivePageLimit)
+		if cursorPaged {
+			pageEndpoint = fmt.Sprintf("%s%slimit=%d", endpoint, separator, pipedrivePageLimit)
+			if cursor != "" {
+				pageEndpoint += "&cursor=" + url.QueryEscape(cursor)
+			}
+		}
+		resp, err := p.makePipedriveRequest("GET", pageEndpoint, nil)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response body: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Pipedrive list %s failed: HTTP %d", endpoint, resp.StatusCode)
+		}
+
+		var result pipedrivePage
+		if err := json.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to parse list response: %v", err)
+		}
+		if !result.Success {
+			return fmt.Errorf("Pipedrive list %s was not successful", endpoint)
+		}
+
+		pageItems, err := pageItems(result.Data)
+		if err != nil {
+			return err
+		}
+		if err := fn(pageItems); err != nil {
+			return err
+		}
+
+		if cursorPaged {
+			if result.AdditionalData.NextCursor == "" {
+				return nil
+			}
+			cursor = result.AdditionalData.NextCursor
+			continue
+		}
+		pagination := result.AdditionalData.Pagination
+		if !pagination.MoreItemsInCollection {
+			return nil
+		}
+		if pagination.NextStart > start {
+			start = pagination.NextStart
+		} else {
+			start += len(pageItems)
+		}
+	}
+	return fmt.Errorf("Pipedrive list %s exceeded %d pages", endpoint, pipedriveMaxPages)
+}
+
+// pageItems extracts items from a list response (array) or a search response ({"items": [...]})
+func pageItems(data json.RawMessage) ([]json.RawMessage, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" || trimmed == "null" {
+		return nil, nil
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse list items: %v", err)
+		}
+		return items, nil
+	}
+	var search struct {
+		Items []struct {
+			Item json.RawMessage `json:"item"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &search); err != nil {
+		return nil, fmt.Errorf("failed to parse search items: %v", err)
+	}
+	items := make([]json.RawMessage, 0, len(search.Items))
+	for _, item := range search.Items {
+		items = append(items, item.Item)
+	}
+	return items, nil
+}
+
+// ListPersonActivities returns every activity for a person
+func (p *PipedriveService) ListPersonActivities(personID int) ([]PipedriveActivity, error) {
+	return p.listActivities("person_id", personID)
+}
+
+// ListDealActivities returns every activity linked to a deal
+func (p *PipedriveService) ListDealActivities(dealID int) ([]PipedriveActivity, error) {
+	return p.listActivities("deal_id", dealID)
+}
+
+// ListPersonDeals returns every open, won and lost deal of a person
+func (p *PipedriveService) ListPersonDeals(personID int) ([]PipedriveDeal, error) {
+	items, err := p.listPipedriveItems(fmt.Sprintf("/persons/%d/deals?status=all_not_deleted", personID))
+	if err != nil {
+		return nil, err
+	}
+	deals := make([]PipedriveDeal, 0, len(items))
+	for _, item := range items {
+		var deal PipedriveDeal
+		if err := json.Unmarshal(item, &deal); err != nil {
+			return nil, fmt.Errorf("failed to parse deal: %v", err)
+		}
+		deals = append(deals, deal)
+	}
+	return deals, nil
+}
+
+// SearchPersons returns persons whose phone or email exactly matches a term
+func (p *PipedriveService) SearchPersons(field, term string) ([]PipedrivePerson, error) {
+	items, err := p.listPipedriveItems(fmt.Sprintf("/persons/search?term=%s&fields=%s&exact_match=true", url.QueryEscape(term), field))
+	if err != nil {
+		return nil, err
+	}
+	persons := make([]PipedrivePerson, 0, len(items))
+	for _, item := range items {
+		var person PipedrivePerson
+		if err := json.Unmarshal(item, &person); err != nil {
+			return nil, fmt.Errorf("failed to parse person: %v", err)
+		}
+		persons = append(persons, person)
+	}
+	return persons, nil
+}
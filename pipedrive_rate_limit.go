@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPipedriveThrottlePause is how long requests pause after a 429 without Retry-After
+const defaultPipedriveThrottlePause = 2 * time.Second
+
+// ErrPipedriveRateLimited is returned when a write would wait longer than PIPEDRIVE_RATE_MAX_WAIT_SECONDS
+var ErrPipedriveRateLimited = errors.New("pipedrive rate limit saturated")
+
+// PipedriveRateLimitStats reports how hard the Pipedrive rate limiter is being pushed
+type PipedriveRateLimitStats struct {
+	Limit          int       `json:"limit"` // Requests per second
+	Burst          int       `json:"burst"`
+	Tokens         float64   `json:"tokens"`  // Available now; negative when requests are queued
+	Waiting        int       `json:"waiting"` // Requests waiting for a token
+	MaxWaiting     int       `json:"max_waiting"`
+	Saturation     float64   `json:"saturation"` // Share of the burst in use; 1 when requests queue
+	Requests       int64     `json:"requests"`
+	Delayed        int64     `json:"delayed"`   // Requests that had to wait
+	TimedOut       int64     `json:"timed_out"` // Writes refused, or reads sent early, instead of waiting too long
+	Throttled      int64     `json:"throttled"` // 429s from Pipedrive
+	WaitSeconds    float64   `json:"wait_seconds"`
+	MaxWaitSeconds float64   `json:"max_wait_seconds"`
+	PausedUntil    time.Time `json:"paused_until,omitempty"` // Set by Retry-After or an exhausted rate limit
+}
+
+// PipedriveRateLimiter is a token bucket shared by all Pipedrive requests. Requests wait in
+// line for a token, and everyone pauses when Pipedrive answers 429 or reports the limit spent.
+type PipedriveRateLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // Tokens per second
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	maxWait     time.Duration
+	stats       PipedriveRateLimitStats
+}
+
+// NewPipedriveRateLimiter creates the limiter from PIPEDRIVE_RATE_LIMIT; nil when it is 0
+func NewPipedriveRateLimiter(config *Config) *PipedriveRateLimiter {
+	if config.PipedriveRateLimit <= 0 {
+		return nil
+	}
+	burst := config.PipedriveRateBurst
+	if burst < 1 {
+		burst = config.PipedriveRateLimit * 2
+	}
+	log.Printf("🚦 Pipedrive requests limited to %d/s (burst %d)", config.PipedriveRateLimit, burst)
+	return &PipedriveRateLimiter{
+		rate:    float64(config.PipedriveRateLimit),
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+		maxWait: time.Duration(config.PipedriveRateMaxWait) * time.Second,
+		stats:   PipedriveRateLimitStats{Limit: config.PipedriveRateLimit, Burst: burst},
+	}
+}
+
+// refillLocked adds the tokens earned since the last refill; callers must hold mu
+func (l *PipedriveRateLimiter) refillLocked(now time.Time) {
+	if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+	}
+}
+
+// Wait takes a token, waiting in line behind earlier requests and any pause. A write that would
+// wait longer than PIPEDRIVE_RATE_MAX_WAIT_SECONDS is refused with ErrPipedriveRateLimited; a read
+// waits that long and is then sent anyway, so a long Retry-After doesn't fail the lookups calls
+// depend on.
+func (l *PipedriveRateLimiter) Wait(read bool) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.refillLocked(now)
+	l.stats.Requests++
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	if pause := l.pausedUntil.Sub(now); pause > 0 {
+		wait += pause
+	}
+	if l.maxWait > 0 && wait > l.maxWait {
+		l.stats.TimedOut++
+		if !read {
+			l.tokens++
+			l.mu.Unlock()
+			return fmt.Errorf("%w: request would wait %s", ErrPipedriveRateLimited, wait.Round(time.Millisecond))
+		}
+		wait = l.maxWait
+	}
+	if wait <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	l.stats.Delayed++
+	l.stats.Waiting++
+	if l.stats.Waiting > l.stats.MaxWaiting {
+		l.stats.MaxWaiting = l.stats.Waiting
+	}
+	l.stats.WaitSeconds += wait.Seconds()
+	if wait.Seconds() > l.stats.MaxWaitSeconds {
+		l.stats.MaxWaitSeconds = wait.Seconds()
+	}
+	l.mu.Unlock()
+
+	debugf(SubsystemPipedrive, "Pipedrive rate limit: waiting %s", wait)
+	time.Sleep(wait)
+
+	l.mu.Lock()
+	l.stats.Waiting--
+	l.mu.Unlock()
+	return nil
+}
+
+// Observe pauses requests when Pipedrive answers 429 (for Retry-After) or reports the rate limit
+// spent (X-RateLimit-Remaining: 0, for X-RateLimit-Reset)
+func (l *PipedriveRateLimiter) Observe(resp *http.Response) {
+	if l == nil {
+		return
+	}
+	var pause time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests {
+		pause = retryAfter(resp)
+		if pause == 0 {
+			pause = defaultPipedriveThrottlePause
+		}
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if seconds, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Reset")); err == nil && seconds > 0 {
+			pause = time.Duration(seconds) * time.Second
+		}
+	}
+	if pause == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		l.stats.Throttled++
+	}
+	now := time.Now()
+	if until := now.Add(pause); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		log.Printf("🚦 Pausing Pipedrive requests for %s (HTTP %d)", pause, resp.StatusCode)
+	}
+	l.refillLocked(now)
+	if l.tokens > 0 {
+		l.tokens = 0
+	}
+}
+
+// Stats returns the limiter's current state and counters
+func (l *PipedriveRateLimiter) Stats() PipedriveRateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.refillLocked(now)
+	stats := l.stats
+	stats.Tokens = l.tokens
+	if l.pausedUntil.After(now) {
+		stats.PausedUntil = l.pausedUntil
+	}
+	stats.Saturation = 1 - l.tokens/l.burst
+	if stats.Saturation > 1 || stats.Waiting > 0 || !stats.PausedUntil.IsZero() {
+		stats.Saturation = 1
+	}
+	return stats
+}
+
+// PipedriveRateLimitHandler reports the Pipedrive rate limiter's saturation
+func PipedriveRateLimitHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveService.rateLimiter == nil {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "Pipedrive requests are not rate limited (PIPEDRIVE_RATE_LIMIT=0)",
+			})
+			return
+		}
+		stats := pipedriveService.rateLimiter.Stats()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d Pipedrive requests waiting, %.0f%% saturated", stats.Waiting, stats.Saturation*100),
+			Data:    stats,
+		})
+	}
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pipedrive retry queue limits
+const (
+	pipedriveRetryInterval    = 5 * time.Second // How often due retries are attempted
+	maxPipedriveRetries       = 5000            // Pending writes; further failures are dead-lettered
+	maxPipedriveDeadLetters   = 500             // Dead letters kept, oldest dropped first
+	pipedriveRetryErrorLength = 300             // Response body kept as the error of a failed attempt
+)
+
+// PipedriveRetry is a failed Pipedrive write waiting to be re-attempted, or given up on
+type PipedriveRetry struct {
+	ID            string          `json:"id"`
+	Method        string          `json:"method"`
+	Endpoint      string          `json:"endpoint"`
+	Body          json.RawMessage `json:"body,omitempty"`
+	Attempts      int             `json:"attempts"` // Including the original request
+	LastStatus    int             `json:"last_status,omitempty"`
+	LastError     string          `json:"last_error"`
+	CreatedAt     time.Time       `json:"created_at"`
+	NextAttemptAt time.Time       `json:"next_attempt_at,omitempty"`
+	DeadAt        time.Time       `json:"dead_at,omitempty"` // Set once the write is dead-lettered
+}
+
+// pipedriveRetryFile is the PIPEDRIVE_RETRY_FILE layout
+type pipedriveRetryFile struct {
+	Pending     []PipedriveRetry `json:"pending"`
+	DeadLetters []PipedriveRetry `json:"dead_letters"`
+}
+
+// PipedriveRetryQueue re-attempts activity, note and person writes that failed with a rate limit,
+// a 5xx or a network error, with exponential backoff; creations are only re-attempted after a rate
+// limit, as any other failure may have created the record. Writes still failing after
+// PIPEDRIVE_RETRY_MAX_ATTEMPTS are moved to a dead-letter log. Saved to PIPEDRIVE_RETRY_FILE.
+type PipedriveRetryQueue struct {
+	mu          sync.Mutex
+	path        string
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	pending     map[string]*PipedriveRetry // Keyed by ID
+	dead        []PipedriveRetry           // Oldest first
+}
+
+// NewPipedriveRetryQueue creates the retry queue, loading PIPEDRIVE_RETRY_FILE; nil when
+// PIPEDRIVE_RETRY_MAX_ATTEMPTS disables retries or there is no file to keep them in, as queued
+// writes would be lost on restart
+func NewPipedriveRetryQueue(config *Config) *PipedriveRetryQueue {
+	if config.PipedriveRetryMaxAttempts <= 1 {
+		return nil
+	}
+	if config.PipedriveRetryFile == "" {
+		log.Printf("⚠️ Warning: Pipedrive write retries are disabled, set PIPEDRIVE_RETRY_FILE to keep them across restarts")
+		return nil
+	}
+	q := &PipedriveRetryQueue{
+		path:        config.PipedriveRetryFile,
+		maxAttempts: config.PipedriveRetryMaxAttempts,
+		baseDelay:   time.Duration(config.PipedriveRetryBaseSeconds) * time.Second,
+		maxDelay:    time.Duration(config.PipedriveRetryMaxDelayMinutes) * time.Minute,
+		pending:     make(map[string]*PipedriveRetry),
+	}
+	if q.baseDelay <= 0 {
+		q.baseDelay = 30 * time.Second
+	}
+	if q.maxDelay < q.baseDelay {
+		q.maxDelay = q.baseDelay
+	}
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read PIPEDRIVE_RETRY_FILE %s: %v", q.path, err)
+		}
+		return q
+	}
+	var saved pipedriveRetryFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable PIPEDRIVE_RETRY_FILE %s: %v", q.path, err)
+		return q
+	}
+	for i := range saved.Pending {
+		retry := saved.Pending[i]
+		q.pending[retry.ID] = &retry
+	}
+	q.dead = saved.DeadLetters
+	log.Printf("🔁 Loaded %d pending Pipedrive retries and %d dead letters from %s", len(q.pending), len(q.dead), q.path)
+	return q
+}
+
+// saveLocked writes the queue to PIPEDRIVE_RETRY_FILE; callers must hold mu
+func (q *PipedriveRetryQueue) saveLocked() {
+	if q.path == "" {
+		return
+	}
+	saved := pipedriveRetryFile{Pending: q.pendingLocked(), DeadLetters: q.dead}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		log.Printf("⚠️ Warning: Cannot encode Pipedrive retries: %v", err)
+		return
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("⚠️ Warning: Cannot write PIPEDRIVE_RETRY_FILE %s: %v", q.path, err)
+		return
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		log.Printf("⚠️ Warning: Cannot replace PIPEDRIVE_RETRY_FILE %s: %v", q.path, err)
+	}
+}
+
+// pendingLocked returns the pending retries, soonest first; callers must hold mu
+func (q *PipedriveRetryQueue) pendingLocked() []PipedriveRetry {
+	pending := make([]PipedriveRetry, 0, len(q.pending))
+	for _, retry := range q.pending {
+		pending = append(pending, *retry)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].NextAttemptAt.Before(pending[j].NextAttemptAt) })
+	return pending
+}
+
+// backoff returns the wait after the given number of attempts: the base delay doubled for each
+// attempt after the first, capped at the max delay, and never shorter than Retry-After
+func (q *PipedriveRetryQueue) backoff(attempts int, retryAfter time.Duration) time.Duration {
+	delay := q.baseDelay
+	for i := 1; i < attempts && delay < q.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > q.maxDelay {
+		delay = q.maxDelay
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// Enqueue queues a write whose original request failed
+func (q *PipedriveRetryQueue) Enqueue(method, endpoint string, body interface{}, status int, reason string, retryAfter time.Duration) (PipedriveRetry, error) {
+	retry := PipedriveRetry{
+		ID:         newProcessingID(),
+		Method:     method,
+		Endpoint:   endpoint,
+		Attempts:   1,
+		LastStatus: status,
+		LastError:  reason,
+		CreatedAt:  time.Now(),
+	}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return retry, err
+		}
+		retry.Body = data
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) >= maxPipedriveRetries {
+		retry.LastError = "retry queue full: " + reason
+		q.deadLetterLocked(retry)
+		q.saveLocked()
+		return retry, fmt.Errorf("retry queue is full (%d writes)", maxPipedriveRetries)
+	}
+	retry.NextAttemptAt = retry.CreatedAt.Add(q.backoff(retry.Attempts, retryAfter))
+	q.pending[retry.ID] = &retry
+	q.saveLocked()
+	return retry, nil
+}
+
+// deadLetterLocked moves a retry to the dead-letter log; callers must hold mu
+func (q *PipedriveRetryQueue) deadLetterLocked(retry PipedriveRetry) {
+	delete(q.pending, retry.ID)
+	retry.NextAttemptAt = time.Time{}
+	retry.DeadAt = time.Now()
+	q.dead = append(q.dead, retry)
+	if len(q.dead) > maxPipedriveDeadLetters {
+		q.dead = q.dead[len(q.dead)-maxPipedriveDeadLetters:]
+	}
+}
+
+// Due returns the pending retries whose next attempt is due
+func (q *PipedriveRetryQueue) Due(now time.Time) []PipedriveRetry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []PipedriveRetry
+	for _, retry := range q.pendingLocked() {
+		if !retry.NextAttemptAt.After(now) {
+			due = append(due, retry)
+		}
+	}
+	return due
+}
+
+// Succeeded removes a retry whose write went through
+func (q *PipedriveRetryQueue) Succeeded(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, id)
+	q.saveLocked()
+}
+
+// Failed records a failed attempt and schedules the next one, dead-lettering the write when it is
+// out of attempts or can never succeed. It returns the updated retry and whether it is dead.
+func (q *PipedriveRetryQueue) Failed(id string, status int, reason string, retryAfter time.Duration, permanent bool) (PipedriveRetry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	retry, ok := q.pending[id]
+	if !ok {
+		return PipedriveRetry{}, false
+	}
+	retry.Attempts++
+	retry.LastStatus = status
+	retry.LastError = reason
+	if permanent || retry.Attempts >= q.maxAttempts {
+		updated := *retry
+		q.deadLetterLocked(updated)
+		q.saveLocked()
+		return q.dead[len(q.dead)-1], true
+	}
+	retry.NextAttemptAt = time.Now().Add(q.backoff(retry.Attempts, retryAfter))
+	q.saveLocked()
+	return *retry, false
+}
+
+// Requeue makes a pending retry due now, or moves a dead letter back to the queue with a fresh
+// set of attempts
+func (q *PipedriveRetryQueue) Requeue(id string) (PipedriveRetry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if retry, ok := q.pending[id]; ok {
+		retry.NextAttemptAt = time.Now()
+		q.saveLocked()
+		return *retry, true
+	}
+	for i, retry := range q.dead {
+		if retry.ID != id {
+			continue
+		}
+		q.dead = append(q.dead[:i], q.dead[i+1:]...)
+		retry.Attempts = 0
+		retry.DeadAt = time.Time{}
+		retry.NextAttemptAt = time.Now()
+		q.pending[id] = &retry
+		q.saveLocked()
+		return retry, true
+	}
+	return PipedriveRetry{}, false
+}
+
+// Discard drops a pending retry or dead letter
+func (q *PipedriveRetryQueue) Discard(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.pending[id]; ok {
+		delete(q.pending, id)
+		q.saveLocked()
+		return true
+	}
+	for i, retry := range q.dead {
+		if retry.ID == id {
+			q.dead = append(q.dead[:i], q.dead[i+1:]...)
+			q.saveLocked()
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot returns the pending retries, soonest first, and the dead letters, newest first
+func (q *PipedriveRetryQueue) Snapshot() ([]PipedriveRetry, []PipedriveRetry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	dead := make([]PipedriveRetry, 0, len(q.dead))
+	for i := len(q.dead) - 1; i >= 0; i-- {
+		dead = append(dead, q.dead[i])
+	}
+	return q.pendingLocked(), dead
+}
+
+// retryablePipedriveWrite reports whether a failed request is an activity, note or person write
+// worth re-attempting. Person creation is not retried, callers need the new person right away.
+func retryablePipedriveWrite(method, endpoint string) bool {
+	path := strings.TrimPrefix(strings.SplitN(endpoint, "?", 2)[0], pipedriveV2Prefix)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch method {
+	case "POST":
+		return len(segments) == 1 && (segments[0] == "activities" || segments[0] == "notes")
+	case "PUT", "PATCH":
+		return len(segments) == 2 && (segments[0] == "activities" || segments[0] == "notes" || segments[0] == "persons")
+	}
+	return false
+}
+
+// retryablePipedriveStatus reports whether a failed write is worth retrying: rate limits, and for
+// updates server and network errors (status 0). A creation that failed with a server or network
+// error may still have gone through, so re-sending it could create a duplicate.
+func retryablePipedriveStatus(method string, status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	return method != "POST" && (status == 0 || status >= 500)
+}
+
+// retryAfter reads a Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// truncateRetryError shortens a response body kept as a retry's error
+func truncateRetryError(body []byte) string {
+	reason := strings.TrimSpace(string(body))
+	if len(reason) > pipedriveRetryErrorLength {
+		reason = reason[:pipedriveRetryErrorLength] + "..."
+	}
+	return reason
+}
+
+// queuePipedriveRetry queues a failed activity, note or person write for another attempt
+func (p *PipedriveService) queuePipedriveRetry(method, endpoint string, body interface{}, status int, reason string, wait time.Duration) {
+	if p.retries == nil || !retryablePipedriveWrite(method, endpoint) {
+		return
+	}
+	if !retryablePipedriveStatus(method, status) {
+		p.logf("⚠️ Pipedrive %s %s failed in a way that may have created the record, not retrying it: %s", method, endpoint, reason)
+		return
+	}
+	retry, err := p.retries.Enqueue(method, endpoint, body, status, reason, wait)
+	if err != nil {
+		p.logf("❌ Pipedrive %s %s failed and was dead-lettered: %v", method, endpoint, err)
+		p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "pipedrive_write_dead_lettered",
+			Detail: fmt.Sprintf("%s %s (%s): %v", method, endpoint, retry.ID, err)})
+		return
+	}
+	p.logf("🔁 Pipedrive %s %s failed, retrying at %s (retry %s)", method, endpoint, retry.NextAttemptAt.Format(time.RFC3339), retry.ID)
+}
+
+// attemptPipedriveRetry re-sends a queued write, rescheduling or dead-lettering it on failure
+func (p *PipedriveService) attemptPipedriveRetry(retry PipedriveRetry) {
+	var body interface{}
+	if len(retry.Body) > 0 {
+		if err := json.Unmarshal(retry.Body, &body); err != nil {
+			p.failPipedriveRetry(retry, 0, fmt.Sprintf("unreadable body: %v", err), 0, true)
+			return
+		}
+	}
+	resp, err := p.doPipedriveRequest(retry.Method, retry.Endpoint, body, false)
+	if err != nil {
+		_, refused := err.(*PipedriveFeatureError)
+		p.failPipedriveRetry(retry, 0, err.Error(), 0, refused || !retryablePipedriveStatus(retry.Method, 0))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		p.failPipedriveRetry(retry, resp.StatusCode, truncateRetryError(data), retryAfter(resp), !retryablePipedriveStatus(retry.Method, resp.StatusCode))
+		return
+	}
+	p.retries.Succeeded(retry.ID)
+	p.logf("✅ Pipedrive %s %s went through on attempt %d (retry %s)", retry.Method, retry.Endpoint, retry.Attempts+1, retry.ID)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "pipedrive_write_retried",
+		Detail: fmt.Sprintf("%s %s on attempt %d (%s)", retry.Method, retry.Endpoint, retry.Attempts+1, retry.ID)})
+}
+
+// failPipedriveRetry records a failed retry attempt
+func (p *PipedriveService) failPipedriveRetry(retry PipedriveRetry, status int, reason string, wait time.Duration, permanent bool) {
+	updated, dead := p.retries.Failed(retry.ID, status, reason, wait, permanent)
+	if !dead {
+		p.logf("🔁 Pipedrive %s %s failed again (attempt %d), retrying at %s (retry %s)",
+			retry.Method, retry.Endpoint, updated.Attempts, updated.NextAttemptAt.Format(time.RFC3339), retry.ID)
+		return
+	}
+	p.logf("❌ Giving up on Pipedrive %s %s after %d attempts (retry %s): %s", retry.Method, retry.Endpoint, updated.Attempts, retry.ID, reason)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "pipedrive_write_dead_lettered",
+		Detail: fmt.Sprintf("%s %s after %d attempts (%s): %s", retry.Method, retry.Endpoint, updated.Attempts, retry.ID, reason)})
+}
+
+// runPipedriveRetries re-attempts due Pipedrive writes every few seconds
+func (p *PipedriveService) runPipedriveRetries() {
+	ticker := time.NewTicker(pipedriveRetryInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, retry := range p.retries.Due(now) {
+			p.attemptPipedriveRetry(retry)
+		}
+	}
+}
+
+// pipedriveRetriesDisabled answers 409 when the retry queue is off, reporting whether it did
+func pipedriveRetriesDisabled(c *gin.Context, pipedriveService *PipedriveService) bool {
+	if pipedriveService.retries != nil {
+		return false
+	}
+	c.JSON(http.StatusConflict, WebhookResponse{
+		Success: false,
+		Message: "Pipedrive write retries are disabled (PIPEDRIVE_RETRY_MAX_ATTEMPTS)",
+	})
+	return true
+}
+
+// ListPipedriveRetriesHandler lists pending Pipedrive write retries and dead letters
+func ListPipedriveRetriesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveRetriesDisabled(c, pipedriveService) {
+			return
+		}
+		pending, dead := pipedriveService.retries.Snapshot()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d pending Pipedrive retries, %d dead letters", len(pending), len(dead)),
+			Data: map[string]interface{}{
+				"pending":      pending,
+				"dead_letters": dead,
+				"max_attempts": pipedriveService.retries.maxAttempts,
+			},
+		})
+	}
+}
+
+// RequeuePipedriveRetryHandler retries a pending write now, or puts a dead letter back in the queue
+func RequeuePipedriveRetryHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveRetriesDisabled(c, pipedriveService) {
+			return
+		}
+		retry, ok := pipedriveService.retries.Requeue(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Pipedrive retry not found",
+			})
+			return
+		}
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "pipedrive_write_requeued",
+			Detail: fmt.Sprintf("%s %s (%s)", retry.Method, retry.Endpoint, retry.ID)})
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Pipedrive %s %s queued for retry", retry.Method, retry.Endpoint),
+			Data:    retry,
+		})
+	}
+}
+
+// DiscardPipedriveRetryHandler drops a pending write or dead letter
+func DiscardPipedriveRetryHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveRetriesDisabled(c, pipedriveService) {
+			return
+		}
+		id := c.Param("id")
+		if !pipedriveService.retries.Discard(id) {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Pipedrive retry not found",
+			})
+			return
+		}
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "pipedrive_write_discarded", Detail: id})
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Pipedrive retry " + id + " discarded",
+		})
+	}
+}
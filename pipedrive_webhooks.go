@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Results of checking a registered Pipedrive webhook against the configured credentials
+const (
+	WebhookAuthOK            = "ok"
+	WebhookAuthMissing       = "missing_auth"      // Registered without basic auth
+	WebhookAuthUserMismatch  = "user_mismatch"     // Sends a different user than configured
+	WebhookAuthWrongPassword = "password_mismatch" // Sends a different password than configured
+	WebhookAuthRejected      = "rejected"          // Last delivery was refused with 401
+	WebhookAuthNoCredentials = "no_credentials"    // No credentials configured for its company
+)
+
+// PipedriveWebhook is a webhook as returned by the Pipedrive webhooks API
+type PipedriveWebhook struct {
+	ID               int    `json:"id"`
+	CompanyID        int    `json:"company_id"`
+	EventAction      string `json:"event_action"`
+	EventObject      string `json:"event_object"`
+	SubscriptionURL  string `json:"subscription_url"`
+	IsActive         int    `json:"is_active"`
+	HTTPAuthUser     string `json:"http_auth_user"`
+	HTTPAuthPassword string `json:"http_auth_password"`
+	LastDeliveryTime string `json:"last_delivery_time"`
+	LastHTTPStatus   *int   `json:"last_http_status"`
+}
+
+// PipedriveWebhookAuthCheck is the result of checking one registered webhook
+type PipedriveWebhookAuthCheck struct {
+	ID              int    `json:"id"`
+	Event           string `json:"event"`
+	SubscriptionURL string `json:"subscription_url"`
+	Active          bool   `json:"active"`
+	Tenant          string `json:"tenant,omitempty"`
+	Status          string `json:"status"`
+	Detail          string `json:"detail,omitempty"`
+	LastHTTPStatus  int    `json:"last_http_status,omitempty"`
+}
+
+// ListPipedriveWebhooks returns the webhooks registered on the Pipedrive account
+func (p *PipedriveService) ListPipedriveWebhooks() ([]PipedriveWebhook, error) {
+	resp, err := p.makePipedriveRequest("GET", "/webhooks", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks response: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to list webhooks: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success bool               `json:"success"`
+		Data    []PipedriveWebhook `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks response: %v", err)
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("failed to list webhooks")
+	}
+	return result.Data, nil
+}
+
+// checkPipedriveWebhookAuth compares a registered webhook's basic auth with the credentials
+// configured for its company. Pipedrive masks stored passwords, so a wrong password usually only
+// shows up as a 401 on the last delivery.
+func (c *Config) checkPipedriveWebhookAuth(webhook PipedriveWebhook) PipedriveWebhookAuthCheck {
+	check := PipedriveWebhookAuthCheck{
+		ID:              webhook.ID,
+		Event:           webhook.EventAction + "." + webhook.EventObject,
+		SubscriptionURL: webhook.SubscriptionURL,
+		Active:          webhook.IsActive == 1,
+		Status:          WebhookAuthOK,
+	}
+	if webhook.LastHTTPStatus != nil {
+		check.LastHTTPStatus = *webhook.LastHTTPStatus
+	}
+	tenant, credentials, ok := c.pipedriveWebhookCredentials(strconv.Itoa(webhook.CompanyID))
+	check.Tenant = tenant
+	switch {
+	case !ok:
+		check.Status = WebhookAuthNoCredentials
+		check.Detail = fmt.Sprintf("no webhook credentials configured for company %d", webhook.CompanyID)
+	case webhook.HTTPAuthUser == "":
+		check.Status = WebhookAuthMissing
+		check.Detail = "webhook has no basic auth user; re-register it with the configured credentials"
+	case subtle.ConstantTimeCompare([]byte(webhook.HTTPAuthUser), []byte(credentials.User)) != 1:
+		check.Status = WebhookAuthUserMismatch
+		check.Detail = fmt.Sprintf("webhook sends user %q", webhook.HTTPAuthUser)
+	case webhook.HTTPAuthPassword != "" && strings.Trim(webhook.HTTPAuthPassword, "*") != "" &&
+		subtle.ConstantTimeCompare([]byte(webhook.HTTPAuthPassword), []byte(credentials.Password)) != 1:
+		check.Status = WebhookAuthWrongPassword
+		check.Detail = "webhook sends a different password"
+	case check.LastHTTPStatus == http.StatusUnauthorized:
+		check.Status = WebhookAuthRejected
+		check.Detail = "last delivery was rejected with 401; the registered password is likely wrong"
+	}
+	return check
+}
+
+// PipedriveWebhookAuthCheckHandler checks that the Pipedrive webhooks pointing at this service send
+// the configured basic auth credentials
+func PipedriveWebhookAuthCheckHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		config := pipedriveService.config
+		webhooks, err := pipedriveService.ListPipedriveWebhooks()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to list Pipedrive webhooks: " + err.Error(),
+			})
+			return
+		}
+
+		checks := make([]PipedriveWebhookAuthCheck, 0, len(webhooks))
+		failing := 0
+		for _, webhook := range webhooks {
+			if !strings.Contains(webhook.SubscriptionURL, "/webhook/pipedrive/") {
+				continue
+			}
+			if config.PublicBaseURL != "" && !strings.HasPrefix(webhook.SubscriptionURL, strings.TrimSuffix(config.PublicBaseURL, "/")+"/") {
+				continue
+			}
+			check := config.checkPipedriveWebhookAuth(webhook)
+			if check.Status != WebhookAuthOK {
+				failing++
+			}
+			checks = append(checks, check)
+		}
+
+		warnings := []string{}
+		if !config.webhookSecretSet(WebhookSourcePipedrive) {
+			warnings = append(warnings, "no Pipedrive webhook credentials are configured")
+		}
+		if len(checks) == 0 {
+			warnings = append(warnings, "no Pipedrive webhooks point at this service")
+		}
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: failing == 0 && len(warnings) == 0,
+			Message: fmt.Sprintf("%d of %d Pipedrive webhooks use the configured credentials", len(checks)-failing, len(checks)),
+			Data: map[string]interface{}{
+				"webhooks": checks,
+				"warnings": warnings,
+			},
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Processing job statuses
+const (
+	ProcessingPending   = "pending"
+	ProcessingSucceeded = "succeeded"
+	ProcessingFailed    = "failed"
+)
+
+// processingRetention is how long finished jobs remain available for polling
+const processingRetention = 24 * time.Hour
+
+// ProcessingJob tracks a webhook that is processed in the background
+type ProcessingJob struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Priority    bool       `json:"priority,omitempty"` // Ran on the opt-out/compliance priority lane
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// ProcessingTracker runs webhook processing in the background and records the outcome
+type ProcessingTracker struct {
+	mu      sync.Mutex
+	jobs    map[string]*ProcessingJob
+	workers *WorkerPool
+	slo     *SLOTracker // Finished jobs are measured against the job SLO
+}
+
+// NewProcessingTracker creates a new processing tracker that runs jobs on the worker pool
+func NewProcessingTracker(workers *WorkerPool, slo *SLOTracker) *ProcessingTracker {
+	return &ProcessingTracker{jobs: make(map[string]*ProcessingJob), workers: workers, slo: slo}
+}
+
+// Run queues fn on the worker pool and returns the job tracking it
+func (t *ProcessingTracker) Run(kind string, fn func() error) (ProcessingJob, error) {
+	return t.run(kind, fn, false)
+}
+
+// RunPriority queues fn on the worker pool's priority lane, ahead of any queued work
+func (t *ProcessingTracker) RunPriority(kind string, fn func() error) ProcessingJob {
+	job, _ := t.run(kind, fn, true)
+	return job
+}
+
+// run tracks fn as a job and submits it to the queue or the priority lane
+func (t *ProcessingTracker) run(kind string, fn func() error, priority bool) (ProcessingJob, error) {
+	job := &ProcessingJob{
+		ID:        newProcessingID(),
+		Kind:      kind,
+		Status:    ProcessingPending,
+		Priority:  priority,
+		CreatedAt: time.Now(),
+	}
+
+	t.mu.Lock()
+	t.pruneLocked()
+	t.jobs[job.ID] = job
+	snapshot := *job
+	t.mu.Unlock()
+
+	run := func() {
+		// A panicking job is failed here and the panic passed on for the worker to recover
+		defer func() {
+			if r := recover(); r != nil {
+				t.finish(job, fmt.Errorf("panic: %v", r))
+				panic(r)
+			}
+		}()
+		t.finish(job, fn())
+	}
+	if priority {
+		t.workers.SubmitPriority(run)
+		return snapshot, nil
+	}
+	drop := func() {
+		t.finish(job, ErrJobShed)
+	}
+	if err := t.workers.Submit(run, drop); err != nil {
+		t.mu.Lock()
+		delete(t.jobs, job.ID)
+		t.mu.Unlock()
+		return ProcessingJob{}, err
+	}
+
+	return snapshot, nil
+}
+
+// ErrJobShed marks a job dropped from the queue under backpressure
+var ErrJobShed = errors.New("job shed from the worker queue under backpressure")
+
+// finish records the outcome of a job
+func (t *ProcessingTracker) finish(job *ProcessingJob, err error) {
+	now := time.Now()
+	t.slo.RecordJob(job.Kind, err, now.Sub(job.CreatedAt))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job.CompletedAt = &now
+	if err != nil {
+		job.Status = ProcessingFailed
+		job.Error = err.Error()
+		log.Printf("❌ [PROCESSING] %s job %s failed: %v", job.Kind, job.ID, err)
+	} else {
+		job.Status = ProcessingSucceeded
+		log.Printf("✅ [PROCESSING] %s job %s succeeded", job.Kind, job.ID)
+	}
+}
+
+// Get returns a copy of the job with the given ID
+func (t *ProcessingTracker) Get(id string) (ProcessingJob, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	job, ok := t.jobs[id]
+	if !ok {
+		return ProcessingJob{}, false
+	}
+	return *job, true
+}
+
+// pruneLocked removes finished jobs past the retention window; callers must hold mu
+func (t *ProcessingTracker) pruneLocked() {
+	cutoff := time.Now().Add(-processingRetention)
+	for id, job := range t.jobs {
+		if job.CompletedAt != nil && job.CompletedAt.Before(cutoff) {
+			delete(t.jobs, id)
+		}
+	}
+}
+
+// newProcessingID returns a random hex processing ID
+func newProcessingID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// respondAccepted starts fn in the background and replies 202 with the processing ID
+func respondAccepted(c *gin.Context, pipedriveService *PipedriveService, kind string, fn func() error) {
+	job, err := pipedriveService.processing.Run(kind, fn)
+	if err != nil {
+		respondThrottled(c, pipedriveService, err.Error())
+		return
+	}
+	respondProcessing(c, job)
+}
+
+// respondAcceptedPriority starts fn on the priority lane and replies 202 with the processing ID;
+// it is never throttled
+func respondAcceptedPriority(c *gin.Context, pipedriveService *PipedriveService, kind string, fn func() error) {
+	respondProcessing(c, pipedriveService.processing.RunPriority(kind, fn))
+}
+
+// respondProcessing replies 202 with the processing ID of a background job
+func respondProcessing(c *gin.Context, job ProcessingJob) {
+	c.Header("Location", "/api/processing/"+job.ID)
+	c.JSON(http.StatusAccepted, WebhookResponse{
+		Success: true,
+		Message: "Webhook accepted for processing",
+		Data: gin.H{
+			"processing_id": job.ID,
+			"status_url":    "/api/processing/" + job.ID,
+		},
+	})
+}
+
+// ProcessingStatusHandler returns the status of a background webhook processing job
+func ProcessingStatusHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := pipedriveService.processing.Get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Processing ID not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: job.Status != ProcessingFailed,
+			Message: "Processing " + job.Status,
+			Data:    job,
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// loadCampaignRecentContactDays parses CAMPAIGN_RECENT_CONTACT_DAYS (JSON: campaign -> days)
+func loadCampaignRecentContactDays(raw string) map[string]int {
+	days := make(map[string]int)
+	if raw == "" {
+		return days
+	}
+	if err := json.Unmarshal([]byte(raw), &days); err != nil {
+		log.Printf("⚠️ Invalid CAMPAIGN_RECENT_CONTACT_DAYS, ignoring: %v", err)
+		return make(map[string]int)
+	}
+	return days
+}
+
+// RecentContactDaysFor returns the campaign's suppression window in days, falling back to
+// RECENT_CONTACT_DAYS; 0 disables the check
+func (c *Config) RecentContactDaysFor(campaign string) int {
+	if days, ok := c.CampaignRecentContactDays[campaign]; ok {
+		return days
+	}
+	return c.RecentContactDays
+}
+
+// countsAsRecentContact reports whether a Pipedrive activity type counts as having spoken to the person
+func (c *Config) countsAsRecentContact(activityType string) bool {
+	for _, t := range c.RecentContactTypes {
+		if strings.EqualFold(t, activityType) {
+			return true
+		}
+	}
+	return false
+}
+
+// LastContact returns when the person was last reached on one of our calls (connected, ended, opted
+// out or analyzed without voicemail), or the zero time
+func (s *CallEventStore) LastContact(personID int) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var last time.Time
+	for _, events := range s.events {
+		for _, event := range events {
+			if event.PersonID != personID || !event.Timestamp.After(last) {
+				continue
+			}
+			switch event.Type {
+			case CallEventConnected, CallEventEnded, CallEventOptedOut:
+				last = event.Timestamp
+			case CallEventAnalyzed:
+				if event.Analysis == nil || !event.Analysis.Call.CallAnalysis.InVoicemail {
+					last = event.Timestamp
+				}
+			}
+		}
+	}
+	return last
+}
+
+// recentContact returns a description of the person's latest contact since the cutoff, from our
+// call records and then the person's Pipedrive activity history, or "" when there is none. Only
+// activities marked done count, by when they were done, and the ones we log ourselves are left to
+// the call records so a planned or automated activity doesn't suppress the lead
+func (p *PipedriveService) recentContact(personID int, since time.Time) (string, error) {
+	if last := p.callEvents.LastContact(personID); last.After(since) {
+		return "call on " + last.Format(time.RFC3339), nil
+	}
+	if !p.config.HasPipedriveConfig() {
+		return "", nil
+	}
+	activities, err := p.ListPersonActivities(personID)
+	if err != nil {
+		return "", err
+	}
+	for _, activity := range activities {
+		if !activity.Done || p.isOwnActivity(activity.CreatorUserID, activity.Subject) || !p.config.countsAsRecentContact(activity.Type) {
+			continue
+		}
+		done, err := parsePipedriveTime(activity.MarkedAsDoneTime)
+		if err == nil && done.After(since) {
+			return fmt.Sprintf("%s activity %d (%q) done at %s", activity.Type, activity.ID, activity.Subject, activity.MarkedAsDoneTime), nil
+		}
+	}
+	return "", nil
+}
+
+// suppressRecentlyContacted drops a lead whose person was contacted within the campaign's
+// RECENT_CONTACT_DAYS, so list re-imports do not call people again; it reports whether the lead
+// was suppressed
+func (p *PipedriveService) suppressRecentlyContacted(payload PipedriveLeadWebhookPayload) bool {
+	campaign := p.config.leadCampaign(payload)
+	days := p.config.RecentContactDaysFor(campaign)
+	if days <= 0 || payload.Data.PersonID == 0 {
+		return false
+	}
+	detail, err := p.recentContact(payload.Data.PersonID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		p.logf("⚠️ Warning: Could not check recent contact for person %d, dialing anyway: %v", payload.Data.PersonID, err)
+		return false
+	}
+	if detail == "" {
+		return false
+	}
+
+	detail = fmt.Sprintf("lead %s (campaign %q): contacted within %d days, %s", payload.Data.ID, campaign, days, detail)
+	p.logf("🔕 Skipping call for %s", detail)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "ai_call_suppressed_recent_contact", PersonID: payload.Data.PersonID, Detail: detail})
+	return true
+}
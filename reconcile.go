@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reconciliation outcomes per call
+const (
+	ReconcileOK       = "ok"
+	ReconcileRepaired = "repaired"
+	ReconcilePending  = "pending" // Not analyzed by Retell yet
+	ReconcileFailed   = "failed"
+)
+
+// maxReconciliationReports is how many daily reports are kept
+const maxReconciliationReports = 30
+
+// ReconciliationItem is a call that needed attention during reconciliation
+type ReconciliationItem struct {
+	CallID   string `json:"call_id"`
+	PersonID int    `json:"person_id,omitempty"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// ReconciliationReport summarizes one reconciliation run
+type ReconciliationReport struct {
+	Date       string               `json:"date"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt time.Time            `json:"finished_at"`
+	Checked    int                  `json:"checked"`
+	OK         int                  `json:"ok"`
+	Repaired   int                  `json:"repaired"`
+	Pending    int                  `json:"pending"`
+	Failed     int                  `json:"failed"`
+	Items      []ReconciliationItem `json:"items"`
+	Error      string               `json:"error,omitempty"`
+}
+
+// ReconciliationLog keeps the most recent reconciliation reports
+type ReconciliationLog struct {
+	mu      sync.Mutex
+	reports []ReconciliationReport
+}
+
+// NewReconciliationLog creates an empty reconciliation log
+func NewReconciliationLog() *ReconciliationLog {
+	return &ReconciliationLog{}
+}
+
+// Add stores a report, dropping the oldest beyond the limit
+func (l *ReconciliationLog) Add(report ReconciliationReport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reports = append(l.reports, report)
+	if len(l.reports) > maxReconciliationReports {
+		l.reports = l.reports[len(l.reports)-maxReconciliationReports:]
+	}
+}
+
+// List returns the reports, most recent first
+func (l *ReconciliationLog) List() []ReconciliationReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list := make([]ReconciliationReport, 0, len(l.reports))
+	for i := len(l.reports) - 1; i >= 0; i-- {
+		list = append(list, l.reports[i])
+	}
+	return list
+}
+
+// retellListedCall is a call from list-calls with its webhook-shaped payload
+type retellListedCall struct {
+	Payload RetellCallAnalyzedPayload
+	Details RetellCallDetails
+	Account RetellAccount // Account the call was listed on
+}
+
+// ListRetellCalls returns the calls started between two times on every Retell account
+func (p *PipedriveService) ListRetellCalls(from, to time.Time) ([]retellListedCall, error) {
+	var calls []retellListedCall
+	for _, account := range p.config.retellAccounts() {
+		listed, err := p.listRetellAccountCalls(account, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("%s account: %v", account.Name, err)
+		}
+		calls = append(calls, listed...)
+	}
+	return calls, nil
+}
+
+// listRetellAccountCalls returns the calls started between two times on one Retell account
+func (p *PipedriveService) listRetellAccountCalls(account RetellAccount, from, to time.Time) ([]retellListedCall, error) {
+	var calls []retellListedCall
+	paginationKey := ""
+	for {
+		request := map[string]interface{}{
+			"filter_criteria": map[string]interface{}{
+				"start_timestamp": map[string]interface{}{
+					"lower_threshold": from.UnixMilli(),
+					"upper_threshold": to.UnixMilli(),
+				},
+			},
+			"limit": 1000,
+		}
+		if paginationKey != "" {
+			request["pagination_key"] = paginationKey
+		}
+		body, err := p.makeRetellAccountRequest(account, "POST", "/v2/list-calls", request)
+		if err != nil {
+			return nil, err
+		}
+		var page []json.RawMessage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse calls: %v", err)
+		}
+		for _, raw := range page {
+			var call retellListedCall
+			if err := json.Unmarshal(raw, &call.Payload.Call); err != nil {
+				return nil, fmt.Errorf("failed to parse call: %v", err)
+			}
+			if err := json.Unmarshal(raw, &call.Details); err != nil {
+				return nil, fmt.Errorf("failed to parse call: %v", err)
+			}
+			call.Payload.Event = "call_analyzed"
+			call.Account = account
+			calls = append(calls, call)
+		}
+		if len(page) < 1000 {
+			return calls, nil
+		}
+		paginationKey = calls[len(calls)-1].Payload.Call.CallID
+	}
+}
+
+// hasCallActivity returns true if Pipedrive already has the analyzed-call activity for a call: the
+// one recorded on its mapping, or an analyzed-call activity on the person due at the call's start.
+// Without NOTE_ON_ANALYSIS no activity is written, so the recorded CRM update counts instead.
+func (p *PipedriveService) hasCallActivity(callID string, mapping CallMapping, start time.Time) (bool, error) {
+	if !p.config.NoteOnAnalysis {
+		return latestCallEvent(p.callEvents.Events(callID), CallEventCRMUpdated) != nil, nil
+	}
+	if mapping.ActivityID != 0 {
+		return true, nil
+	}
+	activity, err := p.findCallActivity(mapping.PersonID, 0, start)
+	return activity != nil, err
+}
+
+// findCallActivity returns a person's analyzed-call activity: the one with activityID when
+// known, else the analyzed-call activity due at the call's start. It returns nil when none is.
+func (p *PipedriveService) findCallActivity(personID, activityID int, start time.Time) (*PipedriveActivity, error) {
+	activities, err := p.ListPersonActivities(personID)
+	if err != nil {
+		return nil, err
+	}
+	dueDate, dueTime := pipedriveDueDate(start), pipedriveDueTime(start)[:5]
+	for i, activity := range activities {
+		if activityID != 0 {
+			if activity.ID == activityID {
+				return &activities[i], nil
+			}
+			continue
+		}
+		if (strings.HasPrefix(activity.Subject, "AI Call Analyzed") || strings.HasPrefix(activity.Subject, "AI Voicemail Left")) &&
+			activity.DueDate == dueDate && strings.HasPrefix(activity.DueTime, dueTime) {
+			return &activities[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// repairCall writes an analyzed call Pipedrive is missing, under the same claim as its
+// call_analyzed webhook so the two never both write it
+func (p *PipedriveService) repairCall(callID string) (string, string) {
+	release, ok := p.claim("analyzed:" + callID)
+	if !ok {
+		return ReconcileOK, ""
+	}
+	err := p.refreshCall(callID)
+	if errors.Is(err, ErrCallAlreadyLogged) {
+		return ReconcileOK, ""
+	}
+	if err != nil {
+		release()
+		return ReconcileFailed, "repair failed: " + err.Error()
+	}
+	return ReconcileRepaired, ""
+}
+
+// Reconcile checks every call placed on a day has its Pipedrive activity, repairing the ones that don't
+func (p *PipedriveService) Reconcile(date string) ReconciliationReport {
+	report := ReconciliationReport{Date: date, StartedAt: time.Now(), Items: []ReconciliationItem{}}
+	defer func() {
+		report.FinishedAt = time.Now()
+		p.reconciliations.Add(report)
+		p.logf("🧾 Reconciliation for %s: %d checked, %d ok, %d repaired, %d pending, %d failed",
+			date, report.Checked, report.OK, report.Repaired, report.Pending, report.Failed)
+	}()
+
+	day, err := time.ParseInLocation("2006-01-02", date, p.kpis.location)
+	if err != nil {
+		report.Error = "invalid date: " + err.Error()
+		return report
+	}
+	calls, err := p.ListRetellCalls(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	for _, call := range calls {
+		callID := call.Payload.Call.CallID
+		// Only calls this service placed carry its dynamic variables
+		if _, ours := call.Details.DynamicVariables["lead_title"]; !ours {
+			continue
+		}
+		report.Checked++
+
+		item := ReconciliationItem{CallID: callID}
+		if len(call.Details.CallAnalysis) == 0 || string(call.Details.CallAnalysis) == "null" {
+			item.Status = ReconcilePending
+		} else if latestCallEvent(p.callEvents.Events(callID), CallEventCRMUpdated) != nil || !p.config.NoteOnAnalysis {
+			item.Status = ReconcileOK
+		} else {
+			item.Status, item.Detail = p.repairCall(callID)
+			if mapping, ok := p.getCallMapping(callID); ok {
+				item.PersonID = mapping.PersonID
+			}
+		}
+
+		switch item.Status {
+		case ReconcileOK:
+			report.OK++
+			continue
+		case ReconcileRepaired:
+			report.Repaired++
+		case ReconcilePending:
+			report.Pending++
+		case ReconcileFailed:
+			report.Failed++
+		}
+		report.Items = append(report.Items, item)
+	}
+	return report
+}
+
+// runDaily calls fn with the previous day's date every day at the given hour
+func runDaily(hour int, location *time.Location, fn func(date string)) {
+	for {
+		now := time.Now().In(location)
+		next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, location)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+		fn(next.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+}
+
+// runReconciliation reconciles the previous day every night at RECONCILE_HOUR
+func (p *PipedriveService) runReconciliation() {
+	runDaily(p.config.ReconcileHour, p.kpis.location, func(date string) {
+		if _, ok := p.claim("reconcile:" + date); ok {
+			p.Reconcile(date)
+		}
+	})
+}
+
+// ReconcileHandler reconciles a day now (?date=2006-01-02, default today)
+func ReconcileHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		date := c.DefaultQuery("date", time.Now().In(pipedriveService.kpis.location).Format("2006-01-02"))
+		report := pipedriveService.Reconcile(date)
+		status := http.StatusOK
+		if report.Error != "" {
+			status = http.StatusBadGateway
+		}
+		c.JSON(status, WebhookResponse{
+			Success: report.Error == "",
+			Message: fmt.Sprintf("Reconciled %d calls for %s", report.Checked, date),
+			Data:    report,
+		})
+	}
+}
+
+// ReconciliationReportHandler lists recent reconciliation reports
+func ReconciliationReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reports := pipedriveService.reconciliations.List()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d reconciliation reports", len(reports)),
+			Data:    reports,
+		})
+	}
+}
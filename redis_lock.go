@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// releaseScript deletes a lock only if it still holds our token
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// maxIdleRedisConns is how many authenticated connections are kept open between commands
+const maxIdleRedisConns = 8
+
+// maxRedisUpdateAttempts is how often a watched update is retried when another replica changed the key
+const maxRedisUpdateAttempts = 10
+
+// RedisLocker takes leases in Redis so only one replica acts on a lead or call
+type RedisLocker struct {
+	addr     string
+	password string
+	db       int
+	useTLS   bool
+	prefix   string
+	ttl      time.Duration
+	idle     chan *redisConn // Open connections ready for the next command
+}
+
+// redisConn is an authenticated connection with its reply reader
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+	pooled bool // Taken from the idle pool, so the server may have closed it meanwhile
+}
+
+// redisReplyError is an error reply from Redis; the connection stays usable
+type redisReplyError struct {
+	command string
+	message string
+}
+
+func (e *redisReplyError) Error() string {
+	return fmt.Sprintf("Redis %s failed: %s", e.command, e.message)
+}
+
+// newRedisLocker creates a Redis locker, or returns nil when REDIS_URL is not configured
+func newRedisLocker(config *Config) *RedisLocker {
+	if config.RedisURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(config.RedisURL)
+	if err != nil || (parsed.Scheme != "redis" && parsed.Scheme != "rediss") {
+		log.Printf("⚠️ Invalid REDIS_URL, distributed locks disabled: %v", err)
+		return nil
+	}
+	locker := &RedisLocker{
+		addr:   parsed.Host,
+		useTLS: parsed.Scheme == "rediss",
+		prefix: config.LockPrefix,
+		ttl:    time.Duration(config.LockTTL) * time.Second,
+		idle:   make(chan *redisConn, maxIdleRedisConns),
+	}
+	if !strings.Contains(locker.addr, ":") {
+		locker.addr += ":6379"
+	}
+	if password, ok := parsed.User.Password(); ok {
+		locker.password = password
+	}
+	if db := strings.TrimPrefix(parsed.Path, "/"); db != "" {
+		locker.db, _ = strconv.Atoi(db)
+	}
+	return locker
+}
+
+// Acquire takes a lease on key, returning its token and false if another replica holds it
+func (r *RedisLocker) Acquire(key string) (string, bool, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", false, err
+	}
+	value := hex.EncodeToString(token)
+	reply, err := r.do("SET", r.prefix+key, value, "NX", "PX", strconv.FormatInt(r.ttl.Milliseconds(), 10))
+	if err != nil {
+		return "", false, err
+	}
+	return value, reply == "OK", nil
+}
+
+// Release drops a lease if it is still ours
+func (r *RedisLocker) Release(key, token string) error {
+	_, err := r.do("EVAL", releaseScript, "1", r.prefix+key, token)
+	return err
+}
+
+// Incr increments a shared counter and returns its new value
+func (r *RedisLocker) Incr(key string) (int64, error) {
+	reply, err := r.do("INCR", r.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(reply, 10, 64)
+}
+
+// do runs one command and returns a simple, integer or bulk reply
+func (r *RedisLocker) do(args ...string) (string, error) {
+	var reply string
+	err := r.with(func(conn *redisConn) error {
+		var err error
+		reply, err = redisCommand(conn, conn.reader, args...)
+		return err
+	})
+	return reply, err
+}
+
+// doArray runs one command that answers with an array of bulk strings, e.g. HGETALL or MGET
+func (r *RedisLocker) doArray(args ...string) ([]string, error) {
+	var items []string
+	err := r.with(func(conn *redisConn) error {
+		if err := writeRedisCommand(conn, args...); err != nil {
+			return err
+		}
+		var err error
+		items, err = readRedisArray(conn.reader, args[0])
+		return err
+	})
+	return items, err
+}
+
+// scan returns every key matching pattern, walking SCAN's cursor
+func (r *RedisLocker) scan(pattern string) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		err := r.with(func(conn *redisConn) error {
+			if err := writeRedisCommand(conn, "SCAN", cursor, "MATCH", pattern, "COUNT", "1000"); err != nil {
+				return err
+			}
+			line, err := readRedisLine(conn.reader)
+			if err != nil {
+				return err
+			}
+			if line != "*2" {
+				return fmt.Errorf("unexpected Redis reply: %q", line)
+			}
+			if cursor, err = readRedisReply(conn.reader, "SCAN"); err != nil {
+				return err
+			}
+			page, err := readRedisArray(conn.reader, "SCAN")
+			keys = append(keys, page...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if cursor == "0" {
+			return keys, nil
+		}
+	}
+}
+
+// update changes a key's value atomically with WATCH/MULTI/EXEC, retrying when another replica
+// wrote it in between. change gets the current value ("" when missing) and returns the new one,
+// or false to leave the key alone; a positive ttl sets the key's expiry. It reports whether the
+// key was written.
+func (r *RedisLocker) update(key string, ttl time.Duration, change func(value string) (string, bool)) (bool, error) {
+	for attempt := 0; attempt < maxRedisUpdateAttempts; attempt++ {
+		written, committed := false, false
+		err := r.with(func(conn *redisConn) error {
+			// Any failure leaves the connection watching or inside MULTI, so it must not go back
+			// to the pool: %v drops the reply error type and has the connection closed
+			var err error
+			if written, committed, err = watchedSet(conn, key, ttl, change); err != nil {
+				return fmt.Errorf("%v", err)
+			}
+			return nil
+		})
+		if err != nil || committed {
+			return written, err
+		}
+	}
+	return false, fmt.Errorf("Redis key %s kept changing, gave up after %d attempts", key, maxRedisUpdateAttempts)
+}
+
+// watchedSet runs one WATCH/GET/MULTI/SET/EXEC round of update on conn, reporting whether the
+// key was written and whether the round is final (false when the key changed since WATCH)
+func watchedSet(conn *redisConn, key string, ttl time.Duration, change func(value string) (string, bool)) (bool, bool, error) {
+	if _, err := redisCommand(conn, conn.reader, "WATCH", key); err != nil {
+		return false, false, err
+	}
+	value, err := redisCommand(conn, conn.reader, "GET", key)
+	if err != nil {
+		return false, false, err
+	}
+	value, ok := change(value)
+	if !ok {
+		_, err := redisCommand(conn, conn.reader, "UNWATCH")
+		return false, true, err
+	}
+	set := []string{"SET", key, value}
+	if ttl > 0 {
+		set = append(set, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	if _, err := redisCommand(conn, conn.reader, "MULTI"); err != nil {
+		return false, false, err
+	}
+	if _, err := redisCommand(conn, conn.reader, set...); err != nil {
+		return false, false, err
+	}
+	if err := writeRedisCommand(conn, "EXEC"); err != nil {
+		return false, false, err
+	}
+	line, err := readRedisLine(conn.reader)
+	if err != nil {
+		return false, false, err
+	}
+	if line == "*-1" {
+		return false, false, nil // The key changed since WATCH, so nothing was written
+	}
+	if line != "*1" {
+		return false, false, fmt.Errorf("unexpected Redis reply: %q", line)
+	}
+	if _, err := readRedisReply(conn.reader, "SET"); err != nil {
+		return false, false, err
+	}
+	return true, true, nil
+}
+
+// with runs fn on a pooled connection, retrying once on a fresh one when a pooled connection
+// turns out to have been closed by the server
+func (r *RedisLocker) with(fn func(conn *redisConn) error) error {
+	conn, err := r.get()
+	if err != nil {
+		return err
+	}
+	err = fn(conn)
+	var replyErr *redisReplyError
+	if err != nil && !errors.As(err, &replyErr) && conn.pooled {
+		conn.Close()
+		if conn, err = r.dial(); err != nil {
+			return err
+		}
+		err = fn(conn)
+	}
+	r.put(conn, err)
+	return err
+}
+
+// get takes an idle connection or opens a new one
+func (r *RedisLocker) get() (*redisConn, error) {
+	select {
+	case conn := <-r.idle:
+		conn.pooled = true
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, nil
+	default:
+		return r.dial()
+	}
+}
+
+// put returns a connection to the pool after its command, closing it when the command broke
+// the connection or the pool is full
+func (r *RedisLocker) put(conn *redisConn, err error) {
+	var replyErr *redisReplyError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.Close()
+		return
+	}
+	select {
+	case r.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// dial opens an authenticated connection to the configured database
+func (r *RedisLocker) dial() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	var conn net.Conn
+	var err error
+	if r.useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", r.addr, &tls.Config{MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", r.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	if r.password != "" {
+		if _, err := redisCommand(conn, reader, "AUTH", r.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := redisCommand(conn, reader, "SELECT", strconv.Itoa(r.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &redisConn{Conn: conn, reader: reader}, nil
+}
+
+// redisCommand writes a RESP command and reads its reply
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...string) (string, error) {
+	if err := writeRedisCommand(conn, args...); err != nil {
+		return "", err
+	}
+	return readRedisReply(reader, args[0])
+}
+
+// writeRedisCommand writes a RESP command
+func writeRedisCommand(conn net.Conn, args ...string) error {
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(command.String())); err != nil {
+		return fmt.Errorf("failed to write Redis command: %v", err)
+	}
+	return nil
+}
+
+// readRedisLine reads one reply line without its CRLF
+func readRedisLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read Redis reply: %v", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty Redis reply")
+	}
+	return line, nil
+}
+
+// readRedisReply reads a simple, integer or bulk reply to command
+func readRedisReply(reader *bufio.Reader, command string) (string, error) {
+	line, err := readRedisLine(reader)
+	if err != nil {
+		return "", err
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", &redisReplyError{command: command, message: line[1:]}
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return "", nil // Nil reply, e.g. SET NX on a held key
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return "", fmt.Errorf("failed to read Redis reply: %v", err)
+		}
+		return string(data[:size]), nil
+	}
+	return "", fmt.Errorf("unexpected Redis reply: %q", line)
+}
+
+// readRedisArray reads an array of simple, integer or bulk replies to command; a nil array is empty
+func readRedisArray(reader *bufio.Reader, command string) ([]string, error) {
+	line, err := readRedisLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if line[0] == '-' {
+		return nil, &redisReplyError{command: command, message: line[1:]}
+	}
+	if line[0] != '*' {
+		return nil, fmt.Errorf("unexpected Redis reply: %q", line)
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 {
+		return nil, nil
+	}
+	items := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		item, err := readRedisReply(reader, command)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// localLeases stands in for Redis when REDIS_URL is not set, so a single replica still acts on a
+// lead or call once while its lease lasts
+type localLeases struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	leases map[string]time.Time // Key -> expiry
+}
+
+// newLocalLeases creates process-local leases lasting LOCK_TTL_SECONDS
+func newLocalLeases(config *Config) *localLeases {
+	return &localLeases{ttl: time.Duration(config.LockTTL) * time.Second, leases: make(map[string]time.Time)}
+}
+
+// acquire takes key unless an unexpired lease holds it, returning the lease's expiry as its token
+func (l *localLeases) acquire(key string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if expiry, held := l.leases[key]; held && now.Before(expiry) {
+		return time.Time{}, false
+	}
+	for held, expiry := range l.leases {
+		if !now.Before(expiry) {
+			delete(l.leases, held)
+		}
+	}
+	expiry := now.Add(l.ttl)
+	l.leases[key] = expiry
+	return expiry, true
+}
+
+// release drops key's lease if it is still the one taken at expiry
+func (l *localLeases) release(key string, expiry time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.leases[key].Equal(expiry) {
+		delete(l.leases, key)
+	}
+}
+
+// claim takes the lease for key so only one replica acts on it; release undoes it (e.g. after a failure)
+func (p *PipedriveService) claim(key string) (release func(), ok bool) {
+	if p.locker == nil {
+		if p.leases == nil {
+			return func() {}, true
+		}
+		expiry, acquired := p.leases.acquire(key)
+		if !acquired {
+			p.logf("🔒 %s is already being handled, skipping", key)
+			return nil, false
+		}
+		return func() { p.leases.release(key, expiry) }, true
+	}
+	token, acquired, err := p.locker.Acquire(key)
+	if err != nil {
+		// A duplicate call or message reaches a person, while skipped work is picked up again by
+		// webhook retries, the gap detector and reconciliation; so the work is skipped unless
+		// LOCK_FAIL_OPEN says otherwise
+		if p.config.LockFailOpen {
+			p.logf("⚠️ Warning: Could not take lock %s, proceeding without it: %v", key, err)
+			return func() {}, true
+		}
+		p.logf("❌ Could not take lock %s, skipping: %v", key, err)
+		return nil, false
+	}
+	if !acquired {
+		p.logf("🔒 %s is being handled by another replica, skipping", key)
+		return nil, false
+	}
+	return func() {
+		if err := p.locker.Release(key, token); err != nil {
+			p.logf("⚠️ Warning: Failed to release lock %s: %v", key, err)
+		}
+	}, true
+}
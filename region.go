@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrWrongRegion is returned when a tenant's data is handled outside its region
+var ErrWrongRegion = errors.New("tenant data belongs to another region")
+
+// RegionPolicy decides which tenants this deployment may process
+type RegionPolicy struct {
+	Region        string            // Region of this deployment; "" disables region checks
+	TenantRegions map[string]string // Tenant -> region
+}
+
+// RegionPolicy returns the region policy from config
+func (c *Config) RegionPolicy() RegionPolicy {
+	return RegionPolicy{Region: c.Region, TenantRegions: c.TenantRegions}
+}
+
+// RegionOf returns the region a tenant's data lives in
+func (r RegionPolicy) RegionOf(tenant string) string {
+	if region, ok := r.TenantRegions[tenant]; ok && region != "" {
+		return strings.ToLower(region)
+	}
+	return r.Region
+}
+
+// Allows returns true if this deployment may process a tenant's data; with region checks on, a
+// tenant missing from TENANT_REGIONS is refused rather than assumed to be local
+func (r RegionPolicy) Allows(tenant string) bool {
+	if r.Region == "" {
+		return true
+	}
+	region, ok := r.TenantRegions[tenant]
+	return ok && strings.ToLower(region) == r.Region
+}
+
+// RegionStats counts webhooks rejected for being sent to the wrong region
+type RegionStats struct {
+	mu       sync.Mutex
+	Rejected map[string]int64 `json:"rejected"` // Keyed by reason
+}
+
+// regionStats is the process-wide region rejection counter
+var regionStats = &RegionStats{Rejected: make(map[string]int64)}
+
+// reject counts and logs a misrouted webhook without touching its payload
+func (s *RegionStats) reject(reason, path string) {
+	s.mu.Lock()
+	s.Rejected[reason]++
+	s.mu.Unlock()
+	log.Printf("🌍 Rejected %s: %s", path, reason)
+}
+
+// snapshot returns a copy of the rejection counters
+func (s *RegionStats) snapshot() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int64, len(s.Rejected))
+	for reason, count := range s.Rejected {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// RegionGuardMiddleware rejects webhooks whose routing key or agent tenant belongs to another region
+func RegionGuardMiddleware(config *Config) gin.HandlerFunc {
+	policy := config.RegionPolicy()
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if policy.Region == "" || c.Request.Method != http.MethodPost ||
+			!(strings.HasPrefix(path, "/webhook/") || strings.HasPrefix(path, "/functions/")) {
+			c.Next()
+			return
+		}
+
+		// Routing key configured on the webhook URL or sent by a regional proxy
+		key := c.GetHeader("X-Region")
+		if key == "" {
+			key = c.Query("region")
+		}
+		if key != "" && strings.ToLower(key) != policy.Region {
+			regionStats.reject("routing_key", path)
+			c.AbortWithStatusJSON(http.StatusMisdirectedRequest, WebhookResponse{
+				Success: false,
+				Message: "Webhook routed to the wrong region",
+			})
+			return
+		}
+
+		// Retell payloads identify the agent, and with it the tenant; one that doesn't can't be
+		// placed in a region and is refused
+		if webhookSources[path] != WebhookSourceRetell {
+			c.Next()
+			return
+		}
+		var agentID string
+		if c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err == nil {
+				c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+				var peek struct {
+					AgentID string `json:"agent_id"`
+					Call    struct {
+						AgentID string `json:"agent_id"`
+					} `json:"call"`
+				}
+				if json.Unmarshal(body, &peek) == nil {
+					agentID = peek.Call.AgentID
+					if agentID == "" {
+						agentID = peek.AgentID
+					}
+				}
+			}
+		}
+		if agentID == "" {
+			regionStats.reject("missing_agent", path)
+			c.AbortWithStatusJSON(http.StatusMisdirectedRequest, WebhookResponse{
+				Success: false,
+				Message: "Webhook names no agent, so its region is unknown",
+			})
+			return
+		}
+		if !policy.Allows(config.TenantFor(agentID)) {
+			regionStats.reject("tenant_region", path)
+			c.AbortWithStatusJSON(http.StatusMisdirectedRequest, WebhookResponse{
+				Success: false,
+				Message: "Tenant is served by another region",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RegionStatusHandler reports this deployment's region, tenant regions and rejected webhooks
+func RegionStatusHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Region status",
+			Data: gin.H{
+				"region":         pipedriveService.config.Region,
+				"tenant_regions": pipedriveService.config.TenantRegions,
+				"rejected":       regionStats.snapshot(),
+			},
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reminder statuses
+const (
+	ReminderScheduled = "scheduled"
+	ReminderSent      = "sent"
+	ReminderCancelled = "cancelled"
+	ReminderSkipped   = "skipped"
+	ReminderFailed    = "failed"
+)
+
+// Reminder is a pending or completed reminder for a booked meeting
+type Reminder struct {
+	BookingID  int       `json:"booking_id,omitempty"`
+	BookingUID string    `json:"booking_uid,omitempty"`
+	PersonID   int       `json:"person_id"`
+	Title      string    `json:"title"`
+	StartTime  time.Time `json:"start_time"`
+	SendAt     time.Time `json:"send_at"`
+	Channel    string    `json:"channel"`
+	ActivityID int       `json:"activity_id,omitempty"` // Pipedrive meeting activity, re-checked without the Cal.com API
+	Tenant     string    `json:"tenant,omitempty"`      // Tenant of the booking, for the meeting time's locale
+	Status     string    `json:"status"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+// bookingKey identifies a Cal.com booking: its UID, which every payload generation carries, or
+// the numeric ID for v1 payloads without one
+func bookingKey(bookingID int, bookingUID string) string {
+	if bookingUID != "" {
+		return bookingUID
+	}
+	return strconv.Itoa(bookingID)
+}
+
+// key is the booking the reminder is for
+func (r *Reminder) key() string {
+	return bookingKey(r.BookingID, r.BookingUID)
+}
+
+// ReminderScheduler keeps reminders per booking; pending ones are scheduled jobs, so they survive
+// a restart
+type ReminderScheduler struct {
+	mu        sync.Mutex
+	reminders map[string]*Reminder // Keyed by bookingKey
+	jobs      *JobScheduler
+}
+
+// NewReminderScheduler creates a new reminder scheduler
+func NewReminderScheduler(jobs *JobScheduler) *ReminderScheduler {
+	return &ReminderScheduler{reminders: make(map[string]*Reminder), jobs: jobs}
+}
+
+// reminderJobID is the scheduled job ID of a booking's reminder
+func reminderJobID(key string) string {
+	return "reminder:" + key
+}
+
+// Cancel stops the pending reminder for a booking
+func (s *ReminderScheduler) Cancel(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reminder, ok := s.reminders[key]
+	if !ok || reminder.Status != ReminderScheduled {
+		return
+	}
+	s.jobs.Cancel(reminderJobID(key))
+	reminder.Status = ReminderCancelled
+	log.Printf("🔕 Cancelled reminder for booking %s", key)
+}
+
+// finish records the outcome of a reminder
+func (s *ReminderScheduler) finish(key string, status, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reminder, ok := s.reminders[key]; ok {
+		reminder.Status = status
+		reminder.Detail = detail
+	}
+}
+
+// List returns all reminders sorted by send time
+func (s *ReminderScheduler) List() []Reminder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Reminder, 0, len(s.reminders))
+	for _, reminder := range s.reminders {
+		list = append(list, *reminder)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].SendAt.Before(list[j].SendAt) })
+	return list
+}
+
+// scheduleReminder schedules a reminder before a booked meeting, replacing any earlier one for the
+// booking; activityID is the meeting's Pipedrive activity, or 0 when none was created
+func (p *PipedriveService) scheduleReminder(bookingID int, bookingUID string, personID int, title string, startTime time.Time, activityID int) {
+	if !p.config.RemindersEnabled {
+		return
+	}
+	if !startTime.After(time.Now()) {
+		return
+	}
+
+	sendAt := startTime.Add(-time.Duration(p.config.ReminderHoursBefore) * time.Hour)
+	if sendAt.Before(time.Now()) {
+		sendAt = time.Now()
+	}
+
+	key := bookingKey(bookingID, bookingUID)
+	reminder := &Reminder{
+		BookingID:  bookingID,
+		BookingUID: bookingUID,
+		PersonID:   personID,
+		Title:      title,
+		StartTime:  startTime.UTC(),
+		SendAt:     sendAt.UTC(),
+		Channel:    strings.ToLower(p.config.ReminderChannel),
+		ActivityID: activityID,
+		Tenant:     p.bookingTenant(personID),
+		Status:     ReminderScheduled,
+	}
+	p.reminders.mu.Lock()
+	defer p.reminders.mu.Unlock()
+	if err := p.schedules.Schedule(reminderJobID(key), JobMeetingReminder, reminder.SendAt, reminder); err != nil {
+		p.logf("❌ Failed to schedule the reminder for booking %s: %v", key, err)
+		return
+	}
+	p.reminders.reminders[key] = reminder
+	p.logf("⏰ Scheduled %s reminder for booking %s at %s", reminder.Channel, key, sendAt.Format(time.RFC3339))
+}
+
+// restoreReminders lists the reminders saved in SCHEDULE_FILE again
+func (p *PipedriveService) restoreReminders() {
+	p.reminders.mu.Lock()
+	defer p.reminders.mu.Unlock()
+	for _, job := range p.schedules.Jobs(JobMeetingReminder) {
+		var reminder Reminder
+		if err := json.Unmarshal(job.Payload, &reminder); err != nil {
+			p.logf("⚠️ Warning: Ignoring unreadable reminder %s: %v", job.ID, err)
+			continue
+		}
+		p.reminders.reminders[reminder.key()] = &reminder
+	}
+}
+
+// runReminderJob sends a reminder whose time has come, unless it was cancelled or replaced meanwhile
+func (p *PipedriveService) runReminderJob(raw json.RawMessage) {
+	var job Reminder
+	if err := json.Unmarshal(raw, &job); err != nil {
+		p.logf("❌ Failed to read reminder: %v", err)
+		return
+	}
+	p.reminders.mu.Lock()
+	reminder, ok := p.reminders.reminders[job.key()]
+	pending := ok && reminder.SendAt.Equal(job.SendAt) && reminder.Status == ReminderScheduled
+	p.reminders.mu.Unlock()
+	if pending {
+		p.sendReminder(reminder)
+	}
+}
+
+// sendReminder re-checks the booking and sends the reminder by SMS or AI call
+func (p *PipedriveService) sendReminder(reminder *Reminder) {
+	key := reminder.key()
+	release, ok := p.claim(fmt.Sprintf("reminder:%s:%d", key, reminder.SendAt.Unix()))
+	if !ok {
+		p.reminders.finish(key, ReminderSkipped, "sent by another replica")
+		return
+	}
+
+	// The booking may have been cancelled or moved after the reminder was scheduled; the bookings API
+	// only looks bookings up by numeric ID, so otherwise the meeting activity in Pipedrive is checked
+	switch {
+	case p.cal != nil && reminder.BookingID != 0:
+		booking, err := p.cal.GetBooking(reminder.BookingID)
+		if err != nil {
+			p.logf("⚠️ Warning: Could not confirm booking %d, skipping reminder: %v", reminder.BookingID, err)
+			release()
+			p.reminders.finish(key, ReminderFailed, err.Error())
+			return
+		}
+		if !strings.EqualFold(booking.Status, "accepted") {
+			p.logf("🔕 Booking %d is %s, skipping reminder", reminder.BookingID, booking.Status)
+			p.reminders.finish(key, ReminderSkipped, "booking is "+strings.ToLower(booking.Status))
+			return
+		}
+		if start, err := ParseTimestamp(booking.StartTime, p.config.naiveLocation()); err == nil && !start.UTC.Equal(reminder.StartTime) {
+			p.logf("🔁 Booking %d moved to %s, rescheduling reminder", reminder.BookingID, start)
+			release()
+			p.scheduleReminder(reminder.BookingID, reminder.BookingUID, reminder.PersonID, reminder.Title, start.UTC, reminder.ActivityID)
+			return
+		}
+	case reminder.ActivityID != 0:
+		activity, err := p.findCallActivity(reminder.PersonID, reminder.ActivityID, reminder.StartTime)
+		if err != nil {
+			p.logf("⚠️ Warning: Could not confirm meeting activity %d, skipping reminder: %v", reminder.ActivityID, err)
+			release()
+			p.reminders.finish(key, ReminderFailed, err.Error())
+			return
+		}
+		if activity == nil {
+			p.logf("🔕 Meeting activity %d is gone, skipping reminder for booking %s", reminder.ActivityID, key)
+			p.reminders.finish(key, ReminderSkipped, "meeting activity deleted")
+			return
+		}
+		start, err := parsePipedriveTime(activity.DueDate + " " + activity.DueTime)
+		if err == nil && activity.DueTime != "" && !start.Truncate(time.Minute).Equal(reminder.StartTime.Truncate(time.Minute)) {
+			p.logf("🔁 Meeting activity %d moved to %s, rescheduling reminder", reminder.ActivityID, start.Format(time.RFC3339))
+			release()
+			p.scheduleReminder(reminder.BookingID, reminder.BookingUID, reminder.PersonID, reminder.Title, start, reminder.ActivityID)
+			return
+		}
+	}
+
+	person, err := p.GetPersonByID(reminder.PersonID)
+	if err != nil {
+		p.logf("❌ Failed to get person %d for reminder: %v", reminder.PersonID, err)
+		release()
+		p.reminders.finish(key, ReminderFailed, err.Error())
+		return
+	}
+	phoneNumber := p.extractPhoneFromPerson(person)
+	if phoneNumber == "" {
+		p.logf("⚠️ No phone number for person %d, skipping reminder", reminder.PersonID)
+		p.reminders.finish(key, ReminderSkipped, "no phone number")
+		return
+	}
+	// A reminder is only useful before the meeting, so one due outside contact hours is dropped
+	blocked, notUntil := p.contactGate(person, phoneNumber, reminder.Channel, "meeting reminder", time.Now())
+	if blocked == "" && !notUntil.IsZero() {
+		blocked = "outside contact hours"
+	}
+	if blocked != "" {
+		p.reminders.finish(key, ReminderSkipped, blocked)
+		return
+	}
+
+	tenant := reminder.Tenant
+	if tenant == "" {
+		tenant = p.personTenant(reminder.PersonID)
+	}
+	when := p.meetingTimeText(tenant, reminder.StartTime)
+
+	var reference string
+	switch reminder.Channel {
+	case "call":
+		variables := map[string]interface{}{
+			"reminder":      "true",
+			"meeting_title": reminder.Title,
+			"meeting_time":  when,
+		}
+		agentID, _ := p.consentAgent(phoneNumber, p.config.ReminderAgentID, variables)
+		reference, err = p.CreateRetellCall(phoneNumber, person.Name, reminder.Title, agentID, "", variables, nil)
+	default:
+		if p.sms == nil {
+			err = fmt.Errorf("SMS not configured (TWILIO_SMS_FROM)")
+			break
+		}
+		message := fmt.Sprintf("Hi %s, a reminder about %s on %s. Reply if you need to reschedule.", person.Name, reminder.Title, when)
+		if p.config.ReminderSMSTemplate != "" {
+			message = renderTemplate(p.config.ReminderSMSTemplate, map[string]string{
+				"name":  person.Name,
+				"title": reminder.Title,
+				"time":  when,
+			})
+		}
+		reference, err = p.sms.Send(phoneNumber, p.smsText(p.shortenLinks(message, reminder.PersonID)))
+	}
+	if err != nil {
+		p.logf("❌ Failed to send %s reminder for booking %s: %v", reminder.Channel, key, err)
+		release()
+		p.reminders.finish(key, ReminderFailed, err.Error())
+		return
+	}
+	p.logf("✅ Sent %s reminder for booking %s to %s (%s)", reminder.Channel, key, phoneNumber, reference)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "reminder_sent", PersonID: reminder.PersonID, Detail: fmt.Sprintf("%s reminder for booking %s (%s)", reminder.Channel, key, reference)})
+	p.reminders.finish(key, ReminderSent, reference)
+
+	activityData := map[string]interface{}{
+		"subject":   fmt.Sprintf("Meeting reminder sent (%s)", reminder.Channel),
+		"type":      "task",
+		"person_id": reminder.PersonID,
+		"note":      fmt.Sprintf("Reminder for %s on %s sent to %s\nReference: %s", reminder.Title, when, phoneNumber, reference),
+		"done":      1,
+		"due_date":  pipedriveDueDate(time.Now()),
+		"due_time":  pipedriveDueTime(time.Now()),
+	}
+	if reminder.Channel == "call" {
+		activityData["type"] = "call"
+	}
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to log reminder for person %d: %v", reminder.PersonID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// ListRemindersHandler lists scheduled and completed meeting reminders
+func ListRemindersHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reminders := pipedriveService.reminders.List()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d reminders", len(reminders)),
+			Data:    reminders,
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrCallNotAnalyzed is returned when Retell has not finished analyzing a call
+var ErrCallNotAnalyzed = errors.New("call has not been analyzed by Retell yet")
+
+// ErrNoCallMapping is returned when a replayed call has no mapping to a Pipedrive person
+var ErrNoCallMapping = errors.New("no call mapping for the call")
+
+// ErrCallAlreadyLogged is returned when a replayed call is already logged in Pipedrive
+var ErrCallAlreadyLogged = errors.New("call is already logged in Pipedrive")
+
+// RetellCallDetails holds get-call fields not carried by the call_analyzed webhook
+type RetellCallDetails struct {
+	ToNumber         string                 `json:"to_number"`
+	DynamicVariables map[string]interface{} `json:"retell_llm_dynamic_variables"`
+	CallAnalysis     json.RawMessage        `json:"call_analysis"`
+}
+
+// GetRetellCall fetches a call from Retell in the shape of a call_analyzed webhook
+func (p *PipedriveService) GetRetellCall(callID string) (RetellCallAnalyzedPayload, RetellCallDetails, error) {
+	var payload RetellCallAnalyzedPayload
+	var details RetellCallDetails
+	body, err := p.makeRetellCallRequest("GET", "/v2/get-call/"+url.PathEscape(callID), nil)
+	if err != nil {
+		return payload, details, err
+	}
+	if err := json.Unmarshal(body, &payload.Call); err != nil {
+		return payload, details, fmt.Errorf("failed to parse call: %v", err)
+	}
+	if err := json.Unmarshal(body, &details); err != nil {
+		return payload, details, fmt.Errorf("failed to parse call: %v", err)
+	}
+	payload.Event = "call_analyzed"
+	return payload, details, nil
+}
+
+// refreshCall fetches an analyzed call from Retell and runs it through the call_analyzed pipeline
+func (p *PipedriveService) refreshCall(callID string) error {
+	payload, details, err := p.GetRetellCall(callID)
+	if err != nil {
+		return err
+	}
+	if len(details.CallAnalysis) == 0 || string(details.CallAnalysis) == "null" {
+		return ErrCallNotAnalyzed
+	}
+
+	// Rebuild the call mapping from the call's events, or from the metadata sent with the call
+	// after a restart; a mapping found by phone number alone would miss the lead, owner and tenant
+	if _, ok := p.getCallMapping(callID); !ok {
+		if dialing := latestCallEvent(p.callEvents.Events(callID), CallEventDialing); dialing != nil && dialing.Mapping != nil {
+			p.restoreCallMapping(callID, *dialing.Mapping)
+		} else if _, ok := p.callMappingFor(callID, payload.Call.Metadata, details.ToNumber); !ok {
+			return ErrNoCallMapping
+		}
+	}
+
+	p.logf("🔄 Refreshing call %s from Retell", callID)
+	payload.Replay = true
+	return p.ProcessRetellCallAnalyzed(payload)
+}
+
+// RefreshCallHandler re-fetches a call from Retell and writes its Pipedrive records, for when call_analyzed never arrived
+func RefreshCallHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		callID := c.Param("id")
+		if callOutOfScope(c, pipedriveService, callID) {
+			return
+		}
+		if err := pipedriveService.refreshCall(callID); err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, ErrCallNotAnalyzed) || errors.Is(err, ErrNoCallMapping) || errors.Is(err, ErrCallAlreadyLogged) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, WebhookResponse{
+				Success: false,
+				Message: "Failed to refresh call: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Call refreshed from Retell",
+			Data:    gin.H{"call_id": callID, "events": pipedriveService.callEvents.Events(callID)},
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Retell accounts calls can be placed on
+const (
+	RetellAccountPrimary   = "primary"
+	RetellAccountSecondary = "secondary"
+)
+
+// capacityHints mark a Retell error as the account being out of concurrency or capacity
+var capacityHints = []string{"concurrency", "capacity", "rate limit", "too many"}
+
+// RetellAccount is the API key, endpoint and from number calls are placed with
+type RetellAccount struct {
+	Name       string
+	APIKey     string
+	BaseURL    string
+	FromNumber string
+}
+
+// primaryRetellAccount returns the RETELL_* account
+func (c *Config) primaryRetellAccount() RetellAccount {
+	return RetellAccount{Name: RetellAccountPrimary, APIKey: c.RetellAPIKey, BaseURL: c.RetellBaseURL, FromNumber: c.RetellFromNumber}
+}
+
+// secondaryRetellAccount returns the RETELL_SECONDARY_* backup account; unset fields use the primary's
+func (c *Config) secondaryRetellAccount() RetellAccount {
+	account := RetellAccount{Name: RetellAccountSecondary, APIKey: c.RetellSecondaryAPIKey, BaseURL: c.RetellSecondaryBaseURL, FromNumber: c.RetellSecondaryFromNumber}
+	if account.BaseURL == "" {
+		account.BaseURL = c.RetellBaseURL
+	}
+	if account.FromNumber == "" {
+		account.FromNumber = c.RetellFromNumber
+	}
+	return account
+}
+
+// HasRetellSecondary reports whether a backup Retell account is configured
+func (c *Config) HasRetellSecondary() bool {
+	return c.RetellSecondaryAPIKey != ""
+}
+
+// retellAccounts returns the Retell accounts calls may have been placed on
+func (c *Config) retellAccounts() []RetellAccount {
+	accounts := []RetellAccount{c.primaryRetellAccount()}
+	if c.HasRetellSecondary() {
+		accounts = append(accounts, c.secondaryRetellAccount())
+	}
+	return accounts
+}
+
+// makeRetellCallRequest makes a request about a single call, on the secondary account when the
+// primary doesn't know the call: calls placed after a failover live there
+func (p *PipedriveService) makeRetellCallRequest(method, endpoint string, body interface{}) ([]byte, error) {
+	respBody, err := p.makeRetellRequest(method, endpoint, body)
+	if err == nil || !p.config.HasRetellSecondary() {
+		return respBody, err
+	}
+	respBody, secondaryErr := p.makeRetellAccountRequest(p.config.secondaryRetellAccount(), method, endpoint, body)
+	if secondaryErr != nil {
+		return nil, fmt.Errorf("primary account: %v; secondary account: %v", err, secondaryErr)
+	}
+	return respBody, nil
+}
+
+// secondaryRetellAgent maps an agent of the primary account to its copy on the secondary account
+func (c *Config) secondaryRetellAgent(agentID string) string {
+	if secondary, ok := c.RetellSecondaryAgents[agentID]; ok && secondary != "" {
+		return secondary
+	}
+	if c.RetellSecondaryAgentID != "" {
+		return c.RetellSecondaryAgentID
+	}
+	return agentID
+}
+
+// retellProviderError reports whether a create-call outcome counts against the account's health
+// (5xx and 429; rejected numbers and other 4xx do not), and whether it is a capacity error that
+// fails over at once. Transport errors don't count: the call may have been placed, so it must not
+// be placed again on the secondary account.
+func retellProviderError(status int, body []byte, err error) (bool, bool) {
+	if err != nil {
+		return false, false
+	}
+	if status != http.StatusTooManyRequests && status < 500 {
+		return false, false
+	}
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		return true, true
+	}
+	lower := strings.ToLower(string(body))
+	for _, hint := range capacityHints {
+		if strings.Contains(lower, hint) {
+			return true, true
+		}
+	}
+	return true, false
+}
+
+// RetellFailoverStatus reports which Retell account new calls go to and the recent error rate
+type RetellFailoverStatus struct {
+	Enabled       bool           `json:"enabled"`
+	Active        string         `json:"active"`
+	Since         time.Time      `json:"since"`            // When the active account was switched to
+	Reason        string         `json:"reason,omitempty"` // Why calls were failed over
+	RecentErrors  int            `json:"recent_errors"`    // Provider errors among the primary's recent attempts
+	RecentWindow  int            `json:"recent_window"`
+	ErrorLimit    int            `json:"error_limit"`
+	Calls         map[string]int `json:"calls"` // Calls placed per account
+	Errors        map[string]int `json:"errors"`
+	LastPrimaryOK time.Time      `json:"last_primary_ok"`
+}
+
+// RetellFailover routes new calls to the secondary Retell account once the primary errors above
+// RETELL_FAILOVER_ERRORS in its last RETELL_FAILOVER_WINDOW attempts or reports a capacity error;
+// failback is manual
+type RetellFailover struct {
+	mu            sync.Mutex
+	enabled       bool // A secondary account is configured
+	window        int
+	limit         int
+	recent        []bool // Outcomes of the primary's recent attempts, true for a provider error
+	active        string
+	since         time.Time
+	reason        string
+	calls         map[string]int
+	errors        map[string]int
+	lastPrimaryOK time.Time
+}
+
+// NewRetellFailover creates the failover state with calls going to the primary account
+func NewRetellFailover(config *Config) *RetellFailover {
+	return &RetellFailover{
+		enabled: config.HasRetellSecondary(),
+		window:  config.RetellFailoverWindow,
+		limit:   config.RetellFailoverErrors,
+		active:  RetellAccountPrimary,
+		calls:   make(map[string]int),
+		errors:  make(map[string]int),
+	}
+}
+
+// Active returns the account new calls go to
+func (f *RetellFailover) Active() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+// record counts a call attempt and, for the primary, returns the reason to fail over, or ""
+func (f *RetellFailover) record(account string, providerError, capacity bool, detail string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[account]++
+	if providerError {
+		f.errors[account]++
+	}
+	if account != RetellAccountPrimary {
+		return ""
+	}
+	if !providerError {
+		f.lastPrimaryOK = time.Now()
+	}
+	f.recent = append(f.recent, providerError)
+	if len(f.recent) > f.window {
+		f.recent = f.recent[len(f.recent)-f.window:]
+	}
+	if !f.enabled || f.active != RetellAccountPrimary || !providerError {
+		return ""
+	}
+
+	errors := f.recentErrorsLocked()
+	reason := ""
+	switch {
+	case capacity:
+		reason = "capacity error: " + detail
+	case f.limit > 0 && errors >= f.limit:
+		reason = fmt.Sprintf("%d of the last %d calls failed, latest: %s", errors, len(f.recent), detail)
+	}
+	if reason != "" {
+		f.active, f.since, f.reason = RetellAccountSecondary, time.Now(), reason
+	}
+	return reason
+}
+
+// recentErrorsLocked counts provider errors in the window; callers must hold mu
+func (f *RetellFailover) recentErrorsLocked() int {
+	errors := 0
+	for _, failed := range f.recent {
+		if failed {
+			errors++
+		}
+	}
+	return errors
+}
+
+// failback routes new calls to the primary account again, reporting whether they were failed over
+func (f *RetellFailover) failback() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active == RetellAccountPrimary {
+		return false
+	}
+	f.active, f.since, f.reason, f.recent = RetellAccountPrimary, time.Now(), "", nil
+	return true
+}
+
+// Status returns the failover state
+func (f *RetellFailover) Status() RetellFailoverStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := RetellFailoverStatus{
+		Enabled:       f.enabled,
+		Active:        f.active,
+		Since:         f.since,
+		Reason:        f.reason,
+		RecentErrors:  f.recentErrorsLocked(),
+		RecentWindow:  f.window,
+		ErrorLimit:    f.limit,
+		Calls:         make(map[string]int),
+		Errors:        make(map[string]int),
+		LastPrimaryOK: f.lastPrimaryOK,
+	}
+	for account, calls := range f.calls {
+		status.Calls[account] = calls
+	}
+	for account, errors := range f.errors {
+		status.Errors[account] = errors
+	}
+	return status
+}
+
+// activeRetellAccount returns the account new calls are placed on
+func (p *PipedriveService) activeRetellAccount() RetellAccount {
+	if p.retellFailover.Active() == RetellAccountSecondary {
+		return p.config.secondaryRetellAccount()
+	}
+	return p.config.primaryRetellAccount()
+}
+
+// recordRetellCallResult feeds a create-call outcome to the failover, switching new calls to the
+// secondary account and alerting when the primary is unhealthy; it reports whether this attempt
+// caused the switch
+func (p *PipedriveService) recordRetellCallResult(account RetellAccount, status int, body []byte, err error) bool {
+	providerError, capacity := retellProviderError(status, body, err)
+	detail := fmt.Sprintf("HTTP %d", status)
+	if err != nil {
+		detail = err.Error()
+	}
+	reason := p.retellFailover.record(account.Name, providerError, capacity, detail)
+	if reason == "" {
+		return false
+	}
+	p.logf("🔀 Retell primary account unhealthy (%s); placing new calls on the secondary account", reason)
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "retell_failover", Detail: reason})
+	p.sendAlert(fmt.Sprintf("🔀 Retell primary account is failing (%s). New calls are going to the secondary account until failback via POST /admin/retell/failback.", reason))
+	return true
+}
+
+// RetellFailoverStatusHandler reports which Retell account new calls go to
+func RetellFailoverStatusHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := pipedriveService.retellFailover.Status()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "New calls go to the " + status.Active + " Retell account",
+			Data:    status,
+		})
+	}
+}
+
+// RetellFailbackHandler routes new calls back to the primary Retell account
+func RetellFailbackHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !pipedriveService.retellFailover.failback() {
+			c.JSON(http.StatusConflict, WebhookResponse{
+				Success: false,
+				Message: "New calls already go to the primary Retell account",
+			})
+			return
+		}
+		log.Printf("🔀 Retell failback: new calls go to the primary account again")
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "retell_failback"})
+		pipedriveService.sendAlert("✅ Retell failback: new calls go to the primary account again")
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "New calls go to the primary Retell account",
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetellPhoneNumber represents a phone number owned by the Retell account
+type RetellPhoneNumber struct {
+	PhoneNumber     string `json:"phone_number"`
+	Nickname        string `json:"nickname,omitempty"`
+	AreaCode        int    `json:"area_code,omitempty"`
+	InboundAgentID  string `json:"inbound_agent_id,omitempty"`
+	OutboundAgentID string `json:"outbound_agent_id,omitempty"`
+}
+
+// RetellPhoneNumberRequest is the body for purchasing a number
+type RetellPhoneNumberRequest struct {
+	AreaCode        int    `json:"area_code,omitempty"`
+	Nickname        string `json:"nickname,omitempty"`
+	InboundAgentID  string `json:"inbound_agent_id,omitempty"`
+	OutboundAgentID string `json:"outbound_agent_id,omitempty"`
+}
+
+// RetellOnboardRequest is the body for one-shot tenant onboarding
+type RetellOnboardRequest struct {
+	AgentID    string `json:"agent_id" binding:"required"`
+	AreaCode   int    `json:"area_code"`
+	Nickname   string `json:"nickname"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+// makeRetellRequest makes an authenticated request to the primary Retell account and returns the response body
+func (p *PipedriveService) makeRetellRequest(method, endpoint string, body interface{}) ([]byte, error) {
+	return p.makeRetellAccountRequest(p.config.primaryRetellAccount(), method, endpoint, body)
+}
+
+// makeRetellAccountRequest makes an authenticated request to a Retell account and returns the response body
+func (p *PipedriveService) makeRetellAccountRequest(account RetellAccount, method, endpoint string, body interface{}) ([]byte, error) {
+	if account.APIKey == "" {
+		return nil, fmt.Errorf("Retell AI not configured: missing API key")
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %v", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, account.BaseURL+endpoint, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+account.APIKey)
+
+	p.logf("🌐 Making %s request to Retell AI: %s", method, endpoint)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Retell AI request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Retell AI request failed: HTTP %d, Response: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// ListRetellPhoneNumbers lists the phone numbers on the Retell account
+func (p *PipedriveService) ListRetellPhoneNumbers() ([]RetellPhoneNumber, error) {
+	body, err := p.makeRetellRequest("GET", "/list-phone-numbers", nil)
+	if err != nil {
+		return nil, err
+	}
+	var numbers []RetellPhoneNumber
+	if err := json.Unmarshal(body, &numbers); err != nil {
+		return nil, fmt.Errorf("failed to parse phone numbers: %v", err)
+	}
+	return numbers, nil
+}
+
+// PurchaseRetellPhoneNumber buys a new number, optionally bound to agents
+func (p *PipedriveService) PurchaseRetellPhoneNumber(request RetellPhoneNumberRequest) (*RetellPhoneNumber, error) {
+	body, err := p.makeRetellRequest("POST", "/create-phone-number", request)
+	if err != nil {
+		return nil, err
+	}
+	var number RetellPhoneNumber
+	if err := json.Unmarshal(body, &number); err != nil {
+		return nil, fmt.Errorf("failed to parse phone number: %v", err)
+	}
+	p.logf("✅ Purchased Retell phone number %s", number.PhoneNumber)
+	return &number, nil
+}
+
+// AttachRetellPhoneNumber binds an existing number to inbound/outbound agents
+func (p *PipedriveService) AttachRetellPhoneNumber(phoneNumber, inboundAgentID, outboundAgentID string) (*RetellPhoneNumber, error) {
+	update := map[string]interface{}{}
+	if inboundAgentID != "" {
+		update["inbound_agent_id"] = inboundAgentID
+	}
+	if outboundAgentID != "" {
+		update["outbound_agent_id"] = outboundAgentID
+	}
+
+	body, err := p.makeRetellRequest("PATCH", "/update-phone-number/"+url.PathEscape(phoneNumber), update)
+	if err != nil {
+		return nil, err
+	}
+	var number RetellPhoneNumber
+	if err := json.Unmarshal(body, &number); err != nil {
+		return nil, fmt.Errorf("failed to parse phone number: %v", err)
+	}
+	p.logf("✅ Attached Retell phone number %s to agent %s", phoneNumber, strings.TrimSpace(inboundAgentID+" "+outboundAgentID))
+	return &number, nil
+}
+
+// UpdateRetellAgentWebhook points an agent's webhook at this service
+func (p *PipedriveService) UpdateRetellAgentWebhook(agentID, webhookURL string) error {
+	_, err := p.makeRetellRequest("PATCH", "/update-agent/"+url.PathEscape(agentID), map[string]interface{}{
+		"webhook_url": webhookURL,
+	})
+	return err
+}
+
+// ListRetellPhoneNumbersHandler lists Retell phone numbers
+func ListRetellPhoneNumbersHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		numbers, err := pipedriveService.ListRetellPhoneNumbers()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to list phone numbers: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d phone numbers", len(numbers)),
+			Data:    numbers,
+		})
+	}
+}
+
+// PurchaseRetellPhoneNumberHandler purchases a Retell phone number
+func PurchaseRetellPhoneNumberHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request RetellPhoneNumberRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		number, err := pipedriveService.PurchaseRetellPhoneNumber(request)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to purchase phone number: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusCreated, WebhookResponse{
+			Success: true,
+			Message: "Phone number purchased",
+			Data:    number,
+		})
+	}
+}
+
+// AttachRetellPhoneNumberHandler attaches a Retell phone number to agents
+func AttachRetellPhoneNumberHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			InboundAgentID  string `json:"inbound_agent_id"`
+			OutboundAgentID string `json:"outbound_agent_id"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || (request.InboundAgentID == "" && request.OutboundAgentID == "") {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Missing required field: inbound_agent_id or outbound_agent_id",
+			})
+			return
+		}
+
+		number, err := pipedriveService.AttachRetellPhoneNumber(c.Param("number"), request.InboundAgentID, request.OutboundAgentID)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to attach phone number: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Phone number attached",
+			Data:    number,
+		})
+	}
+}
+
+// RetellOnboardHandler purchases a number, binds it to an agent and points the agent's webhook at this service
+func RetellOnboardHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request RetellOnboardRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Missing required field: agent_id",
+			})
+			return
+		}
+
+		number, err := pipedriveService.PurchaseRetellPhoneNumber(RetellPhoneNumberRequest{
+			AreaCode:        request.AreaCode,
+			Nickname:        request.Nickname,
+			InboundAgentID:  request.AgentID,
+			OutboundAgentID: request.AgentID,
+		})
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to purchase phone number: " + err.Error(),
+			})
+			return
+		}
+
+		webhookURL := request.WebhookURL
+		if webhookURL == "" && pipedriveService.config.PublicBaseURL != "" {
+			webhookURL = pipedriveService.config.PublicBaseURL + "/webhook/retell/analyzed"
+		}
+		if webhookURL != "" {
+			if err := pipedriveService.UpdateRetellAgentWebhook(request.AgentID, webhookURL); err != nil {
+				c.JSON(http.StatusBadGateway, WebhookResponse{
+					Success: false,
+					Message: "Phone number purchased but failed to set agent webhook: " + err.Error(),
+					Data:    number,
+				})
+				return
+			}
+		}
+
+		if pipedriveService.config.voicemailDropEnabled() {
+			if err := pipedriveService.UpdateRetellAgentVoicemail(request.AgentID); err != nil {
+				log.Printf("⚠️ Warning: Failed to configure voicemail drop on agent %s: %v", request.AgentID, err)
+			}
+		}
+
+		c.JSON(http.StatusCreated, WebhookResponse{
+			Success: true,
+			Message: "Tenant onboarded",
+			Data: gin.H{
+				"phone_number": number,
+				"agent_id":     request.AgentID,
+				"webhook_url":  webhookURL,
+			},
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPurgeRuns is how many purge runs are kept for GET /admin/retention
+const maxPurgeRuns = 30
+
+// Kinds of data removed by a retention purge
+const (
+	PurgedRecording     = "recording"      // Recording URL dropped from the stored analysis
+	PurgedTranscript    = "transcript"     // Transcript dropped from the stored analysis and transcript store
+	PurgedRetellCall    = "retell_call"    // Call and its recording deleted in Retell
+	PurgedPipedriveNote = "pipedrive_note" // Recording links or transcript stripped from the call's Pipedrive activity
+	PurgedMemories      = "memories"       // Conversation memories summarizing the calls
+	PurgedCallMapping   = "call_mapping"   // Call's person, phone and lead context
+	PurgedWebhooks      = "webhooks"       // Archived webhooks, whose payloads carry recordings and transcripts
+)
+
+// PurgeRecord is one item removed by a retention purge, written to RETENTION_LOG_FILE
+type PurgeRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	CallID    string    `json:"call_id"`
+	PersonID  int       `json:"person_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// PurgeRun summarizes one retention purge
+type PurgeRun struct {
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	Trigger        string    `json:"trigger"` // "schedule" or the admin who ran it
+	Recordings     int       `json:"recordings"`
+	Transcripts    int       `json:"transcripts"`
+	RetellCalls    int       `json:"retell_calls"`
+	PipedriveNotes int       `json:"pipedrive_notes"`
+	Memories       int       `json:"memories"`
+	CallMappings   int       `json:"call_mappings"`
+	Webhooks       int       `json:"webhooks"`
+	Errors         []string  `json:"errors,omitempty"`
+}
+
+// RetentionManager runs purges and keeps their history and compliance log
+type RetentionManager struct {
+	mu      sync.Mutex
+	runs    []PurgeRun // Oldest first
+	logFile string
+}
+
+// NewRetentionManager creates a retention manager writing purges to RETENTION_LOG_FILE
+func NewRetentionManager(config *Config) *RetentionManager {
+	return &RetentionManager{logFile: config.RetentionLogFile}
+}
+
+// recordPurge appends a purged item to the audit log and RETENTION_LOG_FILE
+func (p *PipedriveService) recordPurge(record PurgeRecord) {
+	record.Timestamp = time.Now()
+	p.auditLog.Append(AuditEntry{
+		Timestamp: record.Timestamp,
+		Actor:     AuditActorSystem,
+		Action:    "purged_" + record.Kind,
+		PersonID:  record.PersonID,
+		CallID:    record.CallID,
+		Detail:    record.Detail,
+	})
+	if p.retention.logFile == "" {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	p.retention.mu.Lock()
+	defer p.retention.mu.Unlock()
+	file, err := os.OpenFile(p.retention.logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = file.Write(append(line, '\n'))
+		file.Close()
+	}
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to write retention log: %v", err)
+	}
+}
+
+// retentionCutoff returns the time before which data kept for days is purged, or zero when kept forever
+func retentionCutoff(days int, now time.Time) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// PurgeExpired removes recordings, transcripts and the call data holding them once they are older
+// than their retention periods. A call whose Pipedrive note or Retell deletion fails is left as it
+// is, so the next run finds and retries it.
+func (p *PipedriveService) PurgeExpired(trigger string) PurgeRun {
+	run := PurgeRun{StartedAt: time.Now(), Trigger: trigger}
+	pending := make(map[string]bool) // Calls whose Pipedrive note could not be redacted
+
+	if cutoff := retentionCutoff(p.config.RetentionRecordingDays, run.StartedAt); !cutoff.IsZero() {
+		for _, event := range p.callEvents.ExpiredAnalyses(cutoff, func(analysis *RetellCallAnalyzedPayload) bool {
+			return analysis.Call.RecordingURL != ""
+		}) {
+			callID := event.CallID
+			if !p.purgeCallNote(&run, event, true, false) {
+				pending[callID] = true
+				continue
+			}
+			p.callEvents.RedactCall(callID, cutoff, func(analysis *RetellCallAnalyzedPayload) bool {
+				if analysis.Call.RecordingURL == "" {
+					return false
+				}
+				analysis.Call.RecordingURL = ""
+				return true
+			})
+			run.Recordings++
+			p.recordPurge(PurgeRecord{Kind: PurgedRecording, CallID: callID, PersonID: event.PersonID})
+		}
+		if p.config.RetentionDeleteRetellCalls {
+			p.deleteExpiredRetellCalls(&run, cutoff)
+		}
+	}
+
+	if cutoff := retentionCutoff(p.config.RetentionTranscriptDays, run.StartedAt); !cutoff.IsZero() {
+		purged := make(map[string]int) // Call ID -> person ID
+		for _, event := range p.callEvents.ExpiredAnalyses(cutoff, func(analysis *RetellCallAnalyzedPayload) bool {
+			return analysis.Call.Transcript != "" || len(analysis.Call.TranscriptObject) > 0
+		}) {
+			callID := event.CallID
+			if !p.purgeCallNote(&run, event, false, true) {
+				pending[callID] = true
+				continue
+			}
+			p.callEvents.RedactCall(callID, cutoff, func(analysis *RetellCallAnalyzedPayload) bool {
+				if analysis.Call.Transcript == "" && len(analysis.Call.TranscriptObject) == 0 {
+					return false
+				}
+				analysis.Call.Transcript = ""
+				analysis.Call.TranscriptObject = nil
+				return true
+			})
+			purged[callID] = event.PersonID
+		}
+		for _, transcript := range p.transcripts.Purge(cutoff) {
+			purged[transcript.CallID] = transcript.PersonID
+		}
+		for callID, personID := range purged {
+			run.Transcripts++
+			p.recordPurge(PurgeRecord{Kind: PurgedTranscript, CallID: callID, PersonID: personID})
+		}
+		// Memories are summaries of what was said, so they go with the transcripts
+		if run.Memories = p.memory.Purge(cutoff); run.Memories > 0 {
+			p.recordPurge(PurgeRecord{Kind: PurgedMemories, Detail: fmt.Sprintf("%d call memories from before %s", run.Memories, cutoff.UTC().Format(time.RFC3339))})
+		}
+	}
+
+	// Archived payloads and call mappings hold both, so they go with whichever is kept for less time
+	callCutoff := retentionCutoff(p.config.RetentionRecordingDays, run.StartedAt)
+	if cutoff := retentionCutoff(p.config.RetentionTranscriptDays, run.StartedAt); cutoff.After(callCutoff) {
+		callCutoff = cutoff
+	}
+	if !callCutoff.IsZero() {
+		if run.Webhooks = p.webhooks.Purge(callCutoff); run.Webhooks > 0 {
+			p.recordPurge(PurgeRecord{Kind: PurgedWebhooks, Detail: fmt.Sprintf("%d archived webhooks received before %s", run.Webhooks, callCutoff.UTC().Format(time.RFC3339))})
+		}
+		p.purgeCallMappings(&run, callCutoff, pending)
+	}
+
+	run.FinishedAt = time.Now()
+	p.retention.mu.Lock()
+	p.retention.runs = append(p.retention.runs, run)
+	if len(p.retention.runs) > maxPurgeRuns {
+		p.retention.runs = p.retention.runs[len(p.retention.runs)-maxPurgeRuns:]
+	}
+	p.retention.mu.Unlock()
+	p.logf("🗑️ Retention purge: %d recordings, %d transcripts, %d Retell calls, %d Pipedrive notes, %d memories, %d call mappings, %d archived webhooks, %d errors",
+		run.Recordings, run.Transcripts, run.RetellCalls, run.PipedriveNotes, run.Memories, run.CallMappings, run.Webhooks, len(run.Errors))
+	return run
+}
+
+// purgeCallNote strips an expired call's recording links or transcript from its Pipedrive activity
+// when RETENTION_REDACT_PIPEDRIVE_NOTES is set. It returns false when that failed, in which case
+// the call is kept as it is for the next run.
+func (p *PipedriveService) purgeCallNote(run *PurgeRun, event CallEvent, recording, transcript bool) bool {
+	if !p.config.RetentionRedactPipedriveNotes || event.PersonID == 0 {
+		return true
+	}
+	callID := event.CallID
+	mapping, _, err := p.callMappings.Get(callID)
+	if err != nil {
+		run.Errors = append(run.Errors, fmt.Sprintf("pipedrive note of call %s: %v", callID, err))
+		return false
+	}
+	start := TimestampFromMillis(event.Analysis.Call.StartTimestamp).UTC
+	activity, err := p.findCallActivity(event.PersonID, mapping.ActivityID, start)
+	if err != nil {
+		run.Errors = append(run.Errors, fmt.Sprintf("pipedrive note of call %s: %v", callID, err))
+		return false
+	}
+	if activity == nil {
+		return true
+	}
+
+	note := activity.Note
+	if recording && event.Analysis.Call.RecordingURL != "" {
+		note = regexp.MustCompile(regexp.QuoteMeta(event.Analysis.Call.RecordingURL)+`(#t=\d+)?`).ReplaceAllString(note, "[purged]")
+	}
+	if transcript {
+		note = redactNoteTranscript(note)
+	}
+	if note == activity.Note {
+		return true
+	}
+	if err := p.updateActivity(activity.ID, map[string]interface{}{"note": note}); err != nil {
+		run.Errors = append(run.Errors, fmt.Sprintf("pipedrive note of call %s: %v", callID, err))
+		return false
+	}
+	run.PipedriveNotes++
+	p.recordPurge(PurgeRecord{Kind: PurgedPipedriveNote, CallID: callID, PersonID: event.PersonID, Detail: fmt.Sprintf("activity %d", activity.ID)})
+	return true
+}
+
+// redactNoteTranscript replaces the transcript section of an analyzed-call note, which runs up to
+// the voicemail section or the end of the note
+func redactNoteTranscript(note string) string {
+	start := strings.Index(note, "Full Transcript:")
+	if start < 0 || strings.HasPrefix(note[start:], "Full Transcript: [purged]") {
+		return note
+	}
+	end := len(note)
+	if voicemail := strings.Index(note[start:], "\n\nVoicemail drop left"); voicemail >= 0 {
+		end = start + voicemail
+	}
+	return note[:start] + "Full Transcript: [purged]" + note[end:]
+}
+
+// deleteExpiredRetellCalls deletes this service's calls started before cutoff from Retell. Retell
+// keeps a call until its deletion succeeds, so a failed one is listed and retried on the next run.
+func (p *PipedriveService) deleteExpiredRetellCalls(run *PurgeRun, cutoff time.Time) {
+	calls, err := p.ListRetellCalls(time.UnixMilli(0), cutoff)
+	if err != nil {
+		run.Errors = append(run.Errors, fmt.Sprintf("retell calls: %v", err))
+		return
+	}
+	for _, call := range calls {
+		callID := call.Payload.Call.CallID
+		personID := 0
+		if metadata := call.Payload.Call.Metadata; metadata != nil {
+			personID = metadata.PersonID
+		}
+		// Only calls this service placed carry its dynamic variables or metadata
+		if _, ours := call.Details.DynamicVariables["lead_title"]; !ours && personID == 0 {
+			continue
+		}
+		if _, err := p.makeRetellAccountRequest(call.Account, "DELETE", "/v2/delete-call/"+url.PathEscape(callID), nil); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("retell call %s: %v", callID, err))
+			continue
+		}
+		run.RetellCalls++
+		p.recordPurge(PurgeRecord{Kind: PurgedRetellCall, CallID: callID, PersonID: personID})
+	}
+}
+
+// purgeCallMappings deletes the mappings of calls placed before cutoff, keeping those whose
+// Pipedrive note still has to be redacted
+func (p *PipedriveService) purgeCallMappings(run *PurgeRun, cutoff time.Time, pending map[string]bool) {
+	mappings, err := p.callMappings.List()
+	if err != nil {
+		run.Errors = append(run.Errors, fmt.Sprintf("call mappings: %v", err))
+		return
+	}
+	for callID, mapping := range mappings {
+		if pending[callID] || !mapping.Timestamp.Before(cutoff) {
+			continue
+		}
+		if err := p.callMappings.Delete(callID); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("call mapping %s: %v", callID, err))
+			continue
+		}
+		run.CallMappings++
+		p.recordPurge(PurgeRecord{Kind: PurgedCallMapping, CallID: callID, PersonID: mapping.PersonID})
+	}
+}
+
+// runRetentionPurge purges expired recordings and transcripts every night at RETENTION_PURGE_HOUR
+func (p *PipedriveService) runRetentionPurge() {
+	runDaily(p.config.RetentionPurgeHour, p.kpis.location, func(date string) {
+		if _, ok := p.claim("retention:" + date); ok {
+			p.PurgeExpired("schedule")
+		}
+	})
+}
+
+// RetentionStatusHandler shows the retention policy and recent purge runs
+func RetentionStatusHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService.retention.mu.Lock()
+		runs := make([]PurgeRun, len(pipedriveService.retention.runs))
+		copy(runs, pipedriveService.retention.runs)
+		pipedriveService.retention.mu.Unlock()
+
+		config := pipedriveService.config
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d purge runs", len(runs)),
+			Data: gin.H{
+				"recording_days":         config.RetentionRecordingDays,
+				"transcript_days":        config.RetentionTranscriptDays,
+				"delete_retell_calls":    config.RetentionDeleteRetellCalls,
+				"redact_pipedrive_notes": config.RetentionRedactPipedriveNotes,
+				"runs":                   runs,
+			},
+		})
+	}
+}
+
+// RetentionPurgeHandler runs a retention purge now
+func RetentionPurgeHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		trigger := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			trigger = user.(*AdminUser).Name
+		}
+		run := pipedriveService.PurgeExpired(trigger)
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: len(run.Errors) == 0,
+			Message: fmt.Sprintf("Purged %d recordings and %d transcripts", run.Recordings, run.Transcripts),
+			Data:    run,
+		})
+	}
+}
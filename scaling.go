@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// throttleWindow is how far back vendor 429 responses count towards the scaling signal
+const throttleWindow = 5 * time.Minute
+
+// maxInFlightCallAge stops calls that never reported an end from counting as in flight forever
+const maxInFlightCallAge = 2 * time.Hour
+
+// What limits throughput right now
+const (
+	ScalingIdle        = "idle"   // Workers have spare capacity
+	ScalingWorkerBound = "worker" // All workers busy with a backlog; more replicas help
+	ScalingVendorBound = "vendor" // Vendors are rate limiting us; more replicas only add 429s
+)
+
+// VendorThrottleTracker records recent 429 responses by host
+type VendorThrottleTracker struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+// vendorThrottles counts 429s seen by every outbound HTTP client
+var vendorThrottles = &VendorThrottleTracker{hits: make(map[string][]time.Time)}
+
+// Record counts a 429 from a host
+func (t *VendorThrottleTracker) Record(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hits[host] = append(t.pruneLocked(host), time.Now())
+}
+
+// pruneLocked drops hits older than the window; callers hold mu
+func (t *VendorThrottleTracker) pruneLocked(host string) []time.Time {
+	hits := t.hits[host]
+	cutoff := time.Now().Add(-throttleWindow)
+	i := 0
+	for i < len(hits) && hits[i].Before(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
+
+// Recent returns 429 counts within the window by host
+func (t *VendorThrottleTracker) Recent() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[string]int)
+	for host := range t.hits {
+		t.hits[host] = t.pruneLocked(host)
+		if len(t.hits[host]) == 0 {
+			delete(t.hits, host)
+			continue
+		}
+		counts[host] = len(t.hits[host])
+	}
+	return counts
+}
+
+// throttleObserver records 429 responses from outbound requests
+type throttleObserver struct {
+	next http.RoundTripper
+}
+
+// RoundTrip makes the request and records the host if it answered 429
+func (o *throttleObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := o.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		vendorThrottles.Record(req.URL.Host)
+	}
+	return resp, err
+}
+
+// vendorBound reports whether any vendor answered at least min 429s within the window
+func vendorBound(throttles map[string]int, min int) bool {
+	if min < 1 {
+		min = 1
+	}
+	for _, count := range throttles {
+		if count >= min {
+			return true
+		}
+	}
+	return false
+}
+
+// ScalingSignals are the autoscaling inputs for this replica
+type ScalingSignals struct {
+	QueueDepth        int            `json:"queue_depth"`
+	QueueCapacity     int            `json:"queue_capacity"`
+	Workers           int            `json:"workers"`
+	ActiveWorkers     int            `json:"active_workers"`
+	WorkerUtilization float64        `json:"worker_utilization"` // Active workers / workers
+	ProcessingLag     float64        `json:"processing_lag"`     // Seconds the oldest queued job has waited
+	InFlightCalls     int            `json:"in_flight_calls"`    // Calls queued, dialing or connected
+	VendorThrottles   map[string]int `json:"vendor_throttles"`   // 429s in the last 5 minutes by vendor
+	Bound             string         `json:"bound"`              // idle, worker or vendor
+	ScalingHelps      bool           `json:"scaling_helps"`      // Whether more replicas would raise throughput
+	ScaleMetric       int            `json:"scale_metric"`       // Value to target per replica
+	WebhooksThrottled int64          `json:"webhooks_throttled"` // Webhooks answered 429 since startup
+
+	PipedriveRateSaturation  float64 `json:"pipedrive_rate_saturation"`  // Share of the Pipedrive rate limit in use
+	PipedriveRequestsWaiting int     `json:"pipedrive_requests_waiting"` // Requests waiting for the Pipedrive rate limit
+}
+
+// ScalingSignals computes the current autoscaling inputs
+func (p *PipedriveService) ScalingSignals() ScalingSignals {
+	stats := p.workers.Stats()
+	signals := ScalingSignals{
+		QueueDepth:        stats.Queued,
+		QueueCapacity:     stats.QueueCapacity,
+		Workers:           stats.Workers,
+		ActiveWorkers:     stats.Active,
+		ProcessingLag:     stats.OldestWaitSeconds,
+		InFlightCalls:     p.callEvents.InFlight(maxInFlightCallAge),
+		VendorThrottles:   make(map[string]int),
+		WebhooksThrottled: stats.Throttled,
+	}
+	if stats.Workers > 0 {
+		signals.WorkerUtilization = float64(stats.Active) / float64(stats.Workers)
+	}
+
+	names := make(map[string]string)
+	for name, host := range vendorHosts(p.config) {
+		names[host] = name
+	}
+	for host, count := range vendorThrottles.Recent() {
+		name, ok := names[host]
+		if !ok {
+			name = host
+		}
+		signals.VendorThrottles[name] += count
+	}
+	if p.rateLimiter != nil {
+		limit := p.rateLimiter.Stats()
+		signals.PipedriveRateSaturation = limit.Saturation
+		signals.PipedriveRequestsWaiting = limit.Waiting
+	}
+
+	switch {
+	case vendorBound(signals.VendorThrottles, p.config.ScalingVendorThrottles), signals.PipedriveRequestsWaiting > 0:
+		signals.Bound = ScalingVendorBound
+	case stats.Queued > 0 && stats.Active >= stats.Workers:
+		signals.Bound = ScalingWorkerBound
+		signals.ScalingHelps = true
+	default:
+		signals.Bound = ScalingIdle
+	}
+
+	// Vendor-bound replicas report only their current work so the autoscaler holds instead of adding replicas
+	signals.ScaleMetric = stats.Active
+	if signals.Bound != ScalingVendorBound {
+		signals.ScaleMetric += stats.Queued
+	}
+	return signals
+}
+
+// prometheus formats the signals as Prometheus text exposition
+func (s ScalingSignals) prometheus() string {
+	var out strings.Builder
+	gauge := func(name, help string, value interface{}) {
+		fmt.Fprintf(&out, "# HELP pipcal_%s %s\n# TYPE pipcal_%s gauge\npipcal_%s %v\n", name, help, name, name, value)
+	}
+	gauge("queue_depth", "Jobs waiting for a worker.", s.QueueDepth)
+	gauge("queue_capacity", "Worker queue capacity.", s.QueueCapacity)
+	gauge("workers", "Worker pool size.", s.Workers)
+	gauge("active_workers", "Workers running a job.", s.ActiveWorkers)
+	gauge("worker_utilization", "Active workers divided by workers.", s.WorkerUtilization)
+	gauge("processing_lag_seconds", "Seconds the oldest queued job has waited.", s.ProcessingLag)
+	gauge("in_flight_calls", "Calls queued, dialing or connected.", s.InFlightCalls)
+	gauge("scaling_helps", "1 when more replicas would raise throughput.", map[bool]int{false: 0, true: 1}[s.ScalingHelps])
+	gauge("scale_metric", "Work per replica to target when autoscaling.", s.ScaleMetric)
+	gauge("pipedrive_rate_saturation", "Share of the Pipedrive rate limit in use.", s.PipedriveRateSaturation)
+	gauge("pipedrive_requests_waiting", "Requests waiting for the Pipedrive rate limit.", s.PipedriveRequestsWaiting)
+	fmt.Fprintf(&out, "# HELP pipcal_webhooks_throttled_total Webhooks answered 429 under backpressure.\n# TYPE pipcal_webhooks_throttled_total counter\npipcal_webhooks_throttled_total %d\n", s.WebhooksThrottled)
+
+	vendors := make([]string, 0, len(s.VendorThrottles))
+	for vendor := range s.VendorThrottles {
+		vendors = append(vendors, vendor)
+	}
+	sort.Strings(vendors)
+	out.WriteString("# HELP pipcal_vendor_throttles 429 responses in the last 5 minutes.\n# TYPE pipcal_vendor_throttles gauge\n")
+	for _, vendor := range vendors {
+		fmt.Fprintf(&out, "pipcal_vendor_throttles{vendor=%q} %d\n", vendor, s.VendorThrottles[vendor])
+	}
+	return out.String()
+}
+
+// ScalingHandler exposes autoscaling signals as JSON (KEDA metrics-api) or Prometheus text (?format=prometheus)
+func ScalingHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signals := pipedriveService.ScalingSignals()
+		if c.Query("format") == "prometheus" {
+			c.String(http.StatusOK, signals.prometheus())
+			return
+		}
+		c.JSON(http.StatusOK, signals)
+	}
+}
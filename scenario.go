@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScenarioStep is one scripted webhook of a demo scenario, sent after Delay
+type ScenarioStep struct {
+	Name    string
+	Fixture string // ID of the test fixture whose payload is sent
+	Delay   time.Duration
+}
+
+// demoScenarios are the scripted flows POST /test/scenario/:name can run; delays roughly match
+// how long each step takes with real leads
+var demoScenarios = map[string][]ScenarioStep{
+	"booked-demo": {
+		{Name: "Lead created", Fixture: "pipedrive-lead"},
+		{Name: "Call placed", Fixture: "retell-completed", Delay: 3 * time.Second},
+		{Name: "Call analyzed", Fixture: "retell-analyzed", Delay: 5 * time.Second},
+		{Name: "Booking created", Fixture: "cal-booking-v1", Delay: 4 * time.Second},
+	},
+	"opt-out": {
+		{Name: "Lead created", Fixture: "pipedrive-lead"},
+		{Name: "Caller opted out", Fixture: "retell-optout", Delay: 3 * time.Second},
+	},
+}
+
+// ScenarioProgress is the data of a step event streamed while a scenario runs
+type ScenarioProgress struct {
+	Scenario string      `json:"scenario"`
+	Step     int         `json:"step"`
+	Total    int         `json:"total"`
+	Name     string      `json:"name"`
+	Path     string      `json:"path"`
+	Status   string      `json:"status"` // running, completed or failed
+	HTTP     int         `json:"http_status,omitempty"`
+	Response interface{} `json:"response,omitempty"`
+}
+
+// scenarioNames lists the demo scenarios
+func scenarioNames() []string {
+	names := make([]string, 0, len(demoScenarios))
+	for name := range demoScenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scenarioService is the service scenario steps run on: processing is synchronous so each step
+// finishes before the next is announced. Steps call the handlers directly, so webhook signatures are
+// not checked
+func (p *PipedriveService) scenarioService() *PipedriveService {
+	config := *p.config
+	config.AsyncWebhookProcessing = false
+
+	service := *p
+	service.config = &config
+	return &service
+}
+
+// runScenarioStep sends a fixture's payload through its webhook handler
+func runScenarioStep(service *PipedriveService, fixture TestFixture) (int, interface{}) {
+	payload, err := json.Marshal(fixture.Payload)
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	recorder := httptest.NewRecorder()
+	step, _ := gin.CreateTestContext(recorder)
+	step.Request = httptest.NewRequest(fixture.Method, fixture.Path, bytes.NewReader(payload))
+	step.Request.Header.Set("Content-Type", "application/json")
+
+	reprocessHandlers[fixture.Path](service)(step)
+	step.Writer.WriteHeaderNow()
+	if json.Valid(recorder.Body.Bytes()) {
+		return recorder.Code, json.RawMessage(recorder.Body.Bytes())
+	}
+	return recorder.Code, recorder.Body.String()
+}
+
+// ScenarioHandler runs a scripted demo scenario in simulation mode, streaming each step's
+// progress as server-sent events; ?speed=N runs the delays N times faster
+func ScenarioHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		steps, ok := demoScenarios[name]
+		if !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Unknown scenario",
+				Data:    gin.H{"scenarios": scenarioNames()},
+			})
+			return
+		}
+		if pipedriveService.config.HasPipedriveConfig() {
+			c.JSON(http.StatusConflict, WebhookResponse{
+				Success: false,
+				Message: "Scenarios only run in simulation mode (PIPEDRIVE_API_KEY unset)",
+			})
+			return
+		}
+		speed := 1.0
+		if raw := c.Query("speed"); raw != "" {
+			parsed, err := strconv.ParseFloat(raw, 64)
+			if err != nil || parsed <= 0 || parsed > 100 {
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "speed must be a number between 0 and 100",
+				})
+				return
+			}
+			speed = parsed
+		}
+
+		fixtures := make(map[string]TestFixture)
+		for _, fixture := range testFixtures() {
+			fixtures[fixture.ID] = fixture
+		}
+		service := pipedriveService.scenarioService()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("scenario", gin.H{"scenario": name, "steps": len(steps), "speed": speed})
+		c.Writer.Flush()
+
+		log.Printf("🎬 Running demo scenario %s (%d steps)", name, len(steps))
+		started := time.Now()
+		success := true
+		for i, step := range steps {
+			select {
+			case <-time.After(time.Duration(float64(step.Delay) / speed)):
+			case <-c.Request.Context().Done():
+				log.Printf("🎬 Demo scenario %s stopped, client disconnected", name)
+				return
+			}
+
+			fixture := fixtures[step.Fixture]
+			progress := ScenarioProgress{Scenario: name, Step: i + 1, Total: len(steps), Name: step.Name, Path: fixture.Path, Status: "running"}
+			c.SSEvent("step", progress)
+			c.Writer.Flush()
+
+			progress.HTTP, progress.Response = runScenarioStep(service, fixture)
+			progress.Status = "completed"
+			if progress.HTTP >= 400 {
+				progress.Status = "failed"
+				success = false
+			}
+			c.SSEvent("step", progress)
+			c.Writer.Flush()
+			if !success {
+				break
+			}
+		}
+
+		c.SSEvent("done", gin.H{
+			"scenario":    name,
+			"success":     success,
+			"duration_ms": time.Since(started).Milliseconds(),
+		})
+		c.Writer.Flush()
+		log.Printf("🎬 Demo scenario %s finished (success: %t) in %s", name, success, time.Since(started).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of scheduled jobs
+const (
+	JobLeadCall        = "lead_call"         // Lead call held in the call queue
+	JobNurtureCall     = "nurture_call"      // Deal-stage nurture call
+	JobMeetingFollowUp = "meeting_follow_up" // Step of a post-meeting follow-up sequence
+	JobMeetingReminder = "meeting_reminder"  // Reminder before a booked Cal.com meeting
+)
+
+// ScheduledJob is a delayed call or message that has to survive a restart
+type ScheduledJob struct {
+	ID      string          `json:"id"`
+	Kind    string          `json:"kind"`
+	RunAt   time.Time       `json:"run_at"`
+	Payload json.RawMessage `json:"payload"`
+
+	timer *time.Timer
+}
+
+// JobScheduler runs jobs at their time and keeps the pending ones in SCHEDULE_FILE, so held calls
+// and follow-ups are not lost when the service restarts
+type JobScheduler struct {
+	mu       sync.Mutex
+	jobs     map[string]*ScheduledJob // Keyed by ID
+	handlers map[string]func(payload json.RawMessage)
+	path     string
+}
+
+// NewJobScheduler creates a job scheduler, loading the jobs saved in SCHEDULE_FILE; they are armed by Start
+func NewJobScheduler(config *Config) *JobScheduler {
+	s := &JobScheduler{
+		jobs:     make(map[string]*ScheduledJob),
+		handlers: make(map[string]func(payload json.RawMessage)),
+		path:     config.ScheduleFile,
+	}
+	if s.path == "" {
+		return s
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read SCHEDULE_FILE %s: %v", s.path, err)
+		}
+		return s
+	}
+	var jobs []*ScheduledJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable SCHEDULE_FILE %s: %v", s.path, err)
+		return s
+	}
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+	}
+	return s
+}
+
+// Handle registers the function running jobs of a kind
+func (s *JobScheduler) Handle(kind string, run func(payload json.RawMessage)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = run
+}
+
+// Start arms the jobs loaded from SCHEDULE_FILE; jobs that fell due while the service was down run now
+func (s *JobScheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	armed := 0
+	for _, job := range s.jobs {
+		if job.timer == nil {
+			s.armLocked(job)
+			armed++
+		}
+	}
+	if armed > 0 {
+		log.Printf("⏰ Resumed %d scheduled jobs", armed)
+	}
+}
+
+// Schedule runs a job at a time, replacing the pending job with the same ID
+func (s *JobScheduler) Schedule(id, kind string, at time.Time, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.jobs[id]; ok && existing.timer != nil {
+		existing.timer.Stop()
+	}
+	job := &ScheduledJob{ID: id, Kind: kind, RunAt: at.UTC(), Payload: data}
+	s.jobs[id] = job
+	s.armLocked(job)
+	s.saveLocked()
+	return nil
+}
+
+// Cancel drops a pending job and reports whether there was one
+func (s *JobScheduler) Cancel(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return false
+	}
+	if job.timer != nil {
+		job.timer.Stop()
+	}
+	delete(s.jobs, id)
+	s.saveLocked()
+	return true
+}
+
+// Jobs returns the pending jobs of a kind by run time
+func (s *JobScheduler) Jobs(kind string) []ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]ScheduledJob, 0)
+	for _, job := range s.jobs {
+		if job.Kind == kind {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].RunAt.Before(jobs[j].RunAt) })
+	return jobs
+}
+
+// armLocked starts a job's timer; callers hold mu
+func (s *JobScheduler) armLocked(job *ScheduledJob) {
+	job.timer = time.AfterFunc(time.Until(job.RunAt), func() { s.fire(job) })
+}
+
+// fire removes a due job and runs it, unless it was cancelled or replaced meanwhile
+func (s *JobScheduler) fire(job *ScheduledJob) {
+	s.mu.Lock()
+	if s.jobs[job.ID] != job {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.jobs, job.ID)
+	s.saveLocked()
+	run := s.handlers[job.Kind]
+	s.mu.Unlock()
+
+	if run == nil {
+		log.Printf("⚠️ Warning: No handler for scheduled %s job %s, dropping it", job.Kind, job.ID)
+		return
+	}
+	run(job.Payload)
+}
+
+// saveLocked rewrites SCHEDULE_FILE; callers hold mu
+func (s *JobScheduler) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	jobs := make([]*ScheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	data, err := json.Marshal(jobs)
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save scheduled jobs: %v", err)
+	}
+}
+
+// handleScheduledJobs registers what each kind of scheduled job does
+func (p *PipedriveService) handleScheduledJobs() {
+	p.schedules.Handle(JobLeadCall, p.runHeldLeadCall)
+	p.schedules.Handle(JobNurtureCall, p.runNurtureJob)
+	p.schedules.Handle(JobMeetingFollowUp, p.sendFollowUp)
+	p.schedules.Handle(JobMeetingReminder, p.runReminderJob)
+}
+
+// resumeScheduledJobs lists the held calls, nurture calls and reminders saved in SCHEDULE_FILE
+// again and arms every saved job
+func (p *PipedriveService) resumeScheduledJobs() {
+	p.restoreHeldLeadCalls()
+	p.restoreNurtures()
+	p.restoreReminders()
+	p.schedules.Start()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScoringRule adds points to a lead score when a signal matches a condition
+type ScoringRule struct {
+	Label  string      `json:"label"`
+	Signal string      `json:"signal"`          // e.g. "call.successful", "analysis.budget", "lead.value", "engagement.activities"
+	Op     string      `json:"op"`              // eq, ne, gt, gte, lt, lte, contains, exists
+	Value  interface{} `json:"value,omitempty"` // Compared against the signal
+	Points float64     `json:"points"`
+}
+
+// LeadScore is the computed score of an analyzed call
+type LeadScore struct {
+	Score   float64  `json:"score"`
+	Matched []string `json:"matched"` // Labels of the rules that added points
+}
+
+// loadScoringRules parses the LEAD_SCORING_RULES JSON list
+func loadScoringRules(raw string) []ScoringRule {
+	if raw == "" {
+		return nil
+	}
+	var rules []ScoringRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Printf("⚠️ Invalid LEAD_SCORING_RULES, ignoring: %v", err)
+		return nil
+	}
+	for i := range rules {
+		rules[i].Op = strings.ToLower(rules[i].Op)
+		if rules[i].Op == "" {
+			rules[i].Op = "eq"
+		}
+		if rules[i].Label == "" {
+			rules[i].Label = fmt.Sprintf("%s %s %v", rules[i].Signal, rules[i].Op, rules[i].Value)
+		}
+	}
+	return rules
+}
+
+// scoringUses returns true if any rule reads a signal with the given prefix
+func (c *Config) scoringUses(prefix string) bool {
+	for _, rule := range c.LeadScoringRules {
+		if strings.HasPrefix(rule.Signal, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// scoringSignals collects call analysis, lead and engagement data for scoring
+func (p *PipedriveService) scoringSignals(payload RetellCallAnalyzedPayload, mapping CallMapping, purchaseIntent bool) map[string]interface{} {
+	analysis := payload.Call.CallAnalysis
+	signals := map[string]interface{}{
+		"call.successful":       analysis.CallSuccessful,
+		"call.sentiment":        analysis.UserSentiment,
+		"call.in_voicemail":     analysis.InVoicemail,
+		"call.duration_seconds": float64(DurationFromMillis(int64(payload.Call.DurationMs)).Seconds()),
+		"call.purchase_intent":  purchaseIntent,
+	}
+	for key, value := range analysis.CustomAnalysisData {
+		signals["analysis."+key] = value
+	}
+
+	if mapping.LeadID != "" && p.config.scoringUses("lead.") {
+		if lead, err := p.GetLead(mapping.LeadID); err != nil {
+			p.logf("⚠️ Warning: Failed to load lead %s for scoring: %v", mapping.LeadID, err)
+		} else {
+			signals["lead.source"] = lead.SourceName
+			if lead.Value != nil {
+				signals["lead.value"] = lead.Value.Amount
+				signals["lead.currency"] = lead.Value.Currency
+			}
+		}
+	}
+
+	if mapping.PersonID != 0 && p.config.scoringUses("engagement.") {
+		if activities, err := p.ListPersonActivities(mapping.PersonID); err != nil {
+			p.logf("⚠️ Warning: Failed to load activities of person %d for scoring: %v", mapping.PersonID, err)
+		} else {
+			done := 0
+			for _, activity := range activities {
+				if activity.Done {
+					done++
+				}
+			}
+			signals["engagement.activities"] = float64(done)
+		}
+	}
+	return signals
+}
+
+// scoreLead applies the configured scoring rules to the call's signals
+func (p *PipedriveService) scoreLead(payload RetellCallAnalyzedPayload, mapping CallMapping, purchaseIntent bool) *LeadScore {
+	if len(p.config.LeadScoringRules) == 0 {
+		return nil
+	}
+	signals := p.scoringSignals(payload, mapping, purchaseIntent)
+
+	score := &LeadScore{Matched: []string{}}
+	for _, rule := range p.config.LeadScoringRules {
+		if rule.matches(signals) {
+			score.Score += rule.Points
+			score.Matched = append(score.Matched, rule.Label)
+		}
+	}
+	sort.Strings(score.Matched)
+	return score
+}
+
+// matches evaluates a rule against the collected signals
+func (r ScoringRule) matches(signals map[string]interface{}) bool {
+	value, ok := signals[r.Signal]
+	if r.Op == "exists" {
+		return ok && formatLeadFieldValue(value) != ""
+	}
+	if !ok {
+		return false
+	}
+
+	actual, actualIsNumber := scoringNumber(value)
+	expected, expectedIsNumber := scoringNumber(r.Value)
+	if actualIsNumber && expectedIsNumber {
+		switch r.Op {
+		case "eq":
+			return actual == expected
+		case "ne":
+			return actual != expected
+		case "gt":
+			return actual > expected
+		case "gte":
+			return actual >= expected
+		case "lt":
+			return actual < expected
+		case "lte":
+			return actual <= expected
+		}
+	}
+
+	actualText := strings.ToLower(formatLeadFieldValue(value))
+	expectedText := strings.ToLower(formatLeadFieldValue(r.Value))
+	switch r.Op {
+	case "eq":
+		return actualText == expectedText
+	case "ne":
+		return actualText != expectedText
+	case "contains":
+		return strings.Contains(actualText, expectedText)
+	}
+	return false
+}
+
+// scoringNumber converts numeric signals and numeric strings to float64
+func scoringNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// writeLeadScore stores the score in the mapped person field
+func (p *PipedriveService) writeLeadScore(personID int, score *LeadScore) {
+	if p.config.LeadScoreField == "" || personID == 0 {
+		return
+	}
+	resp, err := p.makePipedriveRequest("PUT", fmt.Sprintf("/persons/%d", personID), map[string]interface{}{
+		p.config.LeadScoreField: score.Score,
+	})
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to write lead score for person %d: %v", personID, err)
+		return
+	}
+	resp.Body.Close()
+	p.logf("✅ Wrote lead score %g to person %d", score.Score, personID)
+}
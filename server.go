@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// newHTTPServer creates the HTTP server with timeouts tuned for webhook bursts; there is no write
+// timeout, so streamed scenarios and large downloads are not cut off
+func newHTTPServer(addr string, config *Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(config.ServerReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(config.ServerReadTimeout) * time.Second,
+		IdleTimeout:       time.Duration(config.ServerIdleTimeout) * time.Second,
+		MaxHeaderBytes:    1 << 20,
+	}
+}
+
+// newHTTPClient creates the outbound client shared by Pipedrive, Retell and the other integrations
+func newHTTPClient(config *Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = config.OutboundMaxIdleConns
+	transport.MaxIdleConnsPerHost = config.OutboundMaxIdleConnsPerHost
+	transport.IdleConnTimeout = time.Duration(config.OutboundIdleConnTimeout) * time.Second
+	transport.ForceAttemptHTTP2 = true
+	transport.TLSClientConfig = newTLSConfig(config)
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: newChaosTransport(config, &captureTransport{next: &throttleObserver{next: transport}}),
+	}
+}
+
+// tlsVersions maps OUTBOUND_TLS_MIN_VERSION values to TLS versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the outbound TLS settings: minimum version, extra CA certificates and, outside production, skipping verification
+func newTLSConfig(config *Config) *tls.Config {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if version, ok := tlsVersions[config.OutboundTLSMinVersion]; ok {
+		tlsConfig.MinVersion = version
+	} else if config.OutboundTLSMinVersion != "" {
+		log.Printf("⚠️ Invalid OUTBOUND_TLS_MIN_VERSION %q, using 1.2", config.OutboundTLSMinVersion)
+	}
+
+	if config.OutboundCAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(config.OutboundCAFile)
+		if err != nil {
+			log.Printf("⚠️ Cannot read OUTBOUND_CA_FILE %s, using system CAs only: %v", config.OutboundCAFile, err)
+		} else if !pool.AppendCertsFromPEM(pem) {
+			log.Printf("⚠️ No certificates found in OUTBOUND_CA_FILE %s, using system CAs only", config.OutboundCAFile)
+		} else {
+			tlsConfig.RootCAs = pool
+			log.Printf("🔐 Trusting extra CA certificates from %s", config.OutboundCAFile)
+		}
+	}
+
+	if config.OutboundTLSInsecureSkipVerify {
+		if config.IsProduction() {
+			log.Printf("⚠️ OUTBOUND_TLS_INSECURE_SKIP_VERIFY is ignored in production (APP_ENV=%s)", config.AppEnv)
+		} else {
+			log.Printf("⚠️ TLS certificate verification is DISABLED for outbound requests (APP_ENV=%s)", config.AppEnv)
+			tlsConfig.InsecureSkipVerify = true
+		}
+	}
+	return tlsConfig
+}
+
+// compressionMinSize is the smallest response body worth compressing
+const compressionMinSize = 1024
+
+var (
+	gzipWriterPool   = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	brotliWriterPool = sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }}
+)
+
+// CompressionMiddleware compresses responses with brotli or gzip when the client accepts it
+func CompressionMiddleware(config *Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.ResponseCompression || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding}
+		c.Writer = writer
+		c.Header("Vary", "Accept-Encoding")
+		defer writer.Close()
+		c.Next()
+	}
+}
+
+// negotiateEncoding picks brotli over gzip from an Accept-Encoding header
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(fields) > 1 && strings.TrimSpace(fields[1]) == "q=0" {
+			continue
+		}
+		accepted[name] = true
+	}
+	switch {
+	case accepted["br"]:
+		return "br"
+	case accepted["gzip"]:
+		return "gzip"
+	}
+	return ""
+}
+
+// compressWriter buffers small responses and compresses larger ones
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding   string
+	compressor io.WriteCloser
+	buffer     []byte
+	skip       bool // Response is not compressed (already encoded, streamed or too small)
+}
+
+// WriteHeader defers the status until we know whether the body is compressed
+func (w *compressWriter) WriteHeader(code int) {
+	if code == http.StatusNoContent || code == http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+		w.skip = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// WriteString writes a string body
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Write buffers until the body is large enough to compress
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.skip {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.compressor != nil {
+		return w.compressor.Write(data)
+	}
+
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) < compressionMinSize {
+		return len(data), nil
+	}
+	if err := w.startCompression(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// startCompression sets the encoding headers and flushes the buffer through the compressor
+func (w *compressWriter) startCompression() error {
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+
+	switch w.encoding {
+	case "br":
+		bw := brotliWriterPool.Get().(*brotli.Writer)
+		bw.Reset(w.ResponseWriter)
+		w.compressor = bw
+	default:
+		gw := gzipWriterPool.Get().(*gzip.Writer)
+		gw.Reset(w.ResponseWriter)
+		w.compressor = gw
+	}
+
+	buffered := w.buffer
+	w.buffer = nil
+	_, err := w.compressor.Write(buffered)
+	return err
+}
+
+// Flush streams compressed data immediately (used by streaming responses)
+func (w *compressWriter) Flush() {
+	if w.compressor == nil && !w.skip {
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			// Server-sent events are passed through uncompressed
+			w.skip = true
+			w.ResponseWriter.Write(w.buffer)
+			w.buffer = nil
+		} else if err := w.startCompression(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok && !w.skip {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Hijack is not supported once compression may have started
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.compressor != nil {
+		return nil, nil, fmt.Errorf("cannot hijack a compressed response")
+	}
+	return w.ResponseWriter.Hijack()
+}
+
+// Close finishes the compressed stream or writes a small response as-is
+func (w *compressWriter) Close() {
+	if w.compressor == nil {
+		if len(w.buffer) > 0 {
+			w.ResponseWriter.Write(w.buffer)
+		}
+		return
+	}
+
+	w.compressor.Close()
+	switch compressor := w.compressor.(type) {
+	case *brotli.Writer:
+		compressor.Reset(io.Discard)
+		brotliWriterPool.Put(compressor)
+	case *gzip.Writer:
+		compressor.Reset(io.Discard)
+		gzipWriterPool.Put(compressor)
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Short link settings
+const (
+	shortCodeLength     = 7
+	shortCodeAlphabet   = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789" // No look-alike characters
+	shortLinkClickDedup = 10 * time.Minute                                           // Repeat clicks within this window are not noted again
+	shortLinkFlushEvery = 30 * time.Second                                           // Click counts are saved this often rather than on every click
+)
+
+// shortCodePattern limits vanity codes to URL-safe characters
+var shortCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// linkPattern finds URLs in outgoing messages
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// linkPreviewAgents are crawlers that fetch links to render message previews, not people clicking
+var linkPreviewAgents = []string{"bot", "facebookexternalhit", "whatsapp", "slack", "preview", "crawler", "spider"}
+
+// ShortLink maps a short code to a target URL for one person
+type ShortLink struct {
+	Code        string    `json:"code"`
+	URL         string    `json:"url"`
+	PersonID    int       `json:"person_id,omitempty"`
+	Kind        string    `json:"kind,omitempty"` // e.g. booking, recording
+	CreatedAt   time.Time `json:"created_at"`
+	Clicks      int       `json:"clicks"`
+	LastClickAt time.Time `json:"last_click_at,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"` // Zero never expires
+}
+
+// expired reports whether the link has passed its expiry
+func (l *ShortLink) expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && now.After(l.ExpiresAt)
+}
+
+// ShortLinkStore keeps short links, saved to SHORT_LINKS_FILE when set
+type ShortLinkStore struct {
+	mu    sync.Mutex
+	path  string
+	ttl   time.Duration         // SHORT_LINK_TTL_DAYS; zero keeps links forever
+	links map[string]*ShortLink // Keyed by code
+	dirty bool                  // Clicks not yet saved
+}
+
+// NewShortLinkStore creates a short link store, loading SHORT_LINKS_FILE when set
+func NewShortLinkStore(config *Config) *ShortLinkStore {
+	store := &ShortLinkStore{
+		path:  config.ShortLinksFile,
+		ttl:   time.Duration(config.ShortLinkTTLDays) * 24 * time.Hour,
+		links: make(map[string]*ShortLink),
+	}
+	if store.path == "" {
+		return store
+	}
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read SHORT_LINKS_FILE %s: %v", store.path, err)
+		}
+		return store
+	}
+	if err := json.Unmarshal(data, &store.links); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable SHORT_LINKS_FILE %s: %v", store.path, err)
+		store.links = make(map[string]*ShortLink)
+		return store
+	}
+	// Links saved before expiry existed get one from their creation time
+	for _, link := range store.links {
+		if link.ExpiresAt.IsZero() && store.ttl > 0 {
+			link.ExpiresAt = link.CreatedAt.Add(store.ttl)
+		}
+	}
+	if store.pruneLocked(time.Now()) > 0 {
+		store.saveLocked()
+	}
+	log.Printf("🔗 Loaded %d short links from %s", len(store.links), store.path)
+	return store
+}
+
+// pruneLocked drops expired links and returns how many were dropped; callers must hold mu
+func (s *ShortLinkStore) pruneLocked(now time.Time) int {
+	dropped := 0
+	for code, link := range s.links {
+		if link.expired(now) {
+			delete(s.links, code)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// saveLocked writes the links to SHORT_LINKS_FILE; callers must hold mu
+func (s *ShortLinkStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s.links)
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save short links: %v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// runFlush saves click counts and drops expired links every shortLinkFlushEvery
+func (s *ShortLinkStore) runFlush() {
+	ticker := time.NewTicker(shortLinkFlushEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		if s.pruneLocked(time.Now()) > 0 || s.dirty {
+			s.saveLocked()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// newShortCode returns a random code from shortCodeAlphabet
+func newShortCode() (string, error) {
+	code := make([]byte, shortCodeLength)
+	max := big.NewInt(int64(len(shortCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = shortCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// Create stores a link, reusing the person's existing code for the same URL; code may be a
+// vanity code or empty for a random one
+func (s *ShortLinkStore) Create(target string, personID int, kind, code string) (ShortLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.pruneLocked(now)
+	if code == "" {
+		for _, link := range s.links {
+			if link.URL == target && link.PersonID == personID {
+				return *link, nil
+			}
+		}
+		for {
+			generated, err := newShortCode()
+			if err != nil {
+				return ShortLink{}, err
+			}
+			if _, taken := s.links[generated]; !taken {
+				code = generated
+				break
+			}
+		}
+	} else if _, taken := s.links[code]; taken {
+		return ShortLink{}, fmt.Errorf("code %q is already in use", code)
+	}
+
+	link := &ShortLink{Code: code, URL: target, PersonID: personID, Kind: kind, CreatedAt: now}
+	if s.ttl > 0 {
+		link.ExpiresAt = now.Add(s.ttl)
+	}
+	s.links[code] = link
+	s.saveLocked()
+	return *link, nil
+}
+
+// lookup returns a link without counting a click
+func (s *ShortLinkStore) lookup(code string) (ShortLink, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	link, ok := s.links[code]
+	if !ok || link.expired(time.Now()) {
+		return ShortLink{}, false
+	}
+	return *link, true
+}
+
+// Click counts a click and returns the link and whether it is the first click in the dedup
+// window; the count is saved by runFlush
+func (s *ShortLinkStore) Click(code string) (ShortLink, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	link, ok := s.links[code]
+	if !ok || link.expired(now) {
+		return ShortLink{}, false, false
+	}
+	fresh := now.Sub(link.LastClickAt) > shortLinkClickDedup
+	link.Clicks++
+	link.LastClickAt = now
+	s.dirty = true
+	return *link, true, fresh
+}
+
+// List returns all links, newest first
+func (s *ShortLinkStore) List() []ShortLink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	links := make([]ShortLink, 0, len(s.links))
+	for _, link := range s.links {
+		links = append(links, *link)
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].CreatedAt.After(links[j].CreatedAt) })
+	return links
+}
+
+// shortLinkHost returns the base URL short links are served from
+func (c *Config) shortLinkHost() string {
+	if c.ShortLinkBaseURL != "" {
+		return strings.TrimSuffix(c.ShortLinkBaseURL, "/")
+	}
+	return c.PublicBaseURL
+}
+
+// shortLinkBase returns the base URL for shortening outgoing messages, or "" when that is off
+func (c *Config) shortLinkBase() string {
+	if !c.ShortLinksEnabled {
+		return ""
+	}
+	return c.shortLinkHost()
+}
+
+// shortenURL returns a short link for the URL, or the URL itself when short links are off
+func (p *PipedriveService) shortenURL(target string, personID int, kind string) string {
+	base := p.config.shortLinkBase()
+	if base == "" || strings.HasPrefix(target, base+"/r/") {
+		return target
+	}
+	link, err := p.shortLinks.Create(target, personID, kind, "")
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to shorten %s: %v", target, err)
+		return target
+	}
+	return base + "/r/" + link.Code
+}
+
+// shortenLinks replaces every URL in an outgoing message with a tracked short link
+func (p *PipedriveService) shortenLinks(message string, personID int) string {
+	if p.config.shortLinkBase() == "" {
+		return message
+	}
+	return linkPattern.ReplaceAllStringFunc(message, func(target string) string {
+		trimmed := strings.TrimRight(target, ".,;:!?)")
+		return p.shortenURL(trimmed, personID, linkKind(trimmed)) + target[len(trimmed):]
+	})
+}
+
+// linkKind guesses what a link points at for engagement notes
+func linkKind(target string) string {
+	lower := strings.ToLower(target)
+	switch {
+	case strings.Contains(lower, "recording") || strings.HasSuffix(lower, ".mp3") || strings.HasSuffix(lower, ".wav"):
+		return "recording"
+	case strings.Contains(lower, "cal.com") || strings.Contains(lower, "book") || strings.Contains(lower, "meeting"):
+		return "booking"
+	}
+	return "link"
+}
+
+// isLinkPreview reports whether a request comes from a link preview crawler
+func isLinkPreview(userAgent string) bool {
+	lower := strings.ToLower(userAgent)
+	for _, agent := range linkPreviewAgents {
+		if strings.Contains(lower, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordLinkClick adds an engagement note to the person for a short link click
+func (p *PipedriveService) recordLinkClick(link ShortLink) {
+	if link.PersonID == 0 || !p.config.HasPipedriveConfig() {
+		return
+	}
+	noteData := map[string]interface{}{
+		"content": fmt.Sprintf("Engagement: clicked %s link\nLink: %s\nClicked at: %s\nTotal clicks: %d",
+			link.Kind, link.URL, link.LastClickAt.UTC().Format(time.RFC3339), link.Clicks),
+		"person_id": link.PersonID,
+	}
+	resp, err := p.makePipedriveRequest("POST", "/notes", noteData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to note link click for person %d: %v", link.PersonID, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// ShortLinkRedirectHandler redirects a short code to its target and records the click
+func ShortLinkRedirectHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isLinkPreview(c.GetHeader("User-Agent")) {
+			link, ok := pipedriveService.shortLinks.lookup(c.Param("code"))
+			if !ok {
+				c.String(http.StatusNotFound, "Link not found")
+				return
+			}
+			c.Redirect(http.StatusFound, link.URL)
+			return
+		}
+		link, ok, fresh := pipedriveService.shortLinks.Click(c.Param("code"))
+		if !ok {
+			c.String(http.StatusNotFound, "Link not found")
+			return
+		}
+		if fresh {
+			go pipedriveService.recordLinkClick(link)
+		}
+		c.Redirect(http.StatusFound, link.URL)
+	}
+}
+
+// CreateShortLinkRequest creates a short link, optionally with a vanity code
+type CreateShortLinkRequest struct {
+	URL      string `json:"url"`
+	PersonID int    `json:"person_id"`
+	Kind     string `json:"kind"`
+	Code     string `json:"code"`
+}
+
+// CreateShortLinkHandler creates a short link
+func CreateShortLinkHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request CreateShortLinkRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+		if target, err := url.Parse(request.URL); err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "url must be an absolute http(s) URL",
+			})
+			return
+		}
+		if request.Code != "" && !shortCodePattern.MatchString(request.Code) {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "code must be 3-32 letters, digits, '-' or '_'",
+			})
+			return
+		}
+		if request.Kind == "" {
+			request.Kind = linkKind(request.URL)
+		}
+		link, err := pipedriveService.shortLinks.Create(request.URL, request.PersonID, request.Kind, request.Code)
+		if err != nil {
+			c.JSON(http.StatusConflict, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Short link created",
+			Data:    gin.H{"link": link, "short_url": pipedriveService.config.shortLinkHost() + "/r/" + link.Code},
+		})
+	}
+}
+
+// ListShortLinksHandler lists short links with their click counts
+func ListShortLinksHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		links := pipedriveService.shortLinks.List()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d short links", len(links)),
+			Data:    links,
+		})
+	}
+}
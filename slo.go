@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sloBucketSize is the resolution webhook latency samples are kept at
+const sloBucketSize = 5 * time.Minute
+
+// sloJobPrefix names background job kinds in the tracker, e.g. "job:retell_call_analyzed"
+const sloJobPrefix = "job:"
+
+// sloLatencyBoundsMs are the upper bounds of the latency histogram; a final bucket counts anything slower
+var sloLatencyBoundsMs = []int64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// Burn-rate alert windows (long window, short window), as in multiwindow SLO alerting
+var (
+	sloFastBurnWindows = [2]time.Duration{time.Hour, 5 * time.Minute}
+	sloSlowBurnWindows = [2]time.Duration{6 * time.Hour, 30 * time.Minute}
+)
+
+// SLOTarget is the objective for one endpoint: the share of requests that must succeed within the latency target
+type SLOTarget struct {
+	LatencyMs int64   `json:"latency_ms"`
+	Target    float64 `json:"target"` // e.g. 0.995
+}
+
+// sloBucket aggregates the requests to one endpoint in one bucket period
+type sloBucket struct {
+	Start   int64   `json:"start"`   // Unix seconds
+	Count   int64   `json:"count"`   // Requests
+	Errors  int64   `json:"errors"`  // 5xx responses
+	Slow    int64   `json:"slow"`    // Successful responses slower than the latency target
+	Latency []int64 `json:"latency"` // Request counts per sloLatencyBoundsMs bucket
+}
+
+// SLOReport is an endpoint's performance against its objective over a window
+type SLOReport struct {
+	Endpoint             string  `json:"endpoint"`
+	Target               float64 `json:"target"`
+	LatencyTargetMs      int64   `json:"latency_target_ms"`
+	WindowDays           int     `json:"window_days"`
+	Requests             int64   `json:"requests"`
+	SuccessRate          float64 `json:"success_rate"` // Non-5xx responses
+	Compliance           float64 `json:"compliance"`   // Successful within the latency target
+	P50Ms                int64   `json:"p50_ms"`       // Histogram upper bounds
+	P95Ms                int64   `json:"p95_ms"`
+	P99Ms                int64   `json:"p99_ms"`
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"` // 1 = untouched, negative = objective missed
+	BurnRate1h           float64 `json:"burn_rate_1h"`
+	BurnRate6h           float64 `json:"burn_rate_6h"`
+	Alerting             bool    `json:"alerting"`
+}
+
+// SLOTracker aggregates webhook latency and success per endpoint, optionally persisted to SLO_FILE
+type SLOTracker struct {
+	mu        sync.Mutex
+	config    *Config
+	endpoints map[string]map[int64]*sloBucket // Endpoint -> bucket start -> bucket
+	alerting  map[string]bool                 // Endpoints with a firing burn-rate alert
+}
+
+// NewSLOTracker creates a tracker, loading buckets saved in SLO_FILE
+func NewSLOTracker(config *Config) *SLOTracker {
+	tracker := &SLOTracker{
+		config:    config,
+		endpoints: make(map[string]map[int64]*sloBucket),
+		alerting:  make(map[string]bool),
+	}
+	if config.SLOFile == "" {
+		return tracker
+	}
+	data, err := os.ReadFile(config.SLOFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read SLO_FILE %s: %v", config.SLOFile, err)
+		}
+		return tracker
+	}
+	var saved map[string][]*sloBucket
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable SLO_FILE %s: %v", config.SLOFile, err)
+		return tracker
+	}
+	for endpoint, buckets := range saved {
+		tracker.endpoints[endpoint] = make(map[int64]*sloBucket)
+		for _, bucket := range buckets {
+			if len(bucket.Latency) == len(sloLatencyBoundsMs)+1 {
+				tracker.endpoints[endpoint][bucket.Start] = bucket
+			}
+		}
+	}
+	log.Printf("📈 Loaded SLO history for %d endpoints from %s", len(tracker.endpoints), config.SLOFile)
+	return tracker
+}
+
+// sloTargetFor returns the objective for an endpoint, from SLO_TARGETS or the defaults
+func (c *Config) sloTargetFor(endpoint string) SLOTarget {
+	target := SLOTarget{LatencyMs: int64(c.SLOLatencyMs), Target: c.SLOTarget}
+	if strings.HasPrefix(endpoint, sloJobPrefix) {
+		target.LatencyMs = int64(c.SLOJobLatencyMs)
+	}
+	if override, ok := c.SLOTargets[endpoint]; ok {
+		if override.LatencyMs > 0 {
+			target.LatencyMs = override.LatencyMs
+		}
+		if override.Target > 0 && override.Target < 1 {
+			target.Target = override.Target
+		}
+	}
+	return target
+}
+
+// tracksSLO reports whether an endpoint is covered by SLO_ENDPOINTS
+func (c *Config) tracksSLO(endpoint string) bool {
+	for _, prefix := range c.SLOEndpoints {
+		if strings.HasPrefix(endpoint, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Record adds one request to its endpoint's current bucket
+func (s *SLOTracker) Record(endpoint string, status int, latency time.Duration) {
+	target := s.config.sloTargetFor(endpoint)
+	ms := latency.Milliseconds()
+	start := time.Now().Truncate(sloBucketSize).Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buckets, ok := s.endpoints[endpoint]
+	if !ok {
+		buckets = make(map[int64]*sloBucket)
+		s.endpoints[endpoint] = buckets
+	}
+	bucket, ok := buckets[start]
+	if !ok {
+		bucket = &sloBucket{Start: start, Latency: make([]int64, len(sloLatencyBoundsMs)+1)}
+		buckets[start] = bucket
+	}
+	bucket.Count++
+	switch {
+	case status >= 500:
+		bucket.Errors++
+	case ms > target.LatencyMs:
+		bucket.Slow++
+	}
+	index := sort.Search(len(sloLatencyBoundsMs), func(i int) bool { return ms <= sloLatencyBoundsMs[i] })
+	bucket.Latency[index]++
+}
+
+// RecordJob adds a finished background job, timed from acceptance so queue wait counts; a failed or
+// shed job counts as an error
+func (s *SLOTracker) RecordJob(kind string, err error, elapsed time.Duration) {
+	endpoint := sloJobPrefix + kind
+	if !s.config.tracksSLO(endpoint) {
+		return
+	}
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+	}
+	s.Record(endpoint, status, elapsed)
+}
+
+// sumLocked totals an endpoint's buckets that started within the window; callers hold mu
+func (s *SLOTracker) sumLocked(endpoint string, window time.Duration) sloBucket {
+	total := sloBucket{Latency: make([]int64, len(sloLatencyBoundsMs)+1)}
+	cutoff := time.Now().Add(-window).Truncate(sloBucketSize).Unix()
+	for start, bucket := range s.endpoints[endpoint] {
+		if start < cutoff {
+			continue
+		}
+		total.Count += bucket.Count
+		total.Errors += bucket.Errors
+		total.Slow += bucket.Slow
+		for i, n := range bucket.Latency {
+			total.Latency[i] += n
+		}
+	}
+	return total
+}
+
+// percentile returns the histogram upper bound below which the fraction q of requests fall
+func (b sloBucket) percentile(q float64) int64 {
+	if b.Count == 0 {
+		return 0
+	}
+	var seen int64
+	for i, n := range b.Latency {
+		seen += n
+		if float64(seen) >= q*float64(b.Count) && i < len(sloLatencyBoundsMs) {
+			return sloLatencyBoundsMs[i]
+		}
+	}
+	return sloLatencyBoundsMs[len(sloLatencyBoundsMs)-1]
+}
+
+// burnRate is how fast the window consumes the error budget; 1 spends exactly the budget over the SLO window
+func (b sloBucket) burnRate(target float64) float64 {
+	if b.Count == 0 || target >= 1 {
+		return 0
+	}
+	return (float64(b.Errors+b.Slow) / float64(b.Count)) / (1 - target)
+}
+
+// Report summarizes every tracked endpoint over the last days
+func (s *SLOTracker) Report(days int) []SLOReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	window := time.Duration(days) * 24 * time.Hour
+
+	reports := make([]SLOReport, 0, len(s.endpoints))
+	for endpoint := range s.endpoints {
+		target := s.config.sloTargetFor(endpoint)
+		total := s.sumLocked(endpoint, window)
+		report := SLOReport{
+			Endpoint:             endpoint,
+			Target:               target.Target,
+			LatencyTargetMs:      target.LatencyMs,
+			WindowDays:           days,
+			Requests:             total.Count,
+			SuccessRate:          1,
+			Compliance:           1,
+			P50Ms:                total.percentile(0.50),
+			P95Ms:                total.percentile(0.95),
+			P99Ms:                total.percentile(0.99),
+			ErrorBudgetRemaining: 1 - total.burnRate(target.Target),
+			BurnRate1h:           s.sumLocked(endpoint, time.Hour).burnRate(target.Target),
+			BurnRate6h:           s.sumLocked(endpoint, 6*time.Hour).burnRate(target.Target),
+			Alerting:             s.alerting[endpoint],
+		}
+		if total.Count > 0 {
+			report.SuccessRate = 1 - float64(total.Errors)/float64(total.Count)
+			report.Compliance = 1 - float64(total.Errors+total.Slow)/float64(total.Count)
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Endpoint < reports[j].Endpoint })
+	return reports
+}
+
+// evaluate checks burn rates and returns alert messages for endpoints that started or stopped burning too fast
+func (s *SLOTracker) evaluate() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []string
+	for endpoint := range s.endpoints {
+		target := s.config.sloTargetFor(endpoint)
+		burning := func(windows [2]time.Duration, threshold float64) (bool, float64) {
+			long := s.sumLocked(endpoint, windows[0]).burnRate(target.Target)
+			short := s.sumLocked(endpoint, windows[1]).burnRate(target.Target)
+			return threshold > 0 && long > threshold && short > threshold, long
+		}
+		fast, fastRate := burning(sloFastBurnWindows, s.config.SLOFastBurnRate)
+		slow, slowRate := burning(sloSlowBurnWindows, s.config.SLOSlowBurnRate)
+
+		switch {
+		case (fast || slow) && !s.alerting[endpoint]:
+			s.alerting[endpoint] = true
+			messages = append(messages, fmt.Sprintf("🔥 SLO burn on %s: %.1fx over 1h, %.1fx over 6h (target %.2f%% within %dms)",
+				endpoint, fastRate, slowRate, target.Target*100, target.LatencyMs))
+		case !fast && !slow && s.alerting[endpoint]:
+			delete(s.alerting, endpoint)
+			messages = append(messages, fmt.Sprintf("✅ SLO burn on %s resolved: %.1fx over 1h", endpoint, fastRate))
+		}
+	}
+	return messages
+}
+
+// prune drops buckets older than the SLO window
+func (s *SLOTracker) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := time.Now().AddDate(0, 0, -s.config.SLOWindowDays).Unix()
+	for endpoint, buckets := range s.endpoints {
+		for start := range buckets {
+			if start < cutoff {
+				delete(buckets, start)
+			}
+		}
+		if len(buckets) == 0 {
+			delete(s.endpoints, endpoint)
+		}
+	}
+}
+
+// save writes all buckets to SLO_FILE, replacing it atomically
+func (s *SLOTracker) save() error {
+	if s.config.SLOFile == "" {
+		return nil
+	}
+	s.mu.Lock()
+	saved := make(map[string][]*sloBucket, len(s.endpoints))
+	for endpoint, buckets := range s.endpoints {
+		for _, bucket := range buckets {
+			copied := *bucket
+			copied.Latency = append([]int64(nil), bucket.Latency...)
+			saved[endpoint] = append(saved[endpoint], &copied)
+		}
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("failed to encode SLO history: %v", err)
+	}
+	tmp := s.config.SLOFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write SLO history: %v", err)
+	}
+	if err := os.Rename(tmp, s.config.SLOFile); err != nil {
+		return fmt.Errorf("failed to replace SLO history: %v", err)
+	}
+	return nil
+}
+
+// runSLOMonitor saves SLO history and checks burn-rate alerts every minute
+func (p *PipedriveService) runSLOMonitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.slo.prune()
+		if err := p.slo.save(); err != nil {
+			p.logf("⚠️ Warning: %v", err)
+		}
+		for _, message := range p.slo.evaluate() {
+			p.logf("%s", message)
+			p.sendSLOAlert(message)
+		}
+	}
+}
+
+// sendSLOAlert posts an alert to SLO_ALERT_WEBHOOK_URL (Slack-compatible {"text": ...} body)
+func (p *PipedriveService) sendSLOAlert(message string) {
+	if p.config.SLOAlertWebhookURL == "" {
+		return
+	}
+	body, _ := json.Marshal(map[string]string{"text": message})
+	resp, err := p.httpClient.Post(p.config.SLOAlertWebhookURL, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to send SLO alert: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		p.logf("⚠️ Warning: SLO alert webhook returned HTTP %d", resp.StatusCode)
+	}
+}
+
+// SLOMiddleware records latency and status of requests to endpoints covered by SLO_ENDPOINTS
+func SLOMiddleware(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		endpoint := c.FullPath()
+		if endpoint == "" || !pipedriveService.config.tracksSLO(endpoint) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		pipedriveService.slo.Record(endpoint, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// SLOReportHandler reports per-endpoint latency percentiles, success rate and error budget
+func SLOReportHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days := pipedriveService.config.SLOWindowDays
+		if raw := c.Query("days"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed <= 0 || parsed > pipedriveService.config.SLOWindowDays {
+				c.JSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: fmt.Sprintf("days must be between 1 and %d", pipedriveService.config.SLOWindowDays),
+				})
+				return
+			}
+			days = parsed
+		}
+		reports := pipedriveService.slo.Report(days)
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("SLO report for %d endpoints over %d days", len(reports), days),
+			Data:    reports,
+		})
+	}
+}
+
+// loadSLOTargets parses SLO_TARGETS (JSON: route -> {"latency_ms", "target"})
+func loadSLOTargets(raw string) map[string]SLOTarget {
+	targets := make(map[string]SLOTarget)
+	if raw == "" {
+		return targets
+	}
+	if err := json.Unmarshal([]byte(raw), &targets); err != nil {
+		log.Printf("⚠️ Invalid SLO_TARGETS, ignoring: %v", err)
+		return make(map[string]SLOTarget)
+	}
+	return targets
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Snooze suppresses automated outreach to a person until a given time
+type Snooze struct {
+	PersonID  int       `json:"person_id"`
+	Until     time.Time `json:"until"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SnoozeStore keeps active snoozes by person ID
+type SnoozeStore struct {
+	mu      sync.RWMutex
+	snoozes map[int]Snooze
+}
+
+// NewSnoozeStore creates a new snooze store
+func NewSnoozeStore() *SnoozeStore {
+	return &SnoozeStore{snoozes: make(map[int]Snooze)}
+}
+
+// Set snoozes a person until the given time
+func (s *SnoozeStore) Set(snooze Snooze) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snoozes[snooze.PersonID] = snooze
+}
+
+// Clear removes a person's snooze and reports whether one existed
+func (s *SnoozeStore) Clear(personID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.snoozes[personID]
+	delete(s.snoozes, personID)
+	return ok
+}
+
+// Active returns the person's snooze if it has not expired yet
+func (s *SnoozeStore) Active(personID int) (Snooze, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snooze, ok := s.snoozes[personID]
+	if !ok || time.Now().After(snooze.Until) {
+		return Snooze{}, false
+	}
+	return snooze, true
+}
+
+// List returns all active snoozes, soonest expiry first, dropping expired ones
+func (s *SnoozeStore) List() []Snooze {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	snoozes := make([]Snooze, 0, len(s.snoozes))
+	for personID, snooze := range s.snoozes {
+		if now.After(snooze.Until) {
+			delete(s.snoozes, personID)
+			continue
+		}
+		snoozes = append(snoozes, snooze)
+	}
+	sort.Slice(snoozes, func(i, j int) bool { return snoozes[i].Until.Before(snoozes[j].Until) })
+	return snoozes
+}
+
+// isSnoozed returns true and logs when outreach to a person is suppressed, either by a snooze
+// or by a call hold after their phone was removed or they were marked DNC
+func (p *PipedriveService) isSnoozed(personID int, action string) bool {
+	if reason, held := p.personChanges.Held(personID); held {
+		p.logf("🔕 Calls to person %d are held (%s), skipping %s", personID, reason, action)
+		return true
+	}
+	snooze, ok := p.snoozes.Active(personID)
+	if ok {
+		p.logf("😴 Person %d is snoozed until %s, skipping %s", personID, snooze.Until.Format(time.RFC3339), action)
+	}
+	return ok
+}
+
+// parseSnoozeUntil accepts an RFC3339 timestamp or a duration from now (e.g. "48h")
+func parseSnoozeUntil(value string) (time.Time, error) {
+	if until, err := time.Parse(time.RFC3339, value); err == nil {
+		return until.UTC(), nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return time.Now().Add(duration), nil
+	}
+	return time.Time{}, fmt.Errorf("until must be an RFC3339 timestamp or a positive duration")
+}
+
+// SnoozePersonHandler suppresses automated outreach to a person until the given time
+func SnoozePersonHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		personID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid person ID",
+			})
+			return
+		}
+
+		until, err := parseSnoozeUntil(c.Query("until"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid until: " + err.Error(),
+			})
+			return
+		}
+		if !until.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "until must be in the future",
+			})
+			return
+		}
+
+		snooze := Snooze{PersonID: personID, Until: until, CreatedAt: time.Now()}
+		if user, ok := c.Get("admin_user"); ok {
+			snooze.CreatedBy = user.(*AdminUser).Name
+		}
+		pipedriveService.snoozes.Set(snooze)
+		log.Printf("😴 Snoozed person %d until %s", personID, until.Format(time.RFC3339))
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Person %d snoozed until %s", personID, until.Format(time.RFC3339)),
+			Data:    snooze,
+		})
+	}
+}
+
+// UnsnoozePersonHandler lifts a person's snooze early
+func UnsnoozePersonHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		personID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid person ID",
+			})
+			return
+		}
+		if !pipedriveService.snoozes.Clear(personID) {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Person is not snoozed",
+			})
+			return
+		}
+		log.Printf("⏰ Lifted snooze for person %d", personID)
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Person %d unsnoozed", personID),
+		})
+	}
+}
+
+// ListSnoozesHandler returns the active snoozes for the admin dashboard
+func ListSnoozesHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		snoozes := pipedriveService.snoozes.List()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d snoozed persons", len(snoozes)),
+			Data:    snoozes,
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stale initiation activity outcomes
+const (
+	StaleActivityDeleted = "deleted" // The analyzed-call activity already records the call
+	StaleActivityClosed  = "closed"  // Marked done with a note on what happened to the call
+	StaleActivityFailed  = "failed"  // Could not be checked with Retell or updated in Pipedrive
+	StaleActivityKept    = "kept"    // Retell still has the call in progress
+)
+
+// initiationSubjects prefix the pending activities created when a call is placed
+var initiationSubjects = []string{"AI Call Initiated", "AI Nurture Call Initiated"}
+
+// initiationCallID reads the call ID from an initiation activity's note
+var initiationCallID = regexp.MustCompile(`Call ID: (\S+)`)
+
+// StaleActivityItem is a pending initiation activity the cleanup acted on
+type StaleActivityItem struct {
+	ActivityID int    `json:"activity_id"`
+	PersonID   int    `json:"person_id,omitempty"`
+	CallID     string `json:"call_id,omitempty"`
+	Action     string `json:"action"`
+	Reason     string `json:"reason"` // What our call records say happened to the call
+	Error      string `json:"error,omitempty"`
+}
+
+// StaleActivityRun summarizes one cleanup of stale initiation activities
+type StaleActivityRun struct {
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt time.Time           `json:"finished_at"`
+	Trigger    string              `json:"trigger"` // "system" or the admin who ran it
+	Cutoff     time.Time           `json:"cutoff"`  // Activities added before this were stale
+	Checked    int                 `json:"checked"`
+	Deleted    int                 `json:"deleted"`
+	Closed     int                 `json:"closed"`
+	Failed     int                 `json:"failed"`
+	Kept       int                 `json:"kept"`
+	Items      []StaleActivityItem `json:"items"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// isInitiationActivity reports whether an activity is a pending call initiation activity
+func isInitiationActivity(activity PipedriveActivity) bool {
+	if activity.Done {
+		return false
+	}
+	for _, prefix := range initiationSubjects {
+		if strings.HasPrefix(activity.Subject, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listPendingActivities lists every user's open activities added before the cutoff
+func (p *PipedriveService) listPendingActivities(before time.Time) ([]PipedriveActivity, error) {
+	version := p.config.PipedriveActivityAPI
+	endpoint := fmt.Sprintf("%s/activities?done=false&updated_until=%s", pipedriveV2Prefix, url.QueryEscape(before.UTC().Format(time.RFC3339)))
+	if version != PipedriveActivityAPIV2 {
+		// user_id=0 lists all users; initiation activities are due the day they are added
+		endpoint = fmt.Sprintf("/activities?user_id=0&done=0&type=call&end_date=%s", before.UTC().Format("2006-01-02"))
+	}
+	activities, err := p.listActivitiesAt(endpoint, version)
+	if err != nil {
+		return nil, err
+	}
+	stale := activities[:0]
+	for _, activity := range activities {
+		added, err := parsePipedriveTime(activity.AddTime)
+		if err == nil && added.Before(before) && isInitiationActivity(activity) {
+			stale = append(stale, activity)
+		}
+	}
+	return stale, nil
+}
+
+// staleCallOutcome decides what to do with a call's initiation activity: delete it when the
+// analyzed-call activity already records the call, otherwise close it with the reason. Our call
+// records are kept per replica and may predate a restart, so when they don't show how the call
+// ended Retell's get-call decides.
+func (p *PipedriveService) staleCallOutcome(callID string, personID int) (string, string) {
+	if callID == "" {
+		return StaleActivityClosed, "no call ID on the activity"
+	}
+	if strings.HasPrefix(callID, "failed-") {
+		return StaleActivityClosed, "the call could not be placed with Retell"
+	}
+	events := p.callEvents.Events(callID)
+	if latestCallEvent(events, CallEventCRMUpdated) != nil {
+		if p.config.NoteOnAnalysis {
+			return StaleActivityDeleted, "the call was analyzed and logged as its own activity"
+		}
+		return StaleActivityClosed, "the call completed"
+	}
+	if failed := latestCallEvent(events, CallEventFailed); failed != nil {
+		return StaleActivityClosed, "the call failed: " + failed.Detail
+	}
+	if latestCallEvent(events, CallEventEnded) != nil || latestCallEvent(events, CallEventAnalyzed) != nil {
+		return StaleActivityClosed, "the call ended but its analysis was never logged"
+	}
+	if latestCallEvent(events, CallEventOptedOut) != nil {
+		return StaleActivityClosed, "the person opted out during the call"
+	}
+	return p.retellCallOutcome(callID, personID)
+}
+
+// retellCallOutcome decides what to do with an initiation activity from the call's status in Retell
+func (p *PipedriveService) retellCallOutcome(callID string, personID int) (string, string) {
+	payload, details, err := p.GetRetellCall(callID)
+	if err != nil {
+		if strings.Contains(err.Error(), "HTTP 404") {
+			return StaleActivityClosed, "Retell has no record of the call"
+		}
+		return StaleActivityFailed, "could not check the call with Retell: " + err.Error()
+	}
+	call := payload.Call
+	switch call.CallStatus {
+	case "registered", "ongoing":
+		return StaleActivityKept, "the call is still " + call.CallStatus + " in Retell"
+	case "error":
+		return StaleActivityClosed, "the call failed in Retell: " + call.DisconnectionReason
+	case "not_connected":
+		return StaleActivityClosed, "the call never connected: " + call.DisconnectionReason
+	}
+	if len(details.CallAnalysis) == 0 || string(details.CallAnalysis) == "null" {
+		return StaleActivityClosed, "the call ended (" + call.DisconnectionReason + ") but Retell never analyzed it"
+	}
+	if p.config.NoteOnAnalysis && personID != 0 {
+		mapping, _ := p.getCallMapping(callID)
+		activity, err := p.findCallActivity(personID, mapping.ActivityID, TimestampFromMillis(call.StartTimestamp).UTC)
+		if err != nil {
+			return StaleActivityFailed, "could not check Pipedrive for the call: " + err.Error()
+		}
+		if activity != nil {
+			return StaleActivityDeleted, "the call was analyzed and logged as its own activity"
+		}
+	}
+	return StaleActivityClosed, "the call ended (" + call.DisconnectionReason + ") but its analysis was never logged"
+}
+
+// CleanupStaleActivities closes or deletes "AI Call Initiated" activities still pending after
+// STALE_ACTIVITY_HOURS, so calls that failed silently don't leave open to-dos for reps
+func (p *PipedriveService) CleanupStaleActivities(trigger string) (run StaleActivityRun) {
+	run = StaleActivityRun{StartedAt: time.Now(), Trigger: trigger, Items: []StaleActivityItem{}}
+	run.Cutoff = run.StartedAt.Add(-time.Duration(p.config.StaleActivityHours) * time.Hour).UTC()
+	defer func() {
+		run.FinishedAt = time.Now()
+		p.logf("🧹 Stale activity cleanup: %d checked, %d deleted, %d closed, %d kept, %d failed",
+			run.Checked, run.Deleted, run.Closed, run.Kept, run.Failed)
+	}()
+
+	activities, err := p.listPendingActivities(run.Cutoff)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	for _, activity := range activities {
+		if p.callQueue.HoldsActivity(activity.ID) {
+			continue // Created with the ETA of a call that is still queued
+		}
+		run.Checked++
+		item := StaleActivityItem{ActivityID: activity.ID, PersonID: activity.PersonID}
+		if match := initiationCallID.FindStringSubmatch(activity.Note); match != nil {
+			item.CallID = match[1]
+		}
+		item.Action, item.Reason = p.staleCallOutcome(item.CallID, activity.PersonID)
+
+		switch item.Action {
+		case StaleActivityKept:
+			err = nil
+		case StaleActivityFailed:
+			err = errors.New(item.Reason) // Left open for the next run
+		case StaleActivityDeleted:
+			err = p.deleteActivity(activity.ID)
+		default:
+			err = p.updateActivity(activity.ID, map[string]interface{}{
+				"done": 1,
+				"note": fmt.Sprintf("%s\n\nClosed automatically after %d hours: %s", activity.Note, p.config.StaleActivityHours, item.Reason),
+			})
+		}
+		if err != nil {
+			item.Action, item.Error = StaleActivityFailed, err.Error()
+		}
+
+		switch item.Action {
+		case StaleActivityDeleted:
+			run.Deleted++
+		case StaleActivityClosed:
+			run.Closed++
+		case StaleActivityFailed:
+			run.Failed++
+		case StaleActivityKept:
+			run.Kept++
+		}
+		if item.Action != StaleActivityFailed && item.Action != StaleActivityKept {
+			p.auditLog.Append(AuditEntry{Actor: trigger, Action: "stale_activity_" + item.Action, PersonID: item.PersonID, CallID: item.CallID,
+				Detail: fmt.Sprintf("activity %d: %s", activity.ID, item.Reason)})
+		}
+		run.Items = append(run.Items, item)
+	}
+	return run
+}
+
+// runStaleActivityCleanup cleans up stale initiation activities every hour
+func (p *PipedriveService) runStaleActivityCleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if _, ok := p.claim("stale-activities:" + now.UTC().Format("2006-01-02T15")); ok {
+			p.CleanupStaleActivities(AuditActorSystem)
+		}
+	}
+}
+
+// StaleActivityCleanupHandler cleans up stale initiation activities now
+func StaleActivityCleanupHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveService.config.StaleActivityHours <= 0 {
+			c.JSON(http.StatusConflict, WebhookResponse{
+				Success: false,
+				Message: "Stale activity cleanup is disabled (STALE_ACTIVITY_HOURS unset)",
+			})
+			return
+		}
+		trigger := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			trigger = user.(*AdminUser).Name
+		}
+		run := pipedriveService.CleanupStaleActivities(trigger)
+		status := http.StatusOK
+		if run.Error != "" {
+			status = http.StatusBadGateway
+		}
+		c.JSON(status, WebhookResponse{
+			Success: run.Error == "" && run.Failed == 0,
+			Message: fmt.Sprintf("Cleaned up %d of %d stale activities", run.Deleted+run.Closed, run.Checked),
+			Data:    run,
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stripeSignatureMaxAge is how old a Stripe-Signature timestamp may be
+const stripeSignatureMaxAge = 5 * time.Minute
+
+// PaymentLinkRecord stores a generated payment link for confirmation lookups
+type PaymentLinkRecord struct {
+	LinkID    string    `json:"link_id"`
+	URL       string    `json:"url"`
+	CallID    string    `json:"call_id"`
+	PersonID  int       `json:"person_id"`
+	DealID    int       `json:"deal_id,omitempty"` // Deal the payment notes are attached to
+	CreatedAt time.Time `json:"created_at"`
+	Paid      bool      `json:"paid"`
+}
+
+// StripeClient creates payment links and tracks them until payment is confirmed, saved to
+// STRIPE_PAYMENT_LINKS_FILE when set
+type StripeClient struct {
+	config     *Config
+	httpClient *http.Client
+	mu         sync.Mutex
+	path       string
+	links      map[string]*PaymentLinkRecord // Maps payment link ID to record
+}
+
+// StripeEvent represents the parts of a Stripe webhook event we use
+type StripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			ID            string `json:"id"`
+			PaymentLink   string `json:"payment_link"`
+			AmountTotal   int64  `json:"amount_total"`
+			Currency      string `json:"currency"`
+			PaymentStatus string `json:"payment_status"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+// newStripeClient returns a Stripe client, or nil if Stripe is not configured
+func newStripeClient(config *Config, httpClient *http.Client) *StripeClient {
+	if config.StripeAPIKey == "" || config.StripePriceID == "" {
+		return nil
+	}
+	client := &StripeClient{
+		config:     config,
+		httpClient: httpClient,
+		path:       config.StripeLinksFile,
+		links:      make(map[string]*PaymentLinkRecord),
+	}
+	if client.path == "" {
+		return client
+	}
+	data, err := os.ReadFile(client.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read STRIPE_PAYMENT_LINKS_FILE %s: %v", client.path, err)
+		}
+		return client
+	}
+	if err := json.Unmarshal(data, &client.links); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable STRIPE_PAYMENT_LINKS_FILE %s: %v", client.path, err)
+		client.links = make(map[string]*PaymentLinkRecord)
+	}
+	log.Printf("💳 Loaded %d payment links from %s", len(client.links), client.path)
+	return client
+}
+
+// saveLocked rewrites STRIPE_PAYMENT_LINKS_FILE; callers hold mu
+func (s *StripeClient) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.Marshal(s.links)
+	if err == nil {
+		tmp := s.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save payment links: %v", err)
+	}
+}
+
+// CreatePaymentLink creates a payment link for the configured price
+func (s *StripeClient) CreatePaymentLink(callID string, personID, dealID int) (*PaymentLinkRecord, error) {
+	form := url.Values{}
+	form.Set("line_items[0][price]", s.config.StripePriceID)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("metadata[call_id]", callID)
+	form.Set("metadata[person_id]", strconv.Itoa(personID))
+
+	req, err := http.NewRequest("POST", "https://api.stripe.com/v1/payment_links", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.config.StripeAPIKey, "")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make Stripe request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Stripe payment link failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse Stripe response: %v", err)
+	}
+
+	record := &PaymentLinkRecord{
+		LinkID:    result.ID,
+		URL:       result.URL,
+		CallID:    callID,
+		PersonID:  personID,
+		DealID:    dealID,
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.links[record.LinkID] = record
+	s.saveLocked()
+	s.mu.Unlock()
+
+	log.Printf("💳 Created Stripe payment link %s for person %d", record.LinkID, personID)
+	return record, nil
+}
+
+// markPaid marks a payment link as paid and returns its record
+func (s *StripeClient) markPaid(linkID string) (*PaymentLinkRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.links[linkID]
+	if !ok {
+		return nil, false
+	}
+	record.Paid = true
+	s.saveLocked()
+	return record, true
+}
+
+// OpenLinks returns the unpaid payment links generated for a person
+func (s *StripeClient) OpenLinks(personID int) []PaymentLinkRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var open []PaymentLinkRecord
+	for _, record := range s.links {
+		if record.PersonID == personID && !record.Paid {
+			open = append(open, *record)
+		}
+	}
+	return open
+}
+
+// verifyStripeSignature checks the Stripe-Signature header against the webhook secret; the
+// signature must be at most 5 minutes old, as in Stripe's own libraries
+func verifyStripeSignature(payload []byte, header, secret string, now time.Time) bool {
+	if secret == "" {
+		return false
+	}
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > stripeSignatureMaxAge || age < -stripeSignatureMaxAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + string(payload)))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPurchaseIntent returns true if the analysis data reports a committed purchase
+func hasPurchaseIntent(data map[string]interface{}, field string) bool {
+	switch value := data[field].(type) {
+	case bool:
+		return value
+	case string:
+		switch strings.ToLower(value) {
+		case "committed", "yes", "true":
+			return true
+		}
+	}
+	return false
+}
+
+// paymentNoteTarget attaches a payment note to the record's deal, or its person when there is none
+func paymentNoteTarget(noteData map[string]interface{}, record *PaymentLinkRecord) {
+	if record.DealID != 0 {
+		noteData["deal_id"] = record.DealID
+	} else {
+		noteData["person_id"] = record.PersonID
+	}
+}
+
+// sendPaymentLink creates a payment link for a qualified call and records it on the call's deal
+func (p *PipedriveService) sendPaymentLink(callID string, mapping CallMapping) error {
+	// The deal created from this call is only on the stored mapping
+	dealID := mapping.DealID
+	if current, ok := p.getCallMapping(callID); ok && current.DealID != 0 {
+		dealID = current.DealID
+	}
+	if dealID == 0 {
+		dealID = p.dealForPerson(mapping.PersonID)
+	}
+	record, err := p.stripe.CreatePaymentLink(callID, mapping.PersonID, dealID)
+	if err != nil {
+		return err
+	}
+
+	noteData := map[string]interface{}{
+		"content": fmt.Sprintf("💳 Payment link generated after AI call\nCall ID: %s\nLink: %s", callID, record.URL),
+	}
+	paymentNoteTarget(noteData, record)
+	resp, err := p.makePipedriveRequest("POST", "/notes", noteData)
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to add payment link note: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	if p.config.StripeSendLink && p.whatsApp != nil {
+		message := fmt.Sprintf("Hi %s, here is your payment link: %s", mapping.PersonName, record.URL)
+		if err := p.SendWhatsAppFollowUp(mapping.PersonID, mapping.PhoneNumber, message); err != nil {
+			p.logf("⚠️ Warning: Failed to send payment link to person %d: %v", mapping.PersonID, err)
+		}
+	}
+
+	return nil
+}
+
+// StripeWebhookHandler handles Stripe payment confirmation webhooks
+func StripeWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipedriveService := pipedriveService.forRequest(c)
+		if pipedriveService.stripe == nil {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Stripe integration not configured",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Failed to read request body",
+			})
+			return
+		}
+
+		var event StripeEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Invalid JSON payload",
+			})
+			return
+		}
+
+		if event.Type != "checkout.session.completed" || event.Data.Object.PaymentLink == "" {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "Event ignored: " + event.Type,
+			})
+			return
+		}
+
+		record, ok := pipedriveService.stripe.markPaid(event.Data.Object.PaymentLink)
+		if !ok {
+			pipedriveService.logf("⚠️ Stripe payment for unknown payment link: %s", event.Data.Object.PaymentLink)
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "Unknown payment link",
+			})
+			return
+		}
+
+		pipedriveService.logf("💰 Payment received for person %d via link %s", record.PersonID, record.LinkID)
+
+		if pipedriveService.config.HasPipedriveConfig() {
+			noteData := map[string]interface{}{
+				"content": fmt.Sprintf("💰 Payment received\nAmount: %.2f %s\nCheckout Session: %s\nPayment Link: %s",
+					float64(event.Data.Object.AmountTotal)/100, strings.ToUpper(event.Data.Object.Currency),
+					event.Data.Object.ID, record.URL),
+			}
+			paymentNoteTarget(noteData, record)
+			resp, err := pipedriveService.makePipedriveRequest("POST", "/notes", noteData)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, WebhookResponse{
+					Success: false,
+					Message: "Failed to record payment: " + err.Error(),
+				})
+				return
+			}
+			resp.Body.Close()
+		}
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Stripe payment recorded successfully",
+			Data: gin.H{
+				"person_id":    record.PersonID,
+				"deal_id":      record.DealID,
+				"payment_link": record.LinkID,
+			},
+		})
+	}
+}
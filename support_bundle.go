@@ -0,0 +1,411 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Support capture limits
+const (
+	maxCapturedLogLines  = 1000     // Most recent log lines kept
+	maxCapturedExchanges = 200      // Most recent outbound request/response pairs kept
+	maxCapturedWebhooks  = 50       // Most recent failed webhook requests kept
+	maxCapturedBody      = 8 * 1024 // Bytes of each request or response body kept
+)
+
+// CapturedExchange is one outbound HTTP request and its response
+type CapturedExchange struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Method       string    `json:"method"`
+	URL          string    `json:"url"`
+	Status       int       `json:"status,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	Error        string    `json:"error,omitempty"`
+	RequestBody  string    `json:"request_body,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+}
+
+// CapturedWebhook is an inbound webhook request that was rejected or failed
+type CapturedWebhook struct {
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Payload   string    `json:"payload"`
+	Response  string    `json:"response,omitempty"`
+}
+
+// SupportCapture keeps recent logs, outbound exchanges and failed webhooks for support bundles
+type SupportCapture struct {
+	mu        sync.Mutex
+	logs      []string
+	partial   string // Log output not yet terminated by a newline
+	exchanges []CapturedExchange
+	webhooks  []CapturedWebhook
+}
+
+// supportCapture is shared by the log output, the outbound HTTP clients and the webhook routes
+var supportCapture = &SupportCapture{}
+
+var captureLogsOnce sync.Once
+
+// captureLogs copies the standard logger's output into the support capture
+func captureLogs() {
+	captureLogsOnce.Do(func() {
+		logOutput = io.MultiWriter(os.Stderr, supportCapture)
+		log.SetOutput(logOutput)
+	})
+}
+
+// Write records log output line by line
+func (s *SupportCapture) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := strings.Split(s.partial+string(data), "\n")
+	s.partial = lines[len(lines)-1]
+	s.logs = append(s.logs, lines[:len(lines)-1]...)
+	if len(s.logs) > maxCapturedLogLines {
+		s.logs = append([]string(nil), s.logs[len(s.logs)-maxCapturedLogLines:]...)
+	}
+	return len(data), nil
+}
+
+// addExchange records an outbound request/response pair
+func (s *SupportCapture) addExchange(exchange CapturedExchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchanges = append(s.exchanges, exchange)
+	if len(s.exchanges) > maxCapturedExchanges {
+		s.exchanges = append([]CapturedExchange(nil), s.exchanges[len(s.exchanges)-maxCapturedExchanges:]...)
+	}
+}
+
+// addWebhook records a failed inbound webhook
+func (s *SupportCapture) addWebhook(webhook CapturedWebhook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhooks = append(s.webhooks, webhook)
+	if len(s.webhooks) > maxCapturedWebhooks {
+		s.webhooks = append([]CapturedWebhook(nil), s.webhooks[len(s.webhooks)-maxCapturedWebhooks:]...)
+	}
+}
+
+// snapshot returns copies of everything captured
+func (s *SupportCapture) snapshot() ([]string, []CapturedExchange, []CapturedWebhook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logs...),
+		append([]CapturedExchange(nil), s.exchanges...),
+		append([]CapturedWebhook(nil), s.webhooks...)
+}
+
+// truncateBody shortens a captured body to maxCapturedBody
+func truncateBody(body []byte) string {
+	if len(body) > maxCapturedBody {
+		return truncateRunes(string(body), maxCapturedBody) + "…[truncated]"
+	}
+	return string(body)
+}
+
+// captureTransport records outbound requests and responses for support bundles
+type captureTransport struct {
+	next http.RoundTripper
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	exchange := CapturedExchange{Timestamp: time.Now(), Method: req.Method, URL: req.URL.String()}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxCapturedBody+1))
+			body.Close()
+			exchange.RequestBody = truncateBody(data)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	exchange.DurationMs = time.Since(exchange.Timestamp).Milliseconds()
+	if err != nil {
+		exchange.Error = err.Error()
+		supportCapture.addExchange(exchange)
+		return resp, err
+	}
+	exchange.Status = resp.StatusCode
+	if resp.Body != nil {
+		// Only the captured prefix is read here; the caller streams the rest of the body
+		data, readErr := io.ReadAll(io.LimitReader(resp.Body, maxCapturedBody+1))
+		if readErr != nil {
+			exchange.Error = readErr.Error()
+		}
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		exchange.ResponseBody = truncateBody(data)
+	}
+	supportCapture.addExchange(exchange)
+	return resp, nil
+}
+
+// bufferedResponseWriter keeps a copy of the response body for failed webhook capture
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	if w.body.Len() < maxCapturedBody {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// FailedWebhookCaptureMiddleware records webhook and function requests answered with an error status
+func FailedWebhookCaptureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if c.Request.Method != http.MethodPost || c.Request.Body == nil ||
+			!(strings.HasPrefix(path, "/webhook/") || strings.HasPrefix(path, "/functions/")) {
+			c.Next()
+			return
+		}
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+		writer := &bufferedResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		if status := writer.Status(); status >= 400 {
+			supportCapture.addWebhook(CapturedWebhook{
+				Timestamp: time.Now(),
+				Method:    c.Request.Method,
+				Path:      path,
+				Status:    status,
+				Payload:   truncateBody(payload),
+				Response:  writer.body.String(),
+			})
+		}
+	}
+}
+
+// Patterns masked in support bundles
+var (
+	sensitiveQueryPattern = regexp.MustCompile(`(?i)((?:api_token|api_key|apikey|token|key|secret|password)=)[^&\s"]+`)
+	bearerPattern         = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	secretFieldPattern    = regexp.MustCompile(`(?i)("(?:[a-z_]*_)?(?:api_token|api_key|apikey|token|secret|password|authorization)"\s*:\s*")[^"]*`)
+	urlPasswordPattern    = regexp.MustCompile(`(?i)([a-z][a-z0-9+.-]*://[^:@/\s]*:)[^@/\s]+@`)
+	personalFieldPattern  = regexp.MustCompile(`(?i)("(?:name|first_name|last_name|person_name|full_name|transcript|content|note|call_summary|word)"\s*:\s*")((?:[^"\\]|\\.)*)`)
+	emailPattern          = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	phonePattern          = regexp.MustCompile(`\+\d[\d\s().-]{6,}(\d{2})`)
+)
+
+// supportSanitizer masks secrets and personal data in support bundle contents
+type supportSanitizer struct {
+	secrets *strings.Replacer
+	names   *strings.Replacer // Names found in captured bodies, masked in log lines too
+}
+
+// newSupportSanitizer masks the configured secret values in addition to the generic patterns
+func newSupportSanitizer(config *Config) *supportSanitizer {
+	return &supportSanitizer{secrets: strings.NewReplacer(configSecretPairs(config)...), names: strings.NewReplacer()}
+}
+
+// learnNames collects the person names in captured bodies, so log lines mentioning them are masked
+func (s *supportSanitizer) learnNames(bodies []string) {
+	seen := make(map[string]bool)
+	var pairs []string
+	for _, body := range bodies {
+		for _, match := range personalFieldPattern.FindAllStringSubmatch(body, -1) {
+			field := strings.ToLower(strings.Trim(strings.SplitN(match[1], ":", 2)[0], `" `))
+			if !strings.HasSuffix(field, "name") || len(match[2]) < 3 || seen[match[2]] {
+				continue
+			}
+			seen[match[2]] = true
+			pairs = append(pairs, match[2], "[name]")
+		}
+	}
+	s.names = strings.NewReplacer(pairs...)
+}
+
+// configSecretPairs returns replacer pairs masking the config's secret values, including the
+// per-tenant keys and passwords kept in maps
+func configSecretPairs(config *Config) []string {
+	var pairs []string
+	for _, secret := range secretValues(reflect.ValueOf(config).Elem(), false) {
+		if len(secret) >= 6 {
+			pairs = append(pairs, secret, "[redacted]")
+		}
+	}
+	return pairs
+}
+
+// secretValues collects the strings held in secret fields; a struct's string fields count when
+// their name matches redactedConfigFields, map and slice values when the field holding them does
+func secretValues(value reflect.Value, secret bool) []string {
+	var values []string
+	switch value.Kind() {
+	case reflect.String:
+		if secret {
+			values = append(values, value.String())
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			values = secretValues(value.Elem(), secret)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if !value.Type().Field(i).IsExported() {
+				continue
+			}
+			values = append(values, secretValues(value.Field(i), redactedName(value.Type().Field(i).Name))...)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			values = append(values, secretValues(value.MapIndex(key), secret)...)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			values = append(values, secretValues(value.Index(i), secret)...)
+		}
+	}
+	return values
+}
+
+// redactedName reports whether a field name matches redactedConfigFields
+func redactedName(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range redactedConfigFields {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitize masks secrets, tokens, URL passwords, names, transcripts, email addresses and phone numbers
+func (s *supportSanitizer) Sanitize(text string) string {
+	text = s.secrets.Replace(text)
+	text = sensitiveQueryPattern.ReplaceAllString(text, "${1}[redacted]")
+	text = bearerPattern.ReplaceAllString(text, "${1}[redacted]")
+	text = secretFieldPattern.ReplaceAllString(text, "${1}[redacted]")
+	text = urlPasswordPattern.ReplaceAllString(text, "${1}[redacted]@")
+	text = personalFieldPattern.ReplaceAllString(text, "${1}[redacted]")
+	text = s.names.Replace(text)
+	text = emailPattern.ReplaceAllString(text, "${1}***@${2}")
+	return phonePattern.ReplaceAllString(text, "***${1}")
+}
+
+// BuildSupportBundle zips sanitized logs, failed webhooks, outbound exchanges and the redacted config
+func (p *PipedriveService) BuildSupportBundle() ([]byte, error) {
+	sanitizer := newSupportSanitizer(p.config)
+	logs, exchanges, webhooks := supportCapture.snapshot()
+	var bodies []string
+	for _, exchange := range exchanges {
+		bodies = append(bodies, exchange.RequestBody, exchange.ResponseBody)
+	}
+	for _, webhook := range webhooks {
+		bodies = append(bodies, webhook.Payload)
+	}
+	sanitizer.learnNames(bodies)
+	for i := range logs {
+		logs[i] = sanitizer.Sanitize(logs[i])
+	}
+	for i := range exchanges {
+		exchanges[i].URL = sanitizer.Sanitize(exchanges[i].URL)
+		exchanges[i].Error = sanitizer.Sanitize(exchanges[i].Error)
+		exchanges[i].RequestBody = sanitizer.Sanitize(exchanges[i].RequestBody)
+		exchanges[i].ResponseBody = sanitizer.Sanitize(exchanges[i].ResponseBody)
+	}
+	for i := range webhooks {
+		webhooks[i].Payload = sanitizer.Sanitize(webhooks[i].Payload)
+		webhooks[i].Response = sanitizer.Sanitize(webhooks[i].Response)
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"manifest.json", gin.H{
+			"generated_at":     time.Now().UTC(),
+			"go_version":       runtime.Version(),
+			"app_env":          p.config.AppEnv,
+			"log_lines":        len(logs),
+			"exchanges":        len(exchanges),
+			"failed_webhooks":  len(webhooks),
+			"simulation":       !p.config.HasPipedriveConfig(),
+			"retell_available": p.config.HasRetellConfig(),
+		}},
+		{"logs.txt", strings.Join(logs, "\n") + "\n"},
+		{"failed_webhooks.json", webhooks},
+		{"downstream_requests.json", exchanges},
+		{"config.json", redactConfig(p.config)},
+		{"scaling.json", p.ScalingSignals()},
+	}
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if text, ok := file.content.(string); ok {
+			_, err = writer.Write([]byte(text))
+		} else {
+			encoder := json.NewEncoder(writer)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(file.content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", file.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// SupportBundleHandler downloads a support bundle zip for vendor support tickets
+func SupportBundleHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bundle, err := pipedriveService.BuildSupportBundle()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: "Failed to build support bundle: " + err.Error(),
+			})
+			return
+		}
+		actor := AuditActorSystem
+		if user, ok := c.Get("admin_user"); ok {
+			actor = user.(*AdminUser).Name
+		}
+		pipedriveService.auditLog.Append(AuditEntry{Actor: actor, Action: "support_bundle_downloaded"})
+
+		filename := fmt.Sprintf("pipcal-support-%s.zip", time.Now().UTC().Format("20060102-150405"))
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		c.Data(http.StatusOK, "application/zip", bundle)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TeamRouter hands unowned leads' activities to members of a Pipedrive team in turn
+type TeamRouter struct {
+	mu        sync.Mutex
+	members   []int // Active members, in Pipedrive order
+	fetchedAt time.Time
+	counter   int64 // Assignments made so far; persisted so turns survive restarts
+	path      string
+}
+
+// teamRoutingState is the ROUTING_STATE_FILE contents
+type teamRoutingState struct {
+	TeamID  int   `json:"team_id"`
+	Counter int64 `json:"counter"`
+}
+
+// NewTeamRouter creates a team router, restoring the turn counter from ROUTING_STATE_FILE
+func NewTeamRouter(config *Config) *TeamRouter {
+	router := &TeamRouter{path: config.RoutingStateFile}
+	if router.path == "" || config.RoutingTeamID == 0 {
+		return router
+	}
+	data, err := os.ReadFile(router.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Warning: Cannot read ROUTING_STATE_FILE %s: %v", router.path, err)
+		}
+		return router
+	}
+	var state teamRoutingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("⚠️ Warning: Ignoring unreadable ROUTING_STATE_FILE %s: %v", router.path, err)
+		return router
+	}
+	// A different team starts its rotation from the first member
+	if state.TeamID == config.RoutingTeamID {
+		router.counter = state.Counter
+	}
+	return router
+}
+
+// detached returns a copy of the router at the same turn that is never saved, for dry runs
+func (r *TeamRouter) detached() *TeamRouter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &TeamRouter{members: append([]int(nil), r.members...), fetchedAt: r.fetchedAt, counter: r.counter}
+}
+
+// saveLocked writes the turn counter to ROUTING_STATE_FILE; callers hold mu
+func (r *TeamRouter) saveLocked(teamID int) {
+	if r.path == "" {
+		return
+	}
+	data, err := json.Marshal(teamRoutingState{TeamID: teamID, Counter: r.counter})
+	if err == nil {
+		tmp := r.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, r.path)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to save team routing state: %v", err)
+	}
+}
+
+// teamMembers returns the active members of ROUTING_TEAM_ID, cached like owner statuses
+func (p *PipedriveService) teamMembers() ([]int, error) {
+	teamID := p.config.RoutingTeamID
+	p.teamRouter.mu.Lock()
+	if p.teamRouter.members != nil && time.Since(p.teamRouter.fetchedAt) < ownerStatusTTL {
+		members := p.teamRouter.members
+		p.teamRouter.mu.Unlock()
+		return members, nil
+	}
+	p.teamRouter.mu.Unlock()
+
+	resp, err := p.makePipedriveRequest("GET", fmt.Sprintf("/legacyTeams/%d/users", teamID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch team %d: %v", teamID, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read team response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch team %d: HTTP %d, Response: %s", teamID, resp.StatusCode, string(body))
+	}
+	var result struct {
+		Success bool  `json:"success"`
+		Data    []int `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse team response: %v", err)
+	}
+
+	members := make([]int, 0, len(result.Data))
+	for _, userID := range result.Data {
+		if active, err := p.isUserActive(userID); err != nil || active {
+			members = append(members, userID)
+		}
+	}
+
+	p.teamRouter.mu.Lock()
+	p.teamRouter.members = members
+	p.teamRouter.fetchedAt = time.Now()
+	p.teamRouter.mu.Unlock()
+	return members, nil
+}
+
+// nextTeamMember picks the team member whose turn it is, or 0 when the team is unavailable
+func (p *PipedriveService) nextTeamMember() int {
+	members, err := p.teamMembers()
+	if err != nil {
+		p.logf("⚠️ Warning: Team routing unavailable: %v", err)
+		return 0
+	}
+	if len(members) == 0 {
+		p.logf("⚠️ Warning: Team %d has no active members", p.config.RoutingTeamID)
+		return 0
+	}
+
+	// With Redis every replica shares one rotation; otherwise the local counter is used
+	var turn int64
+	if p.locker != nil {
+		turn, err = p.locker.Incr(fmt.Sprintf("routing:team:%d", p.config.RoutingTeamID))
+		if err != nil {
+			p.logf("⚠️ Warning: Shared team rotation unavailable, using local turn: %v", err)
+		}
+	}
+	if p.locker == nil || err != nil {
+		p.teamRouter.mu.Lock()
+		p.teamRouter.counter++
+		turn = p.teamRouter.counter
+		p.teamRouter.saveLocked(p.config.RoutingTeamID)
+		p.teamRouter.mu.Unlock()
+	}
+	return members[(turn-1)%int64(len(members))]
+}
+
+// TeamRoutingHandler shows the routing team's active members and the rotation position
+func TeamRoutingHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pipedriveService.config.RoutingTeamID == 0 {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Team routing is not configured (ROUTING_TEAM_ID)",
+			})
+			return
+		}
+		members, err := pipedriveService.teamMembers()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		pipedriveService.teamRouter.mu.Lock()
+		counter := pipedriveService.teamRouter.counter
+		pipedriveService.teamRouter.mu.Unlock()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Team %d has %d active members", pipedriveService.config.RoutingTeamID, len(members)),
+			Data: gin.H{
+				"team_id":       pipedriveService.config.RoutingTeamID,
+				"members":       members,
+				"local_counter": counter,
+				"shared":        pipedriveService.locker != nil,
+			},
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTenant is used for calls from agents not mapped to a tenant
+const DefaultTenant = "default"
+
+// ErrTenantMismatch is returned when data is read on behalf of another tenant
+var ErrTenantMismatch = errors.New("record belongs to another tenant")
+
+// TenantFor returns the tenant that owns calls from an agent
+func (c *Config) TenantFor(agentID string) string {
+	if tenant, ok := c.AgentTenants[agentID]; ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// adminOpenKey marks a request let through by ADMIN_AUTH_OPEN
+const adminOpenKey = "admin_open"
+
+// noTenant is the scope of a request without an admin user; it matches no tenant's records
+const noTenant = "\x00none"
+
+// requestTenant returns the tenant of the authenticated admin user, or "" if the user may read all
+// tenants. Only an unscoped admin user, or ADMIN_AUTH_OPEN in development, reads all tenants; a
+// request without a user is scoped to no tenant
+func requestTenant(c *gin.Context) string {
+	if user, ok := c.Get("admin_user"); ok {
+		return user.(*AdminUser).Tenant
+	}
+	if c.GetBool(adminOpenKey) {
+		return ""
+	}
+	return noTenant
+}
+
+// callTenant returns the tenant that owns a call: its mapping's, else the one recorded when it was
+// dialed or of the agent that handled it
+func (p *PipedriveService) callTenant(callID string) string {
+	if mapping, ok := p.getCallMapping(callID); ok && mapping.Tenant != "" {
+		return mapping.Tenant
+	}
+	for _, event := range p.callEvents.Events(callID) {
+		if event.Mapping != nil && event.Mapping.Tenant != "" {
+			return event.Mapping.Tenant
+		}
+		if event.Analysis != nil && event.Analysis.Call.AgentID != "" {
+			return p.config.TenantFor(event.Analysis.Call.AgentID)
+		}
+	}
+	return ""
+}
+
+// callOutOfScope answers 404 when a tenant-scoped user asks for a call of another tenant, or one
+// whose tenant is unknown, reporting whether it did
+func callOutOfScope(c *gin.Context, pipedriveService *PipedriveService, callID string) bool {
+	tenant := requestTenant(c)
+	if tenant == "" || pipedriveService.callTenant(callID) == tenant {
+		return false
+	}
+	c.JSON(http.StatusNotFound, WebhookResponse{
+		Success: false,
+		Message: "Call not found",
+	})
+	return true
+}
+
+// KeyProvider supplies per-tenant data encryption keys
+type KeyProvider interface {
+	TenantKey(tenant string) ([]byte, error)
+}
+
+// envKeyProvider reads base64 AES-256 keys from TENANT_ENCRYPTION_KEYS or TENANT_KEY_<TENANT> variables
+type envKeyProvider struct {
+	keys map[string]string
+}
+
+// newKeyProvider returns the configured key provider, or nil when encryption at rest is not configured
+func newKeyProvider(config *Config) KeyProvider {
+	if len(config.TenantEncryptionKeys) == 0 && !config.TranscriptEncryption {
+		log.Printf("⚠️ No tenant encryption keys configured, stored transcripts are not encrypted at rest")
+		return nil
+	}
+	return &envKeyProvider{keys: config.TenantEncryptionKeys}
+}
+
+// TenantKey returns the 32-byte key for a tenant
+func (e *envKeyProvider) TenantKey(tenant string) ([]byte, error) {
+	encoded, ok := e.keys[tenant]
+	if !ok {
+		encoded = os.Getenv("TENANT_KEY_" + strings.ToUpper(strings.ReplaceAll(tenant, "-", "_")))
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("no encryption key for tenant %s", tenant)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("encryption key for tenant %s must be 32 bytes, base64 encoded", tenant)
+	}
+	return key, nil
+}
+
+// loadTenantKeys parses the TENANT_ENCRYPTION_KEYS JSON (tenant -> base64 key)
+func loadTenantKeys(raw string) map[string]string {
+	keys := make(map[string]string)
+	if raw == "" {
+		return keys
+	}
+	if err := json.Unmarshal([]byte(raw), &keys); err != nil {
+		log.Printf("⚠️ Invalid TENANT_ENCRYPTION_KEYS, ignoring: %v", err)
+		return make(map[string]string)
+	}
+	return keys
+}
+
+// sealForTenant encrypts data with the tenant's key using AES-GCM, binding the tenant as additional data
+func sealForTenant(keys KeyProvider, tenant string, plaintext []byte) ([]byte, error) {
+	gcm, err := tenantCipher(keys, tenant)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, []byte(tenant)), nil
+}
+
+// openForTenant decrypts data sealed with sealForTenant
+func openForTenant(keys KeyProvider, tenant string, sealed []byte) ([]byte, error) {
+	gcm, err := tenantCipher(keys, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(tenant))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt tenant data: %v", err)
+	}
+	return plaintext, nil
+}
+
+// tenantCipher builds the AES-GCM cipher for a tenant
+func tenantCipher(keys KeyProvider, tenant string) (cipher.AEAD, error) {
+	key, err := keys.TenantKey(tenant)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestFixture is an editable sample request offered by the test console
+type TestFixture struct {
+	ID          string      `json:"id"`
+	Group       string      `json:"group"`
+	Name        string      `json:"name"`
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	Description string      `json:"description"`
+	Payload     interface{} `json:"payload"`
+}
+
+// testFixtures returns sample payloads for the webhook and function endpoints, with fresh IDs
+func testFixtures() []TestFixture {
+	now := time.Now()
+	stamp := strconv.FormatInt(now.Unix(), 10)
+	start := now.Add(24 * time.Hour).Truncate(time.Hour)
+
+	return []TestFixture{
+		{
+			ID: "retell-completed", Group: "Retell AI", Name: "Call completed",
+			Method: "POST", Path: "/webhook/retell",
+			Description: "Legacy call event; creates a call activity for the person with this phone number",
+			Payload: gin.H{
+				"event":         "call.completed",
+				"call_id":       "test-call-" + stamp,
+				"contact_phone": "+1234567890",
+				"duration":      "00:02:30",
+				"status":        "completed",
+				"transcript":    "This is a test call transcript for completed call.",
+				"timestamp":     now.Format(time.RFC3339),
+			},
+		},
+		{
+			ID: "retell-hangup", Group: "Retell AI", Name: "Call hung up",
+			Method: "POST", Path: "/webhook/retell",
+			Description: "Legacy call event for a call the person hung up",
+			Payload: gin.H{
+				"event":         "call.hangup",
+				"call_id":       "test-call-" + stamp,
+				"contact_phone": "+1234567890",
+				"duration":      "00:00:12",
+				"status":        "hangup",
+				"timestamp":     now.Format(time.RFC3339),
+			},
+		},
+		{
+			ID: "retell-optout", Group: "Retell AI", Name: "Opt-out",
+			Method: "POST", Path: "/webhook/retell",
+			Description: "Legacy call event for a person asking not to be called again",
+			Payload: gin.H{
+				"event":         "call.optout",
+				"call_id":       "test-call-" + stamp,
+				"contact_phone": "+1234567890",
+				"duration":      "00:00:40",
+				"status":        "optout",
+				"timestamp":     now.Format(time.RFC3339),
+			},
+		},
+		{
+			ID: "retell-analyzed", Group: "Retell AI", Name: "Call analyzed",
+			Method: "POST", Path: "/webhook/retell/analyzed",
+			Description: "Post-call analysis; only calls placed by this server (with a call mapping) update Pipedrive",
+			Payload: gin.H{
+				"event": "call_analyzed",
+				"call": gin.H{
+					"call_id":              "test-call-" + stamp,
+					"agent_id":             "",
+					"call_status":          "ended",
+					"start_timestamp":      now.Add(-3 * time.Minute).UnixMilli(),
+					"end_timestamp":        now.UnixMilli(),
+					"duration_ms":          180000,
+					"transcript":           "Agent: Hi, this is Alex.\nUser: Hi, yes I have a minute.",
+					"disconnection_reason": "user_hangup",
+					"recording_url":        "",
+					"call_analysis": gin.H{
+						"call_summary":         "The person is interested and asked for a demo next week.",
+						"in_voicemail":         false,
+						"user_sentiment":       "Positive",
+						"call_successful":      true,
+						"custom_analysis_data": gin.H{},
+					},
+				},
+			},
+		},
+		{
+			ID: "cal-booking-v1", Group: "Cal.com", Name: "Booking created (v1)",
+			Method: "POST", Path: "/webhook/cal",
+			Description: "Creates or finds the attendee and adds a meeting activity",
+			Payload: gin.H{
+				"triggerEvent": "BOOKING_CREATED",
+				"createdAt":    now.Format(time.RFC3339),
+				"payload": gin.H{
+					"id":        now.Unix() % 1000000,
+					"title":     "Demo with Test Person",
+					"startTime": start.Format(time.RFC3339),
+					"endTime":   start.Add(30 * time.Minute).Format(time.RFC3339),
+					"attendees": []gin.H{{"email": "test.person@gmail.com", "name": "Test Person"}},
+					"location":  "https://meet.google.com/abc-defg-hij",
+				},
+			},
+		},
+		{
+			ID: "cal-booking-v2", Group: "Cal.com", Name: "Booking created (v2)",
+			Method: "POST", Path: "/webhook/cal",
+			Description: "Cal.com v2 payload with bookingId/uid and the meeting link in metadata",
+			Payload: gin.H{
+				"triggerEvent": "BOOKING_CREATED",
+				"createdAt":    now.Format(time.RFC3339),
+				"payload": gin.H{
+					"bookingId": now.Unix() % 1000000,
+					"uid":       "test-booking-" + stamp,
+					"title":     "Demo with Test Person",
+					"start":     start.Format(time.RFC3339),
+					"end":       start.Add(30 * time.Minute).Format(time.RFC3339),
+					"attendees": []gin.H{{"email": "test.person@gmail.com", "name": "Test Person"}},
+					"location":  "integrations:google:meet",
+					"metadata":  gin.H{"videoCallUrl": "https://meet.google.com/abc-defg-hij"},
+				},
+			},
+		},
+		{
+			ID: "pipedrive-lead", Group: "Pipedrive", Name: "Lead created",
+			Method: "POST", Path: "/webhook/pipedrive/lead",
+			Description: "Places a real AI call to the lead's person; set person_id to a test person you own",
+			Payload: gin.H{
+				"data": gin.H{
+					"id":          "test-lead-" + stamp,
+					"title":       "Test Lead - " + stamp,
+					"person_id":   0,
+					"owner_id":    0,
+					"add_time":    now.Format(time.RFC3339),
+					"update_time": now.Format(time.RFC3339),
+					"origin":      "ManuallyCreated",
+					"source_name": "Test Lead",
+					"label_ids":   []string{},
+				},
+				"meta": gin.H{
+					"action":    "create",
+					"entity":    "lead",
+					"entity_id": "test-lead-" + stamp,
+					"timestamp": now.Format(time.RFC3339),
+					"version":   "2.0",
+					"attempt":   1,
+				},
+			},
+		},
+		{
+			ID: "lead-capture", Group: "Leads", Name: "Website form",
+			Method: "POST", Path: "/api/leads/capture",
+			Description: "Landing page submission; creates the person and lead",
+			Payload: gin.H{
+				"name":         "Test Person",
+				"email":        "test.person@gmail.com",
+				"phone":        "+1234567890",
+				"company":      "Test Company",
+				"message":      "I'd like a demo",
+				"page":         "/pricing",
+				"utm_source":   "google",
+				"utm_campaign": "test",
+			},
+		},
+		{
+			ID: "function-check-availability", Group: "Agent functions", Name: "Check availability",
+			Method: "POST", Path: "/functions/check-availability",
+			Description: "Custom function the agent calls to offer meeting slots",
+			Payload: gin.H{
+				"name": "check_availability",
+				"call": gin.H{"call_id": "test-call-" + stamp, "to_number": "+1234567890"},
+				"args": gin.H{"days": 3},
+			},
+		},
+		{
+			ID: "function-lookup-contact", Group: "Agent functions", Name: "Look up contact",
+			Method: "POST", Path: "/functions/lookup-contact",
+			Description: "Custom function the agent calls to look up the person on the call",
+			Payload: gin.H{
+				"name": "lookup_contact",
+				"call": gin.H{"call_id": "test-call-" + stamp, "to_number": "+1234567890"},
+				"args": gin.H{"phone": "+1234567890"},
+			},
+		},
+	}
+}
+
+// testConsoleEnabled reports whether the test console may be served
+func (c *Config) testConsoleEnabled() bool {
+	return c.TestConsoleEnabled && !c.IsProduction()
+}
+
+// RequireTestConsole hides test console routes unless TEST_CONSOLE_ENABLED is set outside production
+func RequireTestConsole(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !pipedriveService.config.testConsoleEnabled() {
+			c.AbortWithStatusJSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Test console is disabled",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// TestFixturesHandler returns the test console's sample requests
+func TestFixturesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, WebhookResponse{
+		Success: true,
+		Message: "Test fixtures",
+		Data:    testFixtures(),
+	})
+}
+
+// TestConsoleHandler renders the webhook test console, or a plain status when it is disabled
+func TestConsoleHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !pipedriveService.config.testConsoleEnabled() {
+			c.JSON(http.StatusOK, WebhookResponse{
+				Success: true,
+				Message: "PipCal Webhook Server is running",
+			})
+			return
+		}
+		c.HTML(http.StatusOK, "index.html", gin.H{
+			"title":        "PipCal Webhook Server",
+			"fixtures":     testFixtures(),
+			"pipedriveURL": pipedriveService.config.PipedriveWebURL,
+			"simulation":   !pipedriveService.config.HasPipedriveConfig(),
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts tried for timestamps that carry their own zone or offset
+var zonedTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -0700 MST",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// Layouts tried for naive timestamps, read in NAIVE_TIMESTAMP_ZONE
+var naiveTimestampLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Timestamp is an instant stored in UTC together with the zone it was received in
+type Timestamp struct {
+	UTC    time.Time `json:"utc"`
+	Zone   string    `json:"zone,omitempty"` // e.g. Europe/Madrid, +02:00 or UTC; empty for unix times
+	Offset int       `json:"offset"`         // Seconds east of UTC the original time was given at
+}
+
+// NewTimestamp normalizes a time, keeping its location as the original zone; times in the
+// server's local zone or an unnamed fixed zone keep their offset instead
+func NewTimestamp(t time.Time) Timestamp {
+	_, offset := t.Zone()
+	zone := t.Location().String()
+	if t.Location() == time.Local || zone == "" {
+		zone = formatUTCOffset(offset)
+	}
+	return Timestamp{UTC: t.UTC(), Zone: zone, Offset: offset}
+}
+
+// TimestampFromMillis converts unix milliseconds, which carry no zone
+func TimestampFromMillis(ms int64) Timestamp {
+	return Timestamp{UTC: time.UnixMilli(ms).UTC()}
+}
+
+// ParseTimestamp reads RFC 3339 and similar zoned strings, unix seconds or milliseconds, and
+// naive strings, which are read in naive (UTC when nil)
+func ParseTimestamp(raw string, naive *time.Location) (Timestamp, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Timestamp{}, fmt.Errorf("empty timestamp")
+	}
+	if naive == nil {
+		naive = time.UTC
+	}
+
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		// Seconds until the year 5138; anything larger is milliseconds
+		if unix < 1e11 {
+			return Timestamp{UTC: time.Unix(unix, 0).UTC()}, nil
+		}
+		return TimestampFromMillis(unix), nil
+	}
+	for _, layout := range zonedTimestampLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			_, offset := t.Zone()
+			return Timestamp{UTC: t.UTC(), Zone: formatUTCOffset(offset), Offset: offset}, nil
+		}
+	}
+	for _, layout := range naiveTimestampLayouts {
+		if t, err := time.ParseInLocation(layout, raw, naive); err == nil {
+			return NewTimestamp(t), nil
+		}
+	}
+	return Timestamp{}, fmt.Errorf("unrecognized timestamp %q", raw)
+}
+
+// parsePipedriveTime reads Pipedrive's naive "2006-01-02 15:04:05" times, which are in UTC
+func parsePipedriveTime(raw string) (time.Time, error) {
+	ts, err := ParseTimestamp(raw, time.UTC)
+	return ts.UTC, err
+}
+
+// naiveLocation returns the NAIVE_TIMESTAMP_ZONE location naive timestamps are read in
+func (c *Config) naiveLocation() *time.Location {
+	location, err := time.LoadLocation(c.NaiveTimestampZone)
+	if err != nil {
+		log.Printf("⚠️ Invalid NAIVE_TIMESTAMP_ZONE %q, using UTC: %v", c.NaiveTimestampZone, err)
+		return time.UTC
+	}
+	return location
+}
+
+// IsZero reports whether the timestamp is unset
+func (t Timestamp) IsZero() bool {
+	return t.UTC.IsZero()
+}
+
+// Original returns the instant in the zone it was received in
+func (t Timestamp) Original() time.Time {
+	if t.Zone == "" || t.Zone == "UTC" {
+		return t.UTC
+	}
+	if !strings.HasPrefix(t.Zone, "+") && !strings.HasPrefix(t.Zone, "-") {
+		if location, err := time.LoadLocation(t.Zone); err == nil {
+			return t.UTC.In(location)
+		}
+	}
+	return t.UTC.In(time.FixedZone(t.Zone, t.Offset))
+}
+
+// String formats the timestamp in RFC 3339 at its original offset
+func (t Timestamp) String() string {
+	return t.Original().Format(time.RFC3339)
+}
+
+// formatUTCOffset formats seconds east of UTC as UTC or +hh:mm
+func formatUTCOffset(offset int) string {
+	if offset == 0 {
+		return "UTC"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// noteTime formats an instant for activity notes: UTC, followed by the original local time when
+// it was received in another zone, e.g. "2026-03-02 14:30 UTC (15:30 +01:00)"
+func noteTime(t Timestamp) string {
+	text := t.UTC.Format("2006-01-02 15:04") + " UTC"
+	if t.Offset != 0 {
+		original := t.Original()
+		text += fmt.Sprintf(" (%s %s)", original.Format("15:04"), t.Zone)
+	}
+	return text
+}
+
+// pipedriveDueDate formats the due_date of a Pipedrive activity, which Pipedrive reads as UTC
+func pipedriveDueDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// pipedriveDueTime formats the due_time of a Pipedrive activity, which Pipedrive reads as UTC
+func pipedriveDueTime(t time.Time) string {
+	return t.UTC().Format("15:04:05")
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StoredTranscript is a full call transcript kept outside Pipedrive
+type StoredTranscript struct {
+	CallID   string    `json:"call_id"`
+	Tenant   string    `json:"tenant"`
+	PersonID int       `json:"person_id"`
+	Text     string    `json:"text"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// storedTranscriptRecord is the at-rest form of a transcript; Sealed holds the encrypted text
+type storedTranscriptRecord struct {
+	CallID   string
+	Tenant   string
+	Region   string
+	PersonID int
+	Sealed   []byte
+	StoredAt time.Time
+}
+
+// ErrNoTranscriptKeys is returned when a transcript would have to be stored unencrypted
+var ErrNoTranscriptKeys = errors.New("transcripts are only stored encrypted, set TENANT_ENCRYPTION_KEYS or TRANSCRIPT_ENCRYPTION")
+
+// TranscriptStore keeps full transcripts that are too long for Pipedrive notes, encrypted per tenant
+type TranscriptStore struct {
+	mu          sync.RWMutex
+	transcripts map[string]storedTranscriptRecord // Maps region/callID to transcript
+	keys        KeyProvider                       // nil refuses to store transcripts
+	regions     RegionPolicy                      // Tenants whose transcripts may be stored here
+}
+
+// NewTranscriptStore creates a new transcript store
+func NewTranscriptStore(keys KeyProvider, regions RegionPolicy) *TranscriptStore {
+	return &TranscriptStore{transcripts: make(map[string]storedTranscriptRecord), keys: keys, regions: regions}
+}
+
+// partitionKey returns the storage key of a call in its tenant's region partition
+func (s *TranscriptStore) partitionKey(tenant, callID string) string {
+	return s.regions.RegionOf(tenant) + "/" + callID
+}
+
+// Save encrypts and stores a transcript for a tenant's call
+func (s *TranscriptStore) Save(tenant, callID string, personID int, text string) error {
+	if !s.regions.Allows(tenant) {
+		return ErrWrongRegion
+	}
+	if s.keys == nil {
+		return ErrNoTranscriptKeys
+	}
+	sealed, err := sealForTenant(s.keys, tenant, []byte(text))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcripts[s.partitionKey(tenant, callID)] = storedTranscriptRecord{
+		CallID:   callID,
+		Tenant:   tenant,
+		Region:   s.regions.RegionOf(tenant),
+		PersonID: personID,
+		Sealed:   sealed,
+		StoredAt: time.Now(),
+	}
+	return nil
+}
+
+// Get returns the stored transcript for a call; tenant "" may read any tenant's transcript
+func (s *TranscriptStore) Get(tenant, callID string) (StoredTranscript, bool, error) {
+	// Only this deployment's region partition is readable
+	s.mu.RLock()
+	record, ok := s.transcripts[s.regions.Region+"/"+callID]
+	s.mu.RUnlock()
+	if !ok {
+		return StoredTranscript{}, false, nil
+	}
+	if tenant != "" && record.Tenant != tenant {
+		return StoredTranscript{}, false, ErrTenantMismatch
+	}
+
+	text, err := openForTenant(s.keys, record.Tenant, record.Sealed)
+	if err != nil {
+		return StoredTranscript{}, false, err
+	}
+	return StoredTranscript{
+		CallID:   record.CallID,
+		Tenant:   record.Tenant,
+		PersonID: record.PersonID,
+		Text:     string(text),
+		StoredAt: record.StoredAt,
+	}, true, nil
+}
+
+// Purge deletes transcripts stored before cutoff and returns them without their text
+func (s *TranscriptStore) Purge(cutoff time.Time) []StoredTranscript {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged []StoredTranscript
+	for key, record := range s.transcripts {
+		if record.StoredAt.Before(cutoff) {
+			delete(s.transcripts, key)
+			purged = append(purged, StoredTranscript{CallID: record.CallID, Tenant: record.Tenant, PersonID: record.PersonID, StoredAt: record.StoredAt})
+		}
+	}
+	return purged
+}
+
+// chunkTranscript splits a transcript into chunks of at most maxChars, breaking on line boundaries
+func chunkTranscript(text string, maxChars int) []string {
+	if maxChars <= 0 || len(text) <= maxChars {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		for len(line) > maxChars {
+			if current.Len() > 0 {
+				chunks = append(chunks, current.String())
+				current.Reset()
+			}
+			// Cut on a character boundary; a single character wider than maxChars stays whole
+			part := truncateRunes(line, maxChars)
+			if part == "" {
+				_, size := utf8.DecodeRuneInString(line)
+				part = line[:size]
+			}
+			chunks = append(chunks, part)
+			line = line[len(part):]
+		}
+		if current.Len() > 0 && current.Len()+len(line)+1 > maxChars {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n")
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// summarizeTranscript builds an executive summary of a long transcript, falling back to Retell's call summary
+func (p *PipedriveService) summarizeTranscript(transcript, callSummary string) string {
+	if p.llm == nil {
+		return callSummary
+	}
+
+	chunks := chunkTranscript(transcript, p.config.TranscriptChunkSize)
+	var partials []string
+	for i, chunk := range chunks {
+		partial, err := p.llm.Complete(
+			"You summarize sales call transcripts. Reply with 3-5 concise bullet points covering needs, objections, and commitments.",
+			chunk)
+		if err != nil {
+			p.logf("⚠️ Warning: Failed to summarize transcript chunk %d/%d: %v", i+1, len(chunks), err)
+			return callSummary
+		}
+		partials = append(partials, partial)
+	}
+
+	if len(partials) == 1 {
+		return partials[0]
+	}
+
+	summary, err := p.llm.Complete(
+		"You write executive summaries of sales calls. Combine the partial summaries into one summary of at most 8 bullet points, ending with agreed next steps.",
+		strings.Join(partials, "\n\n"))
+	if err != nil {
+		p.logf("⚠️ Warning: Failed to combine transcript summaries: %v", err)
+		return strings.Join(partials, "\n")
+	}
+	return summary
+}
+
+// transcriptNoteSection returns the transcript part of the analysis note, storing long transcripts outside Pipedrive
+func (p *PipedriveService) transcriptNoteSection(tenant, callID string, personID int, transcript, callSummary string) string {
+	if transcript == "" {
+		return ""
+	}
+	if len(transcript) <= p.config.TranscriptNoteLimit {
+		return "\n\nFull Transcript:\n" + transcript
+	}
+
+	summary := p.summarizeTranscript(transcript, callSummary)
+	if err := p.transcripts.Save(tenant, callID, personID, transcript); err != nil {
+		p.logf("❌ Failed to store transcript for call %s (tenant %s): %v", callID, tenant, err)
+		return fmt.Sprintf("\n\nExecutive Summary (transcript too long for this note):\n%s", summary)
+	}
+	p.logf("📚 Transcript for call %s is %d chars, storing full text and summarizing", callID, len(transcript))
+
+	return fmt.Sprintf("\n\nExecutive Summary (transcript too long for this note):\n%s\n\nFull Transcript: %s/api/transcripts/%s",
+		summary, p.config.PublicBaseURL, callID)
+}
+
+// TranscriptHandler serves a stored full transcript as plain text
+func TranscriptHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transcript, ok, err := pipedriveService.transcripts.Get(requestTenant(c), c.Param("call_id"))
+		if err == ErrTenantMismatch {
+			// Don't reveal that another tenant's transcript exists
+			ok = false
+		} else if err != nil {
+			log.Printf("❌ Failed to read transcript %s: %v", c.Param("call_id"), err)
+			c.JSON(http.StatusInternalServerError, WebhookResponse{
+				Success: false,
+				Message: "Failed to read transcript",
+			})
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Transcript not found",
+			})
+			return
+		}
+		c.String(http.StatusOK, transcript.Text)
+	}
+}
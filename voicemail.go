@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// voicemailDropMatchWords is how many words of the script must appear in the agent's turns for
+// the drop to count as left
+const voicemailDropMatchWords = 6
+
+// voicemailDropEnabled returns true if a voicemail drop script is configured; the agent leaves
+// the script as static text, so an audio URL alone is never played
+func (c *Config) voicemailDropEnabled() bool {
+	return c.VoicemailDropMessage != ""
+}
+
+// voicemailVariables returns the per-call dynamic variables the agent uses for its voicemail drop
+func (p *PipedriveService) voicemailVariables(personName, leadTitle string) map[string]interface{} {
+	variables := make(map[string]interface{})
+	if p.config.VoicemailDropMessage != "" {
+		variables["voicemail_message"] = renderTemplate(p.config.VoicemailDropMessage, map[string]string{
+			"name": personName,
+			"lead": leadTitle,
+		})
+	}
+	if p.config.VoicemailDropAudioURL != "" {
+		variables["voicemail_audio_url"] = p.config.VoicemailDropAudioURL
+	}
+	return variables
+}
+
+// UpdateRetellAgentVoicemail turns on voicemail detection and leaves the per-call voicemail message
+func (p *PipedriveService) UpdateRetellAgentVoicemail(agentID string) error {
+	_, err := p.makeRetellRequest("PATCH", "/update-agent/"+url.PathEscape(agentID), map[string]interface{}{
+		"enable_voicemail_detection": true,
+		"voicemail_option": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type": "static_text",
+				"text": "{{voicemail_message}}",
+			},
+		},
+	})
+	return err
+}
+
+// isVoicemailDrop returns true if the analyzed call reached voicemail and the agent's turns show
+// the drop script was actually spoken
+func (p *PipedriveService) isVoicemailDrop(payload RetellCallAnalyzedPayload, personName, leadTitle string) bool {
+	if !p.config.voicemailDropEnabled() || !payload.Call.CallAnalysis.InVoicemail {
+		return false
+	}
+	message, _ := p.voicemailVariables(personName, leadTitle)["voicemail_message"].(string)
+	words := voicemailWords(message)
+	if len(words) > voicemailDropMatchWords {
+		words = words[:voicemailDropMatchWords]
+	}
+	if len(words) == 0 {
+		return false
+	}
+
+	var spoken []string
+	for _, utterance := range payload.Call.TranscriptObject {
+		if utterance.Role == "agent" {
+			spoken = append(spoken, utterance.Content)
+		}
+	}
+	if len(payload.Call.TranscriptObject) == 0 {
+		spoken = append(spoken, payload.Call.Transcript)
+	}
+	agent := " " + strings.Join(voicemailWords(strings.Join(spoken, " ")), " ") + " "
+	return strings.Contains(agent, " "+strings.Join(words, " ")+" ")
+}
+
+// voicemailWords lowercases text and splits it into words without punctuation
+func voicemailWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// voicemailNoteSection describes the voicemail drop for the call activity note
+func (p *PipedriveService) voicemailNoteSection(personName, leadTitle string) string {
+	section := "\n\nVoicemail drop left (no conversation)"
+	if message, ok := p.voicemailVariables(personName, leadTitle)["voicemail_message"]; ok {
+		section += fmt.Sprintf("\nMessage: %s", message)
+	}
+	return section
+}
+
+// VoicemailDropSetupHandler configures voicemail detection and the voicemail drop on a Retell agent
+func VoicemailDropSetupHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			AgentID string `json:"agent_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Missing required field: agent_id",
+			})
+			return
+		}
+		if !pipedriveService.config.voicemailDropEnabled() {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "Voicemail drop not configured (VOICEMAIL_DROP_MESSAGE)",
+			})
+			return
+		}
+
+		if err := pipedriveService.UpdateRetellAgentVoicemail(request.AgentID); err != nil {
+			c.JSON(http.StatusBadGateway, WebhookResponse{
+				Success: false,
+				Message: "Failed to update agent: " + err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Voicemail drop configured",
+			Data:    gin.H{"agent_id": request.AgentID},
+		})
+	}
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reprocess modes
+const (
+	ReprocessDryRun = "dry-run" // Pipedrive/Retell writes are answered locally and nothing is sent
+	ReprocessLive   = "live"    // Writes go through and are added to the audit log
+)
+
+// ArchivedWebhook is an inbound webhook as received, with the audit range of its original processing
+type ArchivedWebhook struct {
+	ID           string          `json:"id"`
+	ReceivedAt   time.Time       `json:"received_at"`
+	Path         string          `json:"path"`
+	Query        string          `json:"query,omitempty"`
+	Tenant       string          `json:"tenant,omitempty"` // Whose key seals the payload in WEBHOOK_ARCHIVE_FILE
+	Payload      json.RawMessage `json:"payload,omitempty"`
+	Status       int             `json:"status"`
+	Verified     bool            `json:"verified,omitempty"`      // Signature or credentials were checked
+	ProcessingID string          `json:"processing_id,omitempty"` // Background job when processed asynchronously
+	AuditAfter   int64           `json:"audit_after"`             // Last audit seq before the webhook arrived
+	AuditThrough int64           `json:"audit_through"`           // Last audit seq when the response was sent
+}
+
+// archivedWebhookLine is an archived webhook as written to WEBHOOK_ARCHIVE_FILE, with its payload
+// sealed with the tenant's key
+type archivedWebhookLine struct {
+	ArchivedWebhook
+	Sealed []byte `json:"sealed,omitempty"`
+}
+
+// WebhookArchive keeps recent inbound webhooks, optionally persisted as JSON lines
+type WebhookArchive struct {
+	mu       sync.Mutex
+	limit    int
+	webhooks []ArchivedWebhook
+	file     *os.File    // nil when webhooks are kept in memory only
+	path     string      // WEBHOOK_ARCHIVE_FILE
+	lines    int         // Lines in the file, compacted once it holds twice the limit
+	keys     KeyProvider // Seals payloads written to the file; nil keeps them in memory only
+}
+
+// NewWebhookArchive creates an archive of WEBHOOK_ARCHIVE_SIZE webhooks, replaying WEBHOOK_ARCHIVE_FILE
+func NewWebhookArchive(config *Config, keys KeyProvider) *WebhookArchive {
+	a := &WebhookArchive{limit: config.WebhookArchiveSize, path: config.WebhookArchiveFile, keys: keys}
+	if a.path == "" || a.limit <= 0 {
+		return a
+	}
+	if keys == nil {
+		log.Printf("⚠️ No tenant encryption keys configured, archived webhook payloads are not written to WEBHOOK_ARCHIVE_FILE")
+	}
+
+	if existing, err := os.Open(a.path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+		for scanner.Scan() {
+			a.lines++
+			webhook, err := a.decode(scanner.Bytes())
+			if err != nil {
+				log.Printf("⚠️ Warning: Skipping unreadable archived webhook in %s: %v", a.path, err)
+				continue
+			}
+			a.webhooks = append(a.webhooks, webhook)
+		}
+		existing.Close()
+		a.trimLocked()
+		log.Printf("🗄️ Loaded %d archived webhooks from %s", len(a.webhooks), a.path)
+	}
+
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("⚠️ Warning: Cannot open WEBHOOK_ARCHIVE_FILE %s, keeping the archive in memory: %v", a.path, err)
+		return a
+	}
+	a.file = file
+	if a.lines > 2*a.limit {
+		a.rewriteLocked()
+	}
+	return a
+}
+
+// encode renders a webhook as a WEBHOOK_ARCHIVE_FILE line, sealing its payload; without keys the
+// payload is left out of the file
+func (a *WebhookArchive) encode(webhook ArchivedWebhook) ([]byte, error) {
+	line := archivedWebhookLine{ArchivedWebhook: webhook}
+	line.Payload = nil
+	if a.keys != nil && len(webhook.Payload) > 0 {
+		sealed, err := sealForTenant(a.keys, webhook.Tenant, webhook.Payload)
+		if err != nil {
+			return nil, err
+		}
+		line.Sealed = sealed
+	}
+	return json.Marshal(line)
+}
+
+// decode reads a WEBHOOK_ARCHIVE_FILE line, opening its payload
+func (a *WebhookArchive) decode(data []byte) (ArchivedWebhook, error) {
+	var line archivedWebhookLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return ArchivedWebhook{}, err
+	}
+	webhook := line.ArchivedWebhook
+	if len(line.Sealed) > 0 && a.keys != nil {
+		payload, err := openForTenant(a.keys, webhook.Tenant, line.Sealed)
+		if err != nil {
+			log.Printf("⚠️ Warning: Cannot open the payload of archived webhook %s: %v", webhook.ID, err)
+			return webhook, nil
+		}
+		webhook.Payload = payload
+	}
+	return webhook, nil
+}
+
+// rewriteLocked replaces WEBHOOK_ARCHIVE_FILE with the webhooks still kept; callers hold mu
+func (a *WebhookArchive) rewriteLocked() {
+	tmp := a.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to compact WEBHOOK_ARCHIVE_FILE: %v", err)
+		return
+	}
+	writer := bufio.NewWriter(file)
+	for _, webhook := range a.webhooks {
+		line, err := a.encode(webhook)
+		if err != nil {
+			log.Printf("⚠️ Warning: Leaving archived webhook %s out of the file: %v", webhook.ID, err)
+			continue
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err = writer.Flush(); err == nil {
+		err = file.Close()
+	} else {
+		file.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp, a.path)
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to compact WEBHOOK_ARCHIVE_FILE: %v", err)
+		return
+	}
+	reopened, err := os.OpenFile(a.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("⚠️ Warning: Cannot reopen WEBHOOK_ARCHIVE_FILE %s, keeping the archive in memory: %v", a.path, err)
+		a.file.Close()
+		a.file = nil
+		return
+	}
+	a.file.Close()
+	a.file = reopened
+	a.lines = len(a.webhooks)
+}
+
+// Purge drops webhooks received before cutoff, from memory and the file, and returns how many
+func (a *WebhookArchive) Purge(cutoff time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	kept := a.webhooks[:0]
+	for _, webhook := range a.webhooks {
+		if webhook.ReceivedAt.After(cutoff) {
+			kept = append(kept, webhook)
+		}
+	}
+	purged := len(a.webhooks) - len(kept)
+	a.webhooks = kept
+	if purged > 0 && a.file != nil {
+		a.rewriteLocked()
+	}
+	return purged
+}
+
+// trimLocked drops the oldest webhooks beyond the limit; callers must hold mu
+func (a *WebhookArchive) trimLocked() {
+	if len(a.webhooks) > a.limit {
+		a.webhooks = append([]ArchivedWebhook(nil), a.webhooks[len(a.webhooks)-a.limit:]...)
+	}
+}
+
+// Add archives a webhook
+func (a *WebhookArchive) Add(webhook ArchivedWebhook) {
+	if a.limit <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.webhooks = append(a.webhooks, webhook)
+	a.trimLocked()
+	if a.file != nil {
+		line, err := a.encode(webhook)
+		if err == nil {
+			_, err = a.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			log.Printf("⚠️ Warning: Failed to persist archived webhook %s: %v", webhook.ID, err)
+			return
+		}
+		if a.lines++; a.lines > 2*a.limit {
+			a.rewriteLocked()
+		}
+	}
+}
+
+// Get returns an archived webhook by ID
+func (a *WebhookArchive) Get(id string) (ArchivedWebhook, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, webhook := range a.webhooks {
+		if webhook.ID == id {
+			return webhook, true
+		}
+	}
+	return ArchivedWebhook{}, false
+}
+
+// List returns the archived webhooks, newest first
+func (a *WebhookArchive) List() []ArchivedWebhook {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]ArchivedWebhook, 0, len(a.webhooks))
+	for i := len(a.webhooks) - 1; i >= 0; i-- {
+		list = append(list, a.webhooks[i])
+	}
+	return list
+}
+
+// reprocessHandlers are the webhook routes an archived payload can be run through again
+var reprocessHandlers = map[string]func(*PipedriveService) gin.HandlerFunc{
+	"/webhook/retell":             RetellWebhookHandler,
+	"/webhook/cal":                CalWebhookHandler,
+	"/webhook/retell/analyzed":    RetellCallAnalyzedHandler,
+	"/webhook/pipedrive/lead":     PipedriveLeadWebhookHandler,
+	"/webhook/pipedrive/deal":     PipedriveDealWebhookHandler,
+	"/webhook/pipedrive/activity": PipedriveActivityWebhookHandler,
+	"/webhook/pipedrive/person":   PipedrivePersonWebhookHandler,
+	"/webhook/email":              EmailWebhookHandler,
+	"/webhook/facebook":           FacebookLeadsWebhookHandler,
+	"/webhook/stripe":             StripeWebhookHandler,
+}
+
+// WebhookArchiveMiddleware archives JSON webhooks with the audit range of their processing
+func WebhookArchiveMiddleware(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if _, ok := reprocessHandlers[path]; !ok || c.Request.Method != http.MethodPost || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		payload, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(payload))
+		if !json.Valid(payload) {
+			c.Next()
+			return
+		}
+
+		webhook := ArchivedWebhook{
+			ID:         newProcessingID(),
+			ReceivedAt: time.Now(),
+			Path:       path,
+			Query:      c.Request.URL.RawQuery,
+			Payload:    payload,
+			AuditAfter: pipedriveService.auditLog.LastSeq(),
+		}
+		writer := &bufferedResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Header("X-Webhook-Archive-ID", webhook.ID)
+
+		c.Next()
+
+		webhook.Status = writer.Status()
+		webhook.Verified = webhookVerified(c)
+		webhook.Tenant = pipedriveService.archiveTenant(c, payload)
+		webhook.AuditThrough = pipedriveService.auditLog.LastSeq()
+		if webhook.Status == http.StatusAccepted {
+			var response struct {
+				Data struct {
+					ProcessingID string `json:"processing_id"`
+				} `json:"data"`
+			}
+			json.Unmarshal(writer.body.Bytes(), &response)
+			webhook.ProcessingID = response.Data.ProcessingID
+		}
+		pipedriveService.webhooks.Add(webhook)
+	}
+}
+
+// archiveTenant returns the tenant a webhook belongs to: the one its Pipedrive credentials were
+// verified for, or the owner of the Retell agent it names
+func (p *PipedriveService) archiveTenant(c *gin.Context, payload []byte) string {
+	if tenant := webhookTenant(c); tenant != "" {
+		return tenant
+	}
+	var retell struct {
+		Call struct {
+			AgentID string `json:"agent_id"`
+		} `json:"call"`
+	}
+	if json.Unmarshal(payload, &retell) == nil && retell.Call.AgentID != "" {
+		return p.config.TenantFor(retell.Call.AgentID)
+	}
+	return DefaultTenant
+}
+
+// ReprocessRequest is an outbound request made while reprocessing a webhook
+type ReprocessRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Body   string `json:"body,omitempty"`
+}
+
+// reprocessTransport records outbound requests; in dry-run mode only reads reach the network and
+// writes are answered with an empty success so processing continues as if they had been made
+type reprocessTransport struct {
+	next   http.RoundTripper
+	dryRun bool
+
+	mu       sync.Mutex
+	requests []ReprocessRequest
+}
+
+func (t *reprocessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded := ReprocessRequest{Method: req.Method, URL: sensitiveQueryPattern.ReplaceAllString(req.URL.String(), "${1}[redacted]")}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxCapturedBody+1))
+			body.Close()
+			recorded.Body = truncateBody(data)
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	if t.dryRun && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		resp = &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"success":true,"data":{"id":0}}`)),
+			Request:    req,
+		}
+	} else if resp, err = t.next.RoundTrip(req); err != nil {
+		return nil, err
+	}
+	recorded.Status = resp.StatusCode
+
+	t.mu.Lock()
+	t.requests = append(t.requests, recorded)
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// Requests returns the recorded requests
+func (t *reprocessTransport) Requests() []ReprocessRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]ReprocessRequest{}, t.requests...)
+}
+
+// reprocessReplica returns a copy of the service that processes synchronously through transport.
+// Its actions go to a separate audit log, and in dry-run mode the trackers and schedulers it
+// writes to are fresh so nothing it does is visible to the running service.
+func (p *PipedriveService) reprocessReplica(transport *reprocessTransport) *PipedriveService {
+	config := *p.config
+	config.AsyncWebhookProcessing = false
+	client := &http.Client{Timeout: p.httpClient.Timeout, Transport: transport}
+
+	replica := *p
+	replica.config = &config
+	replica.httpClient = client
+	replica.callMappings = newMemoryCallMappingStore(p.listCallMappings(), 0)
+	replica.auditLog = NewAuditLog("", config.AuditMaxEntries)
+	replica.coalescer = nil
+	replica.locker = nil
+	replica.leases = nil
+	replica.conditional = nil
+	replica.retries = nil // Replayed writes report their failures instead of being retried
+	replica.whatsApp = newWhatsAppProvider(&config, client)
+	replica.stripe = newStripeClient(&config, client)
+	replica.facebook = newFacebookLeadsClient(&config, client)
+	replica.cal = newCalClient(&config, client)
+	replica.calendar = newGoogleCalendarClient(&config, client)
+	replica.sms = newSMSProvider(&config, client)
+	replica.phoneValidator = newPhoneValidator(&config, client)
+	replica.llm = newLLMClient(&config, client)
+	replica.exporters = newTranscriptExporters(&config, client)
+	replica.deals = newDealService(&config, &replica)
+	if !transport.dryRun {
+		return &replica
+	}
+
+	config.MemoryFile = ""
+	config.CallEventsFile = ""
+	config.ShortLinksFile = ""
+	config.ScheduleFile = ""
+	config.EmailDedupFile = ""
+	config.WebhookArchiveFile = ""
+	config.PersonHoldsFile = ""
+	config.ExperimentsFile = ""
+	replica.mailer = nil
+	replica.exporters = nil
+	replica.schedules = NewJobScheduler(&config)
+	replica.handleScheduledJobs()
+	replica.reminders = NewReminderScheduler(replica.schedules)
+	replica.nurtures = NewNurtureScheduler(replica.schedules)
+	replica.meetings = NewMeetingTracker(replica.schedules)
+	replica.intake = NewIntakeTracker()
+	replica.attributions = NewAttributionStore()
+	replica.callWindows = NewCallWindowTracker(&config)
+	replica.callEvents = NewCallEventStore("", nil, replica.config.TenantFor)
+	replica.kpis = NewKPITracker(&config)
+	replica.experiments = NewExperimentTracker(&config)
+	replica.dialRules = NewDialRuleTracker()
+	replica.callQueue = NewCallQueue()
+	replica.lostMarks = NewLostMarkStore("")
+	replica.memory = NewMemoryStore(&config, nil)
+	replica.personChanges = NewPersonChangeTracker(&config)
+	replica.shortLinks = NewShortLinkStore(&config)
+	replica.teamRouter = p.teamRouter.detached()
+	replica.features = NewPipedriveFeatureGate(&config)
+	replica.emailDedup = NewEmailDedupStore(&config)
+	replica.owners = NewOwnerResolver()
+	replica.webhookGaps = NewWebhookGapTracker()
+	replica.retellFailover = NewRetellFailover(&config)
+	replica.invites = NewMeetingInviteStore("")
+	replica.personProxy = NewPersonProxyCache()
+	replica.webhooks = NewWebhookArchive(&config, nil)
+	return &replica
+}
+
+// auditActionKey identifies an action independent of when it happened, for diffing
+func auditActionKey(entry AuditEntry) string {
+	return fmt.Sprintf("%s person=%d call=%s %s", entry.Action, entry.PersonID, entry.CallID, entry.Detail)
+}
+
+// originalActions returns the audit entries written while the archived webhook was processed.
+// Entries for other persons or calls, written by webhooks processed at the same time, are left out.
+func (p *PipedriveService) originalActions(webhook ArchivedWebhook, replayed []AuditEntry) []AuditEntry {
+	query := AuditQuery{AfterSeq: webhook.AuditAfter, ThroughSeq: webhook.AuditThrough}
+	if webhook.ProcessingID != "" {
+		query.ThroughSeq = 0
+		query.To = time.Now()
+		if job, ok := p.processing.Get(webhook.ProcessingID); ok && job.CompletedAt != nil {
+			query.To = job.CompletedAt.Add(time.Millisecond)
+		}
+	}
+	entries := []AuditEntry{}
+	if webhook.ProcessingID != "" || webhook.AuditThrough > webhook.AuditAfter {
+		entries = p.auditLog.Query(query)
+	}
+
+	persons := make(map[int]bool)
+	calls := make(map[string]bool)
+	for _, entry := range replayed {
+		if entry.PersonID != 0 {
+			persons[entry.PersonID] = true
+		}
+		if entry.CallID != "" {
+			calls[entry.CallID] = true
+		}
+	}
+	if len(persons) == 0 && len(calls) == 0 {
+		return entries
+	}
+	matched := []AuditEntry{}
+	for _, entry := range entries {
+		if (entry.PersonID == 0 && entry.CallID == "") || persons[entry.PersonID] || calls[entry.CallID] {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// diffActions lists the actions only in the original processing and only in the reprocessing
+func diffActions(original, replayed []AuditEntry) (removed, added []string) {
+	counts := make(map[string]int)
+	for _, entry := range original {
+		counts[auditActionKey(entry)]++
+	}
+	added = []string{}
+	for _, entry := range replayed {
+		key := auditActionKey(entry)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		added = append(added, key)
+	}
+	removed = []string{}
+	for _, entry := range original {
+		key := auditActionKey(entry)
+		if counts[key] > 0 {
+			counts[key]--
+			removed = append(removed, key)
+		}
+	}
+	return removed, added
+}
+
+// ListArchivedWebhooksHandler lists archived webhooks, newest first (?path= filters by route)
+func ListArchivedWebhooksHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhooks := []ArchivedWebhook{}
+		for _, webhook := range pipedriveService.webhooks.List() {
+			if path := c.Query("path"); path == "" || webhook.Path == path {
+				webhooks = append(webhooks, webhook)
+			}
+		}
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d archived webhooks", len(webhooks)),
+			Data:    webhooks,
+		})
+	}
+}
+
+// ReprocessWebhookHandler runs an archived webhook through the current code (?mode=dry-run|live)
+// and diffs the resulting actions against the original processing
+func ReprocessWebhookHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode := c.DefaultQuery("mode", ReprocessDryRun)
+		if mode != ReprocessDryRun && mode != ReprocessLive {
+			c.JSON(http.StatusBadRequest, WebhookResponse{
+				Success: false,
+				Message: "mode must be dry-run or live",
+			})
+			return
+		}
+		webhook, ok := pipedriveService.webhooks.Get(c.Param("archived_id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, WebhookResponse{
+				Success: false,
+				Message: "Archived webhook not found",
+			})
+			return
+		}
+
+		transport := &reprocessTransport{next: pipedriveService.httpClient.Transport, dryRun: mode == ReprocessDryRun}
+		replica := pipedriveService.reprocessReplica(transport)
+		recorder := httptest.NewRecorder()
+		replay, _ := gin.CreateTestContext(recorder)
+		target := webhook.Path
+		if webhook.Query != "" {
+			target += "?" + webhook.Query
+		}
+		replay.Request = httptest.NewRequest(http.MethodPost, target, bytes.NewReader(webhook.Payload))
+		replay.Request.Header.Set("Content-Type", "application/json")
+		replay.Set(webhookVerifiedKey, webhook.Verified)
+
+		log.Printf("⏪ Reprocessing archived webhook %s (%s) in %s mode", webhook.ID, webhook.Path, mode)
+		reprocessHandlers[webhook.Path](replica)(replay)
+		replay.Writer.WriteHeaderNow()
+		var response interface{} = recorder.Body.String()
+		if json.Valid(recorder.Body.Bytes()) {
+			response = json.RawMessage(recorder.Body.Bytes())
+		}
+
+		replayed := replica.auditLog.Query(AuditQuery{})
+		original := pipedriveService.originalActions(webhook, replayed)
+		removed, added := diffActions(original, replayed)
+		if mode == ReprocessLive {
+			for _, entry := range replayed {
+				entry.Detail = strings.TrimSpace(entry.Detail + " (reprocessed webhook " + webhook.ID + ")")
+				entry.Timestamp = time.Time{}
+				pipedriveService.auditLog.Append(entry)
+			}
+		}
+
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("Reprocessed webhook %s in %s mode: %d actions added, %d removed", webhook.ID, mode, len(added), len(removed)),
+			Data: gin.H{
+				"webhook":          webhook,
+				"mode":             mode,
+				"status":           recorder.Code,
+				"response":         response,
+				"original_actions": original,
+				"actions":          replayed,
+				"added":            added,
+				"removed":          removed,
+				"requests":         transport.Requests(),
+			},
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhook sources whose requests are verified
+const (
+	WebhookSourceRetell    = "retell"
+	WebhookSourceCal       = "cal"
+	WebhookSourcePipedrive = "pipedrive"
+	WebhookSourceEmail     = "email"
+	WebhookSourceFacebook  = "facebook"
+	WebhookSourceStripe    = "stripe"
+	WebhookSourceCapture   = "capture"
+)
+
+// webhookSourceSecrets names the settings each source is verified with, in startup log order
+var webhookSourceSecrets = []struct{ source, settings string }{
+	{WebhookSourceRetell, "RETELL_WEBHOOK_SECRET"},
+	{WebhookSourceCal, "CAL_WEBHOOK_SECRET"},
+	{WebhookSourcePipedrive, "PIPEDRIVE_WEBHOOK_USER/PASSWORD with PIPEDRIVE_COMPANY_ID, or PIPEDRIVE_WEBHOOK_CREDENTIALS"},
+	{WebhookSourceEmail, "EMAIL_WEBHOOK_TOKEN or EMAIL_WEBHOOK_SIGNING_KEY"},
+	{WebhookSourceFacebook, "FACEBOOK_APP_SECRET"},
+	{WebhookSourceStripe, "STRIPE_WEBHOOK_SECRET"},
+	{WebhookSourceCapture, "CAPTURE_CAPTCHA_PROVIDER and CAPTURE_CAPTCHA_SECRET"},
+}
+
+// webhookVerifiedKey marks a request whose signature or credentials were checked
+const webhookVerifiedKey = "webhook_verified"
+
+// webhookTenantKey holds the tenant whose credentials verified a Pipedrive webhook
+const webhookTenantKey = "webhook_tenant"
+
+// webhookSources maps webhook, agent function and form paths to the source that calls them
+var webhookSources = map[string]string{
+	"/webhook/retell":               WebhookSourceRetell,
+	"/webhook/retell/analyzed":      WebhookSourceRetell,
+	"/functions/check-availability": WebhookSourceRetell,
+	"/functions/book-meeting":       WebhookSourceRetell,
+	"/functions/lookup-contact":     WebhookSourceRetell,
+	"/functions/mark-dnc":           WebhookSourceRetell,
+	"/webhook/cal":                  WebhookSourceCal,
+	"/webhook/pipedrive/lead":       WebhookSourcePipedrive,
+	"/webhook/pipedrive/deal":       WebhookSourcePipedrive,
+	"/webhook/pipedrive/activity":   WebhookSourcePipedrive,
+	"/webhook/pipedrive/person":     WebhookSourcePipedrive,
+	"/webhook/email":                WebhookSourceEmail,
+	"/webhook/facebook":             WebhookSourceFacebook,
+	"/webhook/stripe":               WebhookSourceStripe,
+	"/api/leads/capture":            WebhookSourceCapture,
+}
+
+// retellSignatureMaxAge is how old a Retell signature timestamp may be
+const retellSignatureMaxAge = 5 * time.Minute
+
+// verifyRetellWebhook checks X-Retell-Signature against RETELL_WEBHOOK_SECRET: either
+// "v=<unix ms>,d=<hex HMAC-SHA256 of body + timestamp>" signed within the last 5 minutes, or a
+// hex HMAC-SHA256 of the body
+func verifyRetellWebhook(signature string, body []byte, secret string, now time.Time) bool {
+	if signature == "" || secret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !strings.HasPrefix(signature, "v=") {
+		expected := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected))
+	}
+
+	var timestamp, digest string
+	for _, part := range strings.Split(signature, ",") {
+		if value, ok := strings.CutPrefix(part, "v="); ok {
+			timestamp = value
+		} else if value, ok := strings.CutPrefix(part, "d="); ok {
+			digest = value
+		}
+	}
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || digest == "" {
+		return false
+	}
+	if age := now.Sub(time.UnixMilli(ms)); age > retellSignatureMaxAge || age < -retellSignatureMaxAge {
+		return false
+	}
+	mac.Write([]byte(timestamp))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(digest), []byte(expected))
+}
+
+// PipedriveWebhookCredentials are the basic auth credentials set on one tenant's Pipedrive webhooks
+type PipedriveWebhookCredentials struct {
+	CompanyID string `json:"company_id"`
+	User      string `json:"user"`
+	Password  string `json:"password"`
+}
+
+// loadPipedriveWebhookCredentials parses PIPEDRIVE_WEBHOOK_CREDENTIALS (JSON: tenant -> company_id,
+// user and password)
+func loadPipedriveWebhookCredentials(raw string) map[string]PipedriveWebhookCredentials {
+	if raw == "" {
+		return nil
+	}
+	var credentials map[string]PipedriveWebhookCredentials
+	if err := json.Unmarshal([]byte(raw), &credentials); err != nil {
+		log.Printf("⚠️ Invalid PIPEDRIVE_WEBHOOK_CREDENTIALS, ignoring: %v", err)
+		return nil
+	}
+	for tenant, entry := range credentials {
+		if entry.CompanyID == "" || entry.User == "" || entry.Password == "" {
+			log.Printf("⚠️ Ignoring Pipedrive webhook credentials for tenant %s: company_id, user and password are required", tenant)
+			delete(credentials, tenant)
+		}
+	}
+	return credentials
+}
+
+// pipedriveWebhookCredentials returns the tenant and credentials webhooks from a Pipedrive company
+// must carry: the tenant with that company ID, otherwise PIPEDRIVE_WEBHOOK_USER/PASSWORD for
+// PIPEDRIVE_COMPANY_ID. Any other company is unknown and has no credentials.
+func (c *Config) pipedriveWebhookCredentials(companyID string) (string, PipedriveWebhookCredentials, bool) {
+	if companyID == "" {
+		return "", PipedriveWebhookCredentials{}, false
+	}
+	for tenant, credentials := range c.PipedriveWebhookCredentials {
+		if credentials.CompanyID == companyID {
+			return tenant, credentials, true
+		}
+	}
+	if c.PipedriveWebhookUser == "" || c.PipedriveWebhookPassword == "" || c.PipedriveCompanyID != companyID {
+		return "", PipedriveWebhookCredentials{}, false
+	}
+	return DefaultTenant, PipedriveWebhookCredentials{CompanyID: c.PipedriveCompanyID, User: c.PipedriveWebhookUser, Password: c.PipedriveWebhookPassword}, true
+}
+
+// pipedriveWebhookCompany reads meta.company_id from a Pipedrive webhook body
+func pipedriveWebhookCompany(body []byte) string {
+	var envelope struct {
+		Meta struct {
+			CompanyID json.Number `json:"company_id"`
+		} `json:"meta"`
+	}
+	if json.Unmarshal(body, &envelope) != nil {
+		return ""
+	}
+	return envelope.Meta.CompanyID.String()
+}
+
+// verifyPipedriveWebhook checks the basic auth credentials set on the Pipedrive webhook
+func verifyPipedriveWebhook(c *gin.Context, user, password string) bool {
+	gotUser, gotPassword, ok := c.Request.BasicAuth()
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(gotUser), []byte(user)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(gotPassword), []byte(password)) == 1
+	return userOK && passwordOK
+}
+
+// emailSignatureMaxAge is how old a Mailgun webhook signature timestamp may be
+const emailSignatureMaxAge = 5 * time.Minute
+
+// verifyEmailWebhook checks an inbound email post: the EMAIL_WEBHOOK_TOKEN query parameter
+// (SendGrid Inbound Parse), or Mailgun's timestamp/token/signature form fields signed with
+// EMAIL_WEBHOOK_SIGNING_KEY
+func verifyEmailWebhook(c *gin.Context, body []byte, config *Config, now time.Time) bool {
+	if config.EmailWebhookToken != "" {
+		if token := c.Query("token"); token != "" {
+			return subtle.ConstantTimeCompare([]byte(token), []byte(config.EmailWebhookToken)) == 1
+		}
+	}
+	if config.EmailWebhookSigningKey == "" {
+		return false
+	}
+	form := webhookForm(c.Request, body)
+	timestamp, token, signature := form.Get("timestamp"), form.Get("token"), form.Get("signature")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || token == "" || signature == "" {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > emailSignatureMaxAge || age < -emailSignatureMaxAge {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(config.EmailWebhookSigningKey))
+	mac.Write([]byte(timestamp + token))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// webhookForm parses a urlencoded or multipart body without consuming the request's own body
+func webhookForm(r *http.Request, body []byte) url.Values {
+	clone := r.Clone(r.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	if err := clone.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		return url.Values{}
+	}
+	if clone.MultipartForm != nil {
+		clone.MultipartForm.RemoveAll()
+	}
+	return clone.PostForm
+}
+
+// webhookSecretSet reports whether the credentials a source is verified with are configured
+func (c *Config) webhookSecretSet(source string) bool {
+	switch source {
+	case WebhookSourceRetell:
+		return c.RetellWebhookSecret != ""
+	case WebhookSourceCal:
+		return c.CalWebhookSecret != ""
+	case WebhookSourcePipedrive:
+		return (c.PipedriveWebhookUser != "" && c.PipedriveWebhookPassword != "" && c.PipedriveCompanyID != "") || len(c.PipedriveWebhookCredentials) > 0
+	case WebhookSourceEmail:
+		return c.EmailWebhookToken != "" || c.EmailWebhookSigningKey != ""
+	case WebhookSourceFacebook:
+		return c.FacebookAppSecret != ""
+	case WebhookSourceStripe:
+		return c.StripeWebhookSecret != ""
+	case WebhookSourceCapture:
+		return c.CaptureCaptchaProvider != "" && c.CaptureCaptchaSecret != ""
+	}
+	return false
+}
+
+// webhookVerified reports whether the request's signature or credentials were checked, so what it
+// says about persons and calls can be trusted
+func webhookVerified(c *gin.Context) bool {
+	return c.GetBool(webhookVerifiedKey)
+}
+
+// webhookTenant returns the tenant a Pipedrive webhook was verified for, or "" when verification
+// was bypassed
+func webhookTenant(c *gin.Context) string {
+	return c.GetString(webhookTenantKey)
+}
+
+// tenantAllows reports whether a webhook verified for a tenant may act through an agent: only
+// the tenant's own agents place its calls
+func (p *PipedriveService) tenantAllows(tenant, agentID, subject string) bool {
+	if tenant == "" || p.config.TenantFor(agentID) == tenant {
+		return true
+	}
+	p.logf("⛔ %s came from tenant %s but agent %s belongs to tenant %s, not calling", subject, tenant, agentID, p.config.TenantFor(agentID))
+	p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "call_refused_tenant_mismatch",
+		Detail: fmt.Sprintf("%s: webhook tenant %s, agent %s of tenant %s", subject, tenant, agentID, p.config.TenantFor(agentID))})
+	return false
+}
+
+// webhookBypassed reports whether WEBHOOK_VERIFY_BYPASS applies, which it never does in production
+func (c *Config) webhookBypassed() bool {
+	return c.WebhookVerifyBypass && !c.IsProduction()
+}
+
+// RequireVerifiedWebhook guards routes that read or change CRM data on the caller's word, e.g. the
+// agent functions: requests the middleware did not verify are refused, whatever the path mapping says
+func RequireVerifiedWebhook(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if webhookVerified(c) || pipedriveService.config.webhookBypassed() {
+			c.Next()
+			return
+		}
+		log.Printf("❌ Rejected unverified request on %s", c.Request.URL.Path)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, WebhookResponse{
+			Success: false,
+			Message: "Invalid webhook signature or credentials",
+		})
+	}
+}
+
+// WebhookAuthMiddleware rejects posts from Retell, Cal.com, Pipedrive, inbound email, Facebook and
+// Stripe with 401 unless they carry a valid signature or credentials. A source whose secret is not
+// set is refused with 503; landing page posts need the captcha, which the capture handler checks.
+// WEBHOOK_VERIFY_BYPASS lets everything through outside production.
+func WebhookAuthMiddleware(config *Config) gin.HandlerFunc {
+	bypass := config.webhookBypassed()
+	if config.WebhookVerifyBypass && config.IsProduction() {
+		log.Printf("⚠️ WEBHOOK_VERIFY_BYPASS is ignored in production (APP_ENV=%s)", config.AppEnv)
+	} else if bypass {
+		log.Printf("⚠️ Webhook signature verification is BYPASSED (APP_ENV=%s)", config.AppEnv)
+	}
+	if !bypass {
+		for _, entry := range webhookSourceSecrets {
+			if !config.webhookSecretSet(entry.source) {
+				log.Printf("⚠️ %s webhooks are rejected, set %s to accept them", entry.source, entry.settings)
+			}
+		}
+	}
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		source, ok := webhookSources[path]
+		if bypass || !ok || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		if !config.webhookSecretSet(source) {
+			log.Printf("❌ Rejected %s webhook on %s: verification is not configured", source, path)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, WebhookResponse{
+				Success: false,
+				Message: "Webhook verification is not configured",
+			})
+			return
+		}
+		if source == WebhookSourceCapture {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, WebhookResponse{
+					Success: false,
+					Message: "Failed to read request body",
+				})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		verified, rejection := false, "invalid signature"
+		switch source {
+		case WebhookSourceRetell:
+			// Calls placed on the secondary account after a failover report with its secret
+			signature := c.GetHeader("X-Retell-Signature")
+			verified = verifyRetellWebhook(signature, body, config.RetellWebhookSecret, time.Now()) ||
+				verifyRetellWebhook(signature, body, config.RetellSecondaryWebhookSecret, time.Now())
+		case WebhookSourceCal:
+			verified = verifyCalWebhook(c, body, config.CalWebhookSecret)
+		case WebhookSourceEmail:
+			verified = verifyEmailWebhook(c, body, config, time.Now())
+			rejection = "invalid token or signature"
+		case WebhookSourceFacebook:
+			verified = verifyFacebookSignature(body, c.GetHeader("X-Hub-Signature-256"), config.FacebookAppSecret)
+		case WebhookSourceStripe:
+			verified = verifyStripeSignature(body, c.GetHeader("Stripe-Signature"), config.StripeWebhookSecret, time.Now())
+		case WebhookSourcePipedrive:
+			company := pipedriveWebhookCompany(body)
+			tenant, credentials, ok := config.pipedriveWebhookCredentials(company)
+			switch _, _, hasAuth := c.Request.BasicAuth(); {
+			case !ok:
+				rejection = fmt.Sprintf("no webhook credentials for Pipedrive company %q", company)
+			case !hasAuth:
+				rejection = fmt.Sprintf("no basic auth (tenant %s)", tenant)
+			default:
+				verified = verifyPipedriveWebhook(c, credentials.User, credentials.Password)
+				rejection = fmt.Sprintf("wrong basic auth credentials (tenant %s)", tenant)
+			}
+			if verified {
+				c.Set(webhookTenantKey, tenant)
+			}
+			if !verified {
+				c.Header("WWW-Authenticate", `Basic realm="pipedrive-webhooks"`)
+			}
+		}
+		if !verified {
+			log.Printf("❌ Rejected %s webhook on %s: %s", source, path, rejection)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, WebhookResponse{
+				Success: false,
+				Message: "Invalid webhook signature or credentials",
+			})
+			return
+		}
+		c.Set(webhookVerifiedKey, true)
+		c.Next()
+	}
+}
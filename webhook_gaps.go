@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhooks expected from Retell for every placed call, in order
+const (
+	WebhookGapStarted  = "started"  // call_started after dialing
+	WebhookGapEnded    = "ended"    // call_ended after call_started
+	WebhookGapAnalyzed = "analyzed" // call_analyzed after call_ended
+)
+
+// Webhook gap statuses
+const (
+	WebhookGapOpen       = "open"       // Overdue and still being checked against get-call
+	WebhookGapHealed     = "healed"     // Recovered from get-call
+	WebhookGapUnresolved = "unresolved" // get-call could not recover it within WEBHOOK_GAP_MAX_CHECKS
+)
+
+// webhookGapLookback is how far back placed calls are watched for missing webhooks
+const webhookGapLookback = 24 * time.Hour
+
+// WebhookGap is an expected Retell webhook that did not arrive in time
+type WebhookGap struct {
+	CallID     string     `json:"call_id"`
+	PersonID   int        `json:"person_id,omitempty"`
+	LeadID     string     `json:"lead_id,omitempty"`
+	Missing    string     `json:"missing"`     // started, ended or analyzed
+	ExpectedBy time.Time  `json:"expected_by"` // When the webhook was due
+	DetectedAt time.Time  `json:"detected_at"`
+	Status     string     `json:"status"`
+	Checks     int        `json:"checks"` // get-call attempts so far
+	Detail     string     `json:"detail,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// WebhookGapTracker keeps the webhook gaps found in the last day
+type WebhookGapTracker struct {
+	mu      sync.Mutex
+	gaps    map[string]*WebhookGap // Keyed by call ID and missing webhook
+	settled map[string]time.Time   // Calls Retell reported as never connected; no more webhooks are expected
+}
+
+// NewWebhookGapTracker creates an empty tracker
+func NewWebhookGapTracker() *WebhookGapTracker {
+	return &WebhookGapTracker{gaps: make(map[string]*WebhookGap), settled: make(map[string]time.Time)}
+}
+
+// open returns the gap for a call's missing webhook, recording it if it is new
+func (t *WebhookGapTracker) open(gap WebhookGap) (*WebhookGap, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := gap.CallID + ":" + gap.Missing
+	if existing, ok := t.gaps[key]; ok {
+		return existing, false
+	}
+	gap.Status = WebhookGapOpen
+	t.gaps[key] = &gap
+	return &gap, true
+}
+
+// update changes a gap under the tracker's lock
+func (t *WebhookGapTracker) update(gap *WebhookGap, fn func(*WebhookGap)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fn(gap)
+}
+
+// settle stops expecting webhooks for a call
+func (t *WebhookGapTracker) settle(callID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.settled[callID] = time.Now()
+}
+
+// isSettled reports whether a call expects no more webhooks
+func (t *WebhookGapTracker) isSettled(callID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.settled[callID]
+	return ok
+}
+
+// prune forgets gaps and settled calls older than the lookback
+func (t *WebhookGapTracker) prune(cutoff time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, gap := range t.gaps {
+		if gap.DetectedAt.Before(cutoff) {
+			delete(t.gaps, key)
+		}
+	}
+	for callID, at := range t.settled {
+		if at.Before(cutoff) {
+			delete(t.settled, callID)
+		}
+	}
+}
+
+// List returns the gaps with the given status (all when empty), newest first
+func (t *WebhookGapTracker) List(status string) []WebhookGap {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	gaps := []WebhookGap{}
+	for _, gap := range t.gaps {
+		if status == "" || gap.Status == status {
+			gaps = append(gaps, *gap)
+		}
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].DetectedAt.After(gaps[j].DetectedAt) })
+	return gaps
+}
+
+// overdueWebhook returns the next webhook a placed call is waiting for and when it was due, or ""
+// when nothing is overdue. Calls that were analyzed or failed in the CRM expect nothing more.
+func (c *Config) overdueWebhook(events []CallEvent, now time.Time) (string, time.Time) {
+	for _, done := range []string{CallEventAnalyzed, CallEventCRMUpdated, CallEventFailed} {
+		if latestCallEvent(events, done) != nil {
+			return "", time.Time{}
+		}
+	}
+	missing, since, wait := WebhookGapStarted, time.Time{}, c.WebhookGapStartedMinutes
+	if ended := latestCallEvent(events, CallEventEnded); ended != nil {
+		missing, since, wait = WebhookGapAnalyzed, ended.Timestamp, c.WebhookGapAnalyzedMinutes
+	} else if connected := latestCallEvent(events, CallEventConnected); connected != nil {
+		missing, since, wait = WebhookGapEnded, connected.Timestamp, c.WebhookGapEndedMinutes
+	} else if dialing := latestCallEvent(events, CallEventDialing); dialing != nil {
+		since = dialing.Timestamp
+	} else {
+		return "", time.Time{}
+	}
+	expectedBy := since.Add(time.Duration(wait) * time.Minute)
+	if now.Before(expectedBy) {
+		return "", time.Time{}
+	}
+	return missing, expectedBy
+}
+
+// CheckWebhookGaps looks for placed calls whose next Retell webhook is overdue, alerts on new gaps
+// and tries to heal open ones from get-call
+func (p *PipedriveService) CheckWebhookGaps() int {
+	now := time.Now()
+	p.webhookGaps.prune(now.Add(-webhookGapLookback))
+	found := 0
+	for callID, events := range p.callEvents.StartedBetween(now.Add(-webhookGapLookback), now) {
+		if latestCallEvent(events, CallEventDialing) == nil || p.webhookGaps.isSettled(callID) {
+			continue // Not placed by this service, or Retell said it never connected
+		}
+		missing, expectedBy := p.config.overdueWebhook(events, now)
+		if missing == "" {
+			continue
+		}
+		dialing := latestCallEvent(events, CallEventDialing)
+		gap, created := p.webhookGaps.open(WebhookGap{
+			CallID:     callID,
+			PersonID:   dialing.PersonID,
+			LeadID:     dialing.LeadID,
+			Missing:    missing,
+			ExpectedBy: expectedBy,
+			DetectedAt: now,
+		})
+		if gap.Status != WebhookGapOpen {
+			continue
+		}
+		// Call events are kept per replica, so the webhook may have reached another one; only a gap
+		// the first get-call check doesn't explain is alerted
+		p.healWebhookGap(gap)
+		if created {
+			found++
+			p.logf("🕳️ Retell call_%s webhook overdue for call %s (due %s)", missing, callID, expectedBy.Format(time.RFC3339))
+			p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "webhook_gap", PersonID: gap.PersonID, CallID: callID,
+				Detail: fmt.Sprintf("call_%s webhook overdue since %s", missing, expectedBy.Format(time.RFC3339))})
+			if gap.Status == WebhookGapOpen {
+				p.sendAlert(fmt.Sprintf("🕳️ Retell call_%s webhook missing for call %s (person %d), still checking get-call", missing, callID, gap.PersonID))
+			}
+		}
+	}
+	return found
+}
+
+// healWebhookGap fetches the call from Retell and records what the missing webhook would have:
+// the lifecycle event for started and ended, and the CRM writes for analyzed. A gap get-call
+// can't explain is reported unresolved after WEBHOOK_GAP_MAX_CHECKS attempts.
+func (p *PipedriveService) healWebhookGap(gap *WebhookGap) {
+	p.webhookGaps.update(gap, func(g *WebhookGap) { g.Checks++ })
+	detail, healed := "", false
+
+	payload, details, err := p.GetRetellCall(gap.CallID)
+	analyzed := len(details.CallAnalysis) > 0 && string(details.CallAnalysis) != "null"
+	status := payload.Call.CallStatus
+	switch {
+	case err != nil:
+		detail = "get-call failed: " + err.Error()
+	case analyzed:
+		detail, healed = p.healAnalyzedCall(gap)
+	case status == "ended":
+		p.recoverCallEvent(gap, CallEventEnded, payload.Call.EndTimestamp)
+		if gap.Missing == WebhookGapAnalyzed {
+			detail = "the call ended but Retell has not analyzed it yet"
+		} else {
+			detail, healed = "call end recovered from get-call", true
+		}
+	case status == "ongoing":
+		p.recoverCallEvent(gap, CallEventConnected, payload.Call.StartTimestamp)
+		if gap.Missing == WebhookGapStarted {
+			detail, healed = "call start recovered from get-call", true
+		} else {
+			detail = "Retell reports the call is still ongoing"
+		}
+	case status == "not_connected" || status == "error":
+		p.recoverCallEvent(gap, CallEventEnded, payload.Call.EndTimestamp)
+		p.webhookGaps.settle(gap.CallID)
+		detail, healed = fmt.Sprintf("Retell reports the call %s (%s)", status, payload.Call.DisconnectionReason), true
+	default:
+		detail = fmt.Sprintf("Retell reports the call %s", status)
+	}
+
+	now := time.Now()
+	p.webhookGaps.update(gap, func(g *WebhookGap) {
+		g.Detail = detail
+		switch {
+		case healed:
+			g.Status, g.ResolvedAt = WebhookGapHealed, &now
+		case g.Checks >= p.config.WebhookGapMaxChecks:
+			g.Status, g.ResolvedAt = WebhookGapUnresolved, &now
+		}
+	})
+	switch gap.Status {
+	case WebhookGapHealed:
+		p.logf("✅ Healed missing call_%s webhook for call %s: %s", gap.Missing, gap.CallID, detail)
+		p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "webhook_gap_healed", PersonID: gap.PersonID, CallID: gap.CallID, Detail: detail})
+	case WebhookGapUnresolved:
+		p.logf("❌ Could not heal missing call_%s webhook for call %s after %d checks: %s", gap.Missing, gap.CallID, gap.Checks, detail)
+		p.auditLog.Append(AuditEntry{Actor: AuditActorSystem, Action: "webhook_gap_unresolved", PersonID: gap.PersonID, CallID: gap.CallID, Detail: detail})
+		p.sendAlert(fmt.Sprintf("❌ Retell call_%s webhook for call %s still missing after %d get-call checks: %s", gap.Missing, gap.CallID, gap.Checks, detail))
+	default:
+		p.debugf(SubsystemRetell, "Webhook gap for call %s still open: %s", gap.CallID, detail)
+	}
+}
+
+// healAnalyzedCall logs an analyzed call whose call_analyzed webhook is missing here, under the
+// same claim as the webhook so a replica that received it doesn't log the call twice
+func (p *PipedriveService) healAnalyzedCall(gap *WebhookGap) (string, bool) {
+	release, ok := p.claim("analyzed:" + gap.CallID)
+	if !ok {
+		return "call_analyzed is being or was handled by another replica", true
+	}
+	err := p.refreshCall(gap.CallID)
+	if errors.Is(err, ErrCallAlreadyLogged) {
+		return "the call is already logged in Pipedrive", true
+	}
+	if err != nil {
+		release()
+		return "refreshing the analyzed call failed: " + err.Error(), false
+	}
+	return "analysis recovered from get-call and logged", true
+}
+
+// recoverCallEvent records a lifecycle event found through get-call, unless the webhook arrived meanwhile
+func (p *PipedriveService) recoverCallEvent(gap *WebhookGap, eventType string, timestampMillis int64) {
+	if latestCallEvent(p.callEvents.Events(gap.CallID), eventType) != nil {
+		return
+	}
+	event := CallEvent{CallID: gap.CallID, Type: eventType, LeadID: gap.LeadID, PersonID: gap.PersonID, Detail: "recovered from Retell get-call"}
+	if timestampMillis > 0 {
+		event.Timestamp = time.UnixMilli(timestampMillis)
+	}
+	p.callEvents.Append(event)
+}
+
+// runWebhookGapDetector checks for missing Retell webhooks every minute
+func (p *PipedriveService) runWebhookGapDetector() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if _, ok := p.claim("webhook-gaps:" + now.UTC().Format("2006-01-02T15:04")); ok {
+			p.CheckWebhookGaps()
+		}
+	}
+}
+
+// WebhookGapsHandler lists missing Retell webhooks and how they were healed, optionally by ?status=
+func WebhookGapsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gaps := pipedriveService.webhookGaps.List(c.Query("status"))
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d webhook gaps in the last %s", len(gaps), webhookGapLookback),
+			Data:    gaps,
+		})
+	}
+}
+
+// WebhookGapCheckHandler checks for missing Retell webhooks now
+func WebhookGapCheckHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !pipedriveService.config.WebhookGapEnabled {
+			c.JSON(http.StatusConflict, WebhookResponse{
+				Success: false,
+				Message: "Webhook gap detection is disabled (WEBHOOK_GAP_DETECTION unset)",
+			})
+			return
+		}
+		found := pipedriveService.CheckWebhookGaps()
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("%d new webhook gaps found", found),
+			Data:    pipedriveService.webhookGaps.List(WebhookGapOpen),
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MessagingProvider sends a text message to a phone number over a single channel
+type MessagingProvider interface {
+	Channel() string
+	Send(to, body string) (string, error)
+}
+
+// MetaWhatsAppProvider sends WhatsApp messages through the Meta Cloud API. Meta only delivers
+// free-form text within 24 hours of the contact's last message, and we never receive inbound
+// WhatsApp messages, so every message goes out as an approved template with the text as its
+// single body parameter
+type MetaWhatsAppProvider struct {
+	accessToken   string
+	phoneNumberID string
+	templateName  string
+	templateLang  string
+	httpClient    *http.Client
+}
+
+// TwilioWhatsAppProvider sends WhatsApp messages through Twilio's Messages API
+type TwilioWhatsAppProvider struct {
+	accountSID string
+	authToken  string
+	from       string
+	httpClient *http.Client
+}
+
+// newWhatsAppProvider returns the configured WhatsApp provider, or nil if none is configured
+func newWhatsAppProvider(config *Config, httpClient *http.Client) MessagingProvider {
+	switch config.WhatsAppProvider {
+	case "meta":
+		if config.WhatsAppAccessToken == "" || config.WhatsAppPhoneNumberID == "" || config.WhatsAppTemplateName == "" {
+			log.Printf("⚠️ WHATSAPP_PROVIDER=meta requires WHATSAPP_ACCESS_TOKEN, WHATSAPP_PHONE_NUMBER_ID and WHATSAPP_TEMPLATE_NAME")
+			return nil
+		}
+		return &MetaWhatsAppProvider{
+			accessToken:   config.WhatsAppAccessToken,
+			phoneNumberID: config.WhatsAppPhoneNumberID,
+			templateName:  config.WhatsAppTemplateName,
+			templateLang:  config.WhatsAppTemplateLang,
+			httpClient:    httpClient,
+		}
+	case "twilio":
+		if config.TwilioAccountSID == "" || config.TwilioAuthToken == "" || config.TwilioWhatsAppFrom == "" {
+			log.Printf("⚠️ WHATSAPP_PROVIDER=twilio requires TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_WHATSAPP_FROM")
+			return nil
+		}
+		return &TwilioWhatsAppProvider{
+			accountSID: config.TwilioAccountSID,
+			authToken:  config.TwilioAuthToken,
+			from:       config.TwilioWhatsAppFrom,
+			httpClient: httpClient,
+		}
+	case "":
+		return nil
+	default:
+		log.Printf("⚠️ Unknown WHATSAPP_PROVIDER: %s", config.WhatsAppProvider)
+		return nil
+	}
+}
+
+// Channel returns the channel name
+func (m *MetaWhatsAppProvider) Channel() string {
+	return "whatsapp"
+}
+
+// Send sends the text through the configured template and returns the WhatsApp message ID
+func (m *MetaWhatsAppProvider) Send(to, body string) (string, error) {
+	// Template parameters may not contain newlines, tabs or runs of spaces
+	parameter := strings.Join(strings.Fields(body), " ")
+	requestBody := map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                strings.TrimPrefix(to, "+"),
+		"type":              "template",
+		"template": map[string]interface{}{
+			"name":     m.templateName,
+			"language": map[string]string{"code": m.templateLang},
+			"components": []map[string]interface{}{{
+				"type":       "body",
+				"parameters": []map[string]string{{"type": "text", "text": parameter}},
+			}},
+		},
+	}
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal WhatsApp message: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("https://graph.facebook.com/v18.0/%s/messages", m.phoneNumberID)
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+
+	respBody, err := doMessagingRequest(m.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil || len(result.Messages) == 0 {
+		return "", fmt.Errorf("failed to parse WhatsApp response: %s", string(respBody))
+	}
+	return result.Messages[0].ID, nil
+}
+
+// Channel returns the channel name
+func (t *TwilioWhatsAppProvider) Channel() string {
+	return "whatsapp"
+}
+
+// Send sends a text message and returns the Twilio message SID
+func (t *TwilioWhatsAppProvider) Send(to, body string) (string, error) {
+	form := url.Values{}
+	form.Set("From", "whatsapp:"+t.from)
+	form.Set("To", "whatsapp:"+to)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", t.accountSID)
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.accountSID, t.authToken)
+
+	respBody, err := doMessagingRequest(t.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		SID string `json:"sid"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil || result.SID == "" {
+		return "", fmt.Errorf("failed to parse Twilio response: %s", string(respBody))
+	}
+	return result.SID, nil
+}
+
+// TwilioSMSProvider sends plain SMS through Twilio's Messages API
+type TwilioSMSProvider struct {
+	accountSID string
+	authToken  string
+	from       string
+	httpClient *http.Client
+}
+
+// newSMSProvider returns a Twilio SMS provider, or nil if SMS is not configured
+func newSMSProvider(config *Config, httpClient *http.Client) MessagingProvider {
+	if config.TwilioAccountSID == "" || config.TwilioAuthToken == "" || config.TwilioSMSFrom == "" {
+		return nil
+	}
+	return &TwilioSMSProvider{
+		accountSID: config.TwilioAccountSID,
+		authToken:  config.TwilioAuthToken,
+		from:       config.TwilioSMSFrom,
+		httpClient: httpClient,
+	}
+}
+
+// Channel returns the channel name
+func (t *TwilioSMSProvider) Channel() string {
+	return "sms"
+}
+
+// Send sends an SMS and returns the Twilio message SID
+func (t *TwilioSMSProvider) Send(to, body string) (string, error) {
+	form := url.Values{}
+	form.Set("From", t.from)
+	form.Set("To", to)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", t.accountSID)
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.accountSID, t.authToken)
+
+	respBody, err := doMessagingRequest(t.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		SID string `json:"sid"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil || result.SID == "" {
+		return "", fmt.Errorf("failed to parse Twilio response: %s", string(respBody))
+	}
+	return result.SID, nil
+}
+
+// doMessagingRequest executes a provider request and returns the body of a 2xx response
+func doMessagingRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("message send failed: HTTP %d, Response: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// buildFollowUpMessage builds the post-call follow-up text
+func (p *PipedriveService) buildFollowUpMessage(personName, summary string) string {
+	if p.config.FollowUpTemplate != "" {
+		return renderTemplate(p.config.FollowUpTemplate, map[string]string{
+			"name":        personName,
+			"summary":     summary,
+			"meeting_url": p.config.FollowUpMeetingURL,
+		})
+	}
+	message := fmt.Sprintf("Hi %s, thanks for speaking with us today.", personName)
+	if summary != "" {
+		message += "\n\nSummary: " + summary
+	}
+	if p.config.FollowUpMeetingURL != "" {
+		message += "\n\nBook a meeting: " + p.config.FollowUpMeetingURL
+	}
+	return message
+}
+
+// SendWhatsAppFollowUp sends a WhatsApp message and logs it as a Pipedrive activity
+func (p *PipedriveService) SendWhatsAppFollowUp(personID int, phoneNumber, message string) error {
+	if p.whatsApp == nil {
+		return fmt.Errorf("WhatsApp not configured")
+	}
+	if phoneNumber == "" {
+		return fmt.Errorf("no phone number for person %d", personID)
+	}
+	person, err := p.GetPersonByID(personID)
+	if err != nil {
+		return fmt.Errorf("failed to check person %d before messaging: %v", personID, err)
+	}
+	if blocked, notUntil := p.contactGate(person, phoneNumber, ContactWhatsApp, "WhatsApp message", time.Now()); blocked != "" || !notUntil.IsZero() {
+		return nil
+	}
+
+	message = p.shortenLinks(message, personID)
+	messageID, err := p.whatsApp.Send(phoneNumber, message)
+	if err != nil {
+		return err
+	}
+	p.logf("✅ Sent WhatsApp follow-up to %s (message: %s)", phoneNumber, messageID)
+
+	activityData := map[string]interface{}{
+		"subject":   "WhatsApp Follow-up Sent",
+		"type":      p.config.WhatsAppActivityType,
+		"person_id": personID,
+		"note":      fmt.Sprintf("WhatsApp message sent to %s\nMessage ID: %s\n\n%s", phoneNumber, messageID, message),
+		"done":      1,
+		"due_date":  pipedriveDueDate(time.Now()),
+		"due_time":  pipedriveDueTime(time.Now()),
+	}
+
+	resp, err := p.createActivity(activityData)
+	if err != nil {
+		return fmt.Errorf("message sent but failed to log activity: %v", err)
+	}
+	resp.Body.Close()
+	return nil
+}
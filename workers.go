@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Backpressure behaviors when the worker queue is full
+const (
+	BackpressureReject     = "reject"      // Refuse new work
+	BackpressureBuffer     = "buffer"      // Wait up to the enqueue timeout for space, then refuse
+	BackpressureShedOldest = "shed_oldest" // Drop the oldest queued job to make room
+)
+
+// ErrQueueFull is returned when the worker queue cannot accept more work
+var ErrQueueFull = errors.New("worker queue is full")
+
+// WorkerPoolStats holds worker pool metrics
+type WorkerPoolStats struct {
+	Region        string `json:"region,omitempty"`
+	Workers       int    `json:"workers"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Backpressure  string `json:"backpressure"`
+	Queued        int    `json:"queued"`
+	Active        int    `json:"active"`
+	MaxQueued     int    `json:"max_queued"`
+	Submitted     int64  `json:"submitted"`
+	Processed     int64  `json:"processed"`
+	Rejected      int64  `json:"rejected"`
+	Shed          int64  `json:"shed"`
+	Throttled     int64  `json:"throttled"` // Webhooks answered 429 so the sender retries later
+	Panicked      int64  `json:"panicked"`  // Jobs that panicked; the worker recovers and carries on
+
+	PriorityWorkers   int   `json:"priority_workers"`   // Workers reserved for the priority lane
+	PriorityQueued    int   `json:"priority_queued"`    // Opt-out and compliance jobs waiting
+	PrioritySubmitted int64 `json:"priority_submitted"` // Jobs submitted to the priority lane
+	PriorityProcessed int64 `json:"priority_processed"`
+
+	OldestWaitSeconds float64 `json:"oldest_wait_seconds"` // How long the oldest queued job has waited
+}
+
+// workerTask is a queued unit of work; drop is called if the task is shed before running
+type workerTask struct {
+	run      func()
+	drop     func()
+	queuedAt time.Time
+}
+
+// WorkerPool runs background work on a fixed number of workers with a bounded queue, plus an
+// unbounded priority lane for opt-out and compliance work that runs ahead of the queue
+type WorkerPool struct {
+	mu             sync.Mutex
+	ready          *sync.Cond // Signalled when work is queued
+	space          *sync.Cond // Signalled when a queued job is taken, for buffered submitters
+	queue          []workerTask
+	priority       []workerTask // Never shed or refused; taken before queue by every worker
+	capacity       int
+	policy         string
+	enqueueTimeout time.Duration
+	stats          WorkerPoolStats
+	start          sync.Once
+}
+
+// NewWorkerPool creates a worker pool sized from config; workers start on first use
+func NewWorkerPool(config *Config) *WorkerPool {
+	workers := config.WorkerPoolSize
+	if workers < 1 {
+		workers = 1
+	}
+	capacity := config.WorkerQueueDepth
+	if capacity < 1 {
+		capacity = 1
+	}
+	policy := config.WorkerBackpressure
+	switch policy {
+	case BackpressureReject, BackpressureBuffer, BackpressureShedOldest:
+	default:
+		log.Printf("⚠️ Unknown WORKER_BACKPRESSURE %q, using %s", policy, BackpressureBuffer)
+		policy = BackpressureBuffer
+	}
+
+	priorityWorkers := config.WorkerPriorityWorkers
+	if priorityWorkers < 0 {
+		priorityWorkers = 0
+	}
+
+	pool := &WorkerPool{
+		capacity:       capacity,
+		policy:         policy,
+		enqueueTimeout: time.Duration(config.WorkerEnqueueTimeout) * time.Second,
+		stats: WorkerPoolStats{
+			Region:          config.Region,
+			Workers:         workers,
+			QueueCapacity:   capacity,
+			Backpressure:    policy,
+			PriorityWorkers: priorityWorkers,
+		},
+	}
+	pool.ready = sync.NewCond(&pool.mu)
+	pool.space = sync.NewCond(&pool.mu)
+	return pool
+}
+
+// startWorkers starts the workers on first use
+func (w *WorkerPool) startWorkers() {
+	w.start.Do(func() {
+		for i := 0; i < w.stats.Workers; i++ {
+			go w.work(false)
+		}
+		for i := 0; i < w.stats.PriorityWorkers; i++ {
+			go w.work(true)
+		}
+	})
+}
+
+// Submit queues work, applying the backpressure policy when the queue is full
+func (w *WorkerPool) Submit(run func(), drop func()) error {
+	w.startWorkers()
+
+	w.mu.Lock()
+
+	if len(w.queue) >= w.capacity {
+		switch w.policy {
+		case BackpressureShedOldest:
+			oldest := w.queue[0]
+			w.queue = w.queue[1:]
+			w.stats.Shed++
+			if oldest.drop != nil {
+				go oldest.drop()
+			}
+			log.Printf("⚠️ [WORKERS] Queue full (%d), shed oldest job", w.capacity)
+		case BackpressureBuffer:
+			// Wait for a worker to take a job, or for the timeout to wake us
+			deadline := time.Now().Add(w.enqueueTimeout)
+			timer := time.AfterFunc(w.enqueueTimeout, func() {
+				w.mu.Lock()
+				w.space.Broadcast()
+				w.mu.Unlock()
+			})
+			for len(w.queue) >= w.capacity && time.Now().Before(deadline) {
+				w.space.Wait()
+			}
+			timer.Stop()
+			if len(w.queue) >= w.capacity {
+				w.stats.Rejected++
+				w.mu.Unlock()
+				log.Printf("⚠️ [WORKERS] Queue still full after %s, rejecting job", w.enqueueTimeout)
+				return ErrQueueFull
+			}
+		default:
+			w.stats.Rejected++
+			w.mu.Unlock()
+			log.Printf("⚠️ [WORKERS] Queue full (%d), rejecting job", w.capacity)
+			return ErrQueueFull
+		}
+	}
+
+	w.queue = append(w.queue, workerTask{run: run, drop: drop, queuedAt: time.Now()})
+	w.stats.Submitted++
+	if len(w.queue) > w.stats.MaxQueued {
+		w.stats.MaxQueued = len(w.queue)
+	}
+	w.mu.Unlock()
+	w.ready.Broadcast()
+	return nil
+}
+
+// SubmitPriority queues work on the priority lane: it is not bounded by the queue capacity, is
+// never shed, and runs before any queued job, on the reserved priority workers when the others are busy
+func (w *WorkerPool) SubmitPriority(run func()) {
+	w.startWorkers()
+
+	w.mu.Lock()
+	w.priority = append(w.priority, workerTask{run: run, queuedAt: time.Now()})
+	w.stats.PrioritySubmitted++
+	w.mu.Unlock()
+	w.ready.Broadcast()
+}
+
+// next removes the task a worker runs next: priority tasks first, in submission order, then the
+// queue unless priorityOnly; callers must hold mu
+func (w *WorkerPool) next(priorityOnly bool) (workerTask, bool, bool) {
+	if len(w.priority) > 0 {
+		task := w.priority[0]
+		w.priority = w.priority[1:]
+		return task, true, true
+	}
+	if priorityOnly || len(w.queue) == 0 {
+		return workerTask{}, false, false
+	}
+	task := w.queue[0]
+	w.queue = w.queue[1:]
+	w.space.Broadcast()
+	return task, false, true
+}
+
+// work runs tasks until the process exits; priority workers only take priority tasks
+func (w *WorkerPool) work(priorityOnly bool) {
+	for {
+		w.mu.Lock()
+		task, priority, ok := w.next(priorityOnly)
+		for !ok {
+			w.ready.Wait()
+			task, priority, ok = w.next(priorityOnly)
+		}
+		w.stats.Active++
+		w.mu.Unlock()
+
+		panicked := w.runTask(task)
+
+		w.mu.Lock()
+		if panicked {
+			w.stats.Panicked++
+		}
+		w.stats.Active--
+		w.stats.Processed++
+		if priority {
+			w.stats.PriorityProcessed++
+		}
+		w.mu.Unlock()
+	}
+}
+
+// runTask runs a task, recovering a panic so the worker keeps serving the queue
+func (w *WorkerPool) runTask(task workerTask) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			log.Printf("❌ [WORKERS] Job panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	task.run()
+	return false
+}
+
+// Stats returns a snapshot of the pool metrics
+func (w *WorkerPool) Stats() WorkerPoolStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	stats.Queued = len(w.queue)
+	stats.PriorityQueued = len(w.priority)
+	if len(w.queue) > 0 {
+		stats.OldestWaitSeconds = time.Since(w.queue[0].queuedAt).Seconds()
+	}
+	return stats
+}
+
+// Saturated reports whether the queue is past WEBHOOK_SHED_QUEUE_PERCENT of capacity or its
+// oldest job has waited longer than WEBHOOK_SHED_MAX_WAIT, with the reason
+func (w *WorkerPool) Saturated(queuePercent int, maxWait time.Duration) (bool, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if queuePercent > 0 && len(w.queue)*100 >= w.capacity*queuePercent {
+		return true, fmt.Sprintf("queue at %d/%d", len(w.queue), w.capacity)
+	}
+	if maxWait > 0 && len(w.queue) > 0 {
+		if wait := time.Since(w.queue[0].queuedAt); wait > maxWait {
+			return true, fmt.Sprintf("oldest job waiting %s", wait.Round(time.Second))
+		}
+	}
+	return false, ""
+}
+
+// recordThrottled counts a webhook refused with 429
+func (w *WorkerPool) recordThrottled() {
+	w.mu.Lock()
+	w.stats.Throttled++
+	w.mu.Unlock()
+}
+
+// respondThrottled answers 429 with Retry-After so the webhook sender redelivers later
+func respondThrottled(c *gin.Context, pipedriveService *PipedriveService, reason string) {
+	pipedriveService.workers.recordThrottled()
+	c.Header("Retry-After", strconv.Itoa(pipedriveService.config.WebhookRetryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, WebhookResponse{
+		Success: false,
+		Message: "Server busy, retry later: " + reason,
+	})
+}
+
+// WebhookBackpressureMiddleware refuses webhooks from senders that retry (WEBHOOK_SHED_PATHS)
+// with 429 while the worker queue is saturated, instead of accepting work that would be shed
+func WebhookBackpressureMiddleware(pipedriveService *PipedriveService) gin.HandlerFunc {
+	config := pipedriveService.config
+	maxWait := time.Duration(config.WebhookShedMaxWait) * time.Second
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		for _, prefix := range config.WebhookShedPaths {
+			if !strings.HasPrefix(c.Request.URL.Path, prefix) {
+				continue
+			}
+			if saturated, reason := pipedriveService.workers.Saturated(config.WebhookShedQueuePercent, maxWait); saturated {
+				if pipedriveService.isComplianceRequest(c) {
+					log.Printf("🚨 [WORKERS] Not throttling compliance event on %s: %s", c.Request.URL.Path, reason)
+					break
+				}
+				log.Printf("⚠️ [WORKERS] Throttling %s: %s", c.Request.URL.Path, reason)
+				respondThrottled(c, pipedriveService, reason)
+				return
+			}
+			break
+		}
+		c.Next()
+	}
+}
+
+// WorkerStatsHandler returns worker pool metrics
+func WorkerStatsHandler(pipedriveService *PipedriveService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, WebhookResponse{
+			Success: true,
+			Message: "Worker pool metrics",
+			Data:    pipedriveService.workers.Stats(),
+		})
+	}
+}
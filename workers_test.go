@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// blockWorker submits a job that holds a regular worker until the returned func is called
+func blockWorker(t *testing.T, pool *WorkerPool) func() {
+	t.Helper()
+	started, release := make(chan struct{}), make(chan struct{})
+	if err := pool.Submit(func() {
+		close(started)
+		<-release
+	}, nil); err != nil {
+		t.Fatalf("submitting the blocking job: %v", err)
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("blocking job never started")
+	}
+	return func() { close(release) }
+}
+
+// recordRun returns a job that sends name to order when it runs
+func recordRun(order chan<- string, name string) func() {
+	return func() { order <- name }
+}
+
+// collect reads n job names from order
+func collect(t *testing.T, order <-chan string, n int) []string {
+	t.Helper()
+	var names []string
+	for len(names) < n {
+		select {
+		case name := <-order:
+			names = append(names, name)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("only %d of %d jobs ran: %v", len(names), n, names)
+		}
+	}
+	return names
+}
+
+func TestPriorityJobsRunBeforeQueuedJobs(t *testing.T) {
+	pool := NewWorkerPool(&Config{WorkerPoolSize: 1, WorkerQueueDepth: 10, WorkerBackpressure: BackpressureReject})
+	release := blockWorker(t, pool)
+
+	order := make(chan string, 10)
+	for i := 1; i <= 3; i++ {
+		if err := pool.Submit(recordRun(order, fmt.Sprintf("campaign-%d", i)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool.SubmitPriority(recordRun(order, "optout-1"))
+	pool.SubmitPriority(recordRun(order, "optout-2"))
+	release()
+
+	want := []string{"optout-1", "optout-2", "campaign-1", "campaign-2", "campaign-3"}
+	got := collect(t, order, len(want))
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("jobs ran in order %v, want %v", got, want)
+	}
+}
+
+func TestPriorityWorkersRunWhileRegularWorkersAreBusy(t *testing.T) {
+	pool := NewWorkerPool(&Config{WorkerPoolSize: 1, WorkerQueueDepth: 10, WorkerBackpressure: BackpressureReject, WorkerPriorityWorkers: 1})
+	release := blockWorker(t, pool)
+	defer release()
+
+	order := make(chan string, 10)
+	if err := pool.Submit(recordRun(order, "campaign"), nil); err != nil {
+		t.Fatal(err)
+	}
+	pool.SubmitPriority(recordRun(order, "optout"))
+
+	if got := collect(t, order, 1); got[0] != "optout" {
+		t.Fatalf("%s ran while the regular worker was busy, want optout", got[0])
+	}
+	// The priority worker is reserved: queued campaign work waits for a regular worker
+	select {
+	case name := <-order:
+		t.Fatalf("%s ran on the priority worker", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPriorityJobsBypassAFullQueue(t *testing.T) {
+	pool := NewWorkerPool(&Config{WorkerPoolSize: 1, WorkerQueueDepth: 1, WorkerBackpressure: BackpressureReject})
+	release := blockWorker(t, pool)
+
+	order := make(chan string, 10)
+	if err := pool.Submit(recordRun(order, "campaign"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(recordRun(order, "rejected"), nil); err != ErrQueueFull {
+		t.Fatalf("submitting to a full queue returned %v, want ErrQueueFull", err)
+	}
+	pool.SubmitPriority(recordRun(order, "optout"))
+	release()
+
+	want := []string{"optout", "campaign"}
+	if got := collect(t, order, len(want)); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("jobs ran in order %v, want %v", got, want)
+	}
+	if stats := pool.Stats(); stats.Rejected != 1 || stats.PrioritySubmitted != 1 {
+		t.Fatalf("rejected %d and priority submitted %d, want 1 and 1", stats.Rejected, stats.PrioritySubmitted)
+	}
+}
+
+func TestSheddingNeverDropsPriorityJobs(t *testing.T) {
+	pool := NewWorkerPool(&Config{WorkerPoolSize: 1, WorkerQueueDepth: 1, WorkerBackpressure: BackpressureShedOldest})
+	release := blockWorker(t, pool)
+
+	order := make(chan string, 10)
+	pool.SubmitPriority(recordRun(order, "optout"))
+	shed := make(chan struct{})
+	if err := pool.Submit(recordRun(order, "campaign-1"), func() { close(shed) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(recordRun(order, "campaign-2"), nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-shed:
+	case <-time.After(time.Second):
+		t.Fatal("the oldest campaign job was not shed")
+	}
+	release()
+
+	want := []string{"optout", "campaign-2"}
+	if got := collect(t, order, len(want)); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("jobs ran in order %v, want %v", got, want)
+	}
+}
+
+func TestPanickingJobDoesNotStopTheWorker(t *testing.T) {
+	pool := NewWorkerPool(&Config{WorkerPoolSize: 1, WorkerQueueDepth: 10, WorkerBackpressure: BackpressureReject})
+
+	order := make(chan string, 10)
+	if err := pool.Submit(func() { panic("boom") }, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Submit(recordRun(order, "after-panic"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, order, 1); got[0] != "after-panic" {
+		t.Fatalf("ran %s, want after-panic", got[0])
+	}
+	if stats := pool.Stats(); stats.Panicked != 1 {
+		t.Fatalf("panicked %d, want 1", stats.Panicked)
+	}
+}
+
+func TestBufferedSubmitWaitsForSpace(t *testing.T) {
+	pool := NewWorkerPool(&Config{WorkerPoolSize: 1, WorkerQueueDepth: 1, WorkerBackpressure: BackpressureBuffer, WorkerEnqueueTimeout: 2})
+	release := blockWorker(t, pool)
+
+	order := make(chan string, 10)
+	if err := pool.Submit(recordRun(order, "queued"), nil); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, release)
+	if err := pool.Submit(recordRun(order, "buffered"), nil); err != nil {
+		t.Fatalf("buffered submit returned %v once the worker freed up", err)
+	}
+
+	want := []string{"queued", "buffered"}
+	if got := collect(t, order, len(want)); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("jobs ran in order %v, want %v", got, want)
+	}
+}